@@ -28,6 +28,7 @@ import (
 	"github.com/rudderlabs/rudder-go-kit/config"
 	"github.com/rudderlabs/rudder-go-kit/testhelper/docker/resource/postgres"
 	"github.com/rudderlabs/rudder-server/app"
+	"github.com/rudderlabs/rudder-server/jobsdb"
 )
 
 func TestAppHandlerStartSequence(t *testing.T) {
@@ -239,3 +240,70 @@ func setDefaultEnv(t *testing.T) {
 	t.Setenv(config.ConfigKeyToEnv(config.DefaultEnvPrefix, "Profiler.Enabled"), "false")
 	t.Setenv(config.ConfigKeyToEnv(config.DefaultEnvPrefix, "Gateway.enableSuppressUserFeature"), "false")
 }
+
+func TestEmbeddedBackendWithReporting(t *testing.T) {
+	config.Reset()
+	defer config.Reset()
+
+	bcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/workspaceConfig":
+			response, _ := jsonrs.Marshal(backendconfig.ConfigT{WorkspaceID: "workspace-1"})
+			_, _ = w.Write(response)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer bcServer.Close()
+	trServer := transformertest.NewBuilder().Build()
+	defer trServer.Close()
+
+	gwPort, err := kithelper.GetFreePort()
+	require.NoError(t, err)
+
+	t.Setenv("CONFIG_BACKEND_URL", bcServer.URL)
+	t.Setenv("WORKSPACE_TOKEN", "token")
+	t.Setenv("DEST_TRANSFORM_URL", trServer.URL)
+	t.Setenv(config.ConfigKeyToEnv(config.DefaultEnvPrefix, "Gateway.webPort"), strconv.Itoa(gwPort))
+	t.Setenv(config.ConfigKeyToEnv(config.DefaultEnvPrefix, "JobsDB.backend"), jobsdb.BackendEmbedded)
+	t.Setenv(config.ConfigKeyToEnv(config.DefaultEnvPrefix, "JobsDB.embedded.dir"), t.TempDir())
+	t.Setenv(config.ConfigKeyToEnv(config.DefaultEnvPrefix, "TrackedUsers.enabled"), "true")
+	t.Setenv("JOBS_DB_HOST", "unreachable.invalid")
+	t.Setenv(config.ConfigKeyToEnv(config.DefaultEnvPrefix, "BackendConfig.dbCacheEnabled"), "false")
+	setDefaultEnv(t)
+	// reporting is enabled by the enterprise token, but needs postgres to sync its reports
+	t.Setenv(config.ConfigKeyToEnv(config.DefaultEnvPrefix, "Reporting.syncer.enabled"), "true")
+
+	options := app.LoadOptions([]string{"app"})
+	options.EnterpriseToken = "enterprise-token"
+	application := app.New(options)
+	application.Setup()
+	versionHandler := func(w http.ResponseWriter, _ *http.Request) {}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	diagnostics.Init()
+	backendconfig.Init()
+	stats.Default = stats.NOP
+	require.NoError(t, backendconfig.Setup(nil))
+	defer backendconfig.DefaultBackendConfig.Stop()
+	backendconfig.DefaultBackendConfig.StartWithIDs(ctx, "")
+
+	appHandler, err := GetAppHandler(application, app.EMBEDDED, versionHandler)
+	require.NoError(t, err)
+	require.NoError(t, appHandler.Setup())
+
+	wg, ctx := errgroup.WithContext(ctx)
+	wg.Go(func() error {
+		return appHandler.StartRudderCore(ctx, options)
+	})
+
+	url := fmt.Sprintf("http://localhost:%d", gwPort)
+	require.Eventually(t, func() bool {
+		return health.IsReady(t, url+"/health")
+	}, 30*time.Second, 1*time.Second, "embedded backend should start without postgres while reporting is enabled")
+
+	cancel()
+	require.NoError(t, wg.Wait())
+}
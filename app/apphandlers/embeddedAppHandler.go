@@ -16,10 +16,13 @@ import (
 	"github.com/rudderlabs/rudder-go-kit/stats"
 	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
 
+	"github.com/rudderlabs/rudder-server/admin"
 	"github.com/rudderlabs/rudder-server/app"
 	"github.com/rudderlabs/rudder-server/app/cluster"
 	"github.com/rudderlabs/rudder-server/archiver"
 	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	reportingfeature "github.com/rudderlabs/rudder-server/enterprise/reporting"
+	"github.com/rudderlabs/rudder-server/enterprise/trackedusers"
 	"github.com/rudderlabs/rudder-server/gateway"
 	gwThrottler "github.com/rudderlabs/rudder-server/gateway/throttler"
 	drain_config "github.com/rudderlabs/rudder-server/internal/drain-config"
@@ -32,11 +35,14 @@ import (
 	routerManager "github.com/rudderlabs/rudder-server/router/manager"
 	rtThrottler "github.com/rudderlabs/rudder-server/router/throttler"
 	schema_forwarder "github.com/rudderlabs/rudder-server/schema-forwarder"
+	"github.com/rudderlabs/rudder-server/services/backlogquota"
 	destinationdebugger "github.com/rudderlabs/rudder-server/services/debugger/destination"
 	sourcedebugger "github.com/rudderlabs/rudder-server/services/debugger/source"
 	transformationdebugger "github.com/rudderlabs/rudder-server/services/debugger/transformation"
 	"github.com/rudderlabs/rudder-server/services/fileuploader"
 	"github.com/rudderlabs/rudder-server/services/rmetrics"
+	"github.com/rudderlabs/rudder-server/services/rsources"
+	"github.com/rudderlabs/rudder-server/services/statuscdc"
 	"github.com/rudderlabs/rudder-server/services/transformer"
 	"github.com/rudderlabs/rudder-server/services/transientsource"
 	"github.com/rudderlabs/rudder-server/utils/crash"
@@ -59,6 +65,7 @@ type embeddedApp struct {
 		rtDSLimit        config.ValueLoader[int]
 		batchrtDSLimit   config.ValueLoader[int]
 		gwDSLimit        config.ValueLoader[int]
+		jobsdbBackend    string
 	}
 }
 
@@ -69,6 +76,19 @@ func (a *embeddedApp) Setup() error {
 	a.config.procErrorDSLimit = config.GetReloadableIntVar(0, 1, "JobsDB.proc_error.dsLimit", "Processor.jobsDB.dsLimit", "JobsDB.dsLimit")
 	a.config.eschDSLimit = config.GetReloadableIntVar(0, 1, "JobsDB.esch.dsLimit", "Processor.jobsDB.dsLimit", "JobsDB.dsLimit")
 	a.config.arcDSLimit = config.GetReloadableIntVar(0, 1, "JobsDB.arc.dsLimit", "Processor.jobsDB.dsLimit", "JobsDB.dsLimit")
+	backend, err := jobsdb.Backend(config.Default)
+	if err != nil {
+		return err
+	}
+	a.config.jobsdbBackend = backend
+	if backend == jobsdb.BackendEmbedded {
+		// the embedded jobsdb doesn't need postgres, as long as none of the services requiring it are enabled
+		if mode := config.GetString("Warehouse.mode", "embedded"); mode != config.OffMode {
+			return fmt.Errorf("the %s jobsdb backend requires Warehouse.mode to be %q, got %q", backend, config.OffMode, mode)
+		}
+		a.setupDone = true
+		return nil
+	}
 	if err := rudderCoreDBValidator(); err != nil {
 		return err
 	}
@@ -96,15 +116,25 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 	}
 	a.log.Infon("Configured deployment type", logger.NewStringField("deploymentType", string(deploymentType)))
 
-	trackedUsersReporter, err := a.app.Features().TrackedUsers.Setup(config)
-	if err != nil {
-		return fmt.Errorf("could not setup tracked users: %w", err)
-	}
-	err = trackedUsersReporter.MigrateDatabase(misc.GetConnectionString(config, "tracked_users"), config)
-	if err != nil {
-		return fmt.Errorf("could not run tracked users database migration: %w", err)
+	postgres := a.config.jobsdbBackend == jobsdb.BackendPostgres
+	var (
+		trackedUsersReporter trackedusers.UsersReporter = trackedusers.NewNoopDataCollector()
+		reporting            types.Reporting            = &reportingfeature.NOOP{}
+	)
+	if postgres {
+		trackedUsersReporter, err = a.app.Features().TrackedUsers.Setup(config)
+		if err != nil {
+			return fmt.Errorf("could not setup tracked users: %w", err)
+		}
+		err = trackedUsersReporter.MigrateDatabase(misc.GetConnectionString(config, "tracked_users"), config)
+		if err != nil {
+			return fmt.Errorf("could not run tracked users database migration: %w", err)
+		}
+		reporting = a.app.Features().Reporting.Setup(ctx, config, backendconfig.DefaultBackendConfig)
+	} else {
+		// reporting and tracked users are written in the same postgres transaction as the jobs, which the embedded jobsdb doesn't have
+		a.log.Warnn("Reporting and tracked users are disabled with the embedded jobsdb backend", logger.NewStringField("backend", a.config.jobsdbBackend))
 	}
-	reporting := a.app.Features().Reporting.Setup(ctx, config, backendconfig.DefaultBackendConfig)
 	defer reporting.Stop()
 	syncer := reporting.DatabaseSyncer(types.SyncerConfig{ConnInfo: misc.GetConnectionString(config, "reporting")})
 	g.Go(func() error {
@@ -134,9 +164,12 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 
 	fileUploaderProvider := fileuploader.NewProvider(ctx, backendconfig.DefaultBackendConfig)
 
-	rsourcesService, err := NewRsourcesService(deploymentType, true, statsFactory)
-	if err != nil {
-		return err
+	rsourcesService := rsources.NewNoOpService()
+	if postgres {
+		rsourcesService, err = NewRsourcesService(deploymentType, true, statsFactory)
+		if err != nil {
+			return err
+		}
 	}
 
 	transformerFeaturesService := transformer.NewFeaturesService(ctx, config, transformer.FeaturesServiceOptions{
@@ -146,7 +179,7 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 	})
 
 	var dbPool *sql.DB
-	if config.GetBoolVar(true, "db.pool.shared") {
+	if postgres && config.GetBoolVar(true, "db.pool.shared") {
 		dbPool, err = misc.NewDatabaseConnectionPool(ctx, config, statsFactory, "embedded-app")
 		if err != nil {
 			return err
@@ -154,10 +187,20 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 		defer dbPool.Close()
 	}
 
+	statusSink, err := statuscdc.New(config)
+	if err != nil {
+		return fmt.Errorf("creating job status cdc sink: %w", err)
+	}
+	if statusSink != nil {
+		defer func() { _ = statusSink.Close() }()
+	}
+
 	// This separate gateway db is created just to be used with gateway because in case of degraded mode,
 	// the earlier created gwDb (which was created to be used mainly with processor) will not be running, and it
 	// will cause issues for gateway because gateway is supposed to receive jobs even in degraded mode.
-	gatewayDB := jobsdb.NewForWrite(
+	gatewayDB := jobsdb.New(
+		a.config.jobsdbBackend,
+		jobsdb.Write,
 		"gw",
 		jobsdb.WithClearDB(options.ClearDB),
 		jobsdb.WithStats(statsFactory),
@@ -171,36 +214,50 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 
 	// This gwDBForProcessor should only be used by processor as this is supposed to be stopped and started with the
 	// Processor.
-	gwDBForProcessor := jobsdb.NewForRead(
+	gwDBForProcessor := jobsdb.New(
+		a.config.jobsdbBackend,
+		jobsdb.Read,
 		"gw",
 		jobsdb.WithClearDB(options.ClearDB),
 		jobsdb.WithDSLimit(a.config.gwDSLimit),
 		jobsdb.WithSkipMaintenanceErr(config.GetBool("Gateway.jobsDB.skipMaintenanceError", true)),
 		jobsdb.WithStats(statsFactory),
 		jobsdb.WithDBHandle(dbPool),
+		jobsdb.WithStatusTransitionSink(statusSink),
+		jobsdb.WithSnapshotStorage(fileUploaderProvider),
 	)
 	defer gwDBForProcessor.Close()
-	routerDB := jobsdb.NewForReadWrite(
+	routerDB := jobsdb.New(
+		a.config.jobsdbBackend,
+		jobsdb.ReadWrite,
 		"rt",
 		jobsdb.WithClearDB(options.ClearDB),
 		jobsdb.WithDSLimit(a.config.rtDSLimit),
 		jobsdb.WithSkipMaintenanceErr(config.GetBool("Router.jobsDB.skipMaintenanceError", false)),
 		jobsdb.WithStats(statsFactory),
 		jobsdb.WithDBHandle(dbPool),
+		jobsdb.WithStatusTransitionSink(statusSink),
+		jobsdb.WithSnapshotStorage(fileUploaderProvider),
 	)
 	defer routerDB.Close()
-	batchRouterDB := jobsdb.NewForReadWrite(
+	batchRouterDB := jobsdb.New(
+		a.config.jobsdbBackend,
+		jobsdb.ReadWrite,
 		"batch_rt",
 		jobsdb.WithClearDB(options.ClearDB),
 		jobsdb.WithDSLimit(a.config.batchrtDSLimit),
 		jobsdb.WithSkipMaintenanceErr(config.GetBool("BatchRouter.jobsDB.skipMaintenanceError", false)),
 		jobsdb.WithStats(statsFactory),
 		jobsdb.WithDBHandle(dbPool),
+		jobsdb.WithStatusTransitionSink(statusSink),
+		jobsdb.WithSnapshotStorage(fileUploaderProvider),
 	)
 	defer batchRouterDB.Close()
 
 	// We need two errorDBs, one in read & one in write mode to support separate gateway to store failures
-	errorDBForRead := jobsdb.NewForRead(
+	errorDBForRead := jobsdb.New(
+		a.config.jobsdbBackend,
+		jobsdb.Read,
 		"proc_error",
 		jobsdb.WithClearDB(options.ClearDB),
 		jobsdb.WithDSLimit(a.config.procErrorDSLimit),
@@ -209,7 +266,9 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 		jobsdb.WithDBHandle(dbPool),
 	)
 	defer errorDBForRead.Close()
-	errorDBForWrite := jobsdb.NewForWrite(
+	errorDBForWrite := jobsdb.New(
+		a.config.jobsdbBackend,
+		jobsdb.Write,
 		"proc_error",
 		jobsdb.WithClearDB(options.ClearDB),
 		jobsdb.WithSkipMaintenanceErr(config.GetBool("Processor.jobsDB.skipMaintenanceError", true)),
@@ -222,7 +281,9 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 	}
 	defer errorDBForWrite.Stop()
 
-	schemaDB := jobsdb.NewForReadWrite(
+	schemaDB := jobsdb.New(
+		a.config.jobsdbBackend,
+		jobsdb.ReadWrite,
 		"esch",
 		jobsdb.WithClearDB(options.ClearDB),
 		jobsdb.WithDSLimit(a.config.eschDSLimit),
@@ -232,7 +293,9 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 	)
 	defer schemaDB.Close()
 
-	archivalDB := jobsdb.NewForReadWrite(
+	archivalDB := jobsdb.New(
+		a.config.jobsdbBackend,
+		jobsdb.ReadWrite,
 		"arc",
 		jobsdb.WithClearDB(options.ClearDB),
 		jobsdb.WithDSLimit(a.config.arcDSLimit),
@@ -243,6 +306,18 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 	)
 	defer archivalDB.Close()
 
+	if postgres {
+		// exposes job search, inspection and requeuing to rudder-cli, using the same handles as the components reading the jobs
+		admin.RegisterAdminHandler("JobsDB", jobsdb.NewJobsAdmin(
+			gwDBForProcessor.(*jobsdb.Handle),
+			routerDB.(*jobsdb.Handle),
+			batchRouterDB.(*jobsdb.Handle),
+			errorDBForRead.(*jobsdb.Handle),
+			schemaDB.(*jobsdb.Handle),
+			archivalDB.(*jobsdb.Handle),
+		))
+	}
+
 	var schemaForwarder schema_forwarder.Forwarder
 	if config.GetBool("EventSchemas2.enabled", false) {
 		client, err := pulsar.NewClient(config)
@@ -359,26 +434,35 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create gw rate limiter: %w", err)
 	}
-	drainConfigManager, err := drain_config.NewDrainConfigManager(config, a.log.Child("drain-config"), statsFactory)
-	if err != nil {
-		return fmt.Errorf("drain config manager setup: %v", err)
+	internalHttpHandlers := map[string]http.Handler{}
+	if postgres {
+		drainConfigManager, err := drain_config.NewDrainConfigManager(config, a.log.Child("drain-config"), statsFactory)
+		if err != nil {
+			return fmt.Errorf("drain config manager setup: %v", err)
+		}
+		defer drainConfigManager.Stop()
+		g.Go(crash.Wrapper(func() (err error) {
+			return drainConfigManager.DrainConfigRoutine(ctx)
+		}))
+		g.Go(crash.Wrapper(func() (err error) {
+			return drainConfigManager.CleanupRoutine(ctx)
+		}))
+		internalHttpHandlers["/drain"] = drainConfigManager.DrainConfigHttpHandler()
 	}
-	defer drainConfigManager.Stop()
-	g.Go(crash.Wrapper(func() (err error) {
-		return drainConfigManager.DrainConfigRoutine(ctx)
-	}))
-	g.Go(crash.Wrapper(func() (err error) {
-		return drainConfigManager.CleanupRoutine(ctx)
+	backlogQuota := backlogquota.NewTracker(config, statsFactory, a.log, gatewayDB,
+		backlogquota.NewPendingEventsReader(pendingEventsRegistry, "rt", "batch_rt"),
+		backlogquota.NewPayloadSizeReader(routerDB),
+		backlogquota.NewPayloadSizeReader(batchRouterDB),
+	)
+	g.Go(crash.Wrapper(func() error {
+		backlogQuota.Run(ctx)
+		return nil
 	}))
 	streamMsgValidator := stream.NewMessageValidator()
 	gw := gateway.Handle{}
 	err = gw.Setup(ctx, config, logger.NewLogger().Child("gateway"), statsFactory, a.app, backendconfig.DefaultBackendConfig,
 		gatewayDB, errorDBForWrite, rateLimiter, a.versionHandler, rsourcesService, transformerFeaturesService, sourceHandle,
-		streamMsgValidator, gateway.WithInternalHttpHandlers(
-			map[string]http.Handler{
-				"/drain": drainConfigManager.DrainConfigHttpHandler(),
-			},
-		))
+		streamMsgValidator, gateway.WithInternalHttpHandlers(internalHttpHandlers), gateway.WithBacklogQuota(backlogQuota))
 	if err != nil {
 		return fmt.Errorf("could not setup gateway: %w", err)
 	}
@@ -410,7 +494,7 @@ func (a *embeddedApp) StartRudderCore(ctx context.Context, options *app.Options)
 		return nil
 	})
 
-	if config.GetBool("JobsDB.Bench.enabled", false) {
+	if postgres && config.GetBool("JobsDB.Bench.enabled", false) {
 		g.Go(func() error {
 			b, err := bench.New(config, statsFactory, a.log.Child("jobsdb.benchmark"), dbPool)
 			if err != nil {
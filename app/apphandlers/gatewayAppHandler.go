@@ -23,8 +23,10 @@ import (
 	gwThrottler "github.com/rudderlabs/rudder-server/gateway/throttler"
 	drain_config "github.com/rudderlabs/rudder-server/internal/drain-config"
 	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/backlogquota"
 	sourcedebugger "github.com/rudderlabs/rudder-server/services/debugger/source"
 	"github.com/rudderlabs/rudder-server/services/transformer"
+	"github.com/rudderlabs/rudder-server/utils/crash"
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	"github.com/rudderlabs/rudder-server/utils/types/deployment"
 )
@@ -143,6 +145,23 @@ func (a *gatewayApp) StartRudderCore(ctx context.Context, options *app.Options)
 		defer drainConfigManager.Stop()
 		drainConfigHttpHandler = drainConfigManager.DrainConfigHttpHandler()
 	}
+	// the router backlogs are only known to the processor's process, thus a standalone gateway scans the router jobsdbs of the same database
+	backlogDB := dbPool
+	if backlogDB == nil {
+		backlogDB, err = misc.NewDatabaseConnectionPool(ctx, config, statsFactory, "gateway-backlog-quota")
+		if err != nil {
+			return err
+		}
+		defer backlogDB.Close()
+	}
+	backlogQuota := backlogquota.NewTracker(config, statsFactory, a.log, gatewayDB,
+		jobsdb.NewBacklogReader(backlogDB, "rt"),
+		jobsdb.NewBacklogReader(backlogDB, "batch_rt"),
+	)
+	g.Go(crash.Wrapper(func() error {
+		backlogQuota.Run(ctx)
+		return nil
+	}))
 	streamMsgValidator := stream.NewMessageValidator()
 	err = gw.Setup(ctx, config, logger.NewLogger().Child("gateway"), statsFactory, a.app, backendconfig.DefaultBackendConfig,
 		gatewayDB, errorDB, rateLimiter, a.versionHandler, rsourcesService, transformerFeaturesService, sourceHandle,
@@ -150,7 +169,7 @@ func (a *gatewayApp) StartRudderCore(ctx context.Context, options *app.Options)
 			map[string]http.Handler{
 				"/drain": drainConfigHttpHandler,
 			},
-		))
+		), gateway.WithBacklogQuota(backlogQuota))
 	if err != nil {
 		return fmt.Errorf("failed to setup gateway: %w", err)
 	}
@@ -15,6 +15,7 @@ import (
 	kithttputil "github.com/rudderlabs/rudder-go-kit/httputil"
 	"github.com/rudderlabs/rudder-go-kit/logger"
 	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-server/admin"
 	"github.com/rudderlabs/rudder-server/app"
 	"github.com/rudderlabs/rudder-server/app/cluster"
 	"github.com/rudderlabs/rudder-server/archiver"
@@ -32,6 +33,7 @@ import (
 	transformationdebugger "github.com/rudderlabs/rudder-server/services/debugger/transformation"
 	"github.com/rudderlabs/rudder-server/services/fileuploader"
 	"github.com/rudderlabs/rudder-server/services/rmetrics"
+	"github.com/rudderlabs/rudder-server/services/statuscdc"
 	"github.com/rudderlabs/rudder-server/services/transformer"
 	"github.com/rudderlabs/rudder-server/services/transientsource"
 	"github.com/rudderlabs/rudder-server/utils/crash"
@@ -159,6 +161,14 @@ func (a *processorApp) StartRudderCore(ctx context.Context, options *app.Options
 		defer dbPool.Close()
 	}
 
+	statusSink, err := statuscdc.New(config)
+	if err != nil {
+		return fmt.Errorf("creating job status cdc sink: %w", err)
+	}
+	if statusSink != nil {
+		defer func() { _ = statusSink.Close() }()
+	}
+
 	gwDBForProcessor := jobsdb.NewForRead(
 		"gw",
 		jobsdb.WithClearDB(options.ClearDB),
@@ -166,6 +176,8 @@ func (a *processorApp) StartRudderCore(ctx context.Context, options *app.Options
 		jobsdb.WithSkipMaintenanceErr(config.GetBool("Gateway.jobsDB.skipMaintenanceError", true)),
 		jobsdb.WithStats(statsFactory),
 		jobsdb.WithDBHandle(dbPool),
+		jobsdb.WithStatusTransitionSink(statusSink),
+		jobsdb.WithSnapshotStorage(fileUploaderProvider),
 	)
 	defer gwDBForProcessor.Close()
 	routerDB := jobsdb.NewForReadWrite(
@@ -175,6 +187,8 @@ func (a *processorApp) StartRudderCore(ctx context.Context, options *app.Options
 		jobsdb.WithSkipMaintenanceErr(config.GetBool("Router.jobsDB.skipMaintenanceError", false)),
 		jobsdb.WithStats(statsFactory),
 		jobsdb.WithDBHandle(dbPool),
+		jobsdb.WithStatusTransitionSink(statusSink),
+		jobsdb.WithSnapshotStorage(fileUploaderProvider),
 	)
 	defer routerDB.Close()
 	batchRouterDB := jobsdb.NewForReadWrite(
@@ -184,6 +198,8 @@ func (a *processorApp) StartRudderCore(ctx context.Context, options *app.Options
 		jobsdb.WithSkipMaintenanceErr(config.GetBool("BatchRouter.jobsDB.skipMaintenanceError", false)),
 		jobsdb.WithStats(statsFactory),
 		jobsdb.WithDBHandle(dbPool),
+		jobsdb.WithStatusTransitionSink(statusSink),
+		jobsdb.WithSnapshotStorage(fileUploaderProvider),
 	)
 	defer batchRouterDB.Close()
 	errorDBForRead := jobsdb.NewForRead(
@@ -227,6 +243,9 @@ func (a *processorApp) StartRudderCore(ctx context.Context, options *app.Options
 	)
 	defer archivalDB.Close()
 
+	// exposes job search, inspection and requeuing to rudder-cli, using the same handles as the components reading the jobs
+	admin.RegisterAdminHandler("JobsDB", jobsdb.NewJobsAdmin(gwDBForProcessor, routerDB, batchRouterDB, errorDBForRead, schemaDB, archivalDB))
+
 	var schemaForwarder schema_forwarder.Forwarder
 	if config.GetBool("EventSchemas2.enabled", false) {
 		client, err := pulsar.NewClient(config)
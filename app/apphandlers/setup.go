@@ -15,6 +15,7 @@ import (
 	"github.com/rudderlabs/rudder-server/app/cluster"
 	"github.com/rudderlabs/rudder-server/app/cluster/state"
 	"github.com/rudderlabs/rudder-server/internal/enricher"
+	"github.com/rudderlabs/rudder-server/jobsdb"
 	"github.com/rudderlabs/rudder-server/services/rsources"
 	"github.com/rudderlabs/rudder-server/services/validators"
 	"github.com/rudderlabs/rudder-server/utils/misc"
@@ -32,6 +33,14 @@ type AppHandler interface {
 
 func GetAppHandler(application app.App, appType string, versionHandler func(w http.ResponseWriter, r *http.Request)) (AppHandler, error) {
 	log := logger.NewLogger().Child("apphandlers").Child(appType)
+	if appType != app.EMBEDDED {
+		// jobs of an embedded jobsdb can't be shared between the separate processes of a gateway and a processor
+		if backend, err := jobsdb.Backend(config.Default); err != nil {
+			return nil, err
+		} else if backend != jobsdb.BackendPostgres {
+			return nil, fmt.Errorf("the %s jobsdb backend is only supported by the %s app type", backend, app.EMBEDDED)
+		}
+	}
 	switch appType {
 	case app.GATEWAY:
 		return &gatewayApp{app: application, versionHandler: versionHandler, log: log}, nil
@@ -160,5 +169,25 @@ func setupPipelineEnrichers(conf *config.Config, log logger.Logger, stats stats.
 		enrichers = append(enrichers, botEnricher)
 	}
 
+	if conf.GetBool("UserAgentEnrichment.enabled", false) {
+		log.Infon("Setting up the user agent pipeline enricher")
+
+		userAgentEnricher, err := enricher.NewUserAgentEnricher(conf, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf("starting user agent enrichment process for pipeline: %w", err)
+		}
+		enrichers = append(enrichers, userAgentEnricher)
+	}
+
+	if conf.GetBool("LookupEnrichment.enabled", false) {
+		log.Infon("Setting up the lookup pipeline enricher")
+
+		lookupEnricher, err := enricher.NewLookupEnricher(conf, log, stats)
+		if err != nil {
+			return nil, fmt.Errorf("starting lookup enrichment process for pipeline: %w", err)
+		}
+		enrichers = append(enrichers, lookupEnricher)
+	}
+
 	return enrichers, nil
 }
@@ -3,10 +3,16 @@ package apphandlers
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+
+	"github.com/rudderlabs/rudder-server/app"
+	"github.com/rudderlabs/rudder-server/jobsdb"
 )
 
 func TestTerminalErrorFunction(t *testing.T) {
@@ -43,3 +49,42 @@ func TestTerminalErrorFunction(t *testing.T) {
 		require.NoError(t, g.Wait()) // all go routines shall return nil
 	})
 }
+
+func TestJobsDBBackend(t *testing.T) {
+	versionHandler := func(w http.ResponseWriter, _ *http.Request) {}
+
+	t.Run("embedded backend is only supported by the embedded app type", func(t *testing.T) {
+		t.Setenv("RSERVER_JOBS_DB_BACKEND", jobsdb.BackendEmbedded)
+		config.Reset()
+		defer config.Reset()
+		for _, appType := range []string{app.GATEWAY, app.PROCESSOR} {
+			_, err := GetAppHandler(nil, appType, versionHandler)
+			require.Error(t, err, appType)
+		}
+		_, err := GetAppHandler(nil, app.EMBEDDED, versionHandler)
+		require.NoError(t, err)
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		t.Setenv("RSERVER_JOBS_DB_BACKEND", "unknown")
+		config.Reset()
+		defer config.Reset()
+		_, err := GetAppHandler(nil, app.GATEWAY, versionHandler)
+		require.Error(t, err)
+	})
+
+	t.Run("embedded app sets up without postgres, as long as the warehouse is off", func(t *testing.T) {
+		t.Setenv("RSERVER_JOBS_DB_BACKEND", jobsdb.BackendEmbedded)
+		t.Setenv("RSERVER_WAREHOUSE_MODE", config.OffMode)
+		t.Setenv("JOBS_DB_HOST", "unreachable.invalid")
+		config.Reset()
+		defer config.Reset()
+		appHandler, err := GetAppHandler(nil, app.EMBEDDED, versionHandler)
+		require.NoError(t, err)
+		require.NoError(t, appHandler.Setup())
+
+		t.Setenv("RSERVER_WAREHOUSE_MODE", config.EmbeddedMode)
+		config.Reset()
+		require.Error(t, appHandler.Setup(), "warehouse needs postgres")
+	})
+}
@@ -113,6 +113,13 @@ type SourceT struct {
 	GeoEnrichment              struct {
 		Enabled bool
 	}
+	UserAgentEnrichment struct {
+		Enabled bool
+	}
+	LookupEnrichment struct {
+		Enabled bool
+		Tables  []string // names of the lookup tables the events of the source are enriched with, all the tables of its workspace if empty
+	}
 }
 
 type Credential struct {
@@ -0,0 +1,121 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+
+	"github.com/rudderlabs/rudder-server/gateway/capture"
+)
+
+// maxRecordSize is the maximum size of a record in a capture file
+const maxRecordSize = 200 * 1024 * 1024
+
+type ReplayInput struct {
+	File        string
+	URL         string
+	WriteKey    string
+	Rate        float64 // requests per second, unlimited if zero
+	Concurrency int
+	SourceID    string // only replay records of this source, if set
+}
+
+type ReplayOutput struct {
+	Requests int
+	Statuses map[int]int // number of responses per status code
+	Errors   int         // number of requests which failed without a response
+}
+
+func Replay(c *cli.Context) error {
+	input := ReplayInput{
+		File:        c.String("file"),
+		URL:         c.String("url"),
+		WriteKey:    c.String("write-key"),
+		Rate:        c.Float64("rate"),
+		Concurrency: c.Int("concurrency"),
+		SourceID:    c.String("source"),
+	}
+	if input.File == "" || input.URL == "" || input.WriteKey == "" {
+		return fmt.Errorf("file, url and write-key are required")
+	}
+	output, err := replay(c.Context, http.DefaultClient, input)
+	fmt.Printf("Replayed %d requests, %d failed without a response\n", output.Requests, output.Errors)
+	statuses := make([]int, 0, len(output.Statuses))
+	for status := range output.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Printf("  %d: %d\n", status, output.Statuses[status])
+	}
+	return err
+}
+
+// replay sends the requests of the capture file to the gateway, in the order they were captured, at the given rate
+func replay(ctx context.Context, client *http.Client, input ReplayInput) (ReplayOutput, error) {
+	output := ReplayOutput{Statuses: make(map[int]int)}
+	f, err := os.Open(input.File)
+	if err != nil {
+		return output, err
+	}
+	defer func() { _ = f.Close() }()
+
+	limit := rate.Inf
+	if input.Rate > 0 {
+		limit = rate.Limit(input.Rate)
+	}
+	limiter := rate.NewLimiter(limit, 1)
+	var (
+		mu sync.Mutex // protects output
+		g  errgroup.Group
+	)
+	g.SetLimit(max(input.Concurrency, 1))
+	reader := capture.NewReader(f, maxRecordSize)
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			_ = g.Wait()
+			return output, err
+		}
+		if input.SourceID != "" && record.SourceID != input.SourceID {
+			continue
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			_ = g.Wait()
+			return output, err
+		}
+		req, err := record.Request(ctx, input.URL, input.WriteKey)
+		if err != nil {
+			_ = g.Wait()
+			return output, fmt.Errorf("creating request for record captured at %s: %w", record.Time.Format(time.RFC3339), err)
+		}
+		g.Go(func() error {
+			resp, err := client.Do(req)
+			mu.Lock()
+			defer mu.Unlock()
+			output.Requests++
+			if err != nil {
+				output.Errors++
+				return nil
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			output.Statuses[resp.StatusCode]++
+			return nil
+		})
+	}
+	return output, g.Wait()
+}
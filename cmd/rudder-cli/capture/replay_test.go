@@ -0,0 +1,81 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+
+	"github.com/rudderlabs/rudder-server/gateway/capture"
+)
+
+func TestReplay(t *testing.T) {
+	// capture a couple of requests of two sources
+	dir := t.TempDir()
+	conf := config.New()
+	conf.Set("Gateway.capture.dir", dir)
+	capturer, err := capture.New(conf, logger.NOP, stats.NOP, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		capturer.Run(ctx)
+	}()
+	for _, sourceID := range []string{"source-1", "source-2", "source-1"} {
+		r := httptest.NewRequest(http.MethodPost, "/v1/batch", nil)
+		r.SetBasicAuth("original-key", "")
+		r.Header.Set("Content-Type", "application/json")
+		capturer.Capture(capture.NewRecord(r, "workspace-1", sourceID, []byte(`{"batch":[{"type":"track","event":"`+sourceID+`"}]}`)))
+	}
+	cancel()
+	<-done
+	files, err := filepath.Glob(filepath.Join(dir, "workspace-1", "capture-*.ndjson"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// replay the ones of source-1 against a fake gateway
+	type received struct {
+		path, writeKey, body string
+	}
+	var (
+		mu       sync.Mutex
+		requests []received
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeKey, _, _ := r.BasicAuth()
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, received{path: r.URL.Path, writeKey: writeKey, body: string(body)})
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	output, err := replay(context.Background(), srv.Client(), ReplayInput{
+		File:        files[0],
+		URL:         srv.URL,
+		WriteKey:    "replay-key",
+		Concurrency: 1,
+		SourceID:    "source-1",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, output.Requests)
+	require.Zero(t, output.Errors)
+	require.Equal(t, map[int]int{http.StatusOK: 2}, output.Statuses)
+	require.Len(t, requests, 2)
+	for _, req := range requests {
+		require.Equal(t, "/v1/batch", req.path)
+		require.Equal(t, "replay-key", req.writeKey)
+		require.JSONEq(t, `{"batch":[{"type":"track","event":"source-1"}]}`, req.body)
+	}
+}
@@ -0,0 +1,357 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rudderlabs/rudder-server/cmd/rudder-cli/client"
+)
+
+type SearchInput struct {
+	TablePrefix   string
+	WorkspaceID   string
+	SourceID      string
+	DestinationID string
+	States        []string
+	From          time.Time
+	To            time.Time
+	PayloadPath   string
+	PayloadValue  string
+	Limit         int
+}
+
+type JobSummary struct {
+	Dataset       string
+	JobID         int64
+	UUID          string
+	UserID        string
+	CustomVal     string
+	WorkspaceID   string
+	SourceID      string
+	DestinationID string
+	EventCount    int
+	CreatedAt     time.Time
+	State         string
+	AttemptNum    int
+	ExecTime      time.Time
+	ErrorCode     string
+}
+
+type InspectInput struct {
+	TablePrefix string
+	JobID       int64
+}
+
+type Job struct {
+	UUID         uuid.UUID
+	JobID        int64
+	UserID       string
+	CreatedAt    time.Time
+	ExpireAt     time.Time
+	CustomVal    string
+	EventCount   int
+	EventPayload []byte
+	Parameters   []byte
+	WorkspaceId  string
+}
+
+type JobStatus struct {
+	JobState      string
+	AttemptNum    int
+	ExecTime      time.Time
+	RetryTime     time.Time
+	ErrorCode     string
+	ErrorResponse []byte
+	Parameters    []byte
+}
+
+type JobDetails struct {
+	Dataset  string
+	Job      Job
+	Statuses []JobStatus
+}
+
+type RequeueInput struct {
+	TablePrefix string
+	JobIDs      []int64
+	State       string
+	Note        string
+}
+
+type RequeueResult struct {
+	Requeued []int64
+	Skipped  map[int64]string
+}
+
+type ExportInput struct {
+	TablePrefix  string
+	WorkspaceID  string
+	Path         string
+	MarkMigrated bool
+}
+
+type ExportResult struct {
+	Jobs     int
+	Migrated int
+}
+
+type ImportInput struct {
+	TablePrefix string
+	Path        string
+}
+
+type ImportResult struct {
+	Jobs int
+}
+
+type SnapshotsInput struct {
+	TablePrefix string
+	WorkspaceID string
+}
+
+type Snapshot struct {
+	ObjectName   string
+	LastModified time.Time
+}
+
+type RestoreInput struct {
+	TablePrefix string
+	WorkspaceID string
+	ObjectName  string
+	States      []string
+}
+
+type RestoreResult struct {
+	Jobs int
+}
+
+func Search(c *cli.Context) (err error) {
+	input := SearchInput{
+		TablePrefix:   c.String("db"),
+		WorkspaceID:   c.String("workspace"),
+		SourceID:      c.String("source"),
+		DestinationID: c.String("dest"),
+		States:        c.StringSlice("state"),
+		PayloadPath:   c.String("path"),
+		PayloadValue:  c.String("value"),
+		Limit:         c.Int("limit"),
+	}
+	if input.From, err = parseTime(c, "from"); err != nil {
+		return
+	}
+	if input.To, err = parseTime(c, "to"); err != nil {
+		return
+	}
+	if input.PayloadValue != "" && input.PayloadPath == "" {
+		return fmt.Errorf("a payload path is required for matching a payload value")
+	}
+
+	var reply []JobSummary
+	if err = client.GetUDSClient().Call("JobsDB.Search", input, &reply); err != nil {
+		return
+	}
+
+	table := newTable([]string{"Dataset", "JobID", "Workspace", "Source", "Destination", "CustomVal", "Events", "CreatedAt", "State", "Attempt", "ExecTime", "ErrorCode"})
+	for _, job := range reply {
+		table.Append([]string{
+			job.Dataset,
+			strconv.FormatInt(job.JobID, 10),
+			job.WorkspaceID,
+			job.SourceID,
+			job.DestinationID,
+			job.CustomVal,
+			strconv.Itoa(job.EventCount),
+			formatTime(job.CreatedAt),
+			job.State,
+			strconv.Itoa(job.AttemptNum),
+			formatTime(job.ExecTime),
+			job.ErrorCode,
+		})
+	}
+	table.Render()
+	fmt.Printf("%d jobs found\n", len(reply))
+	return
+}
+
+func Inspect(c *cli.Context) (err error) {
+	input := InspectInput{
+		TablePrefix: c.String("db"),
+		JobID:       c.Int64("job"),
+	}
+	var reply JobDetails
+	if err = client.GetUDSClient().Call("JobsDB.Inspect", input, &reply); err != nil {
+		return
+	}
+
+	job := reply.Job
+	fmt.Printf("Job %d (dataset %s)\n", job.JobID, reply.Dataset)
+	fmt.Printf("  UUID:        %s\n", job.UUID)
+	fmt.Printf("  UserID:      %s\n", job.UserID)
+	fmt.Printf("  Workspace:   %s\n", job.WorkspaceId)
+	fmt.Printf("  CustomVal:   %s\n", job.CustomVal)
+	fmt.Printf("  EventCount:  %d\n", job.EventCount)
+	fmt.Printf("  CreatedAt:   %s\n", formatTime(job.CreatedAt))
+	fmt.Printf("  ExpireAt:    %s\n", formatTime(job.ExpireAt))
+	fmt.Printf("  Parameters:  %s\n", job.Parameters)
+	fmt.Printf("  Payload:\n%s\n", indentJSON(job.EventPayload))
+
+	table := newTable([]string{"#", "State", "Attempt", "ExecTime", "RetryTime", "ErrorCode", "ErrorResponse"})
+	for i, status := range reply.Statuses {
+		table.Append([]string{
+			strconv.Itoa(i + 1),
+			status.JobState,
+			strconv.Itoa(status.AttemptNum),
+			formatTime(status.ExecTime),
+			formatTime(status.RetryTime),
+			status.ErrorCode,
+			string(status.ErrorResponse),
+		})
+	}
+	table.Render()
+	return
+}
+
+func Requeue(c *cli.Context) (err error) {
+	input := RequeueInput{
+		TablePrefix: c.String("db"),
+		JobIDs:      c.Int64Slice("job"),
+		State:       c.String("state"),
+		Note:        c.String("note"),
+	}
+	if len(input.JobIDs) == 0 {
+		return fmt.Errorf("no job IDs provided")
+	}
+	var reply RequeueResult
+	if err = client.GetUDSClient().Call("JobsDB.Requeue", input, &reply); err != nil {
+		return
+	}
+
+	fmt.Printf("Requeued %d jobs as %s: %v\n", len(reply.Requeued), input.State, reply.Requeued)
+	skipped := make([]int64, 0, len(reply.Skipped))
+	for jobID := range reply.Skipped {
+		skipped = append(skipped, jobID)
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i] < skipped[j] })
+	for _, jobID := range skipped {
+		fmt.Printf("Skipped job %d: %s\n", jobID, reply.Skipped[jobID])
+	}
+	return
+}
+
+func Export(c *cli.Context) (err error) {
+	input := ExportInput{
+		TablePrefix:  c.String("db"),
+		WorkspaceID:  c.String("workspace"),
+		MarkMigrated: c.Bool("mark-migrated"),
+	}
+	// the file is written by the server, which runs on the same host
+	if input.Path, err = filepath.Abs(c.String("file")); err != nil {
+		return
+	}
+	var reply ExportResult
+	if err = client.GetUDSClient().Call("JobsDB.Export", input, &reply); err != nil {
+		return
+	}
+	fmt.Printf("Exported %d pending jobs of workspace %s to %s\n", reply.Jobs, input.WorkspaceID, input.Path)
+	if input.MarkMigrated {
+		fmt.Printf("Marked %d exported jobs as migrated\n", reply.Migrated)
+	}
+	return
+}
+
+func Import(c *cli.Context) (err error) {
+	input := ImportInput{
+		TablePrefix: c.String("db"),
+	}
+	if input.Path, err = filepath.Abs(c.String("file")); err != nil {
+		return
+	}
+	var reply ImportResult
+	if err = client.GetUDSClient().Call("JobsDB.Import", input, &reply); err != nil {
+		return
+	}
+	fmt.Printf("Imported %d jobs from %s\n", reply.Jobs, input.Path)
+	return
+}
+
+func Snapshots(c *cli.Context) (err error) {
+	input := SnapshotsInput{
+		TablePrefix: c.String("db"),
+		WorkspaceID: c.String("workspace"),
+	}
+	var reply []Snapshot
+	if err = client.GetUDSClient().Call("JobsDB.Snapshots", input, &reply); err != nil {
+		return
+	}
+	table := newTable([]string{"ObjectName", "LastModified"})
+	for _, snapshot := range reply {
+		table.Append([]string{snapshot.ObjectName, formatTime(snapshot.LastModified)})
+	}
+	table.Render()
+	fmt.Printf("%d snapshots found\n", len(reply))
+	return
+}
+
+func Restore(c *cli.Context) (err error) {
+	input := RestoreInput{
+		TablePrefix: c.String("db"),
+		WorkspaceID: c.String("workspace"),
+		ObjectName:  c.String("snapshot"),
+		States:      c.StringSlice("state"),
+	}
+	var reply RestoreResult
+	if err = client.GetUDSClient().Call("JobsDB.Restore", input, &reply); err != nil {
+		return
+	}
+	fmt.Printf("Restored %d jobs from %s\n", reply.Jobs, input.ObjectName)
+	return
+}
+
+func parseTime(c *cli.Context, name string) (time.Time, error) {
+	if !c.IsSet(name) {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, c.String(name))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s time, expected RFC3339 format e.g. 2006-01-02T15:04:05Z: %w", name, err)
+	}
+	return t, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func indentJSON(data []byte) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "    ", "  "); err != nil {
+		return "    " + strings.TrimSpace(string(data))
+	}
+	return "    " + out.String()
+}
+
+func newTable(header []string) *tablewriter.Table {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	table.SetAutoFormatHeaders(false)
+	var headerColors []tablewriter.Colors
+	for i := 0; i < len(header); i++ {
+		headerColors = append(headerColors, tablewriter.Colors{tablewriter.Bold, tablewriter.BgCyanColor})
+	}
+	table.SetHeaderColor(headerColors...)
+	return table
+}
@@ -8,7 +8,9 @@ import (
 
 	"github.com/urfave/cli/v2"
 
+	"github.com/rudderlabs/rudder-server/cmd/rudder-cli/capture"
 	"github.com/rudderlabs/rudder-server/cmd/rudder-cli/client"
+	"github.com/rudderlabs/rudder-server/cmd/rudder-cli/jobs"
 	"github.com/rudderlabs/rudder-server/cmd/rudder-cli/warehouse"
 )
 
@@ -80,6 +82,260 @@ func main() {
 				return err
 			},
 		},
+		{
+			Name:  "replay-capture",
+			Usage: "Replay the requests of a gateway capture file against a gateway",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "file",
+					Usage:   `Specify the capture file to replay`,
+					Aliases: []string{"f"},
+				},
+				&cli.StringFlag{
+					Name:    "url",
+					Usage:   `Specify the url of the gateway to replay requests against`,
+					Aliases: []string{"u"},
+					Value:   "http://localhost:8080",
+				},
+				&cli.StringFlag{
+					Name:    "write-key",
+					Usage:   `Specify the write key replacing the redacted write key of captured requests`,
+					Aliases: []string{"w"},
+				},
+				&cli.StringFlag{
+					Name:  "source",
+					Usage: `Specify source ID to only replay requests of that source`,
+				},
+				&cli.Float64Flag{
+					Name:    "rate",
+					Usage:   `Specify the number of requests per second to replay, 0 means unlimited`,
+					Aliases: []string{"r"},
+					Value:   10,
+				},
+				&cli.IntFlag{
+					Name:    "concurrency",
+					Usage:   `Specify the maximum number of requests in flight`,
+					Aliases: []string{"c"},
+					Value:   1,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := capture.Replay(c)
+				return err
+			},
+		},
+		{
+			Name:  "jobs-search",
+			Usage: "Search jobs of a jobsdb, newest jobs first",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "db",
+					Usage:    `Specify the jobsdb to search in, e.g. gw, rt, batch_rt, proc_error`,
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:    "workspace",
+					Usage:   `Specify workspace ID to only return jobs of that workspace`,
+					Aliases: []string{"w"},
+				},
+				&cli.StringFlag{
+					Name:  "source",
+					Usage: `Specify source ID to only return jobs of that source`,
+				},
+				&cli.StringFlag{
+					Name:    "dest",
+					Usage:   `Specify destination ID to only return jobs of that destination`,
+					Aliases: []string{"d"},
+				},
+				&cli.StringSliceFlag{
+					Name:  "state",
+					Usage: `Specify the latest job state to only return jobs in that state, can be repeated. Use not_picked_yet for jobs without any status`,
+				},
+				&cli.StringFlag{
+					Name:  "from",
+					Usage: `Specify an RFC3339 time to only return jobs created at or after it`,
+				},
+				&cli.StringFlag{
+					Name:  "to",
+					Usage: `Specify an RFC3339 time to only return jobs created before it`,
+				},
+				&cli.StringFlag{
+					Name:  "path",
+					Usage: `Specify a dot separated JSON path, e.g. context.traits.email, to only return jobs whose event payload contains it`,
+				},
+				&cli.StringFlag{
+					Name:  "value",
+					Usage: `Specify the value expected at the JSON path`,
+				},
+				&cli.IntFlag{
+					Name:    "limit",
+					Usage:   `Specify the maximum number of jobs to return`,
+					Aliases: []string{"l"},
+					Value:   100,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := jobs.Search(c)
+				return err
+			},
+		},
+		{
+			Name:  "jobs-inspect",
+			Usage: "Inspect a job of a jobsdb along with its full status history",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "db",
+					Usage:    `Specify the jobsdb of the job, e.g. gw, rt, batch_rt, proc_error`,
+					Required: true,
+				},
+				&cli.Int64Flag{
+					Name:     "job",
+					Usage:    `Specify the job ID`,
+					Aliases:  []string{"j"},
+					Required: true,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := jobs.Inspect(c)
+				return err
+			},
+		},
+		{
+			Name:  "jobs-requeue",
+			Usage: "Requeue aborted jobs of a jobsdb so that they are retried",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "db",
+					Usage:    `Specify the jobsdb of the jobs, e.g. rt, batch_rt`,
+					Required: true,
+				},
+				&cli.Int64SliceFlag{
+					Name:     "job",
+					Usage:    `Specify the ID of an aborted job to requeue, can be repeated`,
+					Aliases:  []string{"j"},
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "state",
+					Usage: `Specify the state to requeue the jobs as, either waiting or failed`,
+					Value: "waiting",
+				},
+				&cli.StringFlag{
+					Name:     "note",
+					Usage:    `Specify an audit note explaining why the jobs are requeued`,
+					Aliases:  []string{"n"},
+					Required: true,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := jobs.Requeue(c)
+				return err
+			},
+		},
+		{
+			Name:  "jobs-export",
+			Usage: "Export the pending jobs of a workspace to a compressed file, for importing them into another data plane. The workspace should not be processed while exporting",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "db",
+					Usage:    `Specify the jobsdb to export jobs from, e.g. rt, batch_rt`,
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "workspace",
+					Usage:    `Specify the workspace ID whose pending jobs to export`,
+					Aliases:  []string{"w"},
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "file",
+					Usage:    `Specify the file to export the jobs to`,
+					Aliases:  []string{"f"},
+					Required: true,
+				},
+				&cli.BoolFlag{
+					Name:  "mark-migrated",
+					Usage: `Mark the exported jobs as migrated, so that they are not processed by this data plane anymore`,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := jobs.Export(c)
+				return err
+			},
+		},
+		{
+			Name:  "jobs-import",
+			Usage: "Import the jobs of a file created by jobs-export, preserving their state, attempts and parameters",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "db",
+					Usage:    `Specify the jobsdb to import jobs into, e.g. rt, batch_rt`,
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "file",
+					Usage:    `Specify the file to import the jobs from`,
+					Aliases:  []string{"f"},
+					Required: true,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := jobs.Import(c)
+				return err
+			},
+		},
+		{
+			Name:  "jobs-snapshots",
+			Usage: "List the snapshots of the jobs of a workspace, taken before jobsdb maintenance operations",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "db",
+					Usage:    `Specify the jobsdb whose snapshots to list, e.g. rt, batch_rt`,
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "workspace",
+					Usage:    `Specify the workspace ID whose snapshots to list`,
+					Aliases:  []string{"w"},
+					Required: true,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := jobs.Snapshots(c)
+				return err
+			},
+		},
+		{
+			Name:  "jobs-restore",
+			Usage: "Restore the jobs of a snapshot listed by jobs-snapshots. Jobs which were in a terminal state are processed again",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "db",
+					Usage:    `Specify the jobsdb to restore jobs into, e.g. rt, batch_rt`,
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "workspace",
+					Usage:    `Specify the workspace ID of the snapshot`,
+					Aliases:  []string{"w"},
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "snapshot",
+					Usage:    `Specify the object name of the snapshot`,
+					Aliases:  []string{"s"},
+					Required: true,
+				},
+				&cli.StringSliceFlag{
+					Name:  "state",
+					Usage: `Specify the state of the jobs to restore at the time of the snapshot, can be repeated. Use not_picked_yet for jobs without any status. Defaults to all but succeeded, filtered and migrated`,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := jobs.Restore(c)
+				return err
+			},
+		},
 		{
 			Name:  "logging",
 			Usage: "Set log level for module. It will affect the module and it's children",
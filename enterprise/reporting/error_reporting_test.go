@@ -60,6 +60,16 @@ func TestShouldReport(t *testing.T) {
 		},
 	}
 	Expect(shouldReport(metric4)).To(BeFalse())
+
+	// Test case 5: Filtering rule and sampling cases
+	for _, statusCode := range []int{types.FilterRuleEventCode, types.SampledOutEventCode} {
+		metric := types.PUReportedMetric{
+			StatusDetail: &types.StatusDetail{
+				StatusCode: statusCode,
+			},
+		}
+		Expect(shouldReport(metric)).To(BeTrue())
+	}
 }
 
 func TestCleanUpErrorMessage(t *testing.T) {
@@ -0,0 +1,165 @@
+// Package capture records sanitized raw gateway requests of selected sources for debugging purposes,
+// so that customer payloads can be replayed against another gateway without having to ask customers to resend them.
+//
+// Captured requests are written as newline-delimited JSON [Record]s to rotating files, one set of files per workspace.
+// Rotated files can optionally be uploaded to the workspace's object storage.
+//
+// Retention: locally, at most Gateway.capture.maxFiles rotated files of up to Gateway.capture.maxFileSizeInMB are kept per workspace,
+// the oldest ones being deleted first. Files are rotated once they reach that size or have been written to for Gateway.capture.maxFileAge. Uploaded files are stored under the gw-captures prefix of the workspace's object storage
+// and are subject to that storage's own retention policy, rudder-server never deletes them.
+//
+// Only requests going through the regular HTTP ingestion endpoints are captured, after applying the PII rules of their source.
+// Requests received through gRPC or the streaming import endpoint are not captured.
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+
+	"github.com/rudderlabs/rudder-server/gateway/internal/ndjson"
+)
+
+// Redacted replaces the write key and other credentials of captured requests
+const Redacted = "[REDACTED]"
+
+// redactedHeaders are the headers whose values are replaced with [Redacted]
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// droppedHeaders are the headers which don't apply to the captured body, since it is captured after being decompressed,
+// along with the ones carrying the client's IP address, which must not be captured
+var droppedHeaders = []string{
+	"Content-Length", "Content-Encoding", "Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade",
+	"X-Forwarded-For", "X-Real-Ip", "Forwarded", "True-Client-Ip", "Cf-Connecting-Ip", "X-Client-Ip",
+}
+
+// Record is a captured request
+type Record struct {
+	Time        time.Time       `json:"time"`
+	WorkspaceID string          `json:"workspaceId"`
+	SourceID    string          `json:"sourceId"`
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Query       string          `json:"query,omitempty"`
+	Header      http.Header     `json:"header"`
+	Body        json.RawMessage `json:"body,omitempty"`    // set for JSON bodies
+	RawBody     []byte          `json:"rawBody,omitempty"` // set for any other body, base64 encoded
+}
+
+// NewRecord returns a sanitized record of the request and its (decompressed) body,
+// with the write key redacted from the Authorization header, the writeKey query parameter and the body's writeKey field.
+// The client's IP address is never captured: neither the remote address nor the headers forwarding it are part of the record.
+func NewRecord(r *http.Request, workspaceID, sourceID string, body []byte) Record {
+	record := Record{
+		Time:        time.Now(),
+		WorkspaceID: workspaceID,
+		SourceID:    sourceID,
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		Header:      r.Header.Clone(),
+	}
+	for _, h := range redactedHeaders {
+		if record.Header.Get(h) != "" {
+			record.Header.Set(h, Redacted)
+		}
+	}
+	for _, h := range droppedHeaders {
+		record.Header.Del(h)
+	}
+	if query := r.URL.Query(); len(query) > 0 {
+		if query.Has("writeKey") {
+			query.Set("writeKey", Redacted)
+		}
+		record.Query = query.Encode()
+	}
+	if gjson.ValidBytes(body) {
+		body = bytes.Clone(body)
+		if gjson.GetBytes(body, "writeKey").Type == gjson.String {
+			body, _ = sjson.SetBytes(body, "writeKey", Redacted)
+		}
+		record.Body = body
+	} else if len(body) > 0 {
+		record.RawBody = bytes.Clone(body)
+	}
+	return record
+}
+
+// Request returns a request replaying the record against the gateway at baseURL,
+// authenticated with writeKey wherever the original write key was redacted.
+func (r *Record) Request(ctx context.Context, baseURL, writeKey string) (*http.Request, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + r.Path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %w", err)
+	}
+	if r.Query != "" {
+		query, err := url.ParseQuery(r.Query)
+		if err != nil {
+			return nil, fmt.Errorf("parsing query: %w", err)
+		}
+		if query.Get("writeKey") == Redacted {
+			query.Set("writeKey", writeKey)
+		}
+		u.RawQuery = query.Encode()
+	}
+	body := r.RawBody
+	if len(r.Body) > 0 {
+		body = r.Body
+		if gjson.GetBytes(body, "writeKey").String() == Redacted {
+			if body, err = sjson.SetBytes(bytes.Clone(body), "writeKey", writeKey); err != nil {
+				return nil, fmt.Errorf("setting writeKey in body: %w", err)
+			}
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range r.Header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if req.Header.Get("Authorization") == Redacted {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(writeKey+":")))
+	}
+	return req, nil
+}
+
+// Reader reads records from a capture file
+type Reader struct {
+	r *ndjson.Reader
+}
+
+// NewReader returns a reader for records of up to maxRecordSize bytes
+func NewReader(r io.Reader, maxRecordSize int) *Reader {
+	return &Reader{r: ndjson.NewReader(r, maxRecordSize)}
+}
+
+// Next returns the next record, or [io.EOF] once there are no more records
+func (r *Reader) Next() (Record, error) {
+	var record Record
+	line, lineNo, err := r.r.Next()
+	if errors.Is(err, io.EOF) {
+		return record, err
+	}
+	if err != nil {
+		return record, fmt.Errorf("reading record at line %d: %w", lineNo, err)
+	}
+	if err := jsonrs.Unmarshal(line, &record); err != nil {
+		return record, fmt.Errorf("decoding record at line %d: %w", lineNo, err)
+	}
+	return record, nil
+}
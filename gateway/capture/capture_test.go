@@ -0,0 +1,106 @@
+package capture_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+
+	"github.com/rudderlabs/rudder-server/gateway/capture"
+)
+
+func TestRecord(t *testing.T) {
+	t.Run("sanitizes json requests", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/batch?writeKey=secret&foo=bar", nil)
+		r.SetBasicAuth("secret", "")
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Content-Encoding", "gzip")
+		r.Header.Set("Cookie", "session=secret")
+		r.Header.Set("AnonymousId", "anon-1")
+		r.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.1")
+		r.Header.Set("X-Real-IP", "1.2.3.4")
+		r.RemoteAddr = "1.2.3.4:1234"
+
+		record := capture.NewRecord(r, "workspace-1", "source-1", []byte(`{"writeKey":"secret","batch":[{"type":"track"}]}`))
+		require.Equal(t, "workspace-1", record.WorkspaceID)
+		require.Equal(t, "source-1", record.SourceID)
+		require.Equal(t, http.MethodPost, record.Method)
+		require.Equal(t, "/v1/batch", record.Path)
+		require.Equal(t, "foo=bar&writeKey=%5BREDACTED%5D", record.Query)
+		require.Equal(t, http.Header{
+			"Authorization": {capture.Redacted},
+			"Content-Type":  {"application/json"},
+			"Cookie":        {capture.Redacted},
+			"Anonymousid":   {"anon-1"},
+		}, record.Header)
+		require.JSONEq(t, `{"writeKey":"[REDACTED]","batch":[{"type":"track"}]}`, string(record.Body))
+		require.Empty(t, record.RawBody)
+		require.NotContains(t, string(mustMarshal(t, record)), "secret")
+		require.NotContains(t, string(mustMarshal(t, record)), "1.2.3.4")
+	})
+
+	t.Run("keeps non json bodies as raw bytes", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/traces", nil)
+		record := capture.NewRecord(r, "workspace-1", "source-1", []byte{0x0a, 0x01, 0xff})
+		require.Empty(t, record.Body)
+		require.Equal(t, []byte{0x0a, 0x01, 0xff}, record.RawBody)
+	})
+
+	t.Run("replays with the given write key", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/batch?writeKey=secret", nil)
+		r.SetBasicAuth("secret", "")
+		r.Header.Set("AnonymousId", "anon-1")
+		record := capture.NewRecord(r, "workspace-1", "source-1", []byte(`{"writeKey":"secret","batch":[]}`))
+
+		req, err := record.Request(context.Background(), "http://gateway:8080/", "replay-key")
+		require.NoError(t, err)
+		require.Equal(t, http.MethodPost, req.Method)
+		require.Equal(t, "http://gateway:8080/v1/batch?writeKey=replay-key", req.URL.String())
+		require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("replay-key:")), req.Header.Get("Authorization"))
+		require.Equal(t, "anon-1", req.Header.Get("AnonymousId"))
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"writeKey":"replay-key","batch":[]}`, string(body))
+	})
+}
+
+func TestReader(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/track", nil)
+	first := capture.NewRecord(r, "workspace-1", "source-1", []byte(`{"event":"first"}`))
+	second := capture.NewRecord(r, "workspace-1", "source-2", []byte(`{"event":"second"}`))
+	var buf bytes.Buffer
+	buf.Write(mustMarshal(t, first))
+	buf.WriteString("\n")
+	buf.Write(mustMarshal(t, second))
+	buf.WriteString("\n")
+
+	reader := capture.NewReader(&buf, 1024)
+	record, err := reader.Next()
+	require.NoError(t, err)
+	require.Equal(t, "source-1", record.SourceID)
+	require.JSONEq(t, `{"event":"first"}`, string(record.Body))
+	record, err = reader.Next()
+	require.NoError(t, err)
+	require.Equal(t, "source-2", record.SourceID)
+	_, err = reader.Next()
+	require.True(t, errors.Is(err, io.EOF))
+
+	_, err = capture.NewReader(strings.NewReader("not json\n"), 1024).Next()
+	require.ErrorContains(t, err, "decoding record at line 1")
+}
+
+func mustMarshal(t *testing.T, record capture.Record) []byte {
+	t.Helper()
+	b, err := jsonrs.Marshal(record)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,244 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/services/fileuploader"
+)
+
+const (
+	activeFileName    = "capture.ndjson"
+	rotatedFilePrefix = "capture-"
+	rotatedFileSuffix = ".ndjson"
+)
+
+// Capturer writes captured requests to rotating files in the background, without ever blocking the requests being captured
+type Capturer struct {
+	dir         string
+	maxFileSize int64
+	maxFileAge  time.Duration // zero means active files are only rotated by size
+	maxFiles    int
+	instanceID  string
+	uploader    fileuploader.Provider // optional, rotated files are uploaded to the workspace's object storage if set
+	log         logger.Logger
+
+	records      chan Record
+	files        map[string]*captureFile // active file per workspace
+	droppedStat  stats.Counter
+	capturedStat stats.Counter
+	uploadedStat stats.Counter
+}
+
+type captureFile struct {
+	f        *os.File
+	w        *bufio.Writer
+	size     int64
+	openedAt time.Time
+}
+
+// New returns a capturer configured with Gateway.capture.* settings.
+// If uploader is not nil, rotated files are uploaded to the object storage of their workspace instead of being kept locally.
+func New(conf *config.Config, log logger.Logger, stat stats.Stats, uploader fileuploader.Provider) (*Capturer, error) {
+	dir := conf.GetString("Gateway.capture.dir", "")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "rudder-gateway-captures")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating capture directory: %w", err)
+	}
+	return &Capturer{
+		dir:          dir,
+		maxFileSize:  conf.GetInt64Var(100, 1<<20, "Gateway.capture.maxFileSizeInMB"),
+		maxFileAge:   conf.GetDurationVar(1, time.Hour, "Gateway.capture.maxFileAge"),
+		maxFiles:     conf.GetIntVar(10, 1, "Gateway.capture.maxFiles"),
+		instanceID:   conf.GetString("INSTANCE_ID", "unknown-instance"),
+		uploader:     uploader,
+		log:          log,
+		records:      make(chan Record, conf.GetIntVar(1000, 1, "Gateway.capture.bufferSize")),
+		files:        make(map[string]*captureFile),
+		droppedStat:  stat.NewStat("gateway.capture.dropped_requests", stats.CountType),
+		capturedStat: stat.NewStat("gateway.capture.captured_requests", stats.CountType),
+		uploadedStat: stat.NewStat("gateway.capture.uploaded_files", stats.CountType),
+	}, nil
+}
+
+// Capture queues the record for being written, dropping it if the capturer can't keep up
+func (c *Capturer) Capture(record Record) {
+	select {
+	case c.records <- record:
+	default:
+		c.droppedStat.Increment()
+	}
+}
+
+// Run writes queued records until the context is cancelled, rotating all active files before returning.
+// Active files are also rotated once they have been open for maxFileAge, so that captures of workspaces
+// with little traffic get uploaded or can be replayed without waiting for them to reach maxFileSize.
+func (c *Capturer) Run(ctx context.Context) {
+	c.log.Infon("Starting request capture", logger.NewStringField("dir", c.dir))
+	var rotateTick <-chan time.Time
+	if c.maxFileAge > 0 {
+		ticker := time.NewTicker(min(c.maxFileAge, time.Minute))
+		defer ticker.Stop()
+		rotateTick = ticker.C
+	}
+	for {
+		select {
+		case now := <-rotateTick:
+			for workspaceID, file := range c.files {
+				if now.Sub(file.openedAt) >= c.maxFileAge {
+					c.rotate(ctx, workspaceID)
+				}
+			}
+		case <-ctx.Done():
+			ctx := context.WithoutCancel(ctx)
+			for len(c.records) > 0 {
+				c.writeRecord(ctx, <-c.records)
+			}
+			for workspaceID := range c.files {
+				c.rotate(ctx, workspaceID)
+			}
+			return
+		case record := <-c.records:
+			c.writeRecord(ctx, record)
+		}
+	}
+}
+
+func (c *Capturer) writeRecord(ctx context.Context, record Record) {
+	if err := c.write(ctx, record); err != nil {
+		c.log.Warnn("Failed to write captured request", obskit.WorkspaceID(record.WorkspaceID), obskit.Error(err))
+		return
+	}
+	c.capturedStat.Increment()
+}
+
+func (c *Capturer) write(ctx context.Context, record Record) error {
+	file, err := c.activeFile(record.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	line, err := jsonrs.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshalling record: %w", err)
+	}
+	line = append(line, '\n')
+	if _, err := file.w.Write(line); err != nil {
+		return fmt.Errorf("writing record: %w", err)
+	}
+	// flush after every record, so that captures can be inspected while being written
+	if err := file.w.Flush(); err != nil {
+		return fmt.Errorf("flushing record: %w", err)
+	}
+	file.size += int64(len(line))
+	if file.size >= c.maxFileSize {
+		c.rotate(ctx, record.WorkspaceID)
+	}
+	return nil
+}
+
+// activeFile returns the file records of the workspace are being written to, opening it if needed.
+// Records are appended to the active file left behind by a previous run, if any.
+func (c *Capturer) activeFile(workspaceID string) (*captureFile, error) {
+	if file, ok := c.files[workspaceID]; ok {
+		return file, nil
+	}
+	dir := filepath.Join(c.dir, workspaceID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating workspace capture directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, activeFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening capture file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("reading capture file info: %w", err)
+	}
+	file := &captureFile{f: f, w: bufio.NewWriter(f), size: info.Size(), openedAt: time.Now()}
+	c.files[workspaceID] = file
+	return file, nil
+}
+
+// rotate closes the active file of the workspace, renaming it with the current time, and either uploads it or prunes the oldest rotated files
+func (c *Capturer) rotate(ctx context.Context, workspaceID string) {
+	file, ok := c.files[workspaceID]
+	if !ok {
+		return
+	}
+	delete(c.files, workspaceID)
+	log := c.log.Withn(obskit.WorkspaceID(workspaceID))
+	_ = file.w.Flush()
+	if err := file.f.Close(); err != nil {
+		log.Warnn("Failed to close capture file", obskit.Error(err))
+	}
+	dir := filepath.Join(c.dir, workspaceID)
+	rotated := filepath.Join(dir, rotatedFilePrefix+strconv.FormatInt(time.Now().UnixNano(), 10)+rotatedFileSuffix)
+	if err := os.Rename(file.f.Name(), rotated); err != nil {
+		log.Warnn("Failed to rotate capture file", obskit.Error(err))
+		return
+	}
+	if c.uploader != nil {
+		if err := c.upload(ctx, workspaceID, rotated); err != nil {
+			log.Warnn("Failed to upload capture file, keeping it locally", logger.NewStringField("file", rotated), obskit.Error(err))
+		} else {
+			c.uploadedStat.Increment()
+			_ = os.Remove(rotated)
+		}
+	}
+	c.prune(dir)
+}
+
+func (c *Capturer) upload(ctx context.Context, workspaceID, name string) error {
+	fm, err := c.uploader.GetFileManager(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("getting file manager: %w", err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("opening capture file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	uploaded, err := fm.Upload(ctx, f, "gw-captures", c.instanceID, workspaceID, time.Now().Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	c.log.Infon("Capture file uploaded", obskit.WorkspaceID(workspaceID), logger.NewStringField("location", uploaded.Location), logger.NewStringField("file", filepath.Base(name)))
+	return nil
+}
+
+// prune removes the oldest rotated files of the directory, keeping at most maxFiles of them
+func (c *Capturer) prune(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var rotated []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), rotatedFilePrefix) && strings.HasSuffix(e.Name(), rotatedFileSuffix) {
+			rotated = append(rotated, e.Name())
+		}
+	}
+	if len(rotated) <= c.maxFiles {
+		return
+	}
+	slices.Sort(rotated) // names end with a fixed width timestamp, so they sort chronologically
+	for _, name := range rotated[:len(rotated)-c.maxFiles] {
+		_ = os.Remove(filepath.Join(dir, name))
+	}
+}
@@ -0,0 +1,168 @@
+package capture_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/filemanager"
+	"github.com/rudderlabs/rudder-go-kit/filemanager/mock_filemanager"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/gateway/capture"
+	"github.com/rudderlabs/rudder-server/services/fileuploader"
+)
+
+func TestCapturer(t *testing.T) {
+	newCapturer := func(t *testing.T, uploader fileuploader.Provider) (*capture.Capturer, string) {
+		t.Helper()
+		dir := t.TempDir()
+		conf := config.New()
+		conf.Set("Gateway.capture.dir", dir)
+		conf.Set("Gateway.capture.maxFileSizeInMB", 1)
+		conf.Set("Gateway.capture.maxFiles", 2)
+		c, err := capture.New(conf, logger.NOP, stats.NOP, uploader)
+		require.NoError(t, err)
+		return c, dir
+	}
+	run := func(c *capture.Capturer) (stop func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Run(ctx)
+		}()
+		return func() {
+			cancel()
+			wg.Wait()
+		}
+	}
+	largeRecord := func(workspaceID string) capture.Record {
+		r := httptest.NewRequest(http.MethodPost, "/v1/track", nil)
+		return capture.NewRecord(r, workspaceID, "source-1", []byte(`{"event":"`+strings.Repeat("x", 400*1024)+`"}`))
+	}
+	files := func(t *testing.T, dir string) []string {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	t.Run("writes records to rotating files per workspace", func(t *testing.T) {
+		c, dir := newCapturer(t, nil)
+		stop := run(c)
+		for range 10 {
+			c.Capture(largeRecord("workspace-1"))
+		}
+		c.Capture(largeRecord("workspace-2"))
+		stop()
+
+		// 10 records of 400KB: a file is rotated every 3 records, only the 2 most recent rotated files being kept
+		names := files(t, filepath.Join(dir, "workspace-1"))
+		require.Len(t, names, 2)
+		for _, name := range names {
+			require.True(t, strings.HasPrefix(name, "capture-"), name)
+		}
+		require.Len(t, files(t, filepath.Join(dir, "workspace-2")), 1)
+
+		f, err := os.Open(filepath.Join(dir, "workspace-2", files(t, filepath.Join(dir, "workspace-2"))[0]))
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		record, err := capture.NewReader(f, 1024*1024).Next()
+		require.NoError(t, err)
+		require.Equal(t, "workspace-2", record.WorkspaceID)
+		require.Equal(t, "/v1/track", record.Path)
+	})
+
+	t.Run("rotates files open for longer than the maximum file age", func(t *testing.T) {
+		dir := t.TempDir()
+		conf := config.New()
+		conf.Set("Gateway.capture.dir", dir)
+		conf.Set("Gateway.capture.maxFileAge", "100ms")
+		c, err := capture.New(conf, logger.NOP, stats.NOP, nil)
+		require.NoError(t, err)
+		stop := run(c)
+		defer stop()
+		c.Capture(capture.NewRecord(httptest.NewRequest(http.MethodPost, "/v1/track", nil), "workspace-1", "source-1", []byte(`{"event":"small"}`)))
+
+		require.Eventually(t, func() bool {
+			entries, err := os.ReadDir(filepath.Join(dir, "workspace-1"))
+			return err == nil && len(entries) == 1 && strings.HasPrefix(entries[0].Name(), "capture-")
+		}, 5*time.Second, 10*time.Millisecond, "the active file should be rotated without reaching the maximum file size")
+	})
+
+	t.Run("uploads rotated files", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		fm := mock_filemanager.NewMockFileManager(ctrl)
+		var uploaded []string
+		fm.EXPECT().Upload(gomock.Any(), gomock.Any(), "gw-captures", gomock.Any(), "workspace-1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, f *os.File, prefixes ...string) (filemanager.UploadedFile, error) {
+				uploaded = append(uploaded, filepath.Base(f.Name()))
+				return filemanager.UploadedFile{Location: "s3://bucket/" + filepath.Base(f.Name())}, nil
+			}).Times(2)
+		c, dir := newCapturer(t, &staticUploader{fm: fm})
+		stop := run(c)
+		for range 4 {
+			c.Capture(largeRecord("workspace-1"))
+		}
+		stop()
+
+		require.Len(t, uploaded, 2)
+		require.Empty(t, files(t, filepath.Join(dir, "workspace-1")), "uploaded files should be removed")
+	})
+
+	t.Run("drops records if the buffer is full", func(t *testing.T) {
+		dir := t.TempDir()
+		conf := config.New()
+		conf.Set("Gateway.capture.dir", dir)
+		conf.Set("Gateway.capture.bufferSize", 1)
+		c, err := capture.New(conf, logger.NOP, stats.NOP, nil)
+		require.NoError(t, err)
+		c.Capture(largeRecord("workspace-1"))
+		c.Capture(largeRecord("workspace-1")) // dropped, since the capturer isn't running
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		cancel()
+		c.Run(ctx)
+
+		f, err := os.Open(filepath.Join(dir, "workspace-1", files(t, filepath.Join(dir, "workspace-1"))[0]))
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		reader := capture.NewReader(f, 1024*1024)
+		_, err = reader.Next()
+		require.NoError(t, err)
+		_, err = reader.Next()
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
+
+type staticUploader struct {
+	fm filemanager.FileManager
+}
+
+func (u *staticUploader) GetFileManager(context.Context, string) (filemanager.FileManager, error) {
+	return u.fm, nil
+}
+
+func (u *staticUploader) GetStoragePreferences(context.Context, string) (backendconfig.StoragePreferences, error) {
+	return backendconfig.StoragePreferences{}, nil
+}
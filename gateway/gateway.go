@@ -3,7 +3,11 @@ package gateway
 import (
 	_ "embed"
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
+
+	"github.com/rudderlabs/rudder-server/gateway/response"
 )
 
 /*
@@ -33,10 +37,31 @@ var (
 )
 
 var (
-	errRequestDropped    = errors.New("request dropped")
-	errRequestSuppressed = errors.New("request suppressed")
-	errEventSuppressed   = errors.New("event suppressed")
+	errRequestDropped       = errors.New("request dropped")
+	errRequestSuppressed    = errors.New("request suppressed")
+	errEventSuppressed      = errors.New("event suppressed")
+	errInvalidEventsDropped = errors.New("invalid events dropped")
+	errBacklogQuotaExceeded = errors.New("backlog quota exceeded")
 )
 
+// maxEventSchemaViolations is the maximum number of event schema violations included in a response
+const maxEventSchemaViolations = 20
+
+// eventSchemaError is returned for requests rejected because of events violating the event schema of their source
+type eventSchemaError struct {
+	violations []string
+}
+
+// Error returns the response message, including the violations
+func (e *eventSchemaError) Error() string {
+	violations := e.violations
+	var more string
+	if len(violations) > maxEventSchemaViolations {
+		more = fmt.Sprintf("; and %d more", len(violations)-maxEventSchemaViolations)
+		violations = violations[:maxEventSchemaViolations]
+	}
+	return response.WithDetails(response.InvalidEventSchema, strings.Join(violations, "; ")+more)
+}
+
 //go:embed openapi/index.html
 var openApiSpec []byte
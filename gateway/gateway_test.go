@@ -7,15 +7,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ory/dockertest/v3"
+	"github.com/samber/lo"
 
 	"github.com/rudderlabs/rudder-go-kit/testhelper/docker/resource/minio"
 
@@ -36,6 +43,12 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/rudderlabs/rudder-go-kit/config"
 	"github.com/rudderlabs/rudder-go-kit/logger"
@@ -46,14 +59,19 @@ import (
 	"github.com/rudderlabs/rudder-server/app"
 	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
 	"github.com/rudderlabs/rudder-server/enterprise/suppress-user/model"
+	"github.com/rudderlabs/rudder-server/gateway/capture"
+	"github.com/rudderlabs/rudder-server/gateway/internal/eventschema"
+	"github.com/rudderlabs/rudder-server/gateway/internal/pii"
 	gwstats "github.com/rudderlabs/rudder-server/gateway/internal/stats"
 	"github.com/rudderlabs/rudder-server/gateway/response"
+	"github.com/rudderlabs/rudder-server/gateway/throttler"
 	"github.com/rudderlabs/rudder-server/jobsdb"
 	mocksApp "github.com/rudderlabs/rudder-server/mocks/app"
 	mocksBackendConfig "github.com/rudderlabs/rudder-server/mocks/backend-config"
 	mockGateway "github.com/rudderlabs/rudder-server/mocks/gateway"
 	mocksJobsDB "github.com/rudderlabs/rudder-server/mocks/jobsdb"
 	mocksTypes "github.com/rudderlabs/rudder-server/mocks/utils/types"
+	proto "github.com/rudderlabs/rudder-server/proto/gateway"
 	sourcedebugger "github.com/rudderlabs/rudder-server/services/debugger/source"
 	mocksrcdebugger "github.com/rudderlabs/rudder-server/services/debugger/source/mocks"
 	"github.com/rudderlabs/rudder-server/services/rsources"
@@ -875,6 +893,463 @@ var _ = Describe("Gateway", func() {
 				)
 			}
 		})
+
+		It("should accept OTLP/JSON traces and store them as track events", func() {
+			c.mockJobsDB.EXPECT().WithStoreSafeTx(gomock.Any(), gomock.Any()).Times(1).Do(func(ctx context.Context, f func(tx jobsdb.StoreSafeTx) error) {
+				_ = f(jobsdb.EmptyStoreSafeTx())
+			}).Return(nil)
+			c.mockJobsDB.EXPECT().StoreEachBatchRetryInTx(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+				func(ctx context.Context, tx jobsdb.StoreSafeTx, jobBatches [][]*jobsdb.JobT) (map[uuid.UUID]string, error) {
+					Expect(jobBatches).To(HaveLen(1))
+					Expect(jobBatches[0]).To(HaveLen(1))
+					payload := gjson.GetBytes(jobBatches[0][0].EventPayload, "batch.0")
+					Expect(payload.Get("type").String()).To(Equal("track"))
+					Expect(payload.Get("event").String()).To(Equal("Order Completed"))
+					Expect(payload.Get("userId").String()).To(Equal("user-1"))
+					Expect(payload.Get("properties.traceId").String()).To(Equal("5b8efff798038103d269b633813fc60c"))
+					c.asyncHelper.ExpectAndNotifyCallbackWithName("jobsdb_store")()
+					return jobsToEmptyErrors(ctx, tx, jobBatches)
+				})
+
+			body := `{"resourceSpans": [{"scopeSpans": [{"spans": [{
+				"traceId": "5b8efff798038103d269b633813fc60c",
+				"spanId": "eee19b7ec3c1b174",
+				"name": "Order Completed",
+				"startTimeUnixNano": "1700000000000000000",
+				"attributes": [{"key": "enduser.id", "value": {"stringValue": "user-1"}}]
+			}]}]}]}`
+			req := authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			expectHandlerResponse(gateway.webOTLPTracesHandler(), req, http.StatusOK, "{}", "otlp")
+		})
+
+		It("should store streamed NDJSON import requests in sub-batches and report rejected lines", func() {
+			conf.Set("Gateway.importStream.maxBatchSize", 2)
+			defer conf.Set("Gateway.importStream.maxBatchSize", 200)
+			var (
+				mu     sync.Mutex
+				stored []string
+			)
+			c.mockJobsDB.EXPECT().WithStoreSafeTx(gomock.Any(), gomock.Any()).AnyTimes().Do(func(ctx context.Context, f func(tx jobsdb.StoreSafeTx) error) {
+				_ = f(jobsdb.EmptyStoreSafeTx())
+			}).Return(nil)
+			c.mockJobsDB.EXPECT().StoreEachBatchRetryInTx(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(ctx context.Context, tx jobsdb.StoreSafeTx, jobBatches [][]*jobsdb.JobT) (map[uuid.UUID]string, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					for _, batch := range jobBatches {
+						for _, job := range batch {
+							stored = append(stored, gjson.GetBytes(job.EventPayload, "batch.0.event").String())
+						}
+					}
+					return jobsToEmptyErrors(ctx, tx, jobBatches)
+				})
+
+			body := strings.Join([]string{
+				`{"type": "track", "userId": "user-1", "event": "event-1"}`,
+				`{"type": "track", "userId": "user-1", "event": "event-2"}`,
+				`{"type": "track", "userId": "user-1", "event": `,
+				``,
+				`{"type": "track", "userId": "user-2", "event": "event-3"}`,
+				`["not", "an", "event"]`,
+				`{"type": "track", "userId": "user-2", "event": "event-4"}`,
+				`{"type": "track", "event": "not-identifiable"}`,
+				`{"type": "track", "userId": "user-3", "event": "event-5"}`,
+			}, "\n")
+			rr := httptest.NewRecorder()
+			gateway.webImportStreamHandler().ServeHTTP(rr, authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(body)))
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(MatchJSON(`{
+				"lines": 9,
+				"accepted": 5,
+				"rejected": 3,
+				"failures": [
+					{"line": 3, "error": "invalid json"},
+					{"line": 6, "error": "invalid json"},
+					{"line": 8, "error": "request neither has anonymousId nor userId"}
+				]
+			}`))
+			Expect(stored).To(ConsistOf("event-1", "event-2", "event-3", "event-4", "event-5"))
+		})
+
+		It("should store the events of each user of a streamed NDJSON import request in order", func() {
+			conf.Set("Gateway.importStream.maxBatchSize", 1)
+			defer conf.Set("Gateway.importStream.maxBatchSize", 200)
+			var (
+				mu     sync.Mutex
+				stored = map[string][]string{}
+			)
+			c.mockJobsDB.EXPECT().WithStoreSafeTx(gomock.Any(), gomock.Any()).AnyTimes().Do(func(ctx context.Context, f func(tx jobsdb.StoreSafeTx) error) {
+				_ = f(jobsdb.EmptyStoreSafeTx())
+			}).Return(nil)
+			c.mockJobsDB.EXPECT().StoreEachBatchRetryInTx(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(ctx context.Context, tx jobsdb.StoreSafeTx, jobBatches [][]*jobsdb.JobT) (map[uuid.UUID]string, error) {
+					time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond) // give later sub-batches a chance to overtake earlier ones
+					mu.Lock()
+					defer mu.Unlock()
+					for _, batch := range jobBatches {
+						for _, job := range batch {
+							event := gjson.GetBytes(job.EventPayload, "batch.0")
+							stored[event.Get("userId").String()] = append(stored[event.Get("userId").String()], event.Get("event").String())
+						}
+					}
+					return jobsToEmptyErrors(ctx, tx, jobBatches)
+				})
+
+			var (
+				lines    []string
+				expected = map[string][]string{}
+			)
+			for i := range 50 {
+				userID := "user-" + strconv.Itoa(i%3)
+				event := "event-" + strconv.Itoa(i)
+				lines = append(lines, `{"type": "track", "userId": "`+userID+`", "event": "`+event+`"}`)
+				expected[userID] = append(expected[userID], event)
+			}
+			rr := httptest.NewRecorder()
+			gateway.webImportStreamHandler().ServeHTTP(rr, authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(strings.Join(lines, "\n"))))
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(MatchJSON(`{"lines": 50, "accepted": 50, "rejected": 0}`))
+			Expect(stored).To(Equal(expected))
+		})
+
+		It("should capture sanitized requests of the sources selected for capturing", func() {
+			dir := GinkgoT().TempDir()
+			captureConf := config.New()
+			captureConf.Set("Gateway.capture.dir", dir)
+			capturer, err := capture.New(captureConf, logger.NOP, stats.NOP, nil)
+			Expect(err).To(BeNil())
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				capturer.Run(ctx)
+			}()
+			gateway.capturer = capturer
+			conf.Set("Gateway.capture.sourceIDs", []string{SourceIDEnabled})
+			defer conf.Set("Gateway.capture.sourceIDs", []string{})
+
+			c.mockJobsDB.EXPECT().WithStoreSafeTx(gomock.Any(), gomock.Any()).AnyTimes().Do(func(ctx context.Context, f func(tx jobsdb.StoreSafeTx) error) {
+				_ = f(jobsdb.EmptyStoreSafeTx())
+			}).Return(nil)
+			c.mockJobsDB.EXPECT().StoreEachBatchRetryInTx(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(jobsToEmptyErrors)
+
+			req := authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"userId":"user-1","event":"captured"}`))
+			req.URL.Path = "/v1/track"
+			expectHandlerResponse(gateway.webTrackHandler(), req, http.StatusOK, "ok", "track")
+			cancel()
+			<-done
+
+			files, err := os.ReadDir(filepath.Join(dir, WorkspaceID))
+			Expect(err).To(BeNil())
+			Expect(files).To(HaveLen(1))
+			f, err := os.Open(filepath.Join(dir, WorkspaceID, files[0].Name()))
+			Expect(err).To(BeNil())
+			defer func() { _ = f.Close() }()
+			record, err := capture.NewReader(f, 1024*1024).Next()
+			Expect(err).To(BeNil())
+			Expect(record.SourceID).To(Equal(SourceIDEnabled))
+			Expect(record.Path).To(Equal("/v1/track"))
+			Expect(record.Header.Get("Authorization")).To(Equal(capture.Redacted))
+			Expect(string(record.Body)).To(MatchJSON(`{"userId":"user-1","event":"captured"}`))
+		})
+
+		It("should reject OTLP requests with an unsupported content type", func() {
+			req := authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{}`))
+			req.Header.Set("Content-Type", "text/plain")
+			expectHandlerResponse(gateway.webOTLPLogsHandler(), req, http.StatusUnsupportedMediaType, response.UnsupportedContentType+"\n", "otlp")
+		})
+	})
+
+	Context("Event schema validation", func() {
+		var (
+			gateway *Handle
+			body    = `{"batch": [
+				{"type": "track", "userId": "user-1", "event": "Signed Up"},
+				{"type": "track", "userId": "user-1", "properties": {"plan": "pro"}}
+			]}`
+		)
+
+		setEventSchema := func(policy eventschema.Policy) {
+			v, err := eventschema.New([]byte(`{"type": "object", "required": ["event"], "properties": {"event": {"type": "string"}}}`), policy)
+			Expect(err).To(BeNil())
+			gateway.configSubscriberLock.Lock()
+			gateway.sourceIDEventSchemaMap = map[string]*eventschema.Validator{SourceIDEnabled: v}
+			gateway.configSubscriberLock.Unlock()
+		}
+
+		expectStoredEvents := func(verify func(events []gjson.Result)) {
+			c.mockJobsDB.EXPECT().WithStoreSafeTx(gomock.Any(), gomock.Any()).Times(1).Do(func(ctx context.Context, f func(tx jobsdb.StoreSafeTx) error) {
+				_ = f(jobsdb.EmptyStoreSafeTx())
+			}).Return(nil)
+			c.mockJobsDB.EXPECT().StoreEachBatchRetryInTx(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+				func(ctx context.Context, tx jobsdb.StoreSafeTx, jobBatches [][]*jobsdb.JobT) (map[uuid.UUID]string, error) {
+					var events []gjson.Result
+					for _, batch := range jobBatches {
+						for _, job := range batch {
+							events = append(events, gjson.GetBytes(job.EventPayload, "batch").Array()...)
+						}
+					}
+					verify(events)
+					c.asyncHelper.ExpectAndNotifyCallbackWithName("jobsdb_store")()
+					return jobsToEmptyErrors(ctx, tx, jobBatches)
+				})
+		}
+
+		BeforeEach(func() {
+			c.initializeAppFeatures()
+			conf.Set("Gateway.enableEventSchemaValidation", true)
+			gateway = &Handle{}
+			err := gateway.Setup(context.Background(), conf, logger.NOP, stats.NOP, c.mockApp, c.mockBackendConfig, c.mockJobsDB, c.mockErrJobsDB, c.mockRateLimiter, c.mockVersionHandler, rsources.NewNoOpService(), transformer.NewNoOpService(), sourcedebugger.NewNoOpService(), nil)
+			Expect(err).To(BeNil())
+			waitForBackendConfigInit(gateway)
+		})
+
+		AfterEach(func() {
+			conf.Set("Gateway.enableEventSchemaValidation", false)
+			Expect(gateway.Shutdown()).To(BeNil())
+		})
+
+		It("should reject the whole request with the violations if the policy is reject", func() {
+			setEventSchema(eventschema.PolicyReject)
+			expectHandlerResponse(
+				gateway.webBatchHandler(),
+				authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(body)),
+				http.StatusBadRequest,
+				response.WithDetails(response.InvalidEventSchema, "event 1: (root): event is required")+"\n",
+				"batch",
+			)
+		})
+
+		It("should only drop the invalid events if the policy is drop", func() {
+			setEventSchema(eventschema.PolicyDrop)
+			expectStoredEvents(func(events []gjson.Result) {
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Get("event").String()).To(Equal("Signed Up"))
+			})
+			expectHandlerResponse(gateway.webBatchHandler(), authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(body)), http.StatusOK, "ok", "batch")
+		})
+
+		It("should add the violations to the context of the invalid events if the policy is annotate", func() {
+			setEventSchema(eventschema.PolicyAnnotate)
+			expectStoredEvents(func(events []gjson.Result) {
+				Expect(events).To(HaveLen(2))
+				Expect(events[0].Get("context.violationErrors").Exists()).To(BeFalse())
+				Expect(events[1].Get("context.violationErrors.0.type").String()).To(Equal("required"))
+				Expect(events[1].Get("context.violationErrors.0.message").String()).To(Equal("event is required"))
+			})
+			expectHandlerResponse(gateway.webBatchHandler(), authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(body)), http.StatusOK, "ok", "batch")
+		})
+
+		It("should not validate events if the source has no event schema", func() {
+			expectStoredEvents(func(events []gjson.Result) {
+				Expect(events).To(HaveLen(2))
+			})
+			expectHandlerResponse(gateway.webBatchHandler(), authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(body)), http.StatusOK, "ok", "batch")
+		})
+	})
+
+	Context("PII scrubbing", func() {
+		var gateway *Handle
+
+		expectStoredJobs := func(verify func(jobs []*jobsdb.JobT)) {
+			c.mockJobsDB.EXPECT().WithStoreSafeTx(gomock.Any(), gomock.Any()).Times(1).Do(func(ctx context.Context, f func(tx jobsdb.StoreSafeTx) error) {
+				_ = f(jobsdb.EmptyStoreSafeTx())
+			}).Return(nil)
+			c.mockJobsDB.EXPECT().StoreEachBatchRetryInTx(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+				func(ctx context.Context, tx jobsdb.StoreSafeTx, jobBatches [][]*jobsdb.JobT) (map[uuid.UUID]string, error) {
+					verify(lo.Flatten(jobBatches))
+					c.asyncHelper.ExpectAndNotifyCallbackWithName("jobsdb_store")()
+					return jobsToEmptyErrors(ctx, tx, jobBatches)
+				})
+		}
+		expectStoredPayloads := func(verify func(payloads [][]byte)) {
+			expectStoredJobs(func(jobs []*jobsdb.JobT) {
+				verify(lo.Map(jobs, func(job *jobsdb.JobT, _ int) []byte { return job.EventPayload }))
+			})
+		}
+		scrubIDs := func() {
+			s, err := pii.FromSourceConfig([]byte(`{"piiRules": [
+				{"path": "userId", "action": "hash"},
+				{"path": "anonymousId", "action": "mask"}
+			]}`), gateway.piiWorkspaceSalt(WorkspaceID))
+			Expect(err).To(BeNil())
+			gateway.configSubscriberLock.Lock()
+			gateway.sourceIDPIIScrubberMap = map[string]*pii.Scrubber{SourceIDEnabled: s}
+			gateway.configSubscriberLock.Unlock()
+		}
+
+		BeforeEach(func() {
+			c.initializeAppFeatures()
+			conf.Set("Gateway.pii.salt", "secret")
+			gateway = &Handle{}
+			err := gateway.Setup(context.Background(), conf, logger.NOP, stats.NOP, c.mockApp, c.mockBackendConfig, c.mockJobsDB, c.mockErrJobsDB, c.mockRateLimiter, c.mockVersionHandler, rsources.NewNoOpService(), transformer.NewNoOpService(), sourcedebugger.NewNoOpService(), nil)
+			Expect(err).To(BeNil())
+			waitForBackendConfigInit(gateway)
+
+			s, err := pii.FromSourceConfig([]byte(`{"piiRules": [
+				{"path": "context.traits.email", "action": "hash"},
+				{"path": "context.traits.phone", "action": "mask"},
+				{"path": "request_ip", "action": "remove"}
+			]}`), gateway.piiWorkspaceSalt(WorkspaceID))
+			Expect(err).To(BeNil())
+			gateway.configSubscriberLock.Lock()
+			gateway.sourceIDPIIScrubberMap = map[string]*pii.Scrubber{SourceIDEnabled: s}
+			gateway.configSubscriberLock.Unlock()
+		})
+
+		AfterEach(func() {
+			Expect(gateway.Shutdown()).To(BeNil())
+		})
+
+		It("should scrub the configured fields before storing events", func() {
+			expectStoredPayloads(func(payloads [][]byte) {
+				Expect(payloads).To(HaveLen(1))
+				payload := string(payloads[0])
+				Expect(payload).NotTo(ContainSubstring("user@example.com"))
+				Expect(payload).NotTo(ContainSubstring("1.2.3.4"))
+				Expect(gjson.Get(payload, "requestIP").String()).To(BeEmpty())
+				Expect(gjson.Get(payload, "batch.0.request_ip").Exists()).To(BeFalse())
+				Expect(gjson.Get(payload, "batch.0.context.traits.email").String()).To(HaveLen(64))
+				Expect(gjson.Get(payload, "batch.0.context.traits.phone").String()).To(Equal("******"))
+				Expect(gjson.Get(payload, "batch.0.context.traits.name").String()).To(Equal("user"))
+			})
+			req := authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"userId": "user-1", "context": {"traits": {"email": "user@example.com", "phone": "123456", "name": "user"}}}`))
+			req.Header.Set("X-Forwarded-For", "1.2.3.4")
+			expectHandlerResponse(gateway.webTrackHandler(), req, http.StatusOK, "ok", "track")
+		})
+
+		It("should build the user id and rudderId of jobs from the scrubbed ids", func() {
+			scrubIDs()
+			expectStoredJobs(func(jobs []*jobsdb.JobT) {
+				Expect(jobs).To(HaveLen(1))
+				userID := gjson.GetBytes(jobs[0].EventPayload, "batch.0.userId").String()
+				Expect(userID).To(HaveLen(64))
+				Expect(jobs[0].UserID).To(Equal("******<<>>******<<>>" + userID))
+				Expect(jobs[0].UserID).NotTo(ContainSubstring("user-1"))
+				Expect(jobs[0].UserID).NotTo(ContainSubstring("anon-1"))
+				rudderID, err := getRudderId(userID, "******")
+				Expect(err).To(BeNil())
+				Expect(gjson.GetBytes(jobs[0].EventPayload, "batch.0.rudderId").String()).To(Equal(rudderID.String()))
+			})
+			req := authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"userId": "user-1", "anonymousId": "anon-1"}`))
+			req.Header.Set("AnonymousId", "anon-1")
+			expectHandlerResponse(gateway.webTrackHandler(), req, http.StatusOK, "ok", "track")
+		})
+
+		It("should not scrub events if PII scrubbing is disabled", func() {
+			conf.Set("Gateway.enablePIIScrubbing", false)
+			defer conf.Set("Gateway.enablePIIScrubbing", true)
+			expectStoredPayloads(func(payloads [][]byte) {
+				Expect(payloads).To(HaveLen(1))
+				Expect(gjson.GetBytes(payloads[0], "batch.0.context.traits.email").String()).To(Equal("user@example.com"))
+			})
+			req := authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"userId": "user-1", "context": {"traits": {"email": "user@example.com"}}}`))
+			expectHandlerResponse(gateway.webTrackHandler(), req, http.StatusOK, "ok", "track")
+		})
+
+		It("should reject events of sources with invalid PII rules instead of storing them unscrubbed", func() {
+			gateway.configSubscriberLock.Lock()
+			gateway.sourceIDPIIScrubberMap = map[string]*pii.Scrubber{SourceIDEnabled: pii.Invalid(pii.ErrMissingSalt)}
+			gateway.configSubscriberLock.Unlock()
+			req := authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"userId": "user-1", "context": {"traits": {"email": "user@example.com"}}}`))
+			expectHandlerResponse(gateway.webTrackHandler(), req, http.StatusServiceUnavailable, response.InvalidPIIRules+"\n", "track")
+		})
+
+		It("should refuse hashing without a salt", func() {
+			gateway.conf.piiSalt = ""
+			Expect(gateway.piiWorkspaceSalt(WorkspaceID)).To(BeEmpty())
+			_, err := pii.FromSourceConfig([]byte(`{"piiRules": [{"path": "context.traits.email", "action": "hash"}]}`), gateway.piiWorkspaceSalt(WorkspaceID))
+			Expect(err).To(MatchError(pii.ErrMissingSalt))
+		})
+
+		It("should scrub events of internal batches", func() {
+			payload, err := jsonrs.Marshal([]stream.Message{{
+				Properties: stream.MessageProperties{
+					RequestType: "track",
+					RoutingKey:  "anonymousId_header<<>>anonymousId_1<<>>user-1",
+					WorkspaceID: WorkspaceID,
+					SourceID:    SourceIDEnabled,
+					ReceivedAt:  time.Now(),
+					RequestIP:   "1.2.3.4",
+				},
+				Payload: []byte(`{"userId": "user-1", "request_ip": "1.2.3.4", "context": {"traits": {"email": "user@example.com", "phone": "123456"}}}`),
+			}})
+			Expect(err).To(BeNil())
+			jobs, err := gateway.extractJobsFromInternalBatchPayload("batch", payload)
+			Expect(err).To(BeNil())
+			Expect(jobs).To(HaveLen(1))
+			stored := string(jobs[0].job.EventPayload)
+			Expect(stored).NotTo(ContainSubstring("user@example.com"))
+			Expect(stored).NotTo(ContainSubstring("1.2.3.4"))
+			Expect(gjson.Get(stored, "batch.0.context.traits.email").String()).To(HaveLen(64))
+			Expect(gjson.Get(stored, "batch.0.context.traits.phone").String()).To(Equal("******"))
+
+			gateway.configSubscriberLock.Lock()
+			gateway.sourceIDPIIScrubberMap = map[string]*pii.Scrubber{SourceIDEnabled: pii.Invalid(pii.ErrMissingSalt)}
+			gateway.configSubscriberLock.Unlock()
+			_, err = gateway.extractJobsFromInternalBatchPayload("batch", payload)
+			Expect(err).To(MatchError(response.InvalidPIIRules))
+		})
+
+		It("should build the user id of jobs of internal batches from the scrubbed ids", func() {
+			scrubIDs()
+			payload, err := jsonrs.Marshal([]stream.Message{{
+				Properties: stream.MessageProperties{
+					RequestType: "track",
+					RoutingKey:  "anon-1<<>>anon-1<<>>user-1",
+					WorkspaceID: WorkspaceID,
+					SourceID:    SourceIDEnabled,
+					UserID:      "user-1",
+					ReceivedAt:  time.Now(),
+					RequestIP:   "1.2.3.4",
+				},
+				Payload: []byte(`{"userId": "user-1", "anonymousId": "anon-1"}`),
+			}})
+			Expect(err).To(BeNil())
+			jobs, err := gateway.extractJobsFromInternalBatchPayload("batch", payload)
+			Expect(err).To(BeNil())
+			Expect(jobs).To(HaveLen(1))
+			userID := gjson.GetBytes(jobs[0].job.EventPayload, "batch.0.userId").String()
+			Expect(userID).To(HaveLen(64))
+			Expect(jobs[0].job.UserID).To(Equal("******<<>>******<<>>" + userID))
+			Expect(gjson.GetBytes(jobs[0].job.Parameters, "user_id").String()).To(Equal(userID))
+		})
+
+		It("should capture requests after scrubbing them", func() {
+			dir := GinkgoT().TempDir()
+			captureConf := config.New()
+			captureConf.Set("Gateway.capture.dir", dir)
+			capturer, err := capture.New(captureConf, logger.NOP, stats.NOP, nil)
+			Expect(err).To(BeNil())
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				capturer.Run(ctx)
+			}()
+			gateway.capturer = capturer
+			conf.Set("Gateway.capture.sourceIDs", []string{SourceIDEnabled})
+
+			expectStoredPayloads(func(payloads [][]byte) {
+				Expect(payloads).To(HaveLen(1))
+			})
+			req := authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"batch": [{"userId": "user-1", "context": {"traits": {"email": "user@example.com", "phone": "123456"}}}]}`))
+			expectHandlerResponse(gateway.webBatchHandler(), req, http.StatusOK, "ok", "batch")
+			cancel()
+			<-done
+
+			files, err := os.ReadDir(filepath.Join(dir, WorkspaceID))
+			Expect(err).To(BeNil())
+			Expect(files).To(HaveLen(1))
+			f, err := os.Open(filepath.Join(dir, WorkspaceID, files[0].Name()))
+			Expect(err).To(BeNil())
+			defer func() { _ = f.Close() }()
+			record, err := capture.NewReader(f, 1024*1024).Next()
+			Expect(err).To(BeNil())
+			Expect(string(record.Body)).NotTo(ContainSubstring("user@example.com"))
+			Expect(gjson.GetBytes(record.Body, "batch.0.context.traits.phone").String()).To(Equal("******"))
+		})
 	})
 
 	Context("Bots", func() {
@@ -1010,7 +1485,13 @@ var _ = Describe("Gateway", func() {
 		})
 
 		It("should store messages successfully if rate limit is not reached for workspace", func() {
-			c.mockRateLimiter.EXPECT().CheckLimitReached(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+			c.mockRateLimiter.EXPECT().CheckLimitReached(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, req throttler.Request) (bool, time.Duration, error) {
+				Expect(req.WorkspaceID).To(Equal(WorkspaceID))
+				Expect(req.SourceID).To(BeEmpty(), "source limits should not be checked unless enabled")
+				Expect(req.WriteKey).To(BeEmpty(), "write key limits should not be checked unless enabled")
+				Expect(req.Events).To(Equal(int64(1)))
+				return false, 0, nil
+			}).Times(1)
 			c.mockJobsDB.EXPECT().WithStoreSafeTx(gomock.Any(), gomock.Any()).Times(1).Do(func(ctx context.Context, f func(tx jobsdb.StoreSafeTx) error) {
 				_ = f(jobsdb.EmptyStoreSafeTx())
 			}).Return(nil)
@@ -1052,7 +1533,7 @@ var _ = Describe("Gateway", func() {
 
 		It("should reject messages if rate limit is reached for workspace", func() {
 			conf.Set("Gateway.allowReqsWithoutUserIDAndAnonymousID", true)
-			c.mockRateLimiter.EXPECT().CheckLimitReached(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+			c.mockRateLimiter.EXPECT().CheckLimitReached(gomock.Any(), gomock.Any()).Return(true, time.Duration(0), nil).Times(1)
 			expectHandlerResponse(
 				gateway.webAliasHandler(),
 				authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"data": "valid-json"}`)),
@@ -1080,6 +1561,180 @@ var _ = Describe("Gateway", func() {
 				1*time.Second,
 			).Should(BeTrue())
 		})
+
+		It("should check source and write key limits if enabled and reply with a Retry-After header when reached", func() {
+			conf.Set("Gateway.allowReqsWithoutUserIDAndAnonymousID", true)
+			conf.Set("Gateway.enableSourceRateLimit", true)
+			defer conf.Set("Gateway.enableSourceRateLimit", false)
+			c.mockRateLimiter.EXPECT().CheckLimitReached(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, req throttler.Request) (bool, time.Duration, error) {
+				Expect(req.WorkspaceID).To(Equal(WorkspaceID))
+				Expect(req.SourceID).To(Equal(SourceIDEnabled))
+				Expect(req.WriteKey).To(Equal(WriteKeyEnabled))
+				Expect(req.Bytes).To(BeNumerically(">", 0))
+				return true, 1500 * time.Millisecond, nil
+			}).Times(1)
+			rr := httptest.NewRecorder()
+			gateway.webAliasHandler().ServeHTTP(rr, authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"data": "valid-json"}`)))
+			Expect(rr.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(rr.Body.String()).To(Equal(response.TooManyRequests + "\n"))
+			Expect(rr.Header().Get("Retry-After")).To(Equal("2"))
+		})
+	})
+
+	Context("Backlog quota", func() {
+		var (
+			gateway *Handle
+			quota   *fakeBacklogQuota
+		)
+
+		BeforeEach(func() {
+			c.initializeAppFeatures()
+			quota = &fakeBacklogQuota{}
+			gateway = &Handle{}
+			err := gateway.Setup(context.Background(), conf, logger.NOP, stats.NOP, c.mockApp, c.mockBackendConfig, c.mockJobsDB, c.mockErrJobsDB, nil, c.mockVersionHandler, rsources.NewNoOpService(), transformer.NewNoOpService(), sourcedebugger.NewNoOpService(), nil, WithBacklogQuota(quota))
+			Expect(err).To(BeNil())
+			waitForBackendConfigInit(gateway)
+		})
+
+		AfterEach(func() {
+			err := gateway.Shutdown()
+			Expect(err).To(BeNil())
+		})
+
+		It("should reject messages with a Retry-After header if the workspace exceeds its backlog quota", func() {
+			conf.Set("Gateway.allowReqsWithoutUserIDAndAnonymousID", true)
+			quota.retryAfter = 30 * time.Second
+			rr := httptest.NewRecorder()
+			gateway.webAliasHandler().ServeHTTP(rr, authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"data": "valid-json"}`)))
+			Expect(rr.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(rr.Body.String()).To(Equal(response.BacklogQuotaExceeded + "\n"))
+			Expect(rr.Header().Get("Retry-After")).To(Equal("30"))
+			Expect(quota.workspaceID).To(Equal(WorkspaceID))
+		})
+	})
+
+	Context("gRPC", func() {
+		var (
+			err     error
+			gateway *Handle
+			client  proto.GatewayClient
+			stop    func()
+		)
+
+		BeforeEach(func() {
+			c.initializeAppFeatures()
+			gateway = &Handle{}
+			err = gateway.Setup(context.Background(), conf, logger.NOP, stats.NOP, c.mockApp, c.mockBackendConfig, c.mockJobsDB, c.mockErrJobsDB, nil, c.mockVersionHandler, rsources.NewNoOpService(), transformer.NewNoOpService(), sourcedebugger.NewNoOpService(), nil)
+			Expect(err).To(BeNil())
+			waitForBackendConfigInit(gateway)
+			client, stop = grpcTestClient(gateway)
+		})
+
+		AfterEach(func() {
+			stop()
+			err := gateway.Shutdown()
+			Expect(err).To(BeNil())
+		})
+
+		storedEvents := func() (*sync.Mutex, *[]string) {
+			var (
+				mu     sync.Mutex
+				stored []string
+			)
+			c.mockJobsDB.EXPECT().WithStoreSafeTx(gomock.Any(), gomock.Any()).AnyTimes().Do(func(ctx context.Context, f func(tx jobsdb.StoreSafeTx) error) {
+				_ = f(jobsdb.EmptyStoreSafeTx())
+			}).Return(nil)
+			c.mockJobsDB.EXPECT().StoreEachBatchRetryInTx(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(ctx context.Context, tx jobsdb.StoreSafeTx, jobBatches [][]*jobsdb.JobT) (map[uuid.UUID]string, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					for _, batch := range jobBatches {
+						for _, job := range batch {
+							Expect(gjson.GetBytes(job.EventPayload, "writeKey").String()).To(Equal(WriteKeyEnabled))
+							Expect(gjson.GetBytes(job.EventPayload, "requestIP").String()).To(Equal(TestRemoteAddress))
+							event := gjson.GetBytes(job.EventPayload, "batch.0")
+							stored = append(stored, event.Get("type").String()+":"+event.Get("userId").String())
+						}
+					}
+					return jobsToEmptyErrors(ctx, tx, jobBatches)
+				})
+			return &mu, &stored
+		}
+
+		It("should store events sent with unary rpcs", func() {
+			mu, stored := storedEvents()
+			ctx := grpcAuthorizedContext(WriteKeyEnabled)
+			_, err := client.Track(ctx, &proto.EventRequest{Payload: []byte(`{"userId":"user-1","event":"Order Completed"}`)})
+			Expect(err).To(BeNil())
+			_, err = client.Identify(ctx, &proto.EventRequest{Payload: []byte(`{"userId":"user-2"}`)})
+			Expect(err).To(BeNil())
+			_, err = client.Batch(ctx, &proto.EventRequest{Payload: []byte(`{"batch":[{"type":"page","userId":"user-3"},{"type":"alias","userId":"user-4"}]}`)})
+			Expect(err).To(BeNil())
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(*stored).To(ConsistOf("track:user-1", "identify:user-2", "page:user-3", "alias:user-4"))
+		})
+
+		It("should reject unauthenticated and invalid unary rpcs with the matching status codes", func() {
+			_, err := client.Track(context.Background(), &proto.EventRequest{Payload: []byte(`{"userId":"user-1"}`)})
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+			Expect(status.Convert(err).Message()).To(Equal(response.NoWriteKeyInBasicAuth))
+
+			_, err = client.Track(grpcAuthorizedContext(WriteKeyInvalid), &proto.EventRequest{Payload: []byte(`{"userId":"user-1"}`)})
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+			Expect(status.Convert(err).Message()).To(Equal(response.InvalidWriteKey))
+
+			_, err = client.Track(grpcAuthorizedContext(WriteKeyDisabled), &proto.EventRequest{Payload: []byte(`{"userId":"user-1"}`)})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+
+			_, err = client.Track(grpcAuthorizedContext(WriteKeyEnabled), &proto.EventRequest{Payload: []byte(`{"userId":`)})
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			Expect(status.Convert(err).Message()).To(Equal(response.InvalidJSON))
+		})
+
+		It("should reject messages larger than the max request size", func() {
+			payload := []byte(`{"userId":"user-1","properties":{"padding":"` + strings.Repeat("a", gateway.conf.maxReqSize.Load()+grpcMessageOverhead) + `"}}`)
+			_, err := client.Track(grpcAuthorizedContext(WriteKeyEnabled), &proto.EventRequest{Payload: payload})
+			Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+		})
+
+		It("should acknowledge every request of a SendEvents stream", func() {
+			mu, stored := storedEvents()
+			stream, err := client.SendEvents(grpcAuthorizedContext(WriteKeyEnabled))
+			Expect(err).To(BeNil())
+			requests := []*proto.SendEventsRequest{
+				{Id: "1", Type: proto.EventType_EVENT_TYPE_TRACK, Payload: []byte(`{"userId":"user-1","event":"Order Completed"}`)},
+				{Id: "2", Type: proto.EventType_EVENT_TYPE_SCREEN, Payload: []byte(`{"userId":"user-2"}`)},
+				{Id: "3", Type: proto.EventType_EVENT_TYPE_GROUP, Payload: []byte(`{"userId":`)},
+				{Id: "4", Type: proto.EventType_EVENT_TYPE_UNSPECIFIED, Payload: []byte(`{"userId":"user-4"}`)},
+				{Id: "5", Type: proto.EventType_EVENT_TYPE_BATCH, Payload: []byte(`{"batch":[{"type":"track","userId":"user-5"}]}`)},
+			}
+			for _, req := range requests {
+				Expect(stream.Send(req)).To(BeNil())
+			}
+			Expect(stream.CloseSend()).To(BeNil())
+
+			acks := map[string]codes.Code{}
+			for {
+				res, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				Expect(err).To(BeNil())
+				acks[res.GetId()] = codes.Code(res.GetCode())
+			}
+			Expect(acks).To(Equal(map[string]codes.Code{
+				"1": codes.OK,
+				"2": codes.OK,
+				"3": codes.InvalidArgument,
+				"4": codes.InvalidArgument,
+				"5": codes.OK,
+			}))
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(*stored).To(ConsistOf("track:user-1", "screen:user-2", "track:user-5"))
+		})
 	})
 
 	Context("Invalid requests", func() {
@@ -2549,6 +3204,31 @@ func authorizedRequest(username string, body io.Reader) *http.Request {
 	return req
 }
 
+// grpcTestClient serves the gateway's gRPC API over an in-memory connection, returning a client for it along with a function stopping the server
+func grpcTestClient(gw *Handle) (proto.GatewayClient, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := gw.newGRPCServer()
+	go func() { _ = srv.Serve(lis) }()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	Expect(err).To(BeNil())
+	return proto.NewGatewayClient(conn), func() {
+		_ = conn.Close()
+		srv.Stop()
+	}
+}
+
+// grpcAuthorizedContext returns a context carrying the same metadata as the headers of an authorized request
+func grpcAuthorizedContext(writeKey string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(),
+		"authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(writeKey+":")),
+		"anonymousid", "094985f8-b4eb-43c3-bc8a-e8b75aae9c7c",
+		"x-forwarded-for", TestRemoteAddress,
+	)
+}
+
 func authorizedReplayRequest(sourceID string, body io.Reader) *http.Request {
 	req := unauthorizedRequest(body)
 	req.Header.Set("X-Rudder-Source-Id", sourceID)
@@ -2611,6 +3291,9 @@ func endpointsToVerify() ([]string, []string, []string) {
 		"/v1/merge",
 		"/v1/group",
 		"/v1/import",
+		"/v1/import/stream",
+		"/v1/traces",
+		"/v1/logs",
 		"/v1/audiencelist", // Get rid of this over time and use the /internal endpoint
 		"/v1/webhook",
 		"/beacon/v1/batch",
@@ -2821,3 +3504,14 @@ func createTestGatewayWithLeakyUploader(t *testing.T, endpoint, accessKeyID, sec
 		mockCtrl.Finish()
 	}
 }
+
+// fakeBacklogQuota rejects all jobs once retryAfter is set
+type fakeBacklogQuota struct {
+	workspaceID string
+	retryAfter  time.Duration
+}
+
+func (q *fakeBacklogQuota) Admit(workspaceID string) (bool, time.Duration) {
+	q.workspaceID = workspaceID
+	return q.retryAfter == 0, q.retryAfter
+}
@@ -2,6 +2,8 @@ package gateway
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -36,12 +38,18 @@ import (
 	"github.com/rudderlabs/rudder-go-kit/jsonrs"
 	"github.com/rudderlabs/rudder-server/app"
 	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/gateway/capture"
 	"github.com/rudderlabs/rudder-server/gateway/internal/bot"
+	"github.com/rudderlabs/rudder-server/gateway/internal/eventschema"
+	"github.com/rudderlabs/rudder-server/gateway/internal/idempotency"
+	"github.com/rudderlabs/rudder-server/gateway/internal/pii"
 	gwstats "github.com/rudderlabs/rudder-server/gateway/internal/stats"
 	"github.com/rudderlabs/rudder-server/gateway/response"
 	"github.com/rudderlabs/rudder-server/gateway/throttler"
 	"github.com/rudderlabs/rudder-server/gateway/webhook"
 	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/middleware"
+	"github.com/rudderlabs/rudder-server/services/backlogquota"
 	sourcedebugger "github.com/rudderlabs/rudder-server/services/debugger/source"
 	"github.com/rudderlabs/rudder-server/services/rsources"
 	"github.com/rudderlabs/rudder-server/utils/misc"
@@ -64,6 +72,7 @@ type Handle struct {
 	jobsDB          jobsdb.JobsDB
 	errorDB         jobsdb.JobsDB
 	rateLimiter     throttler.Throttler
+	backlogQuota    backlogquota.Quota // optional
 	versionHandler  func(w http.ResponseWriter, r *http.Request)
 	rsourcesService rsources.JobService
 	sourcehandle    sourcedebugger.SourceDebugger
@@ -112,6 +121,8 @@ type Handle struct {
 	sourceIDSourceMap                 map[string]backendconfig.SourceT
 	nonEventStreamSources             map[string]bool
 	blockedEventsWorkspaceTypeNameMap map[string]map[string]map[string]bool
+	sourceIDEventSchemaMap            map[string]*eventschema.Validator
+	sourceIDPIIScrubberMap            map[string]*pii.Scrubber
 
 	conf struct { // configuration parameters
 		webPort, maxUserWebRequestWorkerProcess, maxDBWriterProcess                       int
@@ -119,7 +130,20 @@ type Handle struct {
 		userWebRequestBatchTimeout, dbBatchWriteTimeout                                   config.ValueLoader[time.Duration]
 
 		maxReqSize                           config.ValueLoader[int]
+		maxDecompressedReqSize               config.ValueLoader[int]
+		maxDecompressionRatio                config.ValueLoader[int]
 		enableRateLimit                      config.ValueLoader[bool]
+		enableSourceRateLimit                config.ValueLoader[bool]
+		enableEventSchemaValidation          config.ValueLoader[bool]
+		enablePIIScrubbing                   config.ValueLoader[bool]
+		piiSalt                              string
+		importStreamMaxBatchSize             config.ValueLoader[int]
+		importStreamConcurrency              config.ValueLoader[int]
+		grpcEnabled                          bool
+		grpcPort                             int
+		grpcShutdownTimeout                  time.Duration
+		grpcStreamConcurrency                config.ValueLoader[int]
+		captureSourceIDs                     config.ValueLoader[[]string]
 		enableSuppressUserFeature            bool
 		diagnosisTickerTime                  time.Duration
 		ReadTimeout                          time.Duration
@@ -132,6 +156,7 @@ type Handle struct {
 		enableInternalBatchEnrichment        config.ValueLoader[bool]
 		webhookV2HandlerEnabled              bool
 		errorDBEnabled                       config.ValueLoader[bool]
+		idempotencyEnabled                   config.ValueLoader[bool]
 	}
 
 	// additional internal http handlers
@@ -144,6 +169,12 @@ type Handle struct {
 
 	webhookAuthMiddleware *auth.WebhookAuth
 
+	// idempotencyStore keeps the responses of requests carrying an idempotency key
+	idempotencyStore *idempotency.Store
+
+	// capturer records the raw requests of the sources in Gateway.capture.sourceIDs, if capturing is enabled
+	capturer *capture.Capturer
+
 	// leakyUploader is an optional function that can be set to handle uploading of invalid payloads
 	leakyUploader func(upload msgToUpload)
 }
@@ -220,11 +251,22 @@ func (gw *Handle) userWebRequestWorkerProcess(userWebRequestWorker *userWebReque
 			if err != nil {
 				switch {
 				case errors.Is(err, errRequestDropped):
+					req.retryAfter.set(jobData.retryAfter)
 					req.done <- response.TooManyRequests
 					sourceStats[sourceTag].RequestDropped()
+				case errors.Is(err, errBacklogQuotaExceeded):
+					req.retryAfter.set(jobData.retryAfter)
+					req.done <- response.BacklogQuotaExceeded
+					sourceStats[sourceTag].RequestDropped()
 				case errors.Is(err, errRequestSuppressed):
 					req.done <- "" // no error
 					sourceStats[sourceTag].RequestSuppressed()
+				case errors.Is(err, errInvalidEventsDropped):
+					req.done <- "" // no error
+					sourceStats[sourceTag].RequestDropped()
+				case errors.As(err, new(*eventSchemaError)):
+					req.done <- err.Error()
+					sourceStats[sourceTag].RequestEventsFailed(jobData.numEvents, response.InvalidEventSchema)
 				default:
 					req.done <- err.Error()
 					sourceStats[sourceTag].RequestEventsFailed(jobData.numEvents, err.Error())
@@ -354,8 +396,19 @@ func (gw *Handle) getJobDataFromRequest(req *webRequestT) (jobData *jobFromReq,
 
 		// facts about the batch populated as we iterate over events
 		containsAudienceList, suppressed bool
+
+		// events violating the event schema of the source, along with the violations if the request is to be rejected
+		invalidEvents    int
+		schemaViolations []string
 	)
 
+	schemaValidator := gw.eventSchemaValidator(sourceID)
+	piiScrubber := gw.piiScrubber(sourceID)
+	if piiScrubber != nil && piiScrubber.Err() != nil {
+		err = errors.New(response.InvalidPIIRules)
+		return
+	}
+	var piiScrubbed int
 	isUserSuppressed := gw.memoizedIsUserSuppressed()
 	for idx, v := range eventsBatch {
 		toSet, ok := v.Value().(map[string]interface{})
@@ -415,17 +468,31 @@ func (gw *Handle) getJobDataFromRequest(req *webRequestT) (jobData *jobFromReq,
 			}
 		}
 
+		if schemaValidator != nil {
+			violations, validationErr := schemaValidator.Validate(toSet)
+			if validationErr != nil {
+				gw.logger.Warnn("Failed to validate event against event schema", obskit.SourceID(sourceID), obskit.Error(validationErr))
+			}
+			if len(violations) > 0 {
+				invalidEvents++
+				switch schemaValidator.Policy() {
+				case eventschema.PolicyReject:
+					for _, violation := range violations {
+						schemaViolations = append(schemaViolations, fmt.Sprintf("event %d: %s", idx, violation))
+					}
+				case eventschema.PolicyDrop:
+					continue
+				case eventschema.PolicyAnnotate:
+					eventschema.Annotate(toSet, violations)
+				}
+			}
+		}
+
 		if isUserSuppressed(workspaceId, userIDFromReq, sourceID) {
 			suppressed = true
 			continue
 		}
 
-		// hashing combination of userIDFromReq + anonIDFromReq, using colon as a delimiter
-		rudderId, err := getRudderId(userIDFromReq, anonIDFromReq)
-		if err != nil {
-			return jobData, err
-		}
-		toSet["rudderId"] = rudderId
 		if _, ok := toSet["receivedAt"]; !ok {
 			toSet["receivedAt"] = gw.now().Format(misc.RFC3339Milli)
 		}
@@ -439,35 +506,97 @@ func (gw *Handle) getJobDataFromRequest(req *webRequestT) (jobData *jobFromReq,
 		} else {
 			toSet["request_ip"] = ipAddr
 		}
+		jobUserIDHeader := userIDHeader
+		if piiScrubber != nil {
+			piiScrubbed += piiScrubber.Scrub(toSet)
+			// the request ip is also stored outside of the event, so it needs to follow any rule targeting request_ip
+			ipAddr, _ = toSet["request_ip"].(string)
+			// and so are the user and anonymous ids, in the user id of the job and in the rudderId
+			rawUserID, rawAnonID := userIDFromReq, anonIDFromReq
+			anonIDFromReq = strings.TrimSpace(sanitize.Unicode(stringify.Any(toSet["anonymousId"])))
+			userIDFromReq = strings.TrimSpace(sanitize.Unicode(stringify.Any(toSet["userId"])))
+			jobUserIDHeader = scrubbedUserIDHeader(userIDHeader, rawAnonID, anonIDFromReq)
+			// users can be suppressed by their scrubbed id too, since it is the only one known past the gateway
+			if userIDFromReq != rawUserID && isUserSuppressed(workspaceId, userIDFromReq, sourceID) {
+				suppressed = true
+				continue
+			}
+		}
+		// hashing combination of userIDFromReq + anonIDFromReq, using colon as a delimiter
+		rudderId, err := getRudderId(userIDFromReq, anonIDFromReq)
+		if err != nil {
+			return jobData, err
+		}
+		toSet["rudderId"] = rudderId
 		fillMessageID(toSet)
 		if eventTypeFromReq == "audiencelist" {
 			containsAudienceList = true
 		}
 
-		userID := buildUserID(userIDHeader, anonIDFromReq, userIDFromReq)
+		userID := buildUserID(jobUserIDHeader, anonIDFromReq, userIDFromReq)
 		out = append(out, jobObject{
 			userID: userID,
 			events: []map[string]interface{}{toSet},
 		})
 	}
 
-	if gw.conf.enableRateLimit.Load() && sourcesJobRunID == "" && sourcesTaskRunID == "" {
+	if invalidEvents > 0 {
+		gw.stats.NewTaggedStat("gateway.event_schema_violations", stats.CountType, stats.Tags{
+			"workspaceId": workspaceId,
+			"sourceID":    sourceID,
+			"policy":      string(schemaValidator.Policy()),
+		}).Count(invalidEvents)
+	}
+	if piiScrubbed > 0 {
+		gw.stats.NewTaggedStat("gateway.pii_scrubbed_fields", stats.CountType, stats.Tags{
+			"workspaceId": workspaceId,
+			"sourceID":    sourceID,
+		}).Count(piiScrubbed)
+	}
+	if len(schemaViolations) > 0 {
+		err = &eventSchemaError{violations: schemaViolations}
+		return
+	}
+
+	if (gw.conf.enableRateLimit.Load() || gw.conf.enableSourceRateLimit.Load()) && sourcesJobRunID == "" && sourcesTaskRunID == "" {
 		// In case of "batch" requests, if rate-limiter returns true for LimitReached, just drop the event batch and continue.
-		ok, errCheck := gw.rateLimiter.CheckLimitReached(context.TODO(), workspaceId, int64(len(eventsBatch)))
+		throttleReq := throttler.Request{Events: int64(len(eventsBatch)), Bytes: int64(len(body))}
+		if gw.conf.enableRateLimit.Load() {
+			throttleReq.WorkspaceID = workspaceId
+		}
+		if gw.conf.enableSourceRateLimit.Load() {
+			throttleReq.SourceID = sourceID
+			throttleReq.WriteKey = arctx.WriteKey
+		}
+		ok, retryAfter, errCheck := gw.rateLimiter.CheckLimitReached(context.TODO(), throttleReq)
 		if errCheck != nil {
 			gw.stats.NewTaggedStat("gateway.rate_limiter_error", stats.CountType, stats.Tags{"workspaceId": workspaceId}).Increment()
 			gw.logger.Errorn("Rate limiter error: Allowing the request", obskit.Error(errCheck))
 		}
 		if ok {
+			jobData.retryAfter = retryAfter
 			return jobData, errRequestDropped
 		}
 	}
 
+	if gw.backlogQuota != nil {
+		if ok, retryAfter := gw.backlogQuota.Admit(workspaceId); !ok {
+			gw.stats.NewTaggedStat("gateway.backlog_quota_exceeded", stats.CountType, stats.Tags{"workspaceId": workspaceId}).Increment()
+			jobData.retryAfter = retryAfter
+			return jobData, errBacklogQuotaExceeded
+		}
+	}
+
 	if len(out) == 0 && suppressed {
 		err = errRequestSuppressed
 		return
 	}
 
+	if len(out) == 0 && invalidEvents > 0 {
+		err = errInvalidEventsDropped
+		return
+	}
+
 	if len(body) > gw.conf.maxReqSize.Load() && !containsAudienceList {
 		err = errors.New((response.RequestBodyTooLarge))
 		return
@@ -562,6 +691,40 @@ func buildUserID(userIDHeader, anonIDFromReq, userIDFromReq string) string {
 	return userIDHeader + delimiter + anonIDFromReq + delimiter + userIDFromReq
 }
 
+// scrubbedUserIDHeader returns the anonymous id header to build the user id of a job with, after PII rules scrubbed the anonymous id of its event:
+// the header usually carries the same anonymous id, in which case it is replaced by the scrubbed one
+func scrubbedUserIDHeader(userIDHeader, rawAnonID, anonID string) string {
+	if userIDHeader == rawAnonID {
+		return anonID
+	}
+	return userIDHeader
+}
+
+// followScrubbedIDs rewrites the ids of an internal batch message which are derived from the user and anonymous ids of its event,
+// i.e. its routing key, its user id and the rudderId of its event, after PII rules scrubbed the raw ids
+func followScrubbedIDs(msg *stream.Message, rawAnonID, rawUserID string) error {
+	anonID := sanitizeAndTrim(gjson.GetBytes(msg.Payload, "anonymousId").String())
+	userID := sanitizeAndTrim(gjson.GetBytes(msg.Payload, "userId").String())
+	if anonID == rawAnonID && userID == rawUserID {
+		return nil
+	}
+	if parts := strings.Split(msg.Properties.RoutingKey, delimiter); len(parts) == 3 {
+		msg.Properties.RoutingKey = buildUserID(scrubbedUserIDHeader(parts[0], rawAnonID, anonID), anonID, userID)
+	}
+	if msg.Properties.UserID == rawUserID {
+		msg.Properties.UserID = userID
+	}
+	if !gjson.GetBytes(msg.Payload, "rudderId").Exists() {
+		return nil
+	}
+	rudderId, err := getRudderId(userID, anonID)
+	if err != nil {
+		return err
+	}
+	msg.Payload, err = sjson.SetBytes(msg.Payload, "rudderId", rudderId.String())
+	return err
+}
+
 // memoizedIsUserSuppressed is a memoized version of isUserSuppressed
 func (gw *Handle) memoizedIsUserSuppressed() func(workspaceID, userID, sourceID string) bool {
 	cache := map[string]bool{}
@@ -623,6 +786,37 @@ func (gw *Handle) isEventBlocked(workspaceID, sourceID, eventType, eventName str
 	return gw.blockedEventsWorkspaceTypeNameMap[workspaceID][eventType][eventName]
 }
 
+// eventSchemaValidator returns the validator for the event schema of a source, or nil if event schema validation is disabled or the source doesn't have a schema
+func (gw *Handle) eventSchemaValidator(sourceID string) *eventschema.Validator {
+	if !gw.conf.enableEventSchemaValidation.Load() {
+		return nil
+	}
+	gw.configSubscriberLock.RLock()
+	defer gw.configSubscriberLock.RUnlock()
+	return gw.sourceIDEventSchemaMap[sourceID]
+}
+
+// piiScrubber returns the scrubber for the PII rules of a source, or nil if PII scrubbing is disabled or the source doesn't have any rules
+func (gw *Handle) piiScrubber(sourceID string) *pii.Scrubber {
+	if !gw.conf.enablePIIScrubbing.Load() {
+		return nil
+	}
+	gw.configSubscriberLock.RLock()
+	defer gw.configSubscriberLock.RUnlock()
+	return gw.sourceIDPIIScrubberMap[sourceID]
+}
+
+// piiWorkspaceSalt returns the salt used for hashing PII values of a workspace, derived from Gateway.pii.salt
+// so that hashes of the same value can't be correlated across workspaces.
+// It is empty if Gateway.pii.salt isn't set, since a salt derived from the workspace ID alone could be derived by anyone.
+func (gw *Handle) piiWorkspaceSalt(workspaceID string) string {
+	if gw.conf.piiSalt == "" {
+		return ""
+	}
+	h := sha256.Sum256([]byte(gw.conf.piiSalt + ":" + workspaceID))
+	return hex.EncodeToString(h[:])
+}
+
 // getPayload reads the request body and returns the payload's bytes or an error if the payload cannot be read
 func (gw *Handle) getPayload(arctx *gwtypes.AuthRequestContext, r *http.Request, reqType string) ([]byte, error) {
 	payload, err := gw.getPayloadFromRequest(r)
@@ -640,10 +834,29 @@ func (gw *Handle) getPayload(arctx *gwtypes.AuthRequestContext, r *http.Request,
 
 		return nil, err
 	}
+	gw.captureRequest(r, arctx, reqType, payload)
 	return payload, err
 }
 
+// reportRequestFailed reports a request of an authenticated source as failed for the given reason
+func (gw *Handle) reportRequestFailed(arctx *gwtypes.AuthRequestContext, reqType, reason string) {
+	stat := gwstats.SourceStat{
+		Source:        arctx.SourceTag(),
+		WriteKey:      arctx.WriteKey,
+		ReqType:       reqType,
+		SourceID:      arctx.SourceID,
+		WorkspaceID:   arctx.WorkspaceID,
+		SourceType:    arctx.SourceCategory,
+		SourceDefName: arctx.SourceDefName,
+	}
+	stat.RequestFailed(reason)
+	stat.Report(gw.stats)
+}
+
 func (gw *Handle) getPayloadFromRequest(r *http.Request) ([]byte, error) {
+	if payload, ok := r.Context().Value(ctxParamPayload).([]byte); ok {
+		return payload, nil
+	}
 	if r.Body == nil {
 		return []byte{}, errors.New((response.RequestBodyNil))
 	}
@@ -653,6 +866,14 @@ func (gw *Handle) getPayloadFromRequest(r *http.Request) ([]byte, error) {
 
 	payload, err := io.ReadAll(r.Body)
 	_ = r.Body.Close()
+	if middleware.IsDecompressionLimitError(err) {
+		gw.logger.Infon(
+			"Request body exceeds decompression limits",
+			logger.NewStringField("Content-Encoding", r.Header.Get("Content-Encoding")),
+			obskit.Error(err),
+		)
+		return nil, errors.New(response.DecompressedRequestBodyTooLarge)
+	}
 	if err != nil {
 		gw.logger.Errorn(
 			"Error reading request body",
@@ -692,6 +913,7 @@ func (gw *Handle) addToWebRequestQ(_ *http.ResponseWriter, req *http.Request, do
 		traceParent:    traceParent,
 		ipAddr:         ipAddr,
 		userIDHeader:   userIDHeader,
+		retryAfter:     retryAfterFromContext(req.Context()),
 	}
 	userWebRequestWorker.webRequestQ <- &webReq
 }
@@ -973,6 +1195,44 @@ func (gw *Handle) extractJobsFromInternalBatchPayload(reqType string, body []byt
 			}
 		}
 
+		rawUserID := msg.Properties.UserID
+		if piiScrubber := gw.piiScrubber(msg.Properties.SourceID); piiScrubber != nil {
+			rawAnonIDFromReq := sanitizeAndTrim(gjson.GetBytes(msg.Payload, "anonymousId").String())
+			rawUserIDFromReq := sanitizeAndTrim(gjson.GetBytes(msg.Payload, "userId").String())
+			var scrubbed int
+			msg.Payload, scrubbed, err = piiScrubber.ScrubEvent(msg.Payload)
+			if err != nil {
+				loggerFields := msg.Properties.LoggerFields()
+				loggerFields = append(loggerFields, obskit.Error(err))
+				gw.logger.Errorn("failed to scrub PII from message", loggerFields...)
+				if piiScrubber.Err() != nil {
+					stat.RequestEventsFailed(1, response.InvalidPIIRules)
+					stat.Report(gw.stats)
+					return nil, errors.New(response.InvalidPIIRules)
+				}
+				stat.RequestEventsFailed(1, response.NotRudderEvent)
+				stat.Report(gw.stats)
+				return nil, errors.New(response.NotRudderEvent)
+			}
+			if scrubbed > 0 {
+				gw.stats.NewTaggedStat("gateway.pii_scrubbed_fields", stats.CountType, stats.Tags{
+					"workspaceId": msg.Properties.WorkspaceID,
+					"sourceID":    msg.Properties.SourceID,
+				}).Count(scrubbed)
+			}
+			// the request ip is also stored outside of the event, so it needs to follow any rule targeting request_ip
+			requestIP := map[string]any{"request_ip": msg.Properties.RequestIP}
+			piiScrubber.Scrub(requestIP)
+			msg.Properties.RequestIP, _ = requestIP["request_ip"].(string)
+			// and so are the ids derived from the user and anonymous ids
+			if err = followScrubbedIDs(&msg, rawAnonIDFromReq, rawUserIDFromReq); err != nil {
+				stat.RequestFailed(response.NotRudderEvent)
+				stat.Report(gw.stats)
+				gw.logger.Errorn("failed to follow scrubbed ids in message", obskit.Error(err))
+				return nil, errors.New(response.NotRudderEvent)
+			}
+		}
+
 		writeKey, sourceDefName, sourceName, sourceType := "", "", "", ""
 		src, ok := gw.getSourceConfigFromSourceID(msg.Properties.SourceID)
 		if !ok {
@@ -989,7 +1249,9 @@ func (gw *Handle) extractJobsFromInternalBatchPayload(reqType string, body []byt
 		stat.SourceDefName = sourceDefName
 		stat.SourceType = sourceType
 
-		if isUserSuppressed(msg.Properties.WorkspaceID, msg.Properties.UserID, msg.Properties.SourceID) {
+		// users can be suppressed by their scrubbed id too, since it is the only one known past the gateway
+		if isUserSuppressed(msg.Properties.WorkspaceID, rawUserID, msg.Properties.SourceID) ||
+			msg.Properties.UserID != rawUserID && isUserSuppressed(msg.Properties.WorkspaceID, msg.Properties.UserID, msg.Properties.SourceID) {
 			gw.logger.Infon("suppressed event",
 				obskit.SourceID(msg.Properties.SourceID),
 				obskit.WorkspaceID(msg.Properties.WorkspaceID),
@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/gateway/capture"
+	gwtypes "github.com/rudderlabs/rudder-server/gateway/types"
+	"github.com/rudderlabs/rudder-server/services/fileuploader"
+)
+
+// newCapturer returns the capturer of raw requests, or nil if Gateway.capture.enabled isn't set.
+// Rotated capture files are uploaded to the object storage of their workspace if Gateway.capture.upload is set, otherwise they are kept locally.
+func (gw *Handle) newCapturer(ctx context.Context) (*capture.Capturer, error) {
+	if !gw.config.GetBool("Gateway.capture.enabled", false) {
+		return nil, nil
+	}
+	var uploader fileuploader.Provider
+	if gw.config.GetBool("Gateway.capture.upload", false) {
+		uploader = fileuploader.NewProvider(ctx, gw.backendConfig)
+	}
+	capturer, err := capture.New(gw.config, gw.logger.Child("capture"), gw.stats, uploader)
+	if err != nil {
+		return nil, fmt.Errorf("creating request capturer: %w", err)
+	}
+	return capturer, nil
+}
+
+// captureRequest captures the request along with its payload, if capturing is enabled for its source.
+// It is only called by the regular HTTP ingestion endpoints: gRPC and streaming import requests are never captured.
+//
+// The payload is captured after applying the source's PII rules to its events, same as they are stored.
+// Requests of sources whose PII rules are invalid, or whose payload can't be scrubbed, like OTLP ones, are not captured.
+func (gw *Handle) captureRequest(r *http.Request, arctx *gwtypes.AuthRequestContext, reqType string, payload []byte) {
+	if gw.capturer == nil || !slices.Contains(gw.conf.captureSourceIDs.Load(), arctx.SourceID) {
+		return
+	}
+	if piiScrubber := gw.piiScrubber(arctx.SourceID); piiScrubber != nil {
+		if reqType == "otlp" {
+			return
+		}
+		scrubbed, err := piiScrubber.ScrubPayload(payload)
+		if err != nil {
+			gw.logger.Debugn("Request not captured, its payload could not be scrubbed",
+				obskit.SourceID(arctx.SourceID),
+				obskit.WorkspaceID(arctx.WorkspaceID),
+				obskit.Error(err),
+			)
+			return
+		}
+		payload = scrubbed
+	}
+	gw.capturer.Capture(capture.NewRecord(r, arctx.WorkspaceID, arctx.SourceID, payload))
+}
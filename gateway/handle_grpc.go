@@ -0,0 +1,280 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	proto "github.com/rudderlabs/rudder-server/proto/gateway"
+)
+
+// grpcEventTypes maps the event types of the gRPC API to the request types of their http counterparts
+var grpcEventTypes = map[proto.EventType]string{
+	proto.EventType_EVENT_TYPE_TRACK:    "track",
+	proto.EventType_EVENT_TYPE_IDENTIFY: "identify",
+	proto.EventType_EVENT_TYPE_PAGE:     "page",
+	proto.EventType_EVENT_TYPE_SCREEN:   "screen",
+	proto.EventType_EVENT_TYPE_GROUP:    "group",
+	proto.EventType_EVENT_TYPE_ALIAS:    "alias",
+	proto.EventType_EVENT_TYPE_BATCH:    "batch",
+}
+
+// grpcServer implements the gateway's gRPC ingestion API.
+// Every request goes through the same auth and regular request handler as its http counterpart.
+type grpcServer struct {
+	proto.UnimplementedGatewayServer
+	gw *Handle
+}
+
+func (s *grpcServer) Track(ctx context.Context, req *proto.EventRequest) (*proto.EventResponse, error) {
+	return s.unary(ctx, "track", req)
+}
+
+func (s *grpcServer) Identify(ctx context.Context, req *proto.EventRequest) (*proto.EventResponse, error) {
+	return s.unary(ctx, "identify", req)
+}
+
+func (s *grpcServer) Page(ctx context.Context, req *proto.EventRequest) (*proto.EventResponse, error) {
+	return s.unary(ctx, "page", req)
+}
+
+func (s *grpcServer) Screen(ctx context.Context, req *proto.EventRequest) (*proto.EventResponse, error) {
+	return s.unary(ctx, "screen", req)
+}
+
+func (s *grpcServer) Group(ctx context.Context, req *proto.EventRequest) (*proto.EventResponse, error) {
+	return s.unary(ctx, "group", req)
+}
+
+func (s *grpcServer) Alias(ctx context.Context, req *proto.EventRequest) (*proto.EventResponse, error) {
+	return s.unary(ctx, "alias", req)
+}
+
+func (s *grpcServer) Batch(ctx context.Context, req *proto.EventRequest) (*proto.EventResponse, error) {
+	return s.unary(ctx, "batch", req)
+}
+
+func (s *grpcServer) unary(ctx context.Context, reqType string, req *proto.EventRequest) (*proto.EventResponse, error) {
+	if err := s.gw.processGRPCRequest(ctx, reqType, req.GetPayload()).Err(); err != nil {
+		return nil, err
+	}
+	return &proto.EventResponse{}, nil
+}
+
+// SendEvents processes the requests of the stream concurrently, up to Gateway.grpc.streamConcurrency at a time,
+// acknowledging each one of them as soon as it has been processed.
+// Requests are authenticated individually, so that a source being disabled takes effect on open streams too.
+func (s *grpcServer) SendEvents(stream proto.Gateway_SendEventsServer) error {
+	var (
+		ctx    = stream.Context()
+		sendMu sync.Mutex // stream.Send isn't safe for concurrent use
+		g      errgroup.Group
+	)
+	g.SetLimit(s.gw.conf.grpcStreamConcurrency.Load())
+	send := func(res *proto.SendEventsResponse) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(res)
+	}
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if waitErr := g.Wait(); waitErr != nil {
+				return waitErr
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		g.Go(func() error {
+			reqType, ok := grpcEventTypes[req.GetType()]
+			if !ok {
+				return send(&proto.SendEventsResponse{
+					Id:      req.GetId(),
+					Code:    int32(codes.InvalidArgument),
+					Message: fmt.Sprintf("unknown event type %q", req.GetType()),
+				})
+			}
+			st := s.gw.processGRPCRequest(ctx, reqType, req.GetPayload())
+			res := &proto.SendEventsResponse{
+				Id:      req.GetId(),
+				Code:    int32(st.Code()),
+				Message: st.Message(),
+			}
+			if d := grpcRetryAfter(st); d > 0 {
+				res.RetryAfter = durationpb.New(d)
+			}
+			return send(res)
+		})
+	}
+}
+
+// processGRPCRequest authenticates a gRPC request and passes its payload on to the regular request handler,
+// returning the outcome as a gRPC status
+func (gw *Handle) processGRPCRequest(ctx context.Context, reqType string, payload []byte) *status.Status {
+	r := withRetryAfter(grpcHTTPRequest(ctx))
+	arctx, errorMessage := gw.authenticateWriteKey(r, reqType)
+	if errorMessage != "" {
+		gw.handleFailureStats(errorMessage, reqType, arctx)
+	} else {
+		var w http.ResponseWriter // the regular request handler doesn't write anything to the response
+		errorMessage = gw.rrh.ProcessRequest(&w, r, reqType, payload, arctx)
+		gw.TrackRequestMetrics(errorMessage)
+	}
+	st := grpcStatus(errorMessage, retryAfterFromContext(r.Context()).get())
+	gw.stats.NewTaggedStat("gateway.grpc_requests", stats.CountType, stats.Tags{
+		"reqType": reqType,
+		"code":    st.Code().String(),
+	}).Increment()
+	if errorMessage != "" {
+		gw.logger.Infon("response",
+			logger.NewStringField("method", r.URL.Path),
+			logger.NewStringField("reqType", reqType),
+			logger.NewStringField("code", st.Code().String()),
+			logger.NewStringField("message", st.Message()))
+	}
+	return st
+}
+
+// grpcHTTPRequest returns an http request carrying the incoming metadata of a gRPC request as headers, along with the address of its peer,
+// so that it can go through the same auth and request handling as http requests
+func grpcHTTPRequest(ctx context.Context) *http.Request {
+	method, _ := grpc.Method(ctx)
+	r := (&http.Request{Method: http.MethodPost, Header: make(http.Header)}).WithContext(ctx)
+	r.URL = &url.URL{Path: method}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, values := range md {
+		for _, v := range values {
+			r.Header.Add(k, v)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
+
+// grpcStatus converts the error message of a request to a gRPC status, with the same code for all error messages sharing an http status code.
+// The status of rate limited requests carries the time after which they can be retried, if known.
+func grpcStatus(errorMessage string, retryAfter time.Duration) *status.Status {
+	if errorMessage == "" {
+		return status.New(codes.OK, "")
+	}
+	st := status.New(grpcCode(response.GetErrorStatusCode(errorMessage)), response.GetStatus(errorMessage))
+	if retryAfter > 0 {
+		if withRetryInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+			st = withRetryInfo
+		}
+	}
+	return st
+}
+
+// grpcRetryAfter returns the retry delay carried by the status, if any
+func grpcRetryAfter(st *status.Status) time.Duration {
+	for _, detail := range st.Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			return retryInfo.GetRetryDelay().AsDuration()
+		}
+	}
+	return 0
+}
+
+// grpcCode maps an http status code to the gRPC code with the closest meaning
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// grpcMessageOverhead is the room left for the fields of a message besides its payload, e.g. its id and type
+const grpcMessageOverhead = 64 * 1024
+
+// newGRPCServer returns a gRPC server exposing the gateway's ingestion API.
+// Requests are tracked as in-flight, so that the gateway doesn't shut down while they are being processed.
+// Messages are limited to the max request size of the gateway, since their payload is subject to the same limit as HTTP request bodies.
+func (gw *Handle) newGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(gw.conf.maxReqSize.Load()+grpcMessageOverhead),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			gw.inFlightRequests.Add(1)
+			defer gw.inFlightRequests.Done()
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			gw.inFlightRequests.Add(1)
+			defer gw.inFlightRequests.Done()
+			return handler(srv, ss)
+		}),
+	)
+	proto.RegisterGatewayServer(srv, &grpcServer{gw: gw})
+	return srv
+}
+
+// serveGRPC serves the gateway's gRPC ingestion API on the gRPC port until the context is cancelled.
+// Open streams are given up to Gateway.grpc.shutdownTimeout to complete before being closed.
+func (gw *Handle) serveGRPC(ctx context.Context) error {
+	lis, err := net.Listen("tcp", ":"+strconv.Itoa(gw.conf.grpcPort))
+	if err != nil {
+		return fmt.Errorf("listening on grpc port %d: %w", gw.conf.grpcPort, err)
+	}
+	gw.logger.Infon("gRPC server starting", logger.NewIntField("grpcPort", int64(gw.conf.grpcPort)))
+	srv := gw.newGRPCServer()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		graceful := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(graceful)
+		}()
+		select {
+		case <-graceful:
+		case <-time.After(gw.conf.grpcShutdownTimeout):
+			srv.Stop()
+		}
+	}()
+	if err := srv.Serve(lis); err != nil {
+		return fmt.Errorf("serving grpc: %w", err)
+	}
+	<-stopped
+	return nil
+}
@@ -26,7 +26,7 @@ func (gw *Handle) webExtractHandler() http.HandlerFunc {
 
 // webBatchHandler - handler for batch requests
 func (gw *Handle) webBatchHandler() http.HandlerFunc {
-	return gw.callType("batch", gw.writeKeyAuth(gw.webHandler()))
+	return gw.callType("batch", gw.writeKeyAuth(gw.idempotencyKeyCheck(gw.webHandler())))
 }
 
 func (gw *Handle) internalBatchHandler() http.HandlerFunc {
@@ -96,7 +96,7 @@ func (gw *Handle) webRequestHandler(rh RequestHandler, w http.ResponseWriter, r
 			"sourceId":    arctx.SourceID,
 		}),
 	)
-	r = r.WithContext(ctx)
+	r = withRetryAfter(r.WithContext(ctx))
 
 	gw.logger.LogRequest(r)
 	var errorMessage string
@@ -111,6 +111,9 @@ func (gw *Handle) webRequestHandler(rh RequestHandler, w http.ResponseWriter, r
 				logger.NewStringField("path", r.URL.Path),
 				logger.NewIntField("status", int64(status)),
 				logger.NewStringField("body", responseBody))
+			if status == http.StatusTooManyRequests {
+				setRetryAfterHeader(w, r)
+			}
 			http.Error(w, responseBody, status)
 			return
 		}
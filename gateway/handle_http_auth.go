@@ -30,33 +30,40 @@ func (gw *Handle) writeKeyAuth(delegate http.HandlerFunc) http.HandlerFunc {
 			gw.handleHttpError(w, r, errorMessage)
 			gw.handleFailureStats(errorMessage, reqType, arctx)
 		}()
-		writeKey, _, ok := r.BasicAuth()
-		if !ok || writeKey == "" {
-			errorMessage = response.NoWriteKeyInBasicAuth
+		arctx, errorMessage = gw.authenticateWriteKey(r, reqType)
+		if errorMessage != "" {
 			return
 		}
-		arctx = gw.authRequestContextForWriteKey(writeKey)
-		if arctx == nil {
-			stat := gwstats.SourceStat{
-				Source:   "invalidWriteKey",
-				SourceID: "invalidWriteKey",
-				WriteKey: writeKey,
-				ReqType:  reqType,
-			}
-			stat.RequestFailed("invalidWriteKey")
-			stat.Report(gw.stats)
-			errorMessage = response.InvalidWriteKey
-			return
-		}
-		if !arctx.SourceEnabled {
-			errorMessage = response.SourceDisabled
-			return
-		}
-		augmentAuthRequestContext(arctx, r)
 		delegate.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), gwtypes.CtxParamAuthRequestContext, arctx)))
 	}
 }
 
+// authenticateWriteKey authenticates the writeKey in the Authorization header of the request,
+// returning the request context of its source or the error message if the writeKey is missing, invalid or belongs to a disabled source.
+func (gw *Handle) authenticateWriteKey(r *http.Request, reqType string) (*gwtypes.AuthRequestContext, string) {
+	writeKey, _, ok := r.BasicAuth()
+	if !ok || writeKey == "" {
+		return nil, response.NoWriteKeyInBasicAuth
+	}
+	arctx := gw.authRequestContextForWriteKey(writeKey)
+	if arctx == nil {
+		stat := gwstats.SourceStat{
+			Source:   "invalidWriteKey",
+			SourceID: "invalidWriteKey",
+			WriteKey: writeKey,
+			ReqType:  reqType,
+		}
+		stat.RequestFailed("invalidWriteKey")
+		stat.Report(gw.stats)
+		return nil, response.InvalidWriteKey
+	}
+	if !arctx.SourceEnabled {
+		return arctx, response.SourceDisabled
+	}
+	augmentAuthRequestContext(arctx, r)
+	return arctx, ""
+}
+
 // webhookAuth middleware to authenticate webhook requests.
 // The writeKey can be passed in the Authorization header or as a query param.
 // If the writeKey is valid, corresponds to a webhook source and the source is enabled, the source auth info is added to the request context.
@@ -262,6 +269,9 @@ func (gw *Handle) handleHttpError(w http.ResponseWriter, r *http.Request, errorM
 			logger.NewStringField("path", r.URL.Path),
 			logger.NewIntField("status", int64(status)),
 			logger.NewStringField("body", responseBody))
+		if status == http.StatusTooManyRequests {
+			setRetryAfterHeader(w, r)
+		}
 		http.Error(w, responseBody, status)
 	}
 }
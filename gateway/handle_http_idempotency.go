@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/rudderlabs/rudder-go-kit/stats"
+
+	"github.com/rudderlabs/rudder-server/gateway/internal/idempotency"
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	gwtypes "github.com/rudderlabs/rudder-server/gateway/types"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyKeyStatsPrefix = "gateway.idempotency_key_"
+)
+
+// ctxParamPayload is the key of the request body in the context of requests whose body has already been read,
+// so that [Handle.getPayloadFromRequest] returns it instead of reading the body and recording its stats again
+const ctxParamPayload gwtypes.ContextKey = "rudder.gateway.payload"
+
+// idempotencyKeyCheck middleware makes requests carrying an Idempotency-Key header idempotent per write key.
+// The first request using a key is processed normally and its response is stored for a configurable period of time.
+// Retries using the same key get the stored response back without being processed again,
+// whereas requests arriving while the first one is still being processed are rejected with a conflict.
+// Retries need to carry the same body as the first request, otherwise they are rejected as unprocessable.
+// Source auth info needs to be present in the request context, i.e. this middleware needs to run after writeKeyAuth.
+func (gw *Handle) idempotencyKeyCheck(delegate http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || gw.idempotencyStore == nil || !gw.conf.idempotencyEnabled.Load() {
+			delegate.ServeHTTP(w, r)
+			return
+		}
+		reqType := r.Context().Value(gwtypes.CtxParamCallType).(string)
+		arctx := r.Context().Value(gwtypes.CtxParamAuthRequestContext).(*gwtypes.AuthRequestContext)
+		if len(key) > maxIdempotencyKeyLength {
+			gw.handleHttpError(w, r, response.InvalidIdempotencyKey)
+			gw.reportRequestFailed(arctx, reqType, response.InvalidIdempotencyKey)
+			return
+		}
+		tags := stats.Tags{
+			"workspaceId": arctx.WorkspaceID,
+			"sourceID":    arctx.SourceID,
+			"reqType":     reqType,
+		}
+		body, err := gw.getPayloadFromRequest(r)
+		if err != nil {
+			gw.handleHttpError(w, r, err.Error())
+			gw.reportRequestFailed(arctx, reqType, err.Error())
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), ctxParamPayload, body))
+		r.Body = io.NopCloser(bytes.NewReader(body)) // for handlers reading the body on their own
+		storeKey := arctx.WriteKey + ":" + key
+		stored, status, token := gw.idempotencyStore.Begin(storeKey, idempotency.Fingerprint(body))
+		switch status {
+		case idempotency.Completed:
+			gw.stats.NewTaggedStat(idempotencyKeyStatsPrefix+"hits", stats.CountType, tags).Increment()
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(stored.StatusCode)
+			_, _ = w.Write(stored.Body)
+			return
+		case idempotency.InProgress:
+			gw.stats.NewTaggedStat(idempotencyKeyStatsPrefix+"conflicts", stats.CountType, tags).Increment()
+			gw.handleHttpError(w, r, response.IdempotencyKeyInProgress)
+			gw.reportRequestFailed(arctx, reqType, response.IdempotencyKeyInProgress)
+			return
+		case idempotency.Mismatch:
+			gw.stats.NewTaggedStat(idempotencyKeyStatsPrefix+"mismatches", stats.CountType, tags).Increment()
+			gw.handleHttpError(w, r, response.IdempotencyKeyMismatch)
+			gw.reportRequestFailed(arctx, reqType, response.IdempotencyKeyMismatch)
+			return
+		}
+		gw.stats.NewTaggedStat(idempotencyKeyStatsPrefix+"misses", stats.CountType, tags).Increment()
+		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		delegate.ServeHTTP(rec, r)
+		gw.idempotencyStore.Complete(storeKey, token, idempotency.Response{
+			StatusCode:  rec.statusCode,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		})
+	}
+}
+
+// responseRecorder is a http.ResponseWriter that keeps a copy of the status code and body written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	if !rr.wroteHeader {
+		rr.statusCode = statusCode
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
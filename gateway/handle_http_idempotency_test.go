@@ -0,0 +1,178 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
+
+	"github.com/rudderlabs/rudder-server/gateway/internal/idempotency"
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	gwtypes "github.com/rudderlabs/rudder-server/gateway/types"
+)
+
+func TestIdempotencyKeyCheck(t *testing.T) {
+	hour := func() time.Duration { return time.Hour }
+	newGateway := func(t *testing.T) (*Handle, *memstats.Store) {
+		statsStore, err := memstats.New()
+		require.NoError(t, err)
+		idempotencyStore, err := idempotency.NewStore(100, hour, hour)
+		require.NoError(t, err)
+		gw := &Handle{
+			logger:           logger.NOP,
+			stats:            statsStore,
+			idempotencyStore: idempotencyStore,
+			bodyReadTimeStat: statsStore.NewStat("gateway.http_body_read_time", stats.TimerType),
+		}
+		gw.conf.idempotencyEnabled = config.SingleValueLoader(true)
+		return gw, statsStore
+	}
+	const body = `{"batch":[{"type":"track"}]}`
+	newRequestWithBody := func(writeKey, idempotencyKey, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/v1/batch", strings.NewReader(body))
+		if idempotencyKey != "" {
+			r.Header.Set(idempotencyKeyHeader, idempotencyKey)
+		}
+		ctx := context.WithValue(r.Context(), gwtypes.CtxParamCallType, "batch")
+		ctx = context.WithValue(ctx, gwtypes.CtxParamAuthRequestContext, &gwtypes.AuthRequestContext{
+			WriteKey:    writeKey,
+			SourceID:    "source-" + writeKey,
+			WorkspaceID: "workspace",
+		})
+		return r.WithContext(ctx)
+	}
+	newRequest := func(writeKey, idempotencyKey string) *http.Request {
+		return newRequestWithBody(writeKey, idempotencyKey, body)
+	}
+	tags := func(writeKey string) stats.Tags {
+		return stats.Tags{"workspaceId": "workspace", "sourceID": "source-" + writeKey, "reqType": "batch"}
+	}
+
+	t.Run("retries with the same key get the original response", func(t *testing.T) {
+		gw, statsStore := newGateway(t)
+		var calls int
+		handler := gw.idempotencyKeyCheck(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			payload, err := gw.getPayloadFromRequest(r)
+			require.NoError(t, err)
+			require.Equal(t, body, string(payload), "the body should still be readable")
+			_, _ = w.Write([]byte("OK"))
+		})
+
+		for range 3 {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newRequest("wk", "key-1"))
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, "OK", w.Body.String())
+		}
+		require.Equal(t, 1, calls, "request should only be processed once")
+		require.Len(t, statsStore.Get("gateway.http_body_read_time", nil).Durations(), 3, "the body of each request should only be read once")
+		require.EqualValues(t, 1, statsStore.Get("gateway.idempotency_key_misses", tags("wk")).LastValue())
+		require.EqualValues(t, 2, statsStore.Get("gateway.idempotency_key_hits", tags("wk")).LastValue())
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest("wk", "key-1"))
+		require.Equal(t, "true", w.Header().Get(idempotentReplayedHeader))
+
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("other-wk", "key-1"))
+		require.Equal(t, 2, calls, "keys should be scoped per write key")
+
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("wk", ""))
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("wk", ""))
+		require.Equal(t, 4, calls, "requests without a key should always be processed")
+	})
+
+	t.Run("failed requests are processed again", func(t *testing.T) {
+		gw, _ := newGateway(t)
+		var calls int
+		handler := gw.idempotencyKeyCheck(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				http.Error(w, response.GatewayTimeout, http.StatusGatewayTimeout)
+				return
+			}
+			_, _ = w.Write([]byte("OK"))
+		})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest("wk", "key-1"))
+		require.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest("wk", "key-1"))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("concurrent request with the same key", func(t *testing.T) {
+		gw, statsStore := newGateway(t)
+		var inner *httptest.ResponseRecorder
+		handler := gw.idempotencyKeyCheck(func(w http.ResponseWriter, r *http.Request) {
+			inner = httptest.NewRecorder()
+			gw.idempotencyKeyCheck(func(w http.ResponseWriter, r *http.Request) {
+				t.Fatal("request in progress should not be processed")
+			}).ServeHTTP(inner, newRequest("wk", "key-1"))
+			_, _ = w.Write([]byte("OK"))
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("wk", "key-1"))
+		require.Equal(t, http.StatusConflict, inner.Code)
+		require.EqualValues(t, 1, statsStore.Get("gateway.idempotency_key_conflicts", tags("wk")).LastValue())
+		failed := statsStore.GetByName("gateway.write_key_failed_requests")
+		require.Len(t, failed, 1, "the in-progress rejection should be reported like other rejections")
+		require.Equal(t, response.IdempotencyKeyInProgress, failed[0].Tags["reason"])
+		require.EqualValues(t, 1, failed[0].Value)
+	})
+
+	t.Run("same key with a different body", func(t *testing.T) {
+		gw, statsStore := newGateway(t)
+		var calls int
+		handler := gw.idempotencyKeyCheck(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_, _ = w.Write([]byte("OK"))
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("wk", "key-1"))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequestWithBody("wk", "key-1", `{"batch":[{"type":"identify"}]}`))
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		require.Equal(t, response.IdempotencyKeyMismatch+"\n", w.Body.String())
+		require.Equal(t, 1, calls)
+		require.EqualValues(t, 1, statsStore.Get("gateway.idempotency_key_mismatches", tags("wk")).LastValue())
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		gw, _ := newGateway(t)
+		handler := gw.idempotencyKeyCheck(func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("request with an invalid key should not be processed")
+		})
+		longKey := make([]byte, maxIdempotencyKeyLength+1)
+		for i := range longKey {
+			longKey[i] = 'a'
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest("wk", string(longKey)))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, response.InvalidIdempotencyKey+"\n", w.Body.String())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		gw, _ := newGateway(t)
+		gw.conf.idempotencyEnabled = config.SingleValueLoader(false)
+		var calls int
+		handler := gw.idempotencyKeyCheck(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("wk", "key-1"))
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("wk", "key-1"))
+		require.Equal(t, 2, calls)
+	})
+}
@@ -0,0 +1,251 @@
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/sanitize"
+	"github.com/rudderlabs/rudder-go-kit/stringify"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/gateway/internal/eventschema"
+	"github.com/rudderlabs/rudder-server/gateway/internal/ndjson"
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	gwtypes "github.com/rudderlabs/rudder-server/gateway/types"
+	"github.com/rudderlabs/rudder-server/middleware"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+)
+
+const (
+	// maxImportStreamFailures is the maximum number of failed lines reported in the response of a streaming import request
+	maxImportStreamFailures = 1000
+	// batchPayloadOverhead is the size of the payload wrapping the events of a sub-batch, i.e. {"batch":[]}
+	batchPayloadOverhead = len(`{"batch":[]}`)
+)
+
+// importStreamResponse is the response of a streaming import request
+type importStreamResponse struct {
+	Lines             int                   `json:"lines"`    // number of lines read
+	Accepted          int                   `json:"accepted"` // number of events stored
+	Rejected          int                   `json:"rejected"` // number of events not stored
+	Failures          []importStreamFailure `json:"failures,omitempty"`
+	FailuresTruncated bool                  `json:"failuresTruncated,omitempty"`
+	Error             string                `json:"error,omitempty"` // set if reading the request body failed before reaching its end
+}
+
+type importStreamFailure struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// importStreamBatch is a sub-batch of events read from the request body, along with their line numbers
+type importStreamBatch struct {
+	payload bytes.Buffer
+	lines   []int
+}
+
+func (b *importStreamBatch) add(event []byte, line int) {
+	if len(b.lines) == 0 {
+		b.payload.WriteString(`{"batch":[`)
+	} else {
+		b.payload.WriteByte(',')
+	}
+	b.payload.Write(event)
+	b.lines = append(b.lines, line)
+}
+
+func (b *importStreamBatch) bytes() []byte {
+	return append(b.payload.Bytes(), ']', '}')
+}
+
+// importStreamLane is the sub-batch being filled with the events of the users assigned to a lane, along with the channel
+// its full sub-batches are sent to, to be stored one after the other
+type importStreamLane struct {
+	batch   *importStreamBatch
+	batches chan *importStreamBatch
+}
+
+// webImportStreamHandler can handle streaming import requests
+func (gw *Handle) webImportStreamHandler() http.HandlerFunc {
+	return gw.callType("import", gw.writeKeyAuth(gw.importStreamHandler))
+}
+
+// importStreamHandler reads newline-delimited JSON events of arbitrary length from the request body, one event per line.
+// Events are stored in sub-batches as they are read, so that reading the body is paused while the gateway is busy storing previous sub-batches.
+// Each user is assigned to one of a bounded number of lanes, whose sub-batches are stored one after the other,
+// so that the events of a user are stored in the order they were read while sub-batches of different lanes are stored concurrently.
+//
+// The response reports the number of accepted and rejected events, along with the line numbers of the rejected ones.
+// Since a sub-batch is stored as a whole, every line is validated before being added to one, so that an invalid event
+// is rejected on its own instead of failing the valid events of its sub-batch. A sub-batch can still fail as a whole,
+// e.g. if it is rate limited or storing it fails, in which case all of its events are rejected.
+func (gw *Handle) importStreamHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	reqType := ctx.Value(gwtypes.CtxParamCallType).(string)
+	arctx := ctx.Value(gwtypes.CtxParamAuthRequestContext).(*gwtypes.AuthRequestContext)
+	gw.logger.LogRequest(r)
+
+	// backfills can take much longer than the server's write timeout allows
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	middleware.RemoveDecompressedSizeLimit(r)
+
+	var (
+		mu  sync.Mutex // protects res
+		res importStreamResponse
+	)
+	reject := func(lines []int, reason string) {
+		mu.Lock()
+		defer mu.Unlock()
+		res.Rejected += len(lines)
+		for _, line := range lines {
+			if len(res.Failures) == maxImportStreamFailures {
+				res.FailuresTruncated = true
+				break
+			}
+			res.Failures = append(res.Failures, importStreamFailure{Line: line, Error: reason})
+		}
+	}
+
+	store := func(batch *importStreamBatch) {
+		errorMessage := gw.rrh.ProcessRequest(&w, r, "batch", batch.bytes(), arctx)
+		gw.TrackRequestMetrics(errorMessage)
+		if errorMessage != "" {
+			reject(batch.lines, response.GetStatus(errorMessage))
+			return
+		}
+		mu.Lock()
+		res.Accepted += len(batch.lines)
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	lanes := make([]*importStreamLane, gw.conf.importStreamConcurrency.Load())
+	for i := range lanes {
+		lane := &importStreamLane{batch: &importStreamBatch{}, batches: make(chan *importStreamBatch)}
+		lanes[i] = lane
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range lane.batches {
+				store(batch)
+			}
+		}()
+	}
+
+	var (
+		schemaValidator = gw.eventSchemaValidator(arctx.SourceID)
+		maxBatchSize    = gw.conf.importStreamMaxBatchSize.Load()
+		maxBatchBytes   = gw.conf.maxReqSize.Load() - batchPayloadOverhead
+		reader          = ndjson.NewReader(r.Body, maxBatchBytes)
+		readErr         error
+	)
+	for {
+		event, line, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		res.Lines = line
+		if errors.Is(err, ndjson.ErrLineTooLong) {
+			reject([]int{line}, response.RequestBodyTooLarge)
+			continue
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		if !gjson.ValidBytes(event) || !gjson.ParseBytes(event).IsObject() {
+			reject([]int{line}, response.InvalidJSON)
+			continue
+		}
+		userID, reason := gw.validateImportStreamEvent(event, schemaValidator)
+		if reason != "" {
+			reject([]int{line}, reason)
+			continue
+		}
+		lane := lanes[misc.GetHash(userID)%len(lanes)]
+		if batch := lane.batch; len(batch.lines) > 0 && (len(batch.lines) >= maxBatchSize || batch.payload.Len()+len(event)+1 > maxBatchBytes) {
+			lane.batches <- batch
+			lane.batch = &importStreamBatch{}
+		}
+		lane.batch.add(event, line)
+	}
+	for _, lane := range lanes {
+		if len(lane.batch.lines) > 0 {
+			lane.batches <- lane.batch
+		}
+		close(lane.batches)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if readErr != nil {
+		errorMessage := response.RequestBodyReadFailed
+		if middleware.IsDecompressionLimitError(readErr) {
+			errorMessage = response.DecompressedRequestBodyTooLarge
+		}
+		gw.logger.Warnn("Failed to read streaming import request body",
+			obskit.SourceID(arctx.SourceID),
+			obskit.WorkspaceID(arctx.WorkspaceID),
+			logger.NewIntField("lines", int64(res.Lines)),
+			obskit.Error(readErr),
+		)
+		gw.reportRequestFailed(arctx, reqType, errorMessage)
+		status = response.GetErrorStatusCode(errorMessage)
+		res.Error = response.GetStatus(errorMessage)
+	}
+	body, err := jsonrs.Marshal(res)
+	if err != nil {
+		gw.handleHttpError(w, r, response.ErrorInMarshal)
+		return
+	}
+	gw.logger.Debugn("response",
+		logger.NewStringField("path", r.URL.Path),
+		logger.NewIntField("status", int64(status)),
+		logger.NewIntField("accepted", int64(res.Accepted)),
+		logger.NewIntField("rejected", int64(res.Rejected)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// validateImportStreamEvent returns the user the event belongs to, along with the reason for which the event would make the whole batch
+// it is stored with fail, if any, applying the same checks to it as [Handle.getJobDataFromRequest] applies to each event of a batch
+func (gw *Handle) validateImportStreamEvent(event []byte, schemaValidator *eventschema.Validator) (userID, reason string) {
+	parsed := gjson.ParseBytes(event)
+	anonIDFromReq := strings.TrimSpace(sanitize.Unicode(stringify.Any(parsed.Get("anonymousId").Value())))
+	userIDFromReq := strings.TrimSpace(sanitize.Unicode(stringify.Any(parsed.Get("userId").Value())))
+	eventTypeFromReq, _ := parsed.Get("type").Value().(string)
+	userID = buildUserID("", anonIDFromReq, userIDFromReq)
+	if gw.isNonIdentifiable(anonIDFromReq, userIDFromReq, eventTypeFromReq) {
+		return userID, response.GetStatus(response.NonIdentifiableRequest)
+	}
+
+	if schemaValidator == nil || schemaValidator.Policy() != eventschema.PolicyReject {
+		return userID, ""
+	}
+	var toValidate map[string]any
+	if err := jsonrs.Unmarshal(event, &toValidate); err != nil {
+		return userID, response.GetStatus(response.InvalidJSON)
+	}
+	violations, err := schemaValidator.Validate(toValidate)
+	if err != nil {
+		gw.logger.Warnn("Failed to validate event against event schema", obskit.Error(err))
+	}
+	if len(violations) == 0 {
+		return userID, ""
+	}
+	schemaViolations := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		schemaViolations = append(schemaViolations, violation.String())
+	}
+	return userID, (&eventSchemaError{violations: schemaViolations}).Error()
+}
@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"net/http"
+
+	kithttputil "github.com/rudderlabs/rudder-go-kit/httputil"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/gateway/internal/otlp"
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	gwtypes "github.com/rudderlabs/rudder-server/gateway/types"
+)
+
+// webOTLPTracesHandler - handler for OTLP/HTTP trace export requests
+func (gw *Handle) webOTLPTracesHandler() http.HandlerFunc {
+	return gw.callType("otlp", gw.writeKeyAuth(gw.otlpHandler(otlp.SignalTraces)))
+}
+
+// webOTLPLogsHandler - handler for OTLP/HTTP log export requests
+func (gw *Handle) webOTLPLogsHandler() http.HandlerFunc {
+	return gw.callType("otlp", gw.writeKeyAuth(gw.otlpHandler(otlp.SignalLogs)))
+}
+
+// otlpHandler handles OTLP/HTTP export requests (protobuf or json encoded).
+// Every span or log record is converted into a track event and the resulting batch goes through the regular request handler,
+// sharing its validation and storage path with the /v1/batch endpoint.
+// On success it responds with an empty export response, encoded the same way as the request.
+func (gw *Handle) otlpHandler(signal otlp.Signal) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		reqType := ctx.Value(gwtypes.CtxParamCallType).(string)
+		arctx := ctx.Value(gwtypes.CtxParamAuthRequestContext).(*gwtypes.AuthRequestContext)
+		r = withRetryAfter(r)
+
+		gw.logger.LogRequest(r)
+		var errorMessage string
+		defer func() {
+			gw.handleHttpError(w, r, errorMessage)
+		}()
+		encoding, err := otlp.Encoding(r.Header.Get("Content-Type"))
+		if err != nil {
+			errorMessage = response.UnsupportedContentType
+			gw.reportRequestFailed(arctx, reqType, errorMessage)
+			return
+		}
+		payload, err := gw.getPayload(arctx, r, reqType)
+		if err != nil {
+			errorMessage = err.Error()
+			return
+		}
+		batch, count, err := otlp.ToBatch(signal, encoding, payload)
+		if err != nil {
+			gw.logger.Infon("invalid otlp payload",
+				obskit.SourceID(arctx.SourceID),
+				obskit.WorkspaceID(arctx.WorkspaceID),
+				logger.NewStringField("signal", string(signal)),
+				obskit.Error(err),
+			)
+			errorMessage = response.InvalidOTLPPayload
+			gw.reportRequestFailed(arctx, reqType, errorMessage)
+			return
+		}
+		if count > 0 {
+			errorMessage = gw.rrh.ProcessRequest(&w, r, "batch", batch, arctx)
+			gw.TrackRequestMetrics(errorMessage)
+			if errorMessage != "" {
+				return
+			}
+		}
+		responseBody, err := otlp.Response(signal, encoding)
+		if err != nil {
+			errorMessage = response.ErrorInMarshal
+			return
+		}
+		gw.logger.Debugn("response",
+			logger.NewStringField("ip", kithttputil.GetRequestIP(r)),
+			logger.NewStringField("path", r.URL.Path),
+			logger.NewIntField("status", int64(http.StatusOK)),
+			logger.NewIntField("records", int64(count)))
+		w.Header().Set("Content-Type", encoding)
+		_, _ = w.Write(responseBody)
+	}
+}
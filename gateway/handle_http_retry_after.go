@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type retryAfterCtxKey struct{}
+
+// retryAfter carries the time after which a rate limited request can be retried,
+// from the worker processing the request back to its http handler
+type retryAfter struct {
+	mu sync.Mutex
+	d  time.Duration
+}
+
+// set records the duration, keeping the longest one if a request is split across many workers. It is a no-op on a nil receiver.
+func (ra *retryAfter) set(d time.Duration) {
+	if ra == nil {
+		return
+	}
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if d > ra.d {
+		ra.d = d
+	}
+}
+
+func (ra *retryAfter) get() time.Duration {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	return ra.d
+}
+
+// withRetryAfter returns a copy of the request with a [retryAfter] in its context,
+// so that [setRetryAfterHeader] can set the Retry-After header if the request ends up being rate limited
+func withRetryAfter(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), retryAfterCtxKey{}, &retryAfter{}))
+}
+
+func retryAfterFromContext(ctx context.Context) *retryAfter {
+	ra, _ := ctx.Value(retryAfterCtxKey{}).(*retryAfter)
+	return ra
+}
+
+// setRetryAfterHeader sets the Retry-After header in seconds, rounded up, if a retry time has been recorded for the request
+func setRetryAfterHeader(w http.ResponseWriter, r *http.Request) {
+	ra := retryAfterFromContext(r.Context())
+	if ra == nil {
+		return
+	}
+	if d := ra.get(); d > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+	}
+}
@@ -16,6 +16,9 @@ import (
 	"github.com/rudderlabs/rudder-go-kit/filemanager"
 	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
 
+	"github.com/rudderlabs/rudder-server/gateway/internal/eventschema"
+	"github.com/rudderlabs/rudder-server/gateway/internal/idempotency"
+	"github.com/rudderlabs/rudder-server/gateway/internal/pii"
 	gwtypes "github.com/rudderlabs/rudder-server/gateway/types"
 
 	"github.com/rudderlabs/rudder-server/gateway/validator"
@@ -43,6 +46,7 @@ import (
 	"github.com/rudderlabs/rudder-server/gateway/webhook"
 	"github.com/rudderlabs/rudder-server/jobsdb"
 	"github.com/rudderlabs/rudder-server/middleware"
+	"github.com/rudderlabs/rudder-server/services/backlogquota"
 	sourcedebugger "github.com/rudderlabs/rudder-server/services/debugger/source"
 	"github.com/rudderlabs/rudder-server/services/diagnostics"
 	"github.com/rudderlabs/rudder-server/services/rsources"
@@ -108,8 +112,38 @@ func (gw *Handle) Setup(
 	gw.conf.gwAllowPartialWriteWithErrors = config.GetReloadableBoolVar(true, "Gateway.allowPartialWriteWithErrors")
 	// Maximum request size to gateway
 	gw.conf.maxReqSize = config.GetReloadableIntVar(4000, 1024, "Gateway.maxReqSizeInKB")
+	// Maximum size of a compressed request's body after decompression, 4 times maxReqSizeInKB if not set,
+	// so that a small compressed payload cannot make the gateway allocate an arbitrary amount of memory
+	gw.conf.maxDecompressedReqSize = decompressedReqSizeLoader{
+		maxReqSize:             gw.conf.maxReqSize,
+		maxDecompressedReqSize: config.GetReloadableIntVar(0, 1024, "Gateway.maxDecompressedReqSizeInKB"),
+	}
+	// Maximum ratio between the decompressed and compressed size of a request's body, '0' means disabled
+	gw.conf.maxDecompressionRatio = config.GetReloadableIntVar(100, 1, "Gateway.maxDecompressionRatio")
 	// Enable rate limit on incoming events. false by default
 	gw.conf.enableRateLimit = config.GetReloadableBoolVar(false, "Gateway.enableRateLimit")
+	// Enable rate limits per source and write key on incoming events and bytes, see RateLimit.source.* and RateLimit.writeKey.*. false by default
+	gw.conf.enableSourceRateLimit = config.GetReloadableBoolVar(false, "Gateway.enableSourceRateLimit")
+	// Enable validation of events against the event schema of their source, if any. false by default
+	gw.conf.enableEventSchemaValidation = config.GetReloadableBoolVar(false, "Gateway.enableEventSchemaValidation")
+	// Enable hashing, masking or removal of the PII fields configured for a source before its events are stored. true by default
+	gw.conf.enablePIIScrubbing = config.GetReloadableBoolVar(true, "Gateway.enablePIIScrubbing")
+	// Secret from which the salt for hashing PII fields of each workspace is derived
+	gw.conf.piiSalt = config.GetStringVar("", "Gateway.pii.salt")
+	// Maximum number of events stored together while streaming an import request
+	gw.conf.importStreamMaxBatchSize = config.GetReloadableIntVar(200, 1, "Gateway.importStream.maxBatchSize")
+	// Number of lanes the users of a streaming import request are spread over, the sub-batches of each lane being stored in order
+	gw.conf.importStreamConcurrency = config.GetReloadableIntVar(4, 1, "Gateway.importStream.concurrency")
+	// Enable the gRPC ingestion API. false by default
+	gw.conf.grpcEnabled = config.GetBoolVar(false, "Gateway.grpc.enabled")
+	// Port where the gRPC ingestion API is served
+	gw.conf.grpcPort = config.GetIntVar(8088, 1, "Gateway.grpc.port")
+	// Time given to open gRPC streams to complete while shutting down
+	gw.conf.grpcShutdownTimeout = config.GetDurationVar(10, time.Second, "Gateway.grpc.shutdownTimeout")
+	// Maximum number of requests of a gRPC stream being processed concurrently
+	gw.conf.grpcStreamConcurrency = config.GetReloadableIntVar(64, 1, "Gateway.grpc.streamConcurrency")
+	// Sources whose raw requests are captured, if capturing is enabled
+	gw.conf.captureSourceIDs = config.GetReloadableStringSliceVar([]string{}, "Gateway.capture.sourceIDs")
 	// Enable suppress user feature. false by default
 	gw.conf.enableSuppressUserFeature = config.GetBoolVar(true, "Gateway.enableSuppressUserFeature")
 	// Time period for diagnosis ticker
@@ -130,6 +164,20 @@ func (gw *Handle) Setup(
 	// enable webhook v2 handler. disabled by default
 	gw.conf.webhookV2HandlerEnabled = config.GetBoolVar(false, "Gateway.webhookV2HandlerEnabled")
 	gw.conf.errorDBEnabled = config.GetReloadableBoolVar(false, "ErrorDB.enabled")
+	// Enables honouring the Idempotency-Key header in batch & import requests. false by default,
+	// since keys are kept in the memory of each gateway replica: a retry reaching another replica, or arriving after a restart, is processed again
+	gw.conf.idempotencyEnabled = config.GetReloadableBoolVar(false, "Gateway.idempotency.enabled")
+	idempotencyKeyTTL := config.GetReloadableDurationVar(24, time.Hour, "Gateway.idempotency.ttl")
+	idempotencyKeyInProgressTTL := config.GetReloadableDurationVar(1, time.Minute, "Gateway.idempotency.inProgressTTL")
+	idempotencyStore, err := idempotency.NewStore(
+		config.GetIntVar(100_000, 1, "Gateway.idempotency.maxKeys"),
+		idempotencyKeyTTL.Load,
+		idempotencyKeyInProgressTTL.Load,
+	)
+	if err != nil {
+		return fmt.Errorf("could not setup idempotency store: %w", err)
+	}
+	gw.idempotencyStore = idempotencyStore
 	// Registering stats
 	gw.batchSizeStat = gw.stats.NewStat("gateway.batch_size", stats.HistogramType)
 	gw.requestSizeStat = gw.stats.NewStat("gateway.request_size", stats.HistogramType)
@@ -231,6 +279,15 @@ func (gw *Handle) Setup(
 		gw.collectMetrics(ctx)
 		return nil
 	}))
+	if capturer, err := gw.newCapturer(ctx); err != nil {
+		gw.logger.Errorn("failed to create request capturer in gateway", obskit.Error(err))
+	} else if capturer != nil {
+		gw.capturer = capturer
+		g.Go(crash.Wrapper(func() error {
+			capturer.Run(ctx)
+			return nil
+		}))
+	}
 
 	if leakyUploaderEnabled {
 		leakyUploaderDone = make(chan struct{})
@@ -324,6 +381,13 @@ func WithInternalHttpHandlers(handlers map[string]http.Handler) OptFunc {
 	}
 }
 
+// WithBacklogQuota rejects new events of the workspaces exceeding their backlog quota
+func WithBacklogQuota(quota backlogquota.Quota) OptFunc {
+	return func(gw *Handle) {
+		gw.backlogQuota = quota
+	}
+}
+
 func WithNow(now func() time.Time) OptFunc {
 	return func(gw *Handle) {
 		gw.now = now
@@ -354,6 +418,8 @@ func (gw *Handle) processBackendConfig(configData map[string]backendconfig.Confi
 		sourceIDSourceMap                 = map[string]backendconfig.SourceT{}
 		nonEventStreamSources             = map[string]bool{}
 		blockedEventsWorkspaceTypeNameMap = map[string]map[string]map[string]bool{}
+		sourceIDEventSchemaMap            = map[string]*eventschema.Validator{}
+		sourceIDPIIScrubberMap            = map[string]*pii.Scrubber{}
 	)
 
 	for workspaceID, wsConfig := range configData {
@@ -368,6 +434,25 @@ func (gw *Handle) processBackendConfig(configData map[string]backendconfig.Confi
 			if source.SourceDefinition.Category != "" && !strings.EqualFold(source.SourceDefinition.Category, webhookSourceCategory) {
 				nonEventStreamSources[source.ID] = true
 			}
+			if v, err := eventschema.FromSourceConfig(source.Config); err != nil {
+				gw.logger.Errorn("Invalid event schema, events of the source will not be validated",
+					obskit.SourceID(source.ID),
+					obskit.WorkspaceID(workspaceID),
+					obskit.Error(err),
+				)
+			} else if v != nil {
+				sourceIDEventSchemaMap[source.ID] = v
+			}
+			if s, err := pii.FromSourceConfig(source.Config, gw.piiWorkspaceSalt(workspaceID)); err != nil {
+				gw.logger.Errorn("Invalid PII rules, events of the source will be rejected until they are fixed",
+					obskit.SourceID(source.ID),
+					obskit.WorkspaceID(workspaceID),
+					obskit.Error(err),
+				)
+				sourceIDPIIScrubberMap[source.ID] = pii.Invalid(err)
+			} else if s != nil {
+				sourceIDPIIScrubberMap[source.ID] = s
+			}
 		}
 
 		if len(wsConfig.Settings.EventBlocking.Events) > 0 {
@@ -388,6 +473,8 @@ func (gw *Handle) processBackendConfig(configData map[string]backendconfig.Confi
 	gw.sourceIDSourceMap = sourceIDSourceMap
 	gw.nonEventStreamSources = nonEventStreamSources
 	gw.blockedEventsWorkspaceTypeNameMap = blockedEventsWorkspaceTypeNameMap
+	gw.sourceIDEventSchemaMap = sourceIDEventSchemaMap
+	gw.sourceIDPIIScrubberMap = sourceIDPIIScrubberMap
 	gw.configSubscriberLock.Unlock()
 }
 
@@ -586,7 +673,7 @@ func (gw *Handle) StartWebHandler(ctx context.Context) error {
 		},
 		chiware.StatMiddleware(ctx, stats.Default, component),
 		middleware.LimitConcurrentRequests(gw.conf.maxConcurrentRequests),
-		middleware.UncompressMiddleware,
+		middleware.NewUncompressMiddleware(gw.stats, gw.conf.maxDecompressedReqSize, gw.conf.maxDecompressionRatio),
 	)
 	srvMux.Route("/internal", func(r chi.Router) {
 		r.Post("/v1/extract", gw.webExtractHandler())
@@ -620,6 +707,9 @@ func (gw *Handle) StartWebHandler(ctx context.Context) error {
 		r.Post("/track", gw.webTrackHandler())
 
 		r.Post("/import", gw.webImportHandler())
+		r.Post("/import/stream", gw.webImportStreamHandler())
+		r.Post("/traces", gw.webOTLPTracesHandler())
+		r.Post("/logs", gw.webOTLPLogsHandler())
 		r.Post("/webhook", gw.webhookHandler())
 
 		r.Get("/webhook", gw.webhookHandler())
@@ -673,7 +763,16 @@ func (gw *Handle) StartWebHandler(ctx context.Context) error {
 		MaxHeaderBytes:    gw.conf.maxHeaderBytes,
 	}
 
-	return kithttputil.ListenAndServe(ctx, srv)
+	g, ctx = errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return kithttputil.ListenAndServe(ctx, srv)
+	})
+	if gw.conf.grpcEnabled {
+		g.Go(func() error {
+			return gw.serveGRPC(ctx)
+		})
+	}
+	return g.Wait()
 }
 
 // Shutdown the gateway
@@ -692,3 +791,17 @@ func (gw *Handle) Shutdown() error {
 
 	return gw.backgroundWait()
 }
+
+// decompressedReqSizeLoader loads the maximum size of a request's body after decompression,
+// falling back to 4 times the current maximum request size if it is not configured explicitly
+type decompressedReqSizeLoader struct {
+	maxReqSize             config.ValueLoader[int]
+	maxDecompressedReqSize config.ValueLoader[int]
+}
+
+func (l decompressedReqSizeLoader) Load() int {
+	if maxSize := l.maxDecompressedReqSize.Load(); maxSize > 0 {
+		return maxSize
+	}
+	return 4 * l.maxReqSize.Load()
+}
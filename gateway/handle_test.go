@@ -1,7 +1,12 @@
 package gateway
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -16,6 +21,8 @@ import (
 	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
 	"github.com/rudderlabs/rudder-schemas/go/stream"
 	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	"github.com/rudderlabs/rudder-server/middleware"
 	mocks_gateway "github.com/rudderlabs/rudder-server/mocks/gateway"
 )
 
@@ -71,35 +78,16 @@ func createTestGateway(t *testing.T, eventBlockingSettings backendconfig.EventBl
 	mockWebhook.EXPECT().Register(gomock.Any()).AnyTimes() // Allow any number of Register calls
 
 	gw := &Handle{
-		stats:   statsStore,
-		logger:  logger.NOP,
-		webhook: mockWebhook,
-		conf: struct {
-			webPort, maxUserWebRequestWorkerProcess, maxDBWriterProcess                       int
-			maxUserWebRequestBatchSize, maxDBBatchSize, maxHeaderBytes, maxConcurrentRequests int
-			userWebRequestBatchTimeout, dbBatchWriteTimeout                                   config.ValueLoader[time.Duration]
-			maxReqSize                                                                        config.ValueLoader[int]
-			enableRateLimit                                                                   config.ValueLoader[bool]
-			enableSuppressUserFeature                                                         bool
-			diagnosisTickerTime                                                               time.Duration
-			ReadTimeout                                                                       time.Duration
-			ReadHeaderTimeout                                                                 time.Duration
-			WriteTimeout                                                                      time.Duration
-			IdleTimeout                                                                       time.Duration
-			allowReqsWithoutUserIDAndAnonymousID                                              config.ValueLoader[bool]
-			gwAllowPartialWriteWithErrors                                                     config.ValueLoader[bool]
-			enableInternalBatchValidator                                                      config.ValueLoader[bool]
-			enableInternalBatchEnrichment                                                     config.ValueLoader[bool]
-			webhookV2HandlerEnabled                                                           bool
-			errorDBEnabled                                                                    config.ValueLoader[bool]
-		}{
-			enableInternalBatchValidator:  config.SingleValueLoader(false),
-			enableInternalBatchEnrichment: config.SingleValueLoader(false),
-			webhookV2HandlerEnabled:       false,
-		},
+		stats:                statsStore,
+		logger:               logger.NOP,
+		webhook:              mockWebhook,
 		configSubscriberLock: sync.RWMutex{},
 		requestSizeStat:      statsStore.NewStat("gateway.request_size", stats.HistogramType),
 	}
+	gw.conf.enableInternalBatchValidator = config.SingleValueLoader(false)
+	gw.conf.enableInternalBatchEnrichment = config.SingleValueLoader(false)
+	gw.conf.enablePIIScrubbing = config.SingleValueLoader(true)
+	gw.conf.webhookV2HandlerEnabled = false
 
 	// Use the same logic as backendConfigSubscriber to process the config data
 	gw.processBackendConfig(configData)
@@ -929,3 +917,55 @@ func TestExtractJobsFromInternalBatchPayload_LiveEventRecording(t *testing.T) {
 		})
 	}
 }
+
+func TestGetPayloadFromRequestDecompressionLimits(t *testing.T) {
+	gw := &Handle{
+		logger:           logger.NOP,
+		bodyReadTimeStat: stats.NOP.NewStat("gateway.http_body_read_time", stats.TimerType),
+	}
+	payload := []byte(strings.Repeat(`{"batch": []}`, 100))
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	read := func(maxSize int) ([]byte, error) {
+		var (
+			body []byte
+			err  error
+		)
+		handler := middleware.NewUncompressMiddleware(stats.NOP, config.SingleValueLoader(maxSize), config.SingleValueLoader(0))(
+			http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				body, err = gw.getPayloadFromRequest(r)
+			}),
+		)
+		req := httptest.NewRequest(http.MethodPost, "/v1/batch", bytes.NewReader(compressed.Bytes()))
+		req.Header.Set("Content-Encoding", "gzip")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return body, err
+	}
+
+	body, err := read(len(payload))
+	require.NoError(t, err)
+	require.Equal(t, payload, body)
+
+	_, err = read(len(payload) - 1)
+	require.EqualError(t, err, response.DecompressedRequestBodyTooLarge)
+	require.Equal(t, http.StatusRequestEntityTooLarge, response.GetErrorStatusCode(err.Error()))
+}
+
+func TestDecompressedReqSizeLoader(t *testing.T) {
+	c := config.New()
+	l := decompressedReqSizeLoader{
+		maxReqSize:             c.GetReloadableIntVar(4000, 1024, "Gateway.maxReqSizeInKB"),
+		maxDecompressedReqSize: c.GetReloadableIntVar(0, 1024, "Gateway.maxDecompressedReqSizeInKB"),
+	}
+	require.Equal(t, 4*4000*1024, l.Load(), "defaults to 4 times the max request size")
+
+	c.Set("Gateway.maxReqSizeInKB", 10000)
+	require.Equal(t, 4*10000*1024, l.Load(), "follows the configured max request size")
+
+	c.Set("Gateway.maxDecompressedReqSizeInKB", 20000)
+	require.Equal(t, 20000*1024, l.Load(), "an explicit limit takes precedence")
+}
@@ -0,0 +1,124 @@
+// Package eventschema validates events against the JSON Schema attached to their source in backend config.
+//
+// The schema and the policy to apply to events violating it are read from the source's config:
+//
+//	{
+//	  "eventSchema": {
+//	    "policy": "reject|drop|annotate",
+//	    "schema": { ... }
+//	  }
+//	}
+package eventschema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+)
+
+// Policy is the action to take for events violating the schema of their source
+type Policy string
+
+const (
+	// PolicyReject rejects the whole request if any of its events violates the schema
+	PolicyReject Policy = "reject"
+	// PolicyDrop drops the events violating the schema and accepts the rest
+	PolicyDrop Policy = "drop"
+	// PolicyAnnotate accepts all events, adding any violations to the context of the event
+	PolicyAnnotate Policy = "annotate"
+)
+
+// Violation is a violation of the schema by an event,
+// in the same format as tracking plan violations are reported in context.violationErrors.
+// The processor appends any tracking plan violations to the ones annotated here, instead of overwriting them.
+type Violation struct {
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+	Property string `json:"property"`
+}
+
+func (v Violation) String() string {
+	return v.Property + ": " + v.Message
+}
+
+// Validator validates events against a compiled schema
+type Validator struct {
+	policy Policy
+	schema *gojsonschema.Schema
+}
+
+// New compiles the schema, returning an error if it isn't a valid JSON Schema or the policy is unknown
+func New(schema json.RawMessage, policy Policy) (*Validator, error) {
+	switch policy {
+	case PolicyReject, PolicyDrop, PolicyAnnotate:
+	case "":
+		policy = PolicyReject
+	default:
+		return nil, fmt.Errorf("unknown policy %q", policy)
+	}
+	s, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("compiling schema: %w", err)
+	}
+	return &Validator{policy: policy, schema: s}, nil
+}
+
+// FromSourceConfig returns the validator for the event schema in the source's config, or nil if the source doesn't have one.
+// Policy defaults to [PolicyReject].
+func FromSourceConfig(sourceConfig json.RawMessage) (*Validator, error) {
+	if len(sourceConfig) == 0 {
+		return nil, nil
+	}
+	var c struct {
+		EventSchema *struct {
+			Policy Policy          `json:"policy"`
+			Schema json.RawMessage `json:"schema"`
+		} `json:"eventSchema"`
+	}
+	if err := jsonrs.Unmarshal(sourceConfig, &c); err != nil {
+		return nil, fmt.Errorf("unmarshalling source config: %w", err)
+	}
+	if c.EventSchema == nil || len(c.EventSchema.Schema) == 0 || string(c.EventSchema.Schema) == "null" {
+		return nil, nil
+	}
+	return New(c.EventSchema.Schema, c.EventSchema.Policy)
+}
+
+// Policy returns the action to take for events violating the schema
+func (v *Validator) Policy() Policy {
+	return v.policy
+}
+
+// Validate returns the violations of the schema by the event, if any
+func (v *Validator) Validate(event map[string]any) ([]Violation, error) {
+	res, err := v.schema.Validate(gojsonschema.NewGoLoader(event))
+	if err != nil {
+		return nil, fmt.Errorf("validating event: %w", err)
+	}
+	if res.Valid() {
+		return nil, nil
+	}
+	violations := make([]Violation, 0, len(res.Errors()))
+	for _, e := range res.Errors() {
+		violations = append(violations, Violation{
+			Type:     e.Type(),
+			Message:  e.Description(),
+			Property: e.Field(),
+		})
+	}
+	return violations, nil
+}
+
+// Annotate adds the violations to the context of the event, creating the context if it is missing.
+// Events with a context which isn't an object are left untouched.
+func Annotate(event map[string]any, violations []Violation) {
+	switch eventContext := event["context"].(type) {
+	case map[string]any:
+		eventContext["violationErrors"] = violations
+	case nil:
+		event["context"] = map[string]any{"violationErrors": violations}
+	}
+}
@@ -0,0 +1,89 @@
+package eventschema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/gateway/internal/eventschema"
+)
+
+const schema = `{
+	"type": "object",
+	"required": ["event"],
+	"properties": {
+		"event": {"type": "string"},
+		"properties": {
+			"type": "object",
+			"properties": {"revenue": {"type": "number"}}
+		}
+	}
+}`
+
+func TestFromSourceConfig(t *testing.T) {
+	t.Run("no event schema", func(t *testing.T) {
+		for _, config := range []string{``, `{}`, `{"eventSchema": null}`, `{"eventSchema": {"policy": "drop"}}`, `{"eventSchema": {"schema": null}}`} {
+			v, err := eventschema.FromSourceConfig(json.RawMessage(config))
+			require.NoError(t, err, config)
+			require.Nil(t, v, config)
+		}
+	})
+
+	t.Run("default policy", func(t *testing.T) {
+		v, err := eventschema.FromSourceConfig(json.RawMessage(`{"eventSchema": {"schema": ` + schema + `}}`))
+		require.NoError(t, err)
+		require.NotNil(t, v)
+		require.Equal(t, eventschema.PolicyReject, v.Policy())
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		v, err := eventschema.FromSourceConfig(json.RawMessage(`{"eventSchema": {"policy": "annotate", "schema": ` + schema + `}}`))
+		require.NoError(t, err)
+		require.Equal(t, eventschema.PolicyAnnotate, v.Policy())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, config := range []string{
+			`{"eventSchema": {"policy": "ignore", "schema": ` + schema + `}}`,
+			`{"eventSchema": {"schema": {"type": "unknown"}}}`,
+			`{"eventSchema": "schema"}`,
+		} {
+			_, err := eventschema.FromSourceConfig(json.RawMessage(config))
+			require.Error(t, err, config)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	v, err := eventschema.New(json.RawMessage(schema), eventschema.PolicyReject)
+	require.NoError(t, err)
+
+	violations, err := v.Validate(map[string]any{"event": "Order Completed", "properties": map[string]any{"revenue": 10.5}})
+	require.NoError(t, err)
+	require.Empty(t, violations)
+
+	violations, err = v.Validate(map[string]any{"properties": map[string]any{"revenue": "10.5"}})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []eventschema.Violation{
+		{Type: "required", Message: "event is required", Property: "(root)"},
+		{Type: "invalid_type", Message: "Invalid type. Expected: number, given: string", Property: "properties.revenue"},
+	}, violations)
+	require.Equal(t, "(root): event is required", violations[0].String())
+}
+
+func TestAnnotate(t *testing.T) {
+	violations := []eventschema.Violation{{Type: "required", Message: "event is required", Property: "(root)"}}
+
+	event := map[string]any{"context": map[string]any{"ip": "1.2.3.4"}}
+	eventschema.Annotate(event, violations)
+	require.Equal(t, map[string]any{"context": map[string]any{"ip": "1.2.3.4", "violationErrors": violations}}, event)
+
+	event = map[string]any{}
+	eventschema.Annotate(event, violations)
+	require.Equal(t, map[string]any{"context": map[string]any{"violationErrors": violations}}, event)
+
+	event = map[string]any{"context": "invalid"}
+	eventschema.Annotate(event, violations)
+	require.Equal(t, map[string]any{"context": "invalid"}, event)
+}
@@ -0,0 +1,127 @@
+// Package idempotency keeps track of the responses given to requests carrying an idempotency key,
+// so that a client retrying a request can receive the original response instead of the request being processed twice.
+//
+// Keys are kept in memory, thus they are only known to the gateway replica which processed the request
+// and are lost whenever it restarts.
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// Status is the status of an idempotency key when a request using it begins
+type Status int
+
+const (
+	// Started means that the key was not known and has now been reserved by the caller
+	Started Status = iota
+	// InProgress means that another request using the same key is currently being processed
+	InProgress
+	// Completed means that a request using the same key has already been processed and its response is available
+	Completed
+	// Mismatch means that the key has already been used by a request with a different body
+	Mismatch
+)
+
+// Response is the response given to a request
+type Response struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Token identifies the reservation of a key made by [Store.Begin], so that a request only completes its own reservation
+type Token uint64
+
+type entry struct {
+	token       Token
+	fingerprint string
+	inProgress  bool
+	response    Response
+	expiresAt   time.Time
+}
+
+// Store is an in-memory store of idempotency keys, bounded both in size and in time.
+// When full, the least recently used keys are evicted first.
+type Store struct {
+	mu        sync.Mutex
+	cache     *lru.Cache[string, *entry]
+	now       func() time.Time
+	lastToken Token
+
+	ttl           func() time.Duration
+	inProgressTTL func() time.Duration
+}
+
+// NewStore creates a new store of up to maxKeys keys, where completed responses are kept for ttl and in-progress reservations for inProgressTTL,
+// so that a key doesn't stay reserved forever if its request never completes.
+func NewStore(maxKeys int, ttl, inProgressTTL func() time.Duration) (*Store, error) {
+	cache, err := lru.New[string, *entry](maxKeys)
+	if err != nil {
+		return nil, fmt.Errorf("creating idempotency keys cache: %w", err)
+	}
+	return &Store{
+		cache:         cache,
+		now:           time.Now,
+		ttl:           ttl,
+		inProgressTTL: inProgressTTL,
+	}, nil
+}
+
+// Fingerprint returns the fingerprint of a request body, to be passed to [Store.Begin]
+func Fingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Begin reserves the key for the request with the given body fingerprint if the key isn't known yet, returning [Started]
+// along with the token to pass to [Store.Complete].
+// Otherwise it returns [Mismatch] if the key was used with a different fingerprint, [InProgress] if another request is still using the key,
+// or [Completed] along with the stored response.
+func (s *Store) Begin(key, fingerprint string) (Response, Status, Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if e, ok := s.cache.Get(key); ok && now.Before(e.expiresAt) {
+		switch {
+		case e.fingerprint != fingerprint:
+			return Response{}, Mismatch, 0
+		case e.inProgress:
+			return Response{}, InProgress, 0
+		default:
+			return e.response, Completed, 0
+		}
+	}
+	s.lastToken++
+	s.cache.Add(key, &entry{token: s.lastToken, fingerprint: fingerprint, inProgress: true, expiresAt: now.Add(s.inProgressTTL())})
+	return Response{}, Started, s.lastToken
+}
+
+// Complete stores the response of a key previously reserved through [Store.Begin] with the given token.
+// Responses for errors that are worth retrying (5xx, 429) are not stored, releasing the key instead.
+// If the reservation expired and the key has been reserved again in the meantime, the key is left to its new owner.
+func (s *Store) Complete(key string, token Token, response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.cache.Peek(key)
+	if !ok || e.token != token {
+		return
+	}
+	if !Cacheable(response.StatusCode) {
+		s.cache.Remove(key)
+		return
+	}
+	s.cache.Add(key, &entry{token: token, fingerprint: e.fingerprint, response: response, expiresAt: s.now().Add(s.ttl())})
+}
+
+// Cacheable returns true if a response with the given status code can be replayed to a client retrying the same request
+func Cacheable(statusCode int) bool {
+	return statusCode < http.StatusInternalServerError && statusCode != http.StatusTooManyRequests
+}
@@ -0,0 +1,126 @@
+package idempotency
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	duration := func(d time.Duration) func() time.Duration { return func() time.Duration { return d } }
+	newStore := func(t *testing.T, maxKeys int, ttl, inProgressTTL time.Duration) *Store {
+		s, err := NewStore(maxKeys, duration(ttl), duration(inProgressTTL))
+		require.NoError(t, err)
+		return s
+	}
+	fingerprint := Fingerprint([]byte(`{"batch":[]}`))
+
+	t.Run("begin and complete", func(t *testing.T) {
+		s := newStore(t, 10, time.Hour, time.Hour)
+
+		_, status, token := s.Begin("key", fingerprint)
+		require.Equal(t, Started, status)
+
+		_, status, _ = s.Begin("key", fingerprint)
+		require.Equal(t, InProgress, status, "a second request should find the key in progress")
+
+		_, status, _ = s.Begin("other-key", fingerprint)
+		require.Equal(t, Started, status, "keys should be independent")
+
+		expected := Response{StatusCode: http.StatusOK, ContentType: "text/plain", Body: []byte("OK")}
+		s.Complete("key", token, expected)
+		resp, status, _ := s.Begin("key", fingerprint)
+		require.Equal(t, Completed, status)
+		require.Equal(t, expected, resp)
+	})
+
+	t.Run("retryable responses release the key", func(t *testing.T) {
+		s := newStore(t, 10, time.Hour, time.Hour)
+		for _, statusCode := range []int{http.StatusInternalServerError, http.StatusGatewayTimeout, http.StatusTooManyRequests} {
+			_, status, token := s.Begin("key", fingerprint)
+			require.Equal(t, Started, status)
+			s.Complete("key", token, Response{StatusCode: statusCode})
+		}
+
+		_, status, token := s.Begin("key", fingerprint)
+		require.Equal(t, Started, status)
+		s.Complete("key", token, Response{StatusCode: http.StatusBadRequest})
+		_, status, _ = s.Begin("key", fingerprint)
+		require.Equal(t, Completed, status, "client errors should be replayed")
+	})
+
+	t.Run("expired keys", func(t *testing.T) {
+		s := newStore(t, 10, time.Millisecond, time.Millisecond)
+		_, status, _ := s.Begin("key", fingerprint)
+		require.Equal(t, Started, status)
+		time.Sleep(5 * time.Millisecond)
+		_, status, token := s.Begin("key", fingerprint)
+		require.Equal(t, Started, status, "an expired in-progress reservation should be released")
+
+		s.Complete("key", token, Response{StatusCode: http.StatusOK})
+		time.Sleep(5 * time.Millisecond)
+		_, status, _ = s.Begin("key", fingerprint)
+		require.Equal(t, Started, status, "an expired response should be forgotten")
+	})
+
+	t.Run("completing an expired reservation", func(t *testing.T) {
+		s := newStore(t, 10, time.Hour, time.Millisecond)
+		_, status, staleToken := s.Begin("key", fingerprint)
+		require.Equal(t, Started, status)
+		time.Sleep(5 * time.Millisecond)
+		_, status, token := s.Begin("key", fingerprint)
+		require.Equal(t, Started, status)
+		require.NotEqual(t, staleToken, token)
+
+		s.Complete("key", staleToken, Response{StatusCode: http.StatusOK, Body: []byte("stale")})
+		_, status, _ = s.Begin("key", fingerprint)
+		require.Equal(t, InProgress, status, "the stale request should not complete the new reservation")
+
+		s.Complete("key", staleToken, Response{StatusCode: http.StatusInternalServerError})
+		_, status, _ = s.Begin("key", fingerprint)
+		require.Equal(t, InProgress, status, "the stale request should not release the new reservation")
+
+		s.Complete("key", token, Response{StatusCode: http.StatusOK, Body: []byte("OK")})
+		resp, status, _ := s.Begin("key", fingerprint)
+		require.Equal(t, Completed, status)
+		require.Equal(t, []byte("OK"), resp.Body)
+	})
+
+	t.Run("different body", func(t *testing.T) {
+		s := newStore(t, 10, time.Hour, time.Hour)
+		otherFingerprint := Fingerprint([]byte(`{"batch":[{}]}`))
+		require.NotEqual(t, fingerprint, otherFingerprint)
+
+		_, status, token := s.Begin("key", fingerprint)
+		require.Equal(t, Started, status)
+		_, status, _ = s.Begin("key", otherFingerprint)
+		require.Equal(t, Mismatch, status, "an in-progress key should not be usable with a different body")
+
+		s.Complete("key", token, Response{StatusCode: http.StatusOK})
+		_, status, _ = s.Begin("key", otherFingerprint)
+		require.Equal(t, Mismatch, status, "a completed key should not be usable with a different body")
+		_, status, _ = s.Begin("key", fingerprint)
+		require.Equal(t, Completed, status)
+	})
+
+	t.Run("max keys", func(t *testing.T) {
+		s := newStore(t, 2, time.Hour, time.Hour)
+		for _, key := range []string{"key-1", "key-2"} {
+			_, status, token := s.Begin(key, fingerprint)
+			require.Equal(t, Started, status)
+			s.Complete(key, token, Response{StatusCode: http.StatusOK})
+		}
+		_, status, _ := s.Begin("key-1", fingerprint)
+		require.Equal(t, Completed, status)
+
+		_, status, _ = s.Begin("key-3", fingerprint)
+		require.Equal(t, Started, status)
+		_, status, _ = s.Begin("key-2", fingerprint)
+		require.Equal(t, Started, status, "the least recently used key should have been evicted")
+
+		_, err := NewStore(0, duration(time.Hour), duration(time.Hour))
+		require.Error(t, err)
+	})
+}
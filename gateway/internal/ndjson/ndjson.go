@@ -0,0 +1,76 @@
+// Package ndjson reads newline-delimited JSON incrementally, one line at a time, without buffering the whole input.
+package ndjson
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// ErrLineTooLong is returned for lines exceeding the maximum line size.
+// Such lines are skipped, so that reading can continue with the next line.
+var ErrLineTooLong = errors.New("line exceeds max size")
+
+// Reader reads lines from newline-delimited JSON input
+type Reader struct {
+	r           *bufio.Reader
+	maxLineSize int
+	lineNo      int
+	buf         []byte
+}
+
+// NewReader returns a reader for lines of up to maxLineSize bytes
+func NewReader(r io.Reader, maxLineSize int) *Reader {
+	return &Reader{
+		r:           bufio.NewReaderSize(r, min(maxLineSize, 64*1024)),
+		maxLineSize: maxLineSize,
+	}
+}
+
+// Next returns the next non-blank line, trimmed of surrounding whitespace, along with its 1-based line number.
+// The returned line is only valid until the next call to Next.
+//
+// It returns [ErrLineTooLong] along with the line number for lines exceeding the maximum line size,
+// and [io.EOF] once there are no more lines.
+func (r *Reader) Next() ([]byte, int, error) {
+	for {
+		line, tooLong, err := r.readLine()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, r.lineNo, err
+		}
+		eof := err != nil
+		if eof && len(line) == 0 && !tooLong {
+			return nil, r.lineNo, io.EOF
+		}
+		r.lineNo++
+		if tooLong {
+			return nil, r.lineNo, ErrLineTooLong
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line, r.lineNo, nil
+		}
+		if eof {
+			return nil, r.lineNo, io.EOF
+		}
+	}
+}
+
+// readLine reads up to and including the next newline, discarding the contents of lines exceeding the maximum line size
+func (r *Reader) readLine() (line []byte, tooLong bool, err error) {
+	r.buf = r.buf[:0]
+	for {
+		var chunk []byte
+		chunk, err = r.r.ReadSlice('\n')
+		if !tooLong {
+			r.buf = append(r.buf, chunk...)
+			if len(bytes.TrimRight(r.buf, "\r\n")) > r.maxLineSize {
+				tooLong = true
+				r.buf = r.buf[:0]
+			}
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return r.buf, tooLong, err
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ndjson_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/gateway/internal/ndjson"
+)
+
+type line struct {
+	no   int
+	line string
+	err  error
+}
+
+func readAll(t *testing.T, r *ndjson.Reader) []line {
+	t.Helper()
+	var lines []line
+	for {
+		l, no, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return lines
+		}
+		lines = append(lines, line{no: no, line: string(l), err: err})
+		if err != nil && !errors.Is(err, ndjson.ErrLineTooLong) {
+			return lines
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	t.Run("lines", func(t *testing.T) {
+		input := "{\"a\":1}\n\n  {\"b\":2}  \r\n\t\n{\"c\":3}"
+		require.Equal(t, []line{
+			{no: 1, line: `{"a":1}`},
+			{no: 3, line: `{"b":2}`},
+			{no: 5, line: `{"c":3}`},
+		}, readAll(t, ndjson.NewReader(strings.NewReader(input), 100)))
+	})
+
+	t.Run("trailing newline", func(t *testing.T) {
+		require.Equal(t, []line{
+			{no: 1, line: `{"a":1}`},
+		}, readAll(t, ndjson.NewReader(strings.NewReader("{\"a\":1}\n"), 100)))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		require.Empty(t, readAll(t, ndjson.NewReader(strings.NewReader(""), 100)))
+		require.Empty(t, readAll(t, ndjson.NewReader(strings.NewReader("\n \n"), 100)))
+	})
+
+	t.Run("lines too long are skipped", func(t *testing.T) {
+		long := `{"a":"` + strings.Repeat("x", 200) + `"}`
+		input := `{"a":1}` + "\n" + long + "\n" + `{"b":2}` + "\n" + long
+		require.Equal(t, []line{
+			{no: 1, line: `{"a":1}`},
+			{no: 2, err: ndjson.ErrLineTooLong},
+			{no: 3, line: `{"b":2}`},
+			{no: 4, err: ndjson.ErrLineTooLong},
+		}, readAll(t, ndjson.NewReader(strings.NewReader(input), 100)))
+	})
+
+	t.Run("lines larger than the read buffer", func(t *testing.T) {
+		long := `{"a":"` + strings.Repeat("x", 100*1024) + `"}`
+		r := ndjson.NewReader(iotest.OneByteReader(strings.NewReader(long+"\n"+long)), 200*1024)
+		lines := readAll(t, r)
+		require.Len(t, lines, 2)
+		require.Equal(t, long, lines[0].line)
+		require.Equal(t, long, lines[1].line)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		readErr := errors.New("read error")
+		r := ndjson.NewReader(io.MultiReader(strings.NewReader("{\"a\":1}\n"), iotest.ErrReader(readErr)), 100)
+		require.Equal(t, []line{
+			{no: 1, line: `{"a":1}`},
+			{no: 1, err: readErr},
+		}, readAll(t, r))
+	})
+}
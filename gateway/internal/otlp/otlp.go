@@ -0,0 +1,370 @@
+// Package otlp converts OpenTelemetry OTLP/HTTP export requests (traces and logs) into rudder batch payloads.
+package otlp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"time"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+	kituuid "github.com/rudderlabs/rudder-go-kit/uuid"
+
+	"github.com/rudderlabs/rudder-server/utils/misc"
+)
+
+// Signal is the type of telemetry carried by an OTLP request
+type Signal string
+
+const (
+	SignalTraces Signal = "traces"
+	SignalLogs   Signal = "logs"
+
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+
+	// LogEventName is the default event name used for log records without an event name
+	LogEventName = "OTel Log Record"
+)
+
+var (
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+	ErrInvalidPayload         = errors.New("invalid otlp payload")
+)
+
+// Encoding returns the supported encoding for the given Content-Type header value
+func Encoding(contentType string) (string, error) {
+	if contentType == "" {
+		return ContentTypeProtobuf, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupportedContentType
+	}
+	switch mediaType {
+	case ContentTypeProtobuf, ContentTypeJSON:
+		return mediaType, nil
+	default:
+		return "", ErrUnsupportedContentType
+	}
+}
+
+// ToBatch decodes an OTLP export request of the given signal and encoding and converts every span or log record into a rudder track event.
+// It returns a rudder batch payload, i.e. {"batch": [...]}, along with the number of events it contains.
+func ToBatch(signal Signal, encoding string, payload []byte) ([]byte, int, error) {
+	var events []map[string]any
+	switch signal {
+	case SignalTraces:
+		var req collectortrace.ExportTraceServiceRequest
+		if err := unmarshal(encoding, payload, &req); err != nil {
+			return nil, 0, err
+		}
+		events = tracesToEvents(&req)
+	case SignalLogs:
+		var req collectorlogs.ExportLogsServiceRequest
+		if err := unmarshal(encoding, payload, &req); err != nil {
+			return nil, 0, err
+		}
+		events = logsToEvents(&req)
+	default:
+		return nil, 0, fmt.Errorf("unsupported signal %q", signal)
+	}
+	batch, err := jsonrs.Marshal(map[string]any{"batch": events})
+	if err != nil {
+		return nil, 0, fmt.Errorf("marshalling batch: %w", err)
+	}
+	return batch, len(events), nil
+}
+
+// Response returns the (empty) export response for the given signal, encoded according to the request's encoding
+func Response(signal Signal, encoding string) ([]byte, error) {
+	var resp proto.Message
+	switch signal {
+	case SignalTraces:
+		resp = &collectortrace.ExportTraceServiceResponse{}
+	case SignalLogs:
+		resp = &collectorlogs.ExportLogsServiceResponse{}
+	default:
+		return nil, fmt.Errorf("unsupported signal %q", signal)
+	}
+	if encoding == ContentTypeJSON {
+		return protojson.Marshal(resp)
+	}
+	return proto.Marshal(resp)
+}
+
+func unmarshal(encoding string, payload []byte, m proto.Message) error {
+	switch encoding {
+	case ContentTypeJSON:
+		// OTLP/JSON encodes trace and span ids as hex strings instead of the base64 protojson expects
+		payload, err := hexIDsToBase64(payload)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+		}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(payload, m); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+		}
+	default:
+		if err := proto.Unmarshal(payload, m); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+		}
+	}
+	return nil
+}
+
+// hexIDsToBase64 rewrites all traceId, spanId and parentSpanId values of an OTLP/JSON document from hex to base64.
+// Numbers are decoded as json.Number, so that integers beyond the precision of float64 are kept intact.
+func hexIDsToBase64(payload []byte) ([]byte, error) {
+	var doc any
+	decoder := jsonrs.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	var walk func(v any) error
+	walk = func(v any) error {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				switch k {
+				case "traceId", "spanId", "parentSpanId":
+					if s, ok := child.(string); ok && s != "" {
+						b, err := hex.DecodeString(s)
+						if err != nil {
+							return fmt.Errorf("invalid %s %q: %w", k, s, err)
+						}
+						v[k] = base64.StdEncoding.EncodeToString(b)
+					}
+				default:
+					if err := walk(child); err != nil {
+						return err
+					}
+				}
+			}
+		case []any:
+			for _, child := range v {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(doc); err != nil {
+		return nil, err
+	}
+	return jsonrs.Marshal(doc)
+}
+
+func tracesToEvents(req *collectortrace.ExportTraceServiceRequest) []map[string]any {
+	var events []map[string]any
+	for _, rs := range req.GetResourceSpans() {
+		resourceAttrs := resourceAttributes(rs.GetResource())
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				attrs := attributes(span.GetAttributes())
+				traceID, spanID := hex.EncodeToString(span.GetTraceId()), hex.EncodeToString(span.GetSpanId())
+				properties := map[string]any{
+					"traceId":    traceID,
+					"spanId":     spanID,
+					"kind":       span.GetKind().String(),
+					"startTime":  formatUnixNano(span.GetStartTimeUnixNano()),
+					"endTime":    formatUnixNano(span.GetEndTimeUnixNano()),
+					"durationMs": durationMs(span.GetStartTimeUnixNano(), span.GetEndTimeUnixNano()),
+					"attributes": attrs,
+				}
+				if parentSpanID := span.GetParentSpanId(); len(parentSpanID) > 0 {
+					properties["parentSpanId"] = hex.EncodeToString(parentSpanID)
+				}
+				if status := span.GetStatus(); status != nil {
+					properties["statusCode"] = status.GetCode().String()
+					if status.GetMessage() != "" {
+						properties["statusMessage"] = status.GetMessage()
+					}
+				}
+				event := newTrackEvent(span.GetName(), span.GetStartTimeUnixNano(), traceID, attrs, resourceAttrs, ss.GetScope(), properties)
+				// a deterministic messageId allows the same span to be deduplicated downstream if the exporter retries
+				if messageID, err := kituuid.GetMD5UUID(traceID + ":" + spanID); err == nil && traceID != "" {
+					event["messageId"] = messageID.String()
+				}
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+func logsToEvents(req *collectorlogs.ExportLogsServiceRequest) []map[string]any {
+	var events []map[string]any
+	for _, rl := range req.GetResourceLogs() {
+		resourceAttrs := resourceAttributes(rl.GetResource())
+		for _, sl := range rl.GetScopeLogs() {
+			for _, record := range sl.GetLogRecords() {
+				attrs := attributes(record.GetAttributes())
+				traceID := hex.EncodeToString(record.GetTraceId())
+				timestamp := record.GetTimeUnixNano()
+				if timestamp == 0 {
+					timestamp = record.GetObservedTimeUnixNano()
+				}
+				properties := map[string]any{
+					"severityNumber": int32(record.GetSeverityNumber()),
+					"attributes":     attrs,
+				}
+				if body := record.GetBody(); body != nil {
+					properties["body"] = anyValue(body)
+				}
+				if record.GetSeverityText() != "" {
+					properties["severityText"] = record.GetSeverityText()
+				}
+				if traceID != "" {
+					properties["traceId"] = traceID
+				}
+				if spanID := record.GetSpanId(); len(spanID) > 0 {
+					properties["spanId"] = hex.EncodeToString(spanID)
+				}
+				eventName := record.GetEventName()
+				if eventName == "" {
+					eventName = LogEventName
+				}
+				events = append(events, newTrackEvent(eventName, timestamp, traceID, attrs, resourceAttrs, sl.GetScope(), properties))
+			}
+		}
+	}
+	return events
+}
+
+// newTrackEvent builds a rudder track event out of an otel record.
+//
+// The userId is taken from the [enduser.id] attribute, whereas the anonymousId from the first non-empty of
+// [session.id], [service.instance.id], the trace id and [service.name], looking at record attributes first and resource attributes next.
+// Records without any of them get an anonymousId derived from their resource attributes, so that they remain identifiable.
+//
+// The sentAt of the event is left unset, since the record's timestamp isn't the time the export was sent
+// and would otherwise break the clock skew correction of the event's timestamp.
+func newTrackEvent(name string, timestampUnixNano uint64, traceID string, attrs, resourceAttrs map[string]any, scope *commonv1.InstrumentationScope, properties map[string]any) map[string]any {
+	lookup := func(key string) string {
+		if v, ok := attrs[key].(string); ok && v != "" {
+			return v
+		}
+		if v, ok := resourceAttrs[key].(string); ok && v != "" {
+			return v
+		}
+		return ""
+	}
+	anonymousID := lookup("session.id")
+	if anonymousID == "" {
+		anonymousID = lookup("service.instance.id")
+	}
+	if anonymousID == "" {
+		anonymousID = traceID
+	}
+	if anonymousID == "" {
+		anonymousID = lookup("service.name")
+	}
+	if anonymousID == "" {
+		anonymousID = resourceID(resourceAttrs)
+	}
+	eventContext := map[string]any{
+		"otel": map[string]any{
+			"resource": resourceAttrs,
+		},
+	}
+	if scope != nil {
+		eventContext["library"] = map[string]any{
+			"name":    scope.GetName(),
+			"version": scope.GetVersion(),
+		}
+	}
+	event := map[string]any{
+		"type":        "track",
+		"event":       name,
+		"anonymousId": anonymousID,
+		"properties":  properties,
+		"context":     eventContext,
+		"channel":     "server",
+	}
+	if userID := lookup("enduser.id"); userID != "" {
+		event["userId"] = userID
+	}
+	if timestampUnixNano > 0 {
+		event["originalTimestamp"] = formatUnixNano(timestampUnixNano)
+	}
+	return event
+}
+
+// resourceID returns a deterministic id for the resource with the given attributes
+func resourceID(resourceAttrs map[string]any) string {
+	// encoding/json marshals map keys in sorted order, so the same attributes always produce the same id
+	attrs, err := json.Marshal(resourceAttrs)
+	if err != nil {
+		attrs = []byte(fmt.Sprint(resourceAttrs))
+	}
+	id, err := kituuid.GetMD5UUID(string(attrs))
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+func resourceAttributes(resource *resourcev1.Resource) map[string]any {
+	return attributes(resource.GetAttributes())
+}
+
+func attributes(kvs []*commonv1.KeyValue) map[string]any {
+	res := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		res[kv.GetKey()] = anyValue(kv.GetValue())
+	}
+	return res
+}
+
+func anyValue(v *commonv1.AnyValue) any {
+	switch v.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		return v.GetStringValue()
+	case *commonv1.AnyValue_BoolValue:
+		return v.GetBoolValue()
+	case *commonv1.AnyValue_IntValue:
+		return v.GetIntValue()
+	case *commonv1.AnyValue_DoubleValue:
+		return v.GetDoubleValue()
+	case *commonv1.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.GetBytesValue())
+	case *commonv1.AnyValue_ArrayValue:
+		values := v.GetArrayValue().GetValues()
+		res := make([]any, len(values))
+		for i := range values {
+			res[i] = anyValue(values[i])
+		}
+		return res
+	case *commonv1.AnyValue_KvlistValue:
+		return attributes(v.GetKvlistValue().GetValues())
+	default:
+		return nil
+	}
+}
+
+func formatUnixNano(ts uint64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(0, int64(ts)).UTC().Format(misc.RFC3339Milli)
+}
+
+func durationMs(start, end uint64) float64 {
+	if end < start {
+		return 0
+	}
+	return float64(end-start) / float64(time.Millisecond)
+}
@@ -0,0 +1,201 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func stringAttr(key, value string) *commonv1.KeyValue {
+	return &commonv1.KeyValue{Key: key, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: value}}}
+}
+
+func TestEncoding(t *testing.T) {
+	for contentType, expected := range map[string]string{
+		"":                                ContentTypeProtobuf,
+		"application/x-protobuf":          ContentTypeProtobuf,
+		"application/json":                ContentTypeJSON,
+		"application/json; charset=utf-8": ContentTypeJSON,
+	} {
+		encoding, err := Encoding(contentType)
+		require.NoError(t, err, contentType)
+		require.Equal(t, expected, encoding, contentType)
+	}
+	_, err := Encoding("text/plain")
+	require.ErrorIs(t, err, ErrUnsupportedContentType)
+}
+
+func TestToBatch(t *testing.T) {
+	t.Run("traces protobuf", func(t *testing.T) {
+		req := &collectortrace.ExportTraceServiceRequest{
+			ResourceSpans: []*tracev1.ResourceSpans{{
+				Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{stringAttr("service.name", "checkout")}},
+				ScopeSpans: []*tracev1.ScopeSpans{{
+					Scope: &commonv1.InstrumentationScope{Name: "my-lib", Version: "1.0.0"},
+					Spans: []*tracev1.Span{{
+						TraceId:           []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+						SpanId:            []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+						Name:              "Order Completed",
+						Kind:              tracev1.Span_SPAN_KIND_SERVER,
+						StartTimeUnixNano: 1700000000000000000,
+						EndTimeUnixNano:   1700000000250000000,
+						Attributes:        []*commonv1.KeyValue{stringAttr("enduser.id", "user-1"), stringAttr("session.id", "session-1")},
+						Status:            &tracev1.Status{Code: tracev1.Status_STATUS_CODE_OK},
+					}},
+				}},
+			}},
+		}
+		payload, err := proto.Marshal(req)
+		require.NoError(t, err)
+
+		batch, count, err := ToBatch(SignalTraces, ContentTypeProtobuf, payload)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		event := gjson.GetBytes(batch, "batch.0")
+		require.Equal(t, "track", event.Get("type").String())
+		require.Equal(t, "Order Completed", event.Get("event").String())
+		require.Equal(t, "user-1", event.Get("userId").String())
+		require.Equal(t, "session-1", event.Get("anonymousId").String())
+		require.Equal(t, "2023-11-14T22:13:20.000Z", event.Get("originalTimestamp").String())
+		require.NotEmpty(t, event.Get("messageId").String())
+		require.Equal(t, "0102030405060708090a0b0c0d0e0f10", event.Get("properties.traceId").String())
+		require.Equal(t, "0102030405060708", event.Get("properties.spanId").String())
+		require.Equal(t, "SPAN_KIND_SERVER", event.Get("properties.kind").String())
+		require.Equal(t, "STATUS_CODE_OK", event.Get("properties.statusCode").String())
+		require.EqualValues(t, 250, event.Get("properties.durationMs").Float())
+		require.Equal(t, "checkout", event.Get("context.otel.resource.service\\.name").String())
+		require.Equal(t, "my-lib", event.Get("context.library.name").String())
+
+		again, _, err := ToBatch(SignalTraces, ContentTypeProtobuf, payload)
+		require.NoError(t, err)
+		require.Equal(t, event.Get("messageId").String(), gjson.GetBytes(again, "batch.0.messageId").String(), "messageId should be deterministic")
+	})
+
+	t.Run("traces json", func(t *testing.T) {
+		payload := []byte(`{
+			"resourceSpans": [{
+				"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+				"scopeSpans": [{
+					"spans": [{
+						"traceId": "5b8efff798038103d269b633813fc60c",
+						"spanId": "eee19b7ec3c1b174",
+						"parentSpanId": "eee19b7ec3c1b173",
+						"name": "Product Viewed",
+						"kind": 2,
+						"startTimeUnixNano": "1700000000000000000",
+						"endTimeUnixNano": "1700000001000000000",
+						"attributes": [
+							{"key": "product.id", "value": {"intValue": "42"}},
+							{"key": "order.id", "value": {"intValue": 9007199254740993}}
+						]
+					}]
+				}]
+			}]
+		}`)
+		batch, count, err := ToBatch(SignalTraces, ContentTypeJSON, payload)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		event := gjson.GetBytes(batch, "batch.0")
+		require.Equal(t, "Product Viewed", event.Get("event").String())
+		require.Equal(t, "5b8efff798038103d269b633813fc60c", event.Get("properties.traceId").String())
+		require.Equal(t, "eee19b7ec3c1b173", event.Get("properties.parentSpanId").String())
+		require.Equal(t, "5b8efff798038103d269b633813fc60c", event.Get("anonymousId").String(), "trace id should be used as anonymousId")
+		require.EqualValues(t, 42, event.Get("properties.attributes.product\\.id").Int())
+		require.Equal(t, "9007199254740993", event.Get("properties.attributes.order\\.id").Raw, "integers should keep their precision")
+		require.False(t, event.Get("userId").Exists())
+		require.False(t, event.Get("sentAt").Exists())
+	})
+
+	t.Run("logs protobuf", func(t *testing.T) {
+		req := &collectorlogs.ExportLogsServiceRequest{
+			ResourceLogs: []*logsv1.ResourceLogs{{
+				Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{stringAttr("service.instance.id", "instance-1")}},
+				ScopeLogs: []*logsv1.ScopeLogs{{
+					LogRecords: []*logsv1.LogRecord{
+						{
+							ObservedTimeUnixNano: 1700000000000000000,
+							SeverityNumber:       logsv1.SeverityNumber_SEVERITY_NUMBER_INFO,
+							SeverityText:         "INFO",
+							Body:                 &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: "hello"}},
+						},
+						{
+							EventName:  "Signed Up",
+							Attributes: []*commonv1.KeyValue{stringAttr("enduser.id", "user-2")},
+						},
+					},
+				}},
+			}},
+		}
+		payload, err := proto.Marshal(req)
+		require.NoError(t, err)
+
+		batch, count, err := ToBatch(SignalLogs, ContentTypeProtobuf, payload)
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+
+		first := gjson.GetBytes(batch, "batch.0")
+		require.Equal(t, LogEventName, first.Get("event").String())
+		require.Equal(t, "instance-1", first.Get("anonymousId").String())
+		require.Equal(t, "hello", first.Get("properties.body").String())
+		require.Equal(t, "INFO", first.Get("properties.severityText").String())
+		require.EqualValues(t, 9, first.Get("properties.severityNumber").Int())
+		require.Equal(t, "2023-11-14T22:13:20.000Z", first.Get("originalTimestamp").String())
+
+		second := gjson.GetBytes(batch, "batch.1")
+		require.Equal(t, "Signed Up", second.Get("event").String())
+		require.Equal(t, "user-2", second.Get("userId").String())
+	})
+
+	t.Run("logs without identifying attributes", func(t *testing.T) {
+		req := &collectorlogs.ExportLogsServiceRequest{
+			ResourceLogs: []*logsv1.ResourceLogs{{
+				Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{stringAttr("host.name", "host-1"), stringAttr("os.type", "linux")}},
+				ScopeLogs: []*logsv1.ScopeLogs{{
+					LogRecords: []*logsv1.LogRecord{{TimeUnixNano: 1700000000000000000}, {TimeUnixNano: 1700000001000000000}},
+				}},
+			}},
+		}
+		payload, err := proto.Marshal(req)
+		require.NoError(t, err)
+
+		batch, count, err := ToBatch(SignalLogs, ContentTypeProtobuf, payload)
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+
+		anonymousID := gjson.GetBytes(batch, "batch.0.anonymousId").String()
+		require.NotEmpty(t, anonymousID, "anonymousId should be derived from the resource")
+		require.Equal(t, anonymousID, gjson.GetBytes(batch, "batch.1.anonymousId").String(), "records of the same resource should share their anonymousId")
+		require.False(t, gjson.GetBytes(batch, "batch.0.sentAt").Exists())
+	})
+
+	t.Run("invalid payloads", func(t *testing.T) {
+		_, _, err := ToBatch(SignalTraces, ContentTypeProtobuf, []byte("not a protobuf"))
+		require.ErrorIs(t, err, ErrInvalidPayload)
+
+		_, _, err = ToBatch(SignalTraces, ContentTypeJSON, []byte(`{"resourceSpans": [{"scopeSpans": [{"spans": [{"traceId": "xyz"}]}]}]}`))
+		require.ErrorIs(t, err, ErrInvalidPayload)
+
+		_, _, err = ToBatch(SignalLogs, ContentTypeJSON, []byte(`{`))
+		require.ErrorIs(t, err, ErrInvalidPayload)
+	})
+}
+
+func TestResponse(t *testing.T) {
+	resp, err := Response(SignalTraces, ContentTypeJSON)
+	require.NoError(t, err)
+	require.JSONEq(t, `{}`, string(resp))
+
+	resp, err = Response(SignalLogs, ContentTypeProtobuf)
+	require.NoError(t, err)
+	require.Empty(t, resp)
+}
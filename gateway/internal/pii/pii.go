@@ -0,0 +1,214 @@
+// Package pii scrubs personally identifiable information from events before they are stored,
+// according to the rules attached to their source in backend config.
+//
+// Each rule applies an action to the value found at a dot-separated path of the event:
+//
+//	{
+//	  "piiRules": [
+//	    {"path": "context.traits.email", "action": "hash"},
+//	    {"path": "context.traits.phone", "action": "mask"},
+//	    {"path": "request_ip", "action": "remove"}
+//	  ]
+//	}
+//
+// Paths traversing arrays apply to every element of the array, e.g. "properties.products.sku".
+//
+// Hashing requires a secret salt, otherwise hashes of low-entropy values like emails or phone numbers could simply be reversed by brute force.
+// Rules which can't be applied, because they are invalid or because they hash values without a salt, result in an invalid scrubber:
+// events of its source must not be stored until the rules are fixed.
+package pii
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+	"github.com/rudderlabs/rudder-go-kit/stringify"
+)
+
+// Action is the action to apply to the value of a path
+type Action string
+
+const (
+	// ActionHash replaces the value with the hex encoded SHA-256 of the workspace salt followed by the value
+	ActionHash Action = "hash"
+	// ActionMask replaces every character of the value with an asterisk
+	ActionMask Action = "mask"
+	// ActionRemove removes the value along with its key
+	ActionRemove Action = "remove"
+)
+
+// Rule applies an action to the value at a path of the event
+type Rule struct {
+	Path   string `json:"path"`
+	Action Action `json:"action"`
+}
+
+type rule struct {
+	path   []string
+	action Action
+}
+
+// ErrMissingSalt is returned for rules hashing values when no salt is available
+var ErrMissingSalt = errors.New("hashing requires a salt, Gateway.pii.salt is not set")
+
+// Scrubber applies a set of rules to events
+type Scrubber struct {
+	rules []rule
+	salt  string
+	err   error
+}
+
+// New returns a scrubber applying the rules, hashing values with the given salt.
+// It returns an error if any of the rules has an empty path or an unknown action, or hashes values while the salt is empty.
+func New(rules []Rule, salt string) (*Scrubber, error) {
+	s := &Scrubber{rules: make([]rule, 0, len(rules)), salt: salt}
+	for i, r := range rules {
+		switch r.Action {
+		case ActionHash:
+			if salt == "" {
+				return nil, fmt.Errorf("rule %d: %w", i, ErrMissingSalt)
+			}
+		case ActionMask, ActionRemove:
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action %q", i, r.Action)
+		}
+		path := strings.Split(r.Path, ".")
+		for _, p := range path {
+			if p == "" {
+				return nil, fmt.Errorf("rule %d: invalid path %q", i, r.Path)
+			}
+		}
+		s.rules = append(s.rules, rule{path: path, action: r.Action})
+	}
+	return s, nil
+}
+
+// FromSourceConfig returns the scrubber for the PII rules in the source's config, or nil if the source doesn't have any
+func FromSourceConfig(sourceConfig json.RawMessage, salt string) (*Scrubber, error) {
+	if len(sourceConfig) == 0 {
+		return nil, nil
+	}
+	var c struct {
+		PIIRules []Rule `json:"piiRules"`
+	}
+	if err := jsonrs.Unmarshal(sourceConfig, &c); err != nil {
+		return nil, fmt.Errorf("unmarshalling source config: %w", err)
+	}
+	if len(c.PIIRules) == 0 {
+		return nil, nil
+	}
+	return New(c.PIIRules, salt)
+}
+
+// Invalid returns a scrubber for rules which couldn't be parsed, so that events of their source can be held back instead of being stored unscrubbed
+func Invalid(err error) *Scrubber {
+	return &Scrubber{err: err}
+}
+
+// Err returns the reason why the scrubber's rules are invalid, or nil if they can be applied.
+// Events must not be scrubbed, nor stored, by a scrubber returning an error.
+func (s *Scrubber) Err() error {
+	return s.err
+}
+
+// ScrubPayload applies the rules to every event of a request payload, which is either a batch of events or a single event,
+// returning the scrubbed payload. It fails if the payload isn't a JSON object or the rules are invalid.
+func (s *Scrubber) ScrubPayload(payload []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var body map[string]any
+	if err := jsonrs.Unmarshal(payload, &body); err != nil {
+		return nil, fmt.Errorf("unmarshalling payload: %w", err)
+	}
+	batch, ok := body["batch"].([]any)
+	if !ok {
+		s.Scrub(body)
+		return jsonrs.Marshal(body)
+	}
+	for _, e := range batch {
+		if event, ok := e.(map[string]any); ok {
+			s.Scrub(event)
+		}
+	}
+	return jsonrs.Marshal(body)
+}
+
+// ScrubEvent applies the rules to a JSON encoded event, returning the scrubbed event along with the number of values scrubbed.
+// It fails if the event isn't a JSON object or the rules are invalid.
+func (s *Scrubber) ScrubEvent(event []byte) ([]byte, int, error) {
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+	var e map[string]any
+	if err := jsonrs.Unmarshal(event, &e); err != nil {
+		return nil, 0, fmt.Errorf("unmarshalling event: %w", err)
+	}
+	scrubbed := s.Scrub(e)
+	if scrubbed == 0 {
+		return event, 0, nil
+	}
+	event, err := jsonrs.Marshal(e)
+	if err != nil {
+		return nil, 0, fmt.Errorf("marshalling event: %w", err)
+	}
+	return event, scrubbed, nil
+}
+
+// Scrub applies the rules to the event in place, returning the number of values scrubbed
+func (s *Scrubber) Scrub(event map[string]any) int {
+	var scrubbed int
+	for _, r := range s.rules {
+		scrubbed += s.apply(event, r.path, r.action)
+	}
+	return scrubbed
+}
+
+func (s *Scrubber) apply(value any, path []string, action Action) int {
+	switch v := value.(type) {
+	case map[string]any:
+		child, ok := v[path[0]]
+		if !ok {
+			return 0
+		}
+		if len(path) > 1 {
+			return s.apply(child, path[1:], action)
+		}
+		if action == ActionRemove {
+			delete(v, path[0])
+			return 1
+		}
+		if child == nil {
+			return 0
+		}
+		v[path[0]] = s.transform(child, action)
+		return 1
+	case []any:
+		var scrubbed int
+		for _, e := range v {
+			scrubbed += s.apply(e, path, action)
+		}
+		return scrubbed
+	default:
+		return 0
+	}
+}
+
+func (s *Scrubber) transform(value any, action Action) string {
+	str, ok := value.(string)
+	if !ok {
+		str = stringify.Any(value)
+	}
+	switch action {
+	case ActionHash:
+		h := sha256.Sum256([]byte(s.salt + str))
+		return hex.EncodeToString(h[:])
+	default:
+		return strings.Repeat("*", len([]rune(str)))
+	}
+}
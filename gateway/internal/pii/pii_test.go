@@ -0,0 +1,127 @@
+package pii_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+
+	"github.com/rudderlabs/rudder-server/gateway/internal/pii"
+)
+
+func TestFromSourceConfig(t *testing.T) {
+	t.Run("no rules", func(t *testing.T) {
+		for _, config := range []string{``, `{}`, `{"piiRules": null}`, `{"piiRules": []}`} {
+			s, err := pii.FromSourceConfig(json.RawMessage(config), "salt")
+			require.NoError(t, err, config)
+			require.Nil(t, s, config)
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, config := range []string{
+			`{"piiRules": [{"path": "context.ip", "action": "encrypt"}]}`,
+			`{"piiRules": [{"path": "", "action": "hash"}]}`,
+			`{"piiRules": [{"path": "context..ip", "action": "hash"}]}`,
+			`{"piiRules": "hash"}`,
+		} {
+			_, err := pii.FromSourceConfig(json.RawMessage(config), "salt")
+			require.Error(t, err, config)
+		}
+	})
+
+	t.Run("hashing without a salt", func(t *testing.T) {
+		_, err := pii.FromSourceConfig(json.RawMessage(`{"piiRules": [{"path": "context.traits.email", "action": "hash"}]}`), "")
+		require.ErrorIs(t, err, pii.ErrMissingSalt)
+
+		s, err := pii.FromSourceConfig(json.RawMessage(`{"piiRules": [{"path": "context.traits.email", "action": "mask"}]}`), "")
+		require.NoError(t, err, "masking doesn't need a salt")
+		require.NotNil(t, s)
+	})
+}
+
+func TestInvalid(t *testing.T) {
+	s := pii.Invalid(pii.ErrMissingSalt)
+	require.ErrorIs(t, s.Err(), pii.ErrMissingSalt)
+	_, _, err := s.ScrubEvent([]byte(`{"email":"user@example.com"}`))
+	require.ErrorIs(t, err, pii.ErrMissingSalt)
+	_, err = s.ScrubPayload([]byte(`{"email":"user@example.com"}`))
+	require.ErrorIs(t, err, pii.ErrMissingSalt)
+}
+
+func TestScrub(t *testing.T) {
+	hash := func(salt, value string) string {
+		h := sha256.Sum256([]byte(salt + value))
+		return hex.EncodeToString(h[:])
+	}
+	s, err := pii.New([]pii.Rule{
+		{Path: "context.traits.email", Action: pii.ActionHash},
+		{Path: "context.traits.phone", Action: pii.ActionMask},
+		{Path: "context.traits.age", Action: pii.ActionHash},
+		{Path: "context.ip", Action: pii.ActionRemove},
+		{Path: "properties.products.sku", Action: pii.ActionMask},
+		{Path: "properties.missing.value", Action: pii.ActionHash},
+	}, "salt")
+	require.NoError(t, err)
+
+	var event map[string]any
+	require.NoError(t, jsonrs.Unmarshal([]byte(`{
+		"type": "track",
+		"context": {
+			"ip": "1.2.3.4",
+			"traits": {"email": "user@example.com", "phone": "+301234", "age": 42, "name": "user"}
+		},
+		"properties": {"products": [{"sku": "abc"}, {"sku": "défg"}, {"price": 1}]}
+	}`), &event))
+
+	require.Equal(t, 6, s.Scrub(event))
+	actual, err := jsonrs.Marshal(event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"type": "track",
+		"context": {
+			"traits": {"email": "`+hash("salt", "user@example.com")+`", "phone": "*******", "age": "`+hash("salt", "42")+`", "name": "user"}
+		},
+		"properties": {"products": [{"sku": "***"}, {"sku": "****"}, {"price": 1}]}
+	}`, string(actual))
+
+	t.Run("hashes are salted", func(t *testing.T) {
+		other, err := pii.New([]pii.Rule{{Path: "email", Action: pii.ActionHash}}, "other")
+		require.NoError(t, err)
+		event := map[string]any{"email": "user@example.com"}
+		other.Scrub(event)
+		require.Equal(t, hash("other", "user@example.com"), event["email"])
+	})
+
+	t.Run("json events", func(t *testing.T) {
+		event, scrubbed, err := s.ScrubEvent([]byte(`{"type":"track","context":{"ip":"1.2.3.4"}}`))
+		require.NoError(t, err)
+		require.Equal(t, 1, scrubbed)
+		require.JSONEq(t, `{"type":"track","context":{}}`, string(event))
+
+		_, _, err = s.ScrubEvent([]byte(`[]`))
+		require.Error(t, err)
+	})
+
+	t.Run("json payloads", func(t *testing.T) {
+		payload, err := s.ScrubPayload([]byte(`{"writeKey":"key","batch":[{"context":{"ip":"1.2.3.4"}},{"context":{"ip":"5.6.7.8","traits":{"phone":"12"}}}]}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"writeKey":"key","batch":[{"context":{}},{"context":{"traits":{"phone":"**"}}}]}`, string(payload))
+
+		payload, err = s.ScrubPayload([]byte(`{"type":"identify","context":{"ip":"1.2.3.4"}}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"type":"identify","context":{}}`, string(payload))
+	})
+
+	t.Run("leaves null values and non object parents untouched", func(t *testing.T) {
+		event := map[string]any{"context": "string", "email": nil}
+		s, err := pii.New([]pii.Rule{{Path: "context.ip", Action: pii.ActionRemove}, {Path: "email", Action: pii.ActionHash}}, "salt")
+		require.NoError(t, err)
+		require.Zero(t, s.Scrub(event))
+		require.Equal(t, map[string]any{"context": "string", "email": nil}, event)
+	})
+}
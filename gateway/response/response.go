@@ -3,6 +3,7 @@ package response
 import (
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 const (
@@ -78,6 +79,24 @@ const (
 	NoDestinationIDInHeader = "failed to read destination id from header"
 	// ErrAuthenticatingWebhookRequest = "error occurred while authenticating the webhook request"
 	ErrAuthenticatingWebhookRequest = "error occurred while authenticating the webhook request"
+	// UnsupportedContentType - content type of the request is not supported by the endpoint
+	UnsupportedContentType = "unsupported content type"
+	// InvalidOTLPPayload - request body is not a valid OTLP export request
+	InvalidOTLPPayload = "invalid otlp payload"
+	// DecompressedRequestBodyTooLarge - decompressed request size or compression ratio exceeds max limit
+	DecompressedRequestBodyTooLarge = "decompressed request size exceeds max limit"
+	// InvalidIdempotencyKey - idempotency key in the request header is invalid
+	InvalidIdempotencyKey = "invalid idempotency key"
+	// IdempotencyKeyInProgress - a request with the same idempotency key is still being processed
+	IdempotencyKeyInProgress = "a request with the same idempotency key is in progress"
+	// IdempotencyKeyMismatch - the idempotency key was already used by a request with a different body
+	IdempotencyKeyMismatch = "idempotency key was already used with a different request body"
+	// InvalidEventSchema - events in the request do not conform to the schema of the source
+	InvalidEventSchema = "events do not conform to the source's event schema"
+	// BacklogQuotaExceeded - the workspace has too many jobs waiting to be delivered
+	BacklogQuotaExceeded = "workspace backlog quota exceeded"
+	// InvalidPIIRules - the PII rules of the source can't be applied, so its events can't be stored until they are fixed
+	InvalidPIIRules = "source has invalid PII rules"
 
 	transPixelResponse = "\x47\x49\x46\x38\x39\x61\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\x00\x00\x00\x21\xF9\x04" +
 		"\x01\x00\x00\x00\x00\x2C\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02\x44\x01\x00\x3B"
@@ -85,6 +104,9 @@ const (
 	InvalidStreamMessage = "missing required fields stream in message"
 )
 
+// detailsSeparator separates a response key from the details appended to it by [WithDetails]
+const detailsSeparator = ": "
+
 var statusMap = map[string]status{
 	Ok:                      {message: Ok, code: http.StatusOK},
 	RequestBodyNil:          {message: RequestBodyNil, code: http.StatusBadRequest},
@@ -105,6 +127,16 @@ var statusMap = map[string]status{
 	InvalidDestinationID:    {message: InvalidDestinationID, code: http.StatusBadRequest},
 	NoDestinationIDInHeader: {message: NoDestinationIDInHeader, code: http.StatusBadRequest},
 	InvalidStreamMessage:    {message: InvalidStreamMessage, code: http.StatusBadRequest},
+	UnsupportedContentType:  {message: UnsupportedContentType, code: http.StatusUnsupportedMediaType},
+	InvalidOTLPPayload:      {message: InvalidOTLPPayload, code: http.StatusBadRequest},
+
+	DecompressedRequestBodyTooLarge: {message: DecompressedRequestBodyTooLarge, code: http.StatusRequestEntityTooLarge},
+	InvalidIdempotencyKey:           {message: InvalidIdempotencyKey, code: http.StatusBadRequest},
+	IdempotencyKeyInProgress:        {message: IdempotencyKeyInProgress, code: http.StatusConflict},
+	IdempotencyKeyMismatch:          {message: IdempotencyKeyMismatch, code: http.StatusUnprocessableEntity},
+	InvalidEventSchema:              {message: InvalidEventSchema, code: http.StatusBadRequest},
+	BacklogQuotaExceeded:            {message: BacklogQuotaExceeded, code: http.StatusTooManyRequests},
+	InvalidPIIRules:                 {message: InvalidPIIRules, code: http.StatusServiceUnavailable},
 
 	// webhook specific status
 	InvalidWebhookSource:                           {message: InvalidWebhookSource, code: http.StatusNotFound},
@@ -146,9 +178,20 @@ func GetErrorStatusCode(key string) int {
 	if status, ok := statusMap[key]; ok {
 		return status.code
 	}
+	if key, _, ok := strings.Cut(key, detailsSeparator); ok {
+		if status, ok := statusMap[key]; ok {
+			return status.code
+		}
+	}
 	return http.StatusInternalServerError
 }
 
+// WithDetails appends details to a response key, e.g. the reasons for rejecting a request.
+// The status code of the resulting message is the one of the key.
+func WithDetails(key, details string) string {
+	return key + detailsSeparator + details
+}
+
 func MakeResponse(msg string) string {
 	return fmt.Sprintf(`{"msg": %q}`, msg)
 }
@@ -8,36 +8,66 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v8"
+
+	"github.com/rudderlabs/rudder-go-kit/cachettl"
 	"github.com/rudderlabs/rudder-go-kit/config"
 	"github.com/rudderlabs/rudder-go-kit/stats"
 	"github.com/rudderlabs/rudder-go-kit/throttling"
 )
 
 const (
-	throttlingAlgoTypeGCRA = "gcra"
+	throttlingAlgoTypeGCRA      = "gcra"
+	throttlingAlgoTypeRedisGCRA = "redis-gcra"
+
+	scopeWorkspace = "workspace"
+	scopeSource    = "source"
+	scopeWriteKey  = "writeKey"
+
+	unitEvents = "events"
+	unitBytes  = "bytes"
 )
 
 type Limiter interface {
-	// Allow returns true if the limit is not exceeded, false otherwise.
-	Allow(ctx context.Context, cost, rate, window int64, key string) (bool, func(context.Context) error, error)
+	// AllowAfter returns true if the limit is not exceeded, false otherwise.
+	// Additionally, it returns the time.Duration until the next allowed request.
+	AllowAfter(ctx context.Context, cost, rate, window int64, key string) (bool, time.Duration, func(context.Context) error, error)
+}
+
+// Request contains the traffic of a request which needs to be checked against the configured limits.
+// Limits are only checked for the identifiers which are set, e.g. an empty WorkspaceID skips the workspace limit.
+type Request struct {
+	WorkspaceID string
+	SourceID    string
+	WriteKey    string
+	Events      int64
+	Bytes       int64
 }
 
 type Throttler interface {
-	CheckLimitReached(context context.Context, workspaceId string, eventCount int64) (bool, error)
+	// CheckLimitReached returns true if any of the limits applying to the request has been reached,
+	// along with the duration after which the request can be retried
+	CheckLimitReached(ctx context.Context, req Request) (limited bool, retryAfter time.Duration, err error)
 }
 
 type Factory struct {
-	Stats        stats.Stats
-	limiter      Limiter
-	throttlers   map[string]*throttler // map key is the workspaceId
+	Stats   stats.Stats
+	limiter Limiter
+	// refunds is true if the cost charged by a limit can be refunded when a later limit is reached,
+	// i.e. when the limiter's state is local to this gateway, like the credit of the throttlers
+	refunds      bool
+	throttlers   *cachettl.Cache[string, *throttler] // cache key is scope:id:unit, e.g. source:<sourceID>:bytes
 	throttlersMu sync.Mutex
+	// idleTimeout is the duration after which the throttler of a key which isn't used anymore is evicted
+	idleTimeout config.ValueLoader[time.Duration]
 }
 
 // New constructs a new Throttler Factory
 func New(stats stats.Stats) (*Factory, error) {
 	f := Factory{
-		Stats:      stats,
-		throttlers: make(map[string]*throttler),
+		Stats:       stats,
+		throttlers:  cachettl.New[string, *throttler](),
+		idleTimeout: config.GetReloadableDurationVar(10, time.Minute, "Gateway.throttler.idleTimeout"),
 	}
 	if err := f.initThrottlerFactory(); err != nil {
 		return nil, err
@@ -45,25 +75,80 @@ func New(stats stats.Stats) (*Factory, error) {
 	return &f, nil
 }
 
-func (f *Factory) CheckLimitReached(context context.Context, workspaceId string, eventCount int64) (bool, error) {
-	t := f.get(workspaceId)
-	return t.checkLimitReached(context, workspaceId, eventCount)
+// CheckLimitReached checks the request against the write key, source and workspace limits, in this order,
+// stopping at the first limit that has been reached.
+//
+// Since the limiter cannot give tokens back, with the in-memory gcra algorithm the cost charged by the limits checked before
+// the one that has been reached is refunded as credit of their throttlers, so that a rejected request doesn't consume
+// the quota of its write key or source. With redis-gcra the quota is shared by all gateways while the credit would only
+// be known to this one, thus nothing is refunded and a rejected request consumes the quota of the earlier limits.
+func (f *Factory) CheckLimitReached(ctx context.Context, req Request) (bool, time.Duration, error) {
+	type check struct {
+		scope, id, unit string
+		cost            int64
+	}
+	var checks []check
+	if req.WriteKey != "" {
+		checks = append(checks, check{scopeWriteKey, req.WriteKey, unitEvents, req.Events}, check{scopeWriteKey, req.WriteKey, unitBytes, req.Bytes})
+	}
+	if req.SourceID != "" {
+		checks = append(checks, check{scopeSource, req.SourceID, unitEvents, req.Events}, check{scopeSource, req.SourceID, unitBytes, req.Bytes})
+	}
+	if req.WorkspaceID != "" {
+		checks = append(checks, check{scopeWorkspace, req.WorkspaceID, unitEvents, req.Events})
+	}
+	charged := make([]*throttler, 0, len(checks))
+	refund := func() {
+		if !f.refunds {
+			return
+		}
+		for i, t := range charged {
+			t.refund(checks[i].cost)
+		}
+	}
+	for _, c := range checks {
+		key := c.scope + ":" + c.id + ":" + c.unit
+		t := f.get(key, c.scope, c.id, c.unit)
+		limited, retryAfter, err := t.checkLimitReached(ctx, key, c.cost)
+		if err != nil {
+			refund()
+			return false, 0, err
+		}
+		if limited {
+			refund()
+			if f.Stats != nil {
+				f.Stats.NewTaggedStat("gateway.throttler.limited_requests", stats.CountType, stats.Tags{"scope": c.scope, "unit": c.unit}).Increment()
+			}
+			return true, retryAfter, nil
+		}
+		charged = append(charged, t)
+	}
+	return false, 0, nil
 }
 
-func (f *Factory) get(workspaceId string) *throttler {
+// get returns the throttler of a key, creating it if needed.
+// Throttlers are evicted once they haven't been used for the idle timeout, so that memory doesn't grow with the number of keys.
+func (f *Factory) get(key, scope, id, unit string) *throttler {
 	f.throttlersMu.Lock()
 	defer f.throttlersMu.Unlock()
-	if t, ok := f.throttlers[workspaceId]; ok {
+	if t := f.throttlers.Get(key); t != nil {
 		return t
 	}
 
 	var conf throttlingConfig
-	conf.readThrottlingConfig(workspaceId)
-	f.throttlers[workspaceId] = &throttler{
+	switch scope {
+	case scopeWorkspace:
+		conf.readThrottlingConfig(id)
+	default:
+		conf.readPerSecondThrottlingConfig(scope, id, unit)
+	}
+	t := &throttler{
 		limiter: f.limiter,
 		config:  conf,
 	}
-	return f.throttlers[workspaceId]
+	// the throttler needs to outlive its credit, which is valid for a window
+	f.throttlers.Put(key, t, max(f.idleTimeout.Load(), conf.window.Load()))
+	return t
 }
 
 func (f *Factory) initThrottlerFactory() error {
@@ -80,6 +165,17 @@ func (f *Factory) initThrottlerFactory() error {
 	switch throttlingAlgorithm {
 	case throttlingAlgoTypeGCRA:
 		l, err = throttling.New(append(opts, throttling.WithInMemoryGCRA(0))...)
+		f.refunds = true
+	case throttlingAlgoTypeRedisGCRA:
+		if !config.IsSet("Gateway.throttler.redis.addr") {
+			return fmt.Errorf("redis address is required with algorithm %s", throttlingAlgorithm)
+		}
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     config.GetString("Gateway.throttler.redis.addr", "localhost:6379"),
+			Username: config.GetString("Gateway.throttler.redis.username", ""),
+			Password: config.GetString("Gateway.throttler.redis.password", ""),
+		})
+		l, err = throttling.New(append(opts, throttling.WithRedisGCRA(redisClient, 0))...)
 	default:
 		return fmt.Errorf("invalid throttling algorithm: %s", throttlingAlgorithm)
 	}
@@ -95,39 +191,97 @@ func (f *Factory) initThrottlerFactory() error {
 type throttler struct {
 	limiter Limiter
 	config  throttlingConfig
+
+	creditMu        sync.Mutex
+	credit          int64     // cost refunded for requests which have been limited elsewhere, consumed before charging the limiter
+	creditExpiresAt time.Time // credit is only valid within the window it was refunded in
 }
 
-// checkLimitReached returns true if we're not allowed to process the number of event
-func (t *throttler) checkLimitReached(ctx context.Context, key string, count int64) (limited bool, retErr error) {
-	allowed, _, err := t.limiter.Allow(ctx, count, t.config.limit, getWindowInSecs(t.config.window), key)
+// checkLimitReached returns true if we're not allowed to process the given cost, along with the duration after which it can be retried.
+// A non-positive limit means that there is no limit.
+//
+// A cost above the limit could never be allowed, since the burst equals the limit, thus it is capped to the limit,
+// i.e. such a request is allowed once the whole burst is available, consuming all of it.
+func (t *throttler) checkLimitReached(ctx context.Context, key string, cost int64) (limited bool, retryAfter time.Duration, retErr error) {
+	limit := t.config.limit.Load()
+	if limit <= 0 || cost <= 0 {
+		return false, 0, nil
+	}
+	cost = min(cost, limit)
+	credit := t.useCredit(cost)
+	if credit == cost {
+		return false, 0, nil
+	}
+	allowed, retryAfter, _, err := t.limiter.AllowAfter(ctx, cost-credit, limit, getWindowInSecs(t.config.window.Load()), key)
 	if err != nil {
-		return false, fmt.Errorf("could not limit: %w", err)
+		t.refund(credit)
+		return false, 0, fmt.Errorf("could not limit: %w", err)
 	}
 	if !allowed {
-		return true, nil // no token to return when limited
+		t.refund(credit)
+		return true, retryAfter, nil // no token to return when limited
+	}
+	return false, 0, nil
+}
+
+// useCredit consumes up to cost of the available credit, returning the amount consumed
+func (t *throttler) useCredit(cost int64) int64 {
+	t.creditMu.Lock()
+	defer t.creditMu.Unlock()
+	if t.credit == 0 {
+		return 0
 	}
-	return false, nil
+	if time.Now().After(t.creditExpiresAt) {
+		t.credit = 0
+		return 0
+	}
+	used := min(cost, t.credit)
+	t.credit -= used
+	return used
+}
+
+// refund gives back cost that has been charged for a request which ended up being limited, up to the limit
+func (t *throttler) refund(cost int64) {
+	limit := t.config.limit.Load()
+	if limit <= 0 || cost <= 0 {
+		return
+	}
+	t.creditMu.Lock()
+	defer t.creditMu.Unlock()
+	now := time.Now()
+	if now.After(t.creditExpiresAt) {
+		t.credit = 0
+	}
+	t.credit = min(t.credit+min(cost, limit), limit)
+	t.creditExpiresAt = now.Add(t.config.window.Load())
 }
 
 type throttlingConfig struct {
-	limit  int64
-	window time.Duration
+	limit  config.ValueLoader[int64]
+	window config.ValueLoader[time.Duration]
 }
 
+// readThrottlingConfig reads the events limit of a workspace, falling back to the global one
 func (c *throttlingConfig) readThrottlingConfig(workspaceID string) {
-	rateLimitKey := fmt.Sprintf("RateLimit.%s.eventLimit", workspaceID)
-	if config.IsSet(rateLimitKey) {
-		c.limit = config.GetInt64(rateLimitKey, 1000)
-	} else {
-		c.limit = config.GetInt64("RateLimit.eventLimit", 1000)
-	}
+	c.limit = config.GetReloadableInt64Var(1000, 1,
+		fmt.Sprintf("RateLimit.%s.eventLimit", workspaceID),
+		"RateLimit.eventLimit",
+	)
+	c.window = config.GetReloadableDurationVar(60, time.Second,
+		fmt.Sprintf("RateLimit.%s.rateLimitWindow", workspaceID),
+		"RateLimit.rateLimitWindow",
+	)
+}
 
-	rateLimitWindowKey := fmt.Sprintf("RateLimit.%s.rateLimitWindow", workspaceID)
-	if config.IsSet(rateLimitWindowKey) {
-		c.window = config.GetDuration(rateLimitWindowKey, 60, time.Second)
-	} else {
-		c.window = config.GetDuration("RateLimit.rateLimitWindow", 60, time.Second)
-	}
+// readPerSecondThrottlingConfig reads the per second limit of a source or write key, falling back to the one of its scope,
+// e.g. RateLimit.source.<sourceID>.bytesPerSecond and then RateLimit.source.bytesPerSecond.
+// There is no limit by default.
+func (c *throttlingConfig) readPerSecondThrottlingConfig(scope, id, unit string) {
+	c.limit = config.GetReloadableInt64Var(0, 1,
+		fmt.Sprintf("RateLimit.%s.%s.%sPerSecond", scope, id, unit),
+		fmt.Sprintf("RateLimit.%s.%sPerSecond", scope, unit),
+	)
+	c.window = config.SingleValueLoader(time.Second)
 }
 
 func getWindowInSecs(d time.Duration) int64 {
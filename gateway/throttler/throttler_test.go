@@ -23,8 +23,8 @@ func TestGateway_Throttler(t *testing.T) {
 	config.Set("RateLimit.timeWindow", timeWindow)
 	defer config.Reset()
 	conf.readThrottlingConfig(workspaceId)
-	require.Equal(t, conf.limit, int64(eventLimit))
-	require.Equal(t, conf.window, time.Duration(timeWindow)*time.Minute)
+	require.Equal(t, conf.limit.Load(), int64(eventLimit))
+	require.Equal(t, conf.window.Load(), time.Duration(timeWindow)*time.Minute)
 
 	l, err := throttling.New(throttling.WithInMemoryGCRA(0))
 	require.NoError(t, err)
@@ -34,14 +34,14 @@ func TestGateway_Throttler(t *testing.T) {
 	}
 
 	for i := 0; i < eventLimit; i++ {
-		_, err := testThrottler.checkLimitReached(context.TODO(), workspaceId, 1)
+		_, _, err := testThrottler.checkLimitReached(context.TODO(), workspaceId, 1)
 		require.NoError(t, err)
 	}
 
 	startTime := time.Now()
 	var passed int
 	for i := 0; i < 2*eventLimit; i++ {
-		allowed, err := testThrottler.checkLimitReached(context.TODO(), workspaceId, 1)
+		allowed, _, err := testThrottler.checkLimitReached(context.TODO(), workspaceId, 1)
 		require.NoError(t, err)
 		if allowed {
 			passed++
@@ -69,14 +69,14 @@ func TestGateway_Factory(t *testing.T) {
 	require.NotNil(t, rateLimiter)
 
 	for i := 0; i < eventLimit; i++ {
-		_, err := rateLimiter.CheckLimitReached(context.TODO(), workspaceId, 1)
+		_, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{WorkspaceID: workspaceId, Events: 1})
 		require.NoError(t, err)
 	}
 
 	startTime := time.Now()
 	var passed int
 	for i := 0; i < 2*eventLimit; i++ {
-		allowed, err := rateLimiter.CheckLimitReached(context.TODO(), workspaceId, 1)
+		allowed, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{WorkspaceID: workspaceId, Events: 1})
 		require.NoError(t, err)
 		if allowed {
 			passed++
@@ -100,6 +100,161 @@ func Test_readThrottlingConfig(t *testing.T) {
 	config.Set("RateLimit.testID.timeWindow", timeWindow)
 	defer config.Reset()
 	conf.readThrottlingConfig(workspaceId)
-	require.Equal(t, conf.limit, int64(eventLimit))
-	require.Equal(t, conf.window, time.Duration(timeWindow)*time.Minute)
+	require.Equal(t, conf.limit.Load(), int64(eventLimit))
+	require.Equal(t, conf.window.Load(), time.Duration(timeWindow)*time.Minute)
+}
+
+func TestGateway_FactorySourceAndWriteKeyLimits(t *testing.T) {
+	t.Run("no limits by default", func(t *testing.T) {
+		defer config.Reset()
+		rateLimiter, err := New(stats.NOP)
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			limited, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source", WriteKey: "writeKey", Events: 1000, Bytes: 1 << 20})
+			require.NoError(t, err)
+			require.False(t, limited)
+		}
+	})
+
+	t.Run("events per second of a source", func(t *testing.T) {
+		defer config.Reset()
+		config.Set("RateLimit.source.source-1.eventsPerSecond", 10)
+		rateLimiter, err := New(stats.NOP)
+		require.NoError(t, err)
+
+		limited, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-1", WriteKey: "writeKey-1", Events: 10, Bytes: 100})
+		require.NoError(t, err)
+		require.False(t, limited)
+
+		limited, retryAfter, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-1", WriteKey: "writeKey-1", Events: 5, Bytes: 100})
+		require.NoError(t, err)
+		require.True(t, limited)
+		require.Greater(t, retryAfter, time.Duration(0))
+		require.LessOrEqual(t, retryAfter, time.Second)
+
+		limited, _, err = rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-2", WriteKey: "writeKey-2", Events: 10, Bytes: 100})
+		require.NoError(t, err)
+		require.False(t, limited, "other sources should not be affected")
+	})
+
+	t.Run("bytes per second of a write key with a default for all write keys", func(t *testing.T) {
+		defer config.Reset()
+		config.Set("RateLimit.writeKey.bytesPerSecond", 1000)
+		config.Set("RateLimit.writeKey.writeKey-2.bytesPerSecond", 100)
+		rateLimiter, err := New(stats.NOP)
+		require.NoError(t, err)
+
+		for range 2 {
+			limited, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{WriteKey: "writeKey-1", Events: 1, Bytes: 500})
+			require.NoError(t, err)
+			require.False(t, limited)
+		}
+		limited, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{WriteKey: "writeKey-2", Events: 1, Bytes: 100})
+		require.NoError(t, err)
+		require.False(t, limited)
+		limited, _, err = rateLimiter.CheckLimitReached(context.TODO(), Request{WriteKey: "writeKey-2", Events: 1, Bytes: 100})
+		require.NoError(t, err)
+		require.True(t, limited, "write key specific limit should take precedence")
+	})
+
+	t.Run("limits are reloaded", func(t *testing.T) {
+		defer config.Reset()
+		config.Set("RateLimit.source.source-1.eventsPerSecond", 1)
+		rateLimiter, err := New(stats.NOP)
+		require.NoError(t, err)
+
+		var limited bool
+		for range 5 {
+			limited, _, err = rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-1", Events: 5})
+			require.NoError(t, err)
+		}
+		require.True(t, limited)
+
+		config.Set("RateLimit.source.source-1.eventsPerSecond", 0)
+		limited, _, err = rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-1", Events: 5})
+		require.NoError(t, err)
+		require.False(t, limited, "limit should have been removed")
+	})
+
+	t.Run("requests limited by a later limit don't consume the earlier ones", func(t *testing.T) {
+		defer config.Reset()
+		config.Set("RateLimit.writeKey.eventsPerSecond", 10)
+		config.Set("RateLimit.source.source-1.eventsPerSecond", 1)
+		rateLimiter, err := New(stats.NOP)
+		require.NoError(t, err)
+
+		var limitedRequests int
+		for range 20 {
+			limited, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-1", WriteKey: "writeKey-1", Events: 1})
+			require.NoError(t, err)
+			if limited {
+				limitedRequests++
+			}
+		}
+		require.GreaterOrEqual(t, limitedRequests, 15, "source limit should have been reached")
+
+		limited, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-2", WriteKey: "writeKey-1", Events: 5})
+		require.NoError(t, err)
+		require.False(t, limited, "requests limited by the source should have been refunded to the write key")
+	})
+
+	t.Run("nothing is refunded when the limiter is shared", func(t *testing.T) {
+		defer config.Reset()
+		config.Set("RateLimit.writeKey.eventsPerSecond", 10)
+		config.Set("RateLimit.source.source-1.eventsPerSecond", 1)
+		rateLimiter, err := New(stats.NOP)
+		require.NoError(t, err)
+		rateLimiter.refunds = false // as with redis-gcra
+
+		for range 20 {
+			_, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-1", WriteKey: "writeKey-1", Events: 1})
+			require.NoError(t, err)
+		}
+		limited, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-2", WriteKey: "writeKey-1", Events: 5})
+		require.NoError(t, err)
+		require.True(t, limited, "requests limited by the source should have consumed the write key limit")
+	})
+
+	t.Run("idle throttlers are evicted", func(t *testing.T) {
+		defer config.Reset()
+		config.Set("RateLimit.writeKey.eventsPerSecond", 10)
+		config.Set("Gateway.throttler.idleTimeout", "10ms")
+		rateLimiter, err := New(stats.NOP)
+		require.NoError(t, err)
+
+		used := rateLimiter.get("writeKey:writeKey-1:events", scopeWriteKey, "writeKey-1", unitEvents)
+		require.Same(t, used, rateLimiter.get("writeKey:writeKey-1:events", scopeWriteKey, "writeKey-1", unitEvents))
+		time.Sleep(time.Second + 10*time.Millisecond) // throttlers outlive the window of their credit
+		require.NotSame(t, used, rateLimiter.get("writeKey:writeKey-1:events", scopeWriteKey, "writeKey-1", unitEvents), "an idle throttler should have been evicted")
+	})
+
+	t.Run("refunds are capped to the limit", func(t *testing.T) {
+		th := throttler{config: throttlingConfig{limit: config.SingleValueLoader[int64](10), window: config.SingleValueLoader(time.Second)}}
+		th.refund(8)
+		th.refund(8)
+		require.EqualValues(t, 10, th.useCredit(100))
+		require.Zero(t, th.useCredit(1))
+	})
+
+	t.Run("requests above the limit consume the whole burst", func(t *testing.T) {
+		defer config.Reset()
+		config.Set("RateLimit.source.bytesPerSecond", 100)
+		rateLimiter, err := New(stats.NOP)
+		require.NoError(t, err)
+
+		limited, _, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-1", Events: 1, Bytes: 1000})
+		require.NoError(t, err)
+		require.False(t, limited, "a request larger than the limit should be allowed once the burst is available")
+		limited, retryAfter, err := rateLimiter.CheckLimitReached(context.TODO(), Request{SourceID: "source-1", Events: 1, Bytes: 1000})
+		require.NoError(t, err)
+		require.True(t, limited, "the burst should have been consumed")
+		require.LessOrEqual(t, retryAfter, time.Second)
+	})
+
+	t.Run("redis-gcra requires a redis address", func(t *testing.T) {
+		defer config.Reset()
+		config.Set("Gateway.throttler.algorithm", "redis-gcra")
+		_, err := New(stats.NOP)
+		require.Error(t, err)
+	})
 }
@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"net/http"
+	"time"
 
 	gwtypes "github.com/rudderlabs/rudder-server/gateway/types"
 
@@ -28,6 +29,7 @@ type webRequestT struct {
 	ipAddr         string
 	userIDHeader   string
 	errors         []string
+	retryAfter     *retryAfter // optional, receives the time after which a rate limited request can be retried
 }
 
 type batchWebRequestT struct {
@@ -66,4 +68,6 @@ type jobFromReq struct {
 	numEvents int
 	botEvents int
 	version   string
+
+	retryAfter time.Duration // time after which a rate limited request can be retried
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/rudderlabs/rudder-go-kit/requesttojson"
 	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
 	"github.com/rudderlabs/rudder-server/gateway/response"
+	"github.com/rudderlabs/rudder-server/middleware"
 	"github.com/rudderlabs/rudder-server/services/transformer"
 	"github.com/rudderlabs/rudder-server/utils/httputil"
 	"github.com/rudderlabs/rudder-server/utils/misc"
@@ -146,6 +147,9 @@ func prepareTransformerEventRequestV1(req *http.Request, sourceType string, sour
 	}()
 
 	body, err := io.ReadAll(req.Body)
+	if middleware.IsDecompressionLimitError(err) {
+		return nil, errors.New(response.DecompressedRequestBodyTooLarge)
+	}
 	if err != nil {
 		return nil, errors.New(strings.ToLower(response.RequestBodyReadFailed))
 	}
@@ -164,6 +168,9 @@ func prepareTransformerEventRequestV1(req *http.Request, sourceType string, sour
 
 func prepareTransformerEventRequestV2(req *http.Request) ([]byte, error) {
 	requestJson, err := requesttojson.RequestToJSON(req, "{}")
+	if middleware.IsDecompressionLimitError(err) {
+		return nil, errors.New(response.DecompressedRequestBodyTooLarge)
+	}
 	if err != nil {
 		return nil, err
 	}
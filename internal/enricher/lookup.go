@@ -0,0 +1,446 @@
+package enricher
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/reader"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/filemanager"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+)
+
+const (
+	lookupFormatCSV     = "csv"
+	lookupFormatParquet = "parquet"
+
+	// lookupKeyFunctionDomain extracts the domain of a url key, e.g. example.com for https://www.example.com/pricing
+	lookupKeyFunctionDomain = "domain"
+)
+
+// downloader downloads objects from the object storage holding the lookup tables
+type downloader interface {
+	Download(context.Context, io.WriterAt, string, ...filemanager.DownloadOption) error
+}
+
+// lookupTableConfig is the config of a lookup table, read from LookupEnrichment.<name>
+type lookupTableConfig struct {
+	name            string
+	objectKey       string   // key of the file in the object storage
+	format          string   // csv or parquet, defaults to the extension of the file
+	key             string   // path of the key in the event, e.g. context.page.url
+	keyFunction     string   // applied to the key of the event before looking it up, e.g. domain
+	keyColumn       string   // column of the table matched against the key
+	columns         []string // columns written to the context, defaults to all of them except for the key column
+	contextKey      string   // key of the context the matched columns are written under, defaults to the name of the table
+	workspaceIDs    []string // workspaces whose sources can be enriched with the table, all of them if empty
+	refreshInterval time.Duration
+}
+
+// lookupTable is a lookup table, along with its latest loaded rows
+type lookupTable struct {
+	config lookupTableConfig
+	rows   atomic.Pointer[map[string]map[string]any] // key => columns
+}
+
+type lookupEnricher struct {
+	tables      []*lookupTable
+	downloader  downloader
+	tmpDir      string
+	loadTimeout time.Duration // of every download of a table, so that an unresponsive storage cannot block startup
+	logger      logger.Logger
+	stats       stats.Stats
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+/*
+NewLookupEnricher returns an enricher joining events with static lookup tables, which are loaded from object storage
+and refreshed periodically. The columns of the row matching the key of an event are written into its context, e.g.
+
+	LookupEnrichment:
+	  tables: [accountTiers]
+	  accountTiers:
+	    objectKey: lookup/account_tiers.csv
+	    key: userId
+	    keyColumn: user_id
+	    columns: [tier, plan]
+	    contextKey: account
+
+writes {"tier": "enterprise", "plan": "annual"} into context.account of the events of the user whose user_id is in the table.
+Keys which are urls can be matched by their domain with keyFunction: domain.
+
+Only the events of sources with lookup enrichment enabled are enriched, with the tables they are configured with,
+among the ones of their workspace, i.e. the tables whose workspaceIDs include it or which have none.
+Tables are loaded concurrently at startup, each within LookupEnrichment.loadTimeout.
+Tables which cannot be loaded initially don't enrich events until they are loaded by a refresh.
+*/
+func NewLookupEnricher(conf *config.Config, log logger.Logger, statClient stats.Stats) (PipelineEnricher, error) {
+	log.Infon("Setting up new event lookup enricher")
+
+	manager, err := filemanager.New(&filemanager.Settings{
+		Provider: conf.GetString("LookupEnrichment.storage.provider", "S3"),
+		Config: map[string]interface{}{
+			"bucketName":       conf.GetString("LookupEnrichment.storage.bucket", ""),
+			"region":           conf.GetString("LookupEnrichment.storage.region", "us-east-1"),
+			"endpoint":         conf.GetString("LookupEnrichment.storage.endpoint", ""),
+			"accessKeyID":      conf.GetString("LookupEnrichment.storage.accessKey", ""),
+			"secretAccessKey":  conf.GetString("LookupEnrichment.storage.secretAccessKey", ""),
+			"s3ForcePathStyle": conf.GetBool("LookupEnrichment.storage.s3ForcePathStyle", false),
+			"disableSSL":       conf.GetBool("LookupEnrichment.storage.disableSSL", false),
+		},
+		Conf: conf,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating a new file manager client: %w", err)
+	}
+	return newLookupEnricher(conf, log, statClient, manager)
+}
+
+func newLookupEnricher(conf *config.Config, log logger.Logger, statClient stats.Stats, d downloader) (*lookupEnricher, error) {
+	configs, err := lookupTableConfigs(conf)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &lookupEnricher{
+		downloader:  d,
+		tmpDir:      path.Join(conf.GetString("RUDDER_TMPDIR", "."), "lookup"),
+		loadTimeout: conf.GetDurationVar(1, time.Minute, "LookupEnrichment.loadTimeout"),
+		logger:      log.Child("lookup"),
+		stats:       statClient,
+		cancel:      cancel,
+	}
+	if err := os.MkdirAll(e.tmpDir, os.ModePerm); err != nil {
+		cancel()
+		return nil, fmt.Errorf("creating directory for downloading lookup tables: %w", err)
+	}
+	var initialLoads sync.WaitGroup
+	for _, c := range configs {
+		table := &lookupTable{config: c}
+		table.rows.Store(&map[string]map[string]any{})
+		e.tables = append(e.tables, table)
+		initialLoads.Add(1)
+		go func() {
+			defer initialLoads.Done()
+			if err := e.load(ctx, table); err != nil {
+				e.logger.Errorn("loading lookup table, events won't be enriched with it until it is refreshed",
+					logger.NewStringField("table", c.name),
+					obskit.Error(err),
+				)
+			}
+		}()
+	}
+	initialLoads.Wait()
+	for _, table := range e.tables {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			e.refresh(ctx, table)
+		}()
+	}
+	return e, nil
+}
+
+func lookupTableConfigs(conf *config.Config) ([]lookupTableConfig, error) {
+	var configs []lookupTableConfig
+	for _, name := range conf.GetStringSlice("LookupEnrichment.tables", nil) {
+		prefix := "LookupEnrichment." + name + "."
+		c := lookupTableConfig{
+			name:            name,
+			objectKey:       conf.GetString(prefix+"objectKey", ""),
+			format:          conf.GetString(prefix+"format", ""),
+			key:             conf.GetString(prefix+"key", ""),
+			keyFunction:     conf.GetString(prefix+"keyFunction", ""),
+			keyColumn:       conf.GetString(prefix+"keyColumn", ""),
+			columns:         conf.GetStringSlice(prefix+"columns", nil),
+			contextKey:      conf.GetString(prefix+"contextKey", name),
+			workspaceIDs:    conf.GetStringSlice(prefix+"workspaceIDs", nil),
+			refreshInterval: conf.GetDurationVar(1, time.Hour, prefix+"refreshInterval", "LookupEnrichment.refreshInterval"),
+		}
+		if c.format == "" {
+			c.format = strings.TrimPrefix(path.Ext(c.objectKey), ".")
+		}
+		switch {
+		case c.objectKey == "":
+			return nil, fmt.Errorf("lookup table %q: objectKey is required", name)
+		case c.key == "":
+			return nil, fmt.Errorf("lookup table %q: key is required", name)
+		case c.keyColumn == "":
+			return nil, fmt.Errorf("lookup table %q: keyColumn is required", name)
+		case c.format != lookupFormatCSV && c.format != lookupFormatParquet:
+			return nil, fmt.Errorf("lookup table %q: unsupported format %q", name, c.format)
+		case c.keyFunction != "" && c.keyFunction != lookupKeyFunctionDomain:
+			return nil, fmt.Errorf("lookup table %q: unsupported key function %q", name, c.keyFunction)
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
+
+// refresh reloads the table periodically, keeping its previous rows if reloading fails
+func (e *lookupEnricher) refresh(ctx context.Context, table *lookupTable) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(table.config.refreshInterval):
+		}
+		if err := e.load(ctx, table); err != nil && ctx.Err() == nil {
+			e.logger.Errorn("refreshing lookup table",
+				logger.NewStringField("table", table.config.name),
+				obskit.Error(err),
+			)
+		}
+	}
+}
+
+// load downloads the table and replaces its rows, giving up on downloads exceeding the load timeout
+func (e *lookupEnricher) load(ctx context.Context, table *lookupTable) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, e.loadTimeout)
+	defer cancel()
+	defer func() {
+		e.stats.NewTaggedStat("proc_lookup_enricher_loads", stats.CountType, stats.Tags{
+			"table":   table.config.name,
+			"success": fmt.Sprint(err == nil),
+		}).Increment()
+	}()
+
+	f, err := os.CreateTemp(e.tmpDir, "lookup-*."+table.config.format)
+	if err != nil {
+		return fmt.Errorf("creating a temporary file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	if err := e.downloader.Download(ctx, f, table.config.objectKey); err != nil {
+		return fmt.Errorf("downloading file with key %s: %w", table.config.objectKey, err)
+	}
+
+	var records []map[string]any
+	switch table.config.format {
+	case lookupFormatCSV:
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("seeking file: %w", err)
+		}
+		records, err = readCSV(f)
+	case lookupFormatParquet:
+		records, err = readParquet(f.Name())
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s file: %w", table.config.format, err)
+	}
+
+	rows := make(map[string]map[string]any, len(records))
+	for _, record := range records {
+		key, ok := record[table.config.keyColumn]
+		if !ok {
+			return fmt.Errorf("key column %q not found", table.config.keyColumn)
+		}
+		if key == nil {
+			continue
+		}
+		row := make(map[string]any)
+		if len(table.config.columns) == 0 {
+			maps.Copy(row, record)
+			delete(row, table.config.keyColumn)
+		} else {
+			for _, column := range table.config.columns {
+				if value, ok := record[column]; ok {
+					row[column] = value
+				}
+			}
+		}
+		rows[fmt.Sprint(key)] = row
+	}
+	table.rows.Store(&rows)
+	e.stats.NewTaggedStat("proc_lookup_enricher_rows", stats.GaugeType, stats.Tags{"table": table.config.name}).Gauge(len(rows))
+	e.logger.Infon("loaded lookup table",
+		logger.NewStringField("table", table.config.name),
+		logger.NewIntField("rows", int64(len(rows))),
+	)
+	return nil
+}
+
+// readCSV reads the records of a csv file, whose first line is the header
+func readCSV(r io.Reader) ([]map[string]any, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	var records []map[string]any
+	for {
+		line, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading line: %w", err)
+		}
+		record := make(map[string]any, len(header))
+		for i, column := range header {
+			record[column] = line[i]
+		}
+		records = append(records, record)
+	}
+}
+
+// readParquet reads the records of a parquet file with flat columns
+func readParquet(filePath string) ([]map[string]any, error) {
+	f, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	r, err := reader.NewParquetColumnReader(f, 1)
+	if err != nil {
+		return nil, fmt.Errorf("creating parquet reader: %w", err)
+	}
+	defer r.ReadStop()
+
+	numRows := r.GetNumRows()
+	records := make([]map[string]any, numRows)
+	for i := range records {
+		records[i] = make(map[string]any)
+	}
+	if numRows == 0 {
+		return records, nil
+	}
+	for i, inPath := range r.SchemaHandler.ValueColumns {
+		exPath := common.StrToPath(r.SchemaHandler.InPathToExPath[inPath])
+		column := exPath[len(exPath)-1]
+		values, _, _, err := r.ReadColumnByIndex(int64(i), numRows)
+		if err != nil {
+			return nil, fmt.Errorf("reading column %q: %w", column, err)
+		}
+		if int64(len(values)) != numRows {
+			return nil, fmt.Errorf("column %q is not flat", column)
+		}
+		for row, value := range values {
+			records[row][column] = value
+		}
+	}
+	return records, nil
+}
+
+// Enrich function runs on a request of GatewayBatchRequest which contains
+// multiple singular events from a source. The enrich function writes the
+// columns of the rows of the lookup tables matching the event into its context.
+func (e *lookupEnricher) Enrich(source *backendconfig.SourceT, request *types.GatewayBatchRequest, _ *types.EventParams) error {
+	if !source.LookupEnrichment.Enabled {
+		return nil
+	}
+	var enrichErrs []error
+	for _, table := range e.tables {
+		if !table.enabledFor(source) {
+			continue
+		}
+		rows := *table.rows.Load()
+		var matched int
+		for _, event := range request.Batch {
+			key := table.lookupKey(event)
+			if key == "" {
+				continue
+			}
+			row, ok := rows[key]
+			if !ok {
+				continue
+			}
+
+			// if the context section is missing on the event
+			// set it with default as map[string]interface{}
+			if _, ok := event["context"]; !ok {
+				event["context"] = map[string]interface{}{}
+			}
+
+			// if the context is other than map[string]interface{}, add error and continue
+			context, ok := event["context"].(map[string]interface{})
+			if !ok {
+				enrichErrs = append(enrichErrs, fmt.Errorf("event on source: %s doesn't have a valid context section", source.ID))
+				continue
+			}
+
+			// values sent with the event take precedence
+			if _, ok := context[table.config.contextKey]; ok {
+				continue
+			}
+			context[table.config.contextKey] = maps.Clone(row)
+			matched++
+		}
+		e.stats.NewTaggedStat("proc_lookup_enricher_matched_events", stats.CountType, stats.Tags{
+			"table":       table.config.name,
+			"sourceId":    source.ID,
+			"workspaceId": source.WorkspaceID,
+		}).Count(matched)
+	}
+	return errors.Join(enrichErrs...)
+}
+
+// enabledFor returns whether the events of the source can be enriched with the table
+func (t *lookupTable) enabledFor(source *backendconfig.SourceT) bool {
+	if len(t.config.workspaceIDs) > 0 && !slices.Contains(t.config.workspaceIDs, source.WorkspaceID) {
+		return false
+	}
+	return len(source.LookupEnrichment.Tables) == 0 || slices.Contains(source.LookupEnrichment.Tables, t.config.name)
+}
+
+// lookupKey returns the key of the event to look up in the table, or an empty string if it has none
+func (t *lookupTable) lookupKey(event types.SingularEventT) string {
+	value := misc.MapLookup(event, strings.Split(t.config.key, ".")...)
+	var key string
+	switch value := value.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return ""
+	case string:
+		key = value
+	default:
+		key = fmt.Sprint(value)
+	}
+	if t.config.keyFunction == lookupKeyFunctionDomain {
+		key = domain(key)
+	}
+	return key
+}
+
+// domain returns the domain of a url, without its www subdomain, or an empty string if it isn't a url
+func domain(rawURL string) string {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func (e *lookupEnricher) Close() error {
+	e.logger.Infon("closing the lookup enricher")
+	e.cancel()
+	e.wg.Wait()
+	return nil
+}
@@ -0,0 +1,251 @@
+package enricher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/filemanager"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+type fakeDownloader struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (d *fakeDownloader) set(key string, data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.objects[key] = data
+}
+
+func (d *fakeDownloader) Download(_ context.Context, w io.WriterAt, key string, _ ...filemanager.DownloadOption) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.objects[key]
+	if !ok {
+		return fmt.Errorf("object %q not found", key)
+	}
+	_, err := w.WriteAt(data, 0)
+	return err
+}
+
+// blockingDownloader blocks downloads until they are cancelled, like an unresponsive storage
+type blockingDownloader struct{}
+
+func (blockingDownloader) Download(ctx context.Context, _ io.WriterAt, _ string, _ ...filemanager.DownloadOption) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type campaign struct {
+	Domain   string  `parquet:"name=domain, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Campaign *string `parquet:"name=campaign, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Budget   int64   `parquet:"name=budget, type=INT64"`
+}
+
+func campaignsParquet(t *testing.T, campaigns ...campaign) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	pw, err := writer.NewParquetWriterFromWriter(&buf, new(campaign), 1)
+	require.NoError(t, err)
+	for _, c := range campaigns {
+		require.NoError(t, pw.Write(c))
+	}
+	require.NoError(t, pw.WriteStop())
+	return buf.Bytes()
+}
+
+func TestLookupEnricher(t *testing.T) {
+	source := &backendconfig.SourceT{ID: "source-1", WorkspaceID: "workspace-1"}
+	source.LookupEnrichment.Enabled = true
+	newConf := func(t *testing.T) *config.Config {
+		conf := config.New()
+		conf.Set("RUDDER_TMPDIR", t.TempDir())
+		conf.Set("LookupEnrichment.tables", []string{"accountTiers", "campaigns"})
+		conf.Set("LookupEnrichment.accountTiers.objectKey", "lookup/account_tiers.csv")
+		conf.Set("LookupEnrichment.accountTiers.key", "userId")
+		conf.Set("LookupEnrichment.accountTiers.keyColumn", "user_id")
+		conf.Set("LookupEnrichment.accountTiers.columns", []string{"tier", "plan"})
+		conf.Set("LookupEnrichment.accountTiers.contextKey", "account")
+		conf.Set("LookupEnrichment.campaigns.objectKey", "lookup/campaigns.parquet")
+		conf.Set("LookupEnrichment.campaigns.key", "context.page.url")
+		conf.Set("LookupEnrichment.campaigns.keyFunction", "domain")
+		conf.Set("LookupEnrichment.campaigns.keyColumn", "domain")
+		return conf
+	}
+	summer := "summer-sale"
+	newDownloader := func(t *testing.T) *fakeDownloader {
+		return &fakeDownloader{objects: map[string][]byte{
+			"lookup/account_tiers.csv": []byte("user_id,tier,plan,owner\nuser-1,enterprise,annual,alice\n42,free,monthly,bob\n"),
+			"lookup/campaigns.parquet": campaignsParquet(t,
+				campaign{Domain: "example.com", Campaign: &summer, Budget: 1000},
+				campaign{Domain: "rudderstack.com", Budget: 10},
+			),
+		}}
+	}
+
+	t.Run("enrich", func(t *testing.T) {
+		e, err := newLookupEnricher(newConf(t), logger.NOP, stats.NOP, newDownloader(t))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, e.Close()) }()
+
+		request := &types.GatewayBatchRequest{Batch: []types.SingularEventT{
+			{"userId": "user-1", "context": map[string]interface{}{"page": map[string]interface{}{"url": "https://www.Example.com/pricing?plan=annual"}}},
+			{"userId": float64(42)},
+			{"userId": "user-2", "context": map[string]interface{}{"page": map[string]interface{}{"url": "rudderstack.com/docs"}}},
+			{"userId": "user-1", "context": map[string]interface{}{"account": "sent with the event"}},
+			{"anonymousId": "anonymous-1", "context": map[string]interface{}{"page": map[string]interface{}{"url": "https://unknown.com"}}},
+		}}
+		require.NoError(t, e.Enrich(source, request, &types.EventParams{}))
+		require.Equal(t, []types.SingularEventT{
+			{"userId": "user-1", "context": map[string]interface{}{
+				"page":      map[string]interface{}{"url": "https://www.Example.com/pricing?plan=annual"},
+				"account":   map[string]any{"tier": "enterprise", "plan": "annual"},
+				"campaigns": map[string]any{"campaign": "summer-sale", "budget": int64(1000)},
+			}},
+			{"userId": float64(42), "context": map[string]interface{}{
+				"account": map[string]any{"tier": "free", "plan": "monthly"},
+			}},
+			{"userId": "user-2", "context": map[string]interface{}{
+				"page":      map[string]interface{}{"url": "rudderstack.com/docs"},
+				"campaigns": map[string]any{"campaign": nil, "budget": int64(10)},
+			}},
+			{"userId": "user-1", "context": map[string]interface{}{"account": "sent with the event"}},
+			{"anonymousId": "anonymous-1", "context": map[string]interface{}{"page": map[string]interface{}{"url": "https://unknown.com"}}},
+		}, request.Batch)
+
+		request = &types.GatewayBatchRequest{Batch: []types.SingularEventT{{"userId": "user-1", "context": "invalid"}}}
+		require.Error(t, e.Enrich(source, request, &types.EventParams{}))
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		conf := newConf(t)
+		conf.Set("LookupEnrichment.refreshInterval", "10ms")
+		d := newDownloader(t)
+		e, err := newLookupEnricher(conf, logger.NOP, stats.NOP, d)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, e.Close()) }()
+
+		tier := func() any {
+			request := &types.GatewayBatchRequest{Batch: []types.SingularEventT{{"userId": "user-1"}}}
+			require.NoError(t, e.Enrich(source, request, &types.EventParams{}))
+			account, _ := request.Batch[0]["context"].(map[string]interface{})["account"].(map[string]any)
+			return account["tier"]
+		}
+
+		d.set("lookup/account_tiers.csv", []byte("not,a\nvalid,lookup,table\n"))
+		time.Sleep(50 * time.Millisecond)
+		require.Equal(t, "enterprise", tier(), "rows are kept if refreshing fails")
+
+		d.set("lookup/account_tiers.csv", []byte("user_id,tier,plan\nuser-1,growth,monthly\n"))
+		require.Eventually(t, func() bool { return tier() == "growth" }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("scope", func(t *testing.T) {
+		conf := newConf(t)
+		conf.Set("LookupEnrichment.accountTiers.workspaceIDs", []string{"workspace-2"})
+		e, err := newLookupEnricher(conf, logger.NOP, stats.NOP, newDownloader(t))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, e.Close()) }()
+
+		enriched := func(source *backendconfig.SourceT) []string {
+			event := types.SingularEventT{"userId": "user-1", "context": map[string]interface{}{"page": map[string]interface{}{"url": "https://example.com"}}}
+			require.NoError(t, e.Enrich(source, &types.GatewayBatchRequest{Batch: []types.SingularEventT{event}}, &types.EventParams{}))
+			var contextKeys []string
+			for _, key := range []string{"account", "campaigns"} {
+				if _, ok := event["context"].(map[string]interface{})[key]; ok {
+					contextKeys = append(contextKeys, key)
+				}
+			}
+			return contextKeys
+		}
+
+		require.Equal(t, []string{"campaigns"}, enriched(source), "tables of other workspaces are not used")
+
+		otherWorkspace := &backendconfig.SourceT{ID: "source-2", WorkspaceID: "workspace-2"}
+		otherWorkspace.LookupEnrichment.Enabled = true
+		require.Equal(t, []string{"account", "campaigns"}, enriched(otherWorkspace))
+		otherWorkspace.LookupEnrichment.Tables = []string{"accountTiers"}
+		require.Equal(t, []string{"account"}, enriched(otherWorkspace), "only the tables of the source are used")
+
+		disabled := &backendconfig.SourceT{ID: "source-3", WorkspaceID: "workspace-2"}
+		require.Empty(t, enriched(disabled), "sources need lookup enrichment enabled")
+	})
+
+	t.Run("tables which fail to load", func(t *testing.T) {
+		conf := newConf(t)
+		conf.Set("LookupEnrichment.refreshInterval", "10ms")
+		d := newDownloader(t)
+		d.set("lookup/account_tiers.csv", []byte("user,tier\nuser-1,growth\n"))
+		e, err := newLookupEnricher(conf, logger.NOP, stats.NOP, d)
+		require.NoError(t, err, "enrichment degrades instead of failing")
+		defer func() { require.NoError(t, e.Close()) }()
+
+		account := func() any {
+			request := &types.GatewayBatchRequest{Batch: []types.SingularEventT{{"userId": "user-1"}}}
+			require.NoError(t, e.Enrich(source, request, &types.EventParams{}))
+			context, _ := request.Batch[0]["context"].(map[string]interface{})
+			return context["account"]
+		}
+		require.Nil(t, account())
+
+		d.set("lookup/account_tiers.csv", []byte("user_id,tier,plan\nuser-1,growth,monthly\n"))
+		require.Eventually(t, func() bool { return account() != nil }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("unresponsive storage", func(t *testing.T) {
+		conf := newConf(t)
+		conf.Set("LookupEnrichment.loadTimeout", "50ms")
+		start := time.Now()
+		e, err := newLookupEnricher(conf, logger.NOP, stats.NOP, blockingDownloader{})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, e.Close()) }()
+		require.Less(t, time.Since(start), time.Second, "initial loads time out concurrently")
+
+		request := &types.GatewayBatchRequest{Batch: []types.SingularEventT{{"userId": "user-1"}}}
+		require.NoError(t, e.Enrich(source, request, &types.EventParams{}))
+		require.Equal(t, []types.SingularEventT{{"userId": "user-1"}}, request.Batch)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		for name, tc := range map[string]struct{ key, value string }{
+			"missing object key":   {key: "LookupEnrichment.accountTiers.objectKey", value: ""},
+			"missing key":          {key: "LookupEnrichment.accountTiers.key", value: ""},
+			"missing key column":   {key: "LookupEnrichment.accountTiers.keyColumn", value: ""},
+			"unsupported format":   {key: "LookupEnrichment.accountTiers.format", value: "xlsx"},
+			"unsupported function": {key: "LookupEnrichment.campaigns.keyFunction", value: "lower"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				conf := newConf(t)
+				conf.Set(tc.key, tc.value)
+				_, err := newLookupEnricher(conf, logger.NOP, stats.NOP, newDownloader(t))
+				require.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestDomain(t *testing.T) {
+	for rawURL, expected := range map[string]string{
+		"https://www.example.com/pricing": "example.com",
+		"http://shop.Example.com:8080":    "shop.example.com",
+		"example.com/docs?query=1":        "example.com",
+		"":                                "",
+		"https://%zz":                     "",
+	} {
+		require.Equal(t, expected, domain(rawURL), rawURL)
+	}
+}
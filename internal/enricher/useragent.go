@@ -0,0 +1,139 @@
+package enricher
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/useragent"
+)
+
+type UserAgentDetails struct {
+	Browser UserAgentSoftware `json:"browser"`
+	OS      UserAgentSoftware `json:"os"`
+	Device  UserAgentDevice   `json:"device"`
+}
+
+type UserAgentSoftware struct {
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	MajorVersion string `json:"majorVersion"`
+}
+
+type UserAgentDevice struct {
+	Type  string `json:"type"`
+	Brand string `json:"brand"`
+	Model string `json:"model"`
+}
+
+type userAgentEnricher struct {
+	parser *useragent.Parser
+	logger logger.Logger
+	stats  stats.Stats
+}
+
+// NewUserAgentEnricher returns an enricher parsing the user agent of events into their context.
+// Regexes are read from UserAgentEnrichment.regexesFile if set, otherwise the embedded ones are used.
+// Up to UserAgentEnrichment.cacheSize parsed user agents are cached.
+func NewUserAgentEnricher(conf *config.Config, log logger.Logger, statClient stats.Stats) (PipelineEnricher, error) {
+	log.Infon("Setting up new event user agent enricher")
+
+	var (
+		parser *useragent.Parser
+		err    error
+	)
+	cacheSize := useragent.WithCacheSize(conf.GetInt("UserAgentEnrichment.cacheSize", useragent.DefaultCacheSize))
+	if regexesFile := conf.GetString("UserAgentEnrichment.regexesFile", ""); regexesFile != "" {
+		log.Infon("loading user agent regexes from file", logger.NewStringField("file", regexesFile))
+		parser, err = useragent.NewFromFile(regexesFile, cacheSize)
+	} else {
+		parser, err = useragent.NewDefault(cacheSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating user agent parser: %w", err)
+	}
+
+	return &userAgentEnricher{
+		parser: parser,
+		stats:  statClient,
+		logger: log.Child("useragent"),
+	}, nil
+}
+
+// Enrich function runs on a request of GatewayBatchRequest which contains
+// multiple singular events from a source. The enrich function parses
+// context.userAgent of each event into context.userAgentDetails.
+func (e *userAgentEnricher) Enrich(source *backendconfig.SourceT, request *types.GatewayBatchRequest, _ *types.EventParams) error {
+	if !source.UserAgentEnrichment.Enabled {
+		return nil
+	}
+
+	e.logger.Debugn("received a call to enrich gateway events for source", obskit.SourceID(source.ID))
+
+	var enrichErrs []error
+	var parsed int
+	for _, event := range request.Batch {
+		// events without a context don't have a user agent either
+		eventContext, ok := event["context"]
+		if !ok || eventContext == nil {
+			continue
+		}
+
+		// if the context is other than map[string]interface{}, add error and continue
+		context, ok := eventContext.(map[string]interface{})
+		if !ok {
+			enrichErrs = append(enrichErrs, fmt.Errorf("event on source: %s doesn't have a valid context section", source.ID))
+			continue
+		}
+
+		// if the `userAgentDetails` key already present on the event, continue
+		if _, ok := context["userAgentDetails"]; ok {
+			continue
+		}
+
+		userAgent, _ := context["userAgent"].(string)
+		if userAgent == "" {
+			continue
+		}
+
+		ua := e.parser.Parse(userAgent)
+		context["userAgentDetails"] = UserAgentDetails{
+			Browser: UserAgentSoftware{
+				Name:         ua.Browser.Family,
+				Version:      ua.Browser.Version(),
+				MajorVersion: ua.Browser.Major,
+			},
+			OS: UserAgentSoftware{
+				Name:         ua.OS.Family,
+				Version:      ua.OS.Version(),
+				MajorVersion: ua.OS.Major,
+			},
+			Device: UserAgentDevice{
+				Type:  ua.Device.Type,
+				Brand: ua.Device.Brand,
+				Model: ua.Device.Model,
+			},
+		}
+		parsed++
+	}
+
+	e.stats.NewTaggedStat(
+		"proc_useragent_enricher_parsed_events",
+		stats.CountType,
+		stats.Tags{
+			"sourceId":    source.ID,
+			"workspaceId": source.WorkspaceID,
+			"sourceType":  source.SourceDefinition.Type,
+		}).Count(parsed)
+
+	return errors.Join(enrichErrs...)
+}
+
+func (e *userAgentEnricher) Close() error {
+	return nil
+}
@@ -0,0 +1,102 @@
+package enricher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+func TestUserAgentEnricher(t *testing.T) {
+	const iPhoneUserAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1"
+	source := &backendconfig.SourceT{ID: "source-1", WorkspaceID: "workspace-1"}
+	source.UserAgentEnrichment.Enabled = true
+
+	t.Run("enrich", func(t *testing.T) {
+		statsStore, err := memstats.New()
+		require.NoError(t, err)
+		e, err := NewUserAgentEnricher(config.New(), logger.NOP, statsStore)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, e.Close()) }()
+
+		request := &types.GatewayBatchRequest{Batch: []types.SingularEventT{
+			{"context": map[string]interface{}{"userAgent": iPhoneUserAgent}},
+			{"context": map[string]interface{}{"userAgent": iPhoneUserAgent, "userAgentDetails": "sent with the event"}},
+			{"context": map[string]interface{}{"userAgent": ""}},
+			{"context": map[string]interface{}{}},
+			{"event": "no context"},
+		}}
+		require.NoError(t, e.Enrich(source, request, &types.EventParams{}))
+		require.Equal(t, []types.SingularEventT{
+			{"context": map[string]interface{}{
+				"userAgent": iPhoneUserAgent,
+				"userAgentDetails": UserAgentDetails{
+					Browser: UserAgentSoftware{Name: "Mobile Safari", Version: "17.1.2", MajorVersion: "17"},
+					OS:      UserAgentSoftware{Name: "iOS", Version: "17.1.2", MajorVersion: "17"},
+					Device:  UserAgentDevice{Type: "mobile", Brand: "Apple", Model: "iPhone"},
+				},
+			}},
+			{"context": map[string]interface{}{"userAgent": iPhoneUserAgent, "userAgentDetails": "sent with the event"}},
+			{"context": map[string]interface{}{"userAgent": ""}},
+			{"context": map[string]interface{}{}},
+			{"event": "no context"},
+		}, request.Batch)
+		require.EqualValues(t, 1, statsStore.Get("proc_useragent_enricher_parsed_events", stats.Tags{
+			"sourceId":    "source-1",
+			"workspaceId": "workspace-1",
+			"sourceType":  "",
+		}).LastValue())
+
+		request = &types.GatewayBatchRequest{Batch: []types.SingularEventT{{"context": "invalid"}}}
+		require.Error(t, e.Enrich(source, request, &types.EventParams{}))
+	})
+
+	t.Run("disabled for the source", func(t *testing.T) {
+		e, err := NewUserAgentEnricher(config.New(), logger.NOP, stats.NOP)
+		require.NoError(t, err)
+
+		request := &types.GatewayBatchRequest{Batch: []types.SingularEventT{
+			{"context": map[string]interface{}{"userAgent": iPhoneUserAgent}},
+		}}
+		require.NoError(t, e.Enrich(&backendconfig.SourceT{ID: "source-2"}, request, &types.EventParams{}))
+		require.NotContains(t, request.Batch[0]["context"], "userAgentDetails")
+	})
+
+	t.Run("regexes file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "regexes.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+user_agent_parsers:
+  - regex: '(MyApp)/(\d+)\.(\d+)'
+device_parsers:
+  - regex: 'Kiosk'
+    device_replacement: 'Generic Tablet'
+`), 0o600))
+		conf := config.New()
+		conf.Set("UserAgentEnrichment.regexesFile", path)
+		e, err := NewUserAgentEnricher(conf, logger.NOP, stats.NOP)
+		require.NoError(t, err)
+
+		request := &types.GatewayBatchRequest{Batch: []types.SingularEventT{
+			{"context": map[string]interface{}{"userAgent": "MyApp/2.5 Kiosk"}},
+		}}
+		require.NoError(t, e.Enrich(source, request, &types.EventParams{}))
+		require.Equal(t, UserAgentDetails{
+			Browser: UserAgentSoftware{Name: "MyApp", Version: "2.5", MajorVersion: "2"},
+			OS:      UserAgentSoftware{Name: "Other"},
+			Device:  UserAgentDevice{Type: "tablet"},
+		}, request.Batch[0]["context"].(map[string]interface{})["userAgentDetails"])
+
+		conf = config.New()
+		conf.Set("UserAgentEnrichment.regexesFile", filepath.Join(t.TempDir(), "missing.yaml"))
+		_, err = NewUserAgentEnricher(conf, logger.NOP, stats.NOP)
+		require.Error(t, err)
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
+
 	"github.com/rudderlabs/rudder-go-kit/logger"
 )
 
@@ -145,7 +147,8 @@ func (jd *Handle) doCleanup(ctx context.Context) error {
 
 	// 2. cleanup journal
 	{
-		deleteStmt := "DELETE FROM %s_journal WHERE start_time < NOW() - INTERVAL '%d DAY'"
+		// pending status transitions are only deleted once published...
+		deleteStmt := "DELETE FROM %s_journal WHERE start_time < NOW() - INTERVAL '%d DAY' AND operation <> '%s'"
 		var journalEntryCount int64
 		res, err := jd.dbHandle.ExecContext(
 			ctx,
@@ -153,6 +156,7 @@ func (jd *Handle) doCleanup(ctx context.Context) error {
 				deleteStmt,
 				jd.tablePrefix,
 				jd.config.GetIntVar(10, 1, jd.configKeys("archivalTimeInDays")...),
+				statusCDCOperation,
 			),
 		)
 		if err != nil {
@@ -165,6 +169,10 @@ func (jd *Handle) doCleanup(ctx context.Context) error {
 		jd.logger.Infon("journal cleanup",
 			logger.NewIntField("journalEntriesCleaned", journalEntryCount),
 		)
+		// ...unless they are too old or too many
+		if _, err := jd.dropStaleStatusTransitions(ctx); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -174,6 +182,12 @@ func (jd *Handle) abortOldJobs(ctx context.Context, dsList []dataSetT) error {
 	jobState := "aborted"
 	maxAgeStatusResponse := `{"reason": "job max age exceeded"}`
 	maxAge := jd.conf.jobMaxAge.Load()
+	createdBefore := time.Now().Add(-maxAge)
+	if !jd.snapshotBefore(ctx, snapshotAbortOldJobs, dsList, false, func(dataSetT) string {
+		return `j.created_at <= $1 AND (s.job_state IS NULL OR s.job_state <> ALL($2))`
+	}, createdBefore, pq.Array(validTerminalStates)) {
+		return nil
+	}
 	for _, ds := range dsList {
 		res, err := jd.dbHandle.ExecContext(
 			ctx,
@@ -185,7 +199,7 @@ func (jd *Handle) abortOldJobs(ctx context.Context, dsList []dataSetT) error {
 			),
 			jobState,
 			maxAgeStatusResponse,
-			time.Now().Add(-maxAge),
+			createdBefore,
 		)
 		if err != nil {
 			return fmt.Errorf("aborting old jobs on ds %v: %w", ds, err)
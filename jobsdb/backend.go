@@ -0,0 +1,59 @@
+package jobsdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+)
+
+// Backends of jobsdb, selected through JobsDB.backend
+const (
+	BackendPostgres = "postgres"
+	BackendEmbedded = "embedded"
+)
+
+// BackendHandle is implemented by the handles of all jobsdb backends, which need to be started before being used, then stopped and closed
+type BackendHandle interface {
+	JobsDB
+	Start() error
+	Stop()
+	Close()
+	GetWorkspaceBacklogs(ctx context.Context) (map[string]WorkspaceBacklog, error)
+}
+
+var (
+	_ BackendHandle = (*Handle)(nil)
+	_ BackendHandle = (*EmbeddedHandle)(nil)
+)
+
+// Backend returns the jobsdb backend configured through JobsDB.backend, postgres by default
+func Backend(c *config.Config) (string, error) {
+	switch backend := c.GetStringVar(BackendPostgres, "JobsDB.backend"); backend {
+	case BackendPostgres, BackendEmbedded:
+		return backend, nil
+	default:
+		return "", fmt.Errorf("unknown jobsdb backend %q, expected one of %q, %q", backend, BackendPostgres, BackendEmbedded)
+	}
+}
+
+// New returns a jobsdb of the owner type for the backend, i.e. an [EmbeddedHandle] for [BackendEmbedded] and a postgres [Handle] otherwise.
+//
+// An embedded jobsdb only uses the config and stats set by the options, all other options only apply to postgres.
+func New(backend string, ownerType OwnerType, tablePrefix string, opts ...OptsFunc) BackendHandle {
+	if backend != BackendEmbedded {
+		return newOwnerType(ownerType, tablePrefix, opts...)
+	}
+	var probe Handle
+	for _, fn := range opts {
+		fn(&probe)
+	}
+	var embeddedOpts []EmbeddedOptsFunc
+	if probe.config != nil {
+		embeddedOpts = append(embeddedOpts, WithEmbeddedConfig(probe.config))
+	}
+	if probe.stats != nil {
+		embeddedOpts = append(embeddedOpts, WithEmbeddedStats(probe.stats))
+	}
+	return newEmbedded(ownerType, tablePrefix, embeddedOpts...)
+}
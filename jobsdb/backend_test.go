@@ -0,0 +1,211 @@
+package jobsdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	rsRand "github.com/rudderlabs/rudder-go-kit/testhelper/rand"
+)
+
+func TestNew(t *testing.T) {
+	c := config.New()
+	backend, err := Backend(c)
+	require.NoError(t, err)
+	require.Equal(t, BackendPostgres, backend)
+
+	c.Set("JobsDB.backend", BackendEmbedded)
+	backend, err = Backend(c)
+	require.NoError(t, err)
+	jd := New(backend, ReadWrite, "gw", WithConfig(c), WithStats(stats.NOP))
+	require.IsType(t, &EmbeddedHandle{}, jd)
+	require.Same(t, c, jd.(*EmbeddedHandle).config)
+	require.Equal(t, stats.NOP, jd.(*EmbeddedHandle).stats)
+
+	c.Set("JobsDB.backend", "mysql")
+	_, err = Backend(c)
+	require.Error(t, err)
+}
+
+func TestQueryLimits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, newJobsDB func(prefix string) testJobsDB) {
+		customVal := "MOCKDS"
+
+		t.Run("querying with an payload size limit should return at least one job even if limit is exceeded", func(t *testing.T) {
+			jobDB := newJobsDB(strings.ToLower(rsRand.String(5)))
+			defer jobDB.TearDown()
+
+			jobs := genJobs(defaultWorkspaceID, customVal, 2, 1)
+			require.NoError(t, jobDB.Store(context.Background(), jobs))
+			first, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{JobsLimit: 1})
+			require.NoError(t, err)
+
+			payloadLimit := first.PayloadSize / 2
+			payloadLimitList, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{
+				CustomValFilters: []string{customVal},
+				JobsLimit:        100,
+				PayloadSizeLimit: payloadLimit,
+				ParameterFilters: []ParameterFilterT{},
+			})
+			require.NoError(t, err, "GetUnprocessed failed")
+
+			requireSequential(t, payloadLimitList.Jobs)
+			require.Equal(t, 1, len(payloadLimitList.Jobs))
+		})
+
+		t.Run("querying with an event count limit should return at least one job even if limit is exceeded", func(t *testing.T) {
+			jobDB := newJobsDB(strings.ToLower(rsRand.String(5)))
+			defer jobDB.TearDown()
+
+			jobs := genJobs(defaultWorkspaceID, customVal, 2, 4)
+			require.NoError(t, jobDB.Store(context.Background(), jobs))
+
+			eventCountLimit := 1
+			eventLimitList, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{
+				CustomValFilters: []string{customVal},
+				JobsLimit:        100,
+				EventsLimit:      eventCountLimit,
+				ParameterFilters: []ParameterFilterT{},
+			})
+			require.NoError(t, err, "GetUnprocessed failed")
+
+			requireSequential(t, eventLimitList.Jobs)
+			require.Equal(t, 1, len(eventLimitList.Jobs))
+		})
+
+		t.Run("filters by custom value, parameters and workspace", func(t *testing.T) {
+			jobDB := newJobsDB(strings.ToLower(rsRand.String(5)))
+			defer jobDB.TearDown()
+
+			jobs := append(genJobs("ws-1", customVal, 2, 1), genJobs("ws-2", customVal+"_other", 1, 1)...)
+			jobs[1].Parameters = []byte(`{"source_id":"other-source"}`)
+			require.NoError(t, jobDB.Store(context.Background(), jobs))
+
+			res, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, JobsLimit: 100})
+			require.NoError(t, err)
+			require.Len(t, res.Jobs, 2)
+
+			res, err = jobDB.GetUnprocessed(context.Background(), GetQueryParams{ParameterFilters: []ParameterFilterT{{Name: "source_id", Value: "other-source"}}, JobsLimit: 100})
+			require.NoError(t, err)
+			require.Len(t, res.Jobs, 1)
+			require.Equal(t, jobs[1].UUID, res.Jobs[0].UUID)
+
+			res, err = jobDB.GetUnprocessed(context.Background(), GetQueryParams{WorkspaceID: "ws-2", JobsLimit: 100})
+			require.NoError(t, err)
+			require.Len(t, res.Jobs, 1)
+			require.Equal(t, "ws-2", res.Jobs[0].WorkspaceId)
+		})
+	})
+}
+
+func TestTransactions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, newJobsDB func(prefix string) testJobsDB) {
+		ctx := context.Background()
+		jobDB := newJobsDB(strings.ToLower(rsRand.String(5)))
+		defer jobDB.TearDown()
+
+		errRollback := errors.New("rollback")
+		err := jobDB.WithStoreSafeTx(ctx, func(tx StoreSafeTx) error {
+			require.NoError(t, jobDB.StoreInTx(ctx, tx, genJobs(defaultWorkspaceID, "MOCKDS", 2, 1)))
+			return errRollback
+		})
+		require.ErrorIs(t, err, errRollback)
+		res, err := jobDB.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 100})
+		require.NoError(t, err)
+		require.Empty(t, res.Jobs, "jobs of a rolled back transaction shouldn't be stored")
+
+		var committed bool
+		require.NoError(t, jobDB.WithStoreSafeTx(ctx, func(tx StoreSafeTx) error {
+			tx.Tx().AddSuccessListener(func() { committed = true })
+			return jobDB.StoreInTx(ctx, tx, genJobs(defaultWorkspaceID, "MOCKDS", 2, 1))
+		}))
+		require.True(t, committed)
+		res, err = jobDB.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 100})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 2)
+
+		err = jobDB.WithUpdateSafeTx(ctx, func(tx UpdateSafeTx) error {
+			require.NoError(t, jobDB.UpdateJobStatusInTx(ctx, tx, genJobStatuses(res.Jobs, Succeeded.State), nil, nil))
+			return errRollback
+		})
+		require.ErrorIs(t, err, errRollback)
+		res, err = jobDB.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 100})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 2, "statuses of a rolled back transaction shouldn't be stored")
+
+		errs := jobDB.StoreEachBatchRetry(ctx, [][]*JobT{genJobs(defaultWorkspaceID, "MOCKDS", 1, 1), genJobs(defaultWorkspaceID, "MOCKDS", 1, 1)})
+		require.Empty(t, errs)
+		res, err = jobDB.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 100})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 4)
+	})
+}
+
+func TestDistinctValuesAndPileUps(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, newJobsDB func(prefix string) testJobsDB) {
+		ctx := context.Background()
+		prefix := strings.ToLower(rsRand.String(5))
+		jobDB := newJobsDB(prefix)
+		defer jobDB.TearDown()
+
+		jobs := append(genJobs("ws-1", "MOCKDS", 3, 2), genJobs("ws-2", "OTHER", 1, 2)...)
+		require.NoError(t, jobDB.Store(ctx, jobs))
+
+		values, err := jobDB.GetDistinctParameterValues(ctx, WorkspaceID, "")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"ws-1", "ws-2"}, values)
+		values, err = jobDB.GetDistinctParameterValues(ctx, WorkspaceID, "MOCKDS")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"ws-1"}, values)
+		values, err = jobDB.GetDistinctParameterValues(ctx, SourceID, "")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"sourceID"}, values)
+
+		res, err := jobDB.GetUnprocessed(ctx, GetQueryParams{CustomValFilters: []string{"MOCKDS"}, JobsLimit: 1})
+		require.NoError(t, err)
+		require.NoError(t, jobDB.UpdateJobStatus(ctx, genJobStatuses(res.Jobs, Succeeded.State), nil, nil))
+
+		pileUps := map[string]float64{}
+		require.NoError(t, jobDB.GetPileUpCounts(ctx, time.Now().Add(time.Minute), func(tablePrefix, workspace, destType string, value float64) {
+			require.Equal(t, prefix, tablePrefix)
+			pileUps[workspace+"/"+destType] += value
+		}))
+		require.Equal(t, map[string]float64{"ws-1/MOCKDS": 2, "ws-2/OTHER": 1}, pileUps)
+	})
+}
+
+func TestJournal(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, newJobsDB func(prefix string) testJobsDB) {
+		jd := newJobsDB(strings.ToLower(rsRand.String(5)))
+		defer jd.TearDown()
+
+		first, err := jd.JournalMarkStart(RawDataDestUploadOperation, []byte(`{"id":1}`))
+		require.NoError(t, err)
+		second, err := jd.JournalMarkStart(RawDataDestUploadOperation, []byte(`{"id":2}`))
+		require.NoError(t, err)
+		other, err := jd.JournalMarkStart(statusCDCOperation, []byte(`{}`))
+		require.NoError(t, err)
+		require.Less(t, first, second)
+
+		entries := jd.GetJournalEntries(RawDataDestUploadOperation)
+		require.Len(t, entries, 2)
+		require.Equal(t, first, entries[0].OpID)
+		require.Equal(t, RawDataDestUploadOperation, entries[0].OpType)
+		require.JSONEq(t, `{"id":1}`, string(entries[0].OpPayload))
+		require.Equal(t, second, entries[1].OpID)
+
+		require.NoError(t, jd.JournalMarkDone(first))
+		jd.JournalDeleteEntry(other)
+		require.Empty(t, jd.GetJournalEntries(statusCDCOperation))
+
+		entries = jd.GetJournalEntries(RawDataDestUploadOperation)
+		require.Len(t, entries, 1)
+		require.Equal(t, second, entries[0].OpID)
+	})
+}
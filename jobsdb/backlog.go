@@ -0,0 +1,140 @@
+package jobsdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lib/pq"
+)
+
+// WorkspaceBacklog is the backlog of incomplete jobs of a workspace in a jobsdb
+type WorkspaceBacklog struct {
+	Jobs         int64
+	PayloadBytes int64 // the size of the payloads of the jobs on disk
+}
+
+// GetWorkspaceBacklogs returns the backlog of incomplete jobs of every workspace having any.
+// Unlike pile up counts, it neither pauses nor blocks dataset migrations, since it is read periodically:
+// datasets which get dropped while being read are skipped, and jobs are only looked up for a terminal status,
+// which doesn't need sorting the statuses of every job for finding its last one.
+// Writers only keep their last datasets in their list, thus they read the datasets from the database instead.
+func (jd *Handle) GetWorkspaceBacklogs(ctx context.Context) (map[string]WorkspaceBacklog, error) {
+	if jd.ownerType == Write {
+		return NewBacklogReader(jd.dbHandle, jd.tablePrefix).GetWorkspaceBacklogs(ctx)
+	}
+	if !jd.dsListLock.RTryLockWithCtx(ctx) {
+		return nil, fmt.Errorf("could not acquire a dslist read lock: %w", ctx.Err())
+	}
+	dsList := jd.getDSList()
+	jd.dsListLock.RUnlock()
+	return workspaceBacklogs(ctx, jd.dbHandle, dsList)
+}
+
+// BacklogReader reads the workspace backlogs of a postgres jobsdb without owning it, e.g. a standalone gateway
+// reading the backlogs of the router jobsdbs of the same database. Datasets are listed from the database on every read.
+type BacklogReader struct {
+	dbHandle    *sql.DB
+	tablePrefix string
+}
+
+// NewBacklogReader returns a reader of the workspace backlogs of the postgres jobsdb with the table prefix
+func NewBacklogReader(dbHandle *sql.DB, tablePrefix string) *BacklogReader {
+	return &BacklogReader{dbHandle: dbHandle, tablePrefix: tablePrefix}
+}
+
+// GetWorkspaceBacklogs returns the backlog of incomplete jobs of every workspace having any, see [Handle.GetWorkspaceBacklogs]
+func (r *BacklogReader) GetWorkspaceBacklogs(ctx context.Context) (map[string]WorkspaceBacklog, error) {
+	tableNames, err := getAllTableNames(r.dbHandle)
+	if err != nil {
+		return nil, fmt.Errorf("getting table names: %w", err)
+	}
+	var dsList []dataSetT
+	for _, tableName := range tableNames {
+		index, ok := strings.CutPrefix(tableName, r.tablePrefix+"_jobs_")
+		if !ok {
+			continue
+		}
+		// datasets being added or dropped in the meantime may lack their status table
+		if statusTable := r.tablePrefix + "_job_status_" + index; slices.Contains(tableNames, statusTable) {
+			dsList = append(dsList, dataSetT{JobTable: tableName, JobStatusTable: statusTable, Index: index})
+		}
+	}
+	return workspaceBacklogs(ctx, r.dbHandle, dsList)
+}
+
+// workspaceBacklogs returns the backlogs of the datasets
+func workspaceBacklogs(ctx context.Context, dbHandle *sql.DB, dsList []dataSetT) (map[string]WorkspaceBacklog, error) {
+	backlogs := make(map[string]WorkspaceBacklog)
+	for _, ds := range dsList {
+		if err := datasetBacklogs(ctx, dbHandle, ds, backlogs); err != nil {
+			var pqError *pq.Error
+			if errors.As(err, &pqError) && pqError.Code == "42P01" { // dropped by a migration in the meantime
+				continue
+			}
+			return nil, err
+		}
+	}
+	return backlogs, nil
+}
+
+// datasetBacklogs adds the incomplete jobs of a dataset to the backlogs of their workspaces
+func datasetBacklogs(ctx context.Context, dbHandle *sql.DB, ds dataSetT, backlogs map[string]WorkspaceBacklog) error {
+	rows, err := dbHandle.QueryContext(ctx, fmt.Sprintf(
+		`SELECT j.workspace_id, COUNT(*), SUM(pg_column_size(j.event_payload)) FROM %[1]q j
+		WHERE NOT EXISTS (SELECT 1 FROM %[2]q s WHERE s.job_id = j.job_id AND s.job_state = ANY($1))
+		GROUP BY j.workspace_id`, ds.JobTable, ds.JobStatusTable),
+		pq.Array(validTerminalStates),
+	)
+	if err != nil {
+		return fmt.Errorf("getting workspace backlogs of %q: %w", ds.JobTable, err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			workspace         string
+			jobs, payloadSize int64
+		)
+		if err := rows.Scan(&workspace, &jobs, &payloadSize); err != nil {
+			return fmt.Errorf("scanning workspace backlogs of %q: %w", ds.JobTable, err)
+		}
+		backlog := backlogs[workspace]
+		backlog.Jobs += jobs
+		backlog.PayloadBytes += payloadSize
+		backlogs[workspace] = backlog
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting workspace backlogs of %q: %w", ds.JobTable, err)
+	}
+	return nil
+}
+
+// GetWorkspaceBacklogs returns the backlog of incomplete jobs of every workspace having any
+func (jd *EmbeddedHandle) GetWorkspaceBacklogs(ctx context.Context) (map[string]WorkspaceBacklog, error) {
+	pendingStates := []string{Executing.State, Failed.State, Importing.State, Waiting.State}
+	backlogs := make(map[string]WorkspaceBacklog)
+	err := jd.store.db.View(func(txn *badger.Txn) error {
+		return iterateEmbeddedJobs(ctx, txn, func(job *JobT) error {
+			statuses, err := getEmbeddedStatuses(txn, job.JobID)
+			if err != nil {
+				return err
+			}
+			if len(statuses) > 0 && !slices.Contains(pendingStates, statuses[len(statuses)-1].JobState) {
+				return nil
+			}
+			backlog := backlogs[job.WorkspaceId]
+			backlog.Jobs++
+			backlog.PayloadBytes += int64(len(job.EventPayload))
+			backlogs[job.WorkspaceId] = backlog
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting workspace backlogs: %w", err)
+	}
+	return backlogs, nil
+}
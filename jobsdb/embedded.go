@@ -0,0 +1,1126 @@
+package jobsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-go-kit/bytesize"
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/services/rmetrics"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	. "github.com/rudderlabs/rudder-server/utils/tx" //nolint:staticcheck
+)
+
+// key prefixes of the embedded store
+const (
+	embeddedJobKeyPrefix    = 'j' // job id -> job
+	embeddedStatusKeyPrefix = 's' // job id -> statuses of the job, the last one being the latest
+	embeddedStateKeyPrefix  = 'x' // latest state of the job + job id -> nothing
+	embeddedSequenceKey     = 'q' // sequence of job ids
+	embeddedJournalPrefix   = 'o' // owner type + operation id -> journal entry
+	embeddedJournalSeqKey   = 'p' // sequence of journal operation ids
+)
+
+var (
+	errEmbeddedTxNotFound = errors.New("transaction doesn't belong to this jobsdb")
+	errEmbeddedTxnTooBig  = fmt.Errorf("transaction exceeds the size limit of the embedded store, see JobsDB.embedded.memTableSizeInMB: %w", badger.ErrTxnTooBig)
+)
+
+var _ JobsDB = (*EmbeddedHandle)(nil)
+
+// EmbeddedHandle is a single-node jobsdb which stores jobs in an embedded key-value store (badger) instead of postgres,
+// for deployments where running postgres alongside the server is too heavy, e.g. edge collectors and local development.
+// It is used instead of postgres by the embedded app type when JobsDB.backend is set to embedded, see [New].
+//
+// Jobs of the same table prefix are stored under JobsDB.embedded.dir/<tablePrefix> and are shared by all
+// handles of that prefix in the process, e.g. a gateway writer and a processor reader.
+//
+// Transactions of an embedded jobsdb aren't backed by a sql.Tx, thus [StoreSafeTx.SqlTx] and [UpdateSafeTx.SqlTx]
+// return nil and can't be used for writing to postgres atomically along with jobs.
+// Transactions are atomic: a transaction exceeding badger's size limit fails as a whole, see JobsDB.embedded.memTableSizeInMB.
+// Jobs in a terminal state are deleted once they are older than JobsDB.embedded.terminalJobsRetention.
+type EmbeddedHandle struct {
+	tablePrefix string
+	ownerType   OwnerType
+	config      *config.Config
+	stats       stats.Stats
+	logger      logger.Logger
+
+	store *embeddedStore
+	txs   sync.Map // *Tx -> *embeddedTxn
+
+	conf struct {
+		dir                   string
+		memTableSize          int64
+		terminalJobsRetention config.ValueLoader[time.Duration]
+		cleanupInterval       config.ValueLoader[time.Duration]
+	}
+
+	lifecycle struct {
+		mu      sync.Mutex
+		started bool
+		cancel  context.CancelFunc
+		wg      sync.WaitGroup
+	}
+}
+
+// EmbeddedOptsFunc is an option of an embedded jobsdb
+type EmbeddedOptsFunc func(jd *EmbeddedHandle)
+
+// WithEmbeddedConfig sets the config of an embedded jobsdb
+func WithEmbeddedConfig(c *config.Config) EmbeddedOptsFunc {
+	return func(jd *EmbeddedHandle) {
+		jd.config = c
+	}
+}
+
+// WithEmbeddedStats sets the stats of an embedded jobsdb
+func WithEmbeddedStats(s stats.Stats) EmbeddedOptsFunc {
+	return func(jd *EmbeddedHandle) {
+		jd.stats = s
+	}
+}
+
+// WithEmbeddedDir sets the directory of the embedded store, overriding JobsDB.embedded.dir
+func WithEmbeddedDir(dir string) EmbeddedOptsFunc {
+	return func(jd *EmbeddedHandle) {
+		jd.conf.dir = dir
+	}
+}
+
+func NewEmbeddedForRead(tablePrefix string, opts ...EmbeddedOptsFunc) *EmbeddedHandle {
+	return newEmbedded(Read, tablePrefix, opts...)
+}
+
+func NewEmbeddedForWrite(tablePrefix string, opts ...EmbeddedOptsFunc) *EmbeddedHandle {
+	return newEmbedded(Write, tablePrefix, opts...)
+}
+
+func NewEmbeddedForReadWrite(tablePrefix string, opts ...EmbeddedOptsFunc) *EmbeddedHandle {
+	return newEmbedded(ReadWrite, tablePrefix, opts...)
+}
+
+func newEmbedded(ownerType OwnerType, tablePrefix string, opts ...EmbeddedOptsFunc) *EmbeddedHandle {
+	jd := &EmbeddedHandle{
+		ownerType:   ownerType,
+		tablePrefix: tablePrefix,
+	}
+	for _, fn := range opts {
+		fn(jd)
+	}
+	if jd.config == nil {
+		jd.config = config.Default
+	}
+	if jd.stats == nil {
+		jd.stats = stats.Default
+	}
+	jd.logger = logger.NewLogger().Child("jobsdb").Child("embedded").Child(tablePrefix)
+	if jd.conf.dir == "" {
+		jd.conf.dir = jd.config.GetStringVar("", "JobsDB.embedded.dir")
+	}
+	// badger limits the size of a transaction to 15% of the size of its memtables
+	jd.conf.memTableSize = jd.config.GetInt64Var(64, bytesize.MB, jd.configKeys("memTableSizeInMB")...)
+	jd.conf.terminalJobsRetention = jd.config.GetReloadableDurationVar(24, time.Hour, jd.configKeys("terminalJobsRetention")...)
+	jd.conf.cleanupInterval = jd.config.GetReloadableDurationVar(1, time.Minute, jd.configKeys("cleanupInterval")...)
+	return jd
+}
+
+func (jd *EmbeddedHandle) configKeys(key string) []string {
+	return []string{
+		"JobsDB." + jd.tablePrefix + ".embedded." + key,
+		"JobsDB.embedded." + key,
+	}
+}
+
+// Start opens the embedded store and starts the cleanup of terminal jobs.
+// Start should be called before any other jobsdb methods are called.
+func (jd *EmbeddedHandle) Start() error {
+	jd.lifecycle.mu.Lock()
+	defer jd.lifecycle.mu.Unlock()
+	if jd.lifecycle.started {
+		return nil
+	}
+	if jd.store == nil {
+		dir := jd.conf.dir
+		if dir == "" {
+			tmpDir, err := misc.CreateTMPDIR()
+			if err != nil {
+				return fmt.Errorf("creating tmp dir: %w", err)
+			}
+			dir = filepath.Join(tmpDir, "jobsdb")
+		}
+		store, err := openEmbeddedStore(filepath.Join(dir, jd.tablePrefix), jd.conf.memTableSize, jd.logger)
+		if err != nil {
+			return fmt.Errorf("opening embedded store of %q: %w", jd.tablePrefix, err)
+		}
+		jd.store = store
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	jd.lifecycle.cancel = cancel
+	if jd.ownerType != Write {
+		jd.lifecycle.wg.Add(1)
+		go func() {
+			defer jd.lifecycle.wg.Done()
+			jd.cleanupLoop(ctx)
+		}()
+	}
+	jd.lifecycle.started = true
+	return nil
+}
+
+// Stop stops the cleanup of terminal jobs and waits until it finishes.
+func (jd *EmbeddedHandle) Stop() {
+	jd.lifecycle.mu.Lock()
+	defer jd.lifecycle.mu.Unlock()
+	if jd.lifecycle.started {
+		jd.lifecycle.cancel()
+		jd.lifecycle.wg.Wait()
+		jd.lifecycle.started = false
+	}
+}
+
+// TearDown stops the cleanup of terminal jobs and closes the embedded store.
+func (jd *EmbeddedHandle) TearDown() {
+	jd.Stop()
+	jd.Close()
+}
+
+// Close releases the embedded store, closing it if no other handle is using it.
+// Stop should be called before Close.
+func (jd *EmbeddedHandle) Close() {
+	jd.lifecycle.mu.Lock()
+	defer jd.lifecycle.mu.Unlock()
+	if jd.store == nil {
+		return
+	}
+	if err := jd.store.release(); err != nil {
+		jd.logger.Errorn("error closing embedded store", obskit.Error(err))
+	}
+	jd.store = nil
+}
+
+func (jd *EmbeddedHandle) Identifier() string {
+	return jd.tablePrefix
+}
+
+func (jd *EmbeddedHandle) Ping() error {
+	if jd.store == nil || jd.store.db.IsClosed() {
+		return errors.New("embedded store is closed")
+	}
+	return nil
+}
+
+func (jd *EmbeddedHandle) IsMasterBackupEnabled() bool {
+	return false
+}
+
+/* Transactions */
+
+// WithTx begins a new transaction of the embedded store. Write transactions of the same store are serialized,
+// so that jobs become visible in the order of their ids.
+//
+// If the writes of the transaction exceed badger's transaction size limit, writing fails with [errEmbeddedTxnTooBig] and nothing is committed.
+func (jd *EmbeddedHandle) WithTx(f func(tx *Tx) error) error {
+	jd.store.mu.Lock()
+	defer jd.store.mu.Unlock()
+	txn := &embeddedTxn{txn: jd.store.db.NewTransaction(true)}
+	defer txn.discard()
+	tx := &Tx{}
+	jd.txs.Store(tx, txn)
+	defer jd.txs.Delete(tx)
+	if err := f(tx); err != nil {
+		return err
+	}
+	if err := txn.commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	tx.Committed()
+	return nil
+}
+
+func (jd *EmbeddedHandle) txn(tx *Tx) (*embeddedTxn, error) {
+	if tx == nil {
+		return nil, errEmbeddedTxNotFound
+	}
+	txn, ok := jd.txs.Load(tx)
+	if !ok {
+		return nil, errEmbeddedTxNotFound
+	}
+	return txn.(*embeddedTxn), nil
+}
+
+// embeddedReader reads from a badger transaction
+type embeddedReader interface {
+	Get(key []byte) (*badger.Item, error)
+	NewIterator(opt badger.IteratorOptions) *badger.Iterator
+}
+
+// embeddedWriter reads from and writes to a badger transaction
+type embeddedWriter interface {
+	embeddedReader
+	Set(key, value []byte) error
+	Delete(key []byte) error
+}
+
+// embeddedTxn is a write transaction of the embedded store
+type embeddedTxn struct {
+	txn *badger.Txn
+}
+
+var _ embeddedWriter = (*embeddedTxn)(nil)
+
+func (t *embeddedTxn) Get(key []byte) (*badger.Item, error) {
+	return t.txn.Get(key)
+}
+
+func (t *embeddedTxn) NewIterator(opt badger.IteratorOptions) *badger.Iterator {
+	return t.txn.NewIterator(opt)
+}
+
+func (t *embeddedTxn) Set(key, value []byte) error {
+	return t.write(func(txn *badger.Txn) error { return txn.Set(key, value) })
+}
+
+func (t *embeddedTxn) Delete(key []byte) error {
+	return t.write(func(txn *badger.Txn) error { return txn.Delete(key) })
+}
+
+func (t *embeddedTxn) write(f func(txn *badger.Txn) error) error {
+	if err := f(t.txn); errors.Is(err, badger.ErrTxnTooBig) {
+		return errEmbeddedTxnTooBig
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *embeddedTxn) commit() error {
+	return t.txn.Commit()
+}
+
+func (t *embeddedTxn) discard() {
+	t.txn.Discard()
+}
+
+func (jd *EmbeddedHandle) WithStoreSafeTx(_ context.Context, f func(tx StoreSafeTx) error) error {
+	return jd.WithTx(func(tx *Tx) error { return f(&storeSafeTx{tx: tx, identity: jd.tablePrefix}) })
+}
+
+func (jd *EmbeddedHandle) WithStoreSafeTxFromTx(_ context.Context, tx *Tx, f func(tx StoreSafeTx) error) error {
+	return f(&storeSafeTx{tx: tx, identity: jd.tablePrefix})
+}
+
+func (jd *EmbeddedHandle) WithUpdateSafeTx(_ context.Context, f func(tx UpdateSafeTx) error) error {
+	return jd.WithTx(func(tx *Tx) error { return f(&updateSafeTx{tx: tx, identity: jd.tablePrefix}) })
+}
+
+/* Commands */
+
+func (jd *EmbeddedHandle) Store(ctx context.Context, jobList []*JobT) error {
+	return jd.WithStoreSafeTx(ctx, func(tx StoreSafeTx) error {
+		return jd.StoreInTx(ctx, tx, jobList)
+	})
+}
+
+func (jd *EmbeddedHandle) StoreInTx(_ context.Context, tx StoreSafeTx, jobList []*JobT) error {
+	txn, err := jd.txn(tx.Tx())
+	if err != nil {
+		return err
+	}
+	defer jd.timer("store_jobs").RecordDuration()()
+	if err := validateEmbeddedJobs(jobList); err != nil {
+		return err
+	}
+	return jd.storeInTxn(txn, jobList)
+}
+
+func (jd *EmbeddedHandle) StoreEachBatchRetry(ctx context.Context, jobBatches [][]*JobT) map[uuid.UUID]string {
+	var res map[uuid.UUID]string
+	err := jd.WithStoreSafeTx(ctx, func(tx StoreSafeTx) error {
+		var err error
+		res, err = jd.StoreEachBatchRetryInTx(ctx, tx, jobBatches)
+		return err
+	})
+	if err != nil {
+		res = make(map[uuid.UUID]string, len(jobBatches))
+		for _, batch := range jobBatches {
+			res[batch[0].UUID] = err.Error()
+		}
+	}
+	return res
+}
+
+// StoreEachBatchRetryInTx stores each batch separately, returning the uuid of the first job of each batch which couldn't be stored.
+// Batches are validated before any of them is stored, so that an invalid batch doesn't leave partial writes in the transaction.
+func (jd *EmbeddedHandle) StoreEachBatchRetryInTx(_ context.Context, tx StoreSafeTx, jobBatches [][]*JobT) (map[uuid.UUID]string, error) {
+	txn, err := jd.txn(tx.Tx())
+	if err != nil {
+		return nil, err
+	}
+	defer jd.timer("store_jobs_retry_each_batch").RecordDuration()()
+	res := make(map[uuid.UUID]string)
+	for _, batch := range jobBatches {
+		if err := validateEmbeddedJobs(batch); err != nil {
+			res[batch[0].UUID] = err.Error()
+			continue
+		}
+		if err := jd.storeInTxn(txn, batch); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func validateEmbeddedJobs(jobList []*JobT) error {
+	for _, job := range jobList {
+		if err := job.sanitizeJSON(); err != nil {
+			return fmt.Errorf("sanitizing job %s: %w", job.UUID, err)
+		}
+	}
+	return nil
+}
+
+func (jd *EmbeddedHandle) storeInTxn(txn embeddedWriter, jobList []*JobT) error {
+	now := time.Now()
+	for _, job := range jobList {
+		id, err := jd.store.seq.Next()
+		if err != nil {
+			return fmt.Errorf("getting next job id: %w", err)
+		}
+		stored := *job
+		stored.JobID = int64(id) + 1 // sequences start from zero
+		stored.CreatedAt = now
+		stored.ExpireAt = now
+		stored.EventCount = max(job.EventCount, 1)
+		stored.LastJobStatus = JobStatusT{}
+		value, err := jsonrs.Marshal(&stored)
+		if err != nil {
+			return fmt.Errorf("marshalling job %s: %w", job.UUID, err)
+		}
+		if err := txn.Set(embeddedJobKey(stored.JobID), value); err != nil {
+			return fmt.Errorf("storing job %s: %w", job.UUID, err)
+		}
+		if err := txn.Set(embeddedStateKey(Unprocessed.State, stored.JobID), nil); err != nil {
+			return fmt.Errorf("storing job %s: %w", job.UUID, err)
+		}
+	}
+	return nil
+}
+
+func (jd *EmbeddedHandle) UpdateJobStatus(ctx context.Context, statusList []*JobStatusT, customValFilters []string, parameterFilters []ParameterFilterT) error {
+	return jd.WithUpdateSafeTx(ctx, func(tx UpdateSafeTx) error {
+		return jd.UpdateJobStatusInTx(ctx, tx, statusList, customValFilters, parameterFilters)
+	})
+}
+
+// UpdateJobStatusInTx appends the statuses to the ones of their jobs. Statuses of jobs which don't exist, e.g. have already been cleaned up, are ignored.
+func (jd *EmbeddedHandle) UpdateJobStatusInTx(_ context.Context, tx UpdateSafeTx, statusList []*JobStatusT, _ []string, _ []ParameterFilterT) error {
+	txn, err := jd.txn(tx.Tx())
+	if err != nil {
+		return err
+	}
+	defer jd.timer("update_job_status").RecordDuration()()
+	for _, status := range statusList {
+		if _, err := txn.Get(embeddedJobKey(status.JobID)); errors.Is(err, badger.ErrKeyNotFound) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("getting job %d: %w", status.JobID, err)
+		}
+		if err := status.sanitizeJson(); err != nil {
+			return fmt.Errorf("sanitizing status of job %d: %w", status.JobID, err)
+		}
+		statuses, err := getEmbeddedStatuses(txn, status.JobID)
+		if err != nil {
+			return err
+		}
+		if err := setEmbeddedStatuses(txn, status.JobID, statuses, append(statuses, *status)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (jd *EmbeddedHandle) DeleteExecuting() {
+	jd.updateExecuting(func(statuses []JobStatusT) []JobStatusT {
+		return statuses[:len(statuses)-1]
+	})
+}
+
+func (jd *EmbeddedHandle) FailExecuting() {
+	jd.updateExecuting(func(statuses []JobStatusT) []JobStatusT {
+		updated := slices.Clone(statuses)
+		updated[len(updated)-1].JobState = Failed.State
+		return updated
+	})
+}
+
+// updateExecuting replaces the statuses of all jobs whose latest state is executing, panicking on failure like [Handle] does during recovery.
+// Jobs are updated in batches, each one in its own transaction, so that any number of executing jobs can be updated:
+// an updated job is no longer executing, thus a failure leaves the remaining jobs to be updated on the next attempt.
+func (jd *EmbeddedHandle) updateExecuting(update func(statuses []JobStatusT) []JobStatusT) {
+	const batchSize = 1000
+	for {
+		var updated int
+		err := jd.WithTx(func(tx *Tx) error {
+			txn, err := jd.txn(tx)
+			if err != nil {
+				return err
+			}
+			ids := embeddedStateJobIDs(txn, Executing.State, batchSize)
+			for _, id := range ids {
+				statuses, err := getEmbeddedStatuses(txn, id)
+				if err != nil {
+					return err
+				}
+				if err := setEmbeddedStatuses(txn, id, statuses, update(statuses)); err != nil {
+					return err
+				}
+			}
+			updated = len(ids)
+			return nil
+		})
+		if err != nil {
+			panic(fmt.Errorf("updating executing jobs of %q: %w", jd.tablePrefix, err))
+		}
+		if updated < batchSize {
+			return
+		}
+	}
+}
+
+/* Queries */
+
+func (jd *EmbeddedHandle) GetJobs(ctx context.Context, states []string, params GetQueryParams) (JobsResult, error) {
+	params.stateFilters = states
+	res, err := jd.getJobs(ctx, params)
+	if err != nil {
+		return JobsResult{}, err
+	}
+	return res.JobsResult, nil
+}
+
+func (jd *EmbeddedHandle) GetUnprocessed(ctx context.Context, params GetQueryParams) (JobsResult, error) {
+	return jd.GetJobs(ctx, []string{Unprocessed.State}, params)
+}
+
+func (jd *EmbeddedHandle) GetImporting(ctx context.Context, params GetQueryParams) (JobsResult, error) {
+	return jd.GetJobs(ctx, []string{Importing.State}, params)
+}
+
+func (jd *EmbeddedHandle) GetAborted(ctx context.Context, params GetQueryParams) (JobsResult, error) {
+	return jd.GetJobs(ctx, []string{Aborted.State}, params)
+}
+
+func (jd *EmbeddedHandle) GetWaiting(ctx context.Context, params GetQueryParams) (JobsResult, error) {
+	return jd.GetJobs(ctx, []string{Waiting.State}, params)
+}
+
+func (jd *EmbeddedHandle) GetSucceeded(ctx context.Context, params GetQueryParams) (JobsResult, error) {
+	return jd.GetJobs(ctx, []string{Succeeded.State}, params)
+}
+
+func (jd *EmbeddedHandle) GetFailed(ctx context.Context, params GetQueryParams) (JobsResult, error) {
+	return jd.GetJobs(ctx, []string{Failed.State}, params)
+}
+
+func (jd *EmbeddedHandle) GetToProcess(ctx context.Context, params GetQueryParams, more MoreToken) (*MoreJobsResult, error) {
+	mtoken := &moreToken{}
+	if more != nil {
+		var ok bool
+		if mtoken, ok = more.(*moreToken); !ok {
+			return nil, fmt.Errorf("invalid token: %+v", more)
+		}
+	}
+	if params.JobsLimit == 0 {
+		return &MoreJobsResult{More: more}, nil
+	}
+	params.stateFilters = []string{Failed.State, Waiting.State, Unprocessed.State}
+	params.afterJobID = mtoken.afterJobID
+	res, err := jd.getJobs(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Jobs) > 0 {
+		afterJobID := res.Jobs[len(res.Jobs)-1].JobID
+		mtoken.afterJobID = &afterJobID
+	}
+	res.More = mtoken
+	return res, nil
+}
+
+// getJobs returns the jobs matching the params, in the order of their ids,
+// by merging the jobs of each of the requested states
+func (jd *EmbeddedHandle) getJobs(ctx context.Context, params GetQueryParams) (*MoreJobsResult, error) {
+	res := &MoreJobsResult{}
+	if params.JobsLimit <= 0 || params.PayloadSizeLimit < 0 {
+		return res, nil
+	}
+	defer jd.timer("get_jobs").RecordDuration()()
+	var minJobID int64 = 1
+	if params.afterJobID != nil {
+		minJobID = *params.afterJobID + 1
+	}
+	err := jd.store.db.View(func(txn *badger.Txn) error {
+		iterators := make([]*embeddedStateIterator, 0, len(params.stateFilters))
+		defer func() {
+			for _, it := range iterators {
+				it.Close()
+			}
+		}()
+		for _, state := range lo.Uniq(params.stateFilters) {
+			iterators = append(iterators, newEmbeddedStateIterator(txn, state, minJobID))
+		}
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var next *embeddedStateIterator
+			for _, it := range iterators {
+				if it.Valid() && (next == nil || it.JobID() < next.JobID()) {
+					next = it
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			job, err := getEmbeddedJob(txn, next.JobID())
+			if err != nil {
+				return err
+			}
+			next.Next()
+			if !embeddedJobMatches(job, params) {
+				continue
+			}
+			if params.EventsLimit > 0 && res.EventsCount+job.EventCount > params.EventsLimit && len(res.Jobs) > 0 {
+				res.LimitsReached = true
+				return nil
+			}
+			payloadSize := int64(len(job.EventPayload))
+			if params.PayloadSizeLimit > 0 && res.PayloadSize+payloadSize > params.PayloadSizeLimit && len(res.Jobs) > 0 {
+				res.LimitsReached = true
+				return nil
+			}
+			res.Jobs = append(res.Jobs, job)
+			res.EventsCount += job.EventCount
+			res.PayloadSize += payloadSize
+			if len(res.Jobs) == params.JobsLimit ||
+				(params.EventsLimit > 0 && res.EventsCount >= params.EventsLimit) ||
+				(params.PayloadSizeLimit > 0 && res.PayloadSize >= params.PayloadSizeLimit) {
+				res.LimitsReached = true
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func embeddedJobMatches(job *JobT, params GetQueryParams) bool {
+	if len(params.CustomValFilters) > 0 && !params.IgnoreCustomValFiltersInQuery && !slices.Contains(params.CustomValFilters, job.CustomVal) {
+		return false
+	}
+	if params.WorkspaceID != "" && job.WorkspaceId != params.WorkspaceID {
+		return false
+	}
+	if len(params.ParameterFilters) > 0 && !lo.SomeBy(params.ParameterFilters, func(p ParameterFilterT) bool {
+		return gjson.GetBytes(job.Parameters, p.Name).String() == p.Value
+	}) {
+		return false
+	}
+	return true
+}
+
+func (jd *EmbeddedHandle) GetPileUpCounts(ctx context.Context, cutoffTime time.Time, increaseFunc rmetrics.IncreasePendingEventsFunc) error {
+	pendingStates := []string{Executing.State, Failed.State, Importing.State, Waiting.State}
+	counts := make(map[[2]string]int)
+	err := jd.store.db.View(func(txn *badger.Txn) error {
+		return iterateEmbeddedJobs(ctx, txn, func(job *JobT) error {
+			statuses, err := getEmbeddedStatuses(txn, job.JobID)
+			if err != nil {
+				return err
+			}
+			// the latest status before the cutoff time, if any, tells whether the job was pending at that time
+			for i := len(statuses) - 1; i >= 0; i-- {
+				if statuses[i].ExecTime.Before(cutoffTime) {
+					if !slices.Contains(pendingStates, statuses[i].JobState) {
+						return nil
+					}
+					break
+				}
+			}
+			counts[[2]string{job.WorkspaceId, job.CustomVal}]++
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("getting pileup counts: %w", err)
+	}
+	for key, count := range counts {
+		increaseFunc(jd.tablePrefix, key[0], key[1], float64(count))
+	}
+	return nil
+}
+
+func (jd *EmbeddedHandle) GetDistinctParameterValues(ctx context.Context, parameter ParameterName, customValFilter string) ([]string, error) {
+	var value func(job *JobT) string
+	switch parameter {
+	case SourceID:
+		value = func(job *JobT) string { return gjson.GetBytes(job.Parameters, "source_id").String() }
+	case DestinationID:
+		value = func(job *JobT) string { return gjson.GetBytes(job.Parameters, "destination_id").String() }
+	case WorkspaceID:
+		value = func(job *JobT) string { return job.WorkspaceId }
+	default:
+		return nil, fmt.Errorf("unsupported parameter: %s", parameter.string())
+	}
+	values := make(map[string]struct{})
+	err := jd.store.db.View(func(txn *badger.Txn) error {
+		return iterateEmbeddedJobs(ctx, txn, func(job *JobT) error {
+			if customValFilter == "" || job.CustomVal == customValFilter {
+				values[value(job)] = struct{}{}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get distinct parameter-%s: %w", parameter.string(), err)
+	}
+	res := lo.Keys(values)
+	slices.Sort(res)
+	return res, nil
+}
+
+/* Journal */
+
+// embeddedJournalEntry is the value of a journal entry in the embedded store
+type embeddedJournalEntry struct {
+	OpType    string          `json:"opType"`
+	OpDone    bool            `json:"opDone"`
+	OpPayload json.RawMessage `json:"opPayload"`
+	StartTime time.Time       `json:"startTime"`
+	EndTime   time.Time       `json:"endTime,omitempty"`
+}
+
+// GetJournalEntries returns the entries of the operation type which aren't done yet, in the order they were started
+func (jd *EmbeddedHandle) GetJournalEntries(opType string) (entries []JournalEntryT) {
+	prefix := jd.journalPrefix()
+	err := jd.store.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var entry embeddedJournalEntry
+			if err := it.Item().Value(func(v []byte) error { return jsonrs.Unmarshal(v, &entry) }); err != nil {
+				return fmt.Errorf("unmarshalling journal entry: %w", err)
+			}
+			if entry.OpDone || entry.OpType != opType {
+				continue
+			}
+			entries = append(entries, JournalEntryT{
+				OpID:      int64(binary.BigEndian.Uint64(bytes.TrimPrefix(it.Item().Key(), prefix))),
+				OpType:    entry.OpType,
+				OpDone:    entry.OpDone,
+				OpPayload: entry.OpPayload,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		panic(fmt.Errorf("getting journal entries of %q: %w", jd.tablePrefix, err))
+	}
+	return entries
+}
+
+// JournalMarkStart adds a journal entry for the start of an operation, returning its id
+func (jd *EmbeddedHandle) JournalMarkStart(opType string, opPayload json.RawMessage) (int64, error) {
+	id, err := jd.store.journalSeq.Next()
+	if err != nil {
+		return 0, fmt.Errorf("getting next journal operation id: %w", err)
+	}
+	opID := int64(id) + 1 // sequences start from zero
+	value, err := jsonrs.Marshal(embeddedJournalEntry{OpType: opType, OpPayload: opPayload, StartTime: time.Now()})
+	if err != nil {
+		return 0, fmt.Errorf("marshalling journal entry: %w", err)
+	}
+	return opID, jd.WithTx(func(tx *Tx) error {
+		txn, err := jd.txn(tx)
+		if err != nil {
+			return err
+		}
+		return txn.Set(jd.journalKey(opID), value)
+	})
+}
+
+// JournalMarkDone marks the operation of a journal entry as done
+func (jd *EmbeddedHandle) JournalMarkDone(opID int64) error {
+	return jd.WithTx(func(tx *Tx) error {
+		txn, err := jd.txn(tx)
+		if err != nil {
+			return err
+		}
+		item, err := txn.Get(jd.journalKey(opID))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("getting journal entry %d: %w", opID, err)
+		}
+		var entry embeddedJournalEntry
+		if err := item.Value(func(v []byte) error { return jsonrs.Unmarshal(v, &entry) }); err != nil {
+			return fmt.Errorf("unmarshalling journal entry %d: %w", opID, err)
+		}
+		entry.OpDone = true
+		entry.EndTime = time.Now()
+		value, err := jsonrs.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("marshalling journal entry %d: %w", opID, err)
+		}
+		return txn.Set(jd.journalKey(opID), value)
+	})
+}
+
+// JournalDeleteEntry deletes a journal entry, panicking on failure like [Handle] does
+func (jd *EmbeddedHandle) JournalDeleteEntry(opID int64) {
+	err := jd.WithTx(func(tx *Tx) error {
+		txn, err := jd.txn(tx)
+		if err != nil {
+			return err
+		}
+		return txn.Delete(jd.journalKey(opID))
+	})
+	if err != nil {
+		panic(fmt.Errorf("deleting journal entry %d of %q: %w", opID, jd.tablePrefix, err))
+	}
+}
+
+// journalPrefix is the key prefix of the journal entries of the handle's owner type, since entries are owned by the handle which started them
+func (jd *EmbeddedHandle) journalPrefix() []byte {
+	return append(append([]byte{embeddedJournalPrefix}, jd.ownerType...), 0)
+}
+
+func (jd *EmbeddedHandle) journalKey(opID int64) []byte {
+	return binary.BigEndian.AppendUint64(jd.journalPrefix(), uint64(opID))
+}
+
+/* Cleanup */
+
+func (jd *EmbeddedHandle) cleanupLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jd.conf.cleanupInterval.Load()):
+		}
+		deleted, err := jd.deleteTerminalJobs(ctx, time.Now().Add(-jd.conf.terminalJobsRetention.Load()))
+		if err != nil {
+			if ctx.Err() == nil {
+				jd.logger.Errorn("deleting terminal jobs", obskit.Error(err))
+			}
+			continue
+		}
+		if deleted > 0 {
+			jd.stats.NewTaggedStat("jobsdb_embedded_deleted_jobs", stats.CountType, stats.Tags{"tablePrefix": jd.tablePrefix}).Count(deleted)
+			// reclaim the space of the deleted payloads
+			for {
+				if err := jd.store.db.RunValueLogGC(0.5); err != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// deleteTerminalJobs deletes the jobs whose latest state is a terminal one and which were last updated before the cutoff time
+func (jd *EmbeddedHandle) deleteTerminalJobs(ctx context.Context, cutoffTime time.Time) (int, error) {
+	const batchSize = 1000
+	var deleted int
+	for _, state := range validTerminalStates {
+		for {
+			if err := ctx.Err(); err != nil {
+				return deleted, err
+			}
+			var ids []int64
+			err := jd.store.db.View(func(txn *badger.Txn) error {
+				it := newEmbeddedStateIterator(txn, state, 1)
+				defer it.Close()
+				for ; it.Valid() && len(ids) < batchSize; it.Next() {
+					statuses, err := getEmbeddedStatuses(txn, it.JobID())
+					if err != nil {
+						return err
+					}
+					if len(statuses) > 0 && statuses[len(statuses)-1].ExecTime.Before(cutoffTime) {
+						ids = append(ids, it.JobID())
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return deleted, err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			err = jd.WithTx(func(tx *Tx) error {
+				txn, err := jd.txn(tx)
+				if err != nil {
+					return err
+				}
+				for _, id := range ids {
+					for _, key := range [][]byte{embeddedJobKey(id), embeddedStatusKey(id), embeddedStateKey(state, id)} {
+						if err := txn.Delete(key); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return deleted, err
+			}
+			deleted += len(ids)
+			if len(ids) < batchSize {
+				break
+			}
+		}
+	}
+	return deleted, nil
+}
+
+func (jd *EmbeddedHandle) timer(operation string) stats.Measurement {
+	return jd.stats.NewTaggedStat("jobsdb_embedded_"+operation+"_time", stats.TimerType, stats.Tags{"tablePrefix": jd.tablePrefix})
+}
+
+/* Store */
+
+// embeddedStores are the stores opened in the process, by path
+var embeddedStores = struct {
+	sync.Mutex
+	m map[string]*embeddedStore
+}{m: make(map[string]*embeddedStore)}
+
+// embeddedStore is a badger database shared by all handles of the same table prefix
+type embeddedStore struct {
+	path string
+	db   *badger.DB
+	seq  *badger.Sequence
+	// journalSeq is the sequence of journal operation ids
+	journalSeq *badger.Sequence
+	mu         sync.Mutex // serializes write transactions
+	refs       int
+}
+
+// embeddedValueThreshold is the size above which values are stored in badger's value log instead of its LSM tree.
+// Such values only count as a value pointer towards the size limit of a transaction, so that a transaction's size
+// depends on the number of jobs it writes rather than on their payloads.
+const embeddedValueThreshold = 1024
+
+func openEmbeddedStore(path string, memTableSize int64, log logger.Logger) (*embeddedStore, error) {
+	embeddedStores.Lock()
+	defer embeddedStores.Unlock()
+	if s, ok := embeddedStores.m[path]; ok {
+		s.refs++
+		return s, nil
+	}
+	db, err := badger.Open(badger.DefaultOptions(path).
+		WithMemTableSize(memTableSize).
+		WithValueThreshold(embeddedValueThreshold).
+		WithLogger(embeddedBadgerLogger{log}))
+	if err != nil {
+		return nil, err
+	}
+	seq, err := db.GetSequence([]byte{embeddedSequenceKey}, 1000)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("getting job id sequence: %w", err)
+	}
+	journalSeq, err := db.GetSequence([]byte{embeddedJournalSeqKey}, 10)
+	if err != nil {
+		_ = seq.Release()
+		_ = db.Close()
+		return nil, fmt.Errorf("getting journal operation id sequence: %w", err)
+	}
+	s := &embeddedStore{path: path, db: db, seq: seq, journalSeq: journalSeq, refs: 1}
+	embeddedStores.m[path] = s
+	return s, nil
+}
+
+func (s *embeddedStore) release() error {
+	embeddedStores.Lock()
+	defer embeddedStores.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	delete(embeddedStores.m, s.path)
+	return errors.Join(s.seq.Release(), s.journalSeq.Release(), s.db.Close())
+}
+
+type embeddedBadgerLogger struct {
+	logger.Logger
+}
+
+func (l embeddedBadgerLogger) Warningf(format string, a ...any) {
+	l.Warnf(format, a...)
+}
+
+func (l embeddedBadgerLogger) Infof(format string, a ...any) {
+	l.Debugf(format, a...)
+}
+
+func embeddedJobKey(jobID int64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{embeddedJobKeyPrefix}, uint64(jobID))
+}
+
+func embeddedStatusKey(jobID int64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{embeddedStatusKeyPrefix}, uint64(jobID))
+}
+
+func embeddedStatePrefix(state string) []byte {
+	return append(append([]byte{embeddedStateKeyPrefix}, state...), 0)
+}
+
+func embeddedStateKey(state string, jobID int64) []byte {
+	return binary.BigEndian.AppendUint64(embeddedStatePrefix(state), uint64(jobID))
+}
+
+func getEmbeddedJob(txn embeddedReader, jobID int64) (*JobT, error) {
+	item, err := txn.Get(embeddedJobKey(jobID))
+	if err != nil {
+		return nil, fmt.Errorf("getting job %d: %w", jobID, err)
+	}
+	var job JobT
+	if err := item.Value(func(v []byte) error { return jsonrs.Unmarshal(v, &job) }); err != nil {
+		return nil, fmt.Errorf("unmarshalling job %d: %w", jobID, err)
+	}
+	statuses, err := getEmbeddedStatuses(txn, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) > 0 {
+		job.LastJobStatus = statuses[len(statuses)-1]
+	}
+	job.LastJobStatus.JobParameters = job.Parameters
+	return &job, nil
+}
+
+func getEmbeddedStatuses(txn embeddedReader, jobID int64) ([]JobStatusT, error) {
+	item, err := txn.Get(embeddedStatusKey(jobID))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting statuses of job %d: %w", jobID, err)
+	}
+	var statuses []JobStatusT
+	if err := item.Value(func(v []byte) error { return jsonrs.Unmarshal(v, &statuses) }); err != nil {
+		return nil, fmt.Errorf("unmarshalling statuses of job %d: %w", jobID, err)
+	}
+	return statuses, nil
+}
+
+// setEmbeddedStatuses replaces the statuses of a job, moving it to the state index of its new latest state
+func setEmbeddedStatuses(txn embeddedWriter, jobID int64, previous, statuses []JobStatusT) error {
+	latestState := func(statuses []JobStatusT) string {
+		if len(statuses) == 0 {
+			return Unprocessed.State
+		}
+		return statuses[len(statuses)-1].JobState
+	}
+	if err := txn.Delete(embeddedStateKey(latestState(previous), jobID)); err != nil {
+		return fmt.Errorf("updating state of job %d: %w", jobID, err)
+	}
+	if err := txn.Set(embeddedStateKey(latestState(statuses), jobID), nil); err != nil {
+		return fmt.Errorf("updating state of job %d: %w", jobID, err)
+	}
+	if len(statuses) == 0 {
+		if err := txn.Delete(embeddedStatusKey(jobID)); err != nil {
+			return fmt.Errorf("deleting statuses of job %d: %w", jobID, err)
+		}
+		return nil
+	}
+	value, err := jsonrs.Marshal(statuses)
+	if err != nil {
+		return fmt.Errorf("marshalling statuses of job %d: %w", jobID, err)
+	}
+	if err := txn.Set(embeddedStatusKey(jobID), value); err != nil {
+		return fmt.Errorf("storing statuses of job %d: %w", jobID, err)
+	}
+	return nil
+}
+
+// embeddedStateJobIDs returns the ids of up to limit jobs in a state, in ascending order
+func embeddedStateJobIDs(txn embeddedReader, state string, limit int) []int64 {
+	it := newEmbeddedStateIterator(txn, state, 1)
+	defer it.Close()
+	var ids []int64
+	for ; it.Valid() && len(ids) < limit; it.Next() {
+		ids = append(ids, it.JobID())
+	}
+	return ids
+}
+
+func iterateEmbeddedJobs(ctx context.Context, txn embeddedReader, f func(job *JobT) error) error {
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: []byte{embeddedJobKeyPrefix}})
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var job JobT
+		if err := it.Item().Value(func(v []byte) error { return jsonrs.Unmarshal(v, &job) }); err != nil {
+			return fmt.Errorf("unmarshalling job: %w", err)
+		}
+		if err := f(&job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// embeddedStateIterator iterates over the ids of the jobs in a state, in ascending order
+type embeddedStateIterator struct {
+	it     *badger.Iterator
+	prefix []byte
+}
+
+func newEmbeddedStateIterator(txn embeddedReader, state string, minJobID int64) *embeddedStateIterator {
+	prefix := embeddedStatePrefix(state)
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+	it.Seek(embeddedStateKey(state, minJobID))
+	return &embeddedStateIterator{it: it, prefix: prefix}
+}
+
+func (i *embeddedStateIterator) Valid() bool {
+	return i.it.ValidForPrefix(i.prefix)
+}
+
+func (i *embeddedStateIterator) JobID() int64 {
+	return int64(binary.BigEndian.Uint64(bytes.TrimPrefix(i.it.Item().Key(), i.prefix)))
+}
+
+func (i *embeddedStateIterator) Next() {
+	i.it.Next()
+}
+
+func (i *embeddedStateIterator) Close() {
+	i.it.Close()
+}
@@ -0,0 +1,291 @@
+package jobsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	. "github.com/rudderlabs/rudder-server/utils/tx" //nolint:staticcheck
+)
+
+func TestEmbeddedJobsDB(t *testing.T) {
+	ctx := context.Background()
+	newJob := func(workspaceID, destinationID string, eventCount int) *JobT {
+		return &JobT{
+			UUID:         uuid.New(),
+			UserID:       "user-1",
+			CustomVal:    "GW",
+			EventCount:   eventCount,
+			EventPayload: []byte(`{"key":"value"}`),
+			Parameters:   []byte(fmt.Sprintf(`{"source_id":"source-1","destination_id":%q}`, destinationID)),
+			WorkspaceId:  workspaceID,
+		}
+	}
+	newStatus := func(job *JobT, state string) *JobStatusT {
+		return &JobStatusT{
+			JobID:         job.JobID,
+			JobState:      state,
+			AttemptNum:    1,
+			ExecTime:      time.Now(),
+			RetryTime:     time.Now(),
+			ErrorResponse: []byte(`{}`),
+			Parameters:    []byte(`{}`),
+			WorkspaceId:   job.WorkspaceId,
+		}
+	}
+	start := func(t *testing.T, jd *EmbeddedHandle) *EmbeddedHandle {
+		t.Helper()
+		require.NoError(t, jd.Start())
+		t.Cleanup(jd.TearDown)
+		return jd
+	}
+	jobIDs := func(jobs []*JobT) []int64 {
+		ids := make([]int64, len(jobs))
+		for i := range jobs {
+			ids[i] = jobs[i].JobID
+		}
+		return ids
+	}
+
+	t.Run("returns jobs to process in order, across states", func(t *testing.T) {
+		jd := start(t, NewEmbeddedForReadWrite("rt", WithEmbeddedDir(t.TempDir()), WithEmbeddedStats(stats.NOP)))
+		require.NoError(t, jd.Store(ctx, []*JobT{newJob("ws-1", "d-1", 1), newJob("ws-1", "d-1", 1), newJob("ws-1", "d-1", 1), newJob("ws-1", "d-1", 1)}))
+		unprocessed, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, 2, 3, 4}, jobIDs(unprocessed.Jobs))
+		require.Equal(t, "ws-1", unprocessed.Jobs[0].WorkspaceId)
+		require.JSONEq(t, `{"key":"value"}`, string(unprocessed.Jobs[0].EventPayload))
+
+		jobs := unprocessed.Jobs
+		require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{
+			newStatus(jobs[0], Failed.State),
+			newStatus(jobs[1], Succeeded.State),
+			newStatus(jobs[2], Waiting.State),
+		}, nil, nil))
+
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 2}, nil)
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, 3}, jobIDs(res.Jobs))
+		require.Equal(t, Failed.State, res.Jobs[0].LastJobStatus.JobState)
+		require.True(t, res.LimitsReached)
+
+		res, err = jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 2}, res.More)
+		require.NoError(t, err)
+		require.Equal(t, []int64{4}, jobIDs(res.Jobs))
+		require.False(t, res.LimitsReached)
+
+		succeeded, err := jd.GetSucceeded(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		require.Equal(t, []int64{2}, jobIDs(succeeded.Jobs))
+		require.Equal(t, 1, succeeded.Jobs[0].LastJobStatus.AttemptNum)
+	})
+
+	t.Run("workspace backlogs", func(t *testing.T) {
+		jd := start(t, NewEmbeddedForReadWrite("rt", WithEmbeddedDir(t.TempDir()), WithEmbeddedStats(stats.NOP)))
+		require.NoError(t, jd.Store(ctx, []*JobT{newJob("ws-1", "d-1", 1), newJob("ws-1", "d-1", 1), newJob("ws-1", "d-1", 1), newJob("ws-2", "d-1", 1)}))
+		unprocessed, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		jobs := unprocessed.Jobs
+		require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{
+			newStatus(jobs[0], Failed.State),
+			newStatus(jobs[1], Succeeded.State),
+			newStatus(jobs[3], Aborted.State),
+		}, nil, nil))
+
+		backlogs, err := jd.GetWorkspaceBacklogs(ctx)
+		require.NoError(t, err)
+		require.Equal(t, map[string]WorkspaceBacklog{"ws-1": {Jobs: 2, PayloadBytes: 30}}, backlogs)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		jd := start(t, NewEmbeddedForReadWrite("rt", WithEmbeddedDir(t.TempDir()), WithEmbeddedStats(stats.NOP)))
+		require.NoError(t, jd.Store(ctx, []*JobT{newJob("ws-1", "d-1", 5), newJob("ws-1", "d-1", 5), newJob("ws-1", "d-1", 5)}))
+
+		res, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10, EventsLimit: 7})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 1)
+		require.Equal(t, 5, res.EventsCount)
+		require.True(t, res.LimitsReached)
+
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10, EventsLimit: 2})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 1, "a job exceeding the events limit on its own should be returned")
+
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10, PayloadSizeLimit: 20})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 1)
+		require.EqualValues(t, 15, res.PayloadSize)
+
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 0})
+		require.NoError(t, err)
+		require.Empty(t, res.Jobs)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		jd := start(t, NewEmbeddedForReadWrite("rt", WithEmbeddedDir(t.TempDir()), WithEmbeddedStats(stats.NOP)))
+		require.NoError(t, jd.Store(ctx, []*JobT{newJob("ws-1", "d-1", 1), newJob("ws-2", "d-2", 1), newJob("ws-2", "d-3", 1)}))
+
+		res, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10, WorkspaceID: "ws-2"})
+		require.NoError(t, err)
+		require.Equal(t, []int64{2, 3}, jobIDs(res.Jobs))
+
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: "d-1"}, {Name: "destination_id", Value: "d-3"}}})
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, 3}, jobIDs(res.Jobs))
+
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"BATCH_RT"}})
+		require.NoError(t, err)
+		require.Empty(t, res.Jobs)
+
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"BATCH_RT"}, IgnoreCustomValFiltersInQuery: true})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 3)
+
+		destinations, err := jd.GetDistinctParameterValues(ctx, DestinationID, "GW")
+		require.NoError(t, err)
+		require.Equal(t, []string{"d-1", "d-2", "d-3"}, destinations)
+		workspaces, err := jd.GetDistinctParameterValues(ctx, WorkspaceID, "")
+		require.NoError(t, err)
+		require.Equal(t, []string{"ws-1", "ws-2"}, workspaces)
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		jd := start(t, NewEmbeddedForReadWrite("rt", WithEmbeddedDir(t.TempDir()), WithEmbeddedStats(stats.NOP)))
+
+		errRollback := errors.New("rollback")
+		err := jd.WithStoreSafeTx(ctx, func(tx StoreSafeTx) error {
+			require.Nil(t, tx.SqlTx())
+			require.NoError(t, jd.StoreInTx(ctx, tx, []*JobT{newJob("ws-1", "d-1", 1)}))
+			return errRollback
+		})
+		require.ErrorIs(t, err, errRollback)
+		res, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		require.Empty(t, res.Jobs)
+
+		var committed bool
+		require.NoError(t, jd.WithStoreSafeTx(ctx, func(tx StoreSafeTx) error {
+			tx.Tx().AddSuccessListener(func() { committed = true })
+			failed, err := jd.StoreEachBatchRetryInTx(ctx, tx, [][]*JobT{
+				{newJob("ws-1", "d-1", 1)},
+				{{UUID: uuid.New(), EventPayload: []byte(`{"invalid"`), Parameters: []byte(`{}`)}},
+			})
+			require.NoError(t, err)
+			require.Len(t, failed, 1)
+			return nil
+		}))
+		require.True(t, committed)
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 1)
+
+		require.ErrorIs(t, jd.StoreInTx(ctx, &storeSafeTx{tx: &Tx{}}, []*JobT{newJob("ws-1", "d-1", 1)}), errEmbeddedTxNotFound)
+	})
+
+	t.Run("handles of the same prefix share their jobs, which survive restarts", func(t *testing.T) {
+		dir := t.TempDir()
+		writer := NewEmbeddedForWrite("gw", WithEmbeddedDir(dir), WithEmbeddedStats(stats.NOP))
+		reader := NewEmbeddedForRead("gw", WithEmbeddedDir(dir), WithEmbeddedStats(stats.NOP))
+		require.NoError(t, writer.Start())
+		require.NoError(t, reader.Start())
+		require.NoError(t, writer.Store(ctx, []*JobT{newJob("ws-1", "d-1", 1)}))
+		res, err := reader.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 1)
+		writer.TearDown()
+		reader.TearDown()
+
+		jd := start(t, NewEmbeddedForReadWrite("gw", WithEmbeddedDir(dir), WithEmbeddedStats(stats.NOP)))
+		require.NoError(t, jd.Store(ctx, []*JobT{newJob("ws-1", "d-1", 1)}))
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 2)
+		require.Less(t, res.Jobs[0].JobID, res.Jobs[1].JobID)
+	})
+
+	t.Run("deletes terminal jobs after their retention", func(t *testing.T) {
+		c := config.New()
+		c.Set("JobsDB.embedded.terminalJobsRetention", "1h")
+		c.Set("JobsDB.embedded.cleanupInterval", "10ms")
+		jd := start(t, NewEmbeddedForReadWrite("rt", WithEmbeddedDir(t.TempDir()), WithEmbeddedConfig(c), WithEmbeddedStats(stats.NOP)))
+		require.NoError(t, jd.Store(ctx, []*JobT{newJob("ws-1", "d-1", 1), newJob("ws-1", "d-1", 1), newJob("ws-1", "d-1", 1)}))
+		res, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		old := newStatus(res.Jobs[0], Succeeded.State)
+		old.ExecTime = time.Now().Add(-2 * time.Hour)
+		require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{old, newStatus(res.Jobs[1], Aborted.State)}, nil, nil))
+
+		require.Eventually(t, func() bool {
+			res, err := jd.GetSucceeded(ctx, GetQueryParams{JobsLimit: 10})
+			return err == nil && len(res.Jobs) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		aborted, err := jd.GetAborted(ctx, GetQueryParams{JobsLimit: 10})
+		require.NoError(t, err)
+		require.Len(t, aborted.Jobs, 1, "recent terminal jobs should be kept")
+
+		pileUp := map[string]float64{}
+		require.NoError(t, jd.GetPileUpCounts(ctx, time.Now().Add(time.Minute), func(tablePrefix, workspace, destType string, value float64) {
+			pileUp[workspace+"/"+destType] += value
+		}))
+		require.Equal(t, map[string]float64{"ws-1/GW": 1}, pileUp)
+	})
+
+	t.Run("stores jobs with big payloads in one transaction", func(t *testing.T) {
+		jd := start(t, NewEmbeddedForReadWrite("gw", WithEmbeddedDir(t.TempDir()), WithEmbeddedStats(stats.NOP)))
+		// badger's default transaction size limit is about 10MB, payloads are stored in its value log
+		payload := []byte(`{"data":"` + strings.Repeat("x", 512*1024) + `"}`)
+		jobs := make([]*JobT, 40)
+		for i := range jobs {
+			jobs[i] = newJob("ws-1", "d-1", 1)
+			jobs[i].EventPayload = payload
+		}
+		require.NoError(t, jd.Store(ctx, jobs))
+		res, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 100})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, len(jobs))
+		for i := range res.Jobs {
+			require.Equal(t, int64(i+1), res.Jobs[i].JobID)
+		}
+	})
+
+	t.Run("fails transactions too big for badger as a whole", func(t *testing.T) {
+		c := config.New()
+		c.Set("JobsDB.embedded.memTableSizeInMB", 1)
+		jd := start(t, NewEmbeddedForReadWrite("gw", WithEmbeddedDir(t.TempDir()), WithEmbeddedStats(stats.NOP), WithEmbeddedConfig(c)))
+		jobs := make([]*JobT, 5000)
+		for i := range jobs {
+			jobs[i] = newJob("ws-1", "d-1", 1)
+		}
+		err := jd.Store(ctx, jobs)
+		require.ErrorIs(t, err, errEmbeddedTxnTooBig)
+		res, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 100})
+		require.NoError(t, err)
+		require.Empty(t, res.Jobs, "no job of a failed transaction should be stored")
+
+		require.NoError(t, jd.Store(ctx, jobs[:100]))
+		res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 1000})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 100)
+	})
+
+	t.Run("journal entries are owned by the handle which started them", func(t *testing.T) {
+		dir := t.TempDir()
+		jd := start(t, NewEmbeddedForReadWrite("batch_rt", WithEmbeddedDir(dir), WithEmbeddedStats(stats.NOP)))
+		opID, err := jd.JournalMarkStart(RawDataDestUploadOperation, []byte(`{}`))
+		require.NoError(t, err)
+
+		reader := start(t, NewEmbeddedForRead("batch_rt", WithEmbeddedDir(dir), WithEmbeddedStats(stats.NOP)))
+		require.Empty(t, reader.GetJournalEntries(RawDataDestUploadOperation))
+		entries := jd.GetJournalEntries(RawDataDestUploadOperation)
+		require.Len(t, entries, 1)
+		require.Equal(t, opID, entries[0].OpID)
+	})
+}
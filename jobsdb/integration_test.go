@@ -347,70 +347,6 @@ func TestJobsDB(t *testing.T) {
 		require.Equal(t, 3, len(payloadLimitList.Jobs))
 	})
 
-	t.Run("querying with an payload size limit should return at least one job even if limit is exceeded", func(t *testing.T) {
-		customVal := "MOCKDS"
-		triggerAddNewDS := make(chan time.Time)
-
-		c.Set("jobsdb.maxDSSize", 1)
-		jobDB := Handle{
-			TriggerAddNewDS: func() <-chan time.Time {
-				return triggerAddNewDS
-			},
-			config: c,
-		}
-		err := jobDB.Setup(ReadWrite, false, strings.ToLower(rand.String(5)))
-		require.NoError(t, err)
-		defer jobDB.TearDown()
-
-		jobs := genJobs(defaultWorkspaceID, customVal, 2, 1)
-		require.NoError(t, jobDB.Store(context.Background(), jobs))
-		payloadSize, err := getPayloadSize(t, &jobDB, jobs[0])
-		require.NoError(t, err)
-
-		payloadLimit := payloadSize / 2
-		payloadLimitList, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{
-			CustomValFilters: []string{customVal},
-			JobsLimit:        100,
-			PayloadSizeLimit: payloadLimit,
-			ParameterFilters: []ParameterFilterT{},
-		})
-		require.NoError(t, err, "GetUnprocessed failed")
-
-		requireSequential(t, payloadLimitList.Jobs)
-		require.Equal(t, 1, len(payloadLimitList.Jobs))
-	})
-
-	t.Run("querying with an event count limit should return at least one job even if limit is exceeded", func(t *testing.T) {
-		customVal := "MOCKDS"
-		triggerAddNewDS := make(chan time.Time)
-
-		c.Set("jobsdb.maxDSSize", 1)
-		jobDB := Handle{
-			config: c,
-			TriggerAddNewDS: func() <-chan time.Time {
-				return triggerAddNewDS
-			},
-		}
-		err := jobDB.Setup(ReadWrite, true, strings.ToLower(rand.String(5)))
-		require.NoError(t, err)
-		defer jobDB.TearDown()
-
-		jobs := genJobs(defaultWorkspaceID, customVal, 2, 4)
-		require.NoError(t, jobDB.Store(context.Background(), jobs))
-
-		eventCountLimit := 1
-		eventLimitList, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{
-			CustomValFilters: []string{customVal},
-			JobsLimit:        100,
-			EventsLimit:      eventCountLimit,
-			ParameterFilters: []ParameterFilterT{},
-		})
-		require.NoError(t, err, "GetUnprocessed failed")
-
-		requireSequential(t, eventLimitList.Jobs)
-		require.Equal(t, 1, len(eventLimitList.Jobs))
-	})
-
 	t.Run("should stay within event count limits", func(t *testing.T) {
 		customVal := "MOCKDS"
 		triggerAddNewDS := make(chan time.Time)
@@ -899,85 +835,75 @@ func TestJobsDB(t *testing.T) {
 }
 
 func TestMultiTenantLegacyGetAllJobs(t *testing.T) {
-	_ = startPostgres(t)
-	triggerAddNewDS := make(chan time.Time)
-	config.Reset()
-	c := config.New()
-	c.Set("jobsdb.maxDSSize", 10)
-	jobDB := Handle{
-		config: c,
-		TriggerAddNewDS: func() <-chan time.Time {
-			return triggerAddNewDS
-		},
-	}
-
-	customVal := "MTL"
-	err := jobDB.Setup(ReadWrite, false, strings.ToLower(rand.String(5)))
-	require.NoError(t, err)
-	defer jobDB.TearDown()
-
-	eventsPerJob := 10
-	// Create 30 jobs
-	jobs := genJobs(defaultWorkspaceID, customVal, 30, eventsPerJob)
-	require.NoError(t, jobDB.Store(context.Background(), jobs))
-	payloadSize, err := getPayloadSize(t, &jobDB, jobs[0])
-	require.NoError(t, err)
-	j, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{JobsLimit: 100}) // read to get Ids
-	require.NoError(t, err, "failed to get unprocessed jobs")
-	jobs = j.Jobs
-	require.Equal(t, 30, len(jobs), "should get all 30 jobs")
-
-	// Mark 1-10 as failed
-	require.NoError(t, jobDB.UpdateJobStatus(context.Background(), genJobStatuses(jobs[0:10], Failed.State), []string{customVal}, []ParameterFilterT{}))
-
-	// Mark 11-20 as waiting
-	require.NoError(t, jobDB.UpdateJobStatus(context.Background(), genJobStatuses(jobs[10:20], Waiting.State), []string{customVal}, []ParameterFilterT{}))
+	forEachBackend(t, func(t *testing.T, newJobsDB func(prefix string) testJobsDB) {
+		customVal := "MTL"
+		jobDB := newJobsDB(strings.ToLower(rand.String(5)))
+		defer jobDB.TearDown()
 
-	t.Run("GetAllJobs with large limits", func(t *testing.T) {
-		params := GetQueryParams{JobsLimit: 30}
-		allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
-		require.NoError(t, err, "failed to get all jobs")
-		require.Equal(t, 30, len(allJobs.Jobs), "should get all 30 jobs")
-	})
+		eventsPerJob := 10
+		// Create 30 jobs
+		jobs := genJobs(defaultWorkspaceID, customVal, 30, eventsPerJob)
+		require.NoError(t, jobDB.Store(context.Background(), jobs))
+		first, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{JobsLimit: 1}) // the payload size of a job, as accounted by the backend
+		require.NoError(t, err)
+		payloadSize := first.PayloadSize
+		j, err := jobDB.GetUnprocessed(context.Background(), GetQueryParams{JobsLimit: 100}) // read to get Ids
+		require.NoError(t, err, "failed to get unprocessed jobs")
+		jobs = j.Jobs
+		require.Equal(t, 30, len(jobs), "should get all 30 jobs")
+
+		// Mark 1-10 as failed
+		require.NoError(t, jobDB.UpdateJobStatus(context.Background(), genJobStatuses(jobs[0:10], Failed.State), []string{customVal}, []ParameterFilterT{}))
+
+		// Mark 11-20 as waiting
+		require.NoError(t, jobDB.UpdateJobStatus(context.Background(), genJobStatuses(jobs[10:20], Waiting.State), []string{customVal}, []ParameterFilterT{}))
+
+		t.Run("GetAllJobs with large limits", func(t *testing.T) {
+			params := GetQueryParams{JobsLimit: 30}
+			allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
+			require.NoError(t, err, "failed to get all jobs")
+			require.Equal(t, 30, len(allJobs.Jobs), "should get all 30 jobs")
+		})
 
-	t.Run("GetAllJobs with only jobs limit", func(t *testing.T) {
-		jobsLimit := 10
-		params := GetQueryParams{JobsLimit: jobsLimit}
-		allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
-		require.NoError(t, err, "failed to get all jobs")
-		require.Truef(t, len(allJobs.Jobs)-jobsLimit == 0, "should get %d jobs", jobsLimit)
-	})
+		t.Run("GetAllJobs with only jobs limit", func(t *testing.T) {
+			jobsLimit := 10
+			params := GetQueryParams{JobsLimit: jobsLimit}
+			allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
+			require.NoError(t, err, "failed to get all jobs")
+			require.Truef(t, len(allJobs.Jobs)-jobsLimit == 0, "should get %d jobs", jobsLimit)
+		})
 
-	t.Run("GetAllJobs with events limit", func(t *testing.T) {
-		jobsLimit := 10
-		params := GetQueryParams{JobsLimit: jobsLimit, EventsLimit: 3 * eventsPerJob}
-		allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
-		require.NoError(t, err, "failed to get all jobs")
-		require.Equal(t, 3, len(allJobs.Jobs), "should get 3 jobs")
-	})
+		t.Run("GetAllJobs with events limit", func(t *testing.T) {
+			jobsLimit := 10
+			params := GetQueryParams{JobsLimit: jobsLimit, EventsLimit: 3 * eventsPerJob}
+			allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
+			require.NoError(t, err, "failed to get all jobs")
+			require.Equal(t, 3, len(allJobs.Jobs), "should get 3 jobs")
+		})
 
-	t.Run("GetAllJobs with events limit less than the events of the first job get one job", func(t *testing.T) {
-		jobsLimit := 10
-		params := GetQueryParams{JobsLimit: jobsLimit, EventsLimit: eventsPerJob - 1}
-		allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
-		require.NoError(t, err, "failed to get all jobs")
-		require.Equal(t, 1, len(allJobs.Jobs), "should get 1 overflown job")
-	})
+		t.Run("GetAllJobs with events limit less than the events of the first job get one job", func(t *testing.T) {
+			jobsLimit := 10
+			params := GetQueryParams{JobsLimit: jobsLimit, EventsLimit: eventsPerJob - 1}
+			allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
+			require.NoError(t, err, "failed to get all jobs")
+			require.Equal(t, 1, len(allJobs.Jobs), "should get 1 overflown job")
+		})
 
-	t.Run("GetAllJobs with payload limit", func(t *testing.T) {
-		jobsLimit := 10
-		params := GetQueryParams{JobsLimit: jobsLimit, PayloadSizeLimit: 3 * payloadSize}
-		allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
-		require.NoError(t, err, "failed to get all jobs")
-		require.Equal(t, 3, len(allJobs.Jobs), "should get 3 jobs")
-	})
+		t.Run("GetAllJobs with payload limit", func(t *testing.T) {
+			jobsLimit := 10
+			params := GetQueryParams{JobsLimit: jobsLimit, PayloadSizeLimit: 3 * payloadSize}
+			allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
+			require.NoError(t, err, "failed to get all jobs")
+			require.Equal(t, 3, len(allJobs.Jobs), "should get 3 jobs")
+		})
 
-	t.Run("GetAllJobs with payload limit less than the payload size should get one job", func(t *testing.T) {
-		jobsLimit := 10
-		params := GetQueryParams{JobsLimit: jobsLimit, PayloadSizeLimit: payloadSize - 1}
-		allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
-		require.NoError(t, err, "failed to get all jobs")
-		require.Equal(t, 1, len(allJobs.Jobs), "should get 1 overflown job")
+		t.Run("GetAllJobs with payload limit less than the payload size should get one job", func(t *testing.T) {
+			jobsLimit := 10
+			params := GetQueryParams{JobsLimit: jobsLimit, PayloadSizeLimit: payloadSize - 1}
+			allJobs, err := jobDB.GetToProcess(context.Background(), params, nil)
+			require.NoError(t, err, "failed to get all jobs")
+			require.Equal(t, 1, len(allJobs.Jobs), "should get 1 overflown job")
+		})
 	})
 }
 
@@ -1249,14 +1175,28 @@ func TestJobsdbSanitizeJSON(t *testing.T) {
 		},
 	}
 
+	// the embedded backend stores payloads as json, thus it sanitizes them like jsonb
+	UTF8Tests = append(UTF8Tests, struct {
+		payloadColumnType string
+		cases             []testCase
+	}{BackendEmbedded, UTF8Tests[0].cases})
+
 	for _, tCase := range UTF8Tests {
 		t.Run(tCase.payloadColumnType, func(t *testing.T) {
-			_ = startPostgres(t)
-			conf := config.New()
-			jobDB := Handle{config: conf}
-			jobDB.conf.payloadColumnType = payloadColumnType(tCase.payloadColumnType)
-			err := jobDB.Setup(ReadWrite, true, tCase.payloadColumnType+"_"+strings.ToLower(rand.String(5)))
-			require.NoError(t, err, tCase.payloadColumnType)
+			var jobDB testJobsDB
+			if tCase.payloadColumnType == BackendEmbedded {
+				embedded := NewEmbeddedForReadWrite(strings.ToLower(rand.String(5)), WithEmbeddedDir(t.TempDir()))
+				require.NoError(t, embedded.Start())
+				jobDB = embedded
+			} else {
+				_ = startPostgres(t)
+				conf := config.New()
+				pg := &Handle{config: conf}
+				pg.conf.payloadColumnType = payloadColumnType(tCase.payloadColumnType)
+				err := pg.Setup(ReadWrite, true, tCase.payloadColumnType+"_"+strings.ToLower(rand.String(5)))
+				require.NoError(t, err, tCase.payloadColumnType)
+				jobDB = pg
+			}
 			eventPayload := []byte(`{"batch":[{"anonymousId":"anon_id","sentAt":"2019-08-12T05:08:30.909Z","type":"track"}]}`)
 			for i, tt := range tCase.cases {
 				customVal := fmt.Sprintf("TEST_%d", i)
@@ -1385,6 +1325,30 @@ func GenerateFuzzCorpus() []string {
 }
 
 func Test_FuzzTestStore(t *testing.T) {
+	store := func(t *testing.T, jobDB JobsDB) {
+		testPayloads := GenerateFuzzCorpus()
+		for i, payload := range testPayloads {
+			jobs := []*JobT{{
+				Parameters:   []byte(`{"batch_id":1,"source_id":"sourceID","source_job_run_id":""}`),
+				EventPayload: []byte(payload),
+				UserID:       uuid.New().String(),
+				UUID:         uuid.New(),
+				CustomVal:    fmt.Sprintf("TEST_%d", i),
+				WorkspaceId:  defaultWorkspaceID,
+				EventCount:   1,
+			}}
+			err := jobDB.Store(context.Background(), jobs)
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("Store with embedded backend", func(t *testing.T) {
+		jobDB := NewEmbeddedForReadWrite(strings.ToLower(rand.String(5)), WithEmbeddedDir(t.TempDir()))
+		require.NoError(t, jobDB.Start())
+		store(t, jobDB)
+		jobDB.TearDown()
+	})
+
 	_ = startPostgres(t)
 	conf := config.New()
 	columnTypes := []string{"jsonb", "text", "bytea"}
@@ -1394,20 +1358,7 @@ func Test_FuzzTestStore(t *testing.T) {
 			jobDB := Handle{config: conf}
 			err := jobDB.Setup(ReadWrite, true, column+"_"+strings.ToLower(rand.String(5)))
 			require.NoError(t, err)
-			testPayloads := GenerateFuzzCorpus()
-			for i, payload := range testPayloads {
-				jobs := []*JobT{{
-					Parameters:   []byte(`{"batch_id":1,"source_id":"sourceID","source_job_run_id":""}`),
-					EventPayload: []byte(payload),
-					UserID:       uuid.New().String(),
-					UUID:         uuid.New(),
-					CustomVal:    fmt.Sprintf("TEST_%d", i),
-					WorkspaceId:  defaultWorkspaceID,
-					EventCount:   1,
-				}}
-				err := jobDB.Store(context.Background(), jobs)
-				require.NoError(t, err)
-			}
+			store(t, &jobDB)
 			jobDB.TearDown()
 		})
 	}
@@ -0,0 +1,656 @@
+package jobsdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/filemanager"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+)
+
+const (
+	defaultJobsSearchLimit = 100
+	maxJobsSearchLimit     = 1000
+)
+
+// JobsSearchInput is the input of JobsAdmin.Search. All non-empty criteria need to be satisfied by a job for it to be returned.
+type JobsSearchInput struct {
+	TablePrefix   string    // the jobsdb to search in, e.g. gw, rt, batch_rt
+	WorkspaceID   string    // optional
+	SourceID      string    // optional, matched against the source_id parameter of the job
+	DestinationID string    // optional, matched against the destination_id parameter of the job
+	States        []string  // optional, the latest state of the job, use not_picked_yet for jobs without any status
+	From          time.Time // optional, jobs created at or after this time
+	To            time.Time // optional, jobs created before this time
+	PayloadPath   string    // optional, a dot separated path in the event payload, e.g. context.traits.email
+	PayloadValue  string    // optional, the value expected at PayloadPath. If empty, the path only needs to exist
+	Limit         int       // maximum number of jobs to return, newest jobs first
+}
+
+// JobSummary is a job returned by JobsAdmin.Search along with its latest status
+type JobSummary struct {
+	Dataset       string
+	JobID         int64
+	UUID          string
+	UserID        string
+	CustomVal     string
+	WorkspaceID   string
+	SourceID      string
+	DestinationID string
+	EventCount    int
+	CreatedAt     time.Time
+	State         string
+	AttemptNum    int
+	ExecTime      time.Time
+	ErrorCode     string
+}
+
+// JobInspectInput is the input of JobsAdmin.Inspect
+type JobInspectInput struct {
+	TablePrefix string
+	JobID       int64
+}
+
+// JobDetails is a job returned by JobsAdmin.Inspect along with all of its statuses, oldest first
+type JobDetails struct {
+	Dataset  string
+	Job      JobT
+	Statuses []JobStatusT
+}
+
+// JobsRequeueInput is the input of JobsAdmin.Requeue
+type JobsRequeueInput struct {
+	TablePrefix string
+	JobIDs      []int64
+	State       string // waiting or failed
+	Note        string // audit note, stored in the error response of the new status
+}
+
+// JobsRequeueResult is the output of JobsAdmin.Requeue
+type JobsRequeueResult struct {
+	Requeued []int64
+	Skipped  map[int64]string // job id => reason
+}
+
+// JobsExportInput is the input of JobsAdmin.Export
+type JobsExportInput struct {
+	TablePrefix  string
+	WorkspaceID  string
+	Path         string // absolute path of the export file on the server, overwritten if it exists
+	MarkMigrated bool   // mark the exported jobs as migrated, so that they are not processed by this data plane anymore
+}
+
+// JobsExportResult is the output of JobsAdmin.Export
+type JobsExportResult struct {
+	Jobs     int // number of jobs exported
+	Migrated int // number of exported jobs marked as migrated
+}
+
+// JobsImportInput is the input of JobsAdmin.Import
+type JobsImportInput struct {
+	TablePrefix string
+	Path        string // absolute path of the export file on the server
+}
+
+// JobsImportResult is the output of JobsAdmin.Import
+type JobsImportResult struct {
+	Jobs    int // number of jobs imported
+	Skipped int // number of jobs skipped, since they were already imported
+}
+
+// JobsSnapshotsInput is the input of JobsAdmin.Snapshots
+type JobsSnapshotsInput struct {
+	TablePrefix string
+	WorkspaceID string
+}
+
+// JobsSnapshot is a snapshot returned by JobsAdmin.Snapshots
+type JobsSnapshot struct {
+	ObjectName   string
+	LastModified time.Time
+}
+
+// JobsRestoreInput is the input of JobsAdmin.Restore
+type JobsRestoreInput struct {
+	TablePrefix string
+	WorkspaceID string
+	ObjectName  string   // the object name of the snapshot, as returned by JobsAdmin.Snapshots
+	States      []string // optional, the states of the jobs to restore at the time of the snapshot, use not_picked_yet for jobs without any status. Defaults to all but succeeded, filtered and migrated
+}
+
+// JobsRestoreResult is the output of JobsAdmin.Restore
+type JobsRestoreResult struct {
+	Jobs int // number of jobs restored
+}
+
+// JobsAdmin exposes admin functions for searching, inspecting and requeuing individual jobs across the datasets of jobsdb handles.
+//
+// It is meant to be registered through admin.RegisterAdminHandler so that it can be used by rudder-cli.
+// The handles passed to it should be the same ones used by the components reading the jobs, so that
+// requeued jobs invalidate their caches.
+type JobsAdmin struct {
+	handles         map[string]*Handle
+	timeout         time.Duration
+	transferTimeout time.Duration // for exports and imports
+	logger          logger.Logger
+}
+
+// NewJobsAdmin creates a new JobsAdmin for the provided handles. If more than one handles share the same table prefix, the first one is used.
+func NewJobsAdmin(handles ...*Handle) *JobsAdmin {
+	a := &JobsAdmin{
+		handles:         make(map[string]*Handle),
+		timeout:         config.GetDuration("JobsDB.admin.timeout", 60, time.Second),
+		transferTimeout: config.GetDuration("JobsDB.admin.transferTimeout", 30, time.Minute),
+		logger:          logger.NewLogger().Child("jobsdb").Child("admin"),
+	}
+	for _, h := range handles {
+		if _, ok := a.handles[h.tablePrefix]; !ok {
+			a.handles[h.tablePrefix] = h
+		}
+	}
+	return a
+}
+
+// isAdminJobState returns whether state is a valid job state, or not_picked_yet for jobs without any status
+func isAdminJobState(state string) bool {
+	return slices.ContainsFunc(jobStates, func(js jobStateT) bool { return js.State == state })
+}
+
+func (a *JobsAdmin) handle(tablePrefix string) (*Handle, error) {
+	h, ok := a.handles[tablePrefix]
+	if !ok {
+		return nil, fmt.Errorf("unknown jobsdb %q, valid ones are: %s", tablePrefix, strings.Join(lo.Keys(a.handles), ", "))
+	}
+	return h, nil
+}
+
+// Search returns the jobs matching the search criteria, newest jobs first
+func (a *JobsAdmin) Search(input JobsSearchInput, reply *[]JobSummary) error {
+	jd, err := a.handle(input.TablePrefix)
+	if err != nil {
+		return err
+	}
+	for _, state := range input.States {
+		if !isAdminJobState(state) {
+			return fmt.Errorf("invalid job state %q", state)
+		}
+	}
+	if input.Limit <= 0 {
+		input.Limit = defaultJobsSearchLimit
+	}
+	input.Limit = min(input.Limit, maxJobsSearchLimit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+	*reply, err = jd.searchJobs(ctx, input)
+	return err
+}
+
+// Inspect returns a job along with its full status history
+func (a *JobsAdmin) Inspect(input JobInspectInput, reply *JobDetails) error {
+	jd, err := a.handle(input.TablePrefix)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+	return jd.inUpdateSafeCtx(ctx, func(dsList []dataSetT, _ []dataSetRangeT) error {
+		details, err := jd.inspectJob(ctx, jd.dbHandle, dsList, input.JobID)
+		if err != nil {
+			return err
+		}
+		*reply = *details
+		return nil
+	})
+}
+
+// Requeue adds a new status with the requested state to aborted jobs, so that they can be picked up again.
+// Jobs that are not found or whose latest state is not aborted are skipped.
+func (a *JobsAdmin) Requeue(input JobsRequeueInput, reply *JobsRequeueResult) error {
+	jd, err := a.handle(input.TablePrefix)
+	if err != nil {
+		return err
+	}
+	if input.State != Waiting.State && input.State != Failed.State {
+		return fmt.Errorf("jobs can only be requeued to %s or %s, not %q", Waiting.State, Failed.State, input.State)
+	}
+	if strings.TrimSpace(input.Note) == "" {
+		return errors.New("please provide a note explaining why the jobs are requeued")
+	}
+	errorResponse, err := json.Marshal(map[string]string{
+		"reason":        "requeued by admin",
+		"note":          input.Note,
+		"previousState": Aborted.State,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+	result := JobsRequeueResult{Skipped: make(map[int64]string)}
+	err = jd.WithUpdateSafeTx(ctx, func(tx UpdateSafeTx) error {
+		statusesByCustomVal := make(map[string][]*JobStatusT)
+		for _, jobID := range input.JobIDs {
+			details, err := jd.inspectJob(ctx, tx.SqlTx(), tx.getDSList(), jobID)
+			if err != nil {
+				if errors.Is(err, errJobNotFound) {
+					result.Skipped[jobID] = "job not found"
+					continue
+				}
+				return err
+			}
+			if details.Job.LastJobStatus.JobState != Aborted.State {
+				result.Skipped[jobID] = fmt.Sprintf("latest state is %q, only aborted jobs can be requeued", lo.CoalesceOrEmpty(details.Job.LastJobStatus.JobState, Unprocessed.State))
+				continue
+			}
+			parameters := details.Job.LastJobStatus.Parameters
+			if len(parameters) == 0 {
+				parameters = []byte(`{}`)
+			}
+			now := time.Now()
+			statusesByCustomVal[details.Job.CustomVal] = append(statusesByCustomVal[details.Job.CustomVal], &JobStatusT{
+				JobID:         jobID,
+				JobState:      input.State,
+				AttemptNum:    0,
+				ExecTime:      now,
+				RetryTime:     now,
+				ErrorCode:     "",
+				ErrorResponse: errorResponse,
+				Parameters:    parameters,
+				JobParameters: details.Job.Parameters,
+				WorkspaceId:   details.Job.WorkspaceId,
+			})
+			result.Requeued = append(result.Requeued, jobID)
+		}
+		for customVal, statuses := range statusesByCustomVal {
+			if err := jd.UpdateJobStatusInTx(ctx, tx, statuses, []string{customVal}, nil); err != nil {
+				return fmt.Errorf("updating job statuses: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	a.logger.Infon("Requeued aborted jobs",
+		logger.NewStringField("tablePrefix", input.TablePrefix),
+		logger.NewStringField("state", input.State),
+		logger.NewStringField("note", input.Note),
+		logger.NewStringField("jobIDs", fmt.Sprint(result.Requeued)),
+		logger.NewIntField("skipped", int64(len(result.Skipped))),
+	)
+	jd.stats.NewTaggedStat("jobsdb_admin_requeued_jobs", stats.CountType, stats.Tags{"customVal": jd.tablePrefix, "state": input.State}).Count(len(result.Requeued))
+	*reply = result
+	return nil
+}
+
+// Export writes the pending jobs of a workspace, along with their latest status, to a portable gzip compressed file, so that they can be imported into another data plane.
+// The workspace should not be processed while its jobs are exported.
+// Jobs are exported in pages, each one marked as migrated along with getting exported if MarkMigrated is set.
+func (a *JobsAdmin) Export(input JobsExportInput, reply *JobsExportResult) error {
+	jd, err := a.handle(input.TablePrefix)
+	if err != nil {
+		return err
+	}
+	if input.WorkspaceID == "" {
+		return errors.New("please provide the workspace to export")
+	}
+	if !filepath.IsAbs(input.Path) {
+		return fmt.Errorf("export path %q is not absolute", input.Path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.transferTimeout)
+	defer cancel()
+	var migratedResponse json.RawMessage
+	if input.MarkMigrated {
+		if migratedResponse, err = json.Marshal(map[string]string{"reason": "exported by admin", "path": input.Path}); err != nil {
+			return err
+		}
+	}
+	exported, migrated, err := jd.exportJobs(ctx, input.WorkspaceID, input.Path, migratedResponse)
+	if err != nil {
+		return err
+	}
+	result := JobsExportResult{Jobs: exported, Migrated: migrated}
+	a.logger.Infon("Exported pending jobs",
+		logger.NewStringField("tablePrefix", input.TablePrefix),
+		logger.NewStringField("workspaceId", input.WorkspaceID),
+		logger.NewStringField("path", input.Path),
+		logger.NewIntField("jobs", int64(result.Jobs)),
+		logger.NewBoolField("markMigrated", input.MarkMigrated),
+	)
+	jd.stats.NewTaggedStat("jobsdb_admin_exported_jobs", stats.CountType, stats.Tags{"customVal": jd.tablePrefix, "workspaceId": input.WorkspaceID}).Count(result.Jobs)
+	*reply = result
+	return nil
+}
+
+// Import stores the jobs of a file created by Export, preserving their state, attempts and parameters.
+// Jobs get new job ids and jobs that were executing while exported are imported as failed.
+// Jobs which were already imported are skipped, so that a failed import can be retried with the same file.
+func (a *JobsAdmin) Import(input JobsImportInput, reply *JobsImportResult) error {
+	jd, err := a.handle(input.TablePrefix)
+	if err != nil {
+		return err
+	}
+	if jd.ownerType != ReadWrite {
+		return fmt.Errorf("jobs cannot be imported into %q, it is not a read-write jobsdb", input.TablePrefix)
+	}
+	f, err := os.Open(input.Path)
+	if err != nil {
+		return fmt.Errorf("opening export file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.transferTimeout)
+	defer cancel()
+	imported, skipped, err := jd.importJobs(ctx, f, nil, true)
+	a.logger.Infon("Imported jobs",
+		logger.NewStringField("tablePrefix", input.TablePrefix),
+		logger.NewStringField("path", input.Path),
+		logger.NewIntField("jobs", int64(imported)),
+		logger.NewIntField("skipped", int64(skipped)),
+	)
+	jd.stats.NewTaggedStat("jobsdb_admin_imported_jobs", stats.CountType, stats.Tags{"customVal": jd.tablePrefix}).Count(imported)
+	if err != nil {
+		return fmt.Errorf("importing jobs, %d jobs were imported: %w", imported, err)
+	}
+	*reply = JobsImportResult{Jobs: imported, Skipped: skipped}
+	return nil
+}
+
+// searchJobs searches the datasets of the handle, starting from the newest one, until enough jobs are found
+func (jd *Handle) searchJobs(ctx context.Context, input JobsSearchInput) ([]JobSummary, error) {
+	jobs := make([]JobSummary, 0)
+	err := jd.inUpdateSafeCtx(ctx, func(dsList []dataSetT, _ []dataSetRangeT) error {
+		payloadTypes, err := payloadColumnTypes(ctx, jd.dbHandle, dsList)
+		if err != nil {
+			return err
+		}
+		for i := len(dsList) - 1; i >= 0 && len(jobs) < input.Limit; i-- {
+			ds := dsList[i]
+			dsJobs, err := jd.searchJobsDS(ctx, ds, payloadTypes[ds.JobTable], input, input.Limit-len(jobs))
+			if err != nil {
+				return fmt.Errorf("searching jobs in %s: %w", ds.JobTable, err)
+			}
+			jobs = append(jobs, dsJobs...)
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// searchJobsDS searches the jobs of a dataset. Payloads of compressed datasets cannot be queried by the database,
+// so they are filtered by us instead, reading the dataset's jobs in pages.
+func (jd *Handle) searchJobsDS(ctx context.Context, ds dataSetT, payloadType string, input JobsSearchInput, limit int) ([]JobSummary, error) {
+	encoding, err := jd.payloadEncodingOf(ctx, jd.dbHandle, ds)
+	if err != nil {
+		return nil, err
+	}
+	filterPayloads := input.PayloadPath != "" && encoding.compressed
+
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if input.WorkspaceID != "" {
+		addCondition("j.workspace_id = $%d", input.WorkspaceID)
+	}
+	if input.SourceID != "" {
+		addCondition("j.parameters->>'source_id' = $%d", input.SourceID)
+	}
+	if input.DestinationID != "" {
+		addCondition("j.parameters->>'destination_id' = $%d", input.DestinationID)
+	}
+	if len(input.States) > 0 {
+		addCondition("COALESCE(s.job_state, '"+Unprocessed.State+"') = ANY($%d)", pq.Array(input.States))
+	}
+	if !input.From.IsZero() {
+		addCondition("j.created_at >= $%d", input.From)
+	}
+	if !input.To.IsZero() {
+		addCondition("j.created_at < $%d", input.To)
+	}
+	if input.PayloadPath != "" && !filterPayloads {
+		payload, err := getColumnConversion(payloadType, string(JSONB))
+		if err != nil {
+			return nil, err
+		}
+		path := pq.Array(strings.Split(input.PayloadPath, "."))
+		if input.PayloadValue == "" {
+			addCondition("("+payload+") #> $%d IS NOT NULL", path)
+		} else {
+			args = append(args, path, input.PayloadValue)
+			conditions = append(conditions, fmt.Sprintf("(%s) #>> $%d = $%d", payload, len(args)-1, len(args)))
+		}
+	}
+	pageSize := limit
+	payloadColumn := "NULL::bytea"
+	if filterPayloads {
+		pageSize = max(limit, defaultJobsSearchLimit)
+		payloadColumn = "j.event_payload"
+		args = append(args, int64(math.MaxInt64))
+		conditions = append(conditions, fmt.Sprintf("j.job_id < $%d", len(args)))
+	}
+	cursor := len(args) - 1
+	var where string
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, pageSize)
+	query := fmt.Sprintf(`SELECT
+			j.job_id, j.uuid, j.user_id, j.custom_val, j.workspace_id,
+			COALESCE(j.parameters->>'source_id', ''), COALESCE(j.parameters->>'destination_id', ''),
+			j.event_count, j.created_at,
+			COALESCE(s.job_state, '%[3]s'), COALESCE(s.attempt, 0), s.exec_time, COALESCE(s.error_code, ''),
+			%[6]s
+		FROM %[1]q j LEFT JOIN "v_last_%[2]s" s ON j.job_id = s.job_id
+		%[4]s
+		ORDER BY j.job_id DESC
+		LIMIT $%[5]d`,
+		ds.JobTable, ds.JobStatusTable, Unprocessed.State, where, len(args), payloadColumn)
+
+	var jobs []JobSummary
+	for {
+		var read int
+		err := func() error {
+			rows, err := jd.dbHandle.QueryContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = rows.Close() }()
+			for rows.Next() {
+				read++
+				job := JobSummary{Dataset: ds.Index}
+				var execTime sql.NullTime
+				var payload []byte
+				if err := rows.Scan(
+					&job.JobID, &job.UUID, &job.UserID, &job.CustomVal, &job.WorkspaceID,
+					&job.SourceID, &job.DestinationID,
+					&job.EventCount, &job.CreatedAt,
+					&job.State, &job.AttemptNum, &execTime, &job.ErrorCode,
+					&payload,
+				); err != nil {
+					return err
+				}
+				job.ExecTime = execTime.Time
+				if filterPayloads {
+					args[cursor] = job.JobID
+					if payload, err = jd.payloadCodec.decode(ctx, payload); err != nil {
+						return fmt.Errorf("job %d: %w", job.JobID, err)
+					}
+					value := gjson.GetBytes(payload, input.PayloadPath)
+					if !value.Exists() || value.Type == gjson.Null || (input.PayloadValue != "" && value.String() != input.PayloadValue) {
+						continue
+					}
+				}
+				if len(jobs) < limit {
+					jobs = append(jobs, job)
+				}
+			}
+			return rows.Err()
+		}()
+		if err != nil {
+			return nil, err
+		}
+		if !filterPayloads || read < pageSize || len(jobs) >= limit {
+			return jobs, nil
+		}
+	}
+}
+
+var errJobNotFound = errors.New("job not found")
+
+type queryContexter interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// inspectJob looks up a job in the provided datasets, returning it along with all of its statuses
+func (jd *Handle) inspectJob(ctx context.Context, db queryContexter, dsList []dataSetT, jobID int64) (*JobDetails, error) {
+	for _, ds := range dsList {
+		var job JobT
+		err := db.QueryRowContext(ctx, fmt.Sprintf(`SELECT job_id, uuid, user_id, custom_val, event_count, event_payload, parameters, created_at, expire_at, workspace_id
+			FROM %q WHERE job_id = $1`, ds.JobTable), jobID).
+			Scan(&job.JobID, &job.UUID, &job.UserID, &job.CustomVal, &job.EventCount, &job.EventPayload, &job.Parameters, &job.CreatedAt, &job.ExpireAt, &job.WorkspaceId)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("getting job %d from %s: %w", jobID, ds.JobTable, err)
+		}
+		if job.EventPayload, err = jd.payloadCodec.decode(ctx, job.EventPayload); err != nil {
+			return nil, fmt.Errorf("job %d: %w", jobID, err)
+		}
+
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT job_state, attempt, exec_time, retry_time, error_code, error_response, parameters
+			FROM %q WHERE job_id = $1 ORDER BY id ASC`, ds.JobStatusTable), jobID)
+		if err != nil {
+			return nil, fmt.Errorf("getting statuses of job %d from %s: %w", jobID, ds.JobStatusTable, err)
+		}
+		defer func() { _ = rows.Close() }()
+		statuses := make([]JobStatusT, 0)
+		for rows.Next() {
+			status := JobStatusT{JobID: jobID, WorkspaceId: job.WorkspaceId}
+			var execTime, retryTime sql.NullTime
+			var errorCode sql.NullString
+			if err := rows.Scan(&status.JobState, &status.AttemptNum, &execTime, &retryTime, &errorCode, &status.ErrorResponse, &status.Parameters); err != nil {
+				return nil, err
+			}
+			status.ExecTime, status.RetryTime, status.ErrorCode = execTime.Time, retryTime.Time, errorCode.String
+			statuses = append(statuses, status)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(statuses) > 0 {
+			job.LastJobStatus = statuses[len(statuses)-1]
+		}
+		return &JobDetails{Dataset: ds.Index, Job: job, Statuses: statuses}, nil
+	}
+	return nil, fmt.Errorf("%w: %d", errJobNotFound, jobID)
+}
+
+// payloadColumnTypes returns the type of the event_payload column of each dataset's jobs table
+func payloadColumnTypes(ctx context.Context, db *sql.DB, dsList []dataSetT) (map[string]string, error) {
+	types := make(map[string]string, len(dsList))
+	for _, ds := range dsList {
+		types[ds.JobTable] = string(JSONB)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT table_name, data_type
+		FROM information_schema.columns
+		WHERE table_name = ANY($1) AND column_name = 'event_payload'`,
+		pq.Array(lo.Keys(types)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get column types: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var table, columnType string
+		if err := rows.Scan(&table, &columnType); err != nil {
+			return nil, fmt.Errorf("scan column types: %w", err)
+		}
+		types[table] = columnType
+	}
+	return types, rows.Err()
+}
+
+// Snapshots lists the snapshots taken for a workspace before maintenance operations, oldest first
+func (a *JobsAdmin) Snapshots(input JobsSnapshotsInput, reply *[]JobsSnapshot) error {
+	jd, err := a.handle(input.TablePrefix)
+	if err != nil {
+		return err
+	}
+	if input.WorkspaceID == "" {
+		return errors.New("please provide the workspace of the snapshots")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+	files, err := jd.listSnapshots(ctx, input.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	*reply = lo.Map(files, func(f *filemanager.FileInfo, _ int) JobsSnapshot {
+		return JobsSnapshot{ObjectName: f.Key, LastModified: f.LastModified}
+	})
+	return nil
+}
+
+// Restore stores again the jobs of a snapshot taken before a maintenance operation.
+// Jobs which were in a terminal state are restored without any status, so that they are processed again,
+// whereas the others keep their state and attempts, like in Import.
+func (a *JobsAdmin) Restore(input JobsRestoreInput, reply *JobsRestoreResult) error {
+	jd, err := a.handle(input.TablePrefix)
+	if err != nil {
+		return err
+	}
+	if jd.ownerType != ReadWrite {
+		return fmt.Errorf("jobs cannot be restored into %q, it is not a read-write jobsdb", input.TablePrefix)
+	}
+	if input.WorkspaceID == "" || input.ObjectName == "" {
+		return errors.New("please provide the workspace and the object name of the snapshot")
+	}
+	states := input.States
+	if len(states) == 0 {
+		states = lo.Without(lo.Map(jobStates, func(js jobStateT, _ int) string { return js.State }), Succeeded.State, Filtered.State, Migrated.State)
+	}
+	for _, state := range states {
+		if !isAdminJobState(state) {
+			return fmt.Errorf("invalid job state %q", state)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.transferTimeout)
+	defer cancel()
+	restored, err := jd.restoreSnapshot(ctx, input.WorkspaceID, input.ObjectName, states)
+	a.logger.Infon("Restored jobs from snapshot",
+		logger.NewStringField("tablePrefix", input.TablePrefix),
+		logger.NewStringField("workspaceId", input.WorkspaceID),
+		logger.NewStringField("objectName", input.ObjectName),
+		logger.NewIntField("jobs", int64(restored)),
+	)
+	jd.stats.NewTaggedStat("jobsdb_admin_restored_jobs", stats.CountType, stats.Tags{"customVal": jd.tablePrefix, "workspaceId": input.WorkspaceID}).Count(restored)
+	if err != nil {
+		return fmt.Errorf("restoring jobs, %d jobs were restored: %w", restored, err)
+	}
+	*reply = JobsRestoreResult{Jobs: restored}
+	return nil
+}
@@ -0,0 +1,142 @@
+package jobsdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/jobsdb/internal/lock"
+	. "github.com/rudderlabs/rudder-server/utils/tx" //nolint:staticcheck
+)
+
+func TestJobsAdmin(t *testing.T) {
+	_ = startPostgres(t)
+	ctx := context.Background()
+
+	jd := NewForReadWrite("rt")
+	require.NoError(t, jd.Start())
+	defer jd.TearDown()
+
+	newJob := func(workspaceID, destinationID, email string) *JobT {
+		return &JobT{
+			UUID:         uuid.New(),
+			UserID:       "user-1",
+			CustomVal:    "WEBHOOK",
+			EventCount:   1,
+			EventPayload: []byte(fmt.Sprintf(`{"context":{"traits":{"email":%q}}}`, email)),
+			Parameters:   []byte(fmt.Sprintf(`{"source_id":"source-1","destination_id":%q}`, destinationID)),
+			WorkspaceId:  workspaceID,
+		}
+	}
+	newStatus := func(jobID int64, state string) *JobStatusT {
+		return &JobStatusT{
+			JobID:         jobID,
+			JobState:      state,
+			AttemptNum:    3,
+			ExecTime:      time.Now(),
+			RetryTime:     time.Now(),
+			ErrorCode:     "400",
+			ErrorResponse: []byte(`{"reason":"bad request"}`),
+			Parameters:    []byte(`{}`),
+			WorkspaceId:   "ws-1",
+		}
+	}
+	require.NoError(t, jd.Store(ctx, []*JobT{
+		newJob("ws-1", "d-1", "one@example.com"),
+		newJob("ws-1", "d-2", "two@example.com"),
+		newJob("ws-2", "d-1", "three@example.com"),
+	}))
+	// a new dataset, so that searches span more than one
+	require.NoError(t, jd.WithTx(func(tx *Tx) error {
+		return jd.createDSInTx(ctx, tx, newDataSet("rt", "2"))
+	}))
+	jd.dsListLock.WithLock(func(l lock.LockToken) {
+		require.NoError(t, jd.doRefreshDSRangeList(l))
+	})
+	require.NoError(t, jd.Store(ctx, []*JobT{newJob("ws-1", "d-1", "four@example.com")}))
+
+	require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{newStatus(1, Failed.State)}, nil, nil))
+	require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{newStatus(1, Aborted.State), newStatus(2, Succeeded.State)}, nil, nil))
+
+	a := NewJobsAdmin(jd)
+	search := func(input JobsSearchInput) []int64 {
+		t.Helper()
+		input.TablePrefix = "rt"
+		var reply []JobSummary
+		require.NoError(t, a.Search(input, &reply))
+		ids := make([]int64, 0, len(reply))
+		for _, job := range reply {
+			ids = append(ids, job.JobID)
+		}
+		return ids
+	}
+
+	t.Run("search", func(t *testing.T) {
+		require.Equal(t, []int64{4, 3, 2, 1}, search(JobsSearchInput{}), "newest jobs should be returned first, across datasets")
+		require.Equal(t, []int64{4, 3}, search(JobsSearchInput{Limit: 2}))
+		require.Equal(t, []int64{4, 2, 1}, search(JobsSearchInput{WorkspaceID: "ws-1"}))
+		require.Equal(t, []int64{4, 3, 1}, search(JobsSearchInput{DestinationID: "d-1", SourceID: "source-1"}))
+		require.Equal(t, []int64{4, 3}, search(JobsSearchInput{States: []string{Unprocessed.State}}))
+		require.Equal(t, []int64{2, 1}, search(JobsSearchInput{States: []string{Aborted.State, Succeeded.State}}))
+		require.Equal(t, []int64{3}, search(JobsSearchInput{PayloadPath: "context.traits.email", PayloadValue: "three@example.com"}))
+		require.Equal(t, []int64{4, 3, 2, 1}, search(JobsSearchInput{PayloadPath: "context.traits.email"}))
+		require.Empty(t, search(JobsSearchInput{PayloadPath: "context.traits.phone"}))
+		require.Empty(t, search(JobsSearchInput{From: time.Now().Add(time.Hour)}))
+		require.Len(t, search(JobsSearchInput{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)}), 4)
+
+		var reply []JobSummary
+		require.NoError(t, a.Search(JobsSearchInput{TablePrefix: "rt", States: []string{Aborted.State}}, &reply))
+		require.Len(t, reply, 1)
+		require.Equal(t, "source-1", reply[0].SourceID)
+		require.Equal(t, "d-1", reply[0].DestinationID)
+		require.Equal(t, 3, reply[0].AttemptNum)
+		require.Equal(t, "400", reply[0].ErrorCode)
+
+		require.Error(t, a.Search(JobsSearchInput{TablePrefix: "gw"}, &reply), "unknown jobsdb")
+		require.Error(t, a.Search(JobsSearchInput{TablePrefix: "rt", States: []string{"unknown"}}, &reply), "invalid state")
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		var reply JobDetails
+		require.NoError(t, a.Inspect(JobInspectInput{TablePrefix: "rt", JobID: 1}, &reply))
+		require.EqualValues(t, 1, reply.Job.JobID)
+		require.JSONEq(t, `{"context":{"traits":{"email":"one@example.com"}}}`, string(reply.Job.EventPayload))
+		require.Len(t, reply.Statuses, 2)
+		require.Equal(t, Failed.State, reply.Statuses[0].JobState)
+		require.Equal(t, Aborted.State, reply.Statuses[1].JobState)
+		require.Equal(t, Aborted.State, reply.Job.LastJobStatus.JobState)
+
+		require.NoError(t, a.Inspect(JobInspectInput{TablePrefix: "rt", JobID: 4}, &reply))
+		require.Empty(t, reply.Statuses)
+
+		require.ErrorIs(t, a.Inspect(JobInspectInput{TablePrefix: "rt", JobID: 100}, &reply), errJobNotFound)
+	})
+
+	t.Run("requeue", func(t *testing.T) {
+		var reply JobsRequeueResult
+		require.Error(t, a.Requeue(JobsRequeueInput{TablePrefix: "rt", JobIDs: []int64{1}, State: Succeeded.State, Note: "retry"}, &reply), "invalid state")
+		require.Error(t, a.Requeue(JobsRequeueInput{TablePrefix: "rt", JobIDs: []int64{1}, State: Failed.State}, &reply), "missing note")
+
+		require.NoError(t, a.Requeue(JobsRequeueInput{TablePrefix: "rt", JobIDs: []int64{1, 2, 100}, State: Failed.State, Note: "destination fixed"}, &reply))
+		require.Equal(t, []int64{1}, reply.Requeued)
+		require.Len(t, reply.Skipped, 2)
+		require.Contains(t, reply.Skipped[2], Succeeded.State)
+		require.Contains(t, reply.Skipped[100], "not found")
+
+		var details JobDetails
+		require.NoError(t, a.Inspect(JobInspectInput{TablePrefix: "rt", JobID: 1}, &details))
+		require.Len(t, details.Statuses, 3)
+		requeued := details.Statuses[2]
+		require.Equal(t, Failed.State, requeued.JobState)
+		require.Equal(t, 0, requeued.AttemptNum)
+		require.JSONEq(t, `{"reason":"requeued by admin","note":"destination fixed","previousState":"aborted"}`, string(requeued.ErrorResponse))
+
+		toProcess, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}}, nil)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), toProcess.Jobs[0].JobID, "requeued job should be picked up again")
+	})
+}
@@ -0,0 +1,469 @@
+package jobsdb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+
+	. "github.com/rudderlabs/rudder-server/utils/tx" //nolint:staticcheck
+)
+
+// jobsExportVersion is the version of the jobs export file format
+const jobsExportVersion = 1
+
+// jobsImportBatchSize is the number of jobs imported per transaction
+const jobsImportBatchSize = 1000
+
+// jobsExportPageSize is the number of jobs exported per transaction
+const jobsExportPageSize = 1000
+
+// jobsExportHeader is the first line of a jobs export file
+type jobsExportHeader struct {
+	Version     int       `json:"version"`
+	TablePrefix string    `json:"tablePrefix"`
+	WorkspaceID string    `json:"workspaceId"`
+	ExportedAt  time.Time `json:"exportedAt"`
+
+	Operation string   `json:"operation,omitempty"` // the maintenance operation a snapshot was taken before
+	Datasets  []string `json:"datasets,omitempty"`  // the datasets a snapshot was taken from
+}
+
+// exportedJob is a line of a jobs export file, following the header.
+// It is a superset of the lines of archiver files, so that the tooling reading them can also read exports and snapshots.
+// Job IDs are not portable across jobsdbs, imported jobs get new ones.
+type exportedJob struct {
+	JobID        int64              `json:"jobId"` // the id of the job in the exporting jobsdb
+	UUID         uuid.UUID          `json:"uuid"`
+	UserID       string             `json:"userId"`
+	CustomVal    string             `json:"customVal"`
+	EventCount   int                `json:"eventCount"`
+	EventPayload json.RawMessage    `json:"payload"`
+	MessageID    string             `json:"messageId"`
+	Parameters   json.RawMessage    `json:"parameters"`
+	WorkspaceID  string             `json:"workspaceId"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	Status       *exportedJobStatus `json:"status,omitempty"` // the latest status of the job, nil if it was never picked up
+
+	History []*exportedJobStatus `json:"history,omitempty"` // the previous statuses of the job, only included in status tables cleanup snapshots
+}
+
+type exportedJobStatus struct {
+	State         string          `json:"state"`
+	AttemptNum    int             `json:"attemptNum"`
+	ExecTime      time.Time       `json:"execTime"`
+	RetryTime     time.Time       `json:"retryTime"`
+	ErrorCode     string          `json:"errorCode"`
+	ErrorResponse json.RawMessage `json:"errorResponse"`
+	Parameters    json.RawMessage `json:"parameters"`
+}
+
+// exportJobs writes the pending jobs of a workspace, along with their latest status, to a gzip compressed file of JSON lines at path.
+// The file is written atomically, i.e. it only appears at path once complete.
+//
+// Jobs are read in pages of jobsExportPageSize jobs, each one in its own transaction, so that locks are only held while reading a page.
+// If a migratedResponse is provided, the jobs of a page are marked as migrated in the same transaction they are read in,
+// so that they can't be picked up between getting exported and getting marked as migrated. Since these jobs are not processed by this jobsdb anymore,
+// the file is still written at path if the export fails after some of them got marked as migrated.
+func (jd *Handle) exportJobs(ctx context.Context, workspaceID, path string, migratedResponse json.RawMessage) (exported, migrated int, err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, 0, fmt.Errorf("creating export file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	defer func() { _ = tmp.Close() }()
+
+	gz := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(gz).Encode(jobsExportHeader{
+		Version:     jobsExportVersion,
+		TablePrefix: jd.tablePrefix,
+		WorkspaceID: workspaceID,
+		ExportedAt:  time.Now().UTC(),
+	}); err != nil {
+		return 0, 0, fmt.Errorf("writing export header: %w", err)
+	}
+
+	complete := func() error {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("closing export file: %w", err)
+		}
+		if err := tmp.Sync(); err != nil {
+			return fmt.Errorf("syncing export file: %w", err)
+		}
+		if err := tmp.Close(); err != nil {
+			return fmt.Errorf("closing export file: %w", err)
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return fmt.Errorf("renaming export file: %w", err)
+		}
+		return nil
+	}
+
+	var afterJobID int64
+	for {
+		var page bytes.Buffer // only written to the file once the page's transaction is committed
+		var pageJobs int
+		err := jd.inUpdateSafeCtx(ctx, func(dsList []dataSetT, dsRangeList []dataSetRangeT) error {
+			return jd.WithTx(func(tx *Tx) error {
+				jobs := make([]*exportedJob, 0, jobsExportPageSize)
+				enc := json.NewEncoder(&page)
+				for _, ds := range dsList {
+					if err := jd.pendingJobsDS(ctx, tx, ds, workspaceID, afterJobID, jobsExportPageSize-len(jobs), func(job *exportedJob) error {
+						if err := enc.Encode(job); err != nil {
+							return fmt.Errorf("writing job %d: %w", job.JobID, err)
+						}
+						job.EventPayload = nil // only needed in the file
+						jobs = append(jobs, job)
+						return nil
+					}); err != nil {
+						return fmt.Errorf("reading pending jobs of %s: %w", ds.JobTable, err)
+					}
+					if len(jobs) == jobsExportPageSize {
+						break
+					}
+				}
+				pageJobs = len(jobs)
+				if pageJobs == 0 {
+					return nil
+				}
+				afterJobID = jobs[pageJobs-1].JobID
+				if migratedResponse == nil {
+					return nil
+				}
+				return jd.markJobsMigratedInTx(ctx, &updateSafeTx{tx: tx, identity: jd.tablePrefix, dsList: dsList, dsRangeList: dsRangeList}, jobs, migratedResponse)
+			})
+		})
+		if err == nil {
+			_, err = page.WriteTo(gz)
+		}
+		if err != nil {
+			if migrated > 0 {
+				if completeErr := complete(); completeErr != nil {
+					return exported, migrated, fmt.Errorf("%w; writing the %d jobs marked as migrated: %s", err, migrated, completeErr)
+				}
+				return exported, migrated, fmt.Errorf("the %d jobs marked as migrated so far were written to %s: %w", migrated, path, err)
+			}
+			return 0, 0, err
+		}
+		exported += pageJobs
+		if migratedResponse != nil {
+			migrated += pageJobs
+		}
+		if pageJobs < jobsExportPageSize {
+			break
+		}
+	}
+	if err := complete(); err != nil {
+		return exported, migrated, err
+	}
+	return exported, migrated, nil
+}
+
+// pendingJobsDS calls fn for up to limit jobs of a workspace in a dataset, after afterJobID, whose latest state is not terminal, oldest first
+func (jd *Handle) pendingJobsDS(ctx context.Context, db queryContexter, ds dataSetT, workspaceID string, afterJobID int64, limit int, fn func(*exportedJob) error) error {
+	return jd.jobsWithStatusDS(ctx, db, ds, false, limit, fn,
+		`j.workspace_id = $1 AND j.job_id > $2 AND (s.job_state IS NULL OR s.job_state <> ALL($3))`,
+		workspaceID, afterJobID, pq.Array(validTerminalStates),
+	)
+}
+
+// jobsWithStatusDS calls fn for every job in a dataset satisfying condition, oldest first, along with its latest status.
+// The condition can refer to the job as j and to its latest status as s.
+// If withHistory is true, the previous statuses of the job are also provided, oldest first.
+// A positive limit limits the rows read, i.e. the jobs if withHistory is false.
+func (jd *Handle) jobsWithStatusDS(ctx context.Context, db queryContexter, ds dataSetT, withHistory bool, limit int, fn func(*exportedJob) error, condition string, args ...any) error {
+	statuses := fmt.Sprintf(`"v_last_%s"`, ds.JobStatusTable)
+	orderBy := "j.job_id"
+	if withHistory {
+		statuses = fmt.Sprintf(`%q`, ds.JobStatusTable)
+		orderBy = "j.job_id, s.id"
+	}
+	var limitQuery string
+	if limit > 0 {
+		limitQuery = fmt.Sprintf(" LIMIT %d", limit)
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT j.job_id, j.uuid, j.user_id, j.custom_val, j.event_count, j.event_payload, j.parameters, j.workspace_id, j.created_at,
+			s.job_state, s.attempt, s.exec_time, s.retry_time, s.error_code, s.error_response, s.parameters
+		FROM %[1]q j LEFT JOIN %[2]s s ON s.job_id = j.job_id
+		WHERE %[3]s
+		ORDER BY %[4]s%[5]s`, ds.JobTable, statuses, condition, orderBy, limitQuery),
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	var job *exportedJob // with history, the rows of a job are only complete once the next job is scanned
+	for rows.Next() {
+		var next exportedJob
+		var payload []byte
+		var state, errorCode sql.NullString
+		var attempt sql.NullInt64
+		var execTime, retryTime sql.NullTime
+		var errorResponse, statusParameters []byte
+		if err := rows.Scan(&next.JobID, &next.UUID, &next.UserID, &next.CustomVal, &next.EventCount, &payload, &next.Parameters, &next.WorkspaceID, &next.CreatedAt,
+			&state, &attempt, &execTime, &retryTime, &errorCode, &errorResponse, &statusParameters); err != nil {
+			return err
+		}
+		var status *exportedJobStatus
+		if state.Valid {
+			status = &exportedJobStatus{
+				State:         state.String,
+				AttemptNum:    int(attempt.Int64),
+				ExecTime:      execTime.Time,
+				RetryTime:     retryTime.Time,
+				ErrorCode:     errorCode.String,
+				ErrorResponse: errorResponse,
+				Parameters:    statusParameters,
+			}
+		}
+		if job != nil && job.JobID == next.JobID {
+			job.History = append(job.History, job.Status)
+			job.Status = status
+			continue
+		}
+		if job != nil {
+			if err := fn(job); err != nil {
+				return err
+			}
+		}
+		if next.EventPayload, err = jd.payloadCodec.decode(ctx, payload); err != nil {
+			return fmt.Errorf("decoding payload of job %d: %w", next.JobID, err)
+		}
+		next.MessageID = gjson.GetBytes(next.EventPayload, "messageId").String()
+		next.Status = status
+		job = &next
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if job != nil {
+		return fn(job)
+	}
+	return nil
+}
+
+// markJobsMigratedInTx marks exported jobs as migrated, so that they are not processed by this jobsdb anymore
+func (jd *Handle) markJobsMigratedInTx(ctx context.Context, tx UpdateSafeTx, jobs []*exportedJob, errorResponse json.RawMessage) error {
+	statusesByCustomVal := make(map[string][]*JobStatusT)
+	now := time.Now()
+	for _, job := range jobs {
+		attempt := 0
+		if job.Status != nil {
+			attempt = job.Status.AttemptNum
+		}
+		statusesByCustomVal[job.CustomVal] = append(statusesByCustomVal[job.CustomVal], &JobStatusT{
+			JobID:         job.JobID,
+			JobState:      Migrated.State,
+			AttemptNum:    attempt,
+			ExecTime:      now,
+			RetryTime:     now,
+			ErrorResponse: errorResponse,
+			Parameters:    []byte(`{}`),
+			JobParameters: job.Parameters,
+			WorkspaceId:   job.WorkspaceID,
+		})
+	}
+	for customVal, statuses := range statusesByCustomVal {
+		if err := jd.UpdateJobStatusInTx(ctx, tx, statuses, []string{customVal}, nil); err != nil {
+			return fmt.Errorf("marking jobs as migrated: %w", err)
+		}
+	}
+	return nil
+}
+
+// importJobs stores the jobs of a jobs export file, along with their latest status, in batches of jobsImportBatchSize jobs.
+// Only the jobs for which keep returns true are imported, all of them if keep is nil.
+// If skipExisting is true, jobs whose uuid is already stored and not migrated are skipped, so that importing a file again doesn't duplicate them.
+// It returns the number of jobs imported and skipped, which might be less than the jobs in the file if an error occurs.
+func (jd *Handle) importJobs(ctx context.Context, r io.Reader, keep func(*exportedJob) bool, skipExisting bool) (imported, skipped int, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, 0, fmt.Errorf("opening export file: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	var header jobsExportHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, 0, fmt.Errorf("reading export header: %w", err)
+	}
+	if header.Version != jobsExportVersion {
+		return 0, 0, fmt.Errorf("unsupported export version %d, expected %d", header.Version, jobsExportVersion)
+	}
+	if header.TablePrefix != jd.tablePrefix {
+		return 0, 0, fmt.Errorf("export file contains %q jobs, cannot import them into %q", header.TablePrefix, jd.tablePrefix)
+	}
+
+	batch := make([]*exportedJob, 0, jobsImportBatchSize)
+	for {
+		var job exportedJob
+		err := dec.Decode(&job)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return imported, skipped, fmt.Errorf("reading job: %w", err)
+		}
+		if err == nil && (keep == nil || keep(&job)) {
+			batch = append(batch, &job)
+		}
+		if len(batch) == jobsImportBatchSize || (errors.Is(err, io.EOF) && len(batch) > 0) {
+			stored, err := jd.importJobsBatch(ctx, batch, skipExisting)
+			if err != nil {
+				return imported, skipped, err
+			}
+			imported += stored
+			skipped += len(batch) - stored
+			batch = batch[:0]
+		}
+		if errors.Is(err, io.EOF) {
+			return imported, skipped, nil
+		}
+	}
+}
+
+// importJobsBatch stores the jobs and their statuses in a single transaction, returning the number of jobs stored
+func (jd *Handle) importJobsBatch(ctx context.Context, batch []*exportedJob, skipExisting bool) (int, error) {
+	var stored int
+	// same lock order as UpdateJobStatus followed by Store, so that statuses are written to the dataset the jobs were stored in
+	err := jd.inUpdateSafeCtx(ctx, func(_ []dataSetT, _ []dataSetRangeT) error {
+		return jd.inStoreSafeCtx(ctx, func() error {
+			return jd.WithTx(func(tx *Tx) error {
+				dsList := jd.getDSList()
+				batch := batch
+				if skipExisting {
+					existing, err := jd.storedJobUUIDs(ctx, tx, dsList, batch)
+					if err != nil {
+						return fmt.Errorf("reading already imported jobs: %w", err)
+					}
+					batch = lo.Reject(batch, func(job *exportedJob, _ int) bool { return existing[job.UUID] })
+					if len(batch) == 0 {
+						return nil
+					}
+				}
+				jobs := make([]*JobT, len(batch))
+				uuids := make([]string, len(batch))
+				for i, job := range batch {
+					jobs[i] = &JobT{
+						UUID:         job.UUID,
+						UserID:       job.UserID,
+						CustomVal:    job.CustomVal,
+						EventCount:   job.EventCount,
+						EventPayload: job.EventPayload,
+						Parameters:   job.Parameters,
+						WorkspaceId:  job.WorkspaceID,
+					}
+					uuids[i] = job.UUID.String()
+				}
+				stored = len(batch)
+
+				lastDS := dsList[len(dsList)-1]
+				var maxJobID int64
+				if err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(job_id), 0) FROM %q`, lastDS.JobTable)).Scan(&maxJobID); err != nil {
+					return fmt.Errorf("reading max job id: %w", err)
+				}
+				if err := jd.StoreInTx(ctx, &storeSafeTx{tx: tx, identity: jd.tablePrefix}, jobs); err != nil {
+					return fmt.Errorf("storing jobs: %w", err)
+				}
+
+				// map the imported jobs to their new ids, for writing their statuses
+				rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT job_id, uuid FROM %q WHERE job_id > $1 AND uuid = ANY($2::uuid[])`, lastDS.JobTable), maxJobID, pq.Array(uuids))
+				if err != nil {
+					return fmt.Errorf("reading imported job ids: %w", err)
+				}
+				jobIDs := make(map[uuid.UUID]int64, len(batch))
+				for rows.Next() {
+					var jobID int64
+					var jobUUID uuid.UUID
+					if err := rows.Scan(&jobID, &jobUUID); err != nil {
+						_ = rows.Close()
+						return fmt.Errorf("scanning imported job ids: %w", err)
+					}
+					jobIDs[jobUUID] = jobID
+				}
+				_ = rows.Close()
+				if err := rows.Err(); err != nil {
+					return fmt.Errorf("iterating imported job ids: %w", err)
+				}
+
+				statusesByCustomVal := make(map[string][]*JobStatusT)
+				for _, job := range batch {
+					if job.Status == nil {
+						continue
+					}
+					jobID, ok := jobIDs[job.UUID]
+					if !ok {
+						return fmt.Errorf("imported job %s not found", job.UUID)
+					}
+					state := job.Status.State
+					if state == Executing.State { // it was in flight while exported and needs to be retried
+						state = Failed.State
+					}
+					statusesByCustomVal[job.CustomVal] = append(statusesByCustomVal[job.CustomVal], &JobStatusT{
+						JobID:         jobID,
+						JobState:      state,
+						AttemptNum:    job.Status.AttemptNum,
+						ExecTime:      job.Status.ExecTime,
+						RetryTime:     job.Status.RetryTime,
+						ErrorCode:     job.Status.ErrorCode,
+						ErrorResponse: lo.Ternary(len(job.Status.ErrorResponse) > 0, job.Status.ErrorResponse, json.RawMessage(`{}`)),
+						Parameters:    lo.Ternary(len(job.Status.Parameters) > 0, job.Status.Parameters, json.RawMessage(`{}`)),
+						JobParameters: job.Parameters,
+						WorkspaceId:   job.WorkspaceID,
+					})
+				}
+				utx := &updateSafeTx{tx: tx, identity: jd.tablePrefix, dsList: dsList, dsRangeList: jd.getDSRangeList()}
+				for customVal, statuses := range statusesByCustomVal {
+					if err := jd.UpdateJobStatusInTx(ctx, utx, statuses, []string{customVal}, nil); err != nil {
+						return fmt.Errorf("updating job statuses: %w", err)
+					}
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return stored, nil
+}
+
+// storedJobUUIDs returns the uuids of the jobs of the batch which are stored in any of the datasets, unless they got migrated away
+func (jd *Handle) storedJobUUIDs(ctx context.Context, tx *Tx, dsList []dataSetT, batch []*exportedJob) (map[uuid.UUID]bool, error) {
+	uuids := lo.Map(batch, func(job *exportedJob, _ int) string { return job.UUID.String() })
+	stored := make(map[uuid.UUID]bool)
+	for _, ds := range dsList {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+			`SELECT j.uuid FROM %[1]q j LEFT JOIN "v_last_%[2]s" s ON s.job_id = j.job_id
+			WHERE j.uuid = ANY($1::uuid[]) AND (s.job_state IS NULL OR s.job_state <> $2)`, ds.JobTable, ds.JobStatusTable),
+			pq.Array(uuids), Migrated.State,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var jobUUID uuid.UUID
+			if err := rows.Scan(&jobUUID); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			stored[jobUUID] = true
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
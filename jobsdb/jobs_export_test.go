@@ -0,0 +1,133 @@
+package jobsdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobsExportImport(t *testing.T) {
+	_ = startPostgres(t)
+	ctx := context.Background()
+
+	jd := NewForReadWrite("rt")
+	require.NoError(t, jd.Start())
+	defer jd.TearDown()
+
+	newJob := func(workspaceID string, i int) *JobT {
+		return &JobT{
+			UUID:         uuid.New(),
+			UserID:       fmt.Sprintf("user-%d", i),
+			CustomVal:    "WEBHOOK",
+			EventCount:   1,
+			EventPayload: []byte(fmt.Sprintf(`{"index":%d}`, i)),
+			Parameters:   []byte(`{"source_id":"source-1","destination_id":"dest-1"}`),
+			WorkspaceId:  workspaceID,
+		}
+	}
+	newStatus := func(jobID int64, state string, attempt int) *JobStatusT {
+		return &JobStatusT{
+			JobID:         jobID,
+			JobState:      state,
+			AttemptNum:    attempt,
+			ExecTime:      time.Now(),
+			RetryTime:     time.Now(),
+			ErrorCode:     "500",
+			ErrorResponse: []byte(`{"reason":"server error"}`),
+			Parameters:    []byte(`{}`),
+			WorkspaceId:   "ws-1",
+		}
+	}
+	require.NoError(t, jd.Store(ctx, []*JobT{
+		newJob("ws-1", 1), // unprocessed
+		newJob("ws-1", 2), // failed
+		newJob("ws-1", 3), // succeeded
+		newJob("ws-1", 4), // executing
+		newJob("ws-2", 5), // other workspace
+	}))
+	require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{
+		newStatus(2, Failed.State, 3),
+		newStatus(3, Succeeded.State, 1),
+		newStatus(4, Executing.State, 2),
+	}, nil, nil))
+
+	a := NewJobsAdmin(jd)
+	path := filepath.Join(t.TempDir(), "rt-ws-1.jsonl.gz")
+
+	t.Run("export", func(t *testing.T) {
+		require.Error(t, a.Export(JobsExportInput{TablePrefix: "rt", WorkspaceID: "ws-1", Path: "relative.gz"}, &JobsExportResult{}), "relative path")
+
+		var reply JobsExportResult
+		require.NoError(t, a.Export(JobsExportInput{TablePrefix: "rt", WorkspaceID: "ws-1", Path: path, MarkMigrated: true}, &reply))
+		require.Equal(t, JobsExportResult{Jobs: 3, Migrated: 3}, reply)
+
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}, WorkspaceID: "ws-1"}, nil)
+		require.NoError(t, err)
+		require.Empty(t, res.Jobs, "exported jobs should be marked as migrated")
+	})
+
+	t.Run("import", func(t *testing.T) {
+		var reply JobsImportResult
+		require.NoError(t, a.Import(JobsImportInput{TablePrefix: "rt", Path: path}, &reply))
+		require.Equal(t, 3, reply.Jobs)
+
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}, WorkspaceID: "ws-1"}, nil)
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 3)
+		for _, job := range res.Jobs {
+			require.Greater(t, job.JobID, int64(5), "imported jobs should get new ids")
+			require.JSONEq(t, `{"source_id":"source-1","destination_id":"dest-1"}`, string(job.Parameters))
+		}
+		require.JSONEq(t, `{"index":1}`, string(res.Jobs[0].EventPayload))
+		require.Empty(t, res.Jobs[0].LastJobStatus.JobState)
+
+		require.JSONEq(t, `{"index":2}`, string(res.Jobs[1].EventPayload))
+		require.Equal(t, Failed.State, res.Jobs[1].LastJobStatus.JobState)
+		require.Equal(t, 3, res.Jobs[1].LastJobStatus.AttemptNum)
+		require.Equal(t, "500", res.Jobs[1].LastJobStatus.ErrorCode)
+
+		require.JSONEq(t, `{"index":4}`, string(res.Jobs[2].EventPayload))
+		require.Equal(t, Failed.State, res.Jobs[2].LastJobStatus.JobState, "executing jobs should be imported as failed")
+		require.Equal(t, 2, res.Jobs[2].LastJobStatus.AttemptNum)
+
+		require.NoError(t, a.Import(JobsImportInput{TablePrefix: "rt", Path: path}, &reply))
+		require.Equal(t, JobsImportResult{Skipped: 3}, reply, "already imported jobs should be skipped")
+	})
+
+	t.Run("export in pages", func(t *testing.T) {
+		jobs := make([]*JobT, jobsExportPageSize+1)
+		for i := range jobs {
+			jobs[i] = newJob("ws-3", i)
+		}
+		require.NoError(t, jd.Store(ctx, jobs))
+
+		var reply JobsExportResult
+		require.NoError(t, a.Export(JobsExportInput{TablePrefix: "rt", WorkspaceID: "ws-3", Path: filepath.Join(t.TempDir(), "rt-ws-3.jsonl.gz"), MarkMigrated: true}, &reply))
+		require.Equal(t, JobsExportResult{Jobs: len(jobs), Migrated: len(jobs)}, reply)
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}, WorkspaceID: "ws-3"}, nil)
+		require.NoError(t, err)
+		require.Empty(t, res.Jobs)
+	})
+
+	t.Run("invalid files", func(t *testing.T) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write([]byte(`{"version":1,"tablePrefix":"batch_rt","workspaceId":"ws-1"}` + "\n"))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		_, _, err = jd.importJobs(ctx, &buf, nil, true)
+		require.ErrorContains(t, err, "cannot import them")
+
+		notGzipped := filepath.Join(t.TempDir(), "plain.jsonl")
+		require.NoError(t, os.WriteFile(notGzipped, []byte(`{"version":1}`), 0o600))
+		require.Error(t, a.Import(JobsImportInput{TablePrefix: "rt", Path: notGzipped}, &JobsImportResult{}))
+	})
+}
@@ -96,6 +96,7 @@ type GetQueryParams struct {
 	ParameterFilters              []ParameterFilterT
 	stateFilters                  []string
 	afterJobID                    *int64
+	priority                      *int // only return jobs of this priority lane
 
 	// query limits
 
@@ -110,6 +111,13 @@ type GetQueryParams struct {
 	// A value less than or equal to zero will disable this limit (no limit),
 	// only values greater than zero are considered as valid limits.
 	PayloadSizeLimit int64
+
+	// PriorityLanes makes GetToProcess serve jobs of higher priority first, see [PriorityHigh].
+	// Lower priority lanes are still guaranteed a share of the limits, so that they are not starved.
+	// Jobs are not returned in job id order when enabled, therefore the jobs of a user get reordered
+	// and it must not be enabled by consumers guaranteeing the order of user events (e.g. the router with guaranteeUserEventOrder).
+	// Querying with priority lanes fails unless they are enabled for the jobsdb, see [PriorityLanesEnabled].
+	PriorityLanes bool
 }
 
 // StoreSafeTx sealed interface
@@ -516,6 +524,19 @@ type Handle struct {
 	dsMigrationLock     *lock.Locker
 	noResultsCache      *cache.NoResultsCache[ParameterFilterT]
 
+	// payload compression
+	payloadCodec     *payloadCodec
+	payloadEncodings sync.Map // jobs table => payloadEncoding
+
+	// change-data-capture stream of job status transitions
+	statusSink StatusTransitionSink
+
+	// object storage for snapshots taken before maintenance operations
+	snapshotStorage SnapshotStorage
+	// consecutive snapshot failures per maintenance operation
+	snapshotFailuresMu sync.Mutex
+	snapshotFailures   map[string]int
+
 	// table count stats
 	statTableCount        stats.Measurement
 	statPreDropTableCount stats.Measurement
@@ -584,6 +605,31 @@ type Handle struct {
 		backup struct {
 			masterBackupEnabled config.ValueLoader[bool]
 		}
+		payloadCompression struct {
+			enabled                 config.ValueLoader[bool]
+			level                   string
+			dictionaryEnabled       config.ValueLoader[bool]
+			dictionaryTrainInterval config.ValueLoader[time.Duration]
+			dictionarySamples       config.ValueLoader[int]
+			dictionaryMaxSize       config.ValueLoader[int]
+		}
+		priorityLanes struct {
+			minShare config.ValueLoader[float64]
+			enabled  bool
+		}
+		statusCDC struct {
+			enabled              bool
+			publishInterval      config.ValueLoader[time.Duration]
+			maxEntriesPerPublish config.ValueLoader[int]
+			maxPendingAge        config.ValueLoader[time.Duration]
+			maxPendingEntries    config.ValueLoader[int]
+		}
+		snapshot struct {
+			operations             config.ValueLoader[[]string]
+			timeout                config.ValueLoader[time.Duration]
+			maxConsecutiveFailures config.ValueLoader[int]
+			instanceID             string
+		}
 	}
 }
 
@@ -784,6 +830,20 @@ func WithJobMaxAge(jobMaxAge config.ValueLoader[time.Duration]) OptsFunc {
 	}
 }
 
+// WithStatusTransitionSink sets the sink which job status transitions are published to, if statusCDC is enabled for the jobsdb
+func WithStatusTransitionSink(sink StatusTransitionSink) OptsFunc {
+	return func(jd *Handle) {
+		jd.statusSink = sink
+	}
+}
+
+// WithSnapshotStorage sets the object storage where the jobs affected by maintenance operations are snapshotted, for the operations listed in snapshot.operations
+func WithSnapshotStorage(storage SnapshotStorage) OptsFunc {
+	return func(jd *Handle) {
+		jd.snapshotStorage = storage
+	}
+}
+
 func NewForRead(tablePrefix string, opts ...OptsFunc) *Handle {
 	return newOwnerType(Read, tablePrefix, opts...)
 }
@@ -849,6 +909,7 @@ func (jd *Handle) init() {
 	}
 
 	jd.loadConfig()
+	jd.payloadCodec = jd.newPayloadCodec()
 
 	// Initialize dbHandle if not already set
 	if jd.dbHandle != nil {
@@ -991,6 +1052,37 @@ func (jd *Handle) loadConfig() {
 	jd.conf.refreshDSTimeout = jd.config.GetReloadableDurationVar(10, time.Minute, jd.configKeys("refreshDS.timeout")...)
 	jd.conf.addNewDSTimeout = jd.config.GetReloadableDurationVar(5, time.Minute, jd.configKeys("addNewDS.timeout")...)
 
+	// payloadCompression.enabled: Compress the payloads of new datasets with zstd. Existing datasets are not affected
+	jd.conf.payloadCompression.enabled = jd.config.GetReloadableBoolVar(false, jd.configKeys("payloadCompression.enabled")...)
+	// payloadCompression.level: zstd compression level, one of fastest, default, better, best
+	jd.conf.payloadCompression.level = jd.config.GetStringVar("default", jd.configKeys("payloadCompression.level")...)
+	// payloadCompression.dictionary.enabled: Compress payloads using a dictionary periodically trained from the payloads of the latest dataset
+	jd.conf.payloadCompression.dictionaryEnabled = jd.config.GetReloadableBoolVar(false, jd.configKeys("payloadCompression.dictionary.enabled")...)
+	jd.conf.payloadCompression.dictionaryTrainInterval = jd.config.GetReloadableDurationVar(24, time.Hour, jd.configKeys("payloadCompression.dictionary.trainInterval")...)
+	jd.conf.payloadCompression.dictionarySamples = jd.config.GetReloadableIntVar(1000, 1, jd.configKeys("payloadCompression.dictionary.samples")...)
+	jd.conf.payloadCompression.dictionaryMaxSize = jd.config.GetReloadableIntVar(64, int(bytesize.KB), jd.configKeys("payloadCompression.dictionary.maxSizeInKB")...)
+	// priorityLanes.minShare: Share of the jobs limit reserved for each lower priority lane, when querying with priority lanes
+	jd.conf.priorityLanes.minShare = jd.config.GetReloadableFloat64Var(0.1, jd.configKeys("priorityLanes.minShare")...)
+	// priorityLanes.enabled: Index the priority of jobs in all datasets, for querying them with priority lanes, see [PriorityLanesEnabled]
+	jd.conf.priorityLanes.enabled = PriorityLanesEnabled(jd.config, jd.tablePrefix)
+	// statusCDC.enabled: Publish every job status transition to the status transition sink of the jobsdb, if it has one
+	jd.conf.statusCDC.enabled = jd.config.GetBoolVar(false, jd.configKeys("statusCDC.enabled")...)
+	// statusCDC.publishInterval: How long to wait before looking for new transitions to publish when there are none left
+	jd.conf.statusCDC.publishInterval = jd.config.GetReloadableDurationVar(1, time.Second, jd.configKeys("statusCDC.publishInterval")...)
+	// statusCDC.maxEntriesPerPublish: Maximum number of journal entries, one per status update, published to the sink at once
+	jd.conf.statusCDC.maxEntriesPerPublish = jd.config.GetReloadableIntVar(100, 1, jd.configKeys("statusCDC.maxEntriesPerPublish")...)
+	// statusCDC.maxPendingAge: Pending transitions older than this are dropped during cleanup, so that an unavailable sink can't grow the journal forever
+	jd.conf.statusCDC.maxPendingAge = jd.config.GetReloadableDurationVar(72, time.Hour, jd.configKeys("statusCDC.maxPendingAge")...)
+	// statusCDC.maxPendingEntries: Maximum number of pending journal entries, the oldest ones exceeding it are dropped during cleanup
+	jd.conf.statusCDC.maxPendingEntries = jd.config.GetReloadableIntVar(1000000, 1, jd.configKeys("statusCDC.maxPendingEntries")...)
+	// snapshot.operations: Maintenance operations (migrateDS, cleanupStatusTables, abortOldJobs) preceded by a snapshot of the jobs they discard or rewrite, if the jobsdb has a snapshot storage
+	jd.conf.snapshot.operations = jd.config.GetReloadableStringSliceVar([]string{}, jd.configKeys("snapshot.operations")...)
+	// snapshot.timeout: Maximum time for taking a snapshot, it fails if exceeded
+	jd.conf.snapshot.timeout = jd.config.GetReloadableDurationVar(30, time.Minute, jd.configKeys("snapshot.timeout")...)
+	// snapshot.maxConsecutiveFailures: Consecutive snapshot failures of a maintenance operation after which it proceeds without a snapshot, 0 for skipping it until a snapshot succeeds
+	jd.conf.snapshot.maxConsecutiveFailures = jd.config.GetReloadableIntVar(10, 1, jd.configKeys("snapshot.maxConsecutiveFailures")...)
+	jd.conf.snapshot.instanceID = jd.config.GetString("INSTANCE_ID", "1")
+
 	// migrationConfig
 
 	// migrateDSLoopSleepDuration: How often is the loop (which checks for migrating DS) run
@@ -1096,6 +1188,7 @@ func (jd *Handle) readerSetup(ctx context.Context, l lock.LockToken) {
 	// Even if two different services (gateway and processor) perform this operation, there should not be any problem.
 	jd.recoverFromJournal(ReadWrite)
 	jd.assertError(jd.doRefreshDSRangeList(l))
+	jd.assertError(jd.indexPriorities(ctx))
 	jd.assertError(func() error {
 		err := jd.doCleanup(ctx)
 		if err != nil && ctx.Err() == nil {
@@ -1111,6 +1204,7 @@ func (jd *Handle) readerSetup(ctx context.Context, l lock.LockToken) {
 	}))
 
 	jd.startMigrateDSLoop(ctx)
+	jd.startStatusCDCLoop(ctx)
 }
 
 func (jd *Handle) writerSetup(ctx context.Context, l lock.LockToken) {
@@ -1129,12 +1223,18 @@ func (jd *Handle) writerSetup(ctx context.Context, l lock.LockToken) {
 		jd.addNewDSLoop(ctx)
 		return nil
 	}))
+
+	jd.backgroundGroup.Go(crash.Wrapper(func() error {
+		jd.payloadDictionaryLoop(ctx)
+		return nil
+	}))
 }
 
 func (jd *Handle) readerWriterSetup(ctx context.Context, l lock.LockToken) {
 	jd.recoverFromJournal(Read)
 
 	jd.writerSetup(ctx, l)
+	jd.assertError(jd.indexPriorities(ctx))
 	jd.assertError(func() error {
 		err := jd.doCleanup(ctx)
 		if err != nil && ctx.Err() == nil {
@@ -1144,6 +1244,7 @@ func (jd *Handle) readerWriterSetup(ctx context.Context, l lock.LockToken) {
 	}())
 
 	jd.startMigrateDSLoop(ctx)
+	jd.startStatusCDCLoop(ctx)
 }
 
 // Stop stops the background goroutines and waits until they finish.
@@ -1504,6 +1605,13 @@ func (jd *Handle) createDSTablesInTx(ctx context.Context, tx *Tx, newDS dataSetT
 	default:
 		columnType = JSONB
 	}
+	encoding, err := jd.newDSPayloadEncodingInTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if encoding.compressed {
+		columnType = BYTEA
+	}
 	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE %q (
 		job_id BIGSERIAL PRIMARY KEY,
 		workspace_id TEXT NOT NULL DEFAULT '',
@@ -1517,6 +1625,11 @@ func (jd *Handle) createDSTablesInTx(ctx context.Context, tx *Tx, newDS dataSetT
 		expire_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW());`, newDS.JobTable)); err != nil {
 		return fmt.Errorf("creating %s: %w", newDS.JobTable, err)
 	}
+	if encoding.compressed {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`COMMENT ON COLUMN %q.event_payload IS '%s'`, newDS.JobTable, encoding)); err != nil {
+			return fmt.Errorf("setting payload encoding of %s: %w", newDS.JobTable, err)
+		}
+	}
 
 	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE %q (
 		id BIGSERIAL,
@@ -1545,6 +1658,11 @@ func (jd *Handle) createDSIndicesInTx(ctx context.Context, tx *Tx, newDS dataSet
 			return fmt.Errorf("creating %s index: %w", param, err)
 		}
 	}
+	if jd.conf.priorityLanes.enabled {
+		if err := createPriorityIndex(ctx, tx, newDS); err != nil {
+			return err
+		}
+	}
 	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE INDEX "idx_%[1]s_jid_id_js" ON %[1]q(job_id asc,id desc,job_state)`, newDS.JobStatusTable)); err != nil {
 		return fmt.Errorf("adding job_id_id index: %w", err)
 	}
@@ -1687,6 +1805,7 @@ func (jd *Handle) dropDSForRecovery(ds dataSetT) {
 
 func (jd *Handle) postDropDs(ds dataSetT) {
 	jd.noResultsCache.InvalidateDataset(ds.Index)
+	jd.payloadEncodings.Delete(ds.JobTable)
 
 	// Tracking time interval between drop ds operations. Hence calling end before start
 	if jd.isStatDropDSPeriodInitialized {
@@ -1853,6 +1972,7 @@ func (jd *Handle) invalidateCacheForJobs(ds dataSetT, jobList []*JobT) {
 
 type moreToken struct {
 	afterJobID *int64
+	lanes      map[int]*int64 // priority lane => afterJobID, when querying with priority lanes
 }
 
 func (jd *Handle) GetToProcess(ctx context.Context, params GetQueryParams, more MoreToken) (*MoreJobsResult, error) { // skipcq: CRT-P0003
@@ -2034,6 +2154,10 @@ func (jd *Handle) GetDistinctParameterValues(ctx context.Context, parameter Para
 }
 
 func (jd *Handle) doStoreJobsInTx(ctx context.Context, tx *Tx, ds dataSetT, jobList []*JobT) error {
+	encoding, err := jd.payloadEncodingOf(ctx, tx, ds)
+	if err != nil {
+		return err
+	}
 	store := func() error {
 		var stmt *sql.Stmt
 		var err error
@@ -2050,7 +2174,13 @@ func (jd *Handle) doStoreJobsInTx(ctx context.Context, tx *Tx, ds dataSetT, jobL
 				eventCount = job.EventCount
 			}
 
-			if _, err = stmt.ExecContext(ctx, job.UUID, job.UserID, job.CustomVal, string(job.Parameters), string(job.EventPayload), eventCount, job.WorkspaceId); err != nil {
+			var payload any = string(job.EventPayload)
+			if encoding.compressed {
+				if payload, err = jd.payloadCodec.encode(ctx, encoding, job.EventPayload); err != nil {
+					return err
+				}
+			}
+			if _, err = stmt.ExecContext(ctx, job.UUID, job.UserID, job.CustomVal, string(job.Parameters), payload, eventCount, job.WorkspaceId); err != nil {
 				return err
 			}
 		}
@@ -2070,7 +2200,7 @@ func (jd *Handle) doStoreJobsInTx(ctx context.Context, tx *Tx, ds dataSetT, jobL
 	if _, err := tx.ExecContext(ctx, savepointSql); err != nil {
 		return err
 	}
-	err := store()
+	err = store()
 
 	var e *pq.Error
 	if err != nil && errors.As(err, &e) {
@@ -2135,7 +2265,7 @@ func (jd *Handle) getJobsDS(ctx context.Context, ds dataSetT, lastDS bool, param
 	defer jd.getTimerStat("jobsdb_get_jobs_ds_time", &tags).RecordDuration()()
 
 	containsUnprocessed := lo.Contains(stateFilters, Unprocessed.State)
-	skipCacheResult := params.afterJobID != nil
+	skipCacheResult := params.afterJobID != nil || params.priority != nil // no jobs in a lane doesn't mean no jobs at all
 	cacheTx := map[string]*cache.NoResultTx[ParameterFilterT]{}
 	if !skipCacheResult {
 		for _, state := range stateFilters {
@@ -2175,6 +2305,10 @@ func (jd *Handle) getJobsDS(ctx context.Context, ds dataSetT, lastDS bool, param
 		filterConditions = append(filterConditions, fmt.Sprintf("jobs.workspace_id = '%s'", workspaceID))
 	}
 
+	if params.priority != nil {
+		filterConditions = append(filterConditions, priorityLaneQuery("jobs", *params.priority))
+	}
+
 	var filterQuery string
 	if len(filterConditions) > 0 {
 		filterQuery = "WHERE " + strings.Join(filterConditions, " AND ")
@@ -2195,13 +2329,25 @@ func (jd *Handle) getJobsDS(ctx context.Context, ds dataSetT, lastDS bool, param
 		joinTable = ds.JobStatusTable
 	}
 
+	encoding, err := jd.payloadEncodingOf(ctx, jd.dbHandle, ds)
+	if err != nil {
+		return JobsResult{}, false, err
+	}
+	payloadSizeExpr := "octet_length(jobs.event_payload::text)"
+	if encoding.compressed {
+		// the text representation of a bytea is its hex encoding, twice the size of the stored payload.
+		// A stored payload is never bigger than the decompressed one, so its size is safe to use for prefiltering
+		// jobs by payload size, whereas the limit itself is applied on the decompressed sizes while scanning the rows.
+		payloadSizeExpr = "octet_length(jobs.event_payload)"
+	}
+
 	var rows *sql.Rows
 	sqlStatement := fmt.Sprintf(`SELECT
 									jobs.job_id, jobs.uuid, jobs.user_id, jobs.parameters, jobs.custom_val, jobs.event_payload, jobs.event_count,
 									jobs.created_at, jobs.expire_at, jobs.workspace_id,
-									octet_length(jobs.event_payload::text) as payload_size,
+									%[6]s as payload_size,
 									sum(jobs.event_count) over (order by jobs.job_id asc) as running_event_counts,
-									sum(octet_length(jobs.event_payload::text)) over (order by jobs.job_id) as running_payload_size,
+									sum(%[6]s) over (order by jobs.job_id) as running_payload_size,
 									job_latest_state.job_state, job_latest_state.attempt,
 									job_latest_state.exec_time, job_latest_state.retry_time,
 									job_latest_state.error_code, job_latest_state.error_response, job_latest_state.parameters
@@ -2210,7 +2356,7 @@ func (jd *Handle) getJobsDS(ctx context.Context, ds dataSetT, lastDS bool, param
 									%[2]s JOIN %[3]q job_latest_state ON jobs.job_id=job_latest_state.job_id
 								    %[4]s
 									ORDER BY jobs.job_id %[5]s`,
-		ds.JobTable, joinType, joinTable, filterQuery, limitQuery)
+		ds.JobTable, joinType, joinTable, filterQuery, limitQuery, payloadSizeExpr)
 
 	var args []interface{}
 
@@ -2247,6 +2393,7 @@ func (jd *Handle) getJobsDS(ctx context.Context, ds dataSetT, lastDS bool, param
 
 	var runningEventCount int
 	var runningPayloadSize int64
+	var runningDecompressedPayloadSize int64
 
 	var jobList []*JobT
 	var limitsReached bool
@@ -2272,7 +2419,13 @@ func (jd *Handle) getJobsDS(ctx context.Context, ds dataSetT, lastDS bool, param
 		if err != nil {
 			return JobsResult{}, false, err
 		}
-		job.EventPayload = payload
+		if job.EventPayload, err = jd.payloadCodec.decode(ctx, payload); err != nil {
+			return JobsResult{}, false, fmt.Errorf("job %d: %w", job.JobID, err)
+		}
+		if encoding.compressed { // sizes calculated by the database are the ones of the compressed payloads
+			runningDecompressedPayloadSize += int64(len(job.EventPayload))
+			runningPayloadSize = runningDecompressedPayloadSize
+		}
 		if jsState.Valid {
 			resultsetStates[jsState.String] = struct{}{}
 			job.LastJobStatus.JobState = jsState.String
@@ -2619,6 +2772,7 @@ const (
 	postMigrateDSOperation     = "POST_MIGRATE_DS_OP"
 	dropDSOperation            = "DROP_DS"
 	RawDataDestUploadOperation = "S3_DEST_UPLOAD"
+	statusCDCOperation         = "STATUS_CDC"
 )
 
 type JournalEntryT struct {
@@ -2649,7 +2803,8 @@ func (jd *Handle) JournalMarkStartInTx(tx *Tx, opType string, opPayload json.Raw
 		opType == migrateCopyOperation ||
 		opType == postMigrateDSOperation ||
 		opType == dropDSOperation ||
-		opType == RawDataDestUploadOperation, fmt.Sprintf("opType: %s is not a supported op", opType))
+		opType == RawDataDestUploadOperation ||
+		opType == statusCDCOperation, fmt.Sprintf("opType: %s is not a supported op", opType))
 
 	sqlStatement := fmt.Sprintf(`INSERT INTO %s_journal (operation, done, operation_payload, start_time, owner)
                                        VALUES ($1, $2, $3, $4, $5) RETURNING id`, jd.tablePrefix)
@@ -2835,6 +2990,9 @@ func (jd *Handle) internalUpdateJobStatusInTx(ctx context.Context, tx *Tx, dsLis
 		)
 		return err
 	}
+	if err := jd.journalStatusTransitionsInTx(tx, statusList); err != nil {
+		return fmt.Errorf("journaling status transitions: %w", err)
+	}
 
 	tx.AddSuccessListener(func() {
 		// clear cache
@@ -3183,6 +3341,12 @@ func (jd *Handle) getJobs(ctx context.Context, params GetQueryParams, more MoreT
 	if params.JobsLimit <= 0 {
 		return &MoreJobsResult{JobsResult: JobsResult{}, More: mtoken}, nil
 	}
+	if params.PriorityLanes && params.priority == nil {
+		if !jd.conf.priorityLanes.enabled {
+			return nil, errPriorityLanesDisabled
+		}
+		return jd.getJobsByPriority(ctx, params, mtoken)
+	}
 	tags := &statTags{
 		StateFilters:     params.stateFilters,
 		CustomValFilters: params.CustomValFilters,
@@ -3360,6 +3524,8 @@ func (jd *Handle) GetLastJob(ctx context.Context) *JobT {
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		jd.assertError(err)
 	}
+	job.EventPayload, err = jd.payloadCodec.decode(ctx, job.EventPayload)
+	jd.assertError(err)
 	return &job
 }
 
@@ -81,6 +81,15 @@ func TestJobsdbPileupCount(t *testing.T) {
 	require.NoError(t, jdb.GetPileUpCounts(context.Background(), time.Now(), increasePendingEvents))
 	require.EqualValues(t, OriginalPendingEvents, pendingEventsCount)
 
+	backlogs, err := jdb.GetWorkspaceBacklogs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, backlogs, 1)
+	require.EqualValues(t, OriginalPendingEvents, backlogs[WorkspaceID].Jobs)
+	require.Positive(t, backlogs[WorkspaceID].PayloadBytes)
+	readerBacklogs, err := NewBacklogReader(jdb.dbHandle, TablePrefix).GetWorkspaceBacklogs(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, backlogs, readerBacklogs, "a reader not owning the jobsdb should read the same backlogs")
+
 	actualPendingEvents := pendingEventsCount
 	pendingEventsCount = 0
 
@@ -109,6 +118,12 @@ func TestJobsdbPileupCount(t *testing.T) {
 	}
 	require.NoError(t, jdb.GetPileUpCounts(context.Background(), beforeUpdating, increasePendingEvents))
 	require.EqualValues(t, OriginalPendingEvents, pendingEventsCount, "Getting pileup counts for the past should get the original count")
+	backlogs, err = jdb.GetWorkspaceBacklogs(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, actualPendingEvents, backlogs[WorkspaceID].Jobs, "jobs with a terminal status shouldn't be part of the backlog")
+	readerBacklogs, err = NewBacklogReader(jdb.dbHandle, TablePrefix).GetWorkspaceBacklogs(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, backlogs, readerBacklogs)
 
 	toProcess, err := jdb.GetJobs(context.Background(), lo.FilterMap(jobStates, func(s jobStateT, _ int) (string, bool) {
 		return s.State, !s.isTerminal
@@ -1013,194 +1013,193 @@ func Test_GetAdvisoryLockForOperation_Unique(t *testing.T) {
 }
 
 func TestAfterJobIDQueryParam(t *testing.T) {
-	_ = startPostgres(t)
-	customVal := "CUSTOMVAL"
-	generateJobs := func(numOfJob int, destinationID string) []*JobT {
-		js := make([]*JobT, numOfJob)
-		for i := 0; i < numOfJob; i++ {
-			js[i] = &JobT{
-				Parameters:   []byte(fmt.Sprintf(`{"batch_id":1,"source_id":"sourceID","destination_id":%q}`, destinationID)),
-				EventPayload: []byte(`{"testKey":"testValue"}`),
-				UserID:       "a-292e-4e79-9880-f8009e0ae4a3",
-				UUID:         uuid.New(),
-				CustomVal:    customVal,
-				EventCount:   1,
+	forEachBackend(t, func(t *testing.T, newJobsDB func(prefix string) testJobsDB) {
+		customVal := "CUSTOMVAL"
+		generateJobs := func(numOfJob int, destinationID string) []*JobT {
+			js := make([]*JobT, numOfJob)
+			for i := 0; i < numOfJob; i++ {
+				js[i] = &JobT{
+					Parameters:   []byte(fmt.Sprintf(`{"batch_id":1,"source_id":"sourceID","destination_id":%q}`, destinationID)),
+					EventPayload: []byte(`{"testKey":"testValue"}`),
+					UserID:       "a-292e-4e79-9880-f8009e0ae4a3",
+					UUID:         uuid.New(),
+					CustomVal:    customVal,
+					EventCount:   1,
+				}
 			}
+			return js
 		}
-		return js
-	}
 
-	t.Run("get unprocessed", func(t *testing.T) {
-		var jobsDB *Handle
-		prefix := strings.ToLower(rsRand.String(5))
-		destinationID := strings.ToLower(rsRand.String(5))
-		jobsDB = NewForReadWrite(prefix)
-		require.NoError(t, jobsDB.Start())
-		defer jobsDB.TearDown()
-		require.NoError(t, jobsDB.Store(context.Background(), generateJobs(2, destinationID)))
-		unprocessed, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
-		require.NoError(t, err)
-		require.Equal(t, 2, len(unprocessed.Jobs))
+		t.Run("get unprocessed", func(t *testing.T) {
+			var jobsDB testJobsDB
+			prefix := strings.ToLower(rsRand.String(5))
+			destinationID := strings.ToLower(rsRand.String(5))
+			jobsDB = newJobsDB(prefix)
+			defer jobsDB.TearDown()
+			require.NoError(t, jobsDB.Store(context.Background(), generateJobs(2, destinationID)))
+			unprocessed, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
+			require.NoError(t, err)
+			require.Equal(t, 2, len(unprocessed.Jobs))
+
+			unprocessed1, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, JobsLimit: 100, afterJobID: &unprocessed.Jobs[0].JobID})
+			require.NoError(t, err)
+			require.Equal(t, 1, len(unprocessed1.Jobs))
+
+			unprocessed2, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, JobsLimit: 100, afterJobID: &unprocessed.Jobs[1].JobID})
+			require.NoError(t, err)
+			require.Equal(t, 0, len(unprocessed2.Jobs))
+		})
 
-		unprocessed1, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, JobsLimit: 100, afterJobID: &unprocessed.Jobs[0].JobID})
-		require.NoError(t, err)
-		require.Equal(t, 1, len(unprocessed1.Jobs))
+		t.Run("get processed", func(t *testing.T) {
+			var jobsDB testJobsDB
+			prefix := strings.ToLower(rsRand.String(5))
+			destinationID := strings.ToLower(rsRand.String(5))
+			jobsDB = newJobsDB(prefix)
+			defer jobsDB.TearDown()
+			require.NoError(t, jobsDB.Store(context.Background(), generateJobs(2, destinationID)))
+			unprocessed, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
+			require.NoError(t, err)
+			require.Equal(t, 2, len(unprocessed.Jobs))
+
+			var statuses []*JobStatusT
+			for _, job := range unprocessed.Jobs {
+				statuses = append(statuses, &JobStatusT{
+					JobID:         job.JobID,
+					JobState:      Failed.State,
+					AttemptNum:    1,
+					ExecTime:      time.Now(),
+					RetryTime:     time.Now(),
+					ErrorCode:     "202",
+					ErrorResponse: []byte(`{"success":"OK"}`),
+					Parameters:    []byte(`{}`),
+					WorkspaceId:   defaultWorkspaceID,
+				})
+			}
+			require.NoError(t, jobsDB.UpdateJobStatus(context.Background(), statuses, []string{customVal}, []ParameterFilterT{}))
 
-		unprocessed2, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, JobsLimit: 100, afterJobID: &unprocessed.Jobs[1].JobID})
-		require.NoError(t, err)
-		require.Equal(t, 0, len(unprocessed2.Jobs))
+			processed1, err := jobsDB.GetFailed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100, afterJobID: &unprocessed.Jobs[0].JobID})
+			require.NoError(t, err)
+			require.Equal(t, 1, len(processed1.Jobs))
+
+			processed2, err := jobsDB.GetFailed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, JobsLimit: 100, afterJobID: &unprocessed.Jobs[1].JobID})
+			require.NoError(t, err)
+			require.Equal(t, 0, len(processed2.Jobs))
+		})
 	})
+}
 
-	t.Run("get processed", func(t *testing.T) {
-		var jobsDB *Handle
+func TestDeleteExecuting(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, newJobsDB func(prefix string) testJobsDB) {
+		customVal := "CUSTOMVAL"
+		generateJobs := func(numOfJob int, destinationID string) []*JobT {
+			js := make([]*JobT, numOfJob)
+			for i := 0; i < numOfJob; i++ {
+				js[i] = &JobT{
+					Parameters:   []byte(fmt.Sprintf(`{"batch_id":1,"source_id":"sourceID","destination_id":%q}`, destinationID)),
+					EventPayload: []byte(`{"testKey":"testValue"}`),
+					UserID:       "a-292e-4e79-9880-f8009e0ae4a3",
+					UUID:         uuid.New(),
+					CustomVal:    customVal,
+					EventCount:   1,
+				}
+			}
+			return js
+		}
+
+		var jobsDB testJobsDB
 		prefix := strings.ToLower(rsRand.String(5))
 		destinationID := strings.ToLower(rsRand.String(5))
-		jobsDB = NewForReadWrite(prefix)
-		require.NoError(t, jobsDB.Start())
+		jobsDB = newJobsDB(prefix)
 		defer jobsDB.TearDown()
 		require.NoError(t, jobsDB.Store(context.Background(), generateJobs(2, destinationID)))
 		unprocessed, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
 		require.NoError(t, err)
 		require.Equal(t, 2, len(unprocessed.Jobs))
-
 		var statuses []*JobStatusT
 		for _, job := range unprocessed.Jobs {
 			statuses = append(statuses, &JobStatusT{
 				JobID:         job.JobID,
-				JobState:      Failed.State,
+				JobState:      Executing.State,
 				AttemptNum:    1,
 				ExecTime:      time.Now(),
 				RetryTime:     time.Now(),
-				ErrorCode:     "202",
-				ErrorResponse: []byte(`{"success":"OK"}`),
+				ErrorCode:     "",
+				ErrorResponse: []byte(`{}`),
 				Parameters:    []byte(`{}`),
 				WorkspaceId:   defaultWorkspaceID,
 			})
 		}
 		require.NoError(t, jobsDB.UpdateJobStatus(context.Background(), statuses, []string{customVal}, []ParameterFilterT{}))
-
-		processed1, err := jobsDB.GetFailed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100, afterJobID: &unprocessed.Jobs[0].JobID})
+		unprocessed, err = jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
 		require.NoError(t, err)
-		require.Equal(t, 1, len(processed1.Jobs))
+		require.Equal(t, 0, len(unprocessed.Jobs))
+
+		jobsDB.DeleteExecuting()
 
-		processed2, err := jobsDB.GetFailed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, JobsLimit: 100, afterJobID: &unprocessed.Jobs[1].JobID})
+		unprocessed, err = jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
 		require.NoError(t, err)
-		require.Equal(t, 0, len(processed2.Jobs))
+		require.Equal(t, 2, len(unprocessed.Jobs))
 	})
 }
 
-func TestDeleteExecuting(t *testing.T) {
-	_ = startPostgres(t)
-	customVal := "CUSTOMVAL"
-	generateJobs := func(numOfJob int, destinationID string) []*JobT {
-		js := make([]*JobT, numOfJob)
-		for i := 0; i < numOfJob; i++ {
-			js[i] = &JobT{
-				Parameters:   []byte(fmt.Sprintf(`{"batch_id":1,"source_id":"sourceID","destination_id":%q}`, destinationID)),
-				EventPayload: []byte(`{"testKey":"testValue"}`),
-				UserID:       "a-292e-4e79-9880-f8009e0ae4a3",
-				UUID:         uuid.New(),
-				CustomVal:    customVal,
-				EventCount:   1,
-			}
-		}
-		return js
-	}
-
-	var jobsDB *Handle
-	prefix := strings.ToLower(rsRand.String(5))
-	destinationID := strings.ToLower(rsRand.String(5))
-	jobsDB = NewForReadWrite(prefix)
-	require.NoError(t, jobsDB.Start())
-	defer jobsDB.TearDown()
-	require.NoError(t, jobsDB.Store(context.Background(), generateJobs(2, destinationID)))
-	unprocessed, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
-	require.NoError(t, err)
-	require.Equal(t, 2, len(unprocessed.Jobs))
-	var statuses []*JobStatusT
-	for _, job := range unprocessed.Jobs {
-		statuses = append(statuses, &JobStatusT{
-			JobID:         job.JobID,
-			JobState:      Executing.State,
-			AttemptNum:    1,
-			ExecTime:      time.Now(),
-			RetryTime:     time.Now(),
-			ErrorCode:     "",
-			ErrorResponse: []byte(`{}`),
-			Parameters:    []byte(`{}`),
-			WorkspaceId:   defaultWorkspaceID,
-		})
-	}
-	require.NoError(t, jobsDB.UpdateJobStatus(context.Background(), statuses, []string{customVal}, []ParameterFilterT{}))
-	unprocessed, err = jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
-	require.NoError(t, err)
-	require.Equal(t, 0, len(unprocessed.Jobs))
-
-	jobsDB.DeleteExecuting()
-
-	unprocessed, err = jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
-	require.NoError(t, err)
-	require.Equal(t, 2, len(unprocessed.Jobs))
-}
-
 func TestFailExecuting(t *testing.T) {
-	_ = startPostgres(t)
-	customVal := "CUSTOMVAL"
-	generateJobs := func(numOfJob int, destinationID string) []*JobT {
-		js := make([]*JobT, numOfJob)
-		for i := 0; i < numOfJob; i++ {
-			js[i] = &JobT{
-				Parameters:   []byte(fmt.Sprintf(`{"batch_id":1,"source_id":"sourceID","destination_id":%q}`, destinationID)),
-				EventPayload: []byte(`{"testKey":"testValue"}`),
-				UserID:       "a-292e-4e79-9880-f8009e0ae4a3",
-				UUID:         uuid.New(),
-				CustomVal:    customVal,
-				EventCount:   1,
+	forEachBackend(t, func(t *testing.T, newJobsDB func(prefix string) testJobsDB) {
+		customVal := "CUSTOMVAL"
+		generateJobs := func(numOfJob int, destinationID string) []*JobT {
+			js := make([]*JobT, numOfJob)
+			for i := 0; i < numOfJob; i++ {
+				js[i] = &JobT{
+					Parameters:   []byte(fmt.Sprintf(`{"batch_id":1,"source_id":"sourceID","destination_id":%q}`, destinationID)),
+					EventPayload: []byte(`{"testKey":"testValue"}`),
+					UserID:       "a-292e-4e79-9880-f8009e0ae4a3",
+					UUID:         uuid.New(),
+					CustomVal:    customVal,
+					EventCount:   1,
+				}
 			}
+			return js
 		}
-		return js
-	}
 
-	var jobsDB *Handle
-	prefix := strings.ToLower(rsRand.String(5))
-	destinationID := strings.ToLower(rsRand.String(5))
-	jobsDB = NewForReadWrite(prefix)
-	require.NoError(t, jobsDB.Start())
-	defer jobsDB.TearDown()
-	require.NoError(t, jobsDB.Store(context.Background(), generateJobs(2, destinationID)))
-	unprocessed, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
-	require.NoError(t, err)
-	require.Equal(t, 2, len(unprocessed.Jobs))
+		var jobsDB testJobsDB
+		prefix := strings.ToLower(rsRand.String(5))
+		destinationID := strings.ToLower(rsRand.String(5))
+		jobsDB = newJobsDB(prefix)
+		defer jobsDB.TearDown()
+		require.NoError(t, jobsDB.Store(context.Background(), generateJobs(2, destinationID)))
+		unprocessed, err := jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
+		require.NoError(t, err)
+		require.Equal(t, 2, len(unprocessed.Jobs))
 
-	var statuses []*JobStatusT
-	for _, job := range unprocessed.Jobs {
-		statuses = append(statuses, &JobStatusT{
-			JobID:         job.JobID,
-			JobState:      Executing.State,
-			AttemptNum:    1,
-			ExecTime:      time.Now(),
-			RetryTime:     time.Now(),
-			ErrorCode:     "",
-			ErrorResponse: []byte(`{}`),
-			Parameters:    []byte(`{}`),
-			WorkspaceId:   defaultWorkspaceID,
-		})
-	}
-	require.NoError(t, jobsDB.UpdateJobStatus(context.Background(), statuses, []string{customVal}, []ParameterFilterT{}))
+		var statuses []*JobStatusT
+		for _, job := range unprocessed.Jobs {
+			statuses = append(statuses, &JobStatusT{
+				JobID:         job.JobID,
+				JobState:      Executing.State,
+				AttemptNum:    1,
+				ExecTime:      time.Now(),
+				RetryTime:     time.Now(),
+				ErrorCode:     "",
+				ErrorResponse: []byte(`{}`),
+				Parameters:    []byte(`{}`),
+				WorkspaceId:   defaultWorkspaceID,
+			})
+		}
+		require.NoError(t, jobsDB.UpdateJobStatus(context.Background(), statuses, []string{customVal}, []ParameterFilterT{}))
 
-	unprocessed, err = jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
-	require.NoError(t, err)
-	require.Equal(t, 0, len(unprocessed.Jobs))
+		unprocessed, err = jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
+		require.NoError(t, err)
+		require.Equal(t, 0, len(unprocessed.Jobs))
 
-	jobsDB.FailExecuting()
+		jobsDB.FailExecuting()
 
-	unprocessed, err = jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
-	require.NoError(t, err)
-	require.Equal(t, 0, len(unprocessed.Jobs))
+		unprocessed, err = jobsDB.GetUnprocessed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
+		require.NoError(t, err)
+		require.Equal(t, 0, len(unprocessed.Jobs))
 
-	failed, err := jobsDB.GetFailed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
-	require.NoError(t, err)
-	require.Equal(t, 2, len(failed.Jobs))
+		failed, err := jobsDB.GetFailed(context.Background(), GetQueryParams{CustomValFilters: []string{customVal}, ParameterFilters: []ParameterFilterT{{Name: "destination_id", Value: destinationID}}, JobsLimit: 100})
+		require.NoError(t, err)
+		require.Equal(t, 2, len(failed.Jobs))
+	})
 }
 
 func TestMaxAgeCleanup(t *testing.T) {
@@ -1633,6 +1632,32 @@ func startPostgres(t testingT) *postgres.Resource {
 	return postgresContainer
 }
 
+// testJobsDB is a jobsdb of any storage backend
+type testJobsDB interface {
+	JobsDB
+	TearDown()
+}
+
+// forEachBackend runs the test against a started read-write jobsdb of each storage backend
+func forEachBackend(t *testing.T, test func(t *testing.T, newJobsDB func(prefix string) testJobsDB)) {
+	t.Run("postgres", func(t *testing.T) {
+		_ = startPostgres(t)
+		test(t, func(prefix string) testJobsDB {
+			jobsDB := NewForReadWrite(prefix)
+			require.NoError(t, jobsDB.Start())
+			return jobsDB
+		})
+	})
+	t.Run("embedded", func(t *testing.T) {
+		dir := t.TempDir()
+		test(t, func(prefix string) testJobsDB {
+			jobsDB := NewEmbeddedForReadWrite(prefix, WithEmbeddedDir(dir))
+			require.NoError(t, jobsDB.Start())
+			return jobsDB
+		})
+	})
+}
+
 func initJobsDB() {
 	config.Reset()
 	logger.Reset()
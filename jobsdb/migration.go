@@ -82,6 +82,20 @@ func (jd *Handle) doMigrateDS(ctx context.Context) error {
 	if len(migrateFrom) == 0 {
 		return nil
 	}
+	// terminal jobs are snapshotted before acquiring the migration lock, so that it isn't held for as long as a snapshot takes,
+	// and the ones becoming terminal in the meantime, i.e. after the latest status of their dataset at this point, while holding it
+	var watermarks map[string]int64
+	if jd.snapshotEnabled(snapshotMigrateDS) {
+		migrateFromDatasets := lo.Map(migrateFrom, func(ds dsWithPendingJobCount, _ int) dataSetT { return ds.ds })
+		if watermarks, err = jd.statusWatermarks(ctx, migrateFromDatasets); err != nil {
+			return err
+		}
+		if !jd.snapshotBefore(ctx, snapshotMigrateDS, migrateFromDatasets, false, func(ds dataSetT) string {
+			return fmt.Sprintf(`s.job_state = ANY($1) AND s.id <= %d`, watermarks[ds.Index])
+		}, pq.Array(validTerminalStates)) {
+			return nil
+		}
+	}
 	var l lock.LockToken
 	var lockChan chan<- lock.LockToken
 
@@ -104,6 +118,13 @@ func (jd *Handle) doMigrateDS(ctx context.Context) error {
 			}
 
 			migrateFromDatasets := lo.Map(migrateFrom, func(ds dsWithPendingJobCount, _ int) dataSetT { return ds.ds })
+			// pending jobs are copied over, the terminal ones are dropped along with their datasets.
+			// The rest of the snapshot is taken while holding the migration lock, so that no more jobs can become terminal in the meantime
+			if !jd.snapshotBefore(ctx, snapshotMigrateDS, migrateFromDatasets, false, func(ds dataSetT) string {
+				return fmt.Sprintf(`s.job_state = ANY($1) AND s.id > %d`, watermarks[ds.Index])
+			}, pq.Array(validTerminalStates)) {
+				return nil
+			}
 			if pendingJobsCount > 0 { // migrate incomplete jobs
 				var destination dataSetT
 				if err := jd.dsListLock.WithLockInCtx(ctx, func(l lock.LockToken) error {
@@ -332,6 +353,12 @@ func (jd *Handle) cleanupStatusTables(ctx context.Context, dsList []dataSetT) er
 	if err != nil {
 		return err
 	}
+	// all statuses but the latest one of each job are deleted
+	if !jd.snapshotBefore(ctx, snapshotCleanupStatusTables, toCompact, true, func(ds dataSetT) string {
+		return fmt.Sprintf(`j.job_id IN (SELECT job_id FROM %q GROUP BY job_id HAVING COUNT(*) > 1)`, ds.JobStatusTable)
+	}) {
+		return nil
+	}
 	start := time.Now()
 	defer jd.stats.NewTaggedStat(
 		"jobsdb_compact_status_tables",
@@ -542,6 +569,27 @@ func (jd *Handle) migrateJobsInTx(ctx context.Context, tx *Tx, srcDS, destDS dat
 	if err = rows.Err(); err != nil {
 		return 0, fmt.Errorf("rows.Err() on column types: %w", err)
 	}
+	srcEncoding, err := jd.payloadEncodingOf(ctx, tx, srcDS)
+	if err != nil {
+		return 0, err
+	}
+	destEncoding, err := jd.payloadEncodingOf(ctx, tx, destDS)
+	if err != nil {
+		return 0, err
+	}
+	if srcEncoding.compressed != destEncoding.compressed {
+		// payloads can only be (de)compressed by us, not by the database
+		numJobsMigrated, err := jd.migrateCompressedJobsInTx(ctx, tx, srcDS, destDS, destEncoding, columnTypeMap[destDS.JobTable])
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`ANALYZE %q, %q`, destDS.JobTable, destDS.JobStatusTable)); err != nil {
+			return 0, err
+		}
+		return numJobsMigrated, nil
+	}
+	// Payloads are copied as is between datasets which are both compressed or both uncompressed,
+	// compressed payloads being decompressed with any dictionary, regardless of the one of their dataset.
 	payloadLiteral, err := getColumnConversion(columnTypeMap[srcDS.JobTable], columnTypeMap[destDS.JobTable])
 	if err != nil {
 		return 0, err
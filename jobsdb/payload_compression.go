@@ -0,0 +1,421 @@
+package jobsdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/lib/pq"
+
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+	. "github.com/rudderlabs/rudder-server/utils/tx" //nolint:staticcheck
+)
+
+// zstdMagic is the magic number every zstd frame starts with.
+// Since no valid json document can start with it, payloads starting with it are always compressed ones.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// payloadEncoding is the encoding used for storing the payloads of a dataset.
+//
+// Compressed datasets use a bytea payload column having the encoding as its comment, e.g. 'zstd' or 'zstd:<dictionary id>'.
+// A compressed dataset may still contain uncompressed payloads, e.g. the ones migrated from an uncompressed dataset,
+// which is fine since compressed payloads are always recognised by their zstd frame header.
+type payloadEncoding struct {
+	compressed   bool
+	dictionaryID uint32
+}
+
+func (e payloadEncoding) String() string {
+	if !e.compressed {
+		return ""
+	}
+	if e.dictionaryID == 0 {
+		return "zstd"
+	}
+	return "zstd:" + strconv.FormatUint(uint64(e.dictionaryID), 10)
+}
+
+func parsePayloadEncoding(s string) (payloadEncoding, error) {
+	if s == "" {
+		return payloadEncoding{}, nil
+	}
+	algo, id, hasDictionary := strings.Cut(s, ":")
+	if algo != "zstd" {
+		return payloadEncoding{}, fmt.Errorf("unsupported payload encoding %q", s)
+	}
+	if !hasDictionary {
+		return payloadEncoding{compressed: true}, nil
+	}
+	dictionaryID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return payloadEncoding{}, fmt.Errorf("invalid dictionary id in payload encoding %q: %w", s, err)
+	}
+	return payloadEncoding{compressed: true, dictionaryID: uint32(dictionaryID)}, nil
+}
+
+// payloadCodec compresses and decompresses job payloads using zstd, optionally with one of the dictionaries trained for the table prefix.
+type payloadCodec struct {
+	level            zstd.EncoderLevel
+	loadDictionary   func(ctx context.Context, dictionaryID uint32) ([]byte, error)
+	loadDictionaries func(ctx context.Context) ([][]byte, error)
+
+	mu           sync.RWMutex
+	encoders     map[uint32]*zstd.Encoder // dictionary id => encoder, 0 for no dictionary
+	decoder      *zstd.Decoder
+	decoderDicts int
+}
+
+func (c *payloadCodec) encoder(ctx context.Context, dictionaryID uint32) (*zstd.Encoder, error) {
+	c.mu.RLock()
+	enc, ok := c.encoders[dictionaryID]
+	c.mu.RUnlock()
+	if ok {
+		return enc, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if enc, ok := c.encoders[dictionaryID]; ok {
+		return enc, nil
+	}
+	opts := []zstd.EOption{zstd.WithEncoderLevel(c.level), zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true)}
+	if dictionaryID != 0 {
+		d, err := c.loadDictionary(ctx, dictionaryID)
+		if err != nil {
+			return nil, fmt.Errorf("loading payload dictionary %d: %w", dictionaryID, err)
+		}
+		opts = append(opts, zstd.WithEncoderDict(d))
+	}
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating zstd encoder: %w", err)
+	}
+	if c.encoders == nil {
+		c.encoders = make(map[uint32]*zstd.Encoder)
+	}
+	c.encoders[dictionaryID] = enc
+	return enc, nil
+}
+
+// encode compresses the payload according to the encoding, returning it as is if the encoding is not a compressed one.
+// Payloads which don't get smaller by compressing them are returned as is too, so that the size of a stored payload
+// is never bigger than the size of the payload itself.
+func (c *payloadCodec) encode(ctx context.Context, encoding payloadEncoding, payload []byte) ([]byte, error) {
+	if !encoding.compressed {
+		return payload, nil
+	}
+	enc, err := c.encoder(ctx, encoding.dictionaryID)
+	if err != nil {
+		return nil, err
+	}
+	compressed := enc.EncodeAll(payload, make([]byte, 0, len(payload)/4))
+	if len(compressed) >= len(payload) {
+		return payload, nil
+	}
+	return compressed, nil
+}
+
+// decode decompresses the payload if it is a compressed one, otherwise it returns it as is
+func (c *payloadCodec) decode(ctx context.Context, payload []byte) ([]byte, error) {
+	if !bytes.HasPrefix(payload, zstdMagic) {
+		return payload, nil
+	}
+	dec, err := c.getDecoder(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := dec.DecodeAll(payload, nil)
+	if errors.Is(err, zstd.ErrUnknownDictionary) { // a dictionary trained after our decoder got created
+		if dec, err = c.getDecoder(ctx, true); err != nil {
+			return nil, err
+		}
+		decoded, err = dec.DecodeAll(payload, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decompressing payload: %w", err)
+	}
+	return decoded, nil
+}
+
+func (c *payloadCodec) getDecoder(ctx context.Context, reload bool) (*zstd.Decoder, error) {
+	c.mu.RLock()
+	dec := c.decoder
+	c.mu.RUnlock()
+	if dec != nil && !reload {
+		return dec, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.decoder != nil && c.decoder != dec { // someone else already (re)loaded it
+		return c.decoder, nil
+	}
+	dictionaries, err := c.loadDictionaries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading payload dictionaries: %w", err)
+	}
+	if c.decoder != nil && len(dictionaries) == c.decoderDicts {
+		return nil, zstd.ErrUnknownDictionary
+	}
+	newDec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderDicts(dictionaries...))
+	if err != nil {
+		return nil, fmt.Errorf("creating zstd decoder: %w", err)
+	}
+	// the previous decoder is not closed, since it might still be in use. It is only used for DecodeAll, so it holds no goroutines
+	c.decoder, c.decoderDicts = newDec, len(dictionaries)
+	return newDec, nil
+}
+
+func (jd *Handle) payloadDictionariesTable() string {
+	return jd.tablePrefix + "_payload_dictionaries"
+}
+
+func (jd *Handle) newPayloadCodec() *payloadCodec {
+	_, level := zstd.EncoderLevelFromString(jd.conf.payloadCompression.level)
+	return &payloadCodec{
+		level: level,
+		loadDictionary: func(ctx context.Context, dictionaryID uint32) ([]byte, error) {
+			var d []byte
+			err := jd.dbHandle.QueryRowContext(ctx, fmt.Sprintf(`SELECT dictionary FROM %q WHERE dict_id = $1`, jd.payloadDictionariesTable()), int64(dictionaryID)).Scan(&d)
+			return d, err
+		},
+		loadDictionaries: func(ctx context.Context) ([][]byte, error) {
+			rows, err := jd.dbHandle.QueryContext(ctx, fmt.Sprintf(`SELECT dictionary FROM %q ORDER BY created_at`, jd.payloadDictionariesTable()))
+			if err != nil {
+				return nil, err
+			}
+			defer func() { _ = rows.Close() }()
+			var dictionaries [][]byte
+			for rows.Next() {
+				var d []byte
+				if err := rows.Scan(&d); err != nil {
+					return nil, err
+				}
+				dictionaries = append(dictionaries, d)
+			}
+			return dictionaries, rows.Err()
+		},
+	}
+}
+
+// newDSPayloadEncodingInTx returns the payload encoding to be used by a new dataset, according to the current configuration
+func (jd *Handle) newDSPayloadEncodingInTx(ctx context.Context, tx *Tx) (payloadEncoding, error) {
+	if !jd.conf.payloadCompression.enabled.Load() {
+		return payloadEncoding{}, nil
+	}
+	encoding := payloadEncoding{compressed: true}
+	if !jd.conf.payloadCompression.dictionaryEnabled.Load() {
+		return encoding, nil
+	}
+	var dictionaryID int64
+	err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT dict_id FROM %q ORDER BY created_at DESC LIMIT 1`, jd.payloadDictionariesTable())).Scan(&dictionaryID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return payloadEncoding{}, fmt.Errorf("getting latest payload dictionary: %w", err)
+	}
+	encoding.dictionaryID = uint32(dictionaryID)
+	return encoding, nil
+}
+
+// payloadEncodingOf returns the payload encoding of a dataset. Since a dataset's encoding never changes, it is cached after its first lookup.
+func (jd *Handle) payloadEncodingOf(ctx context.Context, db queryContexter, ds dataSetT) (payloadEncoding, error) {
+	if encoding, ok := jd.payloadEncodings.Load(ds.JobTable); ok {
+		return encoding.(payloadEncoding), nil
+	}
+	var comment sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT col_description(c.oid, a.attnum)
+		FROM pg_class c JOIN pg_attribute a ON a.attrelid = c.oid
+		WHERE c.relname = $1 AND a.attname = 'event_payload' AND pg_table_is_visible(c.oid)`, ds.JobTable).Scan(&comment); err != nil {
+		if errors.Is(err, sql.ErrNoRows) { // e.g. a dataset being dropped
+			return payloadEncoding{}, nil
+		}
+		return payloadEncoding{}, fmt.Errorf("getting payload encoding of %s: %w", ds.JobTable, err)
+	}
+	encoding, err := parsePayloadEncoding(comment.String)
+	if err != nil {
+		return payloadEncoding{}, err
+	}
+	jd.payloadEncodings.Store(ds.JobTable, encoding)
+	return encoding, nil
+}
+
+// payloadDictionaryLoop periodically trains a new compression dictionary using payloads of the latest dataset.
+// New datasets use the latest dictionary, whereas all dictionaries are kept for decompressing payloads of older datasets.
+func (jd *Handle) payloadDictionaryLoop(ctx context.Context) {
+	for {
+		if jd.conf.payloadCompression.enabled.Load() && jd.conf.payloadCompression.dictionaryEnabled.Load() {
+			if err := jd.trainPayloadDictionary(ctx); err != nil && ctx.Err() == nil {
+				jd.logger.Warnn("Training payload compression dictionary", obskit.Error(err))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jd.conf.payloadCompression.dictionaryTrainInterval.Load()):
+		}
+	}
+}
+
+// trainPayloadDictionary trains a new dictionary if the latest one is older than the training interval
+func (jd *Handle) trainPayloadDictionary(ctx context.Context) error {
+	var lastTrained sql.NullTime
+	if err := jd.dbHandle.QueryRowContext(ctx, fmt.Sprintf(`SELECT MAX(created_at) FROM %q`, jd.payloadDictionariesTable())).Scan(&lastTrained); err != nil {
+		return fmt.Errorf("getting latest payload dictionary: %w", err)
+	}
+	if lastTrained.Valid && time.Since(lastTrained.Time) < jd.conf.payloadCompression.dictionaryTrainInterval.Load() {
+		return nil
+	}
+	if !jd.dsListLock.RTryLockWithCtx(ctx) {
+		return fmt.Errorf("could not acquire a dslist read lock: %w", ctx.Err())
+	}
+	dsList := jd.getDSList()
+	jd.dsListLock.RUnlock()
+	if len(dsList) == 0 {
+		return nil
+	}
+	ds := dsList[len(dsList)-1]
+	rows, err := jd.dbHandle.QueryContext(ctx, fmt.Sprintf(`SELECT event_payload FROM %q ORDER BY job_id DESC LIMIT $1`, ds.JobTable), jd.conf.payloadCompression.dictionarySamples.Load())
+	if err != nil {
+		return fmt.Errorf("sampling payloads of %s: %w", ds.JobTable, err)
+	}
+	defer func() { _ = rows.Close() }()
+	var samples [][]byte
+	for rows.Next() {
+		var payload []byte
+		if err := rows.Scan(&payload); err != nil {
+			return fmt.Errorf("scanning payload sample: %w", err)
+		}
+		if payload, err = jd.payloadCodec.decode(ctx, payload); err != nil {
+			return err
+		}
+		samples = append(samples, payload)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sampling payloads of %s: %w", ds.JobTable, err)
+	}
+	if len(samples) < minPayloadDictionarySamples {
+		jd.logger.Debugn("Not enough payloads for training a compression dictionary", logger.NewIntField("samples", int64(len(samples))))
+		return nil
+	}
+
+	start := time.Now()
+	d, err := dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: jd.conf.payloadCompression.dictionaryMaxSize.Load(),
+		HashBytes:   6,
+		ZstdLevel:   jd.payloadCodec.level,
+	})
+	if err != nil {
+		return fmt.Errorf("building dictionary: %w", err)
+	}
+	info, err := zstd.InspectDictionary(d)
+	if err != nil {
+		return fmt.Errorf("inspecting dictionary: %w", err)
+	}
+	if _, err := jd.dbHandle.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %q (dict_id, dictionary) VALUES ($1, $2) ON CONFLICT DO NOTHING`, jd.payloadDictionariesTable()), int64(info.ID()), d); err != nil {
+		return fmt.Errorf("storing dictionary: %w", err)
+	}
+	jd.stats.NewTaggedStat("jobsdb_payload_dictionary_train_time", stats.TimerType, stats.Tags{"customVal": jd.tablePrefix}).Since(start)
+	jd.logger.Infon("Trained a new payload compression dictionary",
+		logger.NewIntField("dictionaryID", int64(info.ID())),
+		logger.NewIntField("size", int64(len(d))),
+		logger.NewIntField("samples", int64(len(samples))),
+	)
+	return nil
+}
+
+// minPayloadDictionarySamples is the minimum number of payloads needed for training a dictionary
+const minPayloadDictionarySamples = 100
+
+// migrateCompressedJobsInTx migrates the non-terminal jobs of a dataset to a dataset whose payloads are compressed differently,
+// i.e. from a compressed dataset to an uncompressed one or the other way around.
+// Since payloads cannot be (de)compressed by the database, jobs are read, decompressed, encoded according to the destination's
+// payload encoding and copied to the destination dataset in batches.
+func (jd *Handle) migrateCompressedJobsInTx(ctx context.Context, tx *Tx, srcDS, destDS dataSetT, destEncoding payloadEncoding, destColumnType string) (int, error) {
+	const batchSize = 1000
+	type row struct {
+		jobID                                int64
+		workspaceID, uuid, userID, customVal string
+		parameters, payload                  []byte
+		eventCount                           int
+		createdAt, expireAt                  time.Time
+	}
+	var numJobsMigrated int
+	var lastJobID int64
+	for {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+			`SELECT j.job_id, j.workspace_id, j.uuid, j.user_id, j.custom_val, j.parameters, j.event_payload, j.event_count, j.created_at, j.expire_at
+			FROM %[1]q j LEFT JOIN "v_last_%[2]s" js ON js.job_id = j.job_id
+			WHERE j.job_id > $1 AND (js.job_id IS NULL OR js.job_state = ANY($2))
+			ORDER BY j.job_id LIMIT $3`, srcDS.JobTable, srcDS.JobStatusTable),
+			lastJobID, pq.Array(validNonTerminalStates), batchSize,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("reading jobs of %s: %w", srcDS.JobTable, err)
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.jobID, &r.workspaceID, &r.uuid, &r.userID, &r.customVal, &r.parameters, &r.payload, &r.eventCount, &r.createdAt, &r.expireAt); err != nil {
+				_ = rows.Close()
+				return 0, fmt.Errorf("scanning jobs of %s: %w", srcDS.JobTable, err)
+			}
+			batch = append(batch, r)
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("reading jobs of %s: %w", srcDS.JobTable, err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		stmt, err := tx.PrepareContext(ctx, pq.CopyIn(destDS.JobTable, "job_id", "workspace_id", "uuid", "user_id", "custom_val", "parameters", "event_payload", "event_count", "created_at", "expire_at"))
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range batch {
+			payload, err := jd.payloadCodec.decode(ctx, r.payload)
+			if err != nil {
+				_ = stmt.Close()
+				return 0, fmt.Errorf("job %d: %w", r.jobID, err)
+			}
+			if payload, err = jd.payloadCodec.encode(ctx, destEncoding, payload); err != nil {
+				_ = stmt.Close()
+				return 0, fmt.Errorf("job %d: %w", r.jobID, err)
+			}
+			var value any = string(payload)
+			if destColumnType == string(BYTEA) {
+				value = payload
+			}
+			if _, err := stmt.ExecContext(ctx, r.jobID, r.workspaceID, r.uuid, r.userID, r.customVal, string(r.parameters), value, r.eventCount, r.createdAt, r.expireAt); err != nil {
+				_ = stmt.Close()
+				return 0, err
+			}
+		}
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			_ = stmt.Close()
+			return 0, err
+		}
+		if err := stmt.Close(); err != nil {
+			return 0, err
+		}
+		numJobsMigrated += len(batch)
+		lastJobID = batch[len(batch)-1].jobID
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+		`INSERT INTO %[1]q (job_id, job_state, attempt, exec_time, retry_time, error_code, error_response, parameters)
+		(SELECT job_id, job_state, attempt, exec_time, retry_time, error_code, error_response, parameters FROM "v_last_%[2]s" WHERE job_state = ANY($1))`,
+		destDS.JobStatusTable, srcDS.JobStatusTable), pq.Array(validNonTerminalStates),
+	); err != nil {
+		return 0, fmt.Errorf("migrating job statuses of %s: %w", srcDS.JobStatusTable, err)
+	}
+	return numJobsMigrated, nil
+}
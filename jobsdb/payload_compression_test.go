@@ -0,0 +1,226 @@
+package jobsdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-server/jobsdb/internal/lock"
+	. "github.com/rudderlabs/rudder-server/utils/tx" //nolint:staticcheck
+)
+
+func samplePayload(i int) []byte {
+	return []byte(fmt.Sprintf(`{"type":"track","event":"Order Completed %[1]d","userId":"user-%[1]d","messageId":"message-%[1]d","context":{"library":{"name":"rudder-sdk-js","version":"3.0.%[2]d"},"traits":{"email":"user-%[1]d@example.com"}},"properties":{"revenue":%[1]d,"currency":"EUR"}}`, i, i%10))
+}
+
+func TestPayloadEncoding(t *testing.T) {
+	for _, encoding := range []payloadEncoding{{}, {compressed: true}, {compressed: true, dictionaryID: 1234}} {
+		parsed, err := parsePayloadEncoding(encoding.String())
+		require.NoError(t, err)
+		require.Equal(t, encoding, parsed)
+	}
+	_, err := parsePayloadEncoding("lz4")
+	require.Error(t, err)
+	_, err = parsePayloadEncoding("zstd:abc")
+	require.Error(t, err)
+}
+
+func TestPayloadCodec(t *testing.T) {
+	ctx := context.Background()
+	var samples [][]byte
+	for i := 0; i < 500; i++ {
+		samples = append(samples, samplePayload(i))
+	}
+	d, err := dict.BuildZstdDict(samples, dict.Options{MaxDictSize: 16 * 1024, HashBytes: 6})
+	require.NoError(t, err)
+	info, err := zstd.InspectDictionary(d)
+	require.NoError(t, err)
+
+	var dictionaries [][]byte
+	codec := &payloadCodec{
+		level: zstd.SpeedDefault,
+		loadDictionary: func(_ context.Context, id uint32) ([]byte, error) {
+			require.Equal(t, info.ID(), id)
+			return d, nil
+		},
+		loadDictionaries: func(context.Context) ([][]byte, error) {
+			return dictionaries, nil
+		},
+	}
+
+	t.Run("uncompressed payloads are returned as is", func(t *testing.T) {
+		payload := samplePayload(1)
+		encoded, err := codec.encode(ctx, payloadEncoding{}, payload)
+		require.NoError(t, err)
+		require.Equal(t, payload, encoded)
+		decoded, err := codec.decode(ctx, payload)
+		require.NoError(t, err)
+		require.Equal(t, payload, decoded)
+	})
+
+	t.Run("without dictionary", func(t *testing.T) {
+		payload := samplePayload(2)
+		encoded, err := codec.encode(ctx, payloadEncoding{compressed: true}, payload)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(encoded, zstdMagic))
+		decoded, err := codec.decode(ctx, encoded)
+		require.NoError(t, err)
+		require.Equal(t, payload, decoded)
+	})
+
+	t.Run("payloads which don't get smaller are stored as is", func(t *testing.T) {
+		payload := []byte(`{"a":1}`)
+		encoded, err := codec.encode(ctx, payloadEncoding{compressed: true}, payload)
+		require.NoError(t, err)
+		require.Equal(t, payload, encoded)
+		decoded, err := codec.decode(ctx, encoded)
+		require.NoError(t, err)
+		require.Equal(t, payload, decoded)
+	})
+
+	t.Run("with a dictionary trained after the decoder got created", func(t *testing.T) {
+		payload := samplePayload(1000)
+		encoded, err := codec.encode(ctx, payloadEncoding{compressed: true, dictionaryID: info.ID()}, payload)
+		require.NoError(t, err)
+		withoutDictionary, err := codec.encode(ctx, payloadEncoding{compressed: true}, payload)
+		require.NoError(t, err)
+		require.Less(t, len(encoded), len(withoutDictionary))
+
+		_, err = codec.decode(ctx, encoded)
+		require.ErrorIs(t, err, zstd.ErrUnknownDictionary, "dictionary is not stored yet")
+
+		dictionaries = append(dictionaries, d)
+		decoded, err := codec.decode(ctx, encoded)
+		require.NoError(t, err)
+		require.Equal(t, payload, decoded)
+	})
+}
+
+func TestPayloadCompression(t *testing.T) {
+	_ = startPostgres(t)
+	ctx := context.Background()
+	c := config.New()
+	c.Set("JobsDB.payloadCompression.enabled", true)
+	c.Set("JobsDB.payloadCompression.dictionary.enabled", true)
+	jd := NewForReadWrite("compressed", WithConfig(c), WithStats(stats.NOP))
+	require.NoError(t, jd.Start())
+	defer jd.TearDown()
+
+	jobs := genJobs("ws", "cv", 200, 1)
+	for i := range jobs {
+		jobs[i].EventPayload = samplePayload(i)
+	}
+	require.NoError(t, jd.Store(ctx, jobs))
+
+	var columnType string
+	require.NoError(t, jd.dbHandle.QueryRow(`SELECT data_type FROM information_schema.columns WHERE table_name = 'compressed_jobs_1' AND column_name = 'event_payload'`).Scan(&columnType))
+	require.Equal(t, string(BYTEA), columnType)
+	var stored []byte
+	require.NoError(t, jd.dbHandle.QueryRow(`SELECT event_payload FROM compressed_jobs_1 WHERE job_id = 1`).Scan(&stored))
+	require.True(t, bytes.HasPrefix(stored, zstdMagic), "payloads should be stored compressed")
+
+	res, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 1000})
+	require.NoError(t, err)
+	require.Len(t, res.Jobs, len(jobs))
+	for i := range res.Jobs {
+		require.Equal(t, string(jobs[i].EventPayload), string(res.Jobs[i].EventPayload))
+	}
+
+	res, err = jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 1000, PayloadSizeLimit: int64(len(samplePayload(0)) + len(samplePayload(1)))})
+	require.NoError(t, err)
+	require.Len(t, res.Jobs, 2, "payload size limit should apply to decompressed payloads")
+	require.True(t, res.LimitsReached)
+
+	t.Run("dictionary", func(t *testing.T) {
+		require.NoError(t, jd.trainPayloadDictionary(ctx))
+		var dictionaryID int64
+		require.NoError(t, jd.dbHandle.QueryRow(`SELECT dict_id FROM compressed_payload_dictionaries`).Scan(&dictionaryID))
+
+		require.NoError(t, jd.WithTx(func(tx *Tx) error {
+			return jd.createDSInTx(ctx, tx, newDataSet("compressed", "2"))
+		}))
+		jd.dsListLock.WithLock(func(l lock.LockToken) {
+			require.NoError(t, jd.doRefreshDSRangeList(l))
+		})
+		encoding, err := jd.payloadEncodingOf(ctx, jd.dbHandle, newDataSet("compressed", "2"))
+		require.NoError(t, err)
+		require.Equal(t, payloadEncoding{compressed: true, dictionaryID: uint32(dictionaryID)}, encoding)
+
+		newJobs := genJobs("ws", "cv", 10, 1)
+		for i := range newJobs {
+			newJobs[i].EventPayload = samplePayload(1000 + i)
+		}
+		require.NoError(t, jd.Store(ctx, newJobs))
+		res, err := jd.GetUnprocessed(ctx, GetQueryParams{JobsLimit: 1000})
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, len(jobs)+len(newJobs))
+		require.Equal(t, string(samplePayload(1009)), string(res.Jobs[len(res.Jobs)-1].EventPayload))
+	})
+
+	t.Run("migration to an uncompressed dataset", func(t *testing.T) {
+		c.Set("JobsDB.payloadCompression.enabled", false)
+		defer c.Set("JobsDB.payloadCompression.enabled", true)
+		src := newDataSet("compressed", "1")
+		dest := newDataSet("compressed", "1_1")
+		require.NoError(t, jd.WithTx(func(tx *Tx) error {
+			if err := jd.createDSInTx(ctx, tx, dest); err != nil {
+				return err
+			}
+			migrated, err := jd.migrateJobsInTx(ctx, tx, src, dest)
+			require.Equal(t, len(jobs), migrated)
+			return err
+		}))
+		var payload string
+		require.NoError(t, jd.dbHandle.QueryRow(`SELECT event_payload FROM compressed_jobs_1_1 WHERE job_id = 1`).Scan(&payload))
+		require.Equal(t, string(samplePayload(0)), payload)
+	})
+
+	t.Run("migration to a compressed dataset", func(t *testing.T) {
+		migrate := func(src, dest dataSetT) {
+			require.NoError(t, jd.WithTx(func(tx *Tx) error {
+				if err := jd.createDSInTx(ctx, tx, dest); err != nil {
+					return err
+				}
+				migrated, err := jd.migrateJobsInTx(ctx, tx, src, dest)
+				require.Equal(t, len(jobs), migrated)
+				return err
+			}))
+		}
+		requireCompressed := func(dest dataSetT) {
+			rows, err := jd.dbHandle.Query(fmt.Sprintf(`SELECT job_id, event_payload FROM %q ORDER BY job_id`, dest.JobTable))
+			require.NoError(t, err)
+			defer func() { _ = rows.Close() }()
+			var i int
+			for rows.Next() {
+				var (
+					jobID  int64
+					stored []byte
+				)
+				require.NoError(t, rows.Scan(&jobID, &stored))
+				require.True(t, bytes.HasPrefix(stored, zstdMagic), "job %d should have been stored compressed", jobID)
+				payload, err := jd.payloadCodec.decode(ctx, stored)
+				require.NoError(t, err)
+				require.Equal(t, string(samplePayload(i)), string(payload))
+				i++
+			}
+			require.NoError(t, rows.Err())
+			require.Equal(t, len(jobs), i)
+		}
+
+		// from the uncompressed dataset of the previous migration
+		dest := newDataSet("compressed", "1_2")
+		migrate(newDataSet("compressed", "1_1"), dest)
+		requireCompressed(dest)
+
+		dest = newDataSet("compressed", "1_3")
+		migrate(newDataSet("compressed", "1"), dest)
+		requireCompressed(dest)
+	})
+}
@@ -0,0 +1,164 @@
+package jobsdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+)
+
+// Job priorities, stored in the priority parameter of a job. Jobs without a valid priority parameter have the default priority.
+// Priorities only affect the order in which jobs are returned by GetToProcess when [GetQueryParams.PriorityLanes] is enabled,
+// which consumers guaranteeing the order of user events must not do, since jobs of a user with different priorities get reordered.
+const (
+	PriorityHigh    = 1
+	PriorityDefault = 0
+	PriorityLow     = -1
+)
+
+// priorityLanes are the priorities of GetToProcess lanes, highest first
+var priorityLanes = []int{PriorityHigh, PriorityDefault, PriorityLow}
+
+var errPriorityLanesDisabled = errors.New("priority lanes are not enabled for this jobsdb")
+
+// PriorityLanesEnabled returns whether the jobsdb with the table prefix serves priority lanes, which is the only switch of priority lanes:
+// such a jobsdb indexes the priority of jobs in all of its datasets, while querying any other jobsdb with priority lanes fails.
+// It is set with JobsDB.<tablePrefix>.priorityLanes.enabled, which defaults to Router.priorityLanes.enabled for the router's jobsdb.
+func PriorityLanesEnabled(c *config.Config, tablePrefix string) bool {
+	keys := []string{"JobsDB." + tablePrefix + ".priorityLanes.enabled"}
+	if tablePrefix == "rt" {
+		keys = append(keys, "Router.priorityLanes.enabled")
+	}
+	return c.GetBoolVar(false, keys...)
+}
+
+// priorityExpr returns the priority of a job, or the default priority if its priority parameter is not an integer.
+// The datasets of a jobsdb have an index on it when priority lanes are enabled, created with an empty alias.
+func priorityExpr(alias string) string {
+	param := "parameters->>'priority'"
+	if alias != "" {
+		param = alias + "." + param
+	}
+	return fmt.Sprintf(`CASE WHEN %[1]s ~ '^-?[0-9]{1,9}$' THEN (%[1]s)::int ELSE %[2]d END`, param, PriorityDefault)
+}
+
+// priorityLaneQuery returns the condition for jobs belonging to a priority lane.
+// Jobs with an unknown priority belong to the closest lane.
+func priorityLaneQuery(alias string, priority int) string {
+	expr := "(" + priorityExpr(alias) + ")"
+	switch priority {
+	case PriorityHigh:
+		return expr + fmt.Sprintf(" >= %d", PriorityHigh)
+	case PriorityLow:
+		return expr + fmt.Sprintf(" <= %d", PriorityLow)
+	default:
+		return expr + fmt.Sprintf(" = %d", priority)
+	}
+}
+
+// getJobsByPriority queries the priority lanes one after the other, highest first.
+//
+// Every lane gets the jobs limit left by the higher lanes, minus a share of the original limit
+// reserved for each of the lower lanes (priorityLanes.minShare), so that lower lanes cannot be starved by higher ones.
+// Reservations not used by lower lanes are given back to the higher lanes which had more jobs than their limit.
+// Events and payload size limits are shared by all lanes, in priority order.
+func (jd *Handle) getJobsByPriority(ctx context.Context, params GetQueryParams, mtoken *moreToken) (*MoreJobsResult, error) {
+	if mtoken.lanes == nil {
+		mtoken.lanes = make(map[int]*int64, len(priorityLanes))
+	}
+	res := &MoreJobsResult{More: mtoken}
+	reserved := max(1, int(float64(params.JobsLimit)*jd.conf.priorityLanes.minShare.Load()))
+	limitByEventCount := params.EventsLimit > 0
+	limitByPayloadSize := params.PayloadSizeLimit > 0
+
+	// queryLane queries a lane for up to jobsLimit jobs and returns whether the lane has more jobs than that
+	// and whether the events or payload size limits were reached
+	queryLane := func(lane, jobsLimit int) (more, limitsReached bool, err error) {
+		laneParams := params
+		laneParams.priority = &lane
+		laneParams.afterJobID = nil
+		laneParams.JobsLimit = jobsLimit
+		laneRes, err := jd.getJobs(ctx, laneParams, &moreToken{afterJobID: mtoken.lanes[lane]})
+		if err != nil {
+			return false, false, err
+		}
+		mtoken.lanes[lane] = laneRes.More.(*moreToken).afterJobID
+		res.Jobs = append(res.Jobs, laneRes.Jobs...)
+		res.EventsCount += laneRes.EventsCount
+		res.PayloadSize += laneRes.PayloadSize
+		params.JobsLimit -= len(laneRes.Jobs)
+		if limitByEventCount {
+			params.EventsLimit -= laneRes.EventsCount
+		}
+		if limitByPayloadSize {
+			params.PayloadSizeLimit -= laneRes.PayloadSize
+		}
+		if !laneRes.LimitsReached {
+			return false, false, nil
+		}
+		if len(laneRes.Jobs) == jobsLimit {
+			return true, false, nil
+		}
+		return false, true, nil
+	}
+
+	lanesWithMore := make([]int, 0, len(priorityLanes))
+	for i, lane := range priorityLanes {
+		jobsLimit := max(1, params.JobsLimit-reserved*(len(priorityLanes)-1-i))
+		more, limitsReached, err := queryLane(lane, jobsLimit)
+		if err != nil {
+			return nil, err
+		}
+		if limitsReached {
+			res.LimitsReached = true
+			return res, nil
+		}
+		if more {
+			lanesWithMore = append(lanesWithMore, lane)
+		}
+		if params.JobsLimit <= 0 {
+			res.LimitsReached = true
+			return res, nil
+		}
+	}
+	for _, lane := range lanesWithMore {
+		_, limitsReached, err := queryLane(lane, params.JobsLimit)
+		if err != nil {
+			return nil, err
+		}
+		if limitsReached || params.JobsLimit <= 0 {
+			res.LimitsReached = true
+			return res, nil
+		}
+	}
+	return res, nil
+}
+
+type execContexter interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// createPriorityIndex creates the priority index of a dataset, unless it already has one
+func createPriorityIndex(ctx context.Context, tx execContexter, ds dataSetT) error {
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "idx_%[1]s_priority" ON %[1]q USING BTREE ((%[2]s))`, ds.JobTable, priorityExpr(""))); err != nil {
+		return fmt.Errorf("creating priority index of %q: %w", ds.JobTable, err)
+	}
+	return nil
+}
+
+// indexPriorities creates the priority index of the datasets which were created before priority lanes got enabled
+func (jd *Handle) indexPriorities(ctx context.Context) error {
+	if !jd.conf.priorityLanes.enabled {
+		return nil
+	}
+	for _, ds := range jd.getDSList() {
+		if err := createPriorityIndex(ctx, jd.dbHandle, ds); err != nil {
+			return err
+		}
+	}
+	jd.logger.Infon("Indexed the priorities of existing datasets", logger.NewIntField("datasets", int64(len(jd.getDSList()))))
+	return nil
+}
@@ -0,0 +1,154 @@
+package jobsdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	. "github.com/rudderlabs/rudder-server/utils/tx" //nolint:staticcheck
+)
+
+func TestPriorityLanes(t *testing.T) {
+	_ = startPostgres(t)
+	ctx := context.Background()
+	c := config.New()
+	c.Set("JobsDB.priorityLanes.minShare", 0.1)
+	c.Set("JobsDB.prio.priorityLanes.enabled", true)
+	c.Set("JobsDB.prio_invalid.priorityLanes.enabled", true)
+	jd := NewForReadWrite("prio", WithConfig(c))
+	require.NoError(t, jd.Start())
+	defer jd.TearDown()
+
+	newJob := func(priority int) *JobT {
+		params := `{"source_id":"source-1","destination_id":"dest-1"}`
+		if priority != PriorityDefault {
+			params = fmt.Sprintf(`{"source_id":"source-1","destination_id":"dest-1","priority":%d}`, priority)
+		}
+		return &JobT{
+			UUID:         uuid.New(),
+			UserID:       "user-1",
+			CustomVal:    "WEBHOOK",
+			EventCount:   1,
+			EventPayload: []byte(`{}`),
+			Parameters:   []byte(params),
+			WorkspaceId:  "ws-1",
+		}
+	}
+	var jobs []*JobT
+	for range 20 {
+		jobs = append(jobs, newJob(PriorityLow))
+	}
+	for range 20 {
+		jobs = append(jobs, newJob(PriorityDefault))
+	}
+	for range 20 {
+		jobs = append(jobs, newJob(PriorityHigh))
+	}
+	require.NoError(t, jd.Store(ctx, jobs))
+
+	priorityOf := func(job *JobT) int {
+		switch {
+		case job.JobID > 40:
+			return PriorityHigh
+		case job.JobID > 20:
+			return PriorityDefault
+		default:
+			return PriorityLow
+		}
+	}
+	countByPriority := func(jobs []*JobT) map[int]int {
+		counts := map[int]int{}
+		for _, job := range jobs {
+			counts[priorityOf(job)]++
+		}
+		return counts
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}}, nil)
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 10)
+		require.Equal(t, map[int]int{PriorityLow: 10}, countByPriority(res.Jobs), "jobs should be returned in job id order")
+	})
+
+	t.Run("higher priorities first without starving lower ones", func(t *testing.T) {
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}, PriorityLanes: true}, nil)
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 10)
+		require.True(t, res.LimitsReached)
+		require.Equal(t, map[int]int{PriorityHigh: 8, PriorityDefault: 1, PriorityLow: 1}, countByPriority(res.Jobs))
+		require.Equal(t, PriorityHigh, priorityOf(res.Jobs[0]))
+	})
+
+	t.Run("unused reservations go to the higher lanes", func(t *testing.T) {
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 50, CustomValFilters: []string{"WEBHOOK"}, PriorityLanes: true}, nil)
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, 50)
+		require.Equal(t, map[int]int{PriorityHigh: 20, PriorityDefault: 20, PriorityLow: 10}, countByPriority(res.Jobs))
+	})
+
+	t.Run("more token", func(t *testing.T) {
+		params := GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}, PriorityLanes: true}
+		seen := map[int64]struct{}{}
+		var more MoreToken
+		for range 6 {
+			res, err := jd.GetToProcess(ctx, params, more)
+			require.NoError(t, err)
+			for _, job := range res.Jobs {
+				require.NotContains(t, seen, job.JobID, "job returned twice")
+				seen[job.JobID] = struct{}{}
+			}
+			more = res.More
+		}
+		require.Len(t, seen, 60)
+	})
+
+	t.Run("invalid priorities get the default one", func(t *testing.T) {
+		jd := NewForReadWrite("prio_invalid", WithConfig(c))
+		require.NoError(t, jd.Start())
+		defer jd.TearDown()
+
+		var jobs []*JobT
+		for _, priority := range []string{`"high"`, `1.5`, `99999999999`, `null`} {
+			job := newJob(PriorityDefault)
+			job.Parameters = []byte(fmt.Sprintf(`{"source_id":"source-1","destination_id":"dest-1","priority":%s}`, priority))
+			jobs = append(jobs, job)
+		}
+		require.NoError(t, jd.Store(ctx, jobs))
+
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}, PriorityLanes: true}, nil)
+		require.NoError(t, err)
+		require.Len(t, res.Jobs, len(jobs))
+	})
+
+	t.Run("priority index", func(t *testing.T) {
+		hasIndex := func(jd *Handle, ds dataSetT) bool {
+			var exists bool
+			require.NoError(t, jd.dbHandle.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = $1)`, "idx_"+ds.JobTable+"_priority").Scan(&exists))
+			return exists
+		}
+		require.True(t, hasIndex(jd, newDataSet("prio", "1")))
+
+		rt := NewForReadWrite("rt", WithConfig(config.New()))
+		require.NoError(t, rt.Start())
+		require.False(t, hasIndex(rt, newDataSet("rt", "1")), "priorities shouldn't be indexed while priority lanes are disabled")
+		_, err := rt.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, PriorityLanes: true}, nil)
+		require.ErrorIs(t, err, errPriorityLanesDisabled, "priority lanes shouldn't be served without the priority index")
+		rt.TearDown()
+
+		rc := config.New()
+		rc.Set("Router.priorityLanes.enabled", true)
+		rt = NewForReadWrite("rt", WithConfig(rc))
+		require.NoError(t, rt.Start())
+		defer rt.TearDown()
+		require.True(t, hasIndex(rt, newDataSet("rt", "1")), "datasets created before priority lanes got enabled should be indexed")
+		require.NoError(t, rt.WithTx(func(tx *Tx) error {
+			return rt.createDSInTx(ctx, tx, newDataSet("rt", "2"))
+		}))
+		require.True(t, hasIndex(rt, newDataSet("rt", "2")))
+	})
+}
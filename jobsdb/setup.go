@@ -53,6 +53,12 @@ func (jd *Handle) dropDatabaseTables(l lock.LockToken) {
 	jd.dropSchemaMigrationTables()
 	jd.assertError(jd.dropAllDS(l))
 	jd.dropJournal()
+	jd.dropPayloadDictionaries()
+}
+
+func (jd *Handle) dropPayloadDictionaries() {
+	_, err := jd.dbHandle.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %q`, jd.payloadDictionariesTable()))
+	jd.assertError(err)
 }
 
 func (jd *Handle) dropSchemaMigrationTables() {
@@ -0,0 +1,295 @@
+package jobsdb
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	"github.com/rudderlabs/rudder-go-kit/filemanager"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+)
+
+// Maintenance operations which can be preceded by a snapshot of the jobs they discard or rewrite, see snapshot.operations
+const (
+	snapshotMigrateDS           = "migrateDS"           // terminal jobs of the datasets being migrated
+	snapshotCleanupStatusTables = "cleanupStatusTables" // jobs with more than one status in the status tables being cleaned up, along with their status history
+	snapshotAbortOldJobs        = "abortOldJobs"        // non terminal jobs older than the job max age
+)
+
+// snapshotsPrefix is the object storage prefix under which snapshots are uploaded
+const snapshotsPrefix = "jobsdb-snapshots"
+
+// SnapshotStorage provides the object storage of a workspace, where the snapshots of its jobs are uploaded.
+// It is satisfied by a fileuploader.Provider.
+type SnapshotStorage interface {
+	GetFileManager(ctx context.Context, workspaceID string) (filemanager.FileManager, error)
+}
+
+// uploadedSnapshot is the snapshot of the jobs of a workspace
+type uploadedSnapshot struct {
+	workspaceID string
+	objectName  string
+	location    string
+	jobs        int
+}
+
+// snapshotFile is a snapshot being written
+type snapshotFile struct {
+	f    *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+	jobs int
+
+	firstCreatedAt, lastCreatedAt time.Time
+}
+
+// snapshotEnabled returns whether the jobs affected by a maintenance operation need to be snapshotted before it runs
+func (jd *Handle) snapshotEnabled(operation string) bool {
+	return jd.snapshotStorage != nil && slices.Contains(jd.conf.snapshot.operations.Load(), operation)
+}
+
+// snapshotBefore snapshots the jobs of the datasets satisfying the condition of their dataset, if snapshots are enabled for the operation,
+// and returns whether the operation can proceed.
+// Failures are logged and the operation needs to be skipped, until a later attempt manages to take its snapshot
+// or snapshot.maxConsecutiveFailures attempts in a row failed, in which case the operation proceeds without a snapshot, so that maintenance isn't blocked forever.
+func (jd *Handle) snapshotBefore(ctx context.Context, operation string, datasets []dataSetT, withHistory bool, condition func(ds dataSetT) string, args ...any) bool {
+	if len(datasets) == 0 || !jd.snapshotEnabled(operation) {
+		return true
+	}
+	start := time.Now()
+	tags := stats.Tags{"customVal": jd.tablePrefix, "operation": operation}
+	ctx, cancel := context.WithTimeout(ctx, jd.conf.snapshot.timeout.Load())
+	defer cancel()
+	snapshots, err := jd.snapshot(ctx, operation, datasets, withHistory, condition, args...)
+	jd.stats.NewTaggedStat("jobsdb_snapshot_time", stats.TimerType, tags).Since(start)
+	if err != nil {
+		jd.stats.NewTaggedStat("jobsdb_snapshot_errors", stats.CountType, tags).Increment()
+		failures := jd.snapshotFailed(operation)
+		if maxFailures := jd.conf.snapshot.maxConsecutiveFailures.Load(); maxFailures > 0 && failures >= maxFailures {
+			jd.stats.NewTaggedStat("jobsdb_snapshot_bypassed", stats.CountType, tags).Increment()
+			jd.logger.Errorn("Proceeding with maintenance operation without a snapshot, too many snapshots failed in a row",
+				logger.NewStringField("operation", operation),
+				logger.NewIntField("failures", int64(failures)),
+				obskit.Error(err),
+			)
+			jd.snapshotSucceeded(operation)
+			return true
+		}
+		jd.logger.Errorn("Skipping maintenance operation, its snapshot failed",
+			logger.NewStringField("operation", operation),
+			logger.NewIntField("failures", int64(failures)),
+			obskit.Error(err),
+		)
+		return false
+	}
+	jd.snapshotSucceeded(operation)
+	for _, s := range snapshots {
+		jd.logger.Infon("Snapshot uploaded",
+			logger.NewStringField("operation", operation),
+			obskit.WorkspaceID(s.workspaceID),
+			logger.NewStringField("objectName", s.objectName),
+			logger.NewStringField("location", s.location),
+			logger.NewIntField("jobs", int64(s.jobs)),
+		)
+		jd.stats.NewTaggedStat("jobsdb_snapshot_jobs", stats.CountType, stats.Tags{"customVal": jd.tablePrefix, "operation": operation, "workspaceId": s.workspaceID}).Count(s.jobs)
+	}
+	return true
+}
+
+// snapshotFailed records a failed snapshot of the operation and returns the number of its consecutive failures
+func (jd *Handle) snapshotFailed(operation string) int {
+	jd.snapshotFailuresMu.Lock()
+	defer jd.snapshotFailuresMu.Unlock()
+	if jd.snapshotFailures == nil {
+		jd.snapshotFailures = make(map[string]int)
+	}
+	jd.snapshotFailures[operation]++
+	return jd.snapshotFailures[operation]
+}
+
+// snapshotSucceeded resets the consecutive failures of the operation's snapshots
+func (jd *Handle) snapshotSucceeded(operation string) {
+	jd.snapshotFailuresMu.Lock()
+	defer jd.snapshotFailuresMu.Unlock()
+	delete(jd.snapshotFailures, operation)
+}
+
+// statusWatermarks returns the id of the latest status of every dataset, so that the jobs which become terminal after a snapshot can be told apart
+func (jd *Handle) statusWatermarks(ctx context.Context, datasets []dataSetT) (map[string]int64, error) {
+	watermarks := make(map[string]int64, len(datasets))
+	for _, ds := range datasets {
+		var id int64
+		if err := jd.dbHandle.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(id), 0) FROM %q`, ds.JobStatusTable)).Scan(&id); err != nil {
+			return nil, fmt.Errorf("reading the latest status of %s: %w", ds.JobStatusTable, err)
+		}
+		watermarks[ds.Index] = id
+	}
+	return watermarks, nil
+}
+
+// snapshot writes the jobs of the datasets satisfying the condition of their dataset to a file per workspace and uploads every file to the storage of its workspace.
+// Files follow the layout of the archiver, i.e. they are named <first job created at>_<last job created at>_<workspaceID>_<uuid>.json.gz
+// and uploaded under snapshotsPrefix/<tablePrefix>/<operation>/<date>/<hour>/<instanceID>, with the date and hour of their first job.
+// Every line of a file has the fields of an archived job, along with the ones needed for restoring it.
+func (jd *Handle) snapshot(ctx context.Context, operation string, datasets []dataSetT, withHistory bool, condition func(ds dataSetT) string, args ...any) ([]uploadedSnapshot, error) {
+	dir, err := os.MkdirTemp("", "rudder-jobsdb-snapshot-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating snapshot directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	files := make(map[string]*snapshotFile)
+	defer func() {
+		for _, sf := range files {
+			_ = sf.f.Close()
+		}
+	}()
+
+	header := jobsExportHeader{
+		Version:     jobsExportVersion,
+		TablePrefix: jd.tablePrefix,
+		ExportedAt:  time.Now().UTC(),
+		Operation:   operation,
+		Datasets:    lo.Map(datasets, func(ds dataSetT, _ int) string { return ds.Index }),
+	}
+	for _, ds := range datasets {
+		if err := jd.jobsWithStatusDS(ctx, jd.dbHandle, ds, withHistory, 0, func(job *exportedJob) error {
+			sf, ok := files[job.WorkspaceID]
+			if !ok {
+				f, err := os.CreateTemp(dir, "snapshot-*")
+				if err != nil {
+					return fmt.Errorf("creating snapshot file: %w", err)
+				}
+				sf = &snapshotFile{f: f, gz: gzip.NewWriter(f), firstCreatedAt: job.CreatedAt.UTC()}
+				sf.enc = json.NewEncoder(sf.gz)
+				files[job.WorkspaceID] = sf
+				header.WorkspaceID = job.WorkspaceID
+				if err := sf.enc.Encode(header); err != nil {
+					return fmt.Errorf("writing snapshot header: %w", err)
+				}
+			}
+			sf.jobs++
+			sf.firstCreatedAt = lo.Ternary(job.CreatedAt.Before(sf.firstCreatedAt), job.CreatedAt.UTC(), sf.firstCreatedAt)
+			sf.lastCreatedAt = lo.Ternary(job.CreatedAt.After(sf.lastCreatedAt), job.CreatedAt.UTC(), sf.lastCreatedAt)
+			if err := sf.enc.Encode(job); err != nil {
+				return fmt.Errorf("writing job %d: %w", job.JobID, err)
+			}
+			return nil
+		}, condition(ds), args...); err != nil {
+			return nil, fmt.Errorf("reading jobs of %s: %w", ds.JobTable, err)
+		}
+	}
+
+	snapshots := make([]uploadedSnapshot, 0, len(files))
+	for workspaceID, sf := range files {
+		if err := sf.gz.Close(); err != nil {
+			return nil, fmt.Errorf("closing snapshot file: %w", err)
+		}
+		if err := sf.f.Close(); err != nil {
+			return nil, fmt.Errorf("closing snapshot file: %w", err)
+		}
+		// the uploaded object is named after the file
+		name := filepath.Join(dir, fmt.Sprintf("%d_%d_%s_%s.json.gz", sf.firstCreatedAt.Unix(), sf.lastCreatedAt.Unix(), workspaceID, uuid.NewString()))
+		if err := os.Rename(sf.f.Name(), name); err != nil {
+			return nil, fmt.Errorf("renaming snapshot file: %w", err)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("opening snapshot file: %w", err)
+		}
+		sf.f = f
+		fm, err := jd.snapshotStorage.GetFileManager(ctx, workspaceID)
+		if err != nil {
+			return nil, fmt.Errorf("getting the snapshot storage of workspace %q: %w", workspaceID, err)
+		}
+		uploaded, err := fm.Upload(ctx, f, snapshotsPrefix, jd.tablePrefix, operation,
+			sf.firstCreatedAt.Format("2006-01-02"), strconv.Itoa(sf.firstCreatedAt.Hour()), jd.conf.snapshot.instanceID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("uploading snapshot of workspace %q: %w", workspaceID, err)
+		}
+		snapshots = append(snapshots, uploadedSnapshot{
+			workspaceID: workspaceID,
+			objectName:  uploaded.ObjectName,
+			location:    uploaded.Location,
+			jobs:        sf.jobs,
+		})
+	}
+	return snapshots, nil
+}
+
+// listSnapshots returns the snapshots of the jobs of a workspace, oldest first
+func (jd *Handle) listSnapshots(ctx context.Context, workspaceID string) ([]*filemanager.FileInfo, error) {
+	if jd.snapshotStorage == nil {
+		return nil, fmt.Errorf("no snapshot storage is configured for %q", jd.tablePrefix)
+	}
+	fm, err := jd.snapshotStorage.GetFileManager(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("getting the snapshot storage of workspace %q: %w", workspaceID, err)
+	}
+	var files []*filemanager.FileInfo
+	session := fm.ListFilesWithPrefix(ctx, "", path.Join(fm.Prefix(), snapshotsPrefix, jd.tablePrefix)+"/", 1000)
+	for {
+		batch, err := session.Next()
+		if err != nil {
+			return nil, fmt.Errorf("listing snapshots: %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		files = append(files, batch...)
+	}
+	slices.SortFunc(files, func(a, b *filemanager.FileInfo) int { return a.LastModified.Compare(b.LastModified) })
+	return files, nil
+}
+
+// restoreSnapshot imports the jobs of a snapshot whose state, at the time of the snapshot, is one of the provided states.
+// Jobs in a terminal state are imported without any status, so that they are processed again.
+func (jd *Handle) restoreSnapshot(ctx context.Context, workspaceID, objectName string, states []string) (int, error) {
+	if jd.snapshotStorage == nil {
+		return 0, fmt.Errorf("no snapshot storage is configured for %q", jd.tablePrefix)
+	}
+	fm, err := jd.snapshotStorage.GetFileManager(ctx, workspaceID)
+	if err != nil {
+		return 0, fmt.Errorf("getting the snapshot storage of workspace %q: %w", workspaceID, err)
+	}
+	f, err := os.CreateTemp("", "rudder-jobsdb-snapshot-*.json.gz")
+	if err != nil {
+		return 0, fmt.Errorf("creating snapshot file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	defer func() { _ = f.Close() }()
+	if err := fm.Download(ctx, f, objectName); err != nil {
+		return 0, fmt.Errorf("downloading snapshot %q: %w", objectName, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("rewinding snapshot file: %w", err)
+	}
+	restored, _, err := jd.importJobs(ctx, f, func(job *exportedJob) bool {
+		state := Unprocessed.State
+		if job.Status != nil {
+			state = job.Status.State
+		}
+		if !slices.Contains(states, state) {
+			return false
+		}
+		if slices.Contains(validTerminalStates, state) {
+			job.Status = nil
+		}
+		job.History = nil
+		return true
+	}, false)
+	return restored, err
+}
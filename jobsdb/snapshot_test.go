@@ -0,0 +1,172 @@
+package jobsdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/filemanager"
+)
+
+// dirSnapshotStorage stores snapshots in a local directory
+type dirSnapshotStorage struct {
+	dir string
+	err error
+}
+
+func (s *dirSnapshotStorage) GetFileManager(context.Context, string) (filemanager.FileManager, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &dirFileManager{dir: s.dir}, nil
+}
+
+type dirFileManager struct {
+	filemanager.FileManager // only the methods used by snapshots are implemented
+	dir                     string
+}
+
+func (m *dirFileManager) Upload(_ context.Context, f *os.File, prefixes ...string) (filemanager.UploadedFile, error) {
+	objectName := path.Join(path.Join(prefixes...), filepath.Base(f.Name()))
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(m.dir, objectName)), 0o700); err != nil {
+		return filemanager.UploadedFile{}, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return filemanager.UploadedFile{}, err
+	}
+	return filemanager.UploadedFile{Location: "file://" + filepath.Join(m.dir, objectName), ObjectName: objectName}, os.WriteFile(filepath.Join(m.dir, objectName), data, 0o600)
+}
+
+func (m *dirFileManager) Download(_ context.Context, w io.WriterAt, key string, _ ...filemanager.DownloadOption) error {
+	data, err := os.ReadFile(filepath.Join(m.dir, key))
+	if err != nil {
+		return err
+	}
+	_, err = w.WriteAt(data, 0)
+	return err
+}
+
+func (m *dirFileManager) ListFilesWithPrefix(_ context.Context, _, prefix string, _ int64) filemanager.ListSession {
+	var files []*filemanager.FileInfo
+	_ = filepath.Walk(m.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		key, _ := filepath.Rel(m.dir, p)
+		if strings.HasPrefix(key, prefix) {
+			files = append(files, &filemanager.FileInfo{Key: key, LastModified: info.ModTime()})
+		}
+		return nil
+	})
+	return &dirListSession{files: files}
+}
+
+func (*dirFileManager) Prefix() string { return "" }
+
+type dirListSession struct {
+	files []*filemanager.FileInfo
+}
+
+func (s *dirListSession) Next() ([]*filemanager.FileInfo, error) {
+	files := s.files
+	s.files = nil
+	return files, nil
+}
+
+func TestSnapshotBeforeAbortingOldJobs(t *testing.T) {
+	_ = startPostgres(t)
+	ctx := context.Background()
+	c := config.New()
+	c.Set("JobsDB.snapshot.operations", []string{snapshotAbortOldJobs})
+	storage := &dirSnapshotStorage{dir: t.TempDir(), err: errors.New("storage unavailable")}
+	jd := NewForReadWrite("snap", WithConfig(c), WithSnapshotStorage(storage), WithJobMaxAge(config.SingleValueLoader(time.Nanosecond)))
+	require.NoError(t, jd.Start())
+	defer jd.TearDown()
+
+	newJob := func(i string) *JobT {
+		return &JobT{
+			UUID:         uuid.New(),
+			UserID:       "user-" + i,
+			CustomVal:    "WEBHOOK",
+			EventCount:   1,
+			EventPayload: []byte(`{"index":` + i + `}`),
+			Parameters:   []byte(`{"source_id":"source-1","destination_id":"dest-1"}`),
+			WorkspaceId:  "ws-1",
+		}
+	}
+	newStatus := func(jobID int64, state string) *JobStatusT {
+		return &JobStatusT{
+			JobID:         jobID,
+			JobState:      state,
+			AttemptNum:    1,
+			ExecTime:      time.Now(),
+			RetryTime:     time.Now(),
+			ErrorCode:     "500",
+			ErrorResponse: []byte(`{}`),
+			Parameters:    []byte(`{}`),
+			WorkspaceId:   "ws-1",
+		}
+	}
+	require.NoError(t, jd.Store(ctx, []*JobT{newJob("1"), newJob("2"), newJob("3")}))
+	require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{
+		newStatus(2, Failed.State),
+		newStatus(3, Succeeded.State),
+	}, nil, nil))
+	pending := func() []*JobT {
+		res, err := jd.GetToProcess(ctx, GetQueryParams{JobsLimit: 10, CustomValFilters: []string{"WEBHOOK"}}, nil)
+		require.NoError(t, err)
+		return res.Jobs
+	}
+
+	t.Run("jobs are not aborted if the snapshot fails", func(t *testing.T) {
+		require.NoError(t, jd.abortOldJobs(ctx, jd.getDSList()))
+		require.Len(t, pending(), 2)
+	})
+
+	a := NewJobsAdmin(jd)
+	var snapshots []JobsSnapshot
+	t.Run("jobs are snapshotted before being aborted", func(t *testing.T) {
+		storage.err = nil
+		require.NoError(t, jd.abortOldJobs(ctx, jd.getDSList()))
+		require.Empty(t, pending())
+
+		require.NoError(t, a.Snapshots(JobsSnapshotsInput{TablePrefix: "snap", WorkspaceID: "ws-1"}, &snapshots))
+		require.Len(t, snapshots, 1)
+		require.Regexp(t, `^jobsdb-snapshots/snap/abortOldJobs/\d{4}-\d{2}-\d{2}/\d{1,2}/1/\d+_\d+_ws-1_[0-9a-f-]+\.json\.gz$`, snapshots[0].ObjectName, "snapshots should follow the archiver layout")
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		require.Error(t, a.Restore(JobsRestoreInput{TablePrefix: "snap", WorkspaceID: "ws-1", ObjectName: snapshots[0].ObjectName, States: []string{"unknown"}}, &JobsRestoreResult{}))
+
+		var reply JobsRestoreResult
+		require.NoError(t, a.Restore(JobsRestoreInput{TablePrefix: "snap", WorkspaceID: "ws-1", ObjectName: snapshots[0].ObjectName}, &reply))
+		require.Equal(t, 2, reply.Jobs)
+
+		jobs := pending()
+		require.Len(t, jobs, 2)
+		require.JSONEq(t, `{"index":1}`, string(jobs[0].EventPayload))
+		require.Empty(t, jobs[0].LastJobStatus.JobState)
+		require.JSONEq(t, `{"index":2}`, string(jobs[1].EventPayload))
+		require.Equal(t, Failed.State, jobs[1].LastJobStatus.JobState)
+	})
+
+	t.Run("jobs are aborted without a snapshot after too many failed ones", func(t *testing.T) {
+		storage.err = errors.New("storage unavailable")
+		c.Set("JobsDB.snapshot.maxConsecutiveFailures", 2)
+		require.NoError(t, jd.abortOldJobs(ctx, jd.getDSList()))
+		require.Len(t, pending(), 2)
+		require.NoError(t, jd.abortOldJobs(ctx, jd.getDSList()))
+		require.Empty(t, pending())
+	})
+}
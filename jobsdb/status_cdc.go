@@ -0,0 +1,186 @@
+package jobsdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/utils/crash"
+	. "github.com/rudderlabs/rudder-server/utils/tx" //nolint:staticcheck
+)
+
+// StatusTransition is a job status transition, as published by the change-data-capture stream of a jobsdb
+type StatusTransition struct {
+	TablePrefix   string    `json:"tablePrefix"`
+	JobID         int64     `json:"jobId"`
+	WorkspaceID   string    `json:"workspaceId"`
+	SourceID      string    `json:"sourceId,omitempty"`
+	DestinationID string    `json:"destinationId,omitempty"`
+	State         string    `json:"state"`
+	ErrorCode     string    `json:"errorCode,omitempty"`
+	AttemptNum    int       `json:"attemptNum"`
+	ExecTime      time.Time `json:"execTime"`
+}
+
+// StatusTransitionSink receives the job status transitions of a jobsdb.
+//
+// Delivery is at-least-once: transitions are journaled in the same transaction as the status update
+// and only removed from the journal after Publish returns successfully, so a batch can be published
+// more than once if Publish fails or the server stops before the journal gets updated.
+type StatusTransitionSink interface {
+	Publish(ctx context.Context, transitions []StatusTransition) error
+}
+
+func (jd *Handle) statusCDCEnabled() bool {
+	return jd.statusSink != nil && jd.conf.statusCDC.enabled
+}
+
+// journalStatusTransitionsInTx records the transitions of statusList as a pending journal entry, to be published by the status cdc loop
+func (jd *Handle) journalStatusTransitionsInTx(tx *Tx, statusList []*JobStatusT) error {
+	if !jd.statusCDCEnabled() || len(statusList) == 0 {
+		return nil
+	}
+	transitions := make([]StatusTransition, 0, len(statusList))
+	for _, status := range statusList {
+		transitions = append(transitions, StatusTransition{
+			TablePrefix:   jd.tablePrefix,
+			JobID:         status.JobID,
+			WorkspaceID:   status.WorkspaceId,
+			SourceID:      gjson.GetBytes(status.JobParameters, "source_id").String(),
+			DestinationID: gjson.GetBytes(status.JobParameters, "destination_id").String(),
+			State:         status.JobState,
+			ErrorCode:     status.ErrorCode,
+			AttemptNum:    status.AttemptNum,
+			ExecTime:      status.ExecTime,
+		})
+	}
+	payload, err := jsonrs.Marshal(transitions)
+	if err != nil {
+		return fmt.Errorf("marshalling status transitions: %w", err)
+	}
+	_, err = jd.JournalMarkStartInTx(tx, statusCDCOperation, payload)
+	return err
+}
+
+func (jd *Handle) startStatusCDCLoop(ctx context.Context) {
+	if !jd.statusCDCEnabled() {
+		return
+	}
+	jd.backgroundGroup.Go(crash.Wrapper(func() error {
+		jd.statusCDCLoop(ctx)
+		return nil
+	}))
+}
+
+func (jd *Handle) statusCDCLoop(ctx context.Context) {
+	errorStat := jd.stats.NewTaggedStat("jobsdb_status_cdc_publish_errors", stats.CountType, stats.Tags{"customVal": jd.tablePrefix})
+	for {
+		published, err := jd.publishStatusTransitions(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			errorStat.Increment()
+			jd.logger.Warnn("Failed to publish job status transitions, retrying",
+				logger.NewStringField("tablePrefix", jd.tablePrefix),
+				obskit.Error(err),
+			)
+		}
+		if published > 0 && err == nil {
+			continue // there might be more pending transitions
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jd.conf.statusCDC.publishInterval.Load()):
+		}
+	}
+}
+
+// publishStatusTransitions publishes the oldest pending status transitions to the sink and removes them from the journal.
+// It returns the number of journal entries published.
+func (jd *Handle) publishStatusTransitions(ctx context.Context) (int, error) {
+	rows, err := jd.dbHandle.QueryContext(ctx,
+		fmt.Sprintf(`SELECT id, operation_payload FROM "%s_journal" WHERE operation = $1 AND owner = $2 AND done = false ORDER BY id LIMIT $3`, jd.tablePrefix),
+		statusCDCOperation, jd.ownerType, jd.conf.statusCDC.maxEntriesPerPublish.Load(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("querying pending status transitions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var opIDs []int64
+	var transitions []StatusTransition
+	for rows.Next() {
+		var opID int64
+		var payload []byte
+		if err := rows.Scan(&opID, &payload); err != nil {
+			return 0, fmt.Errorf("scanning pending status transitions: %w", err)
+		}
+		var entryTransitions []StatusTransition
+		if err := jsonrs.Unmarshal(payload, &entryTransitions); err != nil {
+			return 0, fmt.Errorf("unmarshalling status transitions of journal entry %d: %w", opID, err)
+		}
+		opIDs = append(opIDs, opID)
+		transitions = append(transitions, entryTransitions...)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterating pending status transitions: %w", err)
+	}
+	_ = rows.Close()
+	if len(opIDs) == 0 {
+		return 0, nil
+	}
+
+	start := time.Now()
+	if err := jd.statusSink.Publish(ctx, transitions); err != nil {
+		return 0, fmt.Errorf("publishing %d status transitions: %w", len(transitions), err)
+	}
+	jd.stats.NewTaggedStat("jobsdb_status_cdc_publish_time", stats.TimerType, stats.Tags{"customVal": jd.tablePrefix}).Since(start)
+	jd.stats.NewTaggedStat("jobsdb_status_cdc_published_transitions", stats.CountType, stats.Tags{"customVal": jd.tablePrefix}).Count(len(transitions))
+
+	if _, err := jd.dbHandle.ExecContext(ctx,
+		fmt.Sprintf(`DELETE FROM "%s_journal" WHERE id = ANY($1)`, jd.tablePrefix),
+		pq.Array(opIDs),
+	); err != nil {
+		return 0, fmt.Errorf("deleting published status transitions: %w", err)
+	}
+	return len(opIDs), nil
+}
+
+// dropStaleStatusTransitions drops the pending status transitions of this owner which are older than statusCDC.maxPendingAge,
+// along with the oldest ones exceeding statusCDC.maxPendingEntries, so that the journal can't grow unbounded while the sink is unavailable.
+// It returns the number of journal entries dropped.
+func (jd *Handle) dropStaleStatusTransitions(ctx context.Context) (int64, error) {
+	res, err := jd.dbHandle.ExecContext(ctx,
+		fmt.Sprintf(`DELETE FROM "%[1]s_journal" WHERE operation = $1 AND owner = $4 AND (start_time < $2 OR id <= (
+			SELECT id FROM "%[1]s_journal" WHERE operation = $1 AND owner = $4 ORDER BY id DESC OFFSET $3 LIMIT 1
+		))`, jd.tablePrefix),
+		statusCDCOperation,
+		time.Now().Add(-jd.conf.statusCDC.maxPendingAge.Load()),
+		jd.conf.statusCDC.maxPendingEntries.Load(),
+		jd.ownerType,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("dropping stale status transitions: %w", err)
+	}
+	dropped, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("finding dropped status transitions: %w", err)
+	}
+	if dropped > 0 {
+		jd.stats.NewTaggedStat("jobsdb_status_cdc_dropped_entries", stats.CountType, stats.Tags{"customVal": jd.tablePrefix}).Count(int(dropped))
+		jd.logger.Warnn("Dropped pending job status transitions which were never published",
+			logger.NewStringField("tablePrefix", jd.tablePrefix),
+			logger.NewIntField("droppedEntries", dropped),
+		)
+	}
+	return dropped, nil
+}
@@ -0,0 +1,148 @@
+package jobsdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+)
+
+type recordingStatusSink struct {
+	mu          sync.Mutex
+	err         error
+	transitions []StatusTransition
+}
+
+func (s *recordingStatusSink) Publish(_ context.Context, transitions []StatusTransition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.transitions = append(s.transitions, transitions...)
+	return nil
+}
+
+func (s *recordingStatusSink) published() []StatusTransition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StatusTransition(nil), s.transitions...)
+}
+
+func (s *recordingStatusSink) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func TestStatusCDC(t *testing.T) {
+	_ = startPostgres(t)
+	ctx := context.Background()
+	c := config.New()
+	c.Set("JobsDB.statusCDC.enabled", true)
+	c.Set("JobsDB.statusCDC.publishInterval", "10ms")
+	sink := &recordingStatusSink{err: errors.New("sink unavailable")}
+	jd := NewForReadWrite("cdc", WithConfig(c), WithStats(stats.NOP), WithStatusTransitionSink(sink))
+	require.NoError(t, jd.Start())
+	defer jd.TearDown()
+
+	require.NoError(t, jd.Store(ctx, []*JobT{{
+		UUID:         uuid.New(),
+		UserID:       "user-1",
+		CustomVal:    "WEBHOOK",
+		EventCount:   1,
+		EventPayload: []byte(`{}`),
+		Parameters:   []byte(`{"source_id":"source-1","destination_id":"dest-1"}`),
+		WorkspaceId:  "ws-1",
+	}}))
+	newStatus := func(state, errorCode string, attempt int) *JobStatusT {
+		return &JobStatusT{
+			JobID:         1,
+			JobState:      state,
+			AttemptNum:    attempt,
+			ExecTime:      time.Now(),
+			RetryTime:     time.Now(),
+			ErrorCode:     errorCode,
+			ErrorResponse: []byte(`{}`),
+			Parameters:    []byte(`{}`),
+			JobParameters: []byte(`{"source_id":"source-1","destination_id":"dest-1"}`),
+			WorkspaceId:   "ws-1",
+		}
+	}
+	require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{newStatus(Executing.State, "", 1)}, nil, nil))
+	require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{newStatus(Failed.State, "500", 1)}, nil, nil))
+
+	pending := func() (count int) {
+		require.NoError(t, jd.dbHandle.QueryRow(`SELECT COUNT(*) FROM cdc_journal WHERE operation = $1`, statusCDCOperation).Scan(&count))
+		return count
+	}
+	require.Never(t, func() bool { return len(sink.published()) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	require.Equal(t, 2, pending(), "transitions should stay journaled while the sink is failing")
+
+	sink.setError(nil)
+	require.Eventually(t, func() bool { return len(sink.published()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return pending() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	published := sink.published()
+	require.Equal(t, Executing.State, published[0].State)
+	require.Equal(t, Failed.State, published[1].State)
+	require.Equal(t, StatusTransition{
+		TablePrefix:   "cdc",
+		JobID:         1,
+		WorkspaceID:   "ws-1",
+		SourceID:      "source-1",
+		DestinationID: "dest-1",
+		State:         Failed.State,
+		ErrorCode:     "500",
+		AttemptNum:    1,
+		ExecTime:      published[1].ExecTime,
+	}, published[1])
+
+	t.Run("stale transitions are dropped during cleanup", func(t *testing.T) {
+		sink.setError(errors.New("sink unavailable"))
+		for attempt := 2; attempt <= 4; attempt++ {
+			require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{newStatus(Failed.State, "500", attempt)}, nil, nil))
+		}
+		require.Equal(t, 3, pending())
+
+		c.Set("JobsDB.statusCDC.maxPendingEntries", 1)
+		require.NoError(t, jd.doCleanup(ctx))
+		require.Equal(t, 1, pending(), "only the newest entry should be kept")
+
+		c.Set("JobsDB.statusCDC.maxPendingAge", "1ns")
+		dropped, err := jd.dropStaleStatusTransitions(ctx)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, dropped)
+		require.Zero(t, pending())
+	})
+
+	t.Run("cleanup only drops the transitions of its owner", func(t *testing.T) {
+		c.Set("JobsDB.statusCDC.maxPendingAge", "1h")
+		c.Set("JobsDB.statusCDC.maxPendingEntries", 1)
+		otherPending := func() (count int) {
+			require.NoError(t, jd.dbHandle.QueryRow(`SELECT COUNT(*) FROM cdc_journal WHERE operation = $1 AND owner = $2`, statusCDCOperation, Read).Scan(&count))
+			return count
+		}
+		for range 3 { // entries of another owner, e.g. a reader of the same jobsdb
+			_, err := jd.dbHandle.Exec(`INSERT INTO cdc_journal (operation, done, operation_payload, start_time, owner) VALUES ($1, false, '[]', NOW(), $2)`, statusCDCOperation, Read)
+			require.NoError(t, err)
+		}
+		for attempt := 5; attempt <= 6; attempt++ {
+			require.NoError(t, jd.UpdateJobStatus(ctx, []*JobStatusT{newStatus(Failed.State, "500", attempt)}, nil, nil))
+		}
+		require.Equal(t, 5, pending())
+
+		dropped, err := jd.dropStaleStatusTransitions(ctx)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, dropped, "the newer entries of the other owner shouldn't count towards the max pending entries")
+		require.Equal(t, 3, otherPending(), "the entries of the other owner should be kept")
+		require.Equal(t, 4, pending())
+	})
+}
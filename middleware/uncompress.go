@@ -2,43 +2,190 @@ package middleware
 
 import (
 	"compress/gzip"
+	"errors"
 	"io"
 	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+)
+
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingBrotli = "br"
+
+	// minBytesForRatioCheck is the amount of decompressed bytes after which the compression ratio starts being checked,
+	// so that small, highly compressible payloads are not rejected
+	minBytesForRatioCheck = 1 << 20
+
+	// maxZstdWindow is the largest window a zstd frame can declare, which the decoder allocates a buffer for before decompressing anything.
+	// It is the window size decoders are recommended to support, lowered to the decompressed size limit if smaller.
+	maxZstdWindow = 8 << 20
+)
+
+var (
+	// ErrDecompressedBodyTooLarge is returned while reading a request body whose decompressed size exceeds the configured limit
+	ErrDecompressedBodyTooLarge = errors.New("decompressed request body exceeds max limit")
+	// ErrDecompressionRatioExceeded is returned while reading a request body whose compression ratio exceeds the configured limit
+	ErrDecompressionRatioExceeded = errors.New("request body compression ratio exceeds max limit")
 )
 
-// UncompressMiddleware uncompresses gzipped HTTP requests carrying a 'Content-Encoding: gzip' header.
-var UncompressMiddleware = func(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Content-Encoding") == "gzip" {
-			r.Body = &gzipReader{body: r.Body}
+// UncompressMiddleware uncompresses gzip, zstd and brotli HTTP requests carrying a corresponding 'Content-Encoding' header, without any limits.
+var UncompressMiddleware = NewUncompressMiddleware(stats.NOP, config.SingleValueLoader(0), config.SingleValueLoader(0))
+
+// NewUncompressMiddleware returns a middleware which uncompresses HTTP requests carrying a 'Content-Encoding' header of gzip, zstd or br.
+// Requests with any other encoding are passed through untouched.
+//
+// Reading a decompressed body fails with [ErrDecompressedBodyTooLarge] as soon as more than maxSize bytes are produced,
+// or with [ErrDecompressionRatioExceeded] if the ratio between decompressed and compressed bytes grows above maxRatio.
+// A non-positive limit disables the respective check.
+func NewUncompressMiddleware(stat stats.Stats, maxSize, maxRatio config.ValueLoader[int]) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			switch encoding {
+			case EncodingGzip, EncodingZstd, EncodingBrotli:
+				compressed := &countingReader{r: r.Body}
+				r.Body = &decompressingReader{
+					body:       r.Body,
+					compressed: compressed,
+					encoding:   encoding,
+					maxSize:    int64(maxSize.Load()),
+					maxRatio:   int64(maxRatio.Load()),
+					stat:       stat,
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// decompressingReader wraps a body so it can lazily
+// initialize a decompressing reader on the first call to Read, enforcing the configured limits while reading
+type decompressingReader struct {
+	body       io.ReadCloser   // underlying request body
+	compressed *countingReader // counts the compressed bytes read from body
+	encoding   string
+
+	maxSize, maxRatio int64
+	stat              stats.Stats
+
+	zr     io.Reader // lazily-initialized decompressing reader
+	closer func()    // releases resources held by zr, if any
+	zerr   error     // any error from initializing zr or exceeding a limit; sticky
+	n      int64     // decompressed bytes read so far
+	done   bool      // whether stats have already been reported
+}
+
+func (d *decompressingReader) Read(p []byte) (n int, err error) {
+	if d.zerr != nil {
+		return 0, d.zerr
+	}
+	if d.zr == nil {
+		d.zr, d.closer, d.zerr = newDecompressor(d.encoding, d.compressed, d.maxSize)
+		if d.zerr != nil {
+			return 0, d.zerr
 		}
-		h.ServeHTTP(w, r)
-	})
+	}
+	n, err = d.zr.Read(p)
+	d.n += int64(n)
+	if d.maxSize > 0 && d.n > d.maxSize || errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		d.reject("size")
+		d.zerr = ErrDecompressedBodyTooLarge
+		return n, d.zerr
+	}
+	if d.maxRatio > 0 && d.n > minBytesForRatioCheck && d.compressed.n > 0 && d.n/d.compressed.n > d.maxRatio {
+		d.reject("ratio")
+		d.zerr = ErrDecompressionRatioExceeded
+		return n, d.zerr
+	}
+	if errors.Is(err, io.EOF) {
+		d.report()
+	}
+	return n, err
+}
+
+func (d *decompressingReader) Close() error {
+	if d.closer != nil {
+		d.closer()
+	}
+	return d.body.Close()
+}
+
+func (d *decompressingReader) report() {
+	if d.done {
+		return
+	}
+	d.done = true
+	tags := stats.Tags{"encoding": d.encoding}
+	d.stat.NewTaggedStat("gateway.decompressed_requests", stats.CountType, tags).Increment()
+	d.stat.NewTaggedStat("gateway.decompressed_request_size", stats.HistogramType, tags).Observe(float64(d.n))
+	if d.compressed.n > 0 {
+		d.stat.NewTaggedStat("gateway.decompression_ratio", stats.HistogramType, tags).Observe(float64(d.n) / float64(d.compressed.n))
+	}
 }
 
-// gzipReader wraps a body so it can lazily
-// call gzip.NewReader on the first call to Read
-type gzipReader struct {
-	body io.ReadCloser // underlying request body
-	zr   *gzip.Reader  // lazily-initialized gzip reader
-	zerr error         // any error from gzip.NewReader; sticky
+func (d *decompressingReader) reject(reason string) {
+	d.done = true
+	d.stat.NewTaggedStat("gateway.decompression_rejected_requests", stats.CountType, stats.Tags{"encoding": d.encoding, "reason": reason}).Increment()
 }
 
-func (gz *gzipReader) Read(p []byte) (n int, err error) {
-	if gz.zr == nil {
-		if gz.zerr == nil {
-			gz.zr, gz.zerr = gzip.NewReader(gz.body)
+// RemoveDecompressedSizeLimit removes the decompressed size limit from the request's body, if it is being decompressed,
+// for endpoints which stream bodies of arbitrary length. The compression ratio limit still applies.
+func RemoveDecompressedSizeLimit(r *http.Request) {
+	if d, ok := r.Body.(*decompressingReader); ok {
+		d.maxSize = 0
+	}
+}
+
+// IsDecompressionLimitError returns true if the error was caused by a request body exceeding the decompression limits
+func IsDecompressionLimitError(err error) bool {
+	return errors.Is(err, ErrDecompressedBodyTooLarge) || errors.Is(err, ErrDecompressionRatioExceeded)
+}
+
+// newDecompressor returns a reader decompressing r. Zstd frames are rejected upfront
+// if their window or content size exceeds maxSize, so that the decoder never allocates more than needed for the limit.
+func newDecompressor(encoding string, r io.Reader, maxSize int64) (io.Reader, func(), error) {
+	switch encoding {
+	case EncodingGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
 		}
-		if gz.zerr != nil {
-			return 0, gz.zerr
+		return zr, func() { _ = zr.Close() }, nil
+	case EncodingZstd:
+		opts := []zstd.DOption{zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindow)}
+		if maxSize > 0 {
+			opts = append(opts,
+				zstd.WithDecoderMaxWindow(uint64(max(min(maxSize, maxZstdWindow), zstd.MinWindowSize))),
+				zstd.WithDecoderMaxMemory(uint64(maxSize)),
+			)
 		}
+		zr, err := zstd.NewReader(r, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	case EncodingBrotli:
+		return brotli.NewReader(r), nil, nil
+	default:
+		return r, nil, nil
 	}
-	return gz.zr.Read(p)
 }
 
-func (gz *gzipReader) Close() error {
-	if gz.zr != nil {
-		_ = gz.zr.Close()
-	}
-	return gz.body.Close()
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
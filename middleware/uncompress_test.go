@@ -7,11 +7,18 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
 
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
+
 	"github.com/rudderlabs/rudder-server/middleware"
 )
 
@@ -82,3 +89,143 @@ func TestUncompress(t *testing.T) {
 		require.Equal(t, json, res.Body.String(), "handler should receive the non-compressed body")
 	})
 }
+
+func TestUncompressEncodingsAndLimits(t *testing.T) {
+	payload := []byte(strings.Repeat(`{"key": "value"}`, 1000))
+
+	compress := func(t *testing.T, encoding string, data []byte) *bytes.Buffer {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case middleware.EncodingGzip:
+			w = gzip.NewWriter(&buf)
+		case middleware.EncodingZstd:
+			zw, err := zstd.NewWriter(&buf)
+			require.NoError(t, err)
+			w = zw
+		case middleware.EncodingBrotli:
+			w = brotli.NewWriter(&buf)
+		}
+		_, err := w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return &buf
+	}
+
+	echoHandler := func(t *testing.T) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			_ = r.Body.Close()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			_, err = w.Write(b)
+			require.NoError(t, err)
+		})
+	}
+
+	for _, encoding := range []string{middleware.EncodingGzip, middleware.EncodingZstd, middleware.EncodingBrotli} {
+		t.Run(encoding, func(t *testing.T) {
+			t.Run("within limits", func(t *testing.T) {
+				statsStore, err := memstats.New()
+				require.NoError(t, err)
+				handler := middleware.NewUncompressMiddleware(statsStore, config.SingleValueLoader(len(payload)), config.SingleValueLoader(1000))(echoHandler(t))
+
+				req := httptest.NewRequest(http.MethodPost, "/test", compress(t, encoding, payload))
+				req.Header.Set("Content-Encoding", encoding)
+				res := httptest.NewRecorder()
+				handler.ServeHTTP(res, req)
+
+				require.Equal(t, http.StatusOK, res.Code)
+				require.Equal(t, payload, res.Body.Bytes())
+				require.EqualValues(t, 1, statsStore.Get("gateway.decompressed_requests", stats.Tags{"encoding": encoding}).LastValue())
+			})
+
+			t.Run("exceeding max size", func(t *testing.T) {
+				statsStore, err := memstats.New()
+				require.NoError(t, err)
+				handler := middleware.NewUncompressMiddleware(statsStore, config.SingleValueLoader(len(payload)-1), config.SingleValueLoader(0))(echoHandler(t))
+
+				req := httptest.NewRequest(http.MethodPost, "/test", compress(t, encoding, payload))
+				req.Header.Set("Content-Encoding", encoding)
+				res := httptest.NewRecorder()
+				handler.ServeHTTP(res, req)
+
+				require.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+				require.Contains(t, res.Body.String(), middleware.ErrDecompressedBodyTooLarge.Error())
+				require.EqualValues(t, 1, statsStore.Get("gateway.decompression_rejected_requests", stats.Tags{"encoding": encoding, "reason": "size"}).LastValue())
+			})
+
+			t.Run("exceeding max size with the limit removed", func(t *testing.T) {
+				handler := middleware.NewUncompressMiddleware(stats.NOP, config.SingleValueLoader(len(payload)-1), config.SingleValueLoader(0))(
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						middleware.RemoveDecompressedSizeLimit(r)
+						echoHandler(t).ServeHTTP(w, r)
+					}),
+				)
+
+				req := httptest.NewRequest(http.MethodPost, "/test", compress(t, encoding, payload))
+				req.Header.Set("Content-Encoding", encoding)
+				res := httptest.NewRecorder()
+				handler.ServeHTTP(res, req)
+
+				require.Equal(t, http.StatusOK, res.Code)
+				require.Equal(t, payload, res.Body.Bytes())
+			})
+
+			t.Run("exceeding max ratio", func(t *testing.T) {
+				statsStore, err := memstats.New()
+				require.NoError(t, err)
+				handler := middleware.NewUncompressMiddleware(statsStore, config.SingleValueLoader(0), config.SingleValueLoader(10))(echoHandler(t))
+
+				bomb := make([]byte, 10<<20) // 10MB of zeros
+				req := httptest.NewRequest(http.MethodPost, "/test", compress(t, encoding, bomb))
+				req.Header.Set("Content-Encoding", encoding)
+				res := httptest.NewRecorder()
+				handler.ServeHTTP(res, req)
+
+				require.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+				require.Contains(t, res.Body.String(), middleware.ErrDecompressionRatioExceeded.Error())
+				require.EqualValues(t, 1, statsStore.Get("gateway.decompression_rejected_requests", stats.Tags{"encoding": encoding, "reason": "ratio"}).LastValue())
+			})
+		})
+	}
+
+	t.Run("zstd frames declaring a large window", func(t *testing.T) {
+		statsStore, err := memstats.New()
+		require.NoError(t, err)
+		handler := middleware.NewUncompressMiddleware(statsStore, config.SingleValueLoader(len(payload)), config.SingleValueLoader(0))(echoHandler(t))
+
+		frame := []byte{
+			0x28, 0xb5, 0x2f, 0xfd, // magic number
+			0x00,             // frame header descriptor: no content size, window descriptor present
+			0x98,             // window descriptor: 512MB window
+			0x09, 0x00, 0x00, // last raw block of 1 byte
+			'a',
+		}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(frame))
+		req.Header.Set("Content-Encoding", middleware.EncodingZstd)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		runtime.ReadMemStats(&after)
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+		require.Contains(t, res.Body.String(), middleware.ErrDecompressedBodyTooLarge.Error())
+		require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(64<<20), "no buffer should be allocated for the declared window")
+		require.EqualValues(t, 1, statsStore.Get("gateway.decompression_rejected_requests", stats.Tags{"encoding": middleware.EncodingZstd, "reason": "size"}).LastValue())
+	})
+
+	t.Run("unsupported encodings are passed through", func(t *testing.T) {
+		handler := middleware.NewUncompressMiddleware(stats.NOP, config.SingleValueLoader(1), config.SingleValueLoader(1))(echoHandler(t))
+		req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(payload))
+		req.Header.Set("Content-Encoding", "identity")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, payload, res.Body.Bytes())
+	})
+}
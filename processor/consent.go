@@ -1,28 +1,47 @@
 package processor
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/samber/lo"
 
 	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+	"github.com/rudderlabs/rudder-go-kit/stats"
 	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
 
 	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/internal/tcf"
 	"github.com/rudderlabs/rudder-server/processor/types"
 	"github.com/rudderlabs/rudder-server/utils/misc"
 )
 
+// tcfProvider is the consent management provider of destinations relying on IAB TCF v2 consent strings,
+// whose consents are the ids of the purposes they need consent for
+const tcfProvider = "iab"
+
+// tcfConsentOnlyPurposes are the TCF purposes requiring the explicit consent of the user, legitimate interest not being a legal basis for them:
+// storing and accessing information on a device (1) and creating or using profiles for personalised advertising or content (3, 4, 5, 6)
+var tcfConsentOnlyPurposes = map[int]bool{1: true, 3: true, 4: true, 5: true, 6: true}
+
+// errInvalidTCString is returned when the IAB TCF v2 consent string of an event cannot be decoded
+var errInvalidTCString = errors.New("invalid IAB TCF consent string")
+
 type ConsentManagementInfo struct {
 	DeniedConsentIDs   []string    `json:"deniedConsentIds"`
 	AllowedConsentIDs  interface{} `json:"allowedConsentIds"` // Not used currently but added for future use
 	Provider           string      `json:"provider"`
 	ResolutionStrategy string      `json:"resolutionStrategy"`
+	TCString           string      `json:"tcString"` // IAB TCF v2 consent string
+
+	tcfConsent *tcf.Consent // decoded TCString, nil if it is invalid
 }
 
 type GenericConsentManagementProviderData struct {
 	ResolutionStrategy string
 	Consents           []string
+	VendorID           int // IAB global vendor list id, for the iab provider only
 }
 
 type GenericConsentsConfig struct {
@@ -33,65 +52,162 @@ type GenericConsentManagementProviderConfig struct {
 	Provider           string                  `json:"provider"`
 	ResolutionStrategy string                  `json:"resolutionStrategy"`
 	Consents           []GenericConsentsConfig `json:"consents"`
+	VendorID           int                     `json:"vendorId"`
+}
+
+// ConsentProvider decides whether events can be sent to destinations, based on the consents of their users
+type ConsentProvider interface {
+	// Allow returns whether an event with the consent management info can be sent to the destination.
+	// If decided is false, the provider has no say on it, e.g. because the destination isn't configured for it, and the next provider is asked.
+	Allow(info *ConsentManagementInfo, sourceID string, destination *backendconfig.DestinationT) (allowed, decided bool)
+}
+
+// consentProviders returns the consent providers, in the order they are asked
+func (proc *Handle) consentProviders() []ConsentProvider {
+	return []ConsentProvider{
+		&tcfConsentProvider{proc: proc},
+		&genericConsentProvider{proc: proc},
+		&oneTrustConsentProvider{proc: proc},
+		&ketchConsentProvider{proc: proc},
+	}
 }
 
 /*
 Filters and returns destinations based on the consents configured for the destination and the user consents present in the event.
 
-Supports legacy and generic consent management.
+Supports IAB TCF v2 consent strings, legacy and generic consent management.
 For GCM based filtering, uses source and destination IDs to fetch the appropriate GCM data from the config.
 */
 func (proc *Handle) getConsentFilteredDestinations(event types.SingularEventT, sourceID string, destinations []backendconfig.DestinationT) []backendconfig.DestinationT {
-	// If the event does not have denied consent IDs, do not filter any destinations
 	consentManagementInfo, err := getConsentManagementInfo(event)
-	if err != nil {
+	if errors.Is(err, errInvalidTCString) {
+		// invalid consent strings are sent by clients, so they are counted rather than logged for every event
+		proc.statsFactory.NewTaggedStat("processor_invalid_tcf_consent_strings", stats.CountType, stats.Tags{"sourceId": sourceID}).Increment()
+	} else if err != nil {
 		// Log the error for debugging purposes
 		proc.logger.Errorn("failed to get consent management info", obskit.Error(err))
 	}
 
-	if len(consentManagementInfo.DeniedConsentIDs) == 0 {
+	// If the event has neither denied consent IDs nor a consent string, do not filter any destinations
+	if len(consentManagementInfo.DeniedConsentIDs) == 0 && consentManagementInfo.TCString == "" {
 		return destinations
 	}
 
+	providers := proc.consentProviders()
 	return lo.Filter(destinations, func(dest backendconfig.DestinationT, _ int) bool {
-		// Generic consent management
-		if cmpData := proc.getGCMData(sourceID, dest.ID, consentManagementInfo.Provider); len(cmpData.Consents) > 0 {
-
-			finalResolutionStrategy := consentManagementInfo.ResolutionStrategy
-
-			// For custom provider, the resolution strategy is to be picked from the destination config
-			if consentManagementInfo.Provider == "custom" {
-				finalResolutionStrategy = cmpData.ResolutionStrategy
+		for _, provider := range providers {
+			if allowed, decided := provider.Allow(&consentManagementInfo, sourceID, &dest); decided {
+				return allowed
 			}
+		}
+		return true
+	})
+}
 
-			switch finalResolutionStrategy {
-			// The user must consent to at least one of the configured consents in the destination
-			case "or":
-				return !lo.Every(consentManagementInfo.DeniedConsentIDs, cmpData.Consents)
+// tcfConsentProvider filters destinations configured with the iab provider, based on the IAB TCF v2 consent string of the event.
+// The vendor of the destination, if configured, and the purposes it is configured with need either consent or legitimate interest,
+// except for the purposes which the TCF policy doesn't allow to be processed on the basis of legitimate interest.
+type tcfConsentProvider struct {
+	proc *Handle
+}
 
-			// The user must consent to all of the configured consents in the destination
-			default: // "and"
-				return len(lo.Intersect(cmpData.Consents, consentManagementInfo.DeniedConsentIDs)) == 0
-			}
+func (p *tcfConsentProvider) Allow(info *ConsentManagementInfo, sourceID string, dest *backendconfig.DestinationT) (bool, bool) {
+	if info.TCString == "" {
+		return false, false
+	}
+	cmpData := p.proc.getGCMData(sourceID, dest.ID, tcfProvider)
+	if len(cmpData.Consents) == 0 && cmpData.VendorID == 0 {
+		return false, false
+	}
+	consent := info.tcfConsent
+	if consent == nil { // invalid consent strings grant no consent
+		return false, true
+	}
+	if vendorID := cmpData.VendorID; vendorID > 0 && !consent.VendorConsent(vendorID) && !consent.VendorLegitimateInterest(vendorID) {
+		return false, true
+	}
+	purposeAllowed := func(purpose string) bool {
+		purposeID, err := strconv.Atoi(purpose)
+		if err != nil {
+			return false
 		}
+		return consent.PurposeConsent(purposeID) || (!tcfConsentOnlyPurposes[purposeID] && consent.PurposeLegitimateInterest(purposeID))
+	}
+	if len(cmpData.Consents) == 0 {
+		return true, true
+	}
+	switch cmpData.ResolutionStrategy {
+	// The user must allow at least one of the configured purposes in the destination
+	case "or":
+		return lo.SomeBy(cmpData.Consents, purposeAllowed), true
+	// The user must allow all of the configured purposes in the destination
+	default: // "and"
+		return lo.EveryBy(cmpData.Consents, purposeAllowed), true
+	}
+}
 
-		// Legacy consent management
-		if consentManagementInfo.Provider == "" || consentManagementInfo.Provider == "oneTrust" {
-			// If the destination has oneTrustCookieCategories, returns false if any of the oneTrustCategories are present in deniedCategories
-			if oneTrustCategories := proc.getOneTrustConsentData(dest.ID); len(oneTrustCategories) > 0 {
-				return len(lo.Intersect(oneTrustCategories, consentManagementInfo.DeniedConsentIDs)) == 0
-			}
-		}
+// genericConsentProvider filters destinations based on their generic consent management config for the provider of the event
+type genericConsentProvider struct {
+	proc *Handle
+}
 
-		if consentManagementInfo.Provider == "" || consentManagementInfo.Provider == "ketch" {
-			// If the destination has ketchConsentPurposes, returns false if all ketchPurposes are present in deniedCategories
-			if ketchPurposes := proc.getKetchConsentData(dest.ID); len(ketchPurposes) > 0 {
-				return !lo.Every(consentManagementInfo.DeniedConsentIDs, ketchPurposes)
-			}
-		}
+func (p *genericConsentProvider) Allow(info *ConsentManagementInfo, sourceID string, dest *backendconfig.DestinationT) (bool, bool) {
+	if len(info.DeniedConsentIDs) == 0 {
+		return false, false
+	}
+	cmpData := p.proc.getGCMData(sourceID, dest.ID, info.Provider)
+	if len(cmpData.Consents) == 0 {
+		return false, false
+	}
 
-		return true
-	})
+	finalResolutionStrategy := info.ResolutionStrategy
+
+	// For custom provider, the resolution strategy is to be picked from the destination config
+	if info.Provider == "custom" {
+		finalResolutionStrategy = cmpData.ResolutionStrategy
+	}
+
+	switch finalResolutionStrategy {
+	// The user must consent to at least one of the configured consents in the destination
+	case "or":
+		return !lo.Every(info.DeniedConsentIDs, cmpData.Consents), true
+
+	// The user must consent to all of the configured consents in the destination
+	default: // "and"
+		return len(lo.Intersect(cmpData.Consents, info.DeniedConsentIDs)) == 0, true
+	}
+}
+
+// oneTrustConsentProvider filters destinations based on their legacy oneTrustCookieCategories config
+type oneTrustConsentProvider struct {
+	proc *Handle
+}
+
+func (p *oneTrustConsentProvider) Allow(info *ConsentManagementInfo, _ string, dest *backendconfig.DestinationT) (bool, bool) {
+	if len(info.DeniedConsentIDs) == 0 || (info.Provider != "" && info.Provider != "oneTrust") {
+		return false, false
+	}
+	// If the destination has oneTrustCookieCategories, returns false if any of the oneTrustCategories are present in deniedCategories
+	if oneTrustCategories := p.proc.getOneTrustConsentData(dest.ID); len(oneTrustCategories) > 0 {
+		return len(lo.Intersect(oneTrustCategories, info.DeniedConsentIDs)) == 0, true
+	}
+	return false, false
+}
+
+// ketchConsentProvider filters destinations based on their legacy ketchConsentPurposes config
+type ketchConsentProvider struct {
+	proc *Handle
+}
+
+func (p *ketchConsentProvider) Allow(info *ConsentManagementInfo, _ string, dest *backendconfig.DestinationT) (bool, bool) {
+	if len(info.DeniedConsentIDs) == 0 || (info.Provider != "" && info.Provider != "ketch") {
+		return false, false
+	}
+	// If the destination has ketchConsentPurposes, returns false if all ketchPurposes are present in deniedCategories
+	if ketchPurposes := p.proc.getKetchConsentData(dest.ID); len(ketchPurposes) > 0 {
+		return !lo.Every(info.DeniedConsentIDs, ketchPurposes), true
+	}
+	return false, false
 }
 
 func (proc *Handle) getOneTrustConsentData(destinationID string) []string {
@@ -186,7 +302,13 @@ func getGenericConsentManagementData(dest *backendconfig.DestinationT) (ConsentP
 	for _, providerConfig := range consentManagementConfig {
 		consentsConfig := providerConfig.Consents
 
-		if len(consentsConfig) > 0 && providerConfig.Provider != "" {
+		// destinations relying on IAB TCF v2 consent strings can be configured with a vendor only
+		vendorID := 0
+		if providerConfig.Provider == tcfProvider {
+			vendorID = providerConfig.VendorID
+		}
+
+		if (len(consentsConfig) > 0 || vendorID > 0) && providerConfig.Provider != "" {
 			consentIDs := lo.FilterMap(
 				consentsConfig,
 				func(consentsObj GenericConsentsConfig, _ int) (string, bool) {
@@ -194,10 +316,11 @@ func getGenericConsentManagementData(dest *backendconfig.DestinationT) (ConsentP
 				},
 			)
 
-			if len(consentIDs) > 0 {
+			if len(consentIDs) > 0 || vendorID > 0 {
 				genericConsentManagementData[ConsentProviderKey(providerConfig.Provider)] = GenericConsentManagementProviderData{
 					ResolutionStrategy: providerConfig.ResolutionStrategy,
 					Consents:           consentIDs,
+					VendorID:           vendorID,
 				}
 			}
 		}
@@ -226,5 +349,20 @@ func getConsentManagementInfo(event types.SingularEventT) (ConsentManagementInfo
 		consentManagementInfo.DeniedConsentIDs = lo.FilterMap(consentManagementInfo.DeniedConsentIDs, filterPredicate)
 	}
 
+	// IAB TCF v2 consent strings can also be sent outside of consent management, as in the gdpr_consent parameter of ad tech requests
+	if consentManagementInfo.TCString == "" {
+		consentManagementInfo.TCString, _ = misc.MapLookup(event, "context", "gdpr_consent").(string)
+	}
+	if consentManagementInfo.TCString == "" {
+		consentManagementInfo.TCString, _ = event["gdpr_consent"].(string)
+	}
+	if consentManagementInfo.TCString != "" {
+		consent, err := tcf.Decode(consentManagementInfo.TCString)
+		if err != nil {
+			return consentManagementInfo, fmt.Errorf("%w: %w", errInvalidTCString, err)
+		}
+		consentManagementInfo.tcfConsent = consent
+	}
+
 	return consentManagementInfo, nil
 }
@@ -7,6 +7,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
 	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
 	"github.com/rudderlabs/rudder-server/processor/types"
 )
@@ -1373,11 +1375,321 @@ func TestFilterDestinations(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "filter destinations based on an IAB TCF v2 consent string",
+			event: types.SingularEventT{
+				"context": map[string]interface{}{
+					"consentManagement": map[string]interface{}{
+						"provider": "iab",
+						"tcString": "CP99LpAP_jsrQEsACBENCWEgAOAAAAIAAAAAGQQAQF5gAAA", // purposes 1-3 consented, purpose 7 legitimate interest, vendor 755 consented
+					},
+				},
+			},
+			connectionInfo: []ConnectionInfo{
+				{
+					sourceId: "sourceID-1",
+					destinations: []backendconfig.DestinationT{
+						{
+							ID: "destID-1",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"vendorId": 755,
+										"consents": []map[string]interface{}{
+											{"consent": "1"},
+											{"consent": "7"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-2",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"vendorId": 1,
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-3",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider":           "iab",
+										"resolutionStrategy": "or",
+										"consents": []map[string]interface{}{
+											{"consent": "4"},
+											{"consent": "3"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-4",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"consents": []map[string]interface{}{
+											{"consent": "3"},
+											{"consent": "4"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-5",
+							Config: map[string]interface{}{
+								"oneTrustCookieCategories": []interface{}{
+									map[string]interface{}{
+										"oneTrustCookieCategory": "foo-1",
+									},
+								},
+							},
+						},
+					},
+					expectedDestIDs: []string{"destID-1", "destID-3", "destID-5"},
+				},
+			},
+		},
+		{
+			description: "filter destinations based on a gdpr_consent field",
+			event: types.SingularEventT{
+				"context": map[string]interface{}{
+					"gdpr_consent": "CP99LpAP_jsrQEsACBENCWEgAOAAAAIAAAAAGQQAQF5gAAA",
+					"consentManagement": map[string]interface{}{
+						"deniedConsentIds": []interface{}{"foo-1"},
+					},
+				},
+			},
+			connectionInfo: []ConnectionInfo{
+				{
+					sourceId: "sourceID-1",
+					destinations: []backendconfig.DestinationT{
+						{
+							ID: "destID-1",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"vendorId": 755,
+										"consents": []map[string]interface{}{
+											{"consent": "1"},
+											{"consent": "7"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-2",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"vendorId": 1,
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-3",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider":           "iab",
+										"resolutionStrategy": "or",
+										"consents": []map[string]interface{}{
+											{"consent": "4"},
+											{"consent": "3"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-4",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"consents": []map[string]interface{}{
+											{"consent": "3"},
+											{"consent": "4"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-5",
+							Config: map[string]interface{}{
+								"oneTrustCookieCategories": []interface{}{
+									map[string]interface{}{
+										"oneTrustCookieCategory": "foo-1",
+									},
+								},
+							},
+						},
+					},
+					expectedDestIDs: []string{"destID-1", "destID-3"},
+				},
+			},
+		},
+		{
+			description: "require the consent of the user for purposes 1, 3, 4, 5 and 6 of IAB TCF v2 consent strings",
+			event: types.SingularEventT{
+				"context": map[string]interface{}{
+					"consentManagement": map[string]interface{}{
+						"provider": "iab",
+						"tcString": "CP99LpAP_jsrQEsACBENCWEgAOAAABIAAAAAGQQAQF5gAAA", // purposes 1-3 consented, purposes 4 and 7 legitimate interest
+					},
+				},
+			},
+			connectionInfo: []ConnectionInfo{
+				{
+					sourceId: "sourceID-1",
+					destinations: []backendconfig.DestinationT{
+						{
+							ID: "destID-1",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"consents": []map[string]interface{}{
+											{"consent": "4"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-2",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"consents": []map[string]interface{}{
+											{"consent": "1"},
+											{"consent": "7"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-3",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider":           "iab",
+										"resolutionStrategy": "or",
+										"consents": []map[string]interface{}{
+											{"consent": "4"},
+											{"consent": "5"},
+										},
+									},
+								},
+							},
+						},
+					},
+					expectedDestIDs: []string{"destID-2"},
+				},
+			},
+		},
+		{
+			description: "filter out destinations relying on an invalid IAB TCF v2 consent string",
+			event: types.SingularEventT{
+				"gdpr_consent": "invalid",
+			},
+			connectionInfo: []ConnectionInfo{
+				{
+					sourceId: "sourceID-1",
+					destinations: []backendconfig.DestinationT{
+						{
+							ID: "destID-1",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"vendorId": 755,
+										"consents": []map[string]interface{}{
+											{"consent": "1"},
+											{"consent": "7"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-2",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"vendorId": 1,
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-3",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider":           "iab",
+										"resolutionStrategy": "or",
+										"consents": []map[string]interface{}{
+											{"consent": "4"},
+											{"consent": "3"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-4",
+							Config: map[string]interface{}{
+								"consentManagement": []interface{}{
+									map[string]interface{}{
+										"provider": "iab",
+										"consents": []map[string]interface{}{
+											{"consent": "3"},
+											{"consent": "4"},
+										},
+									},
+								},
+							},
+						},
+						{
+							ID: "destID-5",
+							Config: map[string]interface{}{
+								"oneTrustCookieCategories": []interface{}{
+									map[string]interface{}{
+										"oneTrustCookieCategory": "foo-1",
+									},
+								},
+							},
+						},
+					},
+					expectedDestIDs: []string{"destID-5"},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.description, func(t *testing.T) {
-			proc := &Handle{}
+			statsStore, err := memstats.New()
+			require.NoError(t, err)
+			proc := &Handle{statsFactory: statsStore}
 			proc.config.oneTrustConsentCategoriesMap = make(map[string][]string)
 			proc.config.ketchConsentCategoriesMap = make(map[string][]string)
 			proc.config.genericConsentManagementMap = make(SourceConsentMap)
@@ -1397,6 +1709,9 @@ func TestFilterDestinations(t *testing.T) {
 				require.EqualValues(t, connectionInfo.expectedDestIDs, lo.Map(filteredDestinations, func(dest backendconfig.DestinationT, _ int) string {
 					return dest.ID
 				}))
+				if tc.event["gdpr_consent"] == "invalid" {
+					require.EqualValues(t, 1, statsStore.Get("processor_invalid_tcf_consent_strings", stats.Tags{"sourceId": connectionInfo.sourceId}).LastValue())
+				}
 			}
 		})
 	}
@@ -0,0 +1,125 @@
+package eventfilter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+const (
+	filterRulesKey = "eventFilterRules"
+	// maxRuleCost bounds the cost of evaluating a single rule, e.g. of comprehensions over large lists
+	maxRuleCost = 10000
+)
+
+// ruleVariables are the fields of an event which rules can refer to directly, e.g. properties.revenue.
+// The whole event is available as message, e.g. message.type, since type is a reserved identifier.
+var ruleVariables = []string{
+	"anonymousId",
+	"channel",
+	"context",
+	"event",
+	"integrations",
+	"messageId",
+	"originalTimestamp",
+	"properties",
+	"receivedAt",
+	"sentAt",
+	"timestamp",
+	"traits",
+	"userId",
+}
+
+var ruleEnv = sync.OnceValues(func() (*cel.Env, error) {
+	opts := []cel.EnvOption{
+		cel.Variable("message", cel.DynType),
+		cel.CrossTypeNumericComparisons(true),
+	}
+	for _, name := range ruleVariables {
+		opts = append(opts, cel.Variable(name, cel.DynType))
+	}
+	return cel.NewEnv(opts...)
+})
+
+// Rule is a compiled filtering rule of a connection
+type Rule struct {
+	Name      string
+	Condition string
+	program   cel.Program
+}
+
+// Rules are the filtering rules of a connection, all of which an event needs to satisfy to be sent to its destination
+type Rules []Rule
+
+/*
+CompileRules compiles the filtering rules of a connection, defined in its config as a list of named CEL conditions:
+
+	"eventFilterRules": [
+		{"name": "recent app versions", "condition": "context.app.version > \"2.0\""},
+		{"name": "revenue events", "condition": "message.type == \"track\" && properties.revenue != null"}
+	]
+
+Returns no rules if the connection has none.
+*/
+func CompileRules(connection backendconfig.Connection) (Rules, error) {
+	rulesConfig, ok := connection.Config[filterRulesKey].([]interface{})
+	if !ok || len(rulesConfig) == 0 {
+		return nil, nil
+	}
+	env, err := ruleEnv()
+	if err != nil {
+		return nil, fmt.Errorf("creating rules environment: %w", err)
+	}
+	rules := make(Rules, 0, len(rulesConfig))
+	for i, ruleConfig := range rulesConfig {
+		ruleMap, _ := ruleConfig.(map[string]interface{})
+		condition, _ := ruleMap["condition"].(string)
+		if condition == "" {
+			return nil, fmt.Errorf("rule %d: condition is required", i)
+		}
+		name, _ := ruleMap["name"].(string)
+		if name == "" {
+			name = condition
+		}
+		ast, issues := env.Compile(condition)
+		if issues.Err() != nil {
+			return nil, fmt.Errorf("rule %q: %w", name, issues.Err())
+		}
+		if outputType := ast.OutputType(); outputType != cel.BoolType && outputType != cel.DynType {
+			return nil, fmt.Errorf("rule %q: condition evaluates to %s instead of bool", name, outputType)
+		}
+		program, err := env.Program(ast, cel.CostLimit(maxRuleCost))
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", name, err)
+		}
+		rules = append(rules, Rule{Name: name, Condition: condition, program: program})
+	}
+	return rules, nil
+}
+
+// Allow returns whether the event satisfies all rules and, if not, the first rule which it doesn't satisfy.
+// An event doesn't satisfy a rule whose condition cannot be evaluated against it, e.g. because a field it refers to is missing.
+func (r Rules) Allow(event types.SingularEventT) (bool, *Rule) {
+	if len(r) == 0 {
+		return true, nil
+	}
+	vars := make(map[string]any, len(ruleVariables)+1)
+	vars["message"] = map[string]interface{}(event)
+	for _, name := range ruleVariables {
+		vars[name] = event[name] // missing fields are null
+	}
+	for i := range r {
+		out, _, err := r[i].program.Eval(vars)
+		if err != nil {
+			return false, &r[i]
+		}
+		if allow, ok := out.Value().(bool); !ok || !allow {
+			return false, &r[i]
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,92 @@
+package eventfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+func TestRules(t *testing.T) {
+	connection := func(rules ...interface{}) backendconfig.Connection {
+		return backendconfig.Connection{Config: map[string]interface{}{"eventFilterRules": rules}}
+	}
+	rule := func(name, condition string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "condition": condition}
+	}
+
+	t.Run("no rules", func(t *testing.T) {
+		rules, err := CompileRules(backendconfig.Connection{})
+		require.NoError(t, err)
+		require.Empty(t, rules)
+		allow, _ := rules.Allow(types.SingularEventT{})
+		require.True(t, allow)
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for name, c := range map[string]backendconfig.Connection{
+			"missing condition":  connection(map[string]interface{}{"name": "no condition"}),
+			"not a rule":         connection("context.app.version > \"2.0\""),
+			"syntax error":       connection(rule("syntax", "properties.revenue >")),
+			"undeclared field":   connection(rule("undeclared", "custom == 1")),
+			"non boolean result": connection(rule("string", "\"value\"")),
+		} {
+			_, err := CompileRules(c)
+			require.Error(t, err, name)
+		}
+	})
+
+	t.Run("evaluation", func(t *testing.T) {
+		rules, err := CompileRules(connection(
+			rule("recent app versions", `context.app.version > "2.0"`),
+			rule("revenue events", `message.type == "track" && properties.revenue != null`),
+			map[string]interface{}{"condition": `properties.revenue >= 10`},
+		))
+		require.NoError(t, err)
+		require.Len(t, rules, 3)
+		require.Equal(t, "properties.revenue >= 10", rules[2].Name, "conditions name unnamed rules")
+
+		event := func(version string, revenue interface{}) types.SingularEventT {
+			e := types.SingularEventT{
+				"type":       "track",
+				"context":    map[string]interface{}{"app": map[string]interface{}{"version": version}},
+				"properties": map[string]interface{}{},
+			}
+			if revenue != nil {
+				e["properties"] = map[string]interface{}{"revenue": revenue}
+			}
+			return e
+		}
+
+		allow, failed := rules.Allow(event("2.1", 12.5))
+		require.True(t, allow)
+		require.Nil(t, failed)
+
+		allow, failed = rules.Allow(event("1.9", 12.5))
+		require.False(t, allow)
+		require.Equal(t, "recent app versions", failed.Name)
+
+		allow, failed = rules.Allow(event("2.1", nil))
+		require.False(t, allow, "events missing a field don't satisfy rules referring to it")
+		require.Equal(t, "revenue events", failed.Name)
+
+		allow, failed = rules.Allow(event("2.1", 5))
+		require.False(t, allow, "numbers of different types are compared")
+		require.Equal(t, "properties.revenue >= 10", failed.Name)
+
+		allow, failed = rules.Allow(types.SingularEventT{"type": "track"})
+		require.False(t, allow)
+		require.Equal(t, "recent app versions", failed.Name)
+	})
+
+	t.Run("missing top-level fields are null", func(t *testing.T) {
+		rules, err := CompileRules(connection(rule("anonymous", `userId == null`)))
+		require.NoError(t, err)
+		allow, _ := rules.Allow(types.SingularEventT{"anonymousId": "anonymous-id"})
+		require.True(t, allow)
+		allow, _ = rules.Allow(types.SingularEventT{"userId": "user-id"})
+		require.False(t, allow)
+	})
+}
@@ -0,0 +1,81 @@
+package eventfilter
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/spaolacci/murmur3"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+const (
+	samplingKey = "eventSampling"
+
+	// SamplingStrategyRandom keeps every event with the sampling percentage as probability
+	SamplingStrategyRandom = "random"
+	// SamplingStrategyUser keeps all or none of the events of a user, based on a hash of its userId, or anonymousId
+	SamplingStrategyUser = "user"
+)
+
+// Sampling is the sampling policy of a destination
+type Sampling struct {
+	Percentage float64 // share of events to keep, between 0 and 100
+	Strategy   string
+}
+
+/*
+GetSampling returns the sampling policy of a destination, defined in its config:
+
+	"eventSampling": {
+		"percentage": 10,
+		"strategy": "user"
+	}
+
+The strategy defaults to random. Returns nil if the destination has no sampling policy, i.e. all of its events are kept.
+*/
+func GetSampling(destination *backendconfig.DestinationT) (*Sampling, error) {
+	samplingConfig, ok := destination.Config[samplingKey].(map[string]interface{})
+	if !ok || len(samplingConfig) == 0 {
+		return nil, nil
+	}
+	percentage, ok := samplingConfig["percentage"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("sampling percentage is required")
+	}
+	if percentage < 0 || percentage > 100 {
+		return nil, fmt.Errorf("sampling percentage %v is not between 0 and 100", percentage)
+	}
+	strategy, _ := samplingConfig["strategy"].(string)
+	switch strategy {
+	case "":
+		strategy = SamplingStrategyRandom
+	case SamplingStrategyRandom, SamplingStrategyUser:
+	default:
+		return nil, fmt.Errorf("unknown sampling strategy %q", strategy)
+	}
+	if percentage == 100 {
+		return nil, nil
+	}
+	return &Sampling{Percentage: percentage, Strategy: strategy}, nil
+}
+
+// Keep returns whether the event is kept by the sampling policy.
+// Events of unidentified users are sampled randomly, even with the user strategy.
+func (s *Sampling) Keep(event types.SingularEventT) bool {
+	if s == nil {
+		return true
+	}
+	if s.Strategy == SamplingStrategyUser {
+		userID, _ := event["userId"].(string)
+		if userID == "" {
+			userID, _ = event["anonymousId"].(string)
+		}
+		if userID != "" {
+			// the same users are kept for all destinations sharing the same percentage
+			return float64(murmur3.Sum64([]byte(userID))%10000) < s.Percentage*100
+		}
+	}
+	return rand.Float64()*100 < s.Percentage
+}
@@ -0,0 +1,78 @@
+package eventfilter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+func TestSampling(t *testing.T) {
+	destination := func(sampling interface{}) *backendconfig.DestinationT {
+		return &backendconfig.DestinationT{Config: map[string]interface{}{"eventSampling": sampling}}
+	}
+
+	t.Run("config", func(t *testing.T) {
+		s, err := GetSampling(&backendconfig.DestinationT{})
+		require.NoError(t, err)
+		require.Nil(t, s)
+		require.True(t, s.Keep(types.SingularEventT{}), "no sampling keeps all events")
+
+		s, err = GetSampling(destination(map[string]interface{}{"percentage": float64(100), "strategy": "user"}))
+		require.NoError(t, err)
+		require.Nil(t, s)
+
+		s, err = GetSampling(destination(map[string]interface{}{"percentage": float64(10)}))
+		require.NoError(t, err)
+		require.Equal(t, &Sampling{Percentage: 10, Strategy: SamplingStrategyRandom}, s)
+
+		for _, invalid := range []map[string]interface{}{
+			{"strategy": "user"},
+			{"percentage": float64(-1)},
+			{"percentage": float64(101)},
+			{"percentage": float64(10), "strategy": "unknown"},
+		} {
+			_, err := GetSampling(destination(invalid))
+			require.Error(t, err, invalid)
+		}
+	})
+
+	kept := func(s *Sampling, event func(i int) types.SingularEventT) int {
+		var n int
+		for i := range 10000 {
+			if s.Keep(event(i)) {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("random", func(t *testing.T) {
+		s := &Sampling{Percentage: 10, Strategy: SamplingStrategyRandom}
+		n := kept(s, func(int) types.SingularEventT { return types.SingularEventT{"userId": "user"} })
+		require.InDelta(t, 1000, n, 200)
+
+		require.Zero(t, kept(&Sampling{Percentage: 0, Strategy: SamplingStrategyRandom}, func(int) types.SingularEventT { return types.SingularEventT{} }))
+	})
+
+	t.Run("user", func(t *testing.T) {
+		s := &Sampling{Percentage: 10, Strategy: SamplingStrategyUser}
+		n := kept(s, func(i int) types.SingularEventT { return types.SingularEventT{"userId": fmt.Sprintf("user-%d", i)} })
+		require.InDelta(t, 1000, n, 200)
+
+		for i := range 100 {
+			event := types.SingularEventT{"anonymousId": fmt.Sprintf("anonymous-%d", i)}
+			keep := s.Keep(event)
+			for range 10 {
+				require.Equal(t, keep, s.Keep(event), "all events of a user are either kept or sampled out")
+			}
+			require.Equal(t, keep, s.Keep(types.SingularEventT{"userId": "", "anonymousId": event["anonymousId"]}))
+		}
+
+		n = kept(s, func(int) types.SingularEventT { return types.SingularEventT{} })
+		require.InDelta(t, 1000, n, 200, "events of unidentified users are sampled randomly")
+	})
+}
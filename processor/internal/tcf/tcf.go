@@ -0,0 +1,191 @@
+// Package tcf decodes consent strings of the IAB Transparency & Consent Framework (TCF) v2,
+// as specified in https://github.com/InteractiveAdvertisingBureau/GDPR-Transparency-and-Consent-Framework.
+//
+// Only the core segment is decoded, which holds the purpose and vendor consents. Publisher restrictions are not decoded.
+package tcf
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const version2 = 2
+
+// Consent is the core segment of a TCF v2 consent string
+type Consent struct {
+	Version           int
+	Created           time.Time
+	LastUpdated       time.Time
+	CMPID             int
+	CMPVersion        int
+	ConsentScreen     int
+	ConsentLanguage   string
+	VendorListVersion int
+	TCFPolicyVersion  int
+	IsServiceSpecific bool
+
+	purposesConsent           bitField
+	purposesLITransparency    bitField
+	vendorConsents            vendorSection
+	vendorLegitimateInterests vendorSection
+}
+
+// PurposeConsent returns whether the user consented to the purpose
+func (c *Consent) PurposeConsent(purposeID int) bool { return c.purposesConsent.has(purposeID) }
+
+// PurposeLegitimateInterest returns whether the legitimate interest for the purpose was disclosed to the user and not objected to
+func (c *Consent) PurposeLegitimateInterest(purposeID int) bool {
+	return c.purposesLITransparency.has(purposeID)
+}
+
+// VendorConsent returns whether the user consented to the vendor
+func (c *Consent) VendorConsent(vendorID int) bool { return c.vendorConsents.has(vendorID) }
+
+// VendorLegitimateInterest returns whether the legitimate interest of the vendor was disclosed to the user and not objected to
+func (c *Consent) VendorLegitimateInterest(vendorID int) bool {
+	return c.vendorLegitimateInterests.has(vendorID)
+}
+
+// Decode decodes the core segment of a TCF v2 consent string
+func Decode(consentString string) (*Consent, error) {
+	core, _, _ := strings.Cut(strings.TrimSpace(consentString), ".")
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(core, "="))
+	if err != nil {
+		return nil, fmt.Errorf("decoding consent string: %w", err)
+	}
+	r := &bitReader{data: data}
+	c := &Consent{Version: r.int(6)}
+	if r.err == nil && c.Version != version2 {
+		return nil, fmt.Errorf("unsupported consent string version %d", c.Version)
+	}
+	c.Created = r.time()
+	c.LastUpdated = r.time()
+	c.CMPID = r.int(12)
+	c.CMPVersion = r.int(12)
+	c.ConsentScreen = r.int(6)
+	c.ConsentLanguage = r.letters()
+	c.VendorListVersion = r.int(12)
+	c.TCFPolicyVersion = r.int(6)
+	c.IsServiceSpecific = r.bool()
+	r.skip(1 + 12) // UseNonStandardTexts, SpecialFeatureOptIns
+	c.purposesConsent = r.bitField(24)
+	c.purposesLITransparency = r.bitField(24)
+	r.skip(1 + 12) // PurposeOneTreatment, PublisherCC
+	c.vendorConsents = r.vendors()
+	c.vendorLegitimateInterests = r.vendors()
+	if r.err != nil {
+		return nil, fmt.Errorf("decoding consent string: %w", r.err)
+	}
+	return c, nil
+}
+
+// bitField holds whether each id, starting from 1, is set
+type bitField []bool
+
+func (b bitField) has(id int) bool {
+	return id > 0 && id <= len(b) && b[id-1]
+}
+
+// vendorSection holds the vendors of a section, either as a bit field or as the ranges it was encoded with,
+// so that decoding doesn't depend on the number of vendors in the ranges
+type vendorSection struct {
+	bits   bitField
+	ranges []idRange
+}
+
+type idRange struct{ start, end int }
+
+func (s vendorSection) has(id int) bool {
+	if s.bits.has(id) {
+		return true
+	}
+	for _, r := range s.ranges {
+		if id >= r.start && id <= r.end {
+			return true
+		}
+	}
+	return false
+}
+
+var errTooShort = errors.New("consent string too short")
+
+// bitReader reads big-endian bit fields, recording the first error
+type bitReader struct {
+	data []byte
+	pos  int // in bits
+	err  error
+}
+
+func (r *bitReader) int(bits int) int {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+bits > len(r.data)*8 {
+		r.err = errTooShort
+		return 0
+	}
+	var v int
+	for range bits {
+		v = v<<1 | int(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) bool() bool { return r.int(1) == 1 }
+
+func (r *bitReader) skip(bits int) { r.int(bits) }
+
+// time reads a timestamp in deciseconds
+func (r *bitReader) time() time.Time {
+	return time.UnixMilli(int64(r.int(36)) * 100).UTC()
+}
+
+// letters reads a two letter code, e.g. a language
+func (r *bitReader) letters() string {
+	return string([]byte{'A' + byte(r.int(6)), 'A' + byte(r.int(6))})
+}
+
+func (r *bitReader) bitField(bits int) bitField {
+	if r.err == nil && r.pos+bits > len(r.data)*8 {
+		r.err = errTooShort
+	}
+	if r.err != nil {
+		return nil
+	}
+	b := make(bitField, bits)
+	for i := range b {
+		b[i] = r.bool()
+	}
+	return b
+}
+
+// vendors reads a vendor section, either bit field or range encoded
+func (r *bitReader) vendors() vendorSection {
+	maxVendorID := r.int(16)
+	if !r.bool() {
+		return vendorSection{bits: r.bitField(maxVendorID)}
+	}
+	var s vendorSection
+	numEntries := r.int(12)
+	for range numEntries {
+		isRange := r.bool()
+		start := r.int(16)
+		end := start
+		if isRange {
+			end = r.int(16)
+		}
+		if r.err != nil {
+			return vendorSection{}
+		}
+		if start < 1 || end < start || end > maxVendorID {
+			r.err = fmt.Errorf("invalid vendor range %d-%d", start, end)
+			return vendorSection{}
+		}
+		s.ranges = append(s.ranges, idRange{start: start, end: end})
+	}
+	return s
+}
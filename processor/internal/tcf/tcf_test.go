@@ -0,0 +1,147 @@
+package tcf
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bitWriter writes big-endian bit fields, for encoding test consent strings
+type bitWriter struct {
+	bits []bool
+}
+
+func (w *bitWriter) int(v, bits int) *bitWriter {
+	for i := bits - 1; i >= 0; i-- {
+		w.bits = append(w.bits, v>>i&1 == 1)
+	}
+	return w
+}
+
+func (w *bitWriter) ids(bits int, ids ...int) *bitWriter {
+	field := make([]bool, bits)
+	for _, id := range ids {
+		field[id-1] = true
+	}
+	w.bits = append(w.bits, field...)
+	return w
+}
+
+func (w *bitWriter) String() string {
+	data := make([]byte, (len(w.bits)+7)/8)
+	for i, bit := range w.bits {
+		if bit {
+			data[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+var (
+	created     = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	lastUpdated = time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+)
+
+// core writes the fields of the core segment preceding the vendor sections
+func core(version int, purposesConsent, purposesLI []int) *bitWriter {
+	w := &bitWriter{}
+	w.int(version, 6).
+		int(int(created.UnixMilli()/100), 36).
+		int(int(lastUpdated.UnixMilli()/100), 36).
+		int(300, 12).         // CmpId
+		int(2, 12).           // CmpVersion
+		int(1, 6).            // ConsentScreen
+		int(4, 6).int(13, 6). // ConsentLanguage: EN
+		int(150, 12).         // VendorListVersion
+		int(4, 6).            // TcfPolicyVersion
+		int(1, 1).int(0, 1).  // IsServiceSpecific, UseNonStandardTexts
+		int(0, 12).           // SpecialFeatureOptIns
+		ids(24, purposesConsent...).
+		ids(24, purposesLI...).
+		int(0, 1).int(0, 12) // PurposeOneTreatment, PublisherCC
+	return w
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("bit field vendor sections", func(t *testing.T) {
+		w := core(2, []int{1, 3, 4}, []int{2, 7})
+		w.int(10, 16).int(0, 1).ids(10, 2, 10) // vendor consents
+		w.int(5, 16).int(0, 1).ids(5, 5)       // vendor legitimate interests
+		w.int(0, 12)                           // NumPubRestrictions
+
+		c, err := Decode(w.String() + ".YAAAAAAAAAAA")
+		require.NoError(t, err)
+		require.Equal(t, 2, c.Version)
+		require.Equal(t, created, c.Created)
+		require.Equal(t, lastUpdated, c.LastUpdated)
+		require.Equal(t, 300, c.CMPID)
+		require.Equal(t, 2, c.CMPVersion)
+		require.Equal(t, 1, c.ConsentScreen)
+		require.Equal(t, "EN", c.ConsentLanguage)
+		require.Equal(t, 150, c.VendorListVersion)
+		require.Equal(t, 4, c.TCFPolicyVersion)
+		require.True(t, c.IsServiceSpecific)
+
+		for id, consent := range map[int]bool{1: true, 2: false, 3: true, 4: true, 24: false, 25: false, 0: false} {
+			require.Equal(t, consent, c.PurposeConsent(id), "purpose %d", id)
+		}
+		require.True(t, c.PurposeLegitimateInterest(2))
+		require.True(t, c.PurposeLegitimateInterest(7))
+		require.False(t, c.PurposeLegitimateInterest(1))
+
+		for id, consent := range map[int]bool{1: false, 2: true, 9: false, 10: true, 11: false} {
+			require.Equal(t, consent, c.VendorConsent(id), "vendor %d", id)
+		}
+		require.True(t, c.VendorLegitimateInterest(5))
+		require.False(t, c.VendorLegitimateInterest(2))
+	})
+
+	t.Run("range vendor sections", func(t *testing.T) {
+		w := core(2, []int{1}, nil)
+		w.int(755, 16).int(1, 1).int(2, 12).
+			int(0, 1).int(8, 16).               // vendor 8
+			int(1, 1).int(700, 16).int(755, 16) // vendors 700-755
+		w.int(0, 16).int(0, 1) // no vendor legitimate interests
+
+		c, err := Decode(w.String())
+		require.NoError(t, err)
+		for id, consent := range map[int]bool{7: false, 8: true, 9: false, 699: false, 700: true, 755: true, 756: false} {
+			require.Equal(t, consent, c.VendorConsent(id), "vendor %d", id)
+		}
+		require.False(t, c.VendorLegitimateInterest(8))
+	})
+
+	t.Run("large vendor ranges", func(t *testing.T) {
+		w := core(2, nil, nil)
+		w.int(65535, 16).int(1, 1).int(4095, 12)
+		for range 4095 {
+			w.int(1, 1).int(1, 16).int(65535, 16)
+		}
+		w.int(0, 16).int(0, 1)
+
+		c, err := Decode(w.String())
+		require.NoError(t, err)
+		require.True(t, c.VendorConsent(1))
+		require.True(t, c.VendorConsent(65535))
+		require.False(t, c.VendorConsent(65536))
+	})
+
+	t.Run("invalid consent strings", func(t *testing.T) {
+		valid := core(2, nil, nil).int(0, 16).int(0, 1).int(0, 16).int(0, 1)
+		_, err := Decode(valid.String())
+		require.NoError(t, err)
+
+		for name, s := range map[string]string{
+			"empty":         "",
+			"not base64":    "C!!!",
+			"version 1":     core(1, nil, nil).int(0, 16).int(0, 1).int(0, 16).int(0, 1).String(),
+			"too short":     core(2, nil, nil).String(),
+			"invalid range": core(2, nil, nil).int(5, 16).int(1, 1).int(1, 12).int(1, 1).int(4, 16).int(9, 16).int(0, 16).int(0, 1).String(),
+		} {
+			_, err := Decode(s)
+			require.Error(t, err, name)
+		}
+	})
+}
@@ -30,7 +30,10 @@ import (
 	transformerclient "github.com/rudderlabs/rudder-server/internal/transformer-client"
 	"github.com/rudderlabs/rudder-server/processor/integrations"
 	transformerutils "github.com/rudderlabs/rudder-server/processor/internal/transformer"
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer/embedded/eventbridge"
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer/embedded/firehose"
 	"github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer/embedded/kafka"
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer/embedded/kinesis"
 	"github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer/embedded/pubsub"
 	"github.com/rudderlabs/rudder-server/processor/types"
 	"github.com/rudderlabs/rudder-server/utils/httputil"
@@ -83,8 +86,6 @@ func New(conf *config.Config, log logger.Logger, stat stats.Stats, opts ...Opt)
 	handle.config.maxLoggedEvents = conf.GetReloadableIntVar(100, 1, "Processor.DestinationTransformer.maxLoggedEvents")
 
 	handle.stats.comparisonTime = handle.stat.NewStat("embedded_destination_transform_comparison_time", stats.TimerType)
-	handle.stats.matchedEvents = handle.stat.NewStat("embedded_destination_transform_matched_events", stats.CountType)
-	handle.stats.mismatchedEvents = handle.stat.NewStat("embedded_destination_transform_mismatched_events", stats.CountType)
 
 	var err error
 	handle.samplingFileManager, err = getSamplingUploader(conf, log)
@@ -131,9 +132,7 @@ type Client struct {
 	warehouseClient warehouseClient
 
 	stats struct {
-		comparisonTime   stats.Timer
-		matchedEvents    stats.Counter
-		mismatchedEvents stats.Counter
+		comparisonTime stats.Timer
 	}
 
 	loggedEvents        atomic.Int64
@@ -388,6 +387,9 @@ type transformer func(ctx context.Context, clientEvents []types.TransformerEvent
 var embeddedTransformerImpls = map[string]transformer{
 	"GOOGLEPUBSUB": pubsub.Transform,
 	"KAFKA":        kafka.Transform,
+	"KINESIS":      kinesis.Transform,
+	"FIREHOSE":     firehose.Transform,
+	"EVENTBRIDGE":  eventbridge.Transform,
 }
 
 func (c *Client) Transform(ctx context.Context, clientEvents []types.TransformerEvent) types.Response {
@@ -415,7 +417,7 @@ func (c *Client) Transform(ctx context.Context, clientEvents []types.Transformer
 	if c.conf.GetBoolVar(true, "Processor.Transformer.Embedded."+destType+".Verify") {
 		legacyTransformerResponse := c.transform(ctx, clientEvents)
 		embeddedTransformerResponse := impl(ctx, clientEvents)
-		c.CompareAndLog(ctx, embeddedTransformerResponse, legacyTransformerResponse)
+		c.CompareAndLog(ctx, destType, embeddedTransformerResponse, legacyTransformerResponse)
 		return legacyTransformerResponse
 	}
 	return impl(ctx, clientEvents)
@@ -889,3 +889,208 @@ func TestEmbeddedWarehouseTransformer(t *testing.T) {
 	require.ElementsMatch(t, expectedMessageIDs, actualMessageIDS)
 	require.ElementsMatch(t, expectedResponse, r.Events)
 }
+
+func TestEmbeddedStreamTransformer(t *testing.T) {
+	// legacyTransformer mimics the kinesis transformation of the transformer service, using the anonymousId as userId if mismatch is set
+	legacyTransformer := func(mismatch bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var reqBody []types.TransformerEvent
+			require.NoError(t, jsonrs.NewDecoder(r.Body).Decode(&reqBody))
+			responses := make([]types.TransformerResponse, len(reqBody))
+			for i := range reqBody {
+				userID := reqBody[i].Message["userId"]
+				if mismatch {
+					userID = reqBody[i].Message["anonymousId"]
+				}
+				responses[i] = types.TransformerResponse{
+					Output:     map[string]any{"message": reqBody[i].Message, "userId": userID},
+					Metadata:   reqBody[i].Metadata,
+					StatusCode: http.StatusOK,
+				}
+			}
+			w.Header().Set("apiVersion", strconv.Itoa(reportingtypes.SupportedTransformerApiVersion))
+			require.NoError(t, jsonrs.NewEncoder(w).Encode(responses))
+		}
+	}
+	events := lo.RepeatBy(10, func(index int) types.TransformerEvent {
+		return types.TransformerEvent{
+			Message: map[string]any{
+				"messageId":   "messageId" + strconv.Itoa(index+1),
+				"userId":      "user-id",
+				"anonymousId": "anonymous-id",
+			},
+			Metadata: types.Metadata{
+				MessageID:       "messageId" + strconv.Itoa(index+1),
+				DestinationID:   "destination-id",
+				DestinationType: "KINESIS",
+				JobID:           int64(index + 1),
+			},
+			Destination: backendconfig.DestinationT{
+				ID:                    "destination-id",
+				DestinationDefinition: backendconfig.DestinationDefinitionT{Name: "KINESIS"},
+			},
+		}
+	})
+	tags := stats.Tags{"destType": "KINESIS"}
+
+	for _, mismatch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("shadow compare with mismatch=%t", mismatch), func(t *testing.T) {
+			srv := httptest.NewServer(legacyTransformer(mismatch))
+			defer srv.Close()
+			conf := config.New()
+			conf.Set("DEST_TRANSFORM_URL", srv.URL)
+			conf.Set("Processor.Transformer.Embedded.KINESIS.Enabled", true)
+			statsStore, err := memstats.New()
+			require.NoError(t, err)
+
+			r := destination_transformer.New(conf, logger.NOP, statsStore).Transform(context.Background(), events)
+			require.Len(t, r.Events, len(events))
+			if mismatch {
+				require.Equal(t, "anonymous-id", r.Events[0].Output["userId"], "the legacy response is returned")
+			}
+			matched, mismatched := len(events), 0
+			if mismatch {
+				matched, mismatched = 0, len(events)
+			}
+			require.Eventually(t, func() bool {
+				m := statsStore.Get("embedded_destination_transform_matched_events", tags)
+				mm := statsStore.Get("embedded_destination_transform_mismatched_events", tags)
+				return m != nil && mm != nil && int(m.LastValue()) == matched && int(mm.LastValue()) == mismatched
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+
+	t.Run("differences are reported as stats once the differences logged reach the limit", func(t *testing.T) {
+		srv := httptest.NewServer(legacyTransformer(true))
+		defer srv.Close()
+		conf := config.New()
+		conf.Set("DEST_TRANSFORM_URL", srv.URL)
+		conf.Set("Processor.Transformer.Embedded.KINESIS.Enabled", true)
+		conf.Set("Processor.DestinationTransformer.maxLoggedEvents", 0)
+		statsStore, err := memstats.New()
+		require.NoError(t, err)
+
+		client := destination_transformer.New(conf, logger.NOP, statsStore)
+		for range 2 {
+			r := client.Transform(context.Background(), events)
+			require.Len(t, r.Events, len(events))
+		}
+		require.Eventually(t, func() bool {
+			m := statsStore.Get("embedded_destination_transform_matched_events", tags)
+			mm := statsStore.Get("embedded_destination_transform_mismatched_events", tags)
+			return m != nil && mm != nil && m.LastValue() == 0 && int(mm.LastValue()) == 2*len(events)
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("embedded only", func(t *testing.T) {
+		srv := httptest.NewServer(legacyTransformer(true))
+		defer srv.Close()
+		conf := config.New()
+		conf.Set("DEST_TRANSFORM_URL", srv.URL)
+		conf.Set("Processor.Transformer.Embedded.KINESIS.Enabled", true)
+		conf.Set("Processor.Transformer.Embedded.KINESIS.Verify", false)
+
+		r := destination_transformer.New(conf, logger.NOP, stats.NOP).Transform(context.Background(), events)
+		require.Len(t, r.Events, len(events))
+		require.Equal(t, "user-id", r.Events[0].Output["userId"])
+	})
+}
+
+func TestEmbeddedStreamTransformerParity(t *testing.T) {
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+	transformerResource, err := transformertest.Setup(pool, t)
+	require.NoError(t, err)
+
+	messages := []map[string]any{
+		{
+			"messageId":         "message-1",
+			"type":              "track",
+			"event":             "Product Added",
+			"userId":            "user-1",
+			"anonymousId":       "anonymous-1",
+			"properties":        map[string]any{"price": 9.99, "sku": "sku-1", "tags": []any{"a", "b"}},
+			"context":           map[string]any{"library": map[string]any{"name": "rudder-sdk-js"}},
+			"originalTimestamp": "2021-09-01T00:00:00.000Z",
+			"receivedAt":        "2021-09-01T00:00:00.000Z",
+			"sentAt":            "2021-09-01T00:00:00.000Z",
+			"timestamp":         "2021-09-01T00:00:00.000Z",
+		},
+		{
+			"messageId":   "message-2",
+			"type":        "identify",
+			"anonymousId": "anonymous-2",
+			"traits":      map[string]any{"email": "user@example.com"},
+		},
+		{
+			"messageId": "message-3",
+			"type":      "page",
+			"userId":    "",
+			"name":      "Home",
+		},
+		{
+			"messageId":   "message-4",
+			"type":        "record",
+			"event":       "insert",
+			"anonymousId": "anonymous-4",
+			"fields":      map[string]any{"id": 4},
+			"context":     map[string]any{"sources": map[string]any{"job_id": "job-1"}, "mappedToDestination": "true"},
+			"timestamp":   "2021-09-01T00:00:00.000Z",
+		},
+	}
+	destinations := map[string]map[string]any{
+		"KINESIS": {"stream": "stream", "useMessageId": false},
+		"FIREHOSE": {"mapEvents": []any{
+			map[string]any{"from": "Product Added", "to": "products"},
+			map[string]any{"from": "identify", "to": "users"},
+			map[string]any{"from": "record", "to": "records"},
+		}},
+		"EVENTBRIDGE": {"eventBusName": "bus", "detailType": "", "resourceID": "resource"},
+	}
+
+	for destType, destinationConfig := range destinations {
+		t.Run(destType, func(t *testing.T) {
+			// events are created for every transformation, since transformations may modify them
+			newEvents := func() []types.TransformerEvent {
+				return lo.Map(messages, func(message map[string]any, index int) types.TransformerEvent {
+					return types.TransformerEvent{
+						Message: deepCopyMessage(t, message),
+						Metadata: types.Metadata{
+							MessageID:       message["messageId"].(string),
+							SourceID:        "source-id",
+							DestinationID:   "destination-id",
+							DestinationType: destType,
+							EventType:       message["type"].(string),
+							JobID:           int64(index + 1),
+						},
+						Destination: backendconfig.DestinationT{
+							ID:                    "destination-id",
+							WorkspaceID:           "workspace-id",
+							Config:                destinationConfig,
+							DestinationDefinition: backendconfig.DestinationDefinitionT{Name: destType},
+						},
+					}
+				})
+			}
+			transform := func(embedded bool) types.Response {
+				conf := config.New()
+				conf.Set("DEST_TRANSFORM_URL", transformerResource.TransformerURL)
+				conf.Set("Processor.Transformer.Embedded."+destType+".Enabled", embedded)
+				conf.Set("Processor.Transformer.Embedded."+destType+".Verify", false)
+				return destination_transformer.New(conf, logger.NOP, stats.NOP).Transform(context.Background(), newEvents())
+			}
+
+			legacyResponse := transform(false)
+			require.NotEmpty(t, legacyResponse.Events)
+			require.Equal(t, legacyResponse, transform(true))
+		})
+	}
+}
+
+func deepCopyMessage(t *testing.T, message map[string]any) types.SingularEventT {
+	data, err := jsonrs.Marshal(message)
+	require.NoError(t, err)
+	var copied types.SingularEventT
+	require.NoError(t, jsonrs.Unmarshal(data, &copied))
+	return copied
+}
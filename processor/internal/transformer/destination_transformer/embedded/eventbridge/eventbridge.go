@@ -0,0 +1,66 @@
+package eventbridge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+
+	utils "github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer/embedded"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+const source = "rudderstack"
+
+// Transform transforms events into PutEventsRequestEntry payloads, as expected by the EventBridge producer
+func Transform(_ context.Context, events []types.TransformerEvent) types.Response {
+	response := types.Response{}
+	destinationConfig := events[0].Destination.Config
+	eventBusName, _ := destinationConfig["eventBusName"].(string)
+	detailType, _ := destinationConfig["detailType"].(string)
+	resourceID, _ := destinationConfig["resourceID"].(string)
+
+	for _, event := range events {
+		event.Metadata.SourceDefinitionType = "" // TODO: Currently, it's getting ignored during JSON marshalling Remove this once we start using it.
+
+		if event.Destination.ID != events[0].Destination.ID {
+			panic("all events must have the same destination")
+		}
+
+		event.Message = utils.UpdateTimestampFieldForRETLEvent(event.Message)
+		detail, err := jsonrs.Marshal(event.Message)
+		if err != nil {
+			response.FailedEvents = append(response.FailedEvents, types.TransformerResponse{
+				Error:      fmt.Sprintf("encoding event detail: %v", err),
+				Metadata:   event.Metadata,
+				StatusCode: http.StatusBadRequest,
+				StatTags:   utils.GetValidationErrorStatTags(event.Destination),
+			})
+			continue
+		}
+
+		eventDetailType := detailType
+		if eventDetailType == "" {
+			eventDetailType, _ = event.Message["type"].(string)
+		}
+
+		output := map[string]interface{}{
+			"Detail":       string(detail),
+			"DetailType":   eventDetailType,
+			"EventBusName": eventBusName,
+			"Source":       source,
+		}
+		if resourceID != "" {
+			output["Resources"] = []interface{}{resourceID}
+		}
+
+		response.Events = append(response.Events, types.TransformerResponse{
+			Output:     output,
+			Metadata:   event.Metadata,
+			StatusCode: http.StatusOK,
+		})
+	}
+
+	return response
+}
@@ -0,0 +1,114 @@
+package eventbridge
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+func TestTransform(t *testing.T) {
+	destinationWithDefaults := backendconfig.DestinationT{
+		ID:          "destination-id-123",
+		WorkspaceID: "workspace-id-123",
+		DestinationDefinition: backendconfig.DestinationDefinitionT{
+			Name: "EVENTBRIDGE",
+		},
+		Config: map[string]interface{}{
+			"eventBusName": "default",
+		},
+	}
+
+	destinationWithConfig := backendconfig.DestinationT{
+		ID:          "destination-id-456",
+		WorkspaceID: "workspace-id-456",
+		DestinationDefinition: backendconfig.DestinationDefinitionT{
+			Name: "EVENTBRIDGE",
+		},
+		Config: map[string]interface{}{
+			"eventBusName": "custom-bus",
+			"detailType":   "custom-detail-type",
+			"resourceID":   "arn:aws:resource",
+		},
+	}
+
+	cases := []struct {
+		name   string
+		events []types.TransformerEvent
+		want   types.Response
+	}{
+		{
+			name: "should use the event type as detail type by default",
+			events: []types.TransformerEvent{
+				{
+					Message:     map[string]interface{}{"userId": "user-123", "type": "track", "event": "Order Completed"},
+					Destination: destinationWithDefaults,
+					Metadata:    types.Metadata{MessageID: "message-1"},
+				},
+			},
+			want: types.Response{
+				Events: []types.TransformerResponse{
+					{
+						Output: map[string]interface{}{
+							"Detail":       `{"event":"Order Completed","type":"track","userId":"user-123"}`,
+							"DetailType":   "track",
+							"EventBusName": "default",
+							"Source":       "rudderstack",
+						},
+						Metadata:   types.Metadata{MessageID: "message-1"},
+						StatusCode: http.StatusOK,
+					},
+				},
+			},
+		},
+		{
+			name: "should use the configured detail type and resource",
+			events: []types.TransformerEvent{
+				{
+					Message:     map[string]interface{}{"anonymousId": "anonymous-123", "type": "identify"},
+					Destination: destinationWithConfig,
+				},
+			},
+			want: types.Response{
+				Events: []types.TransformerResponse{
+					{
+						Output: map[string]interface{}{
+							"Detail":       `{"anonymousId":"anonymous-123","type":"identify"}`,
+							"DetailType":   "custom-detail-type",
+							"EventBusName": "custom-bus",
+							"Resources":    []interface{}{"arn:aws:resource"},
+							"Source":       "rudderstack",
+						},
+						StatusCode: http.StatusOK,
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Transform(context.Background(), c.events)
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestPanicIfDestinationIDIsDifferent(t *testing.T) {
+	events := []types.TransformerEvent{
+		{
+			Destination: backendconfig.DestinationT{ID: "destination-id-123"},
+		},
+		{
+			Destination: backendconfig.DestinationT{ID: "destination-id-456"},
+		},
+	}
+
+	require.Panics(t, func() {
+		Transform(context.Background(), events)
+	})
+}
@@ -0,0 +1,70 @@
+package firehose
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	utils "github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer/embedded"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+func Transform(_ context.Context, events []types.TransformerEvent) types.Response {
+	response := types.Response{}
+	deliveryStreamMap := utils.GetTopicMap(events[0].Destination, "mapEvents", true)
+
+	for _, event := range events {
+		event.Metadata.SourceDefinitionType = "" // TODO: Currently, it's getting ignored during JSON marshalling Remove this once we start using it.
+
+		if event.Destination.ID != events[0].Destination.ID {
+			panic("all events must have the same destination")
+		}
+
+		event.Message = utils.UpdateTimestampFieldForRETLEvent(event.Message)
+		deliveryStream, err := getDeliveryStream(event.Message, deliveryStreamMap)
+		if err != nil {
+			response.FailedEvents = append(response.FailedEvents, types.TransformerResponse{
+				Error:      err.Error(),
+				Metadata:   event.Metadata,
+				StatusCode: http.StatusBadRequest,
+				StatTags:   utils.GetValidationErrorStatTags(event.Destination),
+			})
+			continue
+		}
+
+		var userId string
+		if id, ok := event.Message["userId"].(string); ok && id != "" {
+			userId = id
+		} else if id, ok := event.Message["anonymousId"].(string); ok {
+			userId = id
+		}
+
+		response.Events = append(response.Events, types.TransformerResponse{
+			Output: map[string]interface{}{
+				"message":             utils.GetMessageAsMap(event.Message),
+				"userId":              userId,
+				"deliveryStreamMapTo": deliveryStream,
+			},
+			Metadata:   event.Metadata,
+			StatusCode: http.StatusOK,
+		})
+	}
+
+	return response
+}
+
+// getDeliveryStream returns the delivery stream mapped to the event name, the event type or, as a catch-all, to "*"
+func getDeliveryStream(message types.SingularEventT, deliveryStreamMap map[string]string) (string, error) {
+	for _, key := range []string{"event", "type"} {
+		if value, ok := message[key].(string); ok && value != "" {
+			if deliveryStream, ok := deliveryStreamMap[strings.ToLower(value)]; ok && deliveryStream != "" {
+				return deliveryStream, nil
+			}
+		}
+	}
+	if deliveryStream, ok := deliveryStreamMap["*"]; ok && deliveryStream != "" {
+		return deliveryStream, nil
+	}
+	return "", fmt.Errorf("no delivery stream set for this event")
+}
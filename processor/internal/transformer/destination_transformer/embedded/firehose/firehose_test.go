@@ -0,0 +1,162 @@
+package firehose
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+func TestTransform(t *testing.T) {
+	destinationWithMappings := backendconfig.DestinationT{
+		ID:          "destination-id-123",
+		WorkspaceID: "workspace-id-123",
+		DestinationDefinition: backendconfig.DestinationDefinitionT{
+			Name: "FIREHOSE",
+		},
+		Config: map[string]interface{}{
+			"mapEvents": []interface{}{
+				map[string]interface{}{"from": "Order Completed", "to": "orders-stream"},
+				map[string]interface{}{"from": "identify", "to": "identify-stream"},
+				map[string]interface{}{"from": "page", "to": ""},
+				map[string]interface{}{"from": "*", "to": "default-stream"},
+			},
+		},
+	}
+
+	destinationWithoutCatchAll := backendconfig.DestinationT{
+		ID:          "destination-id-456",
+		WorkspaceID: "workspace-id-456",
+		DestinationDefinition: backendconfig.DestinationDefinitionT{
+			Name: "FIREHOSE",
+		},
+		Config: map[string]interface{}{
+			"mapEvents": []interface{}{
+				map[string]interface{}{"from": "track", "to": "track-stream"},
+			},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		events []types.TransformerEvent
+		want   types.Response
+	}{
+		{
+			name: "should map events to delivery streams",
+			events: []types.TransformerEvent{
+				{
+					Message:     map[string]interface{}{"userId": "user-123", "type": "track", "event": "order completed"},
+					Destination: destinationWithMappings,
+				},
+				{
+					Message:     map[string]interface{}{"anonymousId": "anonymous-123", "type": "identify"},
+					Destination: destinationWithMappings,
+				},
+				{
+					Message:     map[string]interface{}{"userId": "user-123", "type": "page"},
+					Destination: destinationWithMappings,
+				},
+			},
+			want: types.Response{
+				Events: []types.TransformerResponse{
+					{
+						Output: map[string]interface{}{
+							"message":             map[string]interface{}{"userId": "user-123", "type": "track", "event": "order completed"},
+							"userId":              "user-123",
+							"deliveryStreamMapTo": "orders-stream",
+						},
+						StatusCode: http.StatusOK,
+					},
+					{
+						Output: map[string]interface{}{
+							"message":             map[string]interface{}{"anonymousId": "anonymous-123", "type": "identify"},
+							"userId":              "anonymous-123",
+							"deliveryStreamMapTo": "identify-stream",
+						},
+						StatusCode: http.StatusOK,
+					},
+					{
+						Output: map[string]interface{}{
+							"message":             map[string]interface{}{"userId": "user-123", "type": "page"},
+							"userId":              "user-123",
+							"deliveryStreamMapTo": "default-stream",
+						},
+						StatusCode: http.StatusOK,
+					},
+				},
+			},
+		},
+		{
+			name: "should fail events without a delivery stream",
+			events: []types.TransformerEvent{
+				{
+					Message:     map[string]interface{}{"userId": "user-123", "type": "track", "event": "Product Viewed"},
+					Destination: destinationWithoutCatchAll,
+					Metadata:    types.Metadata{MessageID: "message-1"},
+				},
+				{
+					Message:     map[string]interface{}{"userId": "user-123", "type": "identify"},
+					Destination: destinationWithoutCatchAll,
+					Metadata:    types.Metadata{MessageID: "message-2"},
+				},
+			},
+			want: types.Response{
+				Events: []types.TransformerResponse{
+					{
+						Output: map[string]interface{}{
+							"message":             map[string]interface{}{"userId": "user-123", "type": "track", "event": "Product Viewed"},
+							"userId":              "user-123",
+							"deliveryStreamMapTo": "track-stream",
+						},
+						Metadata:   types.Metadata{MessageID: "message-1"},
+						StatusCode: http.StatusOK,
+					},
+				},
+				FailedEvents: []types.TransformerResponse{
+					{
+						Error:      "no delivery stream set for this event",
+						Metadata:   types.Metadata{MessageID: "message-2"},
+						StatusCode: http.StatusBadRequest,
+						StatTags: map[string]string{
+							"destinationId":  "destination-id-456",
+							"workspaceId":    "workspace-id-456",
+							"destType":       "FIREHOSE",
+							"module":         "destination",
+							"implementation": "native",
+							"errorCategory":  "dataValidation",
+							"errorType":      "configuration",
+							"feature":        "processor",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Transform(context.Background(), c.events)
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestPanicIfDestinationIDIsDifferent(t *testing.T) {
+	events := []types.TransformerEvent{
+		{
+			Destination: backendconfig.DestinationT{ID: "destination-id-123"},
+		},
+		{
+			Destination: backendconfig.DestinationT{ID: "destination-id-456"},
+		},
+	}
+
+	require.Panics(t, func() {
+		Transform(context.Background(), events)
+	})
+}
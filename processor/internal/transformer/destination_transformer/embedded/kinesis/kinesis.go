@@ -0,0 +1,41 @@
+package kinesis
+
+import (
+	"context"
+	"net/http"
+
+	utils "github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer/embedded"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+func Transform(_ context.Context, events []types.TransformerEvent) types.Response {
+	response := types.Response{}
+
+	for _, event := range events {
+		event.Metadata.SourceDefinitionType = "" // TODO: Currently, it's getting ignored during JSON marshalling Remove this once we start using it.
+
+		if event.Destination.ID != events[0].Destination.ID {
+			panic("all events must have the same destination")
+		}
+
+		event.Message = utils.UpdateTimestampFieldForRETLEvent(event.Message)
+		var userId string
+		if id, ok := event.Message["userId"].(string); ok && id != "" {
+			userId = id
+		} else if id, ok := event.Message["anonymousId"].(string); ok {
+			userId = id
+		}
+
+		// the partition key is the userId, unless the producer is configured to use the messageId of the message instead
+		response.Events = append(response.Events, types.TransformerResponse{
+			Output: map[string]interface{}{
+				"message": utils.GetMessageAsMap(event.Message),
+				"userId":  userId,
+			},
+			Metadata:   event.Metadata,
+			StatusCode: http.StatusOK,
+		})
+	}
+
+	return response
+}
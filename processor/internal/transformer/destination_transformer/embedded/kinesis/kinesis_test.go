@@ -0,0 +1,141 @@
+package kinesis
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+func TestTransform(t *testing.T) {
+	destination := backendconfig.DestinationT{
+		ID:          "destination-id-123",
+		WorkspaceID: "workspace-id-123",
+		DestinationDefinition: backendconfig.DestinationDefinitionT{
+			Name: "KINESIS",
+		},
+		Config: map[string]interface{}{
+			"stream":       "stream-123",
+			"useMessageId": true,
+		},
+	}
+
+	cases := []struct {
+		name   string
+		events []types.TransformerEvent
+		want   types.Response
+	}{
+		{
+			name: "should set correct userId for each event",
+			events: []types.TransformerEvent{
+				{
+					Message:     map[string]interface{}{"userId": "user-123", "messageId": "message-1"},
+					Destination: destination,
+					Metadata:    types.Metadata{MessageID: "message-1"},
+				},
+				{
+					Message:     map[string]interface{}{"anonymousId": "anonymous-123"},
+					Destination: destination,
+				},
+				{
+					Message:     map[string]interface{}{"userId": "", "anonymousId": "anonymous-123"},
+					Destination: destination,
+				},
+				{
+					Message:     map[string]interface{}{},
+					Destination: destination,
+				},
+			},
+			want: types.Response{
+				Events: []types.TransformerResponse{
+					{
+						Output: map[string]interface{}{
+							"message": map[string]interface{}{"userId": "user-123", "messageId": "message-1"},
+							"userId":  "user-123",
+						},
+						Metadata:   types.Metadata{MessageID: "message-1"},
+						StatusCode: http.StatusOK,
+					},
+					{
+						Output: map[string]interface{}{
+							"message": map[string]interface{}{"anonymousId": "anonymous-123"},
+							"userId":  "anonymous-123",
+						},
+						StatusCode: http.StatusOK,
+					},
+					{
+						Output: map[string]interface{}{
+							"message": map[string]interface{}{"userId": "", "anonymousId": "anonymous-123"},
+							"userId":  "anonymous-123",
+						},
+						StatusCode: http.StatusOK,
+					},
+					{
+						Output: map[string]interface{}{
+							"message": map[string]interface{}{},
+							"userId":  "",
+						},
+						StatusCode: http.StatusOK,
+					},
+				},
+			},
+		},
+		{
+			name: "should update the timestamp of RETL events",
+			events: []types.TransformerEvent{
+				{
+					Message: map[string]interface{}{
+						"userId":     "user-123",
+						"channel":    "sources",
+						"type":       "track",
+						"properties": map[string]interface{}{"timestamp": "2024-01-01T00:00:00Z"},
+					},
+					Destination: destination,
+				},
+			},
+			want: types.Response{
+				Events: []types.TransformerResponse{
+					{
+						Output: map[string]interface{}{
+							"message": map[string]interface{}{
+								"userId":     "user-123",
+								"channel":    "sources",
+								"type":       "track",
+								"properties": map[string]interface{}{"timestamp": "2024-01-01T00:00:00Z"},
+								"timestamp":  "2024-01-01T00:00:00Z",
+							},
+							"userId": "user-123",
+						},
+						StatusCode: http.StatusOK,
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Transform(context.Background(), c.events)
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestPanicIfDestinationIDIsDifferent(t *testing.T) {
+	events := []types.TransformerEvent{
+		{
+			Destination: backendconfig.DestinationT{ID: "destination-id-123"},
+		},
+		{
+			Destination: backendconfig.DestinationT{ID: "destination-id-456"},
+		},
+	}
+
+	require.Panics(t, func() {
+		Transform(context.Background(), events)
+	})
+}
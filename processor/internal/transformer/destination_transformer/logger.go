@@ -11,41 +11,47 @@ import (
 
 	"github.com/rudderlabs/rudder-go-kit/config"
 	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
 	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
 
 	"github.com/rudderlabs/rudder-go-kit/jsonrs"
 	"github.com/rudderlabs/rudder-server/processor/types"
 )
 
+// CompareAndLog compares the responses of the embedded and legacy transformers in the background.
+// Matched and mismatched events are always reported as stats, tagged by destination type,
+// while samples of the differences are only logged until maxLoggedEvents differences have been logged.
 func (c *Client) CompareAndLog(
 	ctx context.Context,
+	destType string,
 	embeddedResponse, legacyResponse types.Response,
 ) {
-	if c.samplingFileManager == nil { // Cannot upload, we should just report the issue with no diff
-		c.log.Warnn("DestinationTransformer sanity check failed")
-		return
-	}
-	if c.loggedEvents.Load() >= int64(c.config.maxLoggedEvents.Load()) {
-		return
-	}
 	go func() {
 		defer c.stats.comparisonTime.RecordDuration()()
-		c.compareAndLog(ctx, embeddedResponse, legacyResponse)
+		c.compareAndLog(ctx, destType, embeddedResponse, legacyResponse)
 	}()
 }
 
 func (c *Client) compareAndLog(
 	ctx context.Context,
+	destType string,
 	embeddedResponse, legacyResponse types.Response,
 ) {
-	differingResponse, sampleDiff := c.differingEvents(embeddedResponse, legacyResponse)
+	differingResponse, sampleDiff := c.differingEvents(destType, embeddedResponse, legacyResponse)
 	noOfDifferences := int64(len(differingResponse))
 	if noOfDifferences == 0 && sampleDiff == "" {
 		return
 	}
-
+	if c.loggedEvents.Load() >= int64(c.config.maxLoggedEvents.Load()) {
+		return
+	}
 	c.loggedEvents.Add(noOfDifferences)
 
+	if c.samplingFileManager == nil { // Cannot upload, we should just report the issue with no diff
+		c.log.Warnn("DestinationTransformer sanity check failed", logger.NewStringField("destType", destType))
+		return
+	}
+
 	objName := path.Join("embedded-dt-samples", config.GetKubeNamespace(), uuid.New().String())
 	differingResponseJSON, err := jsonrs.Marshal(differingResponse)
 	if err != nil {
@@ -69,10 +75,14 @@ func (c *Client) compareAndLog(
 }
 
 func (c *Client) differingEvents(
+	destType string,
 	embeddedResponse, legacyResponse types.Response,
 ) ([]types.TransformerResponse, string) {
+	tags := stats.Tags{"destType": destType}
+	matchedEvents := c.stat.NewTaggedStat("embedded_destination_transform_matched_events", stats.CountType, tags)
+	mismatchedEvents := c.stat.NewTaggedStat("embedded_destination_transform_mismatched_events", stats.CountType, tags)
 	if len(embeddedResponse.Events) != len(legacyResponse.Events) || len(embeddedResponse.FailedEvents) != len(legacyResponse.FailedEvents) {
-		c.stats.mismatchedEvents.Count(len(embeddedResponse.Events) + len(embeddedResponse.FailedEvents))
+		mismatchedEvents.Count(len(embeddedResponse.Events) + len(embeddedResponse.FailedEvents))
 		return []types.TransformerResponse{}, fmt.Sprintf("Event counts mismatch: Successful events (%d vs %d), Failed events (%d vs %d)",
 			len(embeddedResponse.Events),
 			len(legacyResponse.Events),
@@ -114,7 +124,7 @@ func (c *Client) differingEvents(
 		differedEventsCount++
 	}
 
-	c.stats.matchedEvents.Count(len(legacyResponse.Events) + len(legacyResponse.FailedEvents) - differedEventsCount)
-	c.stats.mismatchedEvents.Count(differedEventsCount)
+	matchedEvents.Count(len(legacyResponse.Events) + len(legacyResponse.FailedEvents) - differedEventsCount)
+	mismatchedEvents.Count(differedEventsCount)
 	return differedSampleEvents, sampleDiff
 }
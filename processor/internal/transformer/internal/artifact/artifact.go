@@ -0,0 +1,105 @@
+// Package artifact caches the versioned artifacts which embedded transformers fetch from the control plane and compile,
+// e.g. user transformations or tracking plans, so that they can run in-process instead of in the transformer service.
+package artifact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/samber/lo"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+)
+
+// FallbackError is returned for events which need to be sent to the transformer service
+type FallbackError struct {
+	Reason string // used as a stats tag
+	Err    error
+}
+
+func (e *FallbackError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+	}
+	return e.Reason
+}
+
+func (e *FallbackError) Unwrap() error { return e.Err }
+
+// ReasonFetch is the reason of fallbacks for artifacts which cannot be fetched
+const ReasonFetch = "fetch"
+
+// NewCache creates a cache of artifacts, keeping up to maxEntries of them.
+// Fetches time out after fetchTimeout and their errors are cached for fetchErrorTTL.
+func NewCache[T any](fetchTimeout, fetchErrorTTL config.ValueLoader[time.Duration], maxEntries config.ValueLoader[int]) *Cache[T] {
+	c := &Cache[T]{
+		entries: lo.Must(lru.New[string, *entry[T]](1)), // resized on every addition
+	}
+	c.config.fetchTimeout = fetchTimeout
+	c.config.fetchErrorTTL = fetchErrorTTL
+	c.config.maxEntries = maxEntries
+	return c
+}
+
+// Cache caches compiled artifacts by version, evicting the least recently used ones.
+// Concurrent requests of the same version are fetched once.
+type Cache[T any] struct {
+	config struct {
+		fetchTimeout  config.ValueLoader[time.Duration]
+		fetchErrorTTL config.ValueLoader[time.Duration]
+		maxEntries    config.ValueLoader[int]
+	}
+
+	fetchGroup singleflight.Group
+	entries    *lru.Cache[string, *entry[T]]
+}
+
+// entry is the outcome of fetching and compiling a version.
+// Versions are immutable, so only fetch errors and fallbacks expire.
+type entry[T any] struct {
+	artifact  T
+	err       error
+	expiresAt time.Time
+}
+
+// Get returns the artifact of the version, calling load to fetch and compile it if it isn't cached.
+//
+// load returns a [FallbackError] for artifacts which cannot be compiled, which is cached for good.
+// Any other error is a fetch error, returned as a [FallbackError] with the [ReasonFetch] reason.
+func (c *Cache[T]) Get(ctx context.Context, version string, load func(ctx context.Context) (T, error)) (T, error) {
+	cached, ok := c.entries.Get(version)
+	if ok && (cached.expiresAt.IsZero() || time.Now().Before(cached.expiresAt)) {
+		return cached.artifact, cached.err
+	}
+
+	v, _, _ := c.fetchGroup.Do(version, func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(ctx, c.config.fetchTimeout.Load())
+		defer cancel()
+		cached := &entry[T]{}
+		cached.artifact, cached.err = load(fetchCtx)
+		var fe *FallbackError
+		if cached.err != nil && !errors.As(cached.err, &fe) {
+			cached.err = &FallbackError{Reason: ReasonFetch, Err: cached.err}
+			if ctx.Err() != nil { // not caching errors of cancelled requests
+				return cached, nil
+			}
+			cached.expiresAt = time.Now().Add(c.config.fetchErrorTTL.Load())
+		}
+
+		c.entries.Resize(c.config.maxEntries.Load())
+		c.entries.Add(version, cached)
+		return cached, nil
+	})
+	cached = v.(*entry[T])
+	return cached.artifact, cached.err
+}
+
+// Fallback makes the version fall back to the transformer service with err until ttl elapses,
+// e.g. after its artifact exceeded the resources of the embedded transformer. It is loaded again afterwards.
+func (c *Cache[T]) Fallback(version string, err *FallbackError, ttl time.Duration) {
+	c.entries.Add(version, &entry[T]{err: err, expiresAt: time.Now().Add(ttl)})
+}
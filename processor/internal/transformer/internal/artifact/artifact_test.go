@@ -0,0 +1,92 @@
+package artifact_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/internal/artifact"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	fetchErrorTTL := 10 * time.Millisecond
+	c := artifact.NewCache[string](config.SingleValueLoader(time.Second), config.SingleValueLoader(fetchErrorTTL), config.SingleValueLoader(2))
+	var loads int
+	load := func(artifact string, err error) func(context.Context) (string, error) {
+		return func(context.Context) (string, error) {
+			loads++
+			return artifact, err
+		}
+	}
+
+	t.Run("artifacts are loaded once", func(t *testing.T) {
+		for range 2 {
+			a, err := c.Get(ctx, "v1", load("artifact-1", nil))
+			require.NoError(t, err)
+			require.Equal(t, "artifact-1", a)
+		}
+		require.Equal(t, 1, loads)
+	})
+
+	t.Run("fallback errors are cached for good", func(t *testing.T) {
+		loads = 0
+		for range 2 {
+			_, err := c.Get(ctx, "v2", load("", &artifact.FallbackError{Reason: "compile"}))
+			var fe *artifact.FallbackError
+			require.ErrorAs(t, err, &fe)
+			require.Equal(t, "compile", fe.Reason)
+		}
+		require.Equal(t, 1, loads)
+	})
+
+	t.Run("fetch errors expire", func(t *testing.T) {
+		loads = 0
+		_, err := c.Get(ctx, "v3", load("", errors.New("unavailable")))
+		var fe *artifact.FallbackError
+		require.ErrorAs(t, err, &fe)
+		require.Equal(t, artifact.ReasonFetch, fe.Reason)
+		require.ErrorContains(t, err, "unavailable")
+		_, err = c.Get(ctx, "v3", load("artifact-3", nil))
+		require.Error(t, err)
+
+		time.Sleep(fetchErrorTTL)
+		a, err := c.Get(ctx, "v3", load("artifact-3", nil))
+		require.NoError(t, err)
+		require.Equal(t, "artifact-3", a)
+		require.Equal(t, 2, loads)
+	})
+
+	t.Run("fallbacks expire", func(t *testing.T) {
+		loads = 0
+		a, err := c.Get(ctx, "v5", load("artifact-5", nil))
+		require.NoError(t, err)
+		require.Equal(t, "artifact-5", a)
+		c.Fallback("v5", &artifact.FallbackError{Reason: "timeout"}, fetchErrorTTL)
+		_, err = c.Get(ctx, "v5", load("artifact-5", nil))
+		var fe *artifact.FallbackError
+		require.ErrorAs(t, err, &fe)
+		require.Equal(t, "timeout", fe.Reason)
+
+		time.Sleep(fetchErrorTTL)
+		a, err = c.Get(ctx, "v5", load("artifact-5", nil))
+		require.NoError(t, err)
+		require.Equal(t, "artifact-5", a)
+		require.Equal(t, 2, loads)
+	})
+
+	t.Run("least recently used artifacts are evicted", func(t *testing.T) {
+		loads = 0
+		_, _ = c.Get(ctx, "v1", load("artifact-1", nil))
+		_, _ = c.Get(ctx, "v4", load("artifact-4", nil))
+		_, _ = c.Get(ctx, "v1", load("artifact-1", nil))
+		require.Equal(t, 2, loads, "v1 was evicted by v3")
+		_, _ = c.Get(ctx, "v4", load("artifact-4", nil))
+		require.Equal(t, 2, loads)
+	})
+}
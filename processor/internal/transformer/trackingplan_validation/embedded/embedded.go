@@ -0,0 +1,129 @@
+// Package embedded validates events in-process against the JSON Schema rules of their tracking plan,
+// saving the round-trip to the transformer service.
+package embedded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/internal/artifact"
+	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/controlplane"
+)
+
+// Fetcher fetches versions of tracking plans
+type Fetcher interface {
+	TrackingPlanByVersion(ctx context.Context, trackingPlanID string, version int) (controlplane.TrackingPlan, error)
+}
+
+// New creates a new embedded validator, fetching tracking plans with the fetcher
+func New(conf *config.Config, log logger.Logger, stat stats.Stats, fetcher Fetcher) *Validator {
+	v := &Validator{
+		log:     log.Child("embedded"),
+		stat:    stat,
+		fetcher: fetcher,
+	}
+	v.config.enabled = conf.GetReloadableBoolVar(false, "Processor.TrackingPlanValidation.Embedded.enabled")
+	v.rules = artifact.NewCache[*rules](
+		conf.GetReloadableDurationVar(10, time.Second, "Processor.TrackingPlanValidation.Embedded.fetchTimeout"),
+		conf.GetReloadableDurationVar(1, time.Minute, "Processor.TrackingPlanValidation.Embedded.fetchErrorTTL"),
+		conf.GetReloadableIntVar(1000, 1, "Processor.TrackingPlanValidation.Embedded.maxCachedTrackingPlans"),
+	)
+	return v
+}
+
+// Validator validates events against the rules of their tracking plan in-process,
+// producing the same responses and violations as the transformer service.
+type Validator struct {
+	log     logger.Logger
+	stat    stats.Stats
+	fetcher Fetcher
+	config  struct {
+		enabled config.ValueLoader[bool]
+	}
+
+	rules *artifact.Cache[*rules] // by tracking plan id and version
+}
+
+// Validate validates the events against the rules of their tracking plan.
+// Events whose tracking plan cannot be validated in-process are returned as fallback events, which need to be sent to the transformer service.
+func (v *Validator) Validate(ctx context.Context, events []types.TransformerEvent) (responses []types.TransformerResponse, fallback []types.TransformerEvent) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	if !v.config.enabled.Load() {
+		return nil, events
+	}
+	start := time.Now()
+	responses = make([]types.TransformerResponse, 0, len(events))
+	fallbackReasons := make(map[string]int)
+	for i := range events {
+		event := &events[i]
+		if event.Metadata.TrackingPlanID == "" {
+			responses = append(responses, types.TransformerResponse{Output: event.Message, Metadata: event.Metadata, StatusCode: 200})
+			continue
+		}
+		rules, err := v.trackingPlanRules(ctx, event.Metadata.TrackingPlanID, event.Metadata.TrackingPlanVersion)
+		if err != nil {
+			var fe *artifact.FallbackError
+			if errors.As(err, &fe) {
+				fallbackReasons[fe.Reason]++
+			}
+			fallback = append(fallback, *event)
+			continue
+		}
+		responses = append(responses, rules.validate(event))
+	}
+	for reason, count := range fallbackReasons {
+		v.stat.NewTaggedStat("embedded_tp_validation_fallback_events", stats.CountType, stats.Tags{"reason": reason}).Count(count)
+	}
+	v.stat.NewStat("embedded_tp_validation_time", stats.TimerType).Since(start)
+	v.stat.NewStat("embedded_tp_validation_events", stats.CountType).Count(len(responses))
+	return responses, fallback
+}
+
+// trackingPlanRules returns the compiled rules of the tracking plan version, fetching it if it isn't cached
+func (v *Validator) trackingPlanRules(ctx context.Context, trackingPlanID string, version int) (*rules, error) {
+	r, err := v.rules.Get(ctx, trackingPlanID+":"+strconv.Itoa(version), func(ctx context.Context) (*rules, error) {
+		trackingPlan, err := v.fetcher.TrackingPlanByVersion(ctx, trackingPlanID, version)
+		if err != nil {
+			return nil, err
+		}
+		return compile(trackingPlan)
+	})
+	if err != nil {
+		v.log.Debugn("Tracking plan not supported by the embedded validator",
+			logger.NewStringField("trackingPlanID", trackingPlanID),
+			logger.NewIntField("trackingPlanVersion", int64(version)),
+			obskit.Error(err),
+		)
+	}
+	return r, err
+}
+
+// compile compiles the JSON Schema rules of the events planned in the tracking plan
+func compile(trackingPlan controlplane.TrackingPlan) (*rules, error) {
+	r := &rules{events: make(map[string]*gojsonschema.Schema, len(trackingPlan.Rules.Events))}
+	for _, event := range trackingPlan.Rules.Events {
+		var schema *gojsonschema.Schema
+		if len(event.Rules) > 0 && string(event.Rules) != "null" {
+			var err error
+			schema, err = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(event.Rules))
+			if err != nil {
+				return nil, &artifact.FallbackError{Reason: "compile", Err: fmt.Errorf("rules of %s event %q: %w", event.EventType, event.Name, err)}
+			}
+		}
+		r.events[eventKey(event.EventType, event.Name)] = schema
+	}
+	return r, nil
+}
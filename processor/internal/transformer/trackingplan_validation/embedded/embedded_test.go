@@ -0,0 +1,253 @@
+package embedded_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
+
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/trackingplan_validation/embedded"
+	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/controlplane"
+)
+
+type fakeFetcher struct {
+	trackingPlans map[string]controlplane.TrackingPlan
+	err           error
+	calls         atomic.Int64
+}
+
+func (f *fakeFetcher) TrackingPlanByVersion(_ context.Context, trackingPlanID string, version int) (controlplane.TrackingPlan, error) {
+	f.calls.Add(1)
+	if f.err != nil {
+		return controlplane.TrackingPlan{}, f.err
+	}
+	tp, ok := f.trackingPlans[fmt.Sprintf("%s:%d", trackingPlanID, version)]
+	if !ok {
+		return controlplane.TrackingPlan{}, fmt.Errorf("tracking plan %q not found", trackingPlanID)
+	}
+	return tp, nil
+}
+
+const productViewedRules = `{
+	"type": "object",
+	"required": ["properties"],
+	"properties": {
+		"properties": {
+			"type": "object",
+			"required": ["sku"],
+			"additionalProperties": false,
+			"properties": {
+				"sku": {"type": "string"},
+				"price": {"type": "number"}
+			}
+		}
+	}
+}`
+
+func newEvent(message types.SingularEventT, tpConfig map[string]interface{}) types.TransformerEvent {
+	return types.TransformerEvent{
+		Message: message,
+		Metadata: types.Metadata{
+			MessageID:           "message-1",
+			SourceID:            "source-1",
+			TrackingPlanID:      "tp-1",
+			TrackingPlanVersion: 2,
+			MergedTpConfig:      tpConfig,
+		},
+	}
+}
+
+func TestValidator(t *testing.T) {
+	ctx := context.Background()
+	fetcher := &fakeFetcher{trackingPlans: map[string]controlplane.TrackingPlan{
+		"tp-1:2": {ID: "tp-1", Version: 2, Rules: controlplane.TrackingPlanRules{Events: []controlplane.TrackingPlanEvent{
+			{Name: "Product Viewed", EventType: "track", Rules: []byte(productViewedRules)},
+			{Name: "Checkout Started", EventType: "track"},
+			{EventType: "identify", Rules: []byte(`{"type":"object","properties":{"traits":{"type":"object","required":["email"]}}}`)},
+		}}},
+		"tp-invalid:1": {ID: "tp-invalid", Version: 1, Rules: controlplane.TrackingPlanRules{Events: []controlplane.TrackingPlanEvent{
+			{Name: "Invalid", EventType: "track", Rules: []byte(`{"type":"unknown"}`)},
+		}}},
+	}}
+	conf := config.New()
+	conf.Set("Processor.TrackingPlanValidation.Embedded.enabled", true)
+	statsStore, err := memstats.New()
+	require.NoError(t, err)
+	v := embedded.New(conf, logger.NOP, statsStore, fetcher)
+
+	validate := func(t *testing.T, event types.TransformerEvent) types.TransformerResponse {
+		t.Helper()
+		responses, fallback := v.Validate(ctx, []types.TransformerEvent{event})
+		require.Empty(t, fallback)
+		require.Len(t, responses, 1)
+		require.Equal(t, event.Metadata, responses[0].Metadata)
+		require.Equal(t, map[string]interface{}(event.Message), responses[0].Output)
+		return responses[0]
+	}
+
+	t.Run("valid events", func(t *testing.T) {
+		res := validate(t, newEvent(types.SingularEventT{"type": "track", "event": "Product Viewed", "properties": map[string]interface{}{"sku": "sku-1", "price": 10}}, nil))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Empty(t, res.ValidationErrors)
+
+		res = validate(t, newEvent(types.SingularEventT{"type": "track", "event": "Checkout Started"}, nil))
+		require.Equal(t, http.StatusOK, res.StatusCode, "events without rules are valid")
+		require.Empty(t, res.ValidationErrors)
+
+		res = validate(t, newEvent(types.SingularEventT{"type": "identify", "traits": map[string]interface{}{"email": "user@example.com"}}, nil))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Empty(t, res.ValidationErrors)
+
+		res = validate(t, newEvent(types.SingularEventT{"type": "page"}, nil))
+		require.Equal(t, http.StatusOK, res.StatusCode, "unplanned events of types other than track are valid")
+		require.Empty(t, res.ValidationErrors)
+
+		res = validate(t, newEvent(types.SingularEventT{"type": "merge"}, map[string]interface{}{"anyOtherViolation": "drop"}))
+		require.Equal(t, http.StatusOK, res.StatusCode, "unsupported event types aren't validated")
+		require.Empty(t, res.ValidationErrors)
+	})
+
+	t.Run("violations", func(t *testing.T) {
+		res := validate(t, newEvent(types.SingularEventT{"type": "track", "event": "Product Viewed", "properties": map[string]interface{}{"price": "10", "color": "red"}}, nil))
+		require.Equal(t, http.StatusOK, res.StatusCode, "events with violations are forwarded by default")
+		require.ElementsMatch(t, []string{"Required-Missing", "Datatype-Mismatch", "Additional-Properties"}, violationTypes(res.ValidationErrors))
+		for _, violation := range res.ValidationErrors {
+			switch violation.Type {
+			case "Required-Missing":
+				require.Equal(t, "properties.sku", violation.Property)
+				require.Equal(t, "must have required property 'sku'", violation.Message)
+				require.Equal(t, map[string]string{"instancePath": "/properties", "missingProperty": "sku"}, violation.Meta)
+			case "Datatype-Mismatch":
+				require.Equal(t, "properties.price", violation.Property)
+				require.Equal(t, "must be number", violation.Message)
+				require.Equal(t, map[string]string{"instancePath": "/properties/price", "expected": "number", "given": "string"}, violation.Meta)
+			case "Additional-Properties":
+				require.Equal(t, "properties.color", violation.Property)
+				require.Equal(t, "must NOT have additional properties", violation.Message)
+				require.Equal(t, map[string]string{"instancePath": "/properties", "additionalProperty": "color"}, violation.Meta)
+			}
+			require.NotEmpty(t, violation.Message)
+		}
+
+		res = validate(t, newEvent(types.SingularEventT{"type": "identify", "traits": map[string]interface{}{}}, nil))
+		require.Equal(t, []string{"Required-Missing"}, violationTypes(res.ValidationErrors))
+		require.Equal(t, "traits.email", res.ValidationErrors[0].Property)
+
+		res = validate(t, newEvent(types.SingularEventT{"type": "track", "event": "Product Added"}, map[string]interface{}{"allowUnplannedEvents": "true"}))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Empty(t, res.ValidationErrors, "unplanned events are allowed")
+	})
+
+	t.Run("policies", func(t *testing.T) {
+		additionalProperty := types.SingularEventT{"type": "track", "event": "Product Viewed", "properties": map[string]interface{}{"sku": "sku-1", "color": "red"}}
+		missingProperty := types.SingularEventT{"type": "track", "event": "Product Viewed", "properties": map[string]interface{}{}}
+		unplanned := types.SingularEventT{"type": "track", "event": "Product Added"}
+
+		for name, tc := range map[string]struct {
+			message  types.SingularEventT
+			tpConfig map[string]interface{}
+			dropped  bool
+		}{
+			"forwarded unplanned properties":       {message: additionalProperty, tpConfig: map[string]interface{}{"unplannedProperties": "forward", "anyOtherViolation": "drop"}},
+			"dropped unplanned properties":         {message: additionalProperty, tpConfig: map[string]interface{}{"unplannedProperties": "drop"}, dropped: true},
+			"forwarded other violations":           {message: missingProperty, tpConfig: map[string]interface{}{"unplannedProperties": "drop", "anyOtherViolation": "forward"}},
+			"dropped other violations":             {message: missingProperty, tpConfig: map[string]interface{}{"anyOtherViolation": "drop"}, dropped: true},
+			"dropped unplanned events":             {message: unplanned, tpConfig: map[string]interface{}{"allowUnplannedEvents": "false"}, dropped: true},
+			"dropped unplanned events with bool":   {message: unplanned, tpConfig: map[string]interface{}{"allowUnplannedEvents": false}, dropped: true},
+			"allowed unplanned events by default":  {message: unplanned, tpConfig: map[string]interface{}{"anyOtherViolation": "drop"}},
+			"allowed unplanned events with bool":   {message: unplanned, tpConfig: map[string]interface{}{"allowUnplannedEvents": true}},
+			"forwarded violations without config":  {message: missingProperty},
+			"forwarded violations of empty config": {message: missingProperty, tpConfig: map[string]interface{}{}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				res := validate(t, newEvent(tc.message, tc.tpConfig))
+				if !tc.dropped {
+					require.Equal(t, http.StatusOK, res.StatusCode)
+					require.Empty(t, res.Error)
+					return
+				}
+				require.Equal(t, http.StatusBadRequest, res.StatusCode)
+				require.NotEmpty(t, res.ValidationErrors)
+				require.JSONEq(t, fmt.Sprintf(`{%q:[%q]}`, res.ValidationErrors[0].Type, res.ValidationErrors[0].Message), res.Error)
+			})
+		}
+
+		res := validate(t, newEvent(unplanned, map[string]interface{}{"allowUnplannedEvents": "false"}))
+		require.Equal(t, []types.ValidationError{{
+			Type:    "Unplanned-Event",
+			Message: "Track event 'Product Added' is not defined in the Tracking Plan",
+			Meta:    map[string]string{},
+		}}, res.ValidationErrors)
+	})
+
+	t.Run("events without tracking plan are valid", func(t *testing.T) {
+		event := newEvent(types.SingularEventT{"type": "track", "event": "Product Added"}, map[string]interface{}{"allowUnplannedEvents": "false"})
+		event.Metadata.TrackingPlanID = ""
+		res := validate(t, event)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Empty(t, res.ValidationErrors)
+	})
+
+	t.Run("tracking plans are fetched once per version", func(t *testing.T) {
+		calls := fetcher.calls.Load()
+		validate(t, newEvent(types.SingularEventT{"type": "track", "event": "Checkout Started"}, nil))
+		require.Equal(t, calls, fetcher.calls.Load())
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		for name, tp := range map[string]struct {
+			id      string
+			version int
+			reason  string
+		}{
+			"unknown tracking plan": {id: "tp-1", version: 3, reason: "fetch"},
+			"invalid rules":         {id: "tp-invalid", version: 1, reason: "compile"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				event := newEvent(types.SingularEventT{"type": "track", "event": "Invalid"}, nil)
+				event.Metadata.TrackingPlanID, event.Metadata.TrackingPlanVersion = tp.id, tp.version
+				calls := fetcher.calls.Load()
+				for range 2 {
+					_, fallback := v.Validate(ctx, []types.TransformerEvent{event, event})
+					require.Len(t, fallback, 2)
+				}
+				require.Equal(t, calls+1, fetcher.calls.Load(), "errors are cached")
+				require.EqualValues(t, 4, statsStore.Get("embedded_tp_validation_fallback_events", stats.Tags{"reason": tp.reason}).LastValue())
+			})
+		}
+	})
+
+	t.Run("events fall back per tracking plan", func(t *testing.T) {
+		valid := newEvent(types.SingularEventT{"type": "track", "event": "Checkout Started"}, nil)
+		unknown := newEvent(types.SingularEventT{"type": "track", "event": "Checkout Started"}, nil)
+		unknown.Metadata.TrackingPlanID = "tp-unknown"
+		responses, fallback := v.Validate(ctx, []types.TransformerEvent{valid, unknown, valid})
+		require.Len(t, responses, 2)
+		require.Equal(t, []types.TransformerEvent{unknown}, fallback)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		conf := config.New()
+		v := embedded.New(conf, logger.NOP, stats.NOP, &fakeFetcher{err: errors.New("not called")})
+		_, fallback := v.Validate(ctx, []types.TransformerEvent{newEvent(types.SingularEventT{"type": "track"}, nil)})
+		require.Len(t, fallback, 1)
+	})
+}
+
+func violationTypes(violations []types.ValidationError) []string {
+	res := make([]string, 0, len(violations))
+	for _, v := range violations {
+		res = append(res, v.Type)
+	}
+	return res
+}
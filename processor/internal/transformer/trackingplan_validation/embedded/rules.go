@@ -0,0 +1,176 @@
+package embedded
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+
+	"github.com/rudderlabs/rudder-server/processor/types"
+)
+
+// Violation types, as reported by the transformer service
+const (
+	ViolationUnplannedEvent       = "Unplanned-Event"
+	ViolationAdditionalProperties = "Additional-Properties"
+	ViolationRequiredMissing      = "Required-Missing"
+	ViolationDatatypeMismatch     = "Datatype-Mismatch"
+	ViolationUnknown              = "Unknown-Violation"
+)
+
+// supportedEventTypes are the types of events validated against tracking plans, other events are always valid
+var supportedEventTypes = map[string]struct{}{
+	"track":    {},
+	"identify": {},
+	"group":    {},
+	"page":     {},
+	"screen":   {},
+	"alias":    {},
+}
+
+// rules are the compiled rules of a tracking plan version
+type rules struct {
+	events map[string]*gojsonschema.Schema // event key => schema, nil if the event has no rules
+}
+
+// eventKey identifies a planned event: track events by their name, other events by their type
+func eventKey(eventType, eventName string) string {
+	eventType = strings.ToLower(eventType)
+	if eventType == "track" {
+		return eventType + ":" + eventName
+	}
+	return eventType
+}
+
+/*
+validate validates the event against the rules, applying the policies of the tracking plan config of its source, e.g.
+
+	"mergedTpConfig": {
+		"allowUnplannedEvents": "false",
+		"unplannedProperties": "drop",
+		"anyOtherViolation": "forward"
+	}
+
+Events with violations are forwarded with status 200, unless a policy drops them with status 400.
+*/
+func (r *rules) validate(event *types.TransformerEvent) types.TransformerResponse {
+	response := types.TransformerResponse{Output: event.Message, Metadata: event.Metadata, StatusCode: http.StatusOK}
+	eventType, _ := event.Message["type"].(string)
+	eventType = strings.ToLower(eventType)
+	if _, ok := supportedEventTypes[eventType]; !ok {
+		return response
+	}
+	eventName, _ := event.Message["event"].(string)
+	tpConfig := event.Metadata.MergedTpConfig
+
+	var violations []types.ValidationError
+	schema, planned := r.events[eventKey(eventType, eventName)]
+	switch {
+	case !planned:
+		if eventType == "track" && configValue(tpConfig, "allowUnplannedEvents") == "false" {
+			violations = append(violations, types.ValidationError{
+				Type:    ViolationUnplannedEvent,
+				Message: fmt.Sprintf("Track event '%s' is not defined in the Tracking Plan", eventName),
+				Meta:    map[string]string{},
+			})
+		}
+	case schema != nil:
+		result, err := schema.Validate(gojsonschema.NewGoLoader(map[string]interface{}(event.Message)))
+		if err != nil {
+			violations = append(violations, types.ValidationError{
+				Type:    ViolationUnknown,
+				Message: err.Error(),
+				Meta:    map[string]string{},
+			})
+			break
+		}
+		for _, resultError := range result.Errors() {
+			violations = append(violations, violation(resultError))
+		}
+	}
+	if len(violations) == 0 {
+		return response
+	}
+
+	response.ValidationErrors = violations
+	if drop(violations, tpConfig) {
+		response.StatusCode = http.StatusBadRequest
+		messagesByType := make(map[string][]string)
+		for _, v := range violations {
+			messagesByType[v.Type] = append(messagesByType[v.Type], v.Message)
+		}
+		errorMessage, _ := jsonrs.Marshal(messagesByType)
+		response.Error = string(errorMessage)
+	}
+	return response
+}
+
+// drop returns whether an event with the violations is dropped by the policies of the tracking plan config
+func drop(violations []types.ValidationError, tpConfig map[string]interface{}) bool {
+	for _, v := range violations {
+		switch v.Type {
+		case ViolationUnplannedEvent:
+			return true // only reported if unplanned events aren't allowed
+		case ViolationAdditionalProperties:
+			if configValue(tpConfig, "unplannedProperties") == "drop" {
+				return true
+			}
+		default:
+			if configValue(tpConfig, "anyOtherViolation") == "drop" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// configValue returns a value of the tracking plan config as a string, since booleans may be set either way
+func configValue(tpConfig map[string]interface{}, key string) string {
+	value, ok := tpConfig[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// violation converts a JSON Schema validation error to a violation.
+// Messages of the violation types follow the ones of the ajv validator the transformer service uses.
+func violation(resultError gojsonschema.ResultError) types.ValidationError {
+	instancePath := strings.TrimPrefix(resultError.Context().String("/"), gojsonschema.STRING_CONTEXT_ROOT)
+	property := strings.ReplaceAll(strings.TrimPrefix(instancePath, "/"), "/", ".")
+	v := types.ValidationError{
+		Type:     ViolationUnknown,
+		Message:  resultError.Description(),
+		Meta:     map[string]string{"instancePath": instancePath},
+		Property: property,
+	}
+	details := resultError.Details()
+	switch resultError.Type() {
+	case "required":
+		v.Type = ViolationRequiredMissing
+		v.Meta["missingProperty"] = fmt.Sprint(details["property"])
+		v.Property = joinProperty(property, v.Meta["missingProperty"])
+		v.Message = fmt.Sprintf("must have required property '%s'", v.Meta["missingProperty"])
+	case "additional_property_not_allowed":
+		v.Type = ViolationAdditionalProperties
+		v.Meta["additionalProperty"] = fmt.Sprint(details["property"])
+		v.Property = joinProperty(property, v.Meta["additionalProperty"])
+		v.Message = "must NOT have additional properties"
+	case "invalid_type":
+		v.Type = ViolationDatatypeMismatch
+		v.Meta["expected"] = fmt.Sprint(details["expected"])
+		v.Meta["given"] = fmt.Sprint(details["given"])
+		v.Message = "must be " + strings.ReplaceAll(v.Meta["expected"], ", ", ",")
+	}
+	return v
+}
+
+func joinProperty(parent, property string) string {
+	if parent == "" {
+		return property
+	}
+	return parent + "." + property
+}
@@ -24,6 +24,7 @@ import (
 	transformerclient "github.com/rudderlabs/rudder-server/internal/transformer-client"
 	"github.com/rudderlabs/rudder-server/processor/integrations"
 	transformerutils "github.com/rudderlabs/rudder-server/processor/internal/transformer"
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/trackingplan_validation/embedded"
 	"github.com/rudderlabs/rudder-server/processor/types"
 	"github.com/rudderlabs/rudder-server/utils/httputil"
 	reportingtypes "github.com/rudderlabs/rudder-server/utils/types"
@@ -37,6 +38,14 @@ func WithClient(client transformerclient.Client) Opt {
 	}
 }
 
+// WithTrackingPlanFetcher enables the embedded validator, which validates events in-process
+// against the rules of their tracking plan after fetching it with the fetcher
+func WithTrackingPlanFetcher(fetcher embedded.Fetcher) Opt {
+	return func(s *Client) {
+		s.embedded = embedded.New(s.conf, s.log, s.stat, fetcher)
+	}
+}
+
 func New(conf *config.Config, log logger.Logger, stat stats.Stats, opts ...Opt) *Client {
 	handle := &Client{}
 	handle.conf = conf
@@ -66,10 +75,11 @@ type Client struct {
 		timeoutDuration         time.Duration
 		batchSize               config.ValueLoader[int]
 	}
-	conf   *config.Config
-	log    logger.Logger
-	stat   stats.Stats
-	client transformerclient.Client
+	conf     *config.Config
+	log      logger.Logger
+	stat     stats.Stats
+	client   transformerclient.Client
+	embedded *embedded.Validator // optional
 }
 
 func (t *Client) Validate(ctx context.Context, clientEvents []types.TransformerEvent) types.Response {
@@ -116,7 +126,7 @@ func (t *Client) Validate(ctx context.Context, clientEvents []types.TransformerE
 		batches,
 		func(batch []types.TransformerEvent, i int) {
 			go func() {
-				transformResponse[i] = t.sendBatch(ctx, t.trackingPlanValidationURL(), labels, batch)
+				transformResponse[i] = t.validateBatch(ctx, t.trackingPlanValidationURL(), labels, batch)
 				wg.Done()
 			}()
 		},
@@ -148,6 +158,23 @@ func (t *Client) Validate(ctx context.Context, clientEvents []types.TransformerE
 	}
 }
 
+// validateBatch validates the events of a batch with the embedded validator if it can,
+// sending the rest of them to the transformer service
+func (t *Client) validateBatch(ctx context.Context, url string, labels types.TransformerMetricLabels, clientEvents []types.TransformerEvent) []types.TransformerResponse {
+	if t.embedded == nil || len(clientEvents) == 0 {
+		return t.sendBatch(ctx, url, labels, clientEvents)
+	}
+	responses, fallback := t.embedded.Validate(ctx, clientEvents)
+	if len(fallback) == 0 {
+		return responses
+	}
+	t.log.Debugn("Sending tracking plan validation to the transformer service",
+		logger.NewIntField("events", int64(len(fallback))),
+		obskit.SourceID(labels.SourceID),
+	)
+	return append(responses, t.sendBatch(ctx, url, labels, fallback)...)
+}
+
 func (t *Client) sendBatch(ctx context.Context, url string, labels types.TransformerMetricLabels, clientEvents []types.TransformerEvent) []types.TransformerResponse {
 	data := lo.Map(clientEvents, func(clientEvent types.TransformerEvent, _ int) types.TrackingPlanValidationEvent {
 		return *clientEvent.ToTrackingPlanValidationEvent()
@@ -14,6 +14,8 @@ import (
 
 	"github.com/rudderlabs/rudder-go-kit/jsonrs"
 
+	"github.com/ory/dockertest/v3"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
@@ -22,6 +24,7 @@ import (
 	"github.com/rudderlabs/rudder-go-kit/logger/mock_logger"
 	"github.com/rudderlabs/rudder-go-kit/stats"
 	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
+	transformertest "github.com/rudderlabs/rudder-go-kit/testhelper/docker/resource/transformer"
 	"github.com/rudderlabs/rudder-go-kit/testhelper/rand"
 
 	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
@@ -29,6 +32,7 @@ import (
 	transformerutils "github.com/rudderlabs/rudder-server/processor/internal/transformer"
 	"github.com/rudderlabs/rudder-server/processor/internal/transformer/trackingplan_validation"
 	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/controlplane"
 	"github.com/rudderlabs/rudder-server/testhelper/backendconfigtest"
 	reportingtypes "github.com/rudderlabs/rudder-server/utils/types"
 )
@@ -631,6 +635,143 @@ func TestTrackingPlanValidator(t *testing.T) {
 	}
 }
 
+type fakeFetcher map[string]controlplane.TrackingPlan
+
+func (f fakeFetcher) TrackingPlanByVersion(_ context.Context, trackingPlanID string, _ int) (controlplane.TrackingPlan, error) {
+	trackingPlan, ok := f[trackingPlanID]
+	if !ok {
+		return controlplane.TrackingPlan{}, fmt.Errorf("tracking plan %q not found", trackingPlanID)
+	}
+	return trackingPlan, nil
+}
+
+func TestEmbeddedTrackingPlanValidator(t *testing.T) {
+	ft := &fakeTransformer{t: t}
+	srv := httptest.NewServer(ft)
+	defer srv.Close()
+
+	conf := config.New()
+	conf.Set("DEST_TRANSFORM_URL", srv.URL)
+	conf.Set("Processor.TrackingPlanValidation.Embedded.enabled", true)
+	tr := trackingplan_validation.New(conf, logger.NOP, stats.NOP, trackingplan_validation.WithClient(srv.Client()), trackingplan_validation.WithTrackingPlanFetcher(fakeFetcher{
+		"tp-1": {ID: "tp-1", Version: 1, Rules: controlplane.TrackingPlanRules{Events: []controlplane.TrackingPlanEvent{
+			{Name: "Product Viewed", EventType: "track", Rules: []byte(`{"type":"object","required":["properties"],"properties":{"properties":{"type":"object","required":["sku"]}}}`)},
+		}}},
+	}))
+
+	newEvent := func(trackingPlanID string) types.TransformerEvent {
+		return types.TransformerEvent{
+			Metadata: types.Metadata{MessageID: "message-1", SourceID: "source-1", TrackingPlanID: trackingPlanID, TrackingPlanVersion: 1},
+			Message:  map[string]interface{}{"type": "track", "event": "Product Viewed", "properties": map[string]interface{}{}, "src-key-1": "value", "forceStatusCode": http.StatusOK},
+		}
+	}
+
+	t.Run("events are validated in-process", func(t *testing.T) {
+		res := tr.Validate(context.Background(), []types.TransformerEvent{newEvent("tp-1")})
+		require.Empty(t, ft.requests)
+		require.Empty(t, res.FailedEvents)
+		require.Len(t, res.Events, 1)
+		require.Len(t, res.Events[0].ValidationErrors, 1)
+		require.Equal(t, "Required-Missing", res.Events[0].ValidationErrors[0].Type)
+		require.Equal(t, "properties.sku", res.Events[0].ValidationErrors[0].Property)
+	})
+
+	t.Run("events of tracking plans which cannot be fetched are sent to the transformer", func(t *testing.T) {
+		res := tr.Validate(context.Background(), []types.TransformerEvent{newEvent("tp-2")})
+		require.Len(t, ft.requests, 1)
+		require.Len(t, res.Events, 1)
+		require.Equal(t, "value", res.Events[0].Output["echo-key-1"])
+	})
+
+	t.Run("only events of tracking plans which cannot be fetched are sent to the transformer", func(t *testing.T) {
+		requests := len(ft.requests)
+		res := tr.Validate(context.Background(), []types.TransformerEvent{newEvent("tp-1"), newEvent("tp-2"), newEvent("tp-1")})
+		require.Len(t, ft.requests, requests+1)
+		require.Len(t, ft.requests[requests], 1)
+		require.Equal(t, "tp-2", ft.requests[requests][0].Metadata.TrackingPlanID)
+		require.Len(t, res.Events, 3)
+	})
+}
+
+func TestEmbeddedTrackingPlanValidatorParity(t *testing.T) {
+	trackingPlan := controlplane.TrackingPlan{ID: "tp-1", Version: 1, Rules: controlplane.TrackingPlanRules{Events: []controlplane.TrackingPlanEvent{
+		{Name: "Product Viewed", EventType: "track", Rules: []byte(`{
+			"type": "object",
+			"required": ["properties"],
+			"properties": {
+				"properties": {
+					"type": "object",
+					"required": ["sku"],
+					"additionalProperties": false,
+					"properties": {"sku": {"type": "string"}, "price": {"type": "number"}}
+				}
+			}
+		}`)},
+		{EventType: "identify", Rules: []byte(`{"type":"object","properties":{"traits":{"type":"object","required":["email"]}}}`)},
+	}}}
+	configBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/workspaces/trackingplans/"+trackingPlan.ID {
+			http.NotFound(w, r)
+			return
+		}
+		require.NoError(t, jsonrs.NewEncoder(w).Encode(trackingPlan))
+	}))
+	defer configBackend.Close()
+
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+	transformerResource, err := transformertest.Setup(pool, t,
+		transformertest.WithConnectionToHostEnabled(),
+		transformertest.WithConfigBackendURL(configBackend.URL),
+	)
+	require.NoError(t, err)
+
+	tpConfig := map[string]interface{}{"allowUnplannedEvents": "false", "unplannedProperties": "forward", "anyOtherViolation": "forward"}
+	events := lo.Map([]types.SingularEventT{
+		{"type": "track", "event": "Product Viewed", "properties": map[string]interface{}{"sku": "sku-1", "price": 10}},
+		{"type": "track", "event": "Product Viewed", "properties": map[string]interface{}{"price": "10", "color": "red"}},
+		{"type": "track", "event": "Product Viewed"},
+		{"type": "track", "event": "Product Added"},
+		{"type": "identify", "traits": map[string]interface{}{}},
+		{"type": "page", "name": "Home"},
+	}, func(message types.SingularEventT, i int) types.TransformerEvent {
+		return types.TransformerEvent{
+			Message: message,
+			Metadata: types.Metadata{
+				MessageID:           "message-" + strconv.Itoa(i),
+				JobID:               int64(i + 1),
+				SourceID:            "source-1",
+				WorkspaceID:         "workspace-1",
+				TrackingPlanID:      trackingPlan.ID,
+				TrackingPlanVersion: trackingPlan.Version,
+				MergedTpConfig:      tpConfig,
+			},
+		}
+	})
+
+	conf := config.New()
+	conf.Set("DEST_TRANSFORM_URL", transformerResource.TransformerURL)
+	legacyResponse := trackingplan_validation.New(conf, logger.NOP, stats.NOP).Validate(context.Background(), events)
+
+	conf.Set("Processor.TrackingPlanValidation.Embedded.enabled", true)
+	embeddedResponse := trackingplan_validation.New(conf, logger.NOP, stats.NOP, trackingplan_validation.WithTrackingPlanFetcher(fakeFetcher{trackingPlan.ID: trackingPlan})).Validate(context.Background(), events)
+
+	// violations are compared regardless of their order and of their meta, which holds details specific to the validator
+	violations := func(response types.Response) map[int64][]string {
+		res := make(map[int64][]string)
+		for _, r := range append(response.Events, response.FailedEvents...) {
+			res[r.Metadata.JobID] = append(res[r.Metadata.JobID], fmt.Sprintf("status:%d", r.StatusCode))
+			for _, v := range r.ValidationErrors {
+				res[r.Metadata.JobID] = append(res[r.Metadata.JobID], fmt.Sprintf("%s:%s:%s", v.Type, v.Property, v.Message))
+			}
+			slices.Sort(res[r.Metadata.JobID])
+		}
+		return res
+	}
+	require.Len(t, legacyResponse.Events, len(events)-1)
+	require.Equal(t, violations(legacyResponse), violations(embeddedResponse))
+}
+
 func TestLongRunningTransformation(t *testing.T) {
 	fileName := t.TempDir() + "out.log"
 	f, err := os.Create(fileName)
@@ -0,0 +1,136 @@
+// Package embedded runs user transformations in-process, in a sandboxed javascript engine,
+// saving the round-trip to the transformer service for the transformations it supports.
+package embedded
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/rudderlabs/rudder-go-kit/bytesize"
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/internal/artifact"
+	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/controlplane"
+)
+
+// Fetcher fetches versions of user transformations
+type Fetcher interface {
+	TransformationByVersionID(ctx context.Context, versionID string) (controlplane.Transformation, error)
+}
+
+// New creates a new embedded runtime for user transformations, fetching their code with the fetcher
+func New(conf *config.Config, log logger.Logger, stat stats.Stats, fetcher Fetcher) *Runtime {
+	r := &Runtime{
+		log:     log.Child("embedded"),
+		stat:    stat,
+		fetcher: fetcher,
+	}
+	r.config.enabled = conf.GetReloadableBoolVar(false, "Processor.UserTransformer.Embedded.enabled")
+	r.config.timeout = conf.GetReloadableDurationVar(10, time.Second, "Processor.UserTransformer.Embedded.timeout")
+	r.config.maxCallStackSize = conf.GetReloadableIntVar(1000, 1, "Processor.UserTransformer.Embedded.maxCallStackSize")
+	r.config.maxHeapGrowth = conf.GetReloadableInt64Var(256, bytesize.MB, "Processor.UserTransformer.Embedded.maxHeapGrowthInMB")
+	r.config.interruptionTTL = conf.GetReloadableDurationVar(10, time.Minute, "Processor.UserTransformer.Embedded.interruptionTTL")
+	r.programs = artifact.NewCache[*goja.Program](
+		conf.GetReloadableDurationVar(10, time.Second, "Processor.UserTransformer.Embedded.fetchTimeout"),
+		conf.GetReloadableDurationVar(1, time.Minute, "Processor.UserTransformer.Embedded.fetchErrorTTL"),
+		conf.GetReloadableIntVar(1000, 1, "Processor.UserTransformer.Embedded.maxCachedTransformations"),
+	)
+	return r
+}
+
+// Runtime runs user transformations in-process. It is experimental and disabled by default.
+//
+// Only javascript transformations defining transformEvent or transformBatch are supported,
+// as long as they use neither libraries nor any of the functions the transformer service provides to them, e.g. fetch.
+// Every batch runs in a new javascript runtime, whose execution time is bounded by Processor.UserTransformer.Embedded.timeout
+// and call stack size by Processor.UserTransformer.Embedded.maxCallStackSize.
+// The engine cannot account for the memory of a runtime, so the runtime is interrupted once the heap of the whole process
+// grows by more than Processor.UserTransformer.Embedded.maxHeapGrowthInMB while it runs: a safeguard against transformations
+// allocating without bounds, which concurrent allocations can trip as well, rather than an accurate limit.
+// Interrupted batches are sent to the transformer service, and so are the following batches of a transformation
+// which timed out or grew the heap too much, for Processor.UserTransformer.Embedded.interruptionTTL.
+// Compiled transformations are cached, evicting the least recently used ones beyond Processor.UserTransformer.Embedded.maxCachedTransformations.
+type Runtime struct {
+	log     logger.Logger
+	stat    stats.Stats
+	fetcher Fetcher
+	config  struct {
+		enabled          config.ValueLoader[bool]
+		timeout          config.ValueLoader[time.Duration]
+		maxCallStackSize config.ValueLoader[int]
+		maxHeapGrowth    config.ValueLoader[int64]
+		interruptionTTL  config.ValueLoader[time.Duration]
+	}
+
+	programs *artifact.Cache[*goja.Program] // by transformation version id
+}
+
+// Transform runs the user transformation of the events, which need to share the same transformation version.
+// If an error is returned, the transformation cannot run in the embedded runtime and the events need to be sent to the transformer service.
+func (r *Runtime) Transform(ctx context.Context, events []types.UserTransformerEvent) ([]types.TransformerResponse, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	if !r.config.enabled.Load() {
+		return nil, &artifact.FallbackError{Reason: "disabled"}
+	}
+	program, err := r.program(ctx, events[0])
+	if err != nil {
+		r.countFallback(err, len(events))
+		return nil, err
+	}
+	start := time.Now()
+	responses, err := r.run(ctx, program, events)
+	r.stat.NewStat("embedded_user_transform_time", stats.TimerType).Since(start)
+	if err != nil {
+		var fe *artifact.FallbackError
+		if errors.As(err, &fe) && fe.Reason != reasonCancelled { // not wasting the resources of the runtime on the next batches
+			r.programs.Fallback(events[0].Destination.Transformations[0].VersionID, fe, r.config.interruptionTTL.Load())
+		}
+		r.countFallback(err, len(events))
+		return nil, err
+	}
+	r.stat.NewStat("embedded_user_transform_events", stats.CountType).Count(len(events))
+	return responses, nil
+}
+
+// countFallback counts the events sent to the transformer service because of the error, by reason
+func (r *Runtime) countFallback(err error, events int) {
+	var fe *artifact.FallbackError
+	if errors.As(err, &fe) {
+		r.stat.NewTaggedStat("embedded_user_transform_fallback_events", stats.CountType, stats.Tags{"reason": fe.Reason}).Count(events)
+	}
+}
+
+// program returns the compiled program of the transformation of the event, fetching it if it isn't cached
+func (r *Runtime) program(ctx context.Context, event types.UserTransformerEvent) (*goja.Program, error) {
+	if len(event.Destination.Transformations) == 0 {
+		return nil, &artifact.FallbackError{Reason: "noTransformation"}
+	}
+	if len(event.Libraries) > 0 {
+		return nil, &artifact.FallbackError{Reason: "libraries"}
+	}
+	versionID := event.Destination.Transformations[0].VersionID
+
+	program, err := r.programs.Get(ctx, versionID, func(ctx context.Context) (*goja.Program, error) {
+		transformation, err := r.fetcher.TransformationByVersionID(ctx, versionID)
+		if err != nil {
+			return nil, err
+		}
+		return compile(transformation)
+	})
+	if err != nil {
+		r.log.Debugn("Transformation not supported by the embedded runtime",
+			logger.NewStringField("transformationVersionID", versionID),
+			obskit.Error(err),
+		)
+	}
+	return program, err
+}
@@ -0,0 +1,318 @@
+package embedded_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/internal/artifact"
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/user_transformer/embedded"
+	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/controlplane"
+)
+
+type fakeFetcher struct {
+	transformations map[string]controlplane.Transformation
+	err             error
+	calls           atomic.Int64
+}
+
+func (f *fakeFetcher) TransformationByVersionID(_ context.Context, versionID string) (controlplane.Transformation, error) {
+	f.calls.Add(1)
+	if f.err != nil {
+		return controlplane.Transformation{}, f.err
+	}
+	t, ok := f.transformations[versionID]
+	if !ok {
+		return controlplane.Transformation{}, fmt.Errorf("transformation %q not found", versionID)
+	}
+	return t, nil
+}
+
+func newEvents(versionID string, n int) []types.UserTransformerEvent {
+	events := make([]types.UserTransformerEvent, n)
+	for i := range events {
+		events[i].Message = types.SingularEventT{"messageId": fmt.Sprintf("message-%d", i), "event": fmt.Sprintf("event-%d", i), "index": i}
+		events[i].Metadata = types.Metadata{MessageID: fmt.Sprintf("message-%d", i), JobID: int64(i + 1), SourceID: "source-1"}
+		events[i].Destination.Transformations = []struct{ VersionID string }{{VersionID: versionID}}
+	}
+	return events
+}
+
+func TestRuntime(t *testing.T) {
+	ctx := context.Background()
+	javascript := func(code string) controlplane.Transformation {
+		return controlplane.Transformation{Code: code, CodeVersion: "1", Language: "javascript"}
+	}
+	fetcher := &fakeFetcher{transformations: map[string]controlplane.Transformation{
+		"enrich": javascript(`
+			export function transformEvent(event, metadata) {
+				event.sourceId = metadata(event).sourceId;
+				return event;
+			}`),
+		"filter": javascript(`
+			export function transformEvent(event) {
+				if (event.index % 2 === 0) {
+					return null;
+				}
+				return event;
+			}`),
+		"split": javascript(`
+			export function transformEvent(event) {
+				return [event, { ...event, event: event.event + "-copy" }];
+			}`),
+		"throw": javascript(`
+			export function transformEvent(event) {
+				if (event.index === 1) {
+					throw new Error("invalid event");
+				}
+				return event;
+			}`),
+		"async": javascript(`
+			export async function transformEvent(event) {
+				const suffix = await Promise.resolve("-async");
+				return { ...event, event: event.event + suffix };
+			}`),
+		"batch": javascript(`
+			export function transformBatch(events, metadata) {
+				return events.filter((event) => metadata(event).jobId > 1).map((event) => ({ ...event, batched: true }));
+			}`),
+		"loop": javascript(`
+			export function transformEvent(event) {
+				while (true) {}
+			}`),
+		"spin": javascript(`
+			export function transformEvent(event) {
+				while (true) {}
+			}`),
+		"heap": javascript(`
+			export function transformEvent(event) {
+				const chunks = [];
+				while (true) {
+					chunks.push(new Array(100000).fill(event.index));
+				}
+			}`),
+		"recursion": javascript(`
+			function f(n) { return f(n + 1); }
+			export function transformEvent(event) {
+				return f(0);
+			}`),
+		"python": {Code: "def transformEvent(event, metadata):\n    return event", CodeVersion: "1", Language: "pythonfaas"},
+		"legacy": {Code: "function transform(events) { return events; }", CodeVersion: "0", Language: "javascript"},
+		"imports": javascript(`
+			import { hash } from "library";
+			export function transformEvent(event) { return event; }`),
+		"fetch": javascript(`
+			export async function transformEvent(event) {
+				event.remote = await fetch("https://example.com");
+				return event;
+			}`),
+		"syntax": javascript(`export function transformEvent(event) {`),
+		"exportsInStrings": javascript(`
+			// import { hash } from "library";
+			export function transformEvent(event) {
+				event.event = ` + "`" + `
+export default ${"import"}` + "`" + `.trim();
+				/*
+				export function ignored() {}
+				*/
+				event.pattern = /"export /.source + 'import';
+				return event;
+			}`),
+		"identity": javascript(`export function transformEvent(event) { return event; }`),
+	}}
+	c := config.New()
+	c.Set("Processor.UserTransformer.Embedded.enabled", true)
+	c.Set("Processor.UserTransformer.Embedded.timeout", 200*time.Millisecond)
+	statsStore, err := memstats.New()
+	require.NoError(t, err)
+	rt := embedded.New(c, logger.NOP, statsStore, fetcher)
+
+	outputs := func(responses []types.TransformerResponse) []string {
+		var events []string
+		for _, r := range responses {
+			require.Equal(t, http.StatusOK, r.StatusCode)
+			events = append(events, fmt.Sprintf("%v:%d", r.Output["event"], r.Metadata.JobID))
+		}
+		return events
+	}
+
+	t.Run("transformEvent", func(t *testing.T) {
+		responses, err := rt.Transform(ctx, newEvents("enrich", 2))
+		require.NoError(t, err)
+		require.Len(t, responses, 2)
+		require.Equal(t, map[string]any{"messageId": "message-0", "event": "event-0", "index": float64(0), "sourceId": "source-1"}, responses[0].Output)
+		require.Equal(t, types.Metadata{MessageID: "message-0", JobID: 1, SourceID: "source-1"}, responses[0].Metadata)
+		require.Equal(t, http.StatusOK, responses[0].StatusCode)
+	})
+
+	t.Run("filtered events", func(t *testing.T) {
+		responses, err := rt.Transform(ctx, newEvents("filter", 4))
+		require.NoError(t, err)
+		require.Equal(t, []string{"event-1:2", "event-3:4"}, outputs(responses))
+	})
+
+	t.Run("multiple events", func(t *testing.T) {
+		responses, err := rt.Transform(ctx, newEvents("split", 1))
+		require.NoError(t, err)
+		require.Equal(t, []string{"event-0:1", "event-0-copy:1"}, outputs(responses))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		responses, err := rt.Transform(ctx, newEvents("throw", 3))
+		require.NoError(t, err)
+		require.Len(t, responses, 3)
+		require.Equal(t, http.StatusBadRequest, responses[1].StatusCode)
+		require.Equal(t, "invalid event", responses[1].Error)
+		require.EqualValues(t, 2, responses[1].Metadata.JobID)
+		require.Equal(t, []string{"event-0:1", "event-2:3"}, outputs([]types.TransformerResponse{responses[0], responses[2]}))
+	})
+
+	t.Run("async", func(t *testing.T) {
+		responses, err := rt.Transform(ctx, newEvents("async", 1))
+		require.NoError(t, err)
+		require.Equal(t, []string{"event-0-async:1"}, outputs(responses))
+	})
+
+	t.Run("transformBatch", func(t *testing.T) {
+		responses, err := rt.Transform(ctx, newEvents("batch", 3))
+		require.NoError(t, err)
+		require.Equal(t, []string{"event-1:2", "event-2:3"}, outputs(responses))
+		require.Equal(t, true, responses[0].Output["batched"])
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		start := time.Now()
+		_, err := rt.Transform(ctx, newEvents("loop", 2))
+		require.Less(t, time.Since(start), 5*time.Second)
+		var fe *artifact.FallbackError
+		require.ErrorAs(t, err, &fe, "timed out batches are sent to the transformer service")
+		require.Equal(t, "timeout", fe.Reason)
+		require.ErrorContains(t, err, "transformation timed out after 200ms")
+		require.EqualValues(t, 2, statsStore.Get("embedded_user_transform_fallback_events", stats.Tags{"reason": "timeout"}).LastValue())
+
+		start = time.Now()
+		calls := fetcher.calls.Load()
+		_, err = rt.Transform(ctx, newEvents("loop", 1))
+		require.Less(t, time.Since(start), 100*time.Millisecond, "the next batches of a timed out transformation fall back without running")
+		require.ErrorAs(t, err, &fe)
+		require.Equal(t, "timeout", fe.Reason)
+		require.Equal(t, calls, fetcher.calls.Load())
+
+		responses, err := rt.Transform(ctx, newEvents("recursion", 1))
+		require.NoError(t, err)
+		require.Len(t, responses, 1)
+		require.Equal(t, http.StatusBadRequest, responses[0].StatusCode)
+	})
+
+	t.Run("module syntax within strings and comments", func(t *testing.T) {
+		responses, err := rt.Transform(ctx, newEvents("exportsInStrings", 1))
+		require.NoError(t, err)
+		require.Equal(t, []string{"export default import:1"}, outputs(responses))
+		require.Equal(t, `"export import`, responses[0].Output["pattern"])
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		c.Set("Processor.UserTransformer.Embedded.timeout", 10*time.Second)
+		defer c.Set("Processor.UserTransformer.Embedded.timeout", 200*time.Millisecond)
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err := rt.Transform(ctx, newEvents("spin", 1))
+		var fe *artifact.FallbackError
+		require.ErrorAs(t, err, &fe)
+		require.Equal(t, "cancelled", fe.Reason)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		c.Set("Processor.UserTransformer.Embedded.timeout", 200*time.Millisecond)
+		responses, err := rt.Transform(context.Background(), newEvents("spin", 1))
+		require.ErrorAs(t, err, &fe, "cancellations are not cached")
+		require.Equal(t, "timeout", fe.Reason)
+		require.Nil(t, responses)
+	})
+
+	t.Run("heap growth", func(t *testing.T) {
+		c.Set("Processor.UserTransformer.Embedded.maxHeapGrowthInMB", 1)
+		c.Set("Processor.UserTransformer.Embedded.timeout", 10*time.Second)
+		defer func() {
+			c.Set("Processor.UserTransformer.Embedded.maxHeapGrowthInMB", 256)
+			c.Set("Processor.UserTransformer.Embedded.timeout", 200*time.Millisecond)
+		}()
+		start := time.Now()
+		_, err := rt.Transform(ctx, newEvents("heap", 1))
+		require.Less(t, time.Since(start), 5*time.Second)
+		var fe *artifact.FallbackError
+		require.ErrorAs(t, err, &fe, "batches growing the heap too much are sent to the transformer service")
+		require.Equal(t, "heap", fe.Reason)
+		require.ErrorContains(t, err, "transformation exceeded the heap growth limit of 1048576 bytes")
+	})
+
+	t.Run("unsupported transformations", func(t *testing.T) {
+		for _, versionID := range []string{"python", "legacy", "imports", "fetch", "syntax", "unknown"} {
+			_, err := rt.Transform(ctx, newEvents(versionID, 1))
+			require.Error(t, err, versionID)
+		}
+		events := newEvents("enrich", 1)
+		events[0].Libraries = []backendconfig.LibraryT{{VersionID: "library-1"}}
+		_, err := rt.Transform(ctx, events)
+		require.Error(t, err)
+		require.EqualValues(t, 1, statsStore.Get("embedded_user_transform_fallback_events", stats.Tags{"reason": "libraries"}).LastValue())
+		require.EqualValues(t, 1, statsStore.Get("embedded_user_transform_fallback_events", stats.Tags{"reason": "unsupportedFunction"}).LastValue())
+	})
+
+	t.Run("transformations are fetched once", func(t *testing.T) {
+		calls := fetcher.calls.Load()
+		for range 3 {
+			_, err := rt.Transform(ctx, newEvents("enrich", 1))
+			require.NoError(t, err)
+			_, err = rt.Transform(ctx, newEvents("python", 1))
+			require.Error(t, err)
+		}
+		require.Equal(t, calls, fetcher.calls.Load())
+	})
+
+	t.Run("least recently used transformations are evicted", func(t *testing.T) {
+		c.Set("Processor.UserTransformer.Embedded.maxCachedTransformations", 2)
+		defer c.Set("Processor.UserTransformer.Embedded.maxCachedTransformations", 1000)
+		for _, versionID := range []string{"enrich", "filter", "enrich", "identity"} {
+			_, err := rt.Transform(ctx, newEvents(versionID, 1))
+			require.NoError(t, err)
+		}
+		calls := fetcher.calls.Load()
+		_, err := rt.Transform(ctx, newEvents("enrich", 1))
+		require.NoError(t, err)
+		require.Equal(t, calls, fetcher.calls.Load(), "recently used transformations are kept")
+		_, err = rt.Transform(ctx, newEvents("filter", 1))
+		require.NoError(t, err)
+		require.Equal(t, calls+1, fetcher.calls.Load(), "least recently used transformations are fetched again")
+	})
+
+	t.Run("fetch errors", func(t *testing.T) {
+		fetcher.err = errors.New("control plane unavailable")
+		defer func() { fetcher.err = nil }()
+		_, err := rt.Transform(ctx, newEvents("new", 1))
+		require.ErrorContains(t, err, "control plane unavailable")
+		calls := fetcher.calls.Load()
+		_, err = rt.Transform(ctx, newEvents("new", 1))
+		require.Error(t, err)
+		require.Equal(t, calls, fetcher.calls.Load(), "fetch errors are cached")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c.Set("Processor.UserTransformer.Embedded.enabled", false)
+		defer c.Set("Processor.UserTransformer.Embedded.enabled", true)
+		_, err := rt.Transform(ctx, newEvents("enrich", 1))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,324 @@
+package embedded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/internal/artifact"
+	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/controlplane"
+)
+
+const (
+	languageJavaScript = "javascript"
+	codeVersionV1      = "1" // transformations exporting transformEvent(event, metadata) or transformBatch(events, metadata)
+
+	// reasons of the fallbacks of interrupted runtimes
+	reasonTimeout   = "timeout"
+	reasonHeap      = "heap"
+	reasonCancelled = "cancelled"
+
+	heapCheckInterval = 5 * time.Millisecond
+)
+
+// functions which the transformer service provides to user transformations, but the embedded runtime doesn't
+var unsupportedFunctionsRegex = regexp.MustCompile(`\b(fetch|fetchV2|geolocation|getCredential)\s*\(`)
+
+// compile compiles the code of a transformation, if the embedded runtime supports it
+func compile(t controlplane.Transformation) (*goja.Program, error) {
+	// modules aren't supported by the engine, exported functions are declared as plain functions instead
+	code, imports := stripModuleSyntax(t.Code)
+	switch {
+	case t.Language != languageJavaScript:
+		return nil, &artifact.FallbackError{Reason: "language", Err: fmt.Errorf("unsupported language %q", t.Language)}
+	case t.CodeVersion != codeVersionV1:
+		return nil, &artifact.FallbackError{Reason: "codeVersion", Err: fmt.Errorf("unsupported code version %q", t.CodeVersion)}
+	case imports:
+		return nil, &artifact.FallbackError{Reason: "imports"}
+	case unsupportedFunctionsRegex.MatchString(t.Code):
+		return nil, &artifact.FallbackError{Reason: "unsupportedFunction", Err: fmt.Errorf("calls %s", unsupportedFunctionsRegex.FindStringSubmatch(t.Code)[1])}
+	}
+	program, err := goja.Compile(t.VersionID, code, false)
+	if err != nil {
+		return nil, &artifact.FallbackError{Reason: "compile", Err: err}
+	}
+	return program, nil
+}
+
+// run runs the program on the events, in a new javascript runtime.
+// Events whose transformation fails get a response with a [http.StatusBadRequest] status code, events which are filtered out get no response.
+// If the runtime gets interrupted, because the transformation timed out, grew the heap too much or the context got cancelled,
+// a [artifact.FallbackError] is returned instead, so that the batch is retried by the transformer service.
+func (r *Runtime) run(ctx context.Context, program *goja.Program, events []types.UserTransformerEvent) ([]types.TransformerResponse, error) {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(r.config.maxCallStackSize.Load())
+	var interruption atomic.Pointer[artifact.FallbackError] // the first reason the runtime was interrupted for
+	interrupt := func(reason string, err error) {
+		interruption.CompareAndSwap(nil, &artifact.FallbackError{Reason: reason, Err: err})
+		vm.Interrupt(err)
+	}
+	timeout := r.config.timeout.Load()
+	stopTimer := time.AfterFunc(timeout, func() { interrupt(reasonTimeout, fmt.Errorf("transformation timed out after %s", timeout)) }).Stop
+	defer stopTimer()
+	stopCtx := context.AfterFunc(ctx, func() { interrupt(reasonCancelled, ctx.Err()) })
+	defer stopCtx()
+	stopWatchdog := watchHeap(r.config.maxHeapGrowth.Load(), func(err error) { interrupt(reasonHeap, err) })
+	defer stopWatchdog()
+
+	responses := transform(vm, program, events)
+	if fe := interruption.Load(); fe != nil {
+		return nil, fe
+	}
+	return responses, nil
+}
+
+// watchHeap calls interrupt if the heap grows by more than maxGrowth bytes, until stopped.
+// The heap is shared by the whole process, so concurrent allocations count towards the growth too:
+// this only guards the process against transformations allocating without bounds.
+func watchHeap(maxGrowth int64, interrupt func(error)) (stop func()) {
+	if maxGrowth <= 0 {
+		return func() {}
+	}
+	start := heapBytes()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heapCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if heapBytes()-start > maxGrowth {
+					interrupt(fmt.Errorf("transformation exceeded the heap growth limit of %d bytes", maxGrowth))
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// heapBytes returns the bytes occupied by the objects of the heap, live or not yet collected
+func heapBytes() int64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return int64(sample[0].Value.Uint64())
+}
+
+// transform runs the program on the events in the runtime.
+// Its responses are meaningless if the runtime gets interrupted.
+func transform(vm *goja.Runtime, program *goja.Program, events []types.UserTransformerEvent) []types.TransformerResponse {
+	failed := func(metadata types.Metadata, err error) types.TransformerResponse {
+		return types.TransformerResponse{Metadata: metadata, StatusCode: http.StatusBadRequest, Error: errorMessage(err)}
+	}
+	failAll := func(events []types.UserTransformerEvent, err error) []types.TransformerResponse {
+		responses := make([]types.TransformerResponse, len(events))
+		for i := range events {
+			responses[i] = failed(events[i].Metadata, err)
+		}
+		return responses
+	}
+
+	// grabbing JSON functions before user code gets a chance to override them
+	jsonObj := vm.Get("JSON").ToObject(vm)
+	jsonParse, _ := goja.AssertFunction(jsonObj.Get("parse"))
+	jsonStringify, _ := goja.AssertFunction(jsonObj.Get("stringify"))
+	toJS := func(v any) (goja.Value, error) {
+		data, err := jsonrs.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return jsonParse(goja.Undefined(), vm.ToValue(string(data)))
+	}
+	toOutput := func(v goja.Value) (map[string]any, error) {
+		if _, ok := v.(*goja.Object); !ok {
+			return nil, errors.New("returned event is not an object")
+		}
+		s, err := jsonStringify(goja.Undefined(), v)
+		if err != nil {
+			return nil, err
+		}
+		var output map[string]any
+		if err := jsonrs.Unmarshal([]byte(s.String()), &output); err != nil {
+			return nil, errors.New("returned event is not an object")
+		}
+		return output, nil
+	}
+
+	// logs are only collected by the transformer service
+	_ = vm.Set("log", func(goja.FunctionCall) goja.Value { return goja.Undefined() })
+	if _, err := vm.RunProgram(program); err != nil {
+		return failAll(events, err)
+	}
+
+	metadataByMessageID := make(map[string]int, len(events))
+	messages := make([]any, len(events))
+	for i := range events {
+		metadataByMessageID[events[i].Metadata.MessageID] = i
+		messages[i] = events[i].Message
+	}
+	jsMetadata := make([]goja.Value, len(events))
+	for i := range events {
+		v, err := toJS(events[i].Metadata)
+		if err != nil {
+			return failAll(events, err)
+		}
+		jsMetadata[i] = v
+	}
+	// metadata(event) returns the metadata of an event, looked up by its message id
+	metadataFn := vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		if event, ok := call.Argument(0).(*goja.Object); ok {
+			if messageID := event.Get("messageId"); messageID != nil {
+				if i, ok := metadataByMessageID[messageID.String()]; ok {
+					return jsMetadata[i]
+				}
+			}
+		}
+		return vm.NewObject()
+	})
+
+	if transformBatch, ok := goja.AssertFunction(vm.Get("transformBatch")); ok {
+		jsEvents, err := toJS(messages)
+		if err != nil {
+			return failAll(events, err)
+		}
+		result, err := settle(transformBatch(goja.Undefined(), jsEvents, metadataFn))
+		if err != nil {
+			return failAll(events, err)
+		}
+		outputs, ok := arrayValues(result)
+		if !ok {
+			return failAll(events, errors.New("transformBatch(events) did not return an array"))
+		}
+		var responses []types.TransformerResponse
+		for _, v := range outputs {
+			if goja.IsUndefined(v) || goja.IsNull(v) {
+				continue
+			}
+			output, err := toOutput(v)
+			if err != nil {
+				responses = append(responses, failed(types.Metadata{}, err))
+				continue
+			}
+			var metadata types.Metadata
+			if messageID, ok := output["messageId"].(string); ok {
+				if i, ok := metadataByMessageID[messageID]; ok {
+					metadata = events[i].Metadata
+				}
+			}
+			responses = append(responses, types.TransformerResponse{Output: output, Metadata: metadata, StatusCode: http.StatusOK})
+		}
+		return responses
+	}
+
+	transformEvent, ok := goja.AssertFunction(vm.Get("transformEvent"))
+	if !ok {
+		return failAll(events, errors.New("transformEvent or transformBatch function is not defined"))
+	}
+	var responses []types.TransformerResponse
+	for i := range events {
+		jsEvent, err := toJS(events[i].Message)
+		if err != nil {
+			responses = append(responses, failed(events[i].Metadata, err))
+			continue
+		}
+		result, err := settle(transformEvent(goja.Undefined(), jsEvent, metadataFn))
+		if err != nil {
+			var interrupted *goja.InterruptedError
+			if errors.As(err, &interrupted) { // the runtime cannot be used anymore
+				return nil
+			}
+			responses = append(responses, failed(events[i].Metadata, err))
+			continue
+		}
+		outputs, ok := arrayValues(result)
+		if !ok {
+			outputs = []goja.Value{result}
+		}
+		for _, v := range outputs {
+			if goja.IsUndefined(v) || goja.IsNull(v) { // filtered out
+				continue
+			}
+			output, err := toOutput(v)
+			if err != nil {
+				responses = append(responses, failed(events[i].Metadata, err))
+				continue
+			}
+			responses = append(responses, types.TransformerResponse{Output: output, Metadata: events[i].Metadata, StatusCode: http.StatusOK})
+		}
+	}
+	return responses
+}
+
+// arrayValues returns the values of a javascript array
+func arrayValues(v goja.Value) ([]goja.Value, bool) {
+	obj, ok := v.(*goja.Object)
+	if !ok || obj.ClassName() != "Array" {
+		return nil, false
+	}
+	values := make([]goja.Value, 0, obj.Get("length").ToInteger())
+	for _, key := range obj.Keys() {
+		values = append(values, obj.Get(key))
+	}
+	return values, true
+}
+
+// rejection is the reason of a rejected promise
+type rejection struct {
+	reason goja.Value
+}
+
+func (e *rejection) Error() string { return e.reason.String() }
+
+// settle returns the value of a settled promise, or the value itself if it isn't a promise
+func settle(v goja.Value, err error) (goja.Value, error) {
+	if err != nil {
+		return nil, err
+	}
+	p, ok := v.Export().(*goja.Promise)
+	if !ok {
+		return v, nil
+	}
+	switch p.State() {
+	case goja.PromiseStateFulfilled:
+		return p.Result(), nil
+	case goja.PromiseStateRejected:
+		return nil, &rejection{reason: p.Result()}
+	default:
+		return nil, errors.New("returned promise never settled")
+	}
+}
+
+// errorMessage returns the message of an error thrown by a transformation, like the transformer service does
+func errorMessage(err error) string {
+	var (
+		thrown   goja.Value
+		ex       *goja.Exception
+		rejected *rejection
+	)
+	switch {
+	case errors.As(err, &ex):
+		thrown = ex.Value()
+	case errors.As(err, &rejected):
+		thrown = rejected.reason
+	default:
+		return err.Error()
+	}
+	if obj, ok := thrown.(*goja.Object); ok {
+		if message := obj.Get("message"); message != nil && !goja.IsUndefined(message) {
+			return message.String()
+		}
+	}
+	return thrown.String()
+}
@@ -0,0 +1,177 @@
+package embedded
+
+import (
+	"slices"
+	"strings"
+)
+
+// keywords after which a slash starts a regular expression instead of being a division
+var regexKeywords = []string{"return", "typeof", "instanceof", "in", "of", "new", "delete", "void", "throw", "case", "do", "else", "yield", "await"}
+
+// stripModuleSyntax removes the export keywords of javascript code, so that exported functions are declared as plain functions,
+// and returns whether the code has import statements, which the engine doesn't support.
+// Strings, template literals, comments and regular expressions are skipped, so that keywords in them are left untouched.
+func stripModuleSyntax(code string) (stripped string, imports bool) {
+	var (
+		out       strings.Builder
+		depth     int   // depth of the braces being scanned
+		templates []int // depths at which the expressions of the template literals being scanned end
+		prev      byte  // last character outside of whitespace, strings, comments and regular expressions
+		prevWord  string
+	)
+	out.Grow(len(code))
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case c == '/' && strings.HasPrefix(code[i:], "//"):
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				end = len(code) - i
+			}
+			out.WriteString(code[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(code[i:], "/*"):
+			end := strings.Index(code[i+2:], "*/")
+			if end < 0 {
+				end = len(code) - i
+			} else {
+				end += 4
+			}
+			out.WriteString(code[i : i+end])
+			i += end
+		case c == '/' && startsRegex(prev, prevWord):
+			end := skipRegex(code, i)
+			out.WriteString(code[i:end])
+			i, prev, prevWord = end, '/', ""
+		case c == '"' || c == '\'':
+			end := skipString(code, i)
+			out.WriteString(code[i:end])
+			i, prev, prevWord = end, c, ""
+		case c == '`' || (c == '}' && len(templates) > 0 && templates[len(templates)-1] == depth):
+			if c == '}' {
+				templates = templates[:len(templates)-1]
+			}
+			end, expression := skipTemplate(code, i+1)
+			if expression {
+				templates = append(templates, depth)
+			}
+			out.WriteString(code[i:end])
+			i, prev, prevWord = end, '`', ""
+		case isIdentifierPart(c):
+			end := i
+			for end < len(code) && isIdentifierPart(code[end]) {
+				end++
+			}
+			word := code[i:end]
+			switch {
+			case word == "import" && prev != '.':
+				imports = true
+			case word == "export" && prev != '.' && end < len(code) && isSpace(code[end]):
+				end = skipSpaces(code, end)
+				if next := skipIdentifier(code, end); code[end:next] == "default" && next < len(code) && isSpace(code[next]) {
+					end = skipSpaces(code, next)
+				}
+				i = end
+				continue
+			}
+			out.WriteString(word)
+			i, prev, prevWord = end, 'a', word
+		default:
+			switch c {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			if !isSpace(c) {
+				prev, prevWord = c, ""
+			}
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String(), imports
+}
+
+// startsRegex returns whether a slash following prev, or the prevWord identifier, starts a regular expression
+func startsRegex(prev byte, prevWord string) bool {
+	if prev == 'a' {
+		return slices.Contains(regexKeywords, prevWord)
+	}
+	return prev != ')' && prev != ']' && prev != '}' && prev != '`' && prev != '"' && prev != '\'' && prev != '/'
+}
+
+// skipString returns the end of the string starting at i
+func skipString(code string, i int) int {
+	quote := code[i]
+	for i++; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case quote, '\n':
+			return i + 1
+		}
+	}
+	return len(code)
+}
+
+// skipTemplate returns the end of the template literal part starting at i, and whether it ends with the start of an expression
+func skipTemplate(code string, i int) (int, bool) {
+	for ; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case '`':
+			return i + 1, false
+		case '$':
+			if i+1 < len(code) && code[i+1] == '{' {
+				return i + 2, true
+			}
+		}
+	}
+	return len(code), false
+}
+
+// skipRegex returns the end of the regular expression starting at i, without its flags
+func skipRegex(code string, i int) int {
+	var class bool
+	for i++; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				return i + 1
+			}
+		case '\n':
+			return i
+		}
+	}
+	return len(code)
+}
+
+func skipIdentifier(code string, i int) int {
+	for i < len(code) && isIdentifierPart(code[i]) {
+		i++
+	}
+	return i
+}
+
+func skipSpaces(code string, i int) int {
+	for i < len(code) && isSpace(code[i]) {
+		i++
+	}
+	return i
+}
+
+func isIdentifierPart(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
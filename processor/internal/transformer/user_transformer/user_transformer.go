@@ -23,6 +23,7 @@ import (
 	transformerclient "github.com/rudderlabs/rudder-server/internal/transformer-client"
 	"github.com/rudderlabs/rudder-server/processor/integrations"
 	transformerutils "github.com/rudderlabs/rudder-server/processor/internal/transformer"
+	"github.com/rudderlabs/rudder-server/processor/internal/transformer/user_transformer/embedded"
 	"github.com/rudderlabs/rudder-server/processor/types"
 	"github.com/rudderlabs/rudder-server/utils/httputil"
 	reportingtypes "github.com/rudderlabs/rudder-server/utils/types"
@@ -34,6 +35,12 @@ func WithClient(client transformerclient.Client) Opt {
 	return func(s *Client) { s.client = client }
 }
 
+// WithTransformationFetcher enables the embedded runtime, which runs the user transformations it supports in-process
+// after fetching their code with the fetcher
+func WithTransformationFetcher(fetcher embedded.Fetcher) Opt {
+	return func(s *Client) { s.embedded = embedded.New(s.conf, s.log, s.stat, fetcher) }
+}
+
 func ForMirroring() Opt {
 	return func(s *Client) { s.config.forMirroring = true }
 }
@@ -76,10 +83,11 @@ type Client struct {
 		collectInstanceLevelStats  bool
 		batchSize                  config.ValueLoader[int]
 	}
-	conf   *config.Config
-	log    logger.Logger
-	stat   stats.Stats
-	client transformerclient.Client
+	conf     *config.Config
+	log      logger.Logger
+	stat     stats.Stats
+	client   transformerclient.Client
+	embedded *embedded.Runtime // optional
 }
 
 func (u *Client) Transform(ctx context.Context, clientEvents []types.TransformerEvent) types.Response {
@@ -134,7 +142,7 @@ func (u *Client) Transform(ctx context.Context, clientEvents []types.Transformer
 		batches,
 		func(batch []types.TransformerEvent, i int) {
 			go func() {
-				transformResponse[i] = u.sendBatch(ctx, u.userTransformURL(), labels, batch)
+				transformResponse[i] = u.transformBatch(ctx, u.userTransformURL(), labels, batch)
 				wg.Done()
 			}()
 		},
@@ -169,6 +177,35 @@ func (u *Client) Transform(ctx context.Context, clientEvents []types.Transformer
 	}
 }
 
+// transformBatch runs the user transformation of a batch in the embedded runtime if it supports it,
+// otherwise it sends the batch to the transformer service
+func (u *Client) transformBatch(ctx context.Context, url string, labels types.TransformerMetricLabels, clientEvents []types.TransformerEvent) []types.TransformerResponse {
+	if u.embedded != nil && len(clientEvents) > 0 {
+		responses, err := u.embedded.Transform(ctx, userTransformerEvents(clientEvents))
+		if err == nil {
+			return responses
+		}
+		u.log.Debugn("Sending user transformation to the transformer service",
+			obskit.Error(err),
+			logger.NewStringField("transformationID", labels.TransformationID),
+		)
+	}
+	return u.sendBatch(ctx, url, labels, clientEvents)
+}
+
+// userTransformerEvents returns the events sent to the user transformation
+func userTransformerEvents(clientEvents []types.TransformerEvent) []types.UserTransformerEvent {
+	return lo.Map(clientEvents, func(clientEvent types.TransformerEvent, index int) types.UserTransformerEvent {
+		res := *clientEvent.ToUserTransformerEvent()
+		// flip sourceID and originalSourceID if it's a replay source for the purpose of any user transformation
+		// flip back afterward
+		if res.Metadata.OriginalSourceID != "" {
+			res.Metadata.OriginalSourceID, res.Metadata.SourceID = res.Metadata.SourceID, res.Metadata.OriginalSourceID
+		}
+		return res
+	})
+}
+
 func (u *Client) sendBatch(ctx context.Context, url string, labels types.TransformerMetricLabels, clientEvents []types.TransformerEvent) []types.TransformerResponse {
 	if len(clientEvents) == 0 {
 		return nil
@@ -180,15 +217,7 @@ func (u *Client) sendBatch(ctx context.Context, url string, labels types.Transfo
 		err     error
 	)
 
-	data := lo.Map(clientEvents, func(clientEvent types.TransformerEvent, index int) types.UserTransformerEvent {
-		res := *clientEvent.ToUserTransformerEvent()
-		// flip sourceID and originalSourceID if it's a replay source for the purpose of any user transformation
-		// flip back afterward
-		if res.Metadata.OriginalSourceID != "" {
-			res.Metadata.OriginalSourceID, res.Metadata.SourceID = res.Metadata.SourceID, res.Metadata.OriginalSourceID
-		}
-		return res
-	})
+	data := userTransformerEvents(clientEvents)
 
 	rawJSON, err = jsonrs.Marshal(data)
 	if err != nil {
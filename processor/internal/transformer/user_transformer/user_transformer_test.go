@@ -31,6 +31,7 @@ import (
 	transformerutils "github.com/rudderlabs/rudder-server/processor/internal/transformer"
 	"github.com/rudderlabs/rudder-server/processor/internal/transformer/user_transformer"
 	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/controlplane"
 	"github.com/rudderlabs/rudder-server/testhelper/backendconfigtest"
 	reportingtypes "github.com/rudderlabs/rudder-server/utils/types"
 )
@@ -700,6 +701,52 @@ func TestUserTransformer(t *testing.T) {
 	}
 }
 
+type fakeFetcher map[string]controlplane.Transformation
+
+func (f fakeFetcher) TransformationByVersionID(_ context.Context, versionID string) (controlplane.Transformation, error) {
+	return f[versionID], nil
+}
+
+func TestEmbeddedUserTransformer(t *testing.T) {
+	ft := &fakeTransformer{t: t}
+	srv := httptest.NewServer(ft)
+	defer srv.Close()
+
+	conf := config.New()
+	conf.Set("USER_TRANSFORM_URL", srv.URL)
+	conf.Set("Processor.UserTransformer.Embedded.enabled", true)
+	tr := user_transformer.New(conf, logger.NOP, stats.NOP, user_transformer.WithClient(srv.Client()), user_transformer.WithTransformationFetcher(fakeFetcher{
+		"embedded": {Code: `export function transformEvent(event) { event["echo-key-1"] = event["src-key-1"]; return event; }`, CodeVersion: "1", Language: "javascript"},
+		"remote":   {Code: "def transformEvent(event, metadata):\n    return event", CodeVersion: "1", Language: "pythonfaas"},
+	}))
+
+	newEvent := func(versionID string) types.TransformerEvent {
+		destination := backendconfigtest.NewDestinationBuilder("WEBHOOK").WithUserTransformation("transformation-"+versionID, versionID).Build()
+		return types.TransformerEvent{
+			Metadata:    types.Metadata{MessageID: "message-1", SourceID: "source-1", OriginalSourceID: "original-source-1", DestinationID: destination.ID},
+			Message:     map[string]interface{}{"src-key-1": "value", "forceStatusCode": http.StatusOK},
+			Destination: destination,
+		}
+	}
+
+	t.Run("supported transformations run in the embedded runtime", func(t *testing.T) {
+		res := tr.Transform(context.Background(), []types.TransformerEvent{newEvent("embedded")})
+		require.Empty(t, ft.requests)
+		require.Empty(t, res.FailedEvents)
+		require.Len(t, res.Events, 1)
+		require.Equal(t, "value", res.Events[0].Output["echo-key-1"])
+		require.Equal(t, "source-1", res.Events[0].Metadata.SourceID)
+		require.Equal(t, "original-source-1", res.Events[0].Metadata.OriginalSourceID)
+	})
+
+	t.Run("other transformations are sent to the transformer", func(t *testing.T) {
+		res := tr.Transform(context.Background(), []types.TransformerEvent{newEvent("remote")})
+		require.Len(t, ft.requests, 1)
+		require.Len(t, res.Events, 1)
+		require.Equal(t, "value", res.Events[0].Output["echo-key-1"])
+	})
+}
+
 func TestLongRunningTransformation(t *testing.T) {
 	fileName := t.TempDir() + "out.log"
 	f, err := os.Create(fileName)
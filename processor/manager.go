@@ -138,6 +138,7 @@ func New(
 				logger.NewLogger().Child("processor"),
 				stats.Default,
 				transformer.WithFeatureService(transformerFeaturesService),
+				transformer.WithBackendConfig(backendconfig.DefaultBackendConfig),
 			),
 		),
 		mainCtx:                    ctx,
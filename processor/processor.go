@@ -160,6 +160,8 @@ type Handle struct {
 		workspaceLibrariesMap                     map[string]backendconfig.LibrariesT
 		oneTrustConsentCategoriesMap              map[string][]string
 		connectionConfigMap                       map[connection]backendconfig.Connection
+		connectionFilterRulesMap                  map[connection]eventfilter.Rules
+		destinationSamplingMap                    map[string]*eventfilter.Sampling
 		ketchConsentCategoriesMap                 map[string][]string
 		genericConsentManagementMap               SourceConsentMap
 		batchDestinations                         []string
@@ -187,6 +189,13 @@ type Handle struct {
 		jobRunIDs config.ValueLoader[[]string]
 	}
 
+	priorityConfig struct {
+		highSourceIDs  config.ValueLoader[[]string]
+		lowSourceIDs   config.ValueLoader[[]string]
+		highEventTypes config.ValueLoader[[]string]
+		lowEventTypes  config.ValueLoader[[]string]
+	}
+
 	namespace  string
 	instanceID string
 
@@ -250,6 +259,7 @@ type ParametersT struct {
 	WorkspaceId             string      `json:"workspaceId"`
 	TraceParent             string      `json:"traceparent"`
 	ConnectionID            string      `json:"connection_id"`
+	Priority                int         `json:"priority,omitempty"`
 }
 
 type MetricMetadata struct {
@@ -611,6 +621,31 @@ func (proc *Handle) setupReloadableVars() {
 	proc.jobsDBCommandTimeout = proc.conf.GetReloadableDurationVar(600, time.Second, "JobsDB.Processor.CommandRequestTimeout", "JobsDB.CommandRequestTimeout")
 	proc.jobdDBMaxRetries = proc.conf.GetReloadableIntVar(2, 1, "JobsDB.Processor.MaxRetries", "JobsDB.MaxRetries")
 	proc.drainConfig.jobRunIDs = proc.conf.GetReloadableStringSliceVar([]string{}, "drain.jobRunIDs")
+	proc.priorityConfig.highSourceIDs = proc.conf.GetReloadableStringSliceVar([]string{}, "Processor.priority.high.sourceIDs")
+	proc.priorityConfig.lowSourceIDs = proc.conf.GetReloadableStringSliceVar([]string{}, "Processor.priority.low.sourceIDs")
+	proc.priorityConfig.highEventTypes = proc.conf.GetReloadableStringSliceVar([]string{}, "Processor.priority.high.eventTypes")
+	proc.priorityConfig.lowEventTypes = proc.conf.GetReloadableStringSliceVar([]string{}, "Processor.priority.low.eventTypes")
+}
+
+// jobPriority returns the router priority of a job, based on its source and event type.
+// Source priorities take precedence over event type priorities.
+func (proc *Handle) jobPriority(sourceID, eventType string) int {
+	switch {
+	case slices.Contains(proc.priorityConfig.highSourceIDs.Load(), sourceID):
+		return jobsdb.PriorityHigh
+	case slices.Contains(proc.priorityConfig.lowSourceIDs.Load(), sourceID):
+		return jobsdb.PriorityLow
+	}
+	equalFold := func(s string) bool { return strings.EqualFold(s, eventType) }
+	switch {
+	case eventType == "":
+		return jobsdb.PriorityDefault
+	case slices.ContainsFunc(proc.priorityConfig.highEventTypes.Load(), equalFold):
+		return jobsdb.PriorityHigh
+	case slices.ContainsFunc(proc.priorityConfig.lowEventTypes.Load(), equalFold):
+		return jobsdb.PriorityLow
+	}
+	return jobsdb.PriorityDefault
 }
 
 // Start starts this processor's main loops.
@@ -805,10 +840,25 @@ func (proc *Handle) backendConfigSubscriber(ctx context.Context) {
 			credentialsMap               = make(map[string][]types.Credential)
 			nonEventStreamSources        = make(map[string]bool)
 			connectionConfigMap          = make(map[connection]backendconfig.Connection)
+			connectionFilterRulesMap     = make(map[connection]eventfilter.Rules)
+			destinationSamplingMap       = make(map[string]*eventfilter.Sampling)
 		)
 		for workspaceID, wConfig := range config {
 			for _, conn := range wConfig.Connections {
-				connectionConfigMap[connection{sourceID: conn.SourceID, destinationID: conn.DestinationID}] = conn
+				key := connection{sourceID: conn.SourceID, destinationID: conn.DestinationID}
+				connectionConfigMap[key] = conn
+				rules, err := eventfilter.CompileRules(conn)
+				if err != nil {
+					proc.logger.Errorn("Invalid event filter rules, not filtering events of the connection",
+						obskit.SourceID(conn.SourceID),
+						obskit.DestinationID(conn.DestinationID),
+						obskit.Error(err),
+					)
+					continue
+				}
+				if len(rules) > 0 {
+					connectionFilterRulesMap[key] = rules
+				}
 			}
 			for i := range wConfig.Sources {
 				source := &wConfig.Sources[i]
@@ -826,6 +876,15 @@ func (proc *Handle) backendConfigSubscriber(ctx context.Context) {
 						if err != nil {
 							proc.logger.Errorn("Error in pinger loop", obskit.Error(err))
 						}
+						sampling, err := eventfilter.GetSampling(destination)
+						if err != nil {
+							proc.logger.Errorn("Invalid event sampling policy, not sampling events of the destination",
+								obskit.DestinationID(destination.ID),
+								obskit.Error(err),
+							)
+						} else if sampling != nil {
+							destinationSamplingMap[destination.ID] = sampling
+						}
 					}
 				}
 				if source.SourceDefinition.Category != "" && !strings.EqualFold(source.SourceDefinition.Category, sourceCategoryWebhook) {
@@ -845,6 +904,8 @@ func (proc *Handle) backendConfigSubscriber(ctx context.Context) {
 		}
 		proc.config.configSubscriberLock.Lock()
 		proc.config.connectionConfigMap = connectionConfigMap
+		proc.config.connectionFilterRulesMap = connectionFilterRulesMap
+		proc.config.destinationSamplingMap = destinationSamplingMap
 		proc.config.oneTrustConsentCategoriesMap = oneTrustConsentCategoriesMap
 		proc.config.ketchConsentCategoriesMap = ketchConsentCategoriesMap
 		proc.config.genericConsentManagementMap = genericConsentManagementMap
@@ -874,6 +935,18 @@ func (proc *Handle) getConnectionConfig(conn connection) backendconfig.Connectio
 	return proc.config.connectionConfigMap[conn]
 }
 
+func (proc *Handle) getConnectionFilterRules(sourceID, destinationID string) eventfilter.Rules {
+	proc.config.configSubscriberLock.RLock()
+	defer proc.config.configSubscriberLock.RUnlock()
+	return proc.config.connectionFilterRulesMap[connection{sourceID: sourceID, destinationID: destinationID}]
+}
+
+func (proc *Handle) getDestinationSampling(destinationID string) *eventfilter.Sampling {
+	proc.config.configSubscriberLock.RLock()
+	defer proc.config.configSubscriberLock.RUnlock()
+	return proc.config.destinationSamplingMap[destinationID]
+}
+
 func (proc *Handle) getSourceBySourceID(sourceId string) (*backendconfig.SourceT, error) {
 	var err error
 	proc.config.configSubscriberLock.RLock()
@@ -1338,6 +1411,13 @@ func (proc *Handle) updateMetricMaps(
 	sd.ViolationCount += int64(veCount)
 }
 
+// filteredStates are the reporting statuses of filtered out events, by their status code
+var filteredStates = map[int]string{
+	reportingtypes.FilterEventCode:     jobsdb.Filtered.State,
+	reportingtypes.FilterRuleEventCode: reportingtypes.FilteredByRuleStatus,
+	reportingtypes.SampledOutEventCode: reportingtypes.SampledOutStatus,
+}
+
 func (proc *Handle) getNonSuccessfulMetrics(
 	response types.Response,
 	commonMetaData *types.Metadata,
@@ -1348,20 +1428,42 @@ func (proc *Handle) getNonSuccessfulMetrics(
 
 	grouped := lo.GroupBy(
 		response.FailedEvents,
-		func(event types.TransformerResponse) bool {
-			return event.StatusCode == reportingtypes.FilterEventCode
+		func(event types.TransformerResponse) string {
+			if state, ok := filteredStates[event.StatusCode]; ok {
+				return state
+			}
+			return jobsdb.Aborted.State
 		},
 	)
-	filtered, failed := grouped[true], grouped[false]
+	failed := grouped[jobsdb.Aborted.State]
 
 	m.filteredJobs, m.filteredMetrics, m.filteredCountMap = proc.getTransformationMetrics(
-		filtered,
+		grouped[jobsdb.Filtered.State],
 		jobsdb.Filtered.State,
 		commonMetaData,
 		eventsByMessageID,
 		inPU,
 		pu,
 	)
+	// events filtered by connection rules or sampled out are counted as filtered, but reported with their own status
+	for _, state := range []string{reportingtypes.FilteredByRuleStatus, reportingtypes.SampledOutStatus} {
+		if len(grouped[state]) == 0 {
+			continue
+		}
+		jobs, metrics, countMap := proc.getTransformationMetrics(
+			grouped[state],
+			state,
+			commonMetaData,
+			eventsByMessageID,
+			inPU,
+			pu,
+		)
+		m.filteredJobs = append(m.filteredJobs, jobs...)
+		m.filteredMetrics = append(m.filteredMetrics, metrics...)
+		for k, v := range countMap {
+			m.filteredCountMap[k] += v
+		}
+	}
 
 	m.failedJobs, m.failedMetrics, m.failedCountMap = proc.getTransformationMetrics(
 		failed,
@@ -1412,7 +1514,7 @@ func (proc *Handle) getTransformationMetrics(
 	countMap := make(map[string]int64)
 	var jobs []*jobsdb.JobT
 	statFunc := procErrorCountsStat
-	if state == jobsdb.Filtered.State {
+	if lo.Contains(lo.Values(filteredStates), state) {
 		statFunc = procFilteredCountStat
 	}
 	for i := range transformerResponses {
@@ -3306,6 +3408,8 @@ func (proc *Handle) userTransformAndFilter(
 			return false, ""
 		},
 	)
+	response = FilterByConnectionRules(response, proc.getConnectionFilterRules(sourceID, destID))
+	response = SampleEvents(response, proc.getDestinationSampling(destID))
 	var successMetrics []*reportingtypes.PUReportedMetric
 	var successCountMap map[string]int64
 	var successCountMetadataMap map[string]MetricMetadata
@@ -3531,6 +3635,7 @@ func (proc *Handle) destTransform(
 				WorkspaceId:             workspaceId,
 				TraceParent:             metadata.TraceParent,
 				ConnectionID:            generateConnectionID(sourceID, destID),
+				Priority:                proc.jobPriority(sourceID, eventType),
 			}
 			marshalledParams, err := jsonrs.Marshal(params)
 			if err != nil {
@@ -3715,6 +3820,52 @@ func ConvertToFilteredTransformerResponse(
 	return types.Response{Events: responses, FailedEvents: failedEvents}
 }
 
+// FilterByConnectionRules moves the events of the response which don't satisfy the filtering rules of their connection
+// to its failed events, with a [reportingtypes.FilterRuleEventCode] status code
+func FilterByConnectionRules(response types.Response, rules eventfilter.Rules) types.Response {
+	if len(rules) == 0 {
+		return response
+	}
+	events := make([]types.TransformerResponse, 0, len(response.Events))
+	for _, event := range response.Events {
+		if allow, rule := rules.Allow(event.Output); !allow {
+			response.FailedEvents = append(response.FailedEvents, types.TransformerResponse{
+				Output:     event.Output,
+				StatusCode: reportingtypes.FilterRuleEventCode,
+				Metadata:   event.Metadata,
+				Error:      fmt.Sprintf("Event filtered by rule %q", rule.Name),
+			})
+			continue
+		}
+		events = append(events, event)
+	}
+	response.Events = events
+	return response
+}
+
+// SampleEvents moves the events of the response which are sampled out by the sampling policy of their destination
+// to its failed events, with a [reportingtypes.SampledOutEventCode] status code
+func SampleEvents(response types.Response, sampling *eventfilter.Sampling) types.Response {
+	if sampling == nil {
+		return response
+	}
+	events := make([]types.TransformerResponse, 0, len(response.Events))
+	for _, event := range response.Events {
+		if !sampling.Keep(event.Output) {
+			response.FailedEvents = append(response.FailedEvents, types.TransformerResponse{
+				Output:     event.Output,
+				StatusCode: reportingtypes.SampledOutEventCode,
+				Metadata:   event.Metadata,
+				Error:      fmt.Sprintf("Event sampled out, keeping %v%% of events", sampling.Percentage),
+			})
+			continue
+		}
+		events = append(events, event)
+	}
+	response.Events = events
+	return response
+}
+
 func (proc *Handle) getJobsStage(ctx context.Context, partition string) jobsdb.JobsResult {
 	s := time.Now()
 
@@ -36,6 +36,7 @@ import (
 	mockDedup "github.com/rudderlabs/rudder-server/mocks/services/dedup"
 	mockFeatures "github.com/rudderlabs/rudder-server/mocks/services/transformer"
 	mockreportingtypes "github.com/rudderlabs/rudder-server/mocks/utils/types"
+	"github.com/rudderlabs/rudder-server/processor/eventfilter"
 	"github.com/rudderlabs/rudder-server/processor/isolation"
 	"github.com/rudderlabs/rudder-server/processor/transformer"
 	"github.com/rudderlabs/rudder-server/processor/types"
@@ -3742,6 +3743,66 @@ var _ = Describe("Static Function Tests", func() {
 		})
 	})
 
+	Context("FilterByConnectionRules Tests", func() {
+		It("Should move events which don't satisfy the rules of their connection to failed events", func() {
+			rules, err := eventfilter.CompileRules(backendconfig.Connection{Config: map[string]interface{}{
+				"eventFilterRules": []interface{}{
+					map[string]interface{}{"name": "has revenue", "condition": "properties.revenue != null"},
+				},
+			}})
+			Expect(err).To(BeNil())
+			response := types.Response{
+				Events: []types.TransformerResponse{
+					{
+						Output:     map[string]interface{}{"properties": map[string]interface{}{"revenue": 10}},
+						StatusCode: 200,
+						Metadata:   types.Metadata{MessageID: "message-1"},
+					},
+					{
+						Output:     map[string]interface{}{"properties": map[string]interface{}{}},
+						StatusCode: 200,
+						Metadata:   types.Metadata{MessageID: "message-2"},
+					},
+				},
+				FailedEvents: []types.TransformerResponse{
+					{StatusCode: reportingtypes.FilterEventCode, Metadata: types.Metadata{MessageID: "message-3"}},
+				},
+			}
+
+			Expect(FilterByConnectionRules(response, nil)).To(Equal(response))
+
+			filtered := FilterByConnectionRules(response, rules)
+			Expect(filtered.Events).To(HaveLen(1))
+			Expect(filtered.Events[0].Metadata.MessageID).To(Equal("message-1"))
+			Expect(filtered.FailedEvents).To(HaveLen(2))
+			Expect(filtered.FailedEvents[1].Metadata.MessageID).To(Equal("message-2"))
+			Expect(filtered.FailedEvents[1].StatusCode).To(Equal(reportingtypes.FilterRuleEventCode))
+			Expect(filtered.FailedEvents[1].Error).To(Equal(`Event filtered by rule "has revenue"`))
+		})
+	})
+
+	Context("SampleEvents Tests", func() {
+		It("Should move events which are sampled out by the sampling policy of their destination to failed events", func() {
+			response := types.Response{
+				Events: []types.TransformerResponse{
+					{Output: map[string]interface{}{"userId": "user-1"}, StatusCode: 200, Metadata: types.Metadata{MessageID: "message-1"}},
+					{Output: map[string]interface{}{"userId": "user-2"}, StatusCode: 200, Metadata: types.Metadata{MessageID: "message-2"}},
+				},
+			}
+
+			Expect(SampleEvents(response, nil)).To(Equal(response))
+			Expect(SampleEvents(response, &eventfilter.Sampling{Percentage: 100, Strategy: eventfilter.SamplingStrategyUser})).To(Equal(response))
+
+			sampled := SampleEvents(response, &eventfilter.Sampling{Percentage: 0, Strategy: eventfilter.SamplingStrategyUser})
+			Expect(sampled.Events).To(BeEmpty())
+			Expect(sampled.FailedEvents).To(HaveLen(2))
+			for _, event := range sampled.FailedEvents {
+				Expect(event.StatusCode).To(Equal(reportingtypes.SampledOutEventCode))
+				Expect(event.Error).To(Equal("Event sampled out, keeping 0% of events"))
+			}
+		})
+	})
+
 	Context("getDiffMetrics Tests", func() {
 		It("Should match diffMetrics response for Valid Inputs", func() {
 			// Case 1: Event name transformation (10 in -> 10 transformed)
@@ -5642,3 +5703,20 @@ func TestStoreMessageMerge(t *testing.T) {
 		start:          time.UnixMicro(99999999),
 	})
 }
+
+func TestJobPriority(t *testing.T) {
+	c := config.New()
+	c.Set("Processor.priority.high.sourceIDs", []string{"high-source"})
+	c.Set("Processor.priority.low.sourceIDs", []string{"low-source"})
+	c.Set("Processor.priority.high.eventTypes", []string{"identify"})
+	c.Set("Processor.priority.low.eventTypes", []string{"page", "screen"})
+	proc := &Handle{conf: c}
+	proc.setupReloadableVars()
+
+	require.Equal(t, jobsdb.PriorityDefault, proc.jobPriority("source", "track"))
+	require.Equal(t, jobsdb.PriorityDefault, proc.jobPriority("source", ""))
+	require.Equal(t, jobsdb.PriorityHigh, proc.jobPriority("source", "Identify"))
+	require.Equal(t, jobsdb.PriorityLow, proc.jobPriority("source", "screen"))
+	require.Equal(t, jobsdb.PriorityHigh, proc.jobPriority("high-source", "track"))
+	require.Equal(t, jobsdb.PriorityLow, proc.jobPriority("low-source", "identify"), "source priority takes precedence")
+}
@@ -48,7 +48,22 @@ func reportViolations(validateEvent *types.TransformerResponse, trackingPlanId s
 	}
 	context["trackingPlanId"] = trackingPlanId
 	context["trackingPlanVersion"] = trackingPlanVersion
-	context["violationErrors"] = validationErrors
+	context["violationErrors"] = mergeViolations(context["violationErrors"], validationErrors)
+}
+
+// mergeViolations appends the validation errors to the violations already present in the context,
+// e.g. the ones annotated by the gateway's event schema validation, so that neither overwrites the other
+func mergeViolations(existing any, validationErrors []types.ValidationError) any {
+	existingViolations, ok := existing.([]any)
+	if !ok || len(existingViolations) == 0 {
+		return validationErrors
+	}
+	merged := make([]any, 0, len(existingViolations)+len(validationErrors))
+	merged = append(merged, existingViolations...)
+	for _, validationError := range validationErrors {
+		merged = append(merged, validationError)
+	}
+	return merged
 }
 
 // enhanceWithViolation It enhances extra information of ValidationErrors in context for:
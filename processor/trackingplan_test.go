@@ -205,4 +205,28 @@ func TestReportViolations(t *testing.T) {
 			assert.Equal(t, eventContext["violationErrors"], event.ValidationErrors)
 		}
 	})
+
+	t.Run("Merging with violations annotated by the gateway", func(t *testing.T) {
+		gatewayViolation := map[string]interface{}{"type": "required", "message": "userId is required", "property": "userId"}
+		validationError := types.ValidationError{Type: "Unplanned-Event", Message: "event not specified in the tracking plan", Meta: map[string]string{}}
+		response := types.Response{
+			Events: []types.TransformerResponse{
+				{
+					Output: map[string]interface{}{
+						"context": map[string]interface{}{"violationErrors": []interface{}{gatewayViolation}},
+					},
+					ValidationErrors: []types.ValidationError{validationError},
+				},
+				{
+					Output: map[string]interface{}{
+						"context": map[string]interface{}{"violationErrors": []interface{}{gatewayViolation}},
+					},
+				},
+			},
+		}
+
+		enhanceWithViolation(response, "tp-1", 1)
+		assert.Equal(t, []interface{}{gatewayViolation, validationError}, response.Events[0].Output["context"].(map[string]interface{})["violationErrors"])
+		assert.Equal(t, []interface{}{gatewayViolation}, response.Events[1].Output["context"].(map[string]interface{})["violationErrors"], "violations of the gateway should be kept")
+	})
 }
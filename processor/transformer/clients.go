@@ -8,10 +8,12 @@ import (
 	"github.com/rudderlabs/rudder-go-kit/config"
 	"github.com/rudderlabs/rudder-go-kit/logger"
 	"github.com/rudderlabs/rudder-go-kit/stats"
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
 	"github.com/rudderlabs/rudder-server/processor/internal/transformer/destination_transformer"
 	"github.com/rudderlabs/rudder-server/processor/internal/transformer/trackingplan_validation"
 	"github.com/rudderlabs/rudder-server/processor/internal/transformer/user_transformer"
 	"github.com/rudderlabs/rudder-server/processor/types"
+	"github.com/rudderlabs/rudder-server/services/controlplane"
 	transformerfs "github.com/rudderlabs/rudder-server/services/transformer"
 )
 
@@ -48,6 +50,17 @@ func WithFeatureService(featuresService transformerfs.FeaturesService) func(*opt
 	}
 }
 
+// WithBackendConfig is used to set the backend config, whose identity is used by the user transformer
+// for fetching the user transformations it can run in its embedded runtime,
+// and by the tracking plan client for fetching the tracking plans it can validate events against in-process.
+func WithBackendConfig(bc backendconfig.BackendConfig) func(*opts) {
+	return func(o *opts) {
+		fetcher := controlplane.NewFetcher(bc)
+		o.userOpts = append(o.userOpts, user_transformer.WithTransformationFetcher(fetcher))
+		o.trackingPlanOpts = append(o.trackingPlanOpts, trackingplan_validation.WithTrackingPlanFetcher(fetcher))
+	}
+}
+
 // NewClients creates a new instance of TransformerClients.
 func NewClients(conf *config.Config, log logger.Logger, statsFactory stats.Stats, options ...func(*opts)) TransformerClients {
 	var opts opts
@@ -55,10 +68,10 @@ func NewClients(conf *config.Config, log logger.Logger, statsFactory stats.Stats
 		option(&opts)
 	}
 	return &Clients{
-		user:         user_transformer.New(conf, log, statsFactory),
+		user:         user_transformer.New(conf, log, statsFactory, opts.userOpts...),
 		userMirror:   user_transformer.New(conf, log, statsFactory, user_transformer.ForMirroring()),
 		destination:  destination_transformer.New(conf, log, statsFactory, opts.destinationOpts...),
-		trackingplan: trackingplan_validation.New(conf, log, statsFactory),
+		trackingplan: trackingplan_validation.New(conf, log, statsFactory, opts.trackingPlanOpts...),
 	}
 }
 
@@ -71,5 +84,7 @@ func (c *Clients) Destination() DestinationClient { return c.destination }
 func (c *Clients) TrackingPlan() TrackingPlanClient { return c.trackingplan }
 
 type opts struct {
-	destinationOpts []destination_transformer.Opt
+	destinationOpts  []destination_transformer.Opt
+	userOpts         []user_transformer.Opt
+	trackingPlanOpts []trackingplan_validation.Opt
 }
@@ -66,6 +66,7 @@ type Handle struct {
 	reloadableConfig                   *reloadableConfig
 	destType                           string
 	guaranteeUserEventOrder            bool
+	priorityLanes                      bool // whether the router's jobsdb serves priority lanes, see [jobsdb.PriorityLanesEnabled]
 	netClientTimeout                   time.Duration
 	transformerTimeout                 time.Duration
 	enableBatching                     bool
@@ -556,6 +557,8 @@ func (rt *Handle) getQueryParams(partition string, pickUpCount int) jobsdb.GetQu
 		CustomValFilters: []string{rt.destType},
 		PayloadSizeLimit: rt.adaptiveLimit(rt.reloadableConfig.payloadLimit.Load()),
 		JobsLimit:        pickUpCount,
+		// priority lanes would reorder the jobs of a user, so they are only used when event ordering isn't guaranteed
+		PriorityLanes: rt.priorityLanes && rt.reloadableConfig.priorityLanes.Load() && !rt.guaranteeUserEventOrder,
 	}
 	rt.isolationStrategy.AugmentQueryParams(partition, &params)
 	return params
@@ -106,6 +106,7 @@ func (rt *Handle) Setup(
 		rt.saveDestinationResponse = value
 	}
 	rt.guaranteeUserEventOrder = getRouterConfigBool("guaranteeUserEventOrder", rt.destType, true)
+	rt.priorityLanes = jobsdb.PriorityLanesEnabled(config, "rt")
 	rt.noOfWorkers = getRouterConfigInt("noOfWorkers", destType, 64)
 	rt.workerInputBufferSize = getRouterConfigInt("noOfJobsPerChannel", destType, 1000)
 	// Explicitly control destination types for which we want to support batching
@@ -312,6 +313,8 @@ func (rt *Handle) setupReloadableVars() {
 	rt.reloadableConfig.maxFailedCountForJob = config.GetReloadableIntVar(3, 1, getRouterConfigKeys("maxFailedCountForJob", rt.destType)...)
 	rt.reloadableConfig.maxFailedCountForSourcesJob = config.GetReloadableIntVar(3, 1, getRouterConfigKeys("RSources.maxFailedCountForJob", rt.destType)...)
 	rt.reloadableConfig.payloadLimit = config.GetReloadableInt64Var(100*bytesize.MB, 1, getRouterConfigKeys("PayloadLimit", rt.destType)...)
+	// priority lanes can only be disabled per destination type, since they need the router's jobsdb to index priorities
+	rt.reloadableConfig.priorityLanes = config.GetReloadableBoolVar(true, "Router."+rt.destType+".priorityLanes.enabled")
 	rt.reloadableConfig.retryTimeWindow = config.GetReloadableDurationVar(180, time.Minute, getRouterConfigKeys("retryTimeWindow", rt.destType)...)
 	rt.reloadableConfig.sourcesRetryTimeWindow = config.GetReloadableDurationVar(1, time.Minute, getRouterConfigKeys("RSources.retryTimeWindow", rt.destType)...)
 	rt.reloadableConfig.maxDSQuerySize = config.GetReloadableIntVar(10, 1, getRouterConfigKeys("maxDSQuery", rt.destType)...)
@@ -340,6 +343,9 @@ func (rt *Handle) setupReloadableVars() {
 
 func (rt *Handle) Start() {
 	rt.logger.Infon("Starting router", obskit.DestinationType(rt.destType))
+	if rt.priorityLanes && rt.reloadableConfig.priorityLanes.Load() && rt.guaranteeUserEventOrder {
+		rt.logger.Warnn("Priority lanes are disabled since user event order is guaranteed, set guaranteeUserEventOrder to false for using them", obskit.DestinationType(rt.destType))
+	}
 	rt.startEnded = make(chan struct{})
 	ctx := rt.backgroundCtx
 
@@ -152,7 +152,8 @@ func (ji *Iterator) Next() *jobsdb.JobT {
 	idx := ji.state.idx
 	ji.state.idx++
 	nextJob := ji.state.jobs[idx]
-	if ji.state.previousJob != nil && ji.state.previousJob.JobID > nextJob.JobID {
+	// with priority lanes, a batch can contain lower priority jobs older than the ones of the previous batch
+	if ji.state.previousJob != nil && ji.state.previousJob.JobID > nextJob.JobID && !ji.params.PriorityLanes {
 		panic(fmt.Errorf("job iterator encountered out of order jobs: previousJobID: %d, nextJobID: %d", ji.state.previousJob.JobID, nextJob.JobID))
 	}
 	ji.state.previousJob = nextJob
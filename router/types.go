@@ -83,4 +83,5 @@ type reloadableConfig struct {
 	skipRtAbortAlertForDelivery       config.ValueLoader[bool] // represents if transformation(router or batch) should be alerted via router-aborted-count alert def
 	oauthV2Enabled                    config.ValueLoader[bool]
 	oauthV2ExpirationTimeDiff         config.ValueLoader[time.Duration]
+	priorityLanes                     config.ValueLoader[bool] // serve higher priority jobs first if the router's jobsdb serves priority lanes, unless user event order is guaranteed
 }
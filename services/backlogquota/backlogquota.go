@@ -0,0 +1,230 @@
+// Package backlogquota enforces quotas on the backlog of incomplete jobs of every workspace,
+// so that a single workspace cannot fill up the disk of a multi-tenant data plane, e.g. because of a dead destination.
+//
+// In embedded mode, where the gateway runs in the same process as the processor and routers, the jobs of the router jobsdbs
+// are known from their pending events counts. A standalone gateway scans the router jobsdbs of the same database instead.
+package backlogquota
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-go-kit/bytesize"
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	obskit "github.com/rudderlabs/rudder-observability-kit/go/labels"
+
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/rmetrics"
+)
+
+// BacklogReader reads the backlog of incomplete jobs of every workspace in a jobsdb
+type BacklogReader interface {
+	GetWorkspaceBacklogs(ctx context.Context) (map[string]jobsdb.WorkspaceBacklog, error)
+}
+
+// NewPendingEventsReader returns a reader of the backlogs of the router jobsdbs with the table prefixes,
+// based on the pending events counts which routers and the processor maintain incrementally, so that reading them is cheap enough for every poll.
+// Pending events are jobs in router jobsdbs, but their payload size is not tracked, so these backlogs only count towards the jobs quota:
+// the payload sizes of the router jobsdbs can be read with [NewPayloadSizeReader].
+func NewPendingEventsReader(registry rmetrics.PendingEventsRegistry, tablePrefixes ...string) BacklogReader {
+	return &pendingEventsReader{registry: registry, tablePrefixes: tablePrefixes}
+}
+
+type pendingEventsReader struct {
+	registry      rmetrics.PendingEventsRegistry
+	tablePrefixes []string
+}
+
+func (r *pendingEventsReader) GetWorkspaceBacklogs(context.Context) (map[string]jobsdb.WorkspaceBacklog, error) {
+	backlogs := make(map[string]jobsdb.WorkspaceBacklog)
+	for _, tablePrefix := range r.tablePrefixes {
+		for workspaceID, pending := range r.registry.PendingEventsByWorkspace(tablePrefix) {
+			backlog := backlogs[workspaceID]
+			backlog.Jobs += int64(pending)
+			backlogs[workspaceID] = backlog
+		}
+	}
+	return backlogs, nil
+}
+
+// NewPayloadSizeReader returns a reader of the payload sizes of the backlogs read by the reader, without their jobs,
+// for jobsdbs whose jobs are already counted by a reader created with [NewPendingEventsReader]
+func NewPayloadSizeReader(reader BacklogReader) BacklogReader {
+	return &payloadSizeReader{reader: reader}
+}
+
+type payloadSizeReader struct {
+	reader BacklogReader
+}
+
+func (r *payloadSizeReader) GetWorkspaceBacklogs(ctx context.Context) (map[string]jobsdb.WorkspaceBacklog, error) {
+	backlogs, err := r.reader.GetWorkspaceBacklogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for workspaceID, backlog := range backlogs {
+		backlogs[workspaceID] = jobsdb.WorkspaceBacklog{PayloadBytes: backlog.PayloadBytes}
+	}
+	return backlogs, nil
+}
+
+// Quota tells whether new jobs of a workspace can be admitted, based on its backlog of incomplete jobs
+type Quota interface {
+	// Admit returns whether new jobs of the workspace can be admitted and, if not, after how long to retry
+	Admit(workspaceID string) (ok bool, retryAfter time.Duration)
+}
+
+// NewTracker creates a new tracker of the backlogs of the provided jobsdbs, which needs to be [Tracker.Run] for enforcing quotas.
+//
+// Readers created with [NewPendingEventsReader] are read on every poll (BacklogQuota.pollInterval), all others scan the backlogs of a jobsdb,
+// so they are only read every BacklogQuota.scanInterval.
+// Only the backlogs of the provided readers count.
+//
+// Quotas are configured with BacklogQuota.maxJobs and BacklogQuota.maxPayloadSizeInMB, which can be overridden per workspace
+// with BacklogQuota.<workspaceID>.maxJobs and BacklogQuota.<workspaceID>.maxPayloadSizeInMB. Zero means no quota.
+// Once a workspace uses more than BacklogQuota.slowDownRatio of a quota, an increasing share of its new jobs is rejected,
+// until all of them are rejected when the quota is exceeded.
+func NewTracker(conf *config.Config, stat stats.Stats, log logger.Logger, readers ...BacklogReader) *Tracker {
+	t := &Tracker{
+		conf:    conf,
+		stats:   stat,
+		logger:  log.Child("backlogquota"),
+		readers: readers,
+		usage:   make(map[string]float64),
+		scanned: make(map[int]map[string]jobsdb.WorkspaceBacklog),
+	}
+	t.config.enabled = conf.GetReloadableBoolVar(false, "BacklogQuota.enabled")
+	t.config.pollInterval = conf.GetReloadableDurationVar(30, time.Second, "BacklogQuota.pollInterval")
+	t.config.scanInterval = conf.GetReloadableDurationVar(5, time.Minute, "BacklogQuota.scanInterval")
+	t.config.slowDownRatio = conf.GetReloadableFloat64Var(0.8, "BacklogQuota.slowDownRatio")
+	return t
+}
+
+// Tracker periodically reads the backlogs of all workspaces and admits new jobs according to their quota usage
+type Tracker struct {
+	conf    *config.Config
+	stats   stats.Stats
+	logger  logger.Logger
+	readers []BacklogReader
+	config  struct {
+		enabled       config.ValueLoader[bool]
+		pollInterval  config.ValueLoader[time.Duration]
+		scanInterval  config.ValueLoader[time.Duration]
+		slowDownRatio config.ValueLoader[float64]
+	}
+
+	// backlogs of the readers scanning their jobsdb (by index), as of their last scan
+	scanned  map[int]map[string]jobsdb.WorkspaceBacklog
+	lastScan time.Time
+
+	mu    sync.RWMutex
+	usage map[string]float64 // workspace => highest share of its quotas being used
+}
+
+// Run polls the backlogs of all workspaces until the context is cancelled
+func (t *Tracker) Run(ctx context.Context) {
+	for {
+		if t.config.enabled.Load() {
+			t.poll(ctx)
+		} else {
+			t.setUsage(map[string]float64{})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(t.config.pollInterval.Load()):
+		}
+	}
+}
+
+// Admit returns whether new jobs of the workspace can be admitted and, if not, after how long to retry
+func (t *Tracker) Admit(workspaceID string) (bool, time.Duration) {
+	t.mu.RLock()
+	usage := t.usage[workspaceID]
+	t.mu.RUnlock()
+	slowDownRatio := t.config.slowDownRatio.Load()
+	switch {
+	case usage < slowDownRatio:
+		return true, 0
+	case usage < 1 && rand.Float64() >= (usage-slowDownRatio)/(1-slowDownRatio):
+		return true, 0
+	default:
+		return false, t.config.pollInterval.Load()
+	}
+}
+
+// poll reads the backlogs of all workspaces and updates their quota usage.
+// If a backlog cannot be read, quotas are not enforced until the next successful poll.
+func (t *Tracker) poll(ctx context.Context) {
+	start := time.Now()
+	scan := time.Since(t.lastScan) >= t.config.scanInterval.Load()
+	backlogs := make(map[string]jobsdb.WorkspaceBacklog)
+	for i, reader := range t.readers {
+		_, incremental := reader.(*pendingEventsReader)
+		readerBacklogs, scanned := t.scanned[i]
+		if incremental || scan || !scanned {
+			var err error
+			if readerBacklogs, err = reader.GetWorkspaceBacklogs(ctx); err != nil {
+				if ctx.Err() == nil {
+					t.logger.Errorn("Reading workspace backlogs failed, not enforcing backlog quotas", obskit.Error(err))
+					t.stats.NewStat("backlog_quota_poll_errors", stats.CountType).Increment()
+					t.setUsage(map[string]float64{})
+					clear(t.scanned)
+				}
+				return
+			}
+			if !incremental {
+				t.scanned[i] = readerBacklogs
+			}
+		}
+		for workspaceID, backlog := range readerBacklogs {
+			total := backlogs[workspaceID]
+			total.Jobs += backlog.Jobs
+			total.PayloadBytes += backlog.PayloadBytes
+			backlogs[workspaceID] = total
+		}
+	}
+	if scan {
+		t.lastScan = start
+	}
+	t.stats.NewStat("backlog_quota_poll_time", stats.TimerType).Since(start)
+
+	usage := make(map[string]float64, len(backlogs))
+	for workspaceID, backlog := range backlogs {
+		tags := stats.Tags{"workspaceId": workspaceID}
+		t.stats.NewTaggedStat("backlog_quota_jobs", stats.GaugeType, tags).Gauge(backlog.Jobs)
+		t.stats.NewTaggedStat("backlog_quota_payload_bytes", stats.GaugeType, tags).Gauge(backlog.PayloadBytes)
+
+		maxJobs := t.conf.GetInt64Var(0, 1, "BacklogQuota."+workspaceID+".maxJobs", "BacklogQuota.maxJobs")
+		maxPayloadBytes := t.conf.GetInt64Var(0, bytesize.MB, "BacklogQuota."+workspaceID+".maxPayloadSizeInMB", "BacklogQuota.maxPayloadSizeInMB")
+		var u float64
+		if maxJobs > 0 {
+			u = max(u, float64(backlog.Jobs)/float64(maxJobs))
+		}
+		if maxPayloadBytes > 0 {
+			u = max(u, float64(backlog.PayloadBytes)/float64(maxPayloadBytes))
+		}
+		if u > 0 {
+			usage[workspaceID] = u
+			t.stats.NewTaggedStat("backlog_quota_usage", stats.GaugeType, tags).Gauge(u)
+		}
+		if u >= 1 {
+			t.logger.Warnn("Workspace exceeds its backlog quota",
+				obskit.WorkspaceID(workspaceID),
+				logger.NewIntField("jobs", backlog.Jobs),
+				logger.NewIntField("payloadBytes", backlog.PayloadBytes),
+			)
+		}
+	}
+	t.setUsage(usage)
+}
+
+func (t *Tracker) setUsage(usage map[string]float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage = usage
+}
@@ -0,0 +1,157 @@
+package backlogquota
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/bytesize"
+	"github.com/rudderlabs/rudder-go-kit/config"
+	"github.com/rudderlabs/rudder-go-kit/logger"
+	"github.com/rudderlabs/rudder-go-kit/stats"
+	"github.com/rudderlabs/rudder-go-kit/stats/memstats"
+
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/rmetrics"
+)
+
+type fakeReader struct {
+	backlogs map[string]jobsdb.WorkspaceBacklog
+	err      error
+}
+
+func (r *fakeReader) GetWorkspaceBacklogs(context.Context) (map[string]jobsdb.WorkspaceBacklog, error) {
+	return r.backlogs, r.err
+}
+
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	c := config.New()
+	c.Set("BacklogQuota.enabled", true)
+	c.Set("BacklogQuota.maxJobs", 100)
+	c.Set("BacklogQuota.ws-3.maxJobs", 1000)
+	c.Set("BacklogQuota.maxPayloadSizeInMB", 1)
+	c.Set("BacklogQuota.scanInterval", 0)
+	statsStore, err := memstats.New()
+	require.NoError(t, err)
+
+	gw := &fakeReader{backlogs: map[string]jobsdb.WorkspaceBacklog{
+		"ws-1": {Jobs: 10, PayloadBytes: 1000},
+		"ws-2": {Jobs: 60, PayloadBytes: 1000},
+		"ws-3": {Jobs: 500, PayloadBytes: 1000},
+		"ws-4": {Jobs: 1, PayloadBytes: 2 * bytesize.MB},
+	}}
+	rt := &fakeReader{backlogs: map[string]jobsdb.WorkspaceBacklog{
+		"ws-2": {Jobs: 50, PayloadBytes: 1000},
+		"ws-5": {Jobs: 90, PayloadBytes: 1000},
+	}}
+	tracker := NewTracker(c, statsStore, logger.NOP, gw, rt)
+	tracker.poll(ctx)
+
+	admitted := func(workspaceID string) int {
+		var n int
+		for range 1000 {
+			if ok, retryAfter := tracker.Admit(workspaceID); ok {
+				n++
+			} else {
+				require.Equal(t, tracker.config.pollInterval.Load(), retryAfter)
+			}
+		}
+		return n
+	}
+
+	t.Run("within quota", func(t *testing.T) {
+		require.Equal(t, 1000, admitted("ws-1"))
+		require.Equal(t, 1000, admitted("ws-3"), "workspace quota overrides the default one")
+		require.Equal(t, 1000, admitted("unknown"))
+	})
+
+	t.Run("slowing down", func(t *testing.T) {
+		n := admitted("ws-5")
+		require.Greater(t, n, 0)
+		require.Less(t, n, 1000)
+	})
+
+	t.Run("quota exceeded", func(t *testing.T) {
+		require.Zero(t, admitted("ws-2"), "backlogs of all jobsdbs are summed up")
+		require.Zero(t, admitted("ws-4"), "payload size quota")
+		require.EqualValues(t, 110, statsStore.Get("backlog_quota_jobs", stats.Tags{"workspaceId": "ws-2"}).LastValue())
+		require.EqualValues(t, 1.1, statsStore.Get("backlog_quota_usage", stats.Tags{"workspaceId": "ws-2"}).LastValue())
+	})
+
+	t.Run("quotas are not enforced if backlogs cannot be read", func(t *testing.T) {
+		rt.err = errors.New("read failed")
+		tracker.poll(ctx)
+		require.Equal(t, 1000, admitted("ws-2"))
+		require.EqualValues(t, 1, statsStore.Get("backlog_quota_poll_errors", nil).LastValue())
+		rt.err = nil
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracker.poll(ctx)
+		require.Zero(t, admitted("ws-2"))
+
+		c.Set("BacklogQuota.enabled", false)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		tracker.Run(ctx)
+		require.Equal(t, 1000, admitted("ws-2"))
+	})
+}
+
+func TestTrackerReaders(t *testing.T) {
+	ctx := context.Background()
+	c := config.New()
+	c.Set("BacklogQuota.enabled", true)
+	c.Set("BacklogQuota.maxJobs", 100)
+	registry := rmetrics.NewPendingEventsRegistry()
+	gw := &fakeReader{backlogs: map[string]jobsdb.WorkspaceBacklog{"ws-1": {Jobs: 50}}}
+	tracker := NewTracker(c, stats.NOP, logger.NOP, gw, NewPendingEventsReader(registry, "rt", "batch_rt"))
+
+	tracker.poll(ctx)
+	ok, _ := tracker.Admit("ws-1")
+	require.True(t, ok)
+
+	t.Run("pending events are read on every poll", func(t *testing.T) {
+		registry.IncreasePendingEvents("rt", "ws-1", "WEBHOOK", 30)
+		registry.IncreasePendingEvents("batch_rt", "ws-1", "S3", 30)
+		registry.IncreasePendingEvents("proc_error", "ws-1", "S3", 1000)
+		tracker.poll(ctx)
+		ok, _ := tracker.Admit("ws-1")
+		require.False(t, ok)
+	})
+
+	t.Run("jobsdbs are only scanned every scan interval", func(t *testing.T) {
+		gw.backlogs = map[string]jobsdb.WorkspaceBacklog{}
+		tracker.poll(ctx)
+		ok, _ := tracker.Admit("ws-1")
+		require.False(t, ok, "the gateway backlog of the last scan should still count")
+
+		c.Set("BacklogQuota.scanInterval", 0)
+		tracker.poll(ctx)
+		require.Equal(t, 60.0/100, tracker.usage["ws-1"])
+	})
+}
+
+func TestPayloadSizeReader(t *testing.T) {
+	ctx := context.Background()
+	c := config.New()
+	c.Set("BacklogQuota.enabled", true)
+	c.Set("BacklogQuota.maxJobs", 100)
+	c.Set("BacklogQuota.maxPayloadSizeInMB", 1)
+	registry := rmetrics.NewPendingEventsRegistry()
+	registry.IncreasePendingEvents("rt", "ws-1", "WEBHOOK", 50)
+	rt := &fakeReader{backlogs: map[string]jobsdb.WorkspaceBacklog{"ws-1": {Jobs: 50, PayloadBytes: bytesize.MB / 2}}}
+	tracker := NewTracker(c, stats.NOP, logger.NOP, NewPendingEventsReader(registry, "rt"), NewPayloadSizeReader(rt))
+
+	tracker.poll(ctx)
+	require.Equal(t, 0.5, tracker.usage["ws-1"], "jobs of the pending events shouldn't be counted twice")
+
+	rt.backlogs = map[string]jobsdb.WorkspaceBacklog{"ws-1": {Jobs: 50, PayloadBytes: 2 * bytesize.MB}}
+	c.Set("BacklogQuota.scanInterval", 0)
+	tracker.poll(ctx)
+	ok, _ := tracker.Admit("ws-1")
+	require.False(t, ok, "the payload size of router backlogs should count")
+}
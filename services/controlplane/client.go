@@ -3,12 +3,14 @@ package controlplane
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -82,6 +84,36 @@ type SSHKeyPair struct {
 	PrivateKey string
 }
 
+// Transformation is a version of a user transformation
+type Transformation struct {
+	ID          string `json:"id"`
+	VersionID   string `json:"versionId"`
+	Name        string `json:"name"`
+	Code        string `json:"code"`
+	CodeVersion string `json:"codeVersion"`
+	Language    string `json:"language"`
+}
+
+// TrackingPlan is a version of a tracking plan
+type TrackingPlan struct {
+	ID      string            `json:"id"`
+	Version int               `json:"version"`
+	Name    string            `json:"name"`
+	Rules   TrackingPlanRules `json:"rules"`
+}
+
+type TrackingPlanRules struct {
+	Events []TrackingPlanEvent `json:"events"`
+}
+
+// TrackingPlanEvent is an event planned in a tracking plan, along with the JSON Schema its messages need to conform to.
+// Track events are identified by their name, other events by their type.
+type TrackingPlanEvent struct {
+	Name      string          `json:"name"`
+	EventType string          `json:"eventType"`
+	Rules     json.RawMessage `json:"rules"`
+}
+
 func hostname() string {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -277,3 +309,66 @@ func (c *Client) DestinationHistory(ctx context.Context, revisionID string) (bac
 
 	return destination, err
 }
+
+// TransformationByVersionID returns the version of a user transformation
+func (c *Client) TransformationByVersionID(ctx context.Context, versionID string) (Transformation, error) {
+	urlValues := url.Values{}
+	urlValues.Set("versionId", versionID)
+	urlStr := fmt.Sprintf("%s/transformation/getByVersionId?%s", c.url, urlValues.Encode())
+
+	var transformation Transformation
+	err := c.getJSON(ctx, urlStr, &transformation)
+	return transformation, err
+}
+
+// TrackingPlanByVersion returns the version of a tracking plan
+func (c *Client) TrackingPlanByVersion(ctx context.Context, trackingPlanID string, version int) (TrackingPlan, error) {
+	urlValues := url.Values{}
+	urlValues.Set("version", strconv.Itoa(version))
+	urlStr := fmt.Sprintf("%s/workspaces/trackingplans/%s?%s", c.url, url.PathEscape(trackingPlanID), urlValues.Encode())
+
+	var trackingPlan TrackingPlan
+	err := c.getJSON(ctx, urlStr, &trackingPlan)
+	return trackingPlan, err
+}
+
+// getJSON gets the url, retrying on retriable errors, and decodes the JSON response body into v
+func (c *Client) getJSON(ctx context.Context, urlStr string, v any) error {
+	return c.retry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, http.NoBody)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", c.ua)
+
+		req.SetBasicAuth(c.identity.BasicAuth())
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { httputil.CloseResponse(resp) }()
+
+		if resp.StatusCode != http.StatusOK {
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return fmt.Errorf("read response body: %w", err)
+			}
+
+			err = fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(b))
+			if !httputil.RetriableStatus(resp.StatusCode) {
+				return backoff.Permanent(fmt.Errorf("non retriable: %w", err))
+			}
+			return err
+		}
+
+		err = jsonrs.NewDecoder(resp.Body).Decode(v)
+		if err != nil {
+			return fmt.Errorf("unmarshal response body: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -2,6 +2,7 @@ package controlplane_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -249,6 +250,56 @@ func TestDestinationHistory(t *testing.T) {
 	}
 }
 
+func TestTransformationByVersionID(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _, _ := r.BasicAuth()
+		require.Equal(t, "valid-secret", u)
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/transformation/getByVersionId", r.URL.Path)
+		require.Equal(t, "version-1", r.URL.Query().Get("versionId"))
+		_, _ = w.Write([]byte(`{"id":"transformation-1","versionId":"version-1","name":"test","code":"export function transformEvent(event) { return event; }","codeVersion":"1","language":"javascript"}`))
+	}))
+	defer s.Close()
+
+	c := controlplane.NewClient(s.URL, &identity.Workspace{WorkspaceID: "valid-workspace-id", WorkspaceToken: "valid-secret"}, controlplane.WithHTTPClient(s.Client()))
+	transformation, err := c.TransformationByVersionID(context.Background(), "version-1")
+	require.NoError(t, err)
+	require.Equal(t, controlplane.Transformation{
+		ID:          "transformation-1",
+		VersionID:   "version-1",
+		Name:        "test",
+		Code:        "export function transformEvent(event) { return event; }",
+		CodeVersion: "1",
+		Language:    "javascript",
+	}, transformation)
+}
+
+func TestTrackingPlanByVersion(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _, _ := r.BasicAuth()
+		require.Equal(t, "valid-secret", u)
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/workspaces/trackingplans/tp-1", r.URL.Path)
+		require.Equal(t, "3", r.URL.Query().Get("version"))
+		_, _ = w.Write([]byte(`{"id":"tp-1","version":3,"name":"test","rules":{"events":[{"name":"Product Viewed","eventType":"track","rules":{"type":"object"}}]}}`))
+	}))
+	defer s.Close()
+
+	c := controlplane.NewClient(s.URL, &identity.Workspace{WorkspaceID: "valid-workspace-id", WorkspaceToken: "valid-secret"}, controlplane.WithHTTPClient(s.Client()))
+	trackingPlan, err := c.TrackingPlanByVersion(context.Background(), "tp-1", 3)
+	require.NoError(t, err)
+	require.Equal(t, controlplane.TrackingPlan{
+		ID:      "tp-1",
+		Version: 3,
+		Name:    "test",
+		Rules: controlplane.TrackingPlanRules{
+			Events: []controlplane.TrackingPlanEvent{
+				{Name: "Product Viewed", EventType: "track", Rules: json.RawMessage(`{"type":"object"}`)},
+			},
+		},
+	}, trackingPlan)
+}
+
 func TestRetriesTimeout(t *testing.T) {
 	t.Log("all methods should exhibit the same retry and timeout behavior")
 	methods := []struct {
@@ -268,6 +319,20 @@ func TestRetriesTimeout(t *testing.T) {
 				return err
 			},
 		},
+		{
+			name: "TransformationByVersionID",
+			fn: func(c *controlplane.Client) error {
+				_, err := c.TransformationByVersionID(context.Background(), "test")
+				return err
+			},
+		},
+		{
+			name: "TrackingPlanByVersion",
+			fn: func(c *controlplane.Client) error {
+				_, err := c.TrackingPlanByVersion(context.Background(), "test", 1)
+				return err
+			},
+		},
 	}
 
 	for _, m := range methods {
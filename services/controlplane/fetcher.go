@@ -0,0 +1,31 @@
+package controlplane
+
+import (
+	"context"
+
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+)
+
+// NewFetcher returns a [Fetcher] of the versioned artifacts which embedded transformers run in-process,
+// authenticating with the identity of the backend config
+func NewFetcher(bc backendconfig.BackendConfig) *Fetcher {
+	return &Fetcher{backendConfig: bc}
+}
+
+// Fetcher fetches user transformations and tracking plans from the control plane
+type Fetcher struct {
+	backendConfig backendconfig.BackendConfig
+}
+
+func (f *Fetcher) TransformationByVersionID(ctx context.Context, versionID string) (Transformation, error) {
+	return f.client().TransformationByVersionID(ctx, versionID)
+}
+
+func (f *Fetcher) TrackingPlanByVersion(ctx context.Context, trackingPlanID string, version int) (TrackingPlan, error) {
+	return f.client().TrackingPlanByVersion(ctx, trackingPlanID, version)
+}
+
+// client returns a client with the current identity of the backend config, which is only known once the config is loaded
+func (f *Fetcher) client() *Client {
+	return NewClient(backendconfig.GetConfigBackendURL(), f.backendConfig.Identity())
+}
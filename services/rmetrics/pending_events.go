@@ -25,6 +25,8 @@ type PendingEventsRegistry interface {
 	DecreasePendingEvents(tablePrefix, workspace, destType string, value float64)
 	// PendingEvents gets the measurement for pending events metric
 	PendingEvents(tablePrefix, workspace, destType string) metric.Gauge
+	// PendingEventsByWorkspace returns the pending events of every workspace in a jobsdb, across all destination types
+	PendingEventsByWorkspace(tablePrefix string) map[string]float64
 	// Publish publishes the metrics to the global published metrics registry
 	Publish()
 	// Reset resets the registry to a new, non published one and clears the global published metrics registry
@@ -86,6 +88,24 @@ func (pem *pendingEventsRegistry) PendingEvents(tablePrefix, workspace, destType
 	return pem.registry.MustGetGauge(newPendingEventsMeasurement(tablePrefix, workspace, destType))
 }
 
+// PendingEventsByWorkspace returns the pending events of every workspace in a jobsdb, across all destination types
+func (pem *pendingEventsRegistry) PendingEventsByWorkspace(tablePrefix string) map[string]float64 {
+	pem.registryMu.RLock()
+	defer pem.registryMu.RUnlock()
+	pending := make(map[string]float64)
+	pem.registry.Range(func(key, value any) bool {
+		m, ok := key.(pendingEventsMeasurement)
+		if !ok || m.tablePrefix != tablePrefix || m.workspace == All || m.destType == All {
+			return true
+		}
+		if gauge, ok := value.(metric.Gauge); ok {
+			pending[m.workspace] += gauge.Value()
+		}
+		return true
+	})
+	return pending
+}
+
 // Publish publishes the metrics to the global published metrics registry
 func (pem *pendingEventsRegistry) Publish() {
 	pem.registryMu.Lock()
@@ -88,4 +88,18 @@ func TestPendingEventsRegistry(t *testing.T) {
 		})
 		require.Equal(t, 5, metricsCount, "a publish after a reset should publish any pending events recorded after reset")
 	})
+
+	t.Run("by workspace", func(t *testing.T) {
+		mi.Reset()
+		r := rmetrics.NewPendingEventsRegistry()
+		r.IncreasePendingEvents(tablePrefix, workspace, destType, 3)
+		r.IncreasePendingEvents(tablePrefix, workspace, "otherDestType", 2)
+		r.IncreasePendingEvents(tablePrefix, "otherWorkspace", destType, 1)
+		r.IncreasePendingEvents("otherTablePrefix", workspace, destType, 10)
+		r.DecreasePendingEvents(tablePrefix, workspace, destType, 1)
+		require.Equal(t, map[string]float64{workspace: 4, "otherWorkspace": 1}, r.PendingEventsByWorkspace(tablePrefix))
+
+		r.Publish()
+		require.Equal(t, map[string]float64{workspace: 4, "otherWorkspace": 1}, r.PendingEventsByWorkspace(tablePrefix))
+	})
 }
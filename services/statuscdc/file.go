@@ -0,0 +1,57 @@
+package statuscdc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+
+	"github.com/rudderlabs/rudder-server/jobsdb"
+)
+
+// FileSink appends status transitions to a local file, one JSON object per line
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileSink creates a sink appending to the file at path, creating it if needed
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file path cannot be empty")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening file %q: %w", path, err)
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Publish appends the transitions to the file and syncs it, so that they are durable once Publish returns
+func (s *FileSink) Publish(_ context.Context, transitions []jobsdb.StatusTransition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := bufio.NewWriter(s.f)
+	for i := range transitions {
+		line, err := jsonrs.Marshal(transitions[i])
+		if err != nil {
+			return fmt.Errorf("marshalling status transition: %w", err)
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("writing status transition: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("flushing status transitions: %w", err)
+	}
+	return s.f.Sync()
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,88 @@
+package statuscdc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	client "github.com/rudderlabs/rudder-go-kit/kafkaclient"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+	backendconfig "github.com/rudderlabs/rudder-server/backend-config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/streammanager/common"
+	"github.com/rudderlabs/rudder-server/services/streammanager/kafka"
+)
+
+// KafkaConfig is the configuration of the kafka cluster and topic status transitions are published to
+type KafkaConfig struct {
+	HostName      string // comma separated list of hosts
+	Port          string
+	Topic         string
+	SslEnabled    bool
+	CACertificate string
+	UseSASL       bool
+	SaslType      string
+	Username      string
+	Password      string
+	Timeout       time.Duration
+}
+
+type kafkaPublisher interface {
+	Publish(ctx context.Context, msgs ...client.Message) error
+	Close() error
+}
+
+// KafkaSink publishes every status transition as a JSON message keyed by job ID,
+// so that the transitions of a job end up in the same partition, in order.
+type KafkaSink struct {
+	topic    string
+	timeout  time.Duration
+	producer kafkaPublisher
+}
+
+// NewKafkaSink creates a sink using the kafka producer of the kafka stream destination
+func NewKafkaSink(c KafkaConfig) (*KafkaSink, error) {
+	producer, err := kafka.NewProducer(&backendconfig.DestinationT{
+		ID: "jobsdb-status-cdc",
+		Config: map[string]interface{}{
+			"hostName":      c.HostName,
+			"port":          c.Port,
+			"topic":         c.Topic,
+			"sslEnabled":    c.SslEnabled,
+			"caCertificate": c.CACertificate,
+			"useSASL":       c.UseSASL,
+			"saslType":      c.SaslType,
+			"username":      c.Username,
+			"password":      c.Password,
+		},
+	}, common.Opts{Timeout: c.Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("creating kafka producer: %w", err)
+	}
+	return &KafkaSink{topic: c.Topic, timeout: c.Timeout, producer: producer}, nil
+}
+
+func (s *KafkaSink) Publish(ctx context.Context, transitions []jobsdb.StatusTransition) error {
+	msgs := make([]client.Message, 0, len(transitions))
+	for i := range transitions {
+		value, err := jsonrs.Marshal(transitions[i])
+		if err != nil {
+			return fmt.Errorf("marshalling status transition: %w", err)
+		}
+		msgs = append(msgs, client.Message{
+			Topic:     s.topic,
+			Key:       []byte(transitions[i].TablePrefix + ":" + strconv.FormatInt(transitions[i].JobID, 10)),
+			Value:     value,
+			Timestamp: transitions[i].ExecTime,
+		})
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.producer.Publish(ctx, msgs...)
+}
+
+func (s *KafkaSink) Close() error {
+	return s.producer.Close()
+}
@@ -0,0 +1,57 @@
+// Package statuscdc provides the sinks which the change-data-capture stream of job status transitions can be published to.
+package statuscdc
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+
+	"github.com/rudderlabs/rudder-server/jobsdb"
+)
+
+// Sink is a closeable jobsdb status transition sink
+type Sink interface {
+	jobsdb.StatusTransitionSink
+	io.Closer
+}
+
+// New creates the sink configured through JobsDB.statusCDC.sink, one of file, kafka or webhook.
+// It returns a nil sink if none is configured. The sink needs to be closed after use.
+func New(conf *config.Config) (Sink, error) {
+	switch sinkType := conf.GetStringVar("", "JobsDB.statusCDC.sink"); sinkType {
+	case "":
+		return nil, nil
+	case "file":
+		return sinkOrError(NewFileSink(conf.GetStringVar("", "JobsDB.statusCDC.file.path")))
+	case "kafka":
+		return sinkOrError(NewKafkaSink(KafkaConfig{
+			HostName:      conf.GetStringVar("", "JobsDB.statusCDC.kafka.hostName"),
+			Port:          conf.GetStringVar("9092", "JobsDB.statusCDC.kafka.port"),
+			Topic:         conf.GetStringVar("", "JobsDB.statusCDC.kafka.topic"),
+			SslEnabled:    conf.GetBoolVar(false, "JobsDB.statusCDC.kafka.sslEnabled"),
+			CACertificate: conf.GetStringVar("", "JobsDB.statusCDC.kafka.caCertificate"),
+			UseSASL:       conf.GetBoolVar(false, "JobsDB.statusCDC.kafka.useSASL"),
+			SaslType:      conf.GetStringVar("", "JobsDB.statusCDC.kafka.saslType"),
+			Username:      conf.GetStringVar("", "JobsDB.statusCDC.kafka.username"),
+			Password:      conf.GetStringVar("", "JobsDB.statusCDC.kafka.password"),
+			Timeout:       conf.GetDurationVar(10, time.Second, "JobsDB.statusCDC.kafka.timeout"),
+		}))
+	case "webhook":
+		return sinkOrError(NewWebhookSink(
+			conf.GetStringVar("", "JobsDB.statusCDC.webhook.url"),
+			conf.GetDurationVar(30, time.Second, "JobsDB.statusCDC.webhook.timeout"),
+		))
+	default:
+		return nil, fmt.Errorf("unknown status cdc sink %q, expected one of file, kafka, webhook", sinkType)
+	}
+}
+
+// sinkOrError avoids returning a non-nil Sink wrapping a nil pointer on error
+func sinkOrError[S Sink](sink S, err error) (Sink, error) {
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
@@ -0,0 +1,99 @@
+package statuscdc_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-go-kit/config"
+
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/statuscdc"
+)
+
+var transitions = []jobsdb.StatusTransition{
+	{TablePrefix: "rt", JobID: 1, WorkspaceID: "ws-1", SourceID: "src-1", DestinationID: "dest-1", State: "executing", AttemptNum: 1, ExecTime: time.Now().UTC().Truncate(time.Second)},
+	{TablePrefix: "rt", JobID: 1, WorkspaceID: "ws-1", SourceID: "src-1", DestinationID: "dest-1", State: "failed", ErrorCode: "500", AttemptNum: 1, ExecTime: time.Now().UTC().Truncate(time.Second)},
+}
+
+func TestNew(t *testing.T) {
+	t.Run("no sink", func(t *testing.T) {
+		sink, err := statuscdc.New(config.New())
+		require.NoError(t, err)
+		require.Nil(t, sink)
+	})
+
+	t.Run("unknown sink", func(t *testing.T) {
+		c := config.New()
+		c.Set("JobsDB.statusCDC.sink", "carrier-pigeon")
+		_, err := statuscdc.New(c)
+		require.Error(t, err)
+	})
+
+	t.Run("invalid sink configuration", func(t *testing.T) {
+		c := config.New()
+		c.Set("JobsDB.statusCDC.sink", "webhook")
+		sink, err := statuscdc.New(c)
+		require.Error(t, err)
+		require.Nil(t, sink)
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transitions.jsonl")
+	c := config.New()
+	c.Set("JobsDB.statusCDC.sink", "file")
+	c.Set("JobsDB.statusCDC.file.path", path)
+	sink, err := statuscdc.New(c)
+	require.NoError(t, err)
+	require.NoError(t, sink.Publish(context.Background(), transitions[:1]))
+	require.NoError(t, sink.Publish(context.Background(), transitions[1:]))
+	require.NoError(t, sink.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	var written []jobsdb.StatusTransition
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var transition jobsdb.StatusTransition
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &transition))
+		written = append(written, transition)
+	}
+	require.Equal(t, transitions, written)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received []jobsdb.StatusTransition
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var batch []jobsdb.StatusTransition
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		received = append(received, batch...)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := config.New()
+	c.Set("JobsDB.statusCDC.sink", "webhook")
+	c.Set("JobsDB.statusCDC.webhook.url", srv.URL)
+	sink, err := statuscdc.New(c)
+	require.NoError(t, err)
+	defer func() { _ = sink.Close() }()
+
+	require.NoError(t, sink.Publish(context.Background(), transitions))
+	require.Equal(t, transitions, received)
+
+	status = http.StatusServiceUnavailable
+	require.Error(t, sink.Publish(context.Background(), transitions), "non 2xx responses should fail the publish")
+}
@@ -0,0 +1,60 @@
+package statuscdc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rudderlabs/rudder-go-kit/jsonrs"
+
+	"github.com/rudderlabs/rudder-server/jobsdb"
+)
+
+// WebhookSink posts status transitions as a JSON array to a webhook.
+// Any response other than 2xx is treated as a failure, so that the transitions get published again.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink posting to webhookURL
+func NewWebhookSink(webhookURL string, timeout time.Duration) (*WebhookSink, error) {
+	if _, err := url.ParseRequestURI(webhookURL); err != nil {
+		return nil, fmt.Errorf("invalid webhook url %q: %w", webhookURL, err)
+	}
+	return &WebhookSink{
+		url:    webhookURL,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, transitions []jobsdb.StatusTransition) error {
+	body, err := jsonrs.Marshal(transitions)
+	if err != nil {
+		return fmt.Errorf("marshalling status transitions: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting status transitions: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,310 @@
+// Package useragent parses user agent strings into their browser, operating system and device,
+// using a database of regexes in the format of https://github.com/ua-parser/uap-core.
+//
+// A default database is embedded, which can be replaced by a file in the same format.
+// The type of the device, e.g. mobile, tablet or desktop, is derived from the families of its device and operating system,
+// and parsed user agents are cached by their string.
+package useragent
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed regexes.yaml
+var defaultRegexes []byte
+
+// DefaultCacheSize is the number of parsed user agents cached by default
+const DefaultCacheSize = 10000
+
+// Other is the family of browsers, operating systems and devices which aren't recognised
+const Other = "Other"
+
+// UserAgent is a parsed user agent
+type UserAgent struct {
+	Browser Browser
+	OS      OS
+	Device  Device
+}
+
+type Browser struct {
+	Family string
+	Major  string
+	Minor  string
+	Patch  string
+}
+
+// Version returns the version of the browser, e.g. 120.0.6099
+func (b Browser) Version() string { return joinVersion(b.Major, b.Minor, b.Patch) }
+
+type OS struct {
+	Family     string
+	Major      string
+	Minor      string
+	Patch      string
+	PatchMinor string
+}
+
+// Version returns the version of the operating system, e.g. 10.15.7
+func (o OS) Version() string { return joinVersion(o.Major, o.Minor, o.Patch, o.PatchMinor) }
+
+type Device struct {
+	Family string
+	Brand  string
+	Model  string
+	Type   string // e.g. mobile, tablet, desktop, tv, console or bot
+}
+
+func joinVersion(parts ...string) string {
+	var version []string
+	for _, part := range parts {
+		if part == "" {
+			break
+		}
+		version = append(version, part)
+	}
+	return strings.Join(version, ".")
+}
+
+type regexesFile struct {
+	UserAgentParsers []struct {
+		Regex             string `yaml:"regex"`
+		FamilyReplacement string `yaml:"family_replacement"`
+		V1Replacement     string `yaml:"v1_replacement"`
+		V2Replacement     string `yaml:"v2_replacement"`
+		V3Replacement     string `yaml:"v3_replacement"`
+	} `yaml:"user_agent_parsers"`
+	OSParsers []struct {
+		Regex           string `yaml:"regex"`
+		OSReplacement   string `yaml:"os_replacement"`
+		OSV1Replacement string `yaml:"os_v1_replacement"`
+		OSV2Replacement string `yaml:"os_v2_replacement"`
+		OSV3Replacement string `yaml:"os_v3_replacement"`
+		OSV4Replacement string `yaml:"os_v4_replacement"`
+		RegexFlag       string `yaml:"regex_flag"`
+	} `yaml:"os_parsers"`
+	DeviceParsers []struct {
+		Regex             string `yaml:"regex"`
+		RegexFlag         string `yaml:"regex_flag"`
+		DeviceReplacement string `yaml:"device_replacement"`
+		BrandReplacement  string `yaml:"brand_replacement"`
+		ModelReplacement  string `yaml:"model_replacement"`
+	} `yaml:"device_parsers"`
+}
+
+// parser extracts the fields of a user agent matching its regex.
+// Each field is either its replacement, in which $1 to $9 refer to the groups of the regex, or the group at its default index.
+type parser struct {
+	regex        *regexp.Regexp
+	replacements []string
+}
+
+func newParser(regex, flag string, replacements ...string) (parser, error) {
+	if flag == "i" {
+		regex = "(?i)" + regex
+	}
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return parser{}, fmt.Errorf("compiling regex %q: %w", regex, err)
+	}
+	return parser{regex: r, replacements: replacements}, nil
+}
+
+// match returns the fields extracted from the user agent, or nil if it doesn't match the regex
+func (p parser) match(userAgent string, defaultGroups ...int) []string {
+	groups := p.regex.FindStringSubmatch(userAgent)
+	if groups == nil {
+		return nil
+	}
+	fields := make([]string, len(p.replacements))
+	for i, replacement := range p.replacements {
+		if replacement != "" {
+			fields[i] = strings.TrimSpace(expand(replacement, groups))
+		} else if group := defaultGroups[i]; group > 0 && group < len(groups) {
+			fields[i] = groups[group]
+		}
+	}
+	return fields
+}
+
+var groupReferenceRegex = regexp.MustCompile(`\$(\d)`)
+
+func expand(replacement string, groups []string) string {
+	return groupReferenceRegex.ReplaceAllStringFunc(replacement, func(ref string) string {
+		i, _ := strconv.Atoi(ref[1:])
+		if i < len(groups) {
+			return groups[i]
+		}
+		return ""
+	})
+}
+
+// Parser parses user agents
+type Parser struct {
+	browsers []parser
+	oses     []parser
+	devices  []parser
+
+	cacheSize int
+	cache     *lru.Cache[string, UserAgent]
+}
+
+type Opt func(*Parser)
+
+// WithCacheSize sets the number of parsed user agents to cache, disabling the cache if it isn't positive
+func WithCacheSize(size int) Opt {
+	return func(p *Parser) {
+		p.cacheSize = size
+	}
+}
+
+// NewDefault returns a parser using the embedded database of regexes
+func NewDefault(opts ...Opt) (*Parser, error) {
+	return New(defaultRegexes, opts...)
+}
+
+// NewFromFile returns a parser using the database of regexes in the file
+func NewFromFile(path string, opts ...Opt) (*Parser, error) {
+	regexes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading regexes file: %w", err)
+	}
+	return New(regexes, opts...)
+}
+
+// New returns a parser using the database of regexes, in the yaml format of uap-core
+func New(regexes []byte, opts ...Opt) (*Parser, error) {
+	var f regexesFile
+	if err := yaml.Unmarshal(regexes, &f); err != nil {
+		return nil, fmt.Errorf("unmarshalling regexes: %w", err)
+	}
+	p := &Parser{cacheSize: DefaultCacheSize}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.cacheSize > 0 {
+		p.cache = lo.Must(lru.New[string, UserAgent](p.cacheSize))
+	}
+	for _, b := range f.UserAgentParsers {
+		parser, err := newParser(b.Regex, "", b.FamilyReplacement, b.V1Replacement, b.V2Replacement, b.V3Replacement)
+		if err != nil {
+			return nil, fmt.Errorf("user agent parser: %w", err)
+		}
+		p.browsers = append(p.browsers, parser)
+	}
+	for _, o := range f.OSParsers {
+		parser, err := newParser(o.Regex, o.RegexFlag, o.OSReplacement, o.OSV1Replacement, o.OSV2Replacement, o.OSV3Replacement, o.OSV4Replacement)
+		if err != nil {
+			return nil, fmt.Errorf("os parser: %w", err)
+		}
+		p.oses = append(p.oses, parser)
+	}
+	for _, d := range f.DeviceParsers {
+		parser, err := newParser(d.Regex, d.RegexFlag, d.DeviceReplacement, d.BrandReplacement, d.ModelReplacement)
+		if err != nil {
+			return nil, fmt.Errorf("device parser: %w", err)
+		}
+		p.devices = append(p.devices, parser)
+	}
+	return p, nil
+}
+
+// Parse parses the user agent. The families of unrecognised browsers, operating systems and devices are [Other].
+func (p *Parser) Parse(userAgent string) UserAgent {
+	if p.cache == nil {
+		return p.parse(userAgent)
+	}
+	if ua, ok := p.cache.Get(userAgent); ok {
+		return ua
+	}
+	ua := p.parse(userAgent)
+	p.cache.Add(userAgent, ua)
+	return ua
+}
+
+func (p *Parser) parse(userAgent string) UserAgent {
+	ua := UserAgent{
+		Browser: Browser{Family: Other},
+		OS:      OS{Family: Other},
+		Device:  Device{Family: Other},
+	}
+	for _, parser := range p.browsers {
+		if f := parser.match(userAgent, 1, 2, 3, 4); f != nil {
+			ua.Browser = Browser{Family: f[0], Major: f[1], Minor: f[2], Patch: f[3]}
+			break
+		}
+	}
+	for _, parser := range p.oses {
+		if f := parser.match(userAgent, 1, 2, 3, 4, 5); f != nil {
+			ua.OS = OS{Family: f[0], Major: f[1], Minor: f[2], Patch: f[3], PatchMinor: f[4]}
+			break
+		}
+	}
+	for _, parser := range p.devices {
+		// the model defaults to the first group as well, as in uap-core
+		if f := parser.match(userAgent, 1, 0, 1); f != nil {
+			ua.Device = Device{Family: f[0], Brand: f[1], Model: f[2]}
+			break
+		}
+	}
+	if ua.Browser.Family == "" {
+		ua.Browser.Family = Other
+	}
+	if ua.OS.Family == "" {
+		ua.OS.Family = Other
+	}
+	if ua.Device.Family == "" {
+		ua.Device.Family = Other
+	}
+	ua.Device.Type = deviceType(ua.Device, ua.OS)
+	return ua
+}
+
+// deviceTypes are the types of the device families of uap-core, tried in order
+var deviceTypes = []struct {
+	family     *regexp.Regexp
+	deviceType string
+}{
+	{regexp.MustCompile(`^Spider$`), "bot"},
+	{regexp.MustCompile(`TV\b`), "tv"},
+	{regexp.MustCompile(`^(?:PlayStation|Xbox|Nintendo)`), "console"},
+	{regexp.MustCompile(`^(?:iPad|Kindle|Generic Tablet|Samsung SM-[TX])`), "tablet"},
+	{regexp.MustCompile(`^Mac$`), "desktop"},
+}
+
+// desktopOSes are the operating systems of desktops, whose devices uap-core doesn't recognise
+var desktopOSes = map[string]struct{}{
+	"Windows":   {},
+	"Mac OS X":  {},
+	"Linux":     {},
+	"Ubuntu":    {},
+	"Fedora":    {},
+	"Debian":    {},
+	"Chrome OS": {},
+}
+
+// deviceType returns the type of the device, i.e. bot, tv, console, tablet, desktop or mobile,
+// or an empty string if neither the device nor its operating system are recognised.
+// Other than the families above, the devices recognised by uap-core are phones.
+func deviceType(device Device, os OS) string {
+	if device.Family == Other {
+		if _, ok := desktopOSes[os.Family]; ok {
+			return "desktop"
+		}
+		return ""
+	}
+	for _, t := range deviceTypes {
+		if t.family.MatchString(device.Family) {
+			return t.deviceType
+		}
+	}
+	return "mobile"
+}
@@ -0,0 +1,148 @@
+package useragent_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/services/useragent"
+)
+
+func TestParse(t *testing.T) {
+	p, err := useragent.NewDefault()
+	require.NoError(t, err)
+
+	type expected struct {
+		browser, browserVersion string
+		os, osVersion           string
+		device, brand, model    string
+		deviceType              string
+	}
+	for userAgent, e := range map[string]expected{
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36": {
+			browser: "Chrome", browserVersion: "120.0.6099", os: "Mac OS X", osVersion: "10.15.7", device: "Mac", brand: "Apple", model: "Mac", deviceType: "desktop",
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91": {
+			browser: "Edge", browserVersion: "120.0.2210", os: "Windows", osVersion: "10", device: "Other", deviceType: "desktop",
+		},
+		"Mozilla/5.0 (Windows NT 6.1; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0": {
+			browser: "Firefox", browserVersion: "121.0", os: "Windows", osVersion: "7", device: "Other", deviceType: "desktop",
+		},
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0": {
+			browser: "Opera", browserVersion: "105.0.0", os: "Linux", device: "Other", deviceType: "desktop",
+		},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_2_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15": {
+			browser: "Safari", browserVersion: "17.2", os: "Mac OS X", osVersion: "14.2.1", device: "Mac", brand: "Apple", model: "Mac", deviceType: "desktop",
+		},
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1": {
+			browser: "Mobile Safari", browserVersion: "17.1.2", os: "iOS", osVersion: "17.1.2", device: "iPhone", brand: "Apple", model: "iPhone", deviceType: "mobile",
+		},
+		"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1": {
+			browser: "Chrome Mobile iOS", browserVersion: "120.0.6099", os: "iOS", osVersion: "16.6", device: "iPad", brand: "Apple", model: "iPad", deviceType: "tablet",
+		},
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8 Pro) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36": {
+			browser: "Chrome Mobile", browserVersion: "120.0.6099", os: "Android", osVersion: "14", device: "Pixel 8 Pro", brand: "Google", model: "Pixel 8 Pro", deviceType: "mobile",
+		},
+		"Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36": {
+			browser: "Samsung Internet", browserVersion: "23.0", os: "Android", osVersion: "13", device: "Samsung SM-S918B", brand: "Samsung", model: "SM-S918B", deviceType: "mobile",
+		},
+		"Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36": {
+			browser: "Chrome", browserVersion: "120.0.0", os: "Android", osVersion: "13", device: "Samsung SM-X710", brand: "Samsung", model: "SM-X710", deviceType: "tablet",
+		},
+		"Mozilla/5.0 (Linux; Android 12; Lenovo TB-X606F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36": {
+			browser: "Chrome", browserVersion: "119.0.0", os: "Android", osVersion: "12", device: "Generic Tablet", deviceType: "tablet",
+		},
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)": {
+			browser: "Googlebot", browserVersion: "2.1", os: "Other", device: "Spider", deviceType: "bot",
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; Xbox; Xbox One) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19041": {
+			browser: "Edge", browserVersion: "18.19041", os: "Windows", osVersion: "10", device: "Xbox", model: "Xbox", deviceType: "console",
+		},
+		"curl/8.4.0": {
+			browser: "curl", browserVersion: "8.4.0", os: "Other", device: "Other",
+		},
+		"": {
+			browser: "Other", os: "Other", device: "Other",
+		},
+	} {
+		ua := p.Parse(userAgent)
+		require.Equal(t, expected{
+			browser: ua.Browser.Family, browserVersion: ua.Browser.Version(),
+			os: ua.OS.Family, osVersion: ua.OS.Version(),
+			device: ua.Device.Family, brand: ua.Device.Brand, model: ua.Device.Model,
+			deviceType: ua.Device.Type,
+		}, e, userAgent)
+	}
+}
+
+func TestNewFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regexes.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+user_agent_parsers:
+  - regex: '(MyApp)/(\d+)\.(\d+)'
+    family_replacement: 'My $1'
+os_parsers:
+  - regex: 'myos (\d+)'
+    regex_flag: 'i'
+    os_replacement: 'MyOS'
+    os_v1_replacement: '$1'
+device_parsers:
+  - regex: '\((\w+) (\w+)\)'
+    device_replacement: '$1 $2'
+    brand_replacement: '$1'
+    model_replacement: '$2'
+`), 0o600))
+	p, err := useragent.NewFromFile(path)
+	require.NoError(t, err)
+
+	require.Equal(t, useragent.UserAgent{
+		Browser: useragent.Browser{Family: "My MyApp", Major: "2", Minor: "5"},
+		OS:      useragent.OS{Family: "MyOS", Major: "3"},
+		Device:  useragent.Device{Family: "Acme K1", Brand: "Acme", Model: "K1", Type: "mobile"},
+	}, p.Parse("MyApp/2.5 (Acme K1) MYOS 3"))
+
+	require.Equal(t, useragent.UserAgent{
+		Browser: useragent.Browser{Family: useragent.Other},
+		OS:      useragent.OS{Family: useragent.Other},
+		Device:  useragent.Device{Family: useragent.Other},
+	}, p.Parse("Mozilla/5.0"))
+
+	t.Run("invalid files", func(t *testing.T) {
+		_, err := useragent.NewFromFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		require.Error(t, err)
+		_, err = useragent.New([]byte("user_agent_parsers: invalid"))
+		require.Error(t, err)
+		_, err = useragent.New([]byte("user_agent_parsers:\n  - regex: '(?!unsupported)'"))
+		require.Error(t, err)
+	})
+}
+
+func TestParseCache(t *testing.T) {
+	uncached, err := useragent.NewDefault(useragent.WithCacheSize(0))
+	require.NoError(t, err)
+	cached, err := useragent.NewDefault(useragent.WithCacheSize(1))
+	require.NoError(t, err)
+
+	userAgents := []string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+	}
+	// parsing the same user agent twice hits the cache, alternating between them evicts it
+	for _, userAgent := range append(append(userAgents, userAgents...), userAgents[1]) {
+		require.Equal(t, uncached.Parse(userAgent), cached.Parse(userAgent), userAgent)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for name, size := range map[string]int{"uncached": 0, "cached": useragent.DefaultCacheSize} {
+		b.Run(name, func(b *testing.B) {
+			p, err := useragent.NewDefault(useragent.WithCacheSize(size))
+			require.NoError(b, err)
+			for range b.N {
+				p.Parse("Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36")
+			}
+		})
+	}
+}
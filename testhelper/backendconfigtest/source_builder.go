@@ -84,6 +84,12 @@ func (b *SourceBuilder) WithGeoenrichmentEnabled(enabled bool) *SourceBuilder {
 	return b
 }
 
+// WithUserAgentEnrichmentEnabled enables user agent enrichment for the source
+func (b *SourceBuilder) WithUserAgentEnrichmentEnabled(enabled bool) *SourceBuilder {
+	b.v.UserAgentEnrichment.Enabled = enabled
+	return b
+}
+
 // WithSourceCategory sets the source definition category
 func (b *SourceBuilder) WithSourceCategory(category string) *SourceBuilder {
 	b.v.SourceDefinition.Category = category
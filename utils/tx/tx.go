@@ -18,9 +18,15 @@ func (tx *Tx) AddSuccessListener(listener func()) {
 func (tx *Tx) Commit() error {
 	err := tx.Tx.Commit()
 	if err == nil {
-		for _, successListener := range tx.successListeners {
-			successListener()
-		}
+		tx.Committed()
 	}
 	return err
 }
+
+// Committed executes all listeners.
+// It is only meant to be called directly for transactions which are not backed by a sql.Tx, e.g. the ones of an embedded jobsdb.
+func (tx *Tx) Committed() {
+	for _, successListener := range tx.successListeners {
+		successListener()
+	}
+}
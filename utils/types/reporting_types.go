@@ -22,9 +22,11 @@ const (
 )
 
 const (
-	DiffStatus        = "diff"
-	BotFlaggedStatus  = "bot_flagged"
-	BotDetectedStatus = "bot_detected"
+	DiffStatus           = "diff"
+	BotFlaggedStatus     = "bot_flagged"
+	BotDetectedStatus    = "bot_detected"
+	FilteredByRuleStatus = "filtered_by_rule"
+	SampledOutStatus     = "sampled_out"
 
 	// Module names
 	BOT_MANAGEMENT         = "bot_management"
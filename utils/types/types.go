@@ -10,10 +10,12 @@ import (
 )
 
 const (
-	FilterEventCode   = 298
-	SuppressEventCode = 299
-	DrainEventCode    = 410
-	SuccessEventCode  = 200
+	SampledOutEventCode = 296 // events dropped by the sampling policy of their destination
+	FilterRuleEventCode = 297 // events dropped by the filtering rules of their connection
+	FilterEventCode     = 298
+	SuppressEventCode   = 299
+	DrainEventCode      = 410
+	SuccessEventCode    = 200
 
 	FlagBotEventAction = "flag"
 	DropBotEventAction = "drop"